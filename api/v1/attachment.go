@@ -0,0 +1,158 @@
+package v1
+
+import (
+	"io"
+	"net/http"
+	"sinartimur-go/internal/attachment"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// attachmentParentID parses the ID of the document or record an attachment belongs to
+func attachmentParentID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"id": "ID tidak valid",
+		}))
+		return "", false
+	}
+	return id.String(), true
+}
+
+// UploadAttachmentHandler uploads a file as multipart field "file" with an optional "description"
+func UploadAttachmentHandler(attachmentService *attachment.AttachmentService, parentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parentID, ok := attachmentParentID(w, r)
+		if !ok {
+			return
+		}
+
+		// Leave room for the multipart framing around the file
+		r.Body = http.MaxBytesReader(w, r.Body, attachment.MaxFileSize+1<<20)
+		if err := r.ParseMultipartForm(attachment.MaxFileSize); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusRequestEntityTooLarge, map[string]string{
+				"file": "File tidak valid atau melebihi 10 MB",
+			}))
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"file": "File wajib diunggah",
+			}))
+			return
+		}
+		defer file.Close()
+
+		req := attachment.UploadAttachmentRequest{
+			ParentType:  parentType,
+			ParentID:    parentID,
+			FileName:    header.Filename,
+			Description: r.FormValue("description"),
+		}
+		if errValidation := utils.ValidateStruct(req); errValidation != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, errValidation))
+			return
+		}
+
+		req.Content, err = io.ReadAll(io.LimitReader(file, attachment.MaxFileSize+1))
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"file": "Gagal membaca file",
+			}))
+			return
+		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+		req.UserID, _ = r.Context().Value("user_id").(string)
+
+		created, apiErr := attachmentService.Upload(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, created)
+	}
+}
+
+// GetAttachmentsHandler lists the attachments of a document or record
+func GetAttachmentsHandler(attachmentService *attachment.AttachmentService, parentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parentID, ok := attachmentParentID(w, r)
+		if !ok {
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		attachments, apiErr := attachmentService.GetAll(parentType, parentID, branchID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, attachments)
+	}
+}
+
+// DownloadAttachmentHandler downloads an attachment
+func DownloadAttachmentHandler(attachmentService *attachment.AttachmentService, parentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parentID, ok := attachmentParentID(w, r)
+		if !ok {
+			return
+		}
+		id, err := uuid.Parse(mux.Vars(r)["attachment_id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"attachment_id": "ID lampiran tidak valid",
+			}))
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		found, file, apiErr := attachmentService.Open(parentType, parentID, id.String(), branchID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Type", found.ContentType)
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+found.FileName+"\"")
+		w.Header().Set("Content-Length", strconv.FormatInt(found.Size, 10))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, file)
+	}
+}
+
+// DeleteAttachmentHandler deletes an attachment and its file
+func DeleteAttachmentHandler(attachmentService *attachment.AttachmentService, parentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parentID, ok := attachmentParentID(w, r)
+		if !ok {
+			return
+		}
+		id, err := uuid.Parse(mux.Vars(r)["attachment_id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"attachment_id": "ID lampiran tidak valid",
+			}))
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		if apiErr := attachmentService.Delete(parentType, parentID, id.String(), branchID); apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, utils.WriteMessage("Lampiran berhasil dihapus"))
+	}
+}
@@ -22,7 +22,7 @@ func LoginHandler(userService *auth.AuthService) http.HandlerFunc {
 		username := req.Username
 		password := req.Password
 
-		accessToken, refreshToken, userID, err, roles := userService.LoginUser(username, password)
+		accessToken, refreshToken, userID, err, roles, branches := userService.LoginUser(username, password)
 		if err != nil {
 			utils.ErrorJSON(w, err)
 			return
@@ -49,11 +49,12 @@ func LoginHandler(userService *auth.AuthService) http.HandlerFunc {
 			SameSite: http.SameSiteLaxMode,
 		})
 
-		// Return JSON response with username and roles
+		// Return JSON response with username, roles and branches
 		response := auth.LoginUserResponse{
 			Id:       userID,
 			Username: username,
 			Roles:    roles,
+			Branches: branches,
 		}
 
 		// convert response to json string
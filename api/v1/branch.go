@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/branch"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CreateBranchHandler creates a new branch
+func CreateBranchHandler(branchService *branch.BranchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req branch.CreateBranchRequest
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+
+		created, apiErr := branchService.CreateBranch(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, created)
+	}
+}
+
+// GetAllBranchesHandler fetches all branches with pagination
+func GetAllBranchesHandler(branchService *branch.BranchService) http.HandlerFunc {
+	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		var req branch.GetBranchesRequest
+		req.Search = r.URL.Query().Get("search")
+		req.Page = page
+		req.PageSize = pageSize
+		req.SortBy = sortBy
+		req.SortOrder = sortOrder
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		branches, totalItems, apiErr := branchService.GetAllBranches(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WritePaginationJSON(w, http.StatusOK, page, totalItems, pageSize, branches)
+	})
+}
+
+// GetBranchHandler fetches a branch by ID
+func GetBranchHandler(branchService *branch.BranchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID cabang tidak valid",
+			}))
+			return
+		}
+
+		found, apiErr := branchService.GetBranchByID(id.String())
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, found)
+	}
+}
+
+// UpdateBranchHandler updates a branch
+func UpdateBranchHandler(branchService *branch.BranchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req branch.UpdateBranchRequest
+		req.ID = mux.Vars(r)["id"]
+
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+
+		updated, apiErr := branchService.UpdateBranch(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, updated)
+	}
+}
+
+// DeleteBranchHandler deletes a branch
+func DeleteBranchHandler(branchService *branch.BranchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID cabang tidak valid",
+			}))
+			return
+		}
+
+		if apiErr := branchService.DeleteBranch(id.String()); apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, utils.WriteMessage("Cabang berhasil dihapus"))
+	}
+}
+
+// GetUserBranchesHandler fetches the branches a user is assigned to
+func GetUserBranchesHandler(branchService *branch.BranchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID user tidak valid",
+			}))
+			return
+		}
+
+		branches, apiErr := branchService.GetUserBranches(id.String())
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, branches)
+	}
+}
+
+// AssignUserBranchesHandler replaces the branches a user is assigned to
+func AssignUserBranchesHandler(branchService *branch.BranchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req branch.AssignUserBranchesRequest
+		req.UserID = mux.Vars(r)["id"]
+
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+
+		branches, apiErr := branchService.AssignUserBranches(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, branches)
+	}
+}
+
+// GetBranchSummaryHandler returns the consolidated cross-branch report
+func GetBranchSummaryHandler(branchService *branch.BranchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req branch.GetBranchSummaryRequest
+		req.StartDate = r.URL.Query().Get("start_date")
+		req.EndDate = r.URL.Query().Get("end_date")
+
+		// Validate
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		summary, apiErr := branchService.GetBranchSummary(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, summary)
+	}
+}
@@ -33,9 +33,22 @@ func CreateCategoryHandler(categoryService *category.CategoryService) http.Handl
 	}
 }
 
-// GetAllCategoryHandler fetches all categories
+// categoryExportColumns are the columns of a category export
+var categoryExportColumns = []utils.ExportColumn{
+	{Header: "Nama", Field: "name"},
+	{Header: "Deskripsi", Field: "description"},
+	{Header: "Dibuat", Field: "created_at", Kind: utils.ExportDateTime},
+}
+
+// GetAllCategoryHandler fetches all categories, or downloads them with format=csv or format=xlsx
 func GetAllCategoryHandler(categoryService *category.CategoryService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		var req category.GetCategoryRequest
 		req.Name = r.URL.Query().Get("name")
 
@@ -51,6 +64,11 @@ func GetAllCategoryHandler(categoryService *category.CategoryService) http.Handl
 			return
 		}
 
+		if format != "" {
+			utils.WriteExport(w, format, "kategori", categoryExportColumns, categories)
+			return
+		}
+
 		utils.WriteJSON(w, http.StatusOK, categories)
 	}
 }
@@ -0,0 +1,125 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/creditnote"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// creditNoteExportColumns are the columns of a credit note export
+var creditNoteExportColumns = []utils.ExportColumn{
+	{Header: "No. Nota Kredit", Field: "serial_id"},
+	{Header: "Tanggal", Field: "credit_date", Kind: utils.ExportDate},
+	{Header: "No. Retur", Field: "return_serial_id"},
+	{Header: "No. Pesanan", Field: "sales_order_serial"},
+	{Header: "Pelanggan", Field: "customer_name"},
+	{Header: "Jumlah", Field: "amount", Kind: utils.ExportRupiah},
+	{Header: "Penyelesaian", Field: "settlement"},
+	{Header: "Status", Field: "status"},
+	{Header: "Dibatalkan", Field: "cancelled_at", Kind: utils.ExportDateTime},
+}
+
+// GetCreditNotesHandler fetches credit notes with pagination, or downloads them with format=csv or format=xlsx
+func GetCreditNotesHandler(creditNoteService *creditnote.CreditNoteService) http.HandlerFunc {
+	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		var req creditnote.GetCreditNotesRequest
+		req.Search = r.URL.Query().Get("search")
+		req.CustomerID = r.URL.Query().Get("customer_id")
+		req.Settlement = r.URL.Query().Get("settlement")
+		req.Status = r.URL.Query().Get("status")
+		req.StartDate = r.URL.Query().Get("start_date")
+		req.EndDate = r.URL.Query().Get("end_date")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+		req.Page = page
+		req.PageSize = pageSize
+		req.SortBy = sortBy
+		req.SortOrder = sortOrder
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "nota_kredit", creditNoteExportColumns)
+			req.Export = export
+		}
+
+		creditNotes, totalItems, apiErr := creditNoteService.GetAll(req)
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WritePaginationJSON(w, http.StatusOK, page, totalItems, pageSize, creditNotes)
+	})
+}
+
+// CreateCreditNoteHandler issues the credit note of a sales return
+func CreateCreditNoteHandler(creditNoteService *creditnote.CreditNoteService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		returnID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID retur tidak valid",
+			}))
+			return
+		}
+
+		var req creditnote.CreateCreditNoteRequest
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+		req.SalesOrderReturnID = returnID.String()
+		branchID, _ := r.Context().Value("branch_id").(string)
+		userID, _ := r.Context().Value("user_id").(string)
+
+		created, apiErr := creditNoteService.Create(req, branchID, userID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, created)
+	}
+}
+
+// GetCreditNoteHandler fetches a credit note with its allocations
+func GetCreditNoteHandler(creditNoteService *creditnote.CreditNoteService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID nota kredit tidak valid",
+			}))
+			return
+		}
+		branchID, _ := r.Context().Value("branch_id").(string)
+
+		found, apiErr := creditNoteService.GetByID(id.String(), branchID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, found)
+	}
+}
@@ -30,13 +30,32 @@ func CreateCustomerHandler(customerService *customer.CustomerService) http.Handl
 	}
 }
 
-// GetAllCustomersHandler fetches all customers with pagination
+// customerExportColumns are the columns of a customer export
+var customerExportColumns = []utils.ExportColumn{
+	{Header: "Nama", Field: "name"},
+	{Header: "Alamat", Field: "address"},
+	{Header: "Telepon", Field: "telephone"},
+	{Header: "Email", Field: "email"},
+	{Header: "Batas Kredit", Field: "credit_limit", Kind: utils.ExportRupiah},
+	{Header: "Maks. Hari Terlambat", Field: "max_overdue_days", Kind: utils.ExportNumber},
+	{Header: "Daftar Harga", Field: "price_list_name"},
+	{Header: "Dibuat", Field: "created_at", Kind: utils.ExportDateTime},
+}
+
+// GetAllCustomersHandler fetches all customers with pagination, or downloads them with format=csv or format=xlsx
 func GetAllCustomersHandler(customerService *customer.CustomerService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		var req customer.GetCustomerRequest
 		req.Name = r.URL.Query().Get("name")
 		req.Address = r.URL.Query().Get("address")
 		req.Telephone = r.URL.Query().Get("telephone")
+		req.Search = r.URL.Query().Get("search")
 		req.Page = page
 		req.PageSize = pageSize
 		req.SortBy = sortBy
@@ -49,7 +68,17 @@ func GetAllCustomersHandler(customerService *customer.CustomerService) http.Hand
 			return
 		}
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "pelanggan", customerExportColumns)
+			req.Export = export
+		}
+
 		customers, totalItems, apiErr := customerService.GetAllCustomers(req)
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
 		if apiErr != nil {
 			utils.ErrorJSON(w, apiErr)
 			return
@@ -0,0 +1,108 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/document"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// GetCompanySettingHandler fetches the letterhead printed on documents
+func GetCompanySettingHandler(documentService *document.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setting, apiErr := documentService.GetCompanySetting()
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, setting)
+	}
+}
+
+// UpdateCompanySettingHandler saves the letterhead printed on documents
+func UpdateCompanySettingHandler(documentService *document.DocumentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req document.UpdateCompanySettingRequest
+		if validationErrors := utils.DecodeAndValidate(r, &req); validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+
+		setting, apiErr := documentService.UpdateCompanySetting(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, setting)
+	}
+}
+
+// GetDocumentPDFHandler renders a stored document as PDF, reprints are watermarked as copies
+func GetDocumentPDFHandler(documentService *document.DocumentService, documentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID tidak valid",
+			}))
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		userID, _ := r.Context().Value("user_id").(string)
+		content, fileName, apiErr := documentService.RenderPDF(documentType, id.String(), branchID, userID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", "inline; filename=\""+fileName+"\"")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+	}
+}
+
+// GetReceiptHandler renders the thermal receipt of a cash sale as ESC/POS or plain text
+func GetReceiptHandler(documentService *document.DocumentService, source string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID tidak valid",
+			}))
+			return
+		}
+
+		var req document.GetReceiptRequest
+		req.Width, _ = strconv.Atoi(r.URL.Query().Get("width"))
+		req.Format = r.URL.Query().Get("format")
+		if validationErrors := utils.ValidateStruct(req); validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
+		content, apiErr := documentService.RenderReceipt(source, id.String(), req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		if req.Format == document.ReceiptFormatText {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+	}
+}
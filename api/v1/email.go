@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/email"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// SendDocumentEmailHandler queues a document as PDF attachment for sending to its customer or supplier
+func SendDocumentEmailHandler(emailService *email.EmailService, documentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID tidak valid",
+			}))
+			return
+		}
+
+		var req email.SendDocumentRequest
+		if validationErrors := utils.DecodeAndValidate(r, &req); validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		userID, _ := r.Context().Value("user_id").(string)
+		queued, apiErr := emailService.SendDocument(documentType, id.String(), req, branchID, userID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusAccepted, queued)
+	}
+}
+
+// GetDocumentEmailsHandler fetches the send log of a document
+func GetDocumentEmailsHandler(emailService *email.EmailService, documentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID tidak valid",
+			}))
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		emails, apiErr := emailService.GetDocumentEmails(documentType, id.String(), branchID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, emails)
+	}
+}
@@ -84,9 +84,24 @@ func DeleteEmployeeHandler(employeeService *employee.EmployeeService) http.Handl
 	}
 }
 
-// GetAllEmployeesHandler fetches all employees
+// employeeExportColumns are the columns of an employee export
+var employeeExportColumns = []utils.ExportColumn{
+	{Header: "Nama", Field: "name"},
+	{Header: "NIK", Field: "nik"},
+	{Header: "Jabatan", Field: "position"},
+	{Header: "Telepon", Field: "phone"},
+	{Header: "Tanggal Masuk", Field: "hired_date", Kind: utils.ExportDate},
+}
+
+// GetAllEmployeesHandler fetches all employees, or downloads them with format=csv or format=xlsx
 func GetAllEmployeesHandler(employeeService *employee.EmployeeService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		var req employee.GetAllEmployeeRequest
 		req.Name = r.URL.Query().Get("name")
 		req.Page = page
@@ -101,7 +116,17 @@ func GetAllEmployeesHandler(employeeService *employee.EmployeeService) http.Hand
 			return
 		}
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "karyawan", employeeExportColumns)
+			req.Export = export
+		}
+
 		employees, totalItems, errService := employeeService.GetAllEmployees(req)
+		if export != nil {
+			export.Finish(errService)
+			return
+		}
 		if errService != nil {
 			utils.ErrorJSON(w, errService)
 			return
@@ -111,9 +136,23 @@ func GetAllEmployeesHandler(employeeService *employee.EmployeeService) http.Hand
 	})
 }
 
-// GetAllAttendanceHandler fetches all attendance records
+// attendanceExportColumns are the columns of an attendance export
+var attendanceExportColumns = []utils.ExportColumn{
+	{Header: "Karyawan", Field: "employee_name"},
+	{Header: "Tanggal", Field: "attendance_date", Kind: utils.ExportDate},
+	{Header: "Status", Field: "attendance_status"},
+	{Header: "Keterangan", Field: "description"},
+}
+
+// GetAllAttendanceHandler fetches all attendance records, or downloads them with format=csv or format=xlsx
 func GetAllAttendanceHandler(employeeService *employee.EmployeeService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		var req employee.GetAttendanceRequest
 		req.AttendanceDate = r.URL.Query().Get("attendance_date")
 		// req.EmployeeID = r.URL.Query().Get("employee_id")
@@ -137,6 +176,11 @@ func GetAllAttendanceHandler(employeeService *employee.EmployeeService) http.Han
 			return
 		}
 
+		if format != "" {
+			utils.WriteExport(w, format, "absensi", attendanceExportColumns, attendances)
+			return
+		}
+
 		utils.WriteJSON(w, http.StatusOK, attendances)
 
 		// utils.WritePaginationJSON(w, http.StatusOK, page, totalItems, pageSize, attendances)
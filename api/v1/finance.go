@@ -28,6 +28,7 @@ func CreateFinanceTransactionHandler(financialService *finance.FinanceService) h
 			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, errors))
 			return
 		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		// Create the transaction
 		apiErr := financialService.CreateFinanceTransaction(req, userID)
@@ -45,9 +46,27 @@ func CreateFinanceTransactionHandler(financialService *finance.FinanceService) h
 	}
 }
 
-// GetAllFinanceTransactionsHandler handles requests to get all financial transactions with pagination and filtering
+// financeTransactionExportColumns are the columns of a finance transaction export
+var financeTransactionExportColumns = []utils.ExportColumn{
+	{Header: "Tanggal", Field: "transaction_date", Kind: utils.ExportDate},
+	{Header: "Jenis", Field: "type"},
+	{Header: "Jumlah", Field: "amount", Kind: utils.ExportRupiah},
+	{Header: "Keterangan", Field: "description"},
+	{Header: "Otomatis", Field: "is_system"},
+	{Header: "Pengguna", Field: "username"},
+	{Header: "Dibatalkan", Field: "deleted_at", Kind: utils.ExportDateTime},
+}
+
+// GetAllFinanceTransactionsHandler handles requests to get all financial transactions with pagination and filtering.
+// With format=csv or format=xlsx every matching transaction is downloaded as a spreadsheet.
 func GetAllFinanceTransactionsHandler(financialService *finance.FinanceService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		// Create request with pagination and filtering parameters
 		var req finance.GetFinanceTransactionRequest
 
@@ -68,6 +87,8 @@ func GetAllFinanceTransactionsHandler(financialService *finance.FinanceService)
 			req.SalesOrderID = salesOrderID
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Parse boolean parameters
 		if isSystem := r.URL.Query().Get("is_system"); isSystem != "" {
 			isSystemVal := isSystem == "true"
@@ -89,8 +110,18 @@ func GetAllFinanceTransactionsHandler(financialService *finance.FinanceService)
 		req.SortBy = sortBy
 		req.SortOrder = sortOrder
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "transaksi_keuangan", financeTransactionExportColumns)
+			req.Export = export
+		}
+
 		// Get transactions from service
 		transactions, totalItems, apiErr := financialService.GetAllFinanceTransactions(req)
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
 		if apiErr != nil {
 			utils.ErrorJSON(w, apiErr)
 			return
@@ -175,8 +206,9 @@ func GetFinanceTransactionSummaryHandler(financialService *finance.FinanceServic
 			return
 		}
 
-		// Get financial summary
-		summary, apiErr := financialService.GetFinanceTransactionSummary(startDate, endDate)
+		// Get financial summary for the active branch
+		branchID, _ := r.Context().Value("branch_id").(string)
+		summary, apiErr := financialService.GetFinanceTransactionSummary(startDate, endDate, branchID)
 		if apiErr != nil {
 			utils.ErrorJSON(w, apiErr)
 			return
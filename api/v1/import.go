@@ -0,0 +1,168 @@
+package v1
+
+import (
+	"encoding/csv"
+	"net/http"
+	"sinartimur-go/internal/importer"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxImportFileSize is the largest accepted import upload
+const maxImportFileSize = 10 << 20
+
+// CreateImportHandler uploads a CSV or XLSX file of a resource and validates it.
+// The rows are committed right away when dry_run=false and every row is valid.
+func CreateImportHandler(importService *importer.ImportService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+		if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"file": "File tidak valid atau melebihi 10 MB",
+			}))
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"file": "File wajib diunggah",
+			}))
+			return
+		}
+		defer file.Close()
+
+		var req importer.CreateImportRequest
+		req.Resource = mux.Vars(r)["resource"]
+		req.FileName = header.Filename
+		req.DryRun = true
+		if dryRun := r.URL.Query().Get("dry_run"); dryRun != "" {
+			req.DryRun, err = strconv.ParseBool(dryRun)
+			if err != nil {
+				utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+					"dry_run": "Harus berupa true atau false",
+				}))
+				return
+			}
+		}
+
+		// Validate request
+		if errValidation := utils.ValidateStruct(req); errValidation != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, errValidation))
+			return
+		}
+
+		req.Rows, err = utils.ReadSpreadsheet(file, header.Filename)
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"file": err.Error(),
+			}))
+			return
+		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+		req.UserID, _ = r.Context().Value("user_id").(string)
+
+		job, apiErr := importService.CreateImport(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, job)
+	}
+}
+
+// GetImportTemplateHandler downloads an empty CSV with the columns of a resource
+func GetImportTemplateHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resource := mux.Vars(r)["resource"]
+		columns := importer.Columns(resource)
+		if columns == nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"resource": "Resource impor tidak dikenal",
+			}))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"import_"+resource+".csv\"")
+		writer := csv.NewWriter(w)
+		writer.Write(columns)
+		writer.Flush()
+	}
+}
+
+// GetAllImportsHandler fetches import jobs with pagination
+func GetAllImportsHandler(importService *importer.ImportService) http.HandlerFunc {
+	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		var req importer.GetImportJobsRequest
+		req.Resource = r.URL.Query().Get("resource")
+		req.Status = r.URL.Query().Get("status")
+		req.Page = page
+		req.PageSize = pageSize
+		req.SortBy = sortBy
+		req.SortOrder = sortOrder
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		jobs, totalItems, apiErr := importService.GetAllImports(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WritePaginationJSON(w, http.StatusOK, page, totalItems, pageSize, jobs)
+	})
+}
+
+// GetImportHandler fetches an import job with its row errors
+func GetImportHandler(importService *importer.ImportService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID tidak valid",
+			}))
+			return
+		}
+
+		job, apiErr := importService.GetImport(id.String())
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, job)
+	}
+}
+
+// CommitImportHandler commits a validated dry-run import
+func CommitImportHandler(importService *importer.ImportService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID tidak valid",
+			}))
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		userID, _ := r.Context().Value("user_id").(string)
+		job, apiErr := importService.CommitImport(id.String(), branchID, userID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, job)
+	}
+}
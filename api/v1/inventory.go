@@ -9,14 +9,28 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// GetAllStoragesHandler returns all storage locations with pagination
+// storageExportColumns are the columns of a storage export
+var storageExportColumns = []utils.ExportColumn{
+	{Header: "Nama", Field: "name"},
+	{Header: "Lokasi", Field: "location"},
+	{Header: "Dibuat", Field: "created_at", Kind: utils.ExportDateTime},
+}
+
+// GetAllStoragesHandler returns all storage locations with pagination, or downloads them with format=csv or format=xlsx
 func GetAllStoragesHandler(storageService *inventory.StorageService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		var req inventory.GetStorageRequest
 
 		// Extract query parameters for filtering
 		req.Name = r.URL.Query().Get("name")
 		req.Location = r.URL.Query().Get("location")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		// Set pagination parameters
 		req.Page = page
@@ -30,8 +44,18 @@ func GetAllStoragesHandler(storageService *inventory.StorageService) http.Handle
 			return
 		}
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "gudang", storageExportColumns)
+			req.Export = export
+		}
+
 		// Get storages with pagination
 		storages, totalItems, apiErr := storageService.GetAllStorages(req)
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
 		if apiErr != nil {
 			utils.ErrorJSON(w, apiErr)
 			return
@@ -71,6 +95,7 @@ func CreateStorageHandler(storageService *inventory.StorageService) http.Handler
 			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
 			return
 		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		_, apiErr := storageService.CreateStorage(req)
 		if apiErr != nil {
@@ -138,6 +163,7 @@ func MoveBatchHandler(storageService *inventory.StorageService) http.HandlerFunc
 
 		// Get userID from context (provided by auth middleware)
 		userID := r.Context().Value("user_id").(string)
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		apiErr := storageService.MoveBatch(req, userID)
 		if apiErr != nil {
@@ -149,9 +175,29 @@ func MoveBatchHandler(storageService *inventory.StorageService) http.HandlerFunc
 	}
 }
 
-// GetAllInventoryLogHandler handles requests to get inventory logs
+// inventoryLogExportColumns are the columns of an inventory log export
+var inventoryLogExportColumns = []utils.ExportColumn{
+	{Header: "Tanggal", Field: "log_date", Kind: utils.ExportDateTime},
+	{Header: "Aksi", Field: "action"},
+	{Header: "Produk", Field: "product_name"},
+	{Header: "SKU Batch", Field: "batch_sku"},
+	{Header: "Gudang", Field: "storage_name"},
+	{Header: "Gudang Tujuan", Field: "target_storage_name"},
+	{Header: "Jumlah", Field: "quantity", Kind: utils.ExportNumber},
+	{Header: "Pengguna", Field: "username"},
+	{Header: "Keterangan", Field: "description"},
+}
+
+// GetAllInventoryLogHandler handles requests to get inventory logs.
+// With format=csv or format=xlsx every matching log is downloaded as a spreadsheet.
 func GetAllInventoryLogHandler(storageService *inventory.StorageService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		var req inventory.GetInventoryLogsRequest
 
 		// Extract query parameters for filtering
@@ -163,6 +209,7 @@ func GetAllInventoryLogHandler(storageService *inventory.StorageService) http.Ha
 		req.Action = r.URL.Query().Get("action")
 		req.FromDate = r.URL.Query().Get("from_date")
 		req.ToDate = r.URL.Query().Get("to_date")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		// Set pagination parameters
 		req.Page = page
@@ -183,8 +230,18 @@ func GetAllInventoryLogHandler(storageService *inventory.StorageService) http.Ha
 			return
 		}
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "log_inventaris", inventoryLogExportColumns)
+			req.Export = export
+		}
+
 		// Get inventory logs with pagination
 		logs, totalItems, apiErr := storageService.GetInventoryLogs(req)
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
 		if apiErr != nil {
 			utils.ErrorJSON(w, apiErr)
 			return
@@ -230,6 +287,7 @@ func GetAllBatchHandler(storageService *inventory.StorageService) http.HandlerFu
 		req.ProductID = r.URL.Query().Get("product_id")
 		req.SKU = r.URL.Query().Get("sku")
 		req.StorageID = r.URL.Query().Get("storage_id")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		// Set pagination parameters
 		req.Page = page
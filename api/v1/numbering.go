@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/numbering"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// GetAllDocumentNumberingHandler fetches the numbering schemes of every document type,
+// the next numbers are those of the active branch
+func GetAllDocumentNumberingHandler(numberingService *numbering.NumberingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		branchID, _ := r.Context().Value("branch_id").(string)
+
+		schemes, apiErr := numberingService.GetAllDocumentNumbering(branchID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, schemes)
+	}
+}
+
+// UpdateDocumentNumberingHandler changes the numbering scheme of a document type
+func UpdateDocumentNumberingHandler(numberingService *numbering.NumberingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req numbering.UpdateDocumentNumberingRequest
+		req.DocumentType = strings.ToUpper(mux.Vars(r)["type"])
+
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+
+		userID := r.Context().Value("user_id").(string)
+		branchID, _ := r.Context().Value("branch_id").(string)
+
+		scheme, apiErr := numberingService.UpdateDocumentNumbering(req, userID, branchID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, scheme)
+	}
+}
@@ -0,0 +1,259 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/payment"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// paymentExportColumns are the columns of a customer payment export
+var paymentExportColumns = []utils.ExportColumn{
+	{Header: "No. Pembayaran", Field: "serial_id"},
+	{Header: "Tanggal", Field: "payment_date", Kind: utils.ExportDate},
+	{Header: "Pelanggan", Field: "customer_name"},
+	{Header: "Metode Pembayaran", Field: "payment_method"},
+	{Header: "Jumlah", Field: "amount", Kind: utils.ExportRupiah},
+	{Header: "Referensi", Field: "reference"},
+	{Header: "Status", Field: "status"},
+	{Header: "Dibatalkan", Field: "cancelled_at", Kind: utils.ExportDateTime},
+}
+
+// GetPaymentsHandler fetches customer payments with pagination, or downloads them with format=csv or format=xlsx
+func GetPaymentsHandler(paymentService *payment.PaymentService) http.HandlerFunc {
+	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		var req payment.GetPaymentsRequest
+		req.Search = r.URL.Query().Get("search")
+		req.CustomerID = r.URL.Query().Get("customer_id")
+		req.Status = r.URL.Query().Get("status")
+		req.StartDate = r.URL.Query().Get("start_date")
+		req.EndDate = r.URL.Query().Get("end_date")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+		req.Page = page
+		req.PageSize = pageSize
+		req.SortBy = sortBy
+		req.SortOrder = sortOrder
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "pembayaran_pelanggan", paymentExportColumns)
+			req.Export = export
+		}
+
+		payments, totalItems, apiErr := paymentService.GetAll(req)
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WritePaginationJSON(w, http.StatusOK, page, totalItems, pageSize, payments)
+	})
+}
+
+// CreatePaymentHandler records a customer payment allocated over invoices
+func CreatePaymentHandler(paymentService *payment.PaymentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req payment.CreatePaymentRequest
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+		userID, _ := r.Context().Value("user_id").(string)
+
+		created, apiErr := paymentService.Create(req, userID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, created)
+	}
+}
+
+// GetPaymentHandler fetches a customer payment with its allocations
+func GetPaymentHandler(paymentService *payment.PaymentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID pembayaran tidak valid",
+			}))
+			return
+		}
+		branchID, _ := r.Context().Value("branch_id").(string)
+
+		found, apiErr := paymentService.GetByID(id.String(), branchID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, found)
+	}
+}
+
+// CancelPaymentHandler cancels a customer payment
+func CancelPaymentHandler(paymentService *payment.PaymentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID pembayaran tidak valid",
+			}))
+			return
+		}
+		branchID, _ := r.Context().Value("branch_id").(string)
+		userID, _ := r.Context().Value("user_id").(string)
+
+		cancelled, apiErr := paymentService.Cancel(id.String(), branchID, userID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, cancelled)
+	}
+}
+
+// GetCustomerBalanceHandler fetches the receivable ledger of a customer
+func GetCustomerBalanceHandler(paymentService *payment.PaymentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req payment.CustomerBalanceRequest
+		req.CustomerID = mux.Vars(r)["id"]
+		req.StartDate = r.URL.Query().Get("start_date")
+		req.EndDate = r.URL.Query().Get("end_date")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		balance, apiErr := paymentService.CustomerBalance(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, balance)
+	}
+}
+
+// receivableAgingExportColumns are the columns of a receivable aging export
+var receivableAgingExportColumns = []utils.ExportColumn{
+	{Header: "Pelanggan", Field: "customer_name"},
+	{Header: "Jumlah Faktur", Field: "invoice_count", Kind: utils.ExportNumber},
+	{Header: "Belum Jatuh Tempo", Field: "current", Kind: utils.ExportRupiah},
+	{Header: "1-30 Hari", Field: "days_1_30", Kind: utils.ExportRupiah},
+	{Header: "31-60 Hari", Field: "days_31_60", Kind: utils.ExportRupiah},
+	{Header: "61-90 Hari", Field: "days_61_90", Kind: utils.ExportRupiah},
+	{Header: ">90 Hari", Field: "over_90", Kind: utils.ExportRupiah},
+	{Header: "Total", Field: "total", Kind: utils.ExportRupiah},
+}
+
+// receivableAgingInvoiceExportColumns are the columns of a customer receivable aging export
+var receivableAgingInvoiceExportColumns = []utils.ExportColumn{
+	{Header: "No. Faktur", Field: "serial_id"},
+	{Header: "No. Pesanan", Field: "sales_order_serial"},
+	{Header: "Tanggal", Field: "invoice_date", Kind: utils.ExportDate},
+	{Header: "Jatuh Tempo", Field: "due_date", Kind: utils.ExportDate},
+	{Header: "Total", Field: "total_amount", Kind: utils.ExportRupiah},
+	{Header: "Terbayar", Field: "paid_amount", Kind: utils.ExportRupiah},
+	{Header: "Retur", Field: "returned_amount", Kind: utils.ExportRupiah},
+	{Header: "Sisa Tagihan", Field: "outstanding_amount", Kind: utils.ExportRupiah},
+	{Header: "Hari Lewat Jatuh Tempo", Field: "days_past_due", Kind: utils.ExportNumber},
+	{Header: "Kelompok Umur", Field: "bucket"},
+}
+
+// GetReceivableAgingHandler reports outstanding receivables per customer bucketed by days past due.
+// With format=csv or format=xlsx the customer rows are downloaded as a spreadsheet.
+func GetReceivableAgingHandler(paymentService *payment.PaymentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		var req payment.AgingRequest
+		req.AsOf = r.URL.Query().Get("as_of")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		report, apiErr := paymentService.Aging(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		if format != "" {
+			utils.WriteExport(w, format, "umur_piutang_"+report.AsOf, receivableAgingExportColumns, report.Customers)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, report)
+	}
+}
+
+// GetCustomerReceivableAgingHandler lists the outstanding invoices of a customer in the aging report.
+// With format=csv or format=xlsx the invoices are downloaded as a spreadsheet.
+func GetCustomerReceivableAgingHandler(paymentService *payment.PaymentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		var req payment.AgingRequest
+		req.CustomerID = mux.Vars(r)["id"]
+		req.AsOf = r.URL.Query().Get("as_of")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		report, apiErr := paymentService.CustomerAging(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		if format != "" {
+			utils.WriteExport(w, format, "umur_piutang_"+report.AsOf, receivableAgingInvoiceExportColumns, report.Invoices)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, report)
+	}
+}
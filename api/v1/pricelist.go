@@ -0,0 +1,146 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/pricelist"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// priceListExportColumns are the columns of a price list export
+var priceListExportColumns = []utils.ExportColumn{
+	{Header: "Nama", Field: "name"},
+	{Header: "Deskripsi", Field: "description"},
+	{Header: "Aktif", Field: "is_active"},
+	{Header: "Jumlah Produk", Field: "item_count", Kind: utils.ExportNumber},
+	{Header: "Jumlah Pelanggan", Field: "customer_count", Kind: utils.ExportNumber},
+}
+
+// GetPriceListsHandler fetches price lists with pagination, or downloads them with format=csv or format=xlsx
+func GetPriceListsHandler(priceListService *pricelist.PriceListService) http.HandlerFunc {
+	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		var req pricelist.GetPriceListsRequest
+		req.Search = r.URL.Query().Get("search")
+		req.IsActive = r.URL.Query().Get("is_active")
+		req.Page = page
+		req.PageSize = pageSize
+		req.SortBy = sortBy
+		req.SortOrder = sortOrder
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "daftar_harga", priceListExportColumns)
+			req.Export = export
+		}
+
+		priceLists, totalItems, apiErr := priceListService.GetAll(req)
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WritePaginationJSON(w, http.StatusOK, page, totalItems, pageSize, priceLists)
+	})
+}
+
+// CreatePriceListHandler creates a price list with its product prices
+func CreatePriceListHandler(priceListService *pricelist.PriceListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req pricelist.CreatePriceListRequest
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+
+		created, apiErr := priceListService.Create(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, created)
+	}
+}
+
+// GetPriceListHandler fetches a price list with its product prices
+func GetPriceListHandler(priceListService *pricelist.PriceListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID daftar harga tidak valid",
+			}))
+			return
+		}
+
+		found, apiErr := priceListService.GetByID(id.String())
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, found)
+	}
+}
+
+// UpdatePriceListHandler replaces a price list and its product prices
+func UpdatePriceListHandler(priceListService *pricelist.PriceListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req pricelist.UpdatePriceListRequest
+		req.ID = mux.Vars(r)["id"]
+
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+
+		updated, apiErr := priceListService.Update(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, updated)
+	}
+}
+
+// DeletePriceListHandler deletes a price list
+func DeletePriceListHandler(priceListService *pricelist.PriceListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID daftar harga tidak valid",
+			}))
+			return
+		}
+
+		if apiErr := priceListService.Delete(id.String()); apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, utils.WriteMessage("Daftar harga berhasil dihapus"))
+	}
+}
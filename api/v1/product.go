@@ -10,11 +10,27 @@ import (
 	"sinartimur-go/utils"
 )
 
-// GetAllProductHandler fetches all products
+// productExportColumns are the columns of a product export
+var productExportColumns = []utils.ExportColumn{
+	{Header: "Nama", Field: "name"},
+	{Header: "Deskripsi", Field: "description"},
+	{Header: "Kategori", Field: "category"},
+	{Header: "Satuan", Field: "unit"},
+	{Header: "Dibuat", Field: "created_at", Kind: utils.ExportDateTime},
+}
+
+// GetAllProductHandler fetches all products, or downloads them with format=csv or format=xlsx
 func GetAllProductHandler(productService *product.ProductService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		var req product.GetProductRequest
 		req.Name, req.Category, req.Unit = r.URL.Query().Get("name"), r.URL.Query().Get("category"), r.URL.Query().Get("unit")
+		req.Search = r.URL.Query().Get("search")
 		req.Page = page
 		req.PageSize = pageSize
 		req.SortBy = sortBy
@@ -26,7 +42,17 @@ func GetAllProductHandler(productService *product.ProductService) http.HandlerFu
 			return
 		}
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "produk", productExportColumns)
+			req.Export = export
+		}
+
 		products, totalItems, err := productService.GetAllProducts(req)
+		if export != nil {
+			export.Finish(err)
+			return
+		}
 		if err != nil {
 			utils.ErrorJSON(w, err)
 			return
@@ -26,6 +26,7 @@ func CreatePurchaseOrderHandler(purchaseOrderService *purchase_order.PurchaseOrd
 
 		// Get user ID from context
 		userID := r.Context().Value("user_id").(string)
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 		res, apiError := purchaseOrderService.Create(req, userID)
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
@@ -50,7 +51,8 @@ func GetPurchaseOrderDetailHandler(purchaseOrderService *purchase_order.Purchase
 			return
 		}
 
-		purchaseOrder, apiError := purchaseOrderService.GetPurchaseOrderDetail(id)
+		branchID, _ := r.Context().Value("branch_id").(string)
+		purchaseOrder, apiError := purchaseOrderService.GetPurchaseOrderDetail(id, branchID)
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
 			return
@@ -72,6 +74,7 @@ func UpdatePurchaseOrderHandler(purchaseOrderService *purchase_order.PurchaseOrd
 			return
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 		res, apiError := purchaseOrderService.Update(req)
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
@@ -116,7 +119,8 @@ func CheckPurchaseOrderHandler(purchaseOrderService *purchase_order.PurchaseOrde
 
 		// Get user ID from context
 		userID := r.Context().Value("user_id").(string)
-		apiError := purchaseOrderService.CheckPurchaseOrder(id, userID)
+		branchID, _ := r.Context().Value("branch_id").(string)
+		apiError := purchaseOrderService.CheckPurchaseOrder(id, branchID, userID)
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
 			return
@@ -134,7 +138,8 @@ func CancelPurchaseOrderHandler(purchaseOrderService *purchase_order.PurchaseOrd
 
 		// Get user ID from context
 		userID := r.Context().Value("user_id").(string)
-		res, apiError := purchaseOrderService.CancelPurchaseOrder(id, userID)
+		branchID, _ := r.Context().Value("branch_id").(string)
+		res, apiError := purchaseOrderService.CancelPurchaseOrder(id, branchID, userID)
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
 			return
@@ -158,6 +163,7 @@ func CreatePurchaseOrderReturnHandler(purchaseOrderService *purchase_order.Purch
 
 		// Get user ID from context
 		userID := r.Context().Value("user_id").(string)
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 		apiError := purchaseOrderService.CreateReturnItem(req, userID)
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
@@ -168,9 +174,26 @@ func CreatePurchaseOrderReturnHandler(purchaseOrderService *purchase_order.Purch
 	}
 }
 
-// GetAllPurchaseOrderReturnHandler fetches all purchase purchase-order returns
+// purchaseReturnExportColumns are the columns of a purchase return export
+var purchaseReturnExportColumns = []utils.ExportColumn{
+	{Header: "No. Retur", Field: "serial_id"},
+	{Header: "Tanggal", Field: "returned_at", Kind: utils.ExportDateTime},
+	{Header: "Produk", Field: "product_name"},
+	{Header: "Jumlah", Field: "return_quantity", Kind: utils.ExportNumber},
+	{Header: "Alasan", Field: "reason"},
+	{Header: "Status", Field: "status"},
+	{Header: "Dikembalikan Oleh", Field: "returned_by_name"},
+}
+
+// GetAllPurchaseOrderReturnHandler fetches all purchase purchase-order returns, or downloads them with format=csv or format=xlsx
 func GetAllPurchaseOrderReturnHandler(purchaseOrderService *purchase_order.PurchaseOrderService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		req := purchase_order.GetPurchaseOrderReturnRequest{
 			FromDate: r.URL.Query().Get("from_date"),
 			ToDate:   r.URL.Query().Get("to_date"),
@@ -181,6 +204,7 @@ func GetAllPurchaseOrderReturnHandler(purchaseOrderService *purchase_order.Purch
 				SortOrder: sortOrder,
 			},
 		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		validationErrors := utils.ValidateStruct(req)
 		if validationErrors != nil {
@@ -188,7 +212,17 @@ func GetAllPurchaseOrderReturnHandler(purchaseOrderService *purchase_order.Purch
 			return
 		}
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "retur_pembelian", purchaseReturnExportColumns)
+			req.Export = export
+		}
+
 		returns, totalItems, apiError := purchaseOrderService.GetAllReturns(req)
+		if export != nil {
+			export.Finish(apiError)
+			return
+		}
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
 			return
@@ -213,6 +247,7 @@ func CancelPurchaseOrderReturnHandler(purchaseOrderService *purchase_order.Purch
 		}
 		// Get user ID from context
 		userID := r.Context().Value("user_id").(string)
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 		apiError := purchaseOrderService.CancelReturnItem(req, userID)
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
@@ -229,7 +264,8 @@ func DeletePurchaseOrderItemHandler(purchaseOrderService *purchase_order.Purchas
 		params := mux.Vars(r)
 		id := params["id"]
 
-		apiError := purchaseOrderService.RemovePurchaseOrderItem(id)
+		branchID, _ := r.Context().Value("branch_id").(string)
+		apiError := purchaseOrderService.RemovePurchaseOrderItem(id, branchID)
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
 			return
@@ -253,7 +289,8 @@ func CreatePurchaseOrderItemHandler(purchaseOrderService *purchase_order.Purchas
 			return
 		}
 
-		apiError := purchaseOrderService.AddPurchaseOrderItem(orderID, req)
+		branchID, _ := r.Context().Value("branch_id").(string)
+		apiError := purchaseOrderService.AddPurchaseOrderItem(orderID, branchID, req)
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
 			return
@@ -275,6 +312,7 @@ func UpdatePurchaseOrderItemHandler(purchaseOrderService *purchase_order.Purchas
 			return
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 		apiError := purchaseOrderService.UpdatePurchaseOrderItem(req)
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
@@ -285,9 +323,26 @@ func UpdatePurchaseOrderItemHandler(purchaseOrderService *purchase_order.Purchas
 	}
 }
 
-// GetAllPurchaseOrderHandler fetch all purchase orders
+// purchaseOrderExportColumns are the columns of a purchase order export
+var purchaseOrderExportColumns = []utils.ExportColumn{
+	{Header: "No. PO", Field: "serial_id"},
+	{Header: "Tanggal", Field: "order_date", Kind: utils.ExportDate},
+	{Header: "Pemasok", Field: "supplier_name"},
+	{Header: "Status", Field: "status"},
+	{Header: "Jumlah Item", Field: "item_count", Kind: utils.ExportNumber},
+	{Header: "Total", Field: "total_amount", Kind: utils.ExportRupiah},
+	{Header: "Dibuat Oleh", Field: "created_by"},
+}
+
+// GetAllPurchaseOrderHandler fetch all purchase orders, or downloads them with format=csv or format=xlsx
 func GetAllPurchaseOrderHandler(purchaseOrderService *purchase_order.PurchaseOrderService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		req := purchase_order.GetPurchaseOrderRequest{
 			SupplierID: r.URL.Query().Get("supplier_id"),
 			Status:     r.URL.Query().Get("status"),
@@ -300,13 +355,25 @@ func GetAllPurchaseOrderHandler(purchaseOrderService *purchase_order.PurchaseOrd
 				SortOrder: sortOrder,
 			},
 		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		validationErrors := utils.ValidateStruct(req)
 		if validationErrors != nil {
 			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
 			return
 		}
+
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "pesanan_pembelian", purchaseOrderExportColumns)
+			req.Export = export
+		}
+
 		orders, totalItems, apiError := purchaseOrderService.GetAllPurchaseOrder(req)
+		if export != nil {
+			export.Finish(apiError)
+			return
+		}
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
 			return
@@ -383,11 +450,27 @@ func GetSupplierByIDHandler(supplierService *purchase.SupplierService) http.Hand
 	}
 }
 
-// GetAllSuppliersHandler fetches all suppliers
+// supplierExportColumns are the columns of a supplier export
+var supplierExportColumns = []utils.ExportColumn{
+	{Header: "Nama", Field: "name"},
+	{Header: "Alamat", Field: "address"},
+	{Header: "Telepon", Field: "telephone"},
+	{Header: "Email", Field: "email"},
+	{Header: "Dibuat", Field: "created_at", Kind: utils.ExportDateTime},
+}
+
+// GetAllSuppliersHandler fetches all suppliers, or downloads them with format=csv or format=xlsx
 func GetAllSuppliersHandler(supplierService *purchase.SupplierService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		var req purchase.GetSupplierRequest
 		req.Name, req.Telephone = r.URL.Query().Get("name"), r.URL.Query().Get("telephone")
+		req.Search = r.URL.Query().Get("search")
 		req.Page = page
 		req.PageSize = pageSize
 		req.SortBy = sortBy
@@ -399,7 +482,17 @@ func GetAllSuppliersHandler(supplierService *purchase.SupplierService) http.Hand
 			return
 		}
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "pemasok", supplierExportColumns)
+			req.Export = export
+		}
+
 		suppliers, totalItems, apiError := supplierService.GetAllSuppliers(req)
+		if export != nil {
+			export.Finish(apiError)
+			return
+		}
 		if apiError != nil {
 			utils.ErrorJSON(w, apiError)
 			return
@@ -484,6 +577,7 @@ func CompleteFullPurchaseOrderHandler(purchaseOrderService *purchase_order.Purch
 
 		// Get user ID from context
 		userID := r.Context().Value("user_id").(string)
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		// Call service to complete the purchase order
 		apiError := purchaseOrderService.CompleteFullPurchaseOrder(req, userID)
@@ -0,0 +1,200 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/quotation"
+	"sinartimur-go/internal/sales"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// quotationExportColumns are the columns of a quotation export
+var quotationExportColumns = []utils.ExportColumn{
+	{Header: "No. Penawaran", Field: "serial_id"},
+	{Header: "Tanggal", Field: "quotation_date", Kind: utils.ExportDate},
+	{Header: "Berlaku Sampai", Field: "valid_until", Kind: utils.ExportDate},
+	{Header: "Pelanggan", Field: "customer_name"},
+	{Header: "Status", Field: "status"},
+	{Header: "Metode Pembayaran", Field: "payment_method"},
+	{Header: "Total", Field: "total_amount", Kind: utils.ExportRupiah},
+	{Header: "No. Pesanan", Field: "sales_order_serial_id"},
+}
+
+// GetQuotationsHandler fetches quotations with pagination, or downloads them with format=csv or format=xlsx
+func GetQuotationsHandler(quotationService *quotation.QuotationService) http.HandlerFunc {
+	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		var req quotation.GetQuotationsRequest
+		req.Search = r.URL.Query().Get("search")
+		req.Status = r.URL.Query().Get("status")
+		req.CustomerID = r.URL.Query().Get("customer_id")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+		req.Page = page
+		req.PageSize = pageSize
+		req.SortBy = sortBy
+		req.SortOrder = sortOrder
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "penawaran_penjualan", quotationExportColumns)
+			req.Export = export
+		}
+
+		quotations, totalItems, apiErr := quotationService.GetAll(req)
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WritePaginationJSON(w, http.StatusOK, page, totalItems, pageSize, quotations)
+	})
+}
+
+// CreateQuotationHandler creates a draft quotation
+func CreateQuotationHandler(quotationService *quotation.QuotationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req quotation.CreateQuotationRequest
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+		userID, _ := r.Context().Value("user_id").(string)
+
+		created, apiErr := quotationService.Create(req, userID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, created)
+	}
+}
+
+// GetQuotationHandler fetches a quotation with its items
+func GetQuotationHandler(quotationService *quotation.QuotationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID penawaran tidak valid",
+			}))
+			return
+		}
+		branchID, _ := r.Context().Value("branch_id").(string)
+
+		found, apiErr := quotationService.GetByID(id.String(), branchID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, found)
+	}
+}
+
+// UpdateQuotationHandler replaces a draft quotation
+func UpdateQuotationHandler(quotationService *quotation.QuotationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req quotation.UpdateQuotationRequest
+		req.ID = mux.Vars(r)["id"]
+
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
+		updated, apiErr := quotationService.Update(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, updated)
+	}
+}
+
+// UpdateQuotationStatusHandler marks a quotation as sent or rejected
+func UpdateQuotationStatusHandler(quotationService *quotation.QuotationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID penawaran tidak valid",
+			}))
+			return
+		}
+
+		var req quotation.UpdateQuotationStatusRequest
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+		branchID, _ := r.Context().Value("branch_id").(string)
+
+		updated, apiErr := quotationService.UpdateStatus(id.String(), req, branchID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, updated)
+	}
+}
+
+// ConvertQuotationHandler converts a quotation into a sales order
+func ConvertQuotationHandler(quotationService *quotation.QuotationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID penawaran tidak valid",
+			}))
+			return
+		}
+
+		// The options are optional, an empty body converts with the defaults
+		var req quotation.ConvertQuotationRequest
+		if r.ContentLength != 0 {
+			validationErrors := utils.DecodeAndValidate(r, &req)
+			if validationErrors != nil {
+				utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+				return
+			}
+		}
+		branchID, _ := r.Context().Value("branch_id").(string)
+		userID, _ := r.Context().Value("user_id").(string)
+		roles, _ := r.Context().Value("roles").([]string)
+		req.CanOverrideCredit = sales.CanOverrideCredit(roles)
+
+		created, apiErr := quotationService.Convert(id.String(), req, branchID, userID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, created)
+	}
+}
@@ -1,19 +1,38 @@
 package v1
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 	"sinartimur-go/internal/sales"
 	"sinartimur-go/pkg/dto"
 	"sinartimur-go/utils"
 	"strconv"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
-// GetSalesOrdersHandler retrieves a list of sales orders with pagination and filtering
+// salesOrderExportColumns are the columns of a sales order export
+var salesOrderExportColumns = []utils.ExportColumn{
+	{Header: "No. Pesanan", Field: "serial_id"},
+	{Header: "Tanggal", Field: "order_date", Kind: utils.ExportDate},
+	{Header: "Pelanggan", Field: "customer_name"},
+	{Header: "Status", Field: "status"},
+	{Header: "Metode Pembayaran", Field: "payment_method"},
+	{Header: "Jatuh Tempo", Field: "payment_due_date", Kind: utils.ExportDate},
+	{Header: "Total", Field: "total_amount", Kind: utils.ExportRupiah},
+	{Header: "Dibatalkan", Field: "cancelled_at", Kind: utils.ExportDateTime},
+}
+
+// GetSalesOrdersHandler retrieves a list of sales orders with pagination and filtering.
+// With format=csv or format=xlsx every matching order is downloaded as a spreadsheet.
 func GetSalesOrdersHandler(salesService *sales.SalesService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
 		// Extract filter parameters
 		req := sales.GetSalesOrdersRequest{
 			CustomerID:    r.URL.Query().Get("customer_id"),
@@ -29,6 +48,7 @@ func GetSalesOrdersHandler(salesService *sales.SalesService) http.HandlerFunc {
 				SortOrder: sortOrder,
 			},
 		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		// Validate filter parameters if provided
 		if errors := utils.ValidateStruct(req); errors != nil {
@@ -39,15 +59,28 @@ func GetSalesOrdersHandler(salesService *sales.SalesService) http.HandlerFunc {
 			return
 		}
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "pesanan_penjualan", salesOrderExportColumns)
+			req.Export = export
+		}
+
 		// Call service to get data
 		orders, totalCount, err := salesService.GetSalesOrders(req)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
+			apiErr = &dto.APIError{
 				StatusCode: http.StatusInternalServerError,
 				Details: map[string]string{
 					"general": err.Error(),
 				},
-			})
+			}
+		}
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
 			return
 		}
 
@@ -69,6 +102,20 @@ func GetSalesOrderBatchesHandler(salesService *sales.SalesService) http.HandlerF
 				SortOrder: sortOrder,
 			},
 		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
+		// With a customer the batches carry the price list price for the quantity being ordered
+		req.CustomerID = r.URL.Query().Get("customer_id")
+		if quantity := r.URL.Query().Get("quantity"); quantity != "" {
+			parsed, errParse := strconv.ParseFloat(quantity, 64)
+			if errParse != nil {
+				utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+					"quantity": "Jumlah tidak valid",
+				}))
+				return
+			}
+			req.Quantity = parsed
+		}
 
 		// Validate filter parameters if provided
 		if errors := utils.ValidateStruct(req); errors != nil {
@@ -112,6 +159,10 @@ func CreateSalesOrderHandler(salesService *sales.SalesService) http.HandlerFunc
 			return
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+		roles, _ := r.Context().Value("roles").([]string)
+		req.CanOverrideCredit = sales.CanOverrideCredit(roles)
+
 		// Call service to create purchase-order
 		response, err := salesService.CreateSalesOrder(req, userID)
 		if err != nil {
@@ -149,15 +200,12 @@ func UpdateSalesOrderHandler(salesService *sales.SalesService) http.HandlerFunc
 			return
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Call service to update purchase-order
 		response, err := salesService.UpdateSalesOrder(req)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -170,8 +218,7 @@ func UpdateSalesOrderHandler(salesService *sales.SalesService) http.HandlerFunc
 func AddItemToSalesOrderHandler(salesService *sales.SalesService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract user ID from token
-		//userID := r.Context().Value("user_id").(string)
-		//
+		userID, _ := r.Context().Value("user_id").(string)
 
 		// Extract purchase-order ID from URL path parameters
 		vars := mux.Vars(r)
@@ -188,15 +235,15 @@ func AddItemToSalesOrderHandler(salesService *sales.SalesService) http.HandlerFu
 			return
 		}
 
+		roles, _ := r.Context().Value("roles").([]string)
+		req.CanOverrideCredit = sales.CanOverrideCredit(roles)
+
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Call service to add item
-		response, err := salesService.AddSalesOrderItem(req)
+		response, err := salesService.AddSalesOrderItem(req, userID)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -209,8 +256,8 @@ func AddItemToSalesOrderHandler(salesService *sales.SalesService) http.HandlerFu
 func UpdateSalesOrderItemHandler(salesService *sales.SalesService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract user ID from token
-		//userID := r.Context().Value("user_id").(string)
-		//
+		userID, _ := r.Context().Value("user_id").(string)
+
 		// Extract purchase-order ID and detail ID from URL path parameters
 		vars := mux.Vars(r)
 		salesOrderID := vars["id"]
@@ -228,17 +275,15 @@ func UpdateSalesOrderItemHandler(salesService *sales.SalesService) http.HandlerF
 			return
 		}
 
-		fmt.Println("req", req)
+		roles, _ := r.Context().Value("roles").([]string)
+		req.CanOverrideCredit = sales.CanOverrideCredit(roles)
+
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		// Call service to update item
-		response, err := salesService.UpdateSalesOrderItem(req)
+		response, err := salesService.UpdateSalesOrderItem(req, userID)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -274,15 +319,12 @@ func DeleteSalesOrderItemHandler(salesService *sales.SalesService) http.HandlerF
 			DetailID:     detailID,
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Call service to delete item
 		err := salesService.DeleteSalesOrderItem(req)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -311,14 +353,10 @@ func GetSalesOrderDetailsHandler(salesService *sales.SalesService) http.HandlerF
 		}
 
 		// Call service to get purchase-order details
-		details, err := salesService.GetSalesOrderDetail(orderID)
+		branchID, _ := r.Context().Value("branch_id").(string)
+		details, err := salesService.GetSalesOrderDetail(orderID, branchID)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -327,6 +365,28 @@ func GetSalesOrderDetailsHandler(salesService *sales.SalesService) http.HandlerF
 	}
 }
 
+// GetSalesInvoiceItemsHandler handles fetching the order lines covered by a sales invoice
+func GetSalesInvoiceItemsHandler(salesService *sales.SalesService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invoiceID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID faktur tidak valid",
+			}))
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		items, err := salesService.GetSalesInvoiceItems(invoiceID.String(), branchID)
+		if err != nil {
+			utils.ErrorJSON(w, salesError(err))
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, items)
+	}
+}
+
 // CancelSalesOrderHandler handles cancelling a sales purchase-order
 func CancelSalesOrderHandler(salesService *sales.SalesService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -347,16 +407,13 @@ func CancelSalesOrderHandler(salesService *sales.SalesService) http.HandlerFunc
 			return
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Call service to cancel purchase-order
 		err := salesService.CancelSalesOrder(req, userID)
 		if err != nil {
 
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -367,9 +424,36 @@ func CancelSalesOrderHandler(salesService *sales.SalesService) http.HandlerFunc
 	}
 }
 
-// GetSalesInvoicesHandler retrieves a paginated list of sales invoices with filtering
+// salesInvoiceExportColumns are the columns of a sales invoice export
+var salesInvoiceExportColumns = []utils.ExportColumn{
+	{Header: "No. Faktur", Field: "serial_id"},
+	{Header: "Tanggal", Field: "invoice_date", Kind: utils.ExportDate},
+	{Header: "No. Pesanan", Field: "sales_order_serial"},
+	{Header: "Pelanggan", Field: "customer_name"},
+	{Header: "Status", Field: "status"},
+	{Header: "Subtotal", Field: "subtotal", Kind: utils.ExportRupiah},
+	{Header: "Diskon", Field: "discount_amount", Kind: utils.ExportRupiah},
+	{Header: "DPP", Field: "tax_base", Kind: utils.ExportRupiah},
+	{Header: "PPN", Field: "tax_amount", Kind: utils.ExportRupiah},
+	{Header: "Total", Field: "total_amount", Kind: utils.ExportRupiah},
+	{Header: "Terbayar", Field: "paid_amount", Kind: utils.ExportRupiah},
+	{Header: "Sisa Tagihan", Field: "outstanding_amount", Kind: utils.ExportRupiah},
+	{Header: "Jatuh Tempo", Field: "due_date", Kind: utils.ExportDate},
+	{Header: "Status Pembayaran", Field: "payment_status"},
+	{Header: "Dibuat Oleh", Field: "created_by"},
+	{Header: "Dibatalkan", Field: "cancelled_at", Kind: utils.ExportDateTime},
+}
+
+// GetSalesInvoicesHandler retrieves a paginated list of sales invoices with filtering.
+// With format=csv or format=xlsx every matching invoice is downloaded as a spreadsheet.
 func GetSalesInvoicesHandler(salesService *sales.SalesService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		// Extract pagination parameters
 		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 		if page == 0 {
@@ -386,11 +470,12 @@ func GetSalesInvoicesHandler(salesService *sales.SalesService) http.HandlerFunc
 
 		// Extract filter parameters
 		req := sales.GetSalesInvoicesRequest{
-			CustomerID: r.URL.Query().Get("customer_id"),
-			Status:     r.URL.Query().Get("status"),
-			StartDate:  r.URL.Query().Get("start_date"),
-			EndDate:    r.URL.Query().Get("end_date"),
-			SerialID:   r.URL.Query().Get("serial_id"),
+			CustomerID:    r.URL.Query().Get("customer_id"),
+			Status:        r.URL.Query().Get("status"),
+			PaymentStatus: r.URL.Query().Get("payment_status"),
+			StartDate:     r.URL.Query().Get("start_date"),
+			EndDate:       r.URL.Query().Get("end_date"),
+			SerialID:      r.URL.Query().Get("serial_id"),
 			PaginationParameter: utils.PaginationParameter{
 				Page:      page,
 				PageSize:  pageSize,
@@ -398,6 +483,7 @@ func GetSalesInvoicesHandler(salesService *sales.SalesService) http.HandlerFunc
 				SortOrder: sortOrder,
 			},
 		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
 
 		// Validate filter parameters if provided
 		if errors := utils.ValidateStruct(req); errors != nil {
@@ -408,15 +494,28 @@ func GetSalesInvoicesHandler(salesService *sales.SalesService) http.HandlerFunc
 			return
 		}
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "faktur_penjualan", salesInvoiceExportColumns)
+			req.Export = export
+		}
+
 		// Call service to get data
 		invoices, totalCount, err := salesService.GetSalesInvoices(req)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
+			apiErr = &dto.APIError{
 				StatusCode: http.StatusBadRequest,
 				Details: map[string]string{
 					"general": err.Error(),
 				},
-			})
+			}
+		}
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
 			return
 		}
 
@@ -442,15 +541,12 @@ func CreateSalesInvoiceHandler(salesService *sales.SalesService) http.HandlerFun
 			return
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Call service to create invoice
 		response, err := salesService.CreateSalesInvoice(req, userID)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -476,15 +572,12 @@ func CancelSalesInvoiceHandler(salesService *sales.SalesService) http.HandlerFun
 			return
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Call service to cancel invoice
 		err := salesService.CancelSalesInvoice(req, userID)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -512,15 +605,12 @@ func ReturnInvoiceItemsHandler(salesService *sales.SalesService) http.HandlerFun
 			return
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Call service to process returns
 		response, err := salesService.ReturnInvoiceItems(req, userID)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -546,22 +636,117 @@ func CancelInvoiceReturnHandler(salesService *sales.SalesService) http.HandlerFu
 			return
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Call service to cancel return
 		err := salesService.CancelReturn(req, userID)
 		if err != nil {
+			utils.ErrorJSON(w, salesError(err))
+			return
+		}
+
+		// Return success response
+		utils.WriteJSON(w, http.StatusOK, map[string]string{
+			"message": "Pengembalian berhasil dibatalkan",
+		})
+	}
+}
+
+// deliveryNoteExportColumns are the columns of a delivery note export
+var deliveryNoteExportColumns = []utils.ExportColumn{
+	{Header: "No. Surat Jalan", Field: "serial_id"},
+	{Header: "Tanggal Kirim", Field: "delivery_date", Kind: utils.ExportDate},
+	{Header: "No. Pesanan", Field: "sales_order_serial"},
+	{Header: "No. Faktur", Field: "sales_invoice_serial"},
+	{Header: "Pelanggan", Field: "customer_name"},
+	{Header: "Sopir", Field: "driver_name"},
+	{Header: "Penerima", Field: "recipient_name"},
+	{Header: "Jumlah", Field: "total_quantity", Kind: utils.ExportNumber},
+	{Header: "Status", Field: "status"},
+	{Header: "Dibatalkan", Field: "cancelled_at", Kind: utils.ExportDateTime},
+}
+
+// GetDeliveryNotesHandler handles fetching delivery notes with pagination and filtering.
+// With format=csv or format=xlsx every matching delivery note is downloaded as a spreadsheet.
+func GetDeliveryNotesHandler(salesService *sales.SalesService) http.HandlerFunc {
+	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		req := sales.GetDeliveryNotesRequest{
+			SalesOrderID:   r.URL.Query().Get("sales_order_id"),
+			SalesInvoiceID: r.URL.Query().Get("sales_invoice_id"),
+			StartDate:      r.URL.Query().Get("start_date"),
+			EndDate:        r.URL.Query().Get("end_date"),
+			SerialID:       r.URL.Query().Get("serial_id"),
+			Status:         r.URL.Query().Get("status"),
+			PaginationParameter: utils.PaginationParameter{
+				Page:      page,
+				PageSize:  pageSize,
+				SortBy:    sortBy,
+				SortOrder: sortOrder,
+			},
+		}
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
+		if errors := utils.ValidateStruct(req); errors != nil {
 			utils.ErrorJSON(w, &dto.APIError{
+				StatusCode: http.StatusBadRequest,
+				Details:    errors,
+			})
+			return
+		}
+
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "surat_jalan", deliveryNoteExportColumns)
+			req.Export = export
+		}
+
+		notes, totalCount, err := salesService.GetDeliveryNotes(req)
+		if err != nil {
+			apiErr = &dto.APIError{
 				StatusCode: http.StatusBadRequest,
 				Details: map[string]string{
 					"general": err.Error(),
 				},
-			})
+			}
+		}
+		if export != nil {
+			export.Finish(apiErr)
+			return
+		}
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
 			return
 		}
 
-		// Return success response
-		utils.WriteJSON(w, http.StatusOK, map[string]string{
-			"message": "Pengembalian berhasil dibatalkan",
-		})
+		utils.WritePaginationJSON(w, http.StatusOK, page, totalCount, pageSize, notes)
+	})
+}
+
+// GetDeliveryNoteItemsHandler handles fetching the lines carried by a delivery note
+func GetDeliveryNoteItemsHandler(salesService *sales.SalesService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deliveryNoteID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID surat jalan tidak valid",
+			}))
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		items, err := salesService.GetDeliveryNoteItems(deliveryNoteID.String(), branchID)
+		if err != nil {
+			utils.ErrorJSON(w, salesError(err))
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, items)
 	}
 }
 
@@ -582,15 +767,12 @@ func CreateDeliveryNoteHandler(salesService *sales.SalesService) http.HandlerFun
 			return
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Call service to create delivery note
 		response, err := salesService.CreateDeliveryNote(req, userID)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -623,15 +805,12 @@ func CancelDeliveryNoteHandler(salesService *sales.SalesService) http.HandlerFun
 			DeliveryNoteID: deliveryNoteID,
 		}
 
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
 		// Call service to cancel delivery note
 		err := salesService.CancelDeliveryNote(req, userID)
 		if err != nil {
-			utils.ErrorJSON(w, &dto.APIError{
-				StatusCode: http.StatusBadRequest,
-				Details: map[string]string{
-					"general": err.Error(),
-				},
-			})
+			utils.ErrorJSON(w, salesError(err))
 			return
 		}
 
@@ -641,3 +820,14 @@ func CancelDeliveryNoteHandler(salesService *sales.SalesService) http.HandlerFun
 		})
 	}
 }
+
+// salesError maps a sales service error to a response, documents hidden from the active branch are not found
+func salesError(err error) *dto.APIError {
+	status := http.StatusBadRequest
+	if errors.Is(err, sales.ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	return dto.NewAPIError(status, map[string]string{
+		"general": err.Error(),
+	})
+}
@@ -0,0 +1,36 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/search"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+	"strconv"
+)
+
+// GlobalSearchHandler searches serial numbers, SKUs and master data across all modules
+func GlobalSearchHandler(searchService *search.SearchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req search.GlobalSearchRequest
+		req.Query = r.URL.Query().Get("q")
+		req.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
+		// Validate
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		// Results are filtered by the caller's roles
+		roles, _ := r.Context().Value("roles").([]string)
+
+		results, apiErr := searchService.Search(req, roles)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, results)
+	}
+}
@@ -0,0 +1,84 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sinartimur-go/internal/stream"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+	"strings"
+	"time"
+)
+
+// streamHeartbeat keeps idle connections open through proxies
+const streamHeartbeat = 25 * time.Second
+
+// EventStreamHandler streams stock, document and report refresh events as server-sent events
+func EventStreamHandler(streamService *stream.StreamService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req stream.StreamRequest
+		if topics := r.URL.Query().Get("topics"); topics != "" {
+			req.Topics = strings.Split(topics, ",")
+		}
+		req.StorageID = r.URL.Query().Get("storage_id")
+		req.ProductID = r.URL.Query().Get("product_id")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+
+		// Validate
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+				"general": "Streaming tidak didukung",
+			}))
+			return
+		}
+
+		// Topics are filtered by the caller's roles
+		roles, _ := r.Context().Value("roles").([]string)
+
+		client, apiErr := streamService.Subscribe(req, roles)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+		defer streamService.Unsubscribe(client)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 5000\n\n")
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+				flusher.Flush()
+			case evt, ok := <-client.Events:
+				if !ok {
+					// Dropped for being too slow, the client reconnects
+					return
+				}
+				data, err := json.Marshal(evt)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", evt.ID, evt.Type, data)
+				flusher.Flush()
+			}
+		}
+	}
+}
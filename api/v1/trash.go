@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/trash"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// GetTrashHandler fetches the deleted records of a resource with pagination
+func GetTrashHandler(trashService *trash.TrashService, resource string) http.HandlerFunc {
+	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		var req trash.GetTrashRequest
+		req.Resource = resource
+		req.Search = r.URL.Query().Get("search")
+		req.BranchID, _ = r.Context().Value("branch_id").(string)
+		req.Page = page
+		req.PageSize = pageSize
+		req.SortBy = sortBy
+		req.SortOrder = sortOrder
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		items, totalItems, apiErr := trashService.GetTrash(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WritePaginationJSON(w, http.StatusOK, page, totalItems, pageSize, items)
+	})
+}
+
+// RestoreTrashHandler restores a deleted record of a resource
+func RestoreTrashHandler(trashService *trash.TrashService, resource string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID tidak valid",
+			}))
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		restored, apiErr := trashService.RestoreItem(resource, id.String(), branchID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, restored)
+	}
+}
+
+// PurgeTrashHandler permanently deletes a record of a resource from the trash
+func PurgeTrashHandler(trashService *trash.TrashService, resource string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID tidak valid",
+			}))
+			return
+		}
+
+		branchID, _ := r.Context().Value("branch_id").(string)
+		if apiErr := trashService.PurgeItem(resource, id.String(), branchID); apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, utils.WriteMessage("Data berhasil dihapus permanen"))
+	}
+}
@@ -10,9 +10,22 @@ import (
 	"sinartimur-go/utils"
 )
 
-// GetAllUnitHandler fetches all units
+// unitExportColumns are the columns of a unit export
+var unitExportColumns = []utils.ExportColumn{
+	{Header: "Nama", Field: "name"},
+	{Header: "Deskripsi", Field: "description"},
+	{Header: "Dibuat", Field: "created_at", Kind: utils.ExportDateTime},
+}
+
+// GetAllUnitHandler fetches all units, or downloads them with format=csv or format=xlsx
 func GetAllUnitHandler(unitService *unit.UnitService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		var req unit.GetUnitRequest
 		req.Name = r.URL.Query().Get("name")
 		// Validate req
@@ -27,6 +40,11 @@ func GetAllUnitHandler(unitService *unit.UnitService) http.HandlerFunc {
 			return
 		}
 
+		if format != "" {
+			utils.WriteExport(w, format, "satuan", unitExportColumns, units)
+			return
+		}
+
 		utils.WriteJSON(w, http.StatusOK, units)
 	}
 }
@@ -64,9 +64,24 @@ func UpdateWageHandler(wageService *wage.WageService) http.HandlerFunc {
 	}
 }
 
-// GetAllWagesHandler fetches all wages
+// wageExportColumns are the columns of a wage export
+var wageExportColumns = []utils.ExportColumn{
+	{Header: "Karyawan", Field: "employee_name"},
+	{Header: "Bulan", Field: "month"},
+	{Header: "Tahun", Field: "year"},
+	{Header: "Total Gaji", Field: "total_amount", Kind: utils.ExportRupiah},
+	{Header: "Dibuat", Field: "created_at", Kind: utils.ExportDateTime},
+}
+
+// GetAllWagesHandler fetches all wages, or downloads them with format=csv or format=xlsx
 func GetAllWagesHandler(wageService *wage.WageService) http.HandlerFunc {
 	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		format, apiErr := utils.ExportFormat(r)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
 		var req wage.GetWageRequest
 		req.EmployeeId = r.URL.Query().Get("employee_id")
 		req.Year, _ = strconv.Atoi(r.URL.Query().Get("year"))
@@ -82,7 +97,17 @@ func GetAllWagesHandler(wageService *wage.WageService) http.HandlerFunc {
 			return
 		}
 
+		var export *utils.ExportWriter
+		if format != "" {
+			export = utils.NewExportWriter(w, format, "gaji", wageExportColumns)
+			req.Export = export
+		}
+
 		wages, totalItems, errService := wageService.GetAllWages(req)
+		if export != nil {
+			export.Finish(errService)
+			return
+		}
 		if errService != nil {
 			utils.ErrorJSON(w, errService)
 			return
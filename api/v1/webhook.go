@@ -0,0 +1,179 @@
+package v1
+
+import (
+	"net/http"
+	"sinartimur-go/internal/webhook"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/pkg/event"
+	"sinartimur-go/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CreateWebhookHandler creates a new webhook subscription
+func CreateWebhookHandler(webhookService *webhook.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req webhook.CreateSubscriptionRequest
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+
+		userID := r.Context().Value("user_id").(string)
+
+		subscription, apiErr := webhookService.CreateSubscription(req, userID)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusCreated, subscription)
+	}
+}
+
+// GetAllWebhooksHandler fetches all webhook subscriptions with pagination
+func GetAllWebhooksHandler(webhookService *webhook.WebhookService) http.HandlerFunc {
+	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		var req webhook.GetSubscriptionsRequest
+		req.Event = r.URL.Query().Get("event")
+		req.Page = page
+		req.PageSize = pageSize
+		req.SortBy = sortBy
+		req.SortOrder = sortOrder
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		subscriptions, totalItems, apiErr := webhookService.GetAllSubscriptions(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WritePaginationJSON(w, http.StatusOK, page, totalItems, pageSize, subscriptions)
+	})
+}
+
+// GetWebhookHandler fetches a webhook subscription by ID
+func GetWebhookHandler(webhookService *webhook.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID webhook tidak valid",
+			}))
+			return
+		}
+
+		subscription, apiErr := webhookService.GetSubscriptionByID(id.String())
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, subscription)
+	}
+}
+
+// UpdateWebhookHandler updates a webhook subscription
+func UpdateWebhookHandler(webhookService *webhook.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req webhook.UpdateSubscriptionRequest
+		req.ID = mux.Vars(r)["id"]
+
+		validationErrors := utils.DecodeAndValidate(r, &req)
+		if validationErrors != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, validationErrors))
+			return
+		}
+
+		subscription, apiErr := webhookService.UpdateSubscription(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, subscription)
+	}
+}
+
+// DeleteWebhookHandler deletes a webhook subscription
+func DeleteWebhookHandler(webhookService *webhook.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID webhook tidak valid",
+			}))
+			return
+		}
+
+		if apiErr := webhookService.DeleteSubscription(id.String()); apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, utils.WriteMessage("Webhook berhasil dihapus"))
+	}
+}
+
+// GetWebhookEventTypesHandler lists the event types that can be subscribed to
+func GetWebhookEventTypesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteJSON(w, http.StatusOK, event.Types)
+	}
+}
+
+// GetWebhookDeliveriesHandler fetches the webhook delivery log
+func GetWebhookDeliveriesHandler(webhookService *webhook.WebhookService) http.HandlerFunc {
+	return utils.NewPaginatedHandler(func(w http.ResponseWriter, r *http.Request, page, pageSize int, sortBy, sortOrder string) {
+		var req webhook.GetDeliveriesRequest
+		req.SubscriptionID = r.URL.Query().Get("subscription_id")
+		req.EventType = r.URL.Query().Get("event_type")
+		req.Status = r.URL.Query().Get("status")
+		req.Page = page
+		req.PageSize = pageSize
+		req.SortBy = sortBy
+		req.SortOrder = sortOrder
+
+		// Validate request
+		if err := utils.ValidateStruct(req); err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, err))
+			return
+		}
+
+		deliveries, totalItems, apiErr := webhookService.GetDeliveries(req)
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WritePaginationJSON(w, http.StatusOK, page, totalItems, pageSize, deliveries)
+	})
+}
+
+// RedeliverWebhookHandler queues a delivery to be sent again
+func RedeliverWebhookHandler(webhookService *webhook.WebhookService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"id": "ID pengiriman tidak valid",
+			}))
+			return
+		}
+
+		delivery, apiErr := webhookService.Redeliver(id.String())
+		if apiErr != nil {
+			utils.ErrorJSON(w, apiErr)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusAccepted, delivery)
+	}
+}
@@ -6,20 +6,37 @@ import (
 	"net/http"
 	"os"
 	"sinartimur-go/config"
+	"sinartimur-go/internal/attachment"
 	"sinartimur-go/internal/auth"
+	"sinartimur-go/internal/branch"
 	"sinartimur-go/internal/category"
+	"sinartimur-go/internal/creditnote"
 	"sinartimur-go/internal/customer"
+	"sinartimur-go/internal/document"
+	"sinartimur-go/internal/email"
 	"sinartimur-go/internal/employee"
 	"sinartimur-go/internal/finance"
+	"sinartimur-go/internal/importer"
 	"sinartimur-go/internal/inventory"
+	"sinartimur-go/internal/numbering"
+	"sinartimur-go/internal/outbox"
+	"sinartimur-go/internal/payment"
+	"sinartimur-go/internal/pricelist"
 	"sinartimur-go/internal/product"
 	"sinartimur-go/internal/purchase"
 	purchase_order "sinartimur-go/internal/purchase/purchase-order"
+	"sinartimur-go/internal/quotation"
 	"sinartimur-go/internal/sales"
+	"sinartimur-go/internal/search"
+	"sinartimur-go/internal/stream"
+	"sinartimur-go/internal/trash"
 	"sinartimur-go/internal/unit"
 	"sinartimur-go/internal/user"
 	"sinartimur-go/internal/wage"
+	"sinartimur-go/internal/webhook"
 	"sinartimur-go/middleware"
+	"sinartimur-go/pkg/mailer"
+	"sinartimur-go/pkg/storage"
 	"sinartimur-go/utils"
 
 	"github.com/gorilla/handlers"
@@ -36,12 +53,23 @@ func main() {
 	}()
 
 	redisClient := config.NewRedisClient()
+	sender := config.NewMailer()
+	files := config.NewStorage()
 
 	// Register custom validations
 	utils.RegisterCustomValidators()
 
 	// Build services
-	services := BuildServices(db, redisClient)
+	services := BuildServices(db, redisClient, sender, files)
+
+	// Start dispatching outbox events, streaming them to clients, delivering queued webhooks, sending queued emails and expiring stock reservations in the background
+	services.OutboxDispatcher.Start()
+	if err := services.StreamService.Start(config.NewPostgresListener()); err != nil {
+		log.Fatalf("Failed to start event stream: %v", err)
+	}
+	services.WebhookService.Start()
+	services.EmailService.Start()
+	services.SalesService.Start()
 
 	// Initialize v1 and middleware
 	v1 := mux.NewRouter().PathPrefix("/api/v1").Subrouter()
@@ -68,9 +96,24 @@ type Services struct {
 	CustomerService      *customer.CustomerService
 	FinanceService       *finance.FinanceService
 	SalesService         *sales.SalesService
+	QuotationService     *quotation.QuotationService
+	PaymentService       *payment.PaymentService
+	CreditNoteService    *creditnote.CreditNoteService
+	PriceListService     *pricelist.PriceListService
+	SearchService        *search.SearchService
+	WebhookService       *webhook.WebhookService
+	StreamService        *stream.StreamService
+	NumberingService     *numbering.NumberingService
+	BranchService        *branch.BranchService
+	TrashService         *trash.TrashService
+	ImportService        *importer.ImportService
+	DocumentService      *document.DocumentService
+	EmailService         *email.EmailService
+	AttachmentService    *attachment.AttachmentService
+	OutboxDispatcher     *outbox.Dispatcher
 }
 
-func BuildServices(db *sql.DB, redis *config.RedisClient) *Services {
+func BuildServices(db *sql.DB, redis *config.RedisClient, sender mailer.Mailer, files storage.Storage) *Services {
 	authRepo := auth.NewAuthRepository(db)
 	authService := auth.NewAuthService(authRepo, redis)
 
@@ -110,8 +153,47 @@ func BuildServices(db *sql.DB, redis *config.RedisClient) *Services {
 	financeRepo := finance.NewFinanceTransactionRepository(db)
 	financeService := finance.NewFinanceTransactionService(financeRepo)
 
-	salesRepo := sales.NewSalesRepository(db)
+	salesRepo := sales.NewSalesRepository(db, config.ReservationTTL())
 	salesService := sales.NewSalesService(salesRepo)
+	quotationRepo := quotation.NewQuotationRepository(db)
+	quotationService := quotation.NewQuotationService(quotationRepo, salesService)
+	paymentRepo := payment.NewPaymentRepository(db)
+	paymentService := payment.NewPaymentService(paymentRepo)
+	creditNoteRepo := creditnote.NewCreditNoteRepository(db)
+	creditNoteService := creditnote.NewCreditNoteService(creditNoteRepo)
+	priceListRepo := pricelist.NewPriceListRepository(db)
+	priceListService := pricelist.NewPriceListService(priceListRepo)
+
+	webhookRepo := webhook.NewWebhookRepository(db)
+	webhookService := webhook.NewWebhookService(webhookRepo)
+
+	// Domain events recorded by the repositories are fanned out to subscribers
+	outboxRepo := outbox.NewOutboxRepository(db)
+	outboxDispatcher := outbox.NewDispatcher(outboxRepo)
+	outboxDispatcher.Subscribe("webhook", webhookService.HandleEvent)
+
+	// The stream listens for events itself, so clients on every instance receive them
+	streamRepo := stream.NewStreamRepository(db)
+	streamService := stream.NewStreamService(streamRepo)
+
+	numberingRepo := numbering.NewNumberingRepository(db)
+	numberingService := numbering.NewNumberingService(numberingRepo)
+
+	branchRepo := branch.NewBranchRepository(db)
+	branchService := branch.NewBranchService(branchRepo)
+	trashRepo := trash.NewTrashRepository(db)
+	trashService := trash.NewTrashService(trashRepo)
+	importRepo := importer.NewImportRepository(db)
+	importService := importer.NewImportService(importRepo)
+	documentRepo := document.NewDocumentRepository(db)
+	documentService := document.NewDocumentService(documentRepo, salesRepo, purchaseOrderRepo, creditNoteRepo)
+	emailRepo := email.NewEmailRepository(db)
+	emailService := email.NewEmailService(emailRepo, documentService, sender)
+	attachmentRepo := attachment.NewAttachmentRepository(db)
+	attachmentService := attachment.NewAttachmentService(attachmentRepo, files)
+
+	searchRepo := search.NewSearchRepository(db)
+	searchService := search.NewSearchService(searchRepo)
 
 	return &Services{
 		AuthService:     authService,
@@ -128,5 +210,20 @@ func BuildServices(db *sql.DB, redis *config.RedisClient) *Services {
 		CustomerService:      customerService,
 		FinanceService:       financeService,
 		SalesService:         salesService,
+		QuotationService:     quotationService,
+		PaymentService:       paymentService,
+		CreditNoteService:    creditNoteService,
+		PriceListService:     priceListService,
+		SearchService:        searchService,
+		WebhookService:       webhookService,
+		StreamService:        streamService,
+		NumberingService:     numberingService,
+		BranchService:        branchService,
+		TrashService:         trashService,
+		ImportService:        importService,
+		DocumentService:      documentService,
+		EmailService:         emailService,
+		AttachmentService:    attachmentService,
+		OutboxDispatcher:     outboxDispatcher,
 	}
 }
@@ -2,19 +2,33 @@ package main
 
 import (
 	v1 "sinartimur-go/api/v1"
+	"sinartimur-go/internal/attachment"
 	"sinartimur-go/internal/auth"
+	"sinartimur-go/internal/branch"
 	"sinartimur-go/internal/category"
+	"sinartimur-go/internal/creditnote"
 	"sinartimur-go/internal/customer"
+	"sinartimur-go/internal/document"
+	"sinartimur-go/internal/email"
 	"sinartimur-go/internal/employee"
 	"sinartimur-go/internal/finance"
+	"sinartimur-go/internal/importer"
 	"sinartimur-go/internal/inventory"
+	"sinartimur-go/internal/numbering"
+	"sinartimur-go/internal/payment"
+	"sinartimur-go/internal/pricelist"
 	"sinartimur-go/internal/product"
 	"sinartimur-go/internal/purchase"
 	purchase_order "sinartimur-go/internal/purchase/purchase-order"
+	"sinartimur-go/internal/quotation"
 	"sinartimur-go/internal/sales"
+	"sinartimur-go/internal/search"
+	"sinartimur-go/internal/stream"
+	"sinartimur-go/internal/trash"
 	"sinartimur-go/internal/unit"
 	"sinartimur-go/internal/user"
 	"sinartimur-go/internal/wage"
+	"sinartimur-go/internal/webhook"
 	"sinartimur-go/middleware"
 
 	"github.com/gorilla/mux"
@@ -131,6 +145,43 @@ func RegisterFinanceTransactionRoutes(router *mux.Router, service *finance.Finan
 	router.HandleFunc("/transactions/refresh", v1.RefreshFinanceTransactionViewHandler(service)).Methods("POST")
 }
 
+// RegisterQuotationRoutes registers the sales quotation endpoints
+func RegisterQuotationRoutes(router *mux.Router, quotationService *quotation.QuotationService) {
+	router.HandleFunc("/quotations", v1.GetQuotationsHandler(quotationService)).Methods("GET")
+	router.HandleFunc("/quotation", v1.CreateQuotationHandler(quotationService)).Methods("POST")
+	router.HandleFunc("/quotation/{id}", v1.GetQuotationHandler(quotationService)).Methods("GET")
+	router.HandleFunc("/quotation/{id}", v1.UpdateQuotationHandler(quotationService)).Methods("PUT")
+	router.HandleFunc("/quotation/{id}/status", v1.UpdateQuotationStatusHandler(quotationService)).Methods("POST")
+	router.HandleFunc("/quotation/{id}/convert", v1.ConvertQuotationHandler(quotationService)).Methods("POST")
+}
+
+// RegisterPaymentRoutes registers the customer payment and receivable endpoints
+func RegisterPaymentRoutes(router *mux.Router, paymentService *payment.PaymentService) {
+	router.HandleFunc("/payments", v1.GetPaymentsHandler(paymentService)).Methods("GET")
+	router.HandleFunc("/payment", v1.CreatePaymentHandler(paymentService)).Methods("POST")
+	router.HandleFunc("/payment/{id}", v1.GetPaymentHandler(paymentService)).Methods("GET")
+	router.HandleFunc("/payment/{id}/cancel", v1.CancelPaymentHandler(paymentService)).Methods("POST")
+	router.HandleFunc("/customer/{id}/balance", v1.GetCustomerBalanceHandler(paymentService)).Methods("GET")
+	router.HandleFunc("/receivables/aging", v1.GetReceivableAgingHandler(paymentService)).Methods("GET")
+	router.HandleFunc("/receivables/aging/{id}", v1.GetCustomerReceivableAgingHandler(paymentService)).Methods("GET")
+}
+
+// RegisterCreditNoteRoutes registers the credit note endpoints of sales returns
+func RegisterCreditNoteRoutes(router *mux.Router, creditNoteService *creditnote.CreditNoteService) {
+	router.HandleFunc("/credit-notes", v1.GetCreditNotesHandler(creditNoteService)).Methods("GET")
+	router.HandleFunc("/return/{id}/credit-note", v1.CreateCreditNoteHandler(creditNoteService)).Methods("POST")
+	router.HandleFunc("/credit-note/{id}", v1.GetCreditNoteHandler(creditNoteService)).Methods("GET")
+}
+
+// RegisterPriceListRoutes registers the price list endpoints
+func RegisterPriceListRoutes(router *mux.Router, priceListService *pricelist.PriceListService) {
+	router.HandleFunc("/price-lists", v1.GetPriceListsHandler(priceListService)).Methods("GET")
+	router.HandleFunc("/price-list", v1.CreatePriceListHandler(priceListService)).Methods("POST")
+	router.HandleFunc("/price-list/{id}", v1.GetPriceListHandler(priceListService)).Methods("GET")
+	router.HandleFunc("/price-list/{id}", v1.UpdatePriceListHandler(priceListService)).Methods("PUT")
+	router.HandleFunc("/price-list/{id}", v1.DeletePriceListHandler(priceListService)).Methods("DELETE")
+}
+
 func RegisterSalesRoutes(router *mux.Router, salesService *sales.SalesService) {
 	// Sales Order endpoints
 	router.HandleFunc("/orders", v1.GetSalesOrdersHandler(salesService)).Methods("GET")
@@ -148,13 +199,16 @@ func RegisterSalesRoutes(router *mux.Router, salesService *sales.SalesService) {
 	router.HandleFunc("/invoices", v1.GetSalesInvoicesHandler(salesService)).Methods("GET")
 	router.HandleFunc("/invoice", v1.CreateSalesInvoiceHandler(salesService)).Methods("POST")
 	router.HandleFunc("/invoice/cancel", v1.CancelSalesInvoiceHandler(salesService)).Methods("POST")
+	router.HandleFunc("/invoice/{id}/items", v1.GetSalesInvoiceItemsHandler(salesService)).Methods("GET")
 
 	// Return endpoints
 	router.HandleFunc("/return", v1.ReturnInvoiceItemsHandler(salesService)).Methods("POST")
 	router.HandleFunc("/return/cancel", v1.CancelInvoiceReturnHandler(salesService)).Methods("POST")
 
 	// Delivery Note endpoints
+	router.HandleFunc("/delivery-notes", v1.GetDeliveryNotesHandler(salesService)).Methods("GET")
 	router.HandleFunc("/delivery-note", v1.CreateDeliveryNoteHandler(salesService)).Methods("POST")
+	router.HandleFunc("/delivery-note/{id}/items", v1.GetDeliveryNoteItemsHandler(salesService)).Methods("GET")
 	router.HandleFunc("/delivery-note/{delivery_note_id}/cancel", v1.CancelDeliveryNoteHandler(salesService)).Methods("POST")
 
 	// Get products and batches
@@ -162,6 +216,106 @@ func RegisterSalesRoutes(router *mux.Router, salesService *sales.SalesService) {
 	//router.HandleFunc("/product/{id}/batches", v1.GetProductBatchHandler(salesService)).Methods("GET")
 }
 
+func RegisterWebhookRoutes(router *mux.Router, webhookService *webhook.WebhookService) {
+	router.HandleFunc("/webhook", v1.CreateWebhookHandler(webhookService)).Methods("POST")
+	router.HandleFunc("/webhooks", v1.GetAllWebhooksHandler(webhookService)).Methods("GET")
+	router.HandleFunc("/webhooks/events", v1.GetWebhookEventTypesHandler()).Methods("GET")
+	router.HandleFunc("/webhooks/deliveries", v1.GetWebhookDeliveriesHandler(webhookService)).Methods("GET")
+	router.HandleFunc("/webhooks/deliveries/{id}/redeliver", v1.RedeliverWebhookHandler(webhookService)).Methods("POST")
+	router.HandleFunc("/webhook/{id}", v1.GetWebhookHandler(webhookService)).Methods("GET")
+	router.HandleFunc("/webhook/{id}", v1.UpdateWebhookHandler(webhookService)).Methods("PUT")
+	router.HandleFunc("/webhook/{id}", v1.DeleteWebhookHandler(webhookService)).Methods("DELETE")
+}
+
+func RegisterBranchRoutes(router *mux.Router, branchService *branch.BranchService) {
+	router.HandleFunc("/branch", v1.CreateBranchHandler(branchService)).Methods("POST")
+	router.HandleFunc("/branches", v1.GetAllBranchesHandler(branchService)).Methods("GET")
+	router.HandleFunc("/branches/summary", v1.GetBranchSummaryHandler(branchService)).Methods("GET")
+	router.HandleFunc("/branch/{id}", v1.GetBranchHandler(branchService)).Methods("GET")
+	router.HandleFunc("/branch/{id}", v1.UpdateBranchHandler(branchService)).Methods("PUT")
+	router.HandleFunc("/branch/{id}", v1.DeleteBranchHandler(branchService)).Methods("DELETE")
+	router.HandleFunc("/user/{id}/branches", v1.GetUserBranchesHandler(branchService)).Methods("GET")
+	router.HandleFunc("/user/{id}/branches", v1.AssignUserBranchesHandler(branchService)).Methods("PUT")
+}
+
+func RegisterNumberingRoutes(router *mux.Router, numberingService *numbering.NumberingService) {
+	router.HandleFunc("/numbering", v1.GetAllDocumentNumberingHandler(numberingService)).Methods("GET")
+	router.HandleFunc("/numbering/{type}", v1.UpdateDocumentNumberingHandler(numberingService)).Methods("PUT")
+}
+
+func RegisterSearchRoutes(router *mux.Router, searchService *search.SearchService) {
+	router.HandleFunc("", v1.GlobalSearchHandler(searchService)).Methods("GET")
+}
+
+func RegisterStreamRoutes(router *mux.Router, streamService *stream.StreamService) {
+	router.HandleFunc("", v1.EventStreamHandler(streamService)).Methods("GET")
+}
+
+func RegisterImportRoutes(router *mux.Router, importService *importer.ImportService) {
+	router.HandleFunc("/import/{resource}", v1.CreateImportHandler(importService)).Methods("POST")
+	router.HandleFunc("/import/{resource}/template", v1.GetImportTemplateHandler()).Methods("GET")
+	router.HandleFunc("/imports", v1.GetAllImportsHandler(importService)).Methods("GET")
+	router.HandleFunc("/imports/{id}", v1.GetImportHandler(importService)).Methods("GET")
+	router.HandleFunc("/imports/{id}/commit", v1.CommitImportHandler(importService)).Methods("POST")
+}
+
+func RegisterCompanySettingRoutes(router *mux.Router, documentService *document.DocumentService) {
+	router.HandleFunc("/company", v1.GetCompanySettingHandler(documentService)).Methods("GET")
+	router.HandleFunc("/company", v1.UpdateCompanySettingHandler(documentService)).Methods("PUT")
+}
+
+// RegisterSalesDocumentRoutes registers the PDF and receipt endpoints of sales documents
+func RegisterSalesDocumentRoutes(router *mux.Router, documentService *document.DocumentService) {
+	router.HandleFunc("/invoice/{id}/pdf", v1.GetDocumentPDFHandler(documentService, document.TypeSalesInvoice)).Methods("GET")
+	router.HandleFunc("/delivery-note/{id}/pdf", v1.GetDocumentPDFHandler(documentService, document.TypeDeliveryNote)).Methods("GET")
+	router.HandleFunc("/return/{id}/pdf", v1.GetDocumentPDFHandler(documentService, document.TypeSalesReturn)).Methods("GET")
+	router.HandleFunc("/credit-note/{id}/pdf", v1.GetDocumentPDFHandler(documentService, document.TypeCreditNote)).Methods("GET")
+	router.HandleFunc("/order/{id}/receipt", v1.GetReceiptHandler(documentService, document.TypeSalesOrder)).Methods("GET")
+	router.HandleFunc("/invoice/{id}/receipt", v1.GetReceiptHandler(documentService, document.TypeSalesInvoice)).Methods("GET")
+}
+
+// RegisterSalesEmailRoutes registers the email endpoints of sales documents
+func RegisterSalesEmailRoutes(router *mux.Router, emailService *email.EmailService) {
+	router.HandleFunc("/invoice/{id}/email", v1.SendDocumentEmailHandler(emailService, document.TypeSalesInvoice)).Methods("POST")
+	router.HandleFunc("/invoice/{id}/emails", v1.GetDocumentEmailsHandler(emailService, document.TypeSalesInvoice)).Methods("GET")
+}
+
+// RegisterPurchaseEmailRoutes registers the email endpoints of purchase documents
+func RegisterPurchaseEmailRoutes(router *mux.Router, emailService *email.EmailService) {
+	router.HandleFunc("/order/{id}/email", v1.SendDocumentEmailHandler(emailService, document.TypePurchaseOrder)).Methods("POST")
+	router.HandleFunc("/order/{id}/emails", v1.GetDocumentEmailsHandler(emailService, document.TypePurchaseOrder)).Methods("GET")
+}
+
+// RegisterPurchaseDocumentRoutes registers the PDF endpoints of purchase documents
+func RegisterPurchaseDocumentRoutes(router *mux.Router, documentService *document.DocumentService) {
+	router.HandleFunc("/order/{id}/pdf", v1.GetDocumentPDFHandler(documentService, document.TypePurchaseOrder)).Methods("GET")
+}
+
+// RegisterAttachmentRoutes registers the attachment endpoints of a document or record under its path
+func RegisterAttachmentRoutes(router *mux.Router, attachmentService *attachment.AttachmentService, path, parentType string) {
+	router.HandleFunc(path+"/{id}/attachments", v1.GetAttachmentsHandler(attachmentService, parentType)).Methods("GET")
+	router.HandleFunc(path+"/{id}/attachments", v1.UploadAttachmentHandler(attachmentService, parentType)).Methods("POST")
+	router.HandleFunc(path+"/{id}/attachments/{attachment_id}", v1.DownloadAttachmentHandler(attachmentService, parentType)).Methods("GET")
+	router.HandleFunc(path+"/{id}/attachments/{attachment_id}", v1.DeleteAttachmentHandler(attachmentService, parentType)).Methods("DELETE")
+}
+
+// RegisterTrashRoutes registers the trash list and restore endpoints of the given resources
+func RegisterTrashRoutes(router *mux.Router, trashService *trash.TrashService, resources ...string) {
+	for _, resource := range resources {
+		router.HandleFunc("/"+resource+"/trash", v1.GetTrashHandler(trashService, resource)).Methods("GET")
+		router.HandleFunc("/"+resource+"/{id}/restore", v1.RestoreTrashHandler(trashService, resource)).Methods("PUT")
+	}
+}
+
+// RegisterTrashPurgeRoutes registers the trash endpoints of every resource, including permanent purge
+func RegisterTrashPurgeRoutes(router *mux.Router, trashService *trash.TrashService) {
+	for _, resource := range trash.Resources {
+		router.HandleFunc("/trash/"+resource, v1.GetTrashHandler(trashService, resource)).Methods("GET")
+		router.HandleFunc("/trash/"+resource+"/{id}/restore", v1.RestoreTrashHandler(trashService, resource)).Methods("PUT")
+		router.HandleFunc("/trash/"+resource+"/{id}", v1.PurgeTrashHandler(trashService, resource)).Methods("DELETE")
+	}
+}
+
 // SetupRoutes registers all API routes
 func SetupRoutes(router *mux.Router, services *Services) {
 	// Auth Routes
@@ -178,6 +332,8 @@ func SetupRoutes(router *mux.Router, services *Services) {
 	HRRoutes.Use(middleware.RoleMiddleware("hr"))
 	RegisterEmployeeRoutes(HRRoutes, services.EmployeeService)
 	RegisterWageRoutes(HRRoutes, services.WageService)
+	RegisterTrashRoutes(HRRoutes, services.TrashService, trash.ResourceEmployee)
+	RegisterAttachmentRoutes(HRRoutes, services.AttachmentService, "/employee", attachment.ParentEmployee)
 
 	// Admin middleware setup
 	AdminRoutes := router.PathPrefix("/admin").Subrouter()
@@ -185,6 +341,13 @@ func SetupRoutes(router *mux.Router, services *Services) {
 	RegisterUserRoutes(AdminRoutes, services.UserService)
 	//RegisterRoleRoutes(AdminRoutes, services.RoleService)
 	RegisterFinanceTransactionRoutes(AdminRoutes, services.FinanceService)
+	RegisterWebhookRoutes(AdminRoutes, services.WebhookService)
+	RegisterNumberingRoutes(AdminRoutes, services.NumberingService)
+	RegisterBranchRoutes(AdminRoutes, services.BranchService)
+	RegisterTrashPurgeRoutes(AdminRoutes, services.TrashService)
+	RegisterImportRoutes(AdminRoutes, services.ImportService)
+	RegisterCompanySettingRoutes(AdminRoutes, services.DocumentService)
+	RegisterAttachmentRoutes(AdminRoutes, services.AttachmentService, "/transaction", attachment.ParentFinanceTransaction)
 
 	// Inventory middleware setup
 	InventoryRoutes := router.PathPrefix("/inventory").Subrouter()
@@ -193,6 +356,7 @@ func SetupRoutes(router *mux.Router, services *Services) {
 	RegisterCategoryRoutes(InventoryRoutes, services.CategoryService)
 	RegisterUnitRoutes(InventoryRoutes, services.UnitService)
 	RegisterInventoryRoutes(InventoryRoutes, services.InventoryService)
+	RegisterTrashRoutes(InventoryRoutes, services.TrashService, trash.ResourceCategory, trash.ResourceUnit, trash.ResourceProduct, trash.ResourceStorage)
 
 	//// Finance middleware setup
 	//FinanceRoutes := router.PathPrefix("/finance").Subrouter()
@@ -204,12 +368,37 @@ func SetupRoutes(router *mux.Router, services *Services) {
 	RegisterProductRoutes(SalesRoutes, services.ProductService)
 	RegisterCustomerRoutes(SalesRoutes, services.CustomerService)
 	RegisterSalesRoutes(SalesRoutes, services.SalesService)
+	RegisterQuotationRoutes(SalesRoutes, services.QuotationService)
+	RegisterPaymentRoutes(SalesRoutes, services.PaymentService)
+	RegisterCreditNoteRoutes(SalesRoutes, services.CreditNoteService)
+	RegisterPriceListRoutes(SalesRoutes, services.PriceListService)
+	RegisterTrashRoutes(SalesRoutes, services.TrashService, trash.ResourceCustomer)
+	RegisterSalesDocumentRoutes(SalesRoutes, services.DocumentService)
+	RegisterSalesEmailRoutes(SalesRoutes, services.EmailService)
+	RegisterAttachmentRoutes(SalesRoutes, services.AttachmentService, "/order", attachment.ParentSalesOrder)
+	RegisterAttachmentRoutes(SalesRoutes, services.AttachmentService, "/invoice", attachment.ParentSalesInvoice)
+	RegisterAttachmentRoutes(SalesRoutes, services.AttachmentService, "/delivery-note", attachment.ParentDeliveryNote)
+	RegisterAttachmentRoutes(SalesRoutes, services.AttachmentService, "/return", attachment.ParentSalesReturn)
 
 	// Purchase middleware setup
 	PurchaseRoutes := router.PathPrefix("/purchase").Subrouter()
 	PurchaseRoutes.Use(middleware.RoleMiddleware("purchase"))
 	RegisterSupplierRoutes(PurchaseRoutes, services.SupplierService)
 	RegisterPurchaseOrderRoutes(PurchaseRoutes, services.PurchaseOrderService, services.ProductService, services.InventoryService)
+	RegisterTrashRoutes(PurchaseRoutes, services.TrashService, trash.ResourceSupplier)
+	RegisterPurchaseDocumentRoutes(PurchaseRoutes, services.DocumentService)
+	RegisterPurchaseEmailRoutes(PurchaseRoutes, services.EmailService)
+	RegisterAttachmentRoutes(PurchaseRoutes, services.AttachmentService, "/order", attachment.ParentPurchaseOrder)
+
+	// Global search, open to every role and filtered per result type
+	SearchRoutes := router.PathPrefix("/search").Subrouter()
+	SearchRoutes.Use(middleware.RoleMiddleware("hr", "finance", "inventory", "sales", "purchase"))
+	RegisterSearchRoutes(SearchRoutes, services.SearchService)
+
+	// Live event stream, open to every role and filtered per topic
+	StreamRoutes := router.PathPrefix("/stream").Subrouter()
+	StreamRoutes.Use(middleware.RoleMiddleware("finance", "inventory", "sales", "purchase"))
+	RegisterStreamRoutes(StreamRoutes, services.StreamService)
 
 	//// Purchase middleware setup
 	//PurchaseRoutes := router.PathPrefix("/purchase").Subrouter()
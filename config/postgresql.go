@@ -3,19 +3,25 @@ package config
 import (
 	"database/sql"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"log"
 	"os"
+	"time"
 )
 
-// StartPostgres is a function that starts a connection to a Postgres database
-func StartPostgres() *sql.DB {
-	conn := fmt.Sprintf(
+// postgresConn builds the connection string from the environment
+func postgresConn() string {
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		os.Getenv("POSTGRES_HOST"), os.Getenv("POSTGRES_PORT"),
 		os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_PASSWORD"),
 		os.Getenv("POSTGRES_DB"),
 	)
+}
+
+// StartPostgres is a function that starts a connection to a Postgres database
+func StartPostgres() *sql.DB {
+	conn := postgresConn()
 
 	fmt.Println("Connecting to Postgres with: ", conn)
 
@@ -29,3 +35,12 @@ func StartPostgres() *sql.DB {
 	}
 	return db
 }
+
+// NewPostgresListener opens a dedicated connection for LISTEN/NOTIFY, reconnecting on its own when it drops
+func NewPostgresListener() *pq.Listener {
+	return pq.NewListener(postgresConn(), 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Postgres listener: %v", err)
+		}
+	})
+}
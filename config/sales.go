@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// defaultReservationTTL is how long an open sales order holds its stock when STOCK_RESERVATION_TTL is not set
+const defaultReservationTTL = 72 * time.Hour
+
+// ReservationTTL reads how long open sales orders reserve stock from STOCK_RESERVATION_TTL, e.g. "48h"
+func ReservationTTL() time.Duration {
+	value := os.Getenv("STOCK_RESERVATION_TTL")
+	if value == "" {
+		return defaultReservationTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Fatalf("Invalid STOCK_RESERVATION_TTL %q, use a positive duration such as 72h", value)
+	}
+	fmt.Println("Reserving stock of open sales orders for: ", ttl)
+	return ttl
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sinartimur-go/pkg/mailer"
+)
+
+// NewMailer creates the SMTP mailer, it returns nil when SMTP_HOST is not set so sending is disabled
+func NewMailer() mailer.Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		fmt.Println("SMTP_HOST is not set, sending emails is disabled")
+		return nil
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "noreply@" + host
+	}
+
+	fmt.Println("Sending emails through SMTP at: ", host+":"+port)
+	return &mailer.SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sinartimur-go/pkg/storage"
+)
+
+// NewStorage creates the file storage selected by STORAGE_DRIVER, only "local" is supported for now
+func NewStorage() storage.Storage {
+	driver := os.Getenv("STORAGE_DRIVER")
+	if driver == "" {
+		driver = "local"
+	}
+
+	switch driver {
+	case "local":
+		root := os.Getenv("STORAGE_LOCAL_PATH")
+		if root == "" {
+			root = "./storage"
+		}
+		fmt.Println("Storing files in: ", root)
+		local, err := storage.NewLocalStorage(root)
+		if err != nil {
+			log.Fatalf("Failed to open storage: %v", err)
+		}
+		return local
+	default:
+		log.Fatalf("Unknown STORAGE_DRIVER %q", driver)
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package attachment
+
+// Documents and records files can be attached to
+const (
+	ParentSalesOrder         = "sales_order"
+	ParentSalesInvoice       = "sales_invoice"
+	ParentDeliveryNote       = "delivery_note"
+	ParentSalesReturn        = "sales_return"
+	ParentPurchaseOrder      = "purchase_order"
+	ParentFinanceTransaction = "finance_transaction"
+	ParentEmployee           = "employee"
+)
+
+// MaxFileSize is the largest accepted attachment
+const MaxFileSize = 10 << 20
+
+// allowedContentTypes are the sniffed types accepted as attachments, scans and photos
+var allowedContentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"image/heic":      true,
+}
+
+// Attachment is a file attached to a document or record
+type Attachment struct {
+	ID             string  `json:"id"`
+	ParentType     string  `json:"parent_type"`
+	ParentID       string  `json:"parent_id"`
+	FileName       string  `json:"file_name"`
+	ContentType    string  `json:"content_type"`
+	Size           int64   `json:"size"`
+	Description    *string `json:"description,omitempty"`
+	StorageKey     string  `json:"-"`
+	UploadedBy     *string `json:"uploaded_by,omitempty"`
+	UploadedByName *string `json:"uploaded_by_name,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+// UploadAttachmentRequest holds an uploaded file
+type UploadAttachmentRequest struct {
+	ParentType  string `json:"-"`
+	ParentID    string `json:"-"`
+	FileName    string `json:"file_name" validate:"required,max=255"`
+	Description string `json:"description" validate:"omitempty,max=255"`
+	Content     []byte `json:"-"`
+	BranchID    string `json:"-"`
+	UserID      string `json:"-"`
+}
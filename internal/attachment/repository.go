@@ -0,0 +1,154 @@
+package attachment
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AttachmentRepository defines the interface for attachment data operations
+type AttachmentRepository interface {
+	GetParentBranch(parentType, parentID string) (*string, error)
+	Create(attachment Attachment) (*Attachment, error)
+	GetAll(parentType, parentID string) ([]Attachment, error)
+	GetByID(parentType, parentID, id string) (*Attachment, error)
+	Delete(parentType, parentID, id string) (*Attachment, error)
+}
+
+// AttachmentRepositoryImpl implements the AttachmentRepository interface
+type AttachmentRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewAttachmentRepository creates a new attachment repository instance
+func NewAttachmentRepository(db *sql.DB) AttachmentRepository {
+	return &AttachmentRepositoryImpl{db: db}
+}
+
+// parentQueries select the branch of a parent per parent type, a null branch is visible to every branch
+var parentQueries = map[string]string{
+	ParentSalesOrder: "Select Branch_Id From Sales_Order Where Id = $1",
+	ParentSalesInvoice: `
+		Select So.Branch_Id From Sales_Invoice Si
+		Join Sales_Order So On So.Id = Si.Sales_Order_Id
+		Where Si.Id = $1`,
+	ParentDeliveryNote: `
+		Select So.Branch_Id From Delivery_Note Dn
+		Join Sales_Order So On So.Id = Dn.Sales_Order_Id
+		Where Dn.Id = $1`,
+	ParentSalesReturn: `
+		Select So.Branch_Id From Sales_Order_Return Sor
+		Join Sales_Order So On So.Id = Sor.Sales_Order_Id
+		Where Sor.Id = $1`,
+	ParentPurchaseOrder:      "Select Branch_Id From Purchase_Order Where Id = $1",
+	ParentFinanceTransaction: "Select Branch_Id From Financial_Transaction_Log Where Id = $1",
+	ParentEmployee:           "Select Null::uuid From Employee Where Id = $1 And Deleted_At Is Null",
+}
+
+// GetParentBranch fetches the branch of a parent, sql.ErrNoRows means the parent does not exist
+func (r *AttachmentRepositoryImpl) GetParentBranch(parentType, parentID string) (*string, error) {
+	query, ok := parentQueries[parentType]
+	if !ok {
+		return nil, fmt.Errorf("jenis dokumen tidak dikenal: %s", parentType)
+	}
+
+	var branchID *string
+	if err := r.db.QueryRow(query, parentID).Scan(&branchID); err != nil {
+		return nil, err
+	}
+	return branchID, nil
+}
+
+const attachmentColumns = `A.Id, A.Parent_Type, A.Parent_Id, A.File_Name, A.Content_Type, A.Size, A.Description,
+	A.Storage_Key, A.Uploaded_By, Au.Username, A.Created_At`
+
+// scanAttachment scans a row selected with attachmentColumns
+func scanAttachment(row interface{ Scan(...interface{}) error }) (*Attachment, error) {
+	var a Attachment
+	var createdAt time.Time
+	err := row.Scan(&a.ID, &a.ParentType, &a.ParentID, &a.FileName, &a.ContentType, &a.Size, &a.Description,
+		&a.StorageKey, &a.UploadedBy, &a.UploadedByName, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	a.CreatedAt = createdAt.Format(time.RFC3339)
+	return &a, nil
+}
+
+// Create records an attachment whose file is already stored
+func (r *AttachmentRepositoryImpl) Create(attachment Attachment) (*Attachment, error) {
+	var description, uploadedBy string
+	if attachment.Description != nil {
+		description = *attachment.Description
+	}
+	if attachment.UploadedBy != nil {
+		uploadedBy = *attachment.UploadedBy
+	}
+
+	row := r.db.QueryRow(`
+		With Inserted As (
+			Insert Into Attachment (Id, Parent_Type, Parent_Id, File_Name, Content_Type, Size, Description, Storage_Key, Uploaded_By)
+			Values ($1, $2, $3, $4, $5, $6, Nullif($7, ''), $8, Nullif($9, '')::uuid)
+			Returning *
+		)
+		Select `+attachmentColumns+`
+		From Inserted A
+		Left Join Appuser Au On Au.Id = A.Uploaded_By`,
+		attachment.ID, attachment.ParentType, attachment.ParentID, attachment.FileName, attachment.ContentType,
+		attachment.Size, description, attachment.StorageKey, uploadedBy)
+
+	created, err := scanAttachment(row)
+	if err != nil {
+		return nil, fmt.Errorf("gagal menyimpan lampiran: %w", err)
+	}
+	return created, nil
+}
+
+// GetAll fetches the attachments of a parent, newest first
+func (r *AttachmentRepositoryImpl) GetAll(parentType, parentID string) ([]Attachment, error) {
+	rows, err := r.db.Query(`
+		Select `+attachmentColumns+`
+		From Attachment A
+		Left Join Appuser Au On Au.Id = A.Uploaded_By
+		Where A.Parent_Type = $1 And A.Parent_Id = $2
+		Order By A.Created_At Desc`,
+		parentType, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil lampiran: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		a, errScan := scanAttachment(rows)
+		if errScan != nil {
+			return nil, fmt.Errorf("gagal membaca lampiran: %w", errScan)
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetByID fetches an attachment of a parent
+func (r *AttachmentRepositoryImpl) GetByID(parentType, parentID, id string) (*Attachment, error) {
+	return scanAttachment(r.db.QueryRow(`
+		Select `+attachmentColumns+`
+		From Attachment A
+		Left Join Appuser Au On Au.Id = A.Uploaded_By
+		Where A.Id = $1 And A.Parent_Type = $2 And A.Parent_Id = $3`,
+		id, parentType, parentID))
+}
+
+// Delete removes an attachment record and returns it so its file can be removed
+func (r *AttachmentRepositoryImpl) Delete(parentType, parentID, id string) (*Attachment, error) {
+	return scanAttachment(r.db.QueryRow(`
+		With Deleted As (
+			Delete From Attachment
+			Where Id = $1 And Parent_Type = $2 And Parent_Id = $3
+			Returning *
+		)
+		Select `+attachmentColumns+`
+		From Deleted A
+		Left Join Appuser Au On Au.Id = A.Uploaded_By`,
+		id, parentType, parentID))
+}
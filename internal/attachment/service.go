@@ -0,0 +1,223 @@
+package attachment
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/pkg/storage"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/google/uuid"
+)
+
+// parentNames holds the not found message per parent type
+var parentNames = map[string]string{
+	ParentSalesOrder:         "Pesanan penjualan tidak ditemukan",
+	ParentSalesInvoice:       "Faktur tidak ditemukan",
+	ParentDeliveryNote:       "Surat jalan tidak ditemukan",
+	ParentSalesReturn:        "Retur penjualan tidak ditemukan",
+	ParentPurchaseOrder:      "Pesanan pembelian tidak ditemukan",
+	ParentFinanceTransaction: "Transaksi keuangan tidak ditemukan",
+	ParentEmployee:           "Karyawan tidak ditemukan",
+}
+
+// AttachmentService is the service for files attached to documents and records
+type AttachmentService struct {
+	repo    AttachmentRepository
+	storage storage.Storage
+}
+
+// NewAttachmentService creates a new instance of AttachmentService
+func NewAttachmentService(repo AttachmentRepository, files storage.Storage) *AttachmentService {
+	return &AttachmentService{repo: repo, storage: files}
+}
+
+// checkParent verifies a parent exists, hiding parents of other branches from branch users
+func (s *AttachmentService) checkParent(parentType, parentID, branchID string) *dto.APIError {
+	message, ok := parentNames[parentType]
+	if !ok {
+		return dto.NewAPIError(http.StatusNotFound, map[string]string{
+			"general": "Jenis dokumen tidak dikenal",
+		})
+	}
+	notFound := dto.NewAPIError(http.StatusNotFound, map[string]string{"general": message})
+
+	parentBranch, err := s.repo.GetParentBranch(parentType, parentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return notFound
+		}
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data dokumen",
+		})
+	}
+	if branchID != "" && parentBranch != nil && *parentBranch != branchID {
+		return notFound
+	}
+	return nil
+}
+
+// cleanFileName keeps the base name of an uploaded file without control characters
+func cleanFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// Upload stores a file and attaches it to its parent, the type is sniffed from the content
+func (s *AttachmentService) Upload(req UploadAttachmentRequest) (*Attachment, *dto.APIError) {
+	if apiErr := s.checkParent(req.ParentType, req.ParentID, req.BranchID); apiErr != nil {
+		return nil, apiErr
+	}
+
+	if len(req.Content) == 0 {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"file": "File kosong",
+		})
+	}
+	if len(req.Content) > MaxFileSize {
+		return nil, dto.NewAPIError(http.StatusRequestEntityTooLarge, map[string]string{
+			"file": "Ukuran file melebihi 10 MB",
+		})
+	}
+
+	// The declared type and extension are not trusted
+	detected := mimetype.Detect(req.Content)
+	contentType := ""
+	for allowed := range allowedContentTypes {
+		if detected.Is(allowed) {
+			contentType = allowed
+			break
+		}
+	}
+	if contentType == "" {
+		return nil, dto.NewAPIError(http.StatusUnsupportedMediaType, map[string]string{
+			"file": "Jenis file tidak didukung, gunakan PDF, JPEG, PNG, WEBP atau HEIC",
+		})
+	}
+
+	fileName := cleanFileName(req.FileName)
+	if fileName == "" {
+		fileName = "lampiran" + detected.Extension()
+	}
+
+	id := uuid.New().String()
+	attachment := Attachment{
+		ID:          id,
+		ParentType:  req.ParentType,
+		ParentID:    req.ParentID,
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        int64(len(req.Content)),
+		StorageKey:  req.ParentType + "/" + req.ParentID + "/" + id + detected.Extension(),
+	}
+	if req.Description != "" {
+		attachment.Description = &req.Description
+	}
+	if req.UserID != "" {
+		attachment.UploadedBy = &req.UserID
+	}
+
+	if err := s.storage.Put(attachment.StorageKey, bytes.NewReader(req.Content)); err != nil {
+		log.Printf("attachment: failed to store %s: %v", attachment.StorageKey, err)
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menyimpan file",
+		})
+	}
+
+	created, err := s.repo.Create(attachment)
+	if err != nil {
+		// Do not leave an orphaned file behind
+		if errDelete := s.storage.Delete(attachment.StorageKey); errDelete != nil {
+			log.Printf("attachment: failed to remove %s: %v", attachment.StorageKey, errDelete)
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menyimpan lampiran",
+		})
+	}
+	return created, nil
+}
+
+// GetAll fetches the attachments of a parent
+func (s *AttachmentService) GetAll(parentType, parentID, branchID string) ([]Attachment, *dto.APIError) {
+	if apiErr := s.checkParent(parentType, parentID, branchID); apiErr != nil {
+		return nil, apiErr
+	}
+
+	attachments, err := s.repo.GetAll(parentType, parentID)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil lampiran",
+		})
+	}
+	return attachments, nil
+}
+
+// Open fetches an attachment together with its file, the caller closes the file
+func (s *AttachmentService) Open(parentType, parentID, id, branchID string) (*Attachment, io.ReadCloser, *dto.APIError) {
+	if apiErr := s.checkParent(parentType, parentID, branchID); apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	notFound := dto.NewAPIError(http.StatusNotFound, map[string]string{"general": "Lampiran tidak ditemukan"})
+	attachment, err := s.repo.GetByID(parentType, parentID, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, notFound
+		}
+		return nil, nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil lampiran",
+		})
+	}
+
+	file, err := s.storage.Open(attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("attachment: file %s of %s is missing", attachment.StorageKey, attachment.ID)
+			return nil, nil, notFound
+		}
+		return nil, nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membaca file",
+		})
+	}
+	return attachment, file, nil
+}
+
+// Delete removes an attachment and its file
+func (s *AttachmentService) Delete(parentType, parentID, id, branchID string) *dto.APIError {
+	if apiErr := s.checkParent(parentType, parentID, branchID); apiErr != nil {
+		return apiErr
+	}
+
+	attachment, err := s.repo.Delete(parentType, parentID, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Lampiran tidak ditemukan",
+			})
+		}
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menghapus lampiran",
+		})
+	}
+
+	// The record is gone, a file left behind is only logged
+	if err = s.storage.Delete(attachment.StorageKey); err != nil {
+		log.Printf("attachment: failed to remove %s: %v", attachment.StorageKey, err)
+	}
+	return nil
+}
@@ -23,7 +23,16 @@ type LoginUserRequest struct {
 }
 
 type LoginUserResponse struct {
-	Id       string    `json:"id"`
-	Username string    `json:"username"`
-	Roles    []*string `json:"roles"`
+	Id       string       `json:"id"`
+	Username string       `json:"username"`
+	Roles    []*string    `json:"roles"`
+	Branches []UserBranch `json:"branches"`
+}
+
+// UserBranch is a branch the user is assigned to
+type UserBranch struct {
+	ID        string `json:"id"`
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	IsDefault bool   `json:"is_default"`
 }
@@ -4,6 +4,7 @@ import "database/sql"
 
 type AuthRepository interface {
 	GetByUsername(username string) (*User, error)
+	GetUserBranches(userID string) ([]UserBranch, error)
 	//GetRolesByID(userID string) ([]string, error)
 }
 
@@ -26,6 +27,31 @@ func (r *authRepositoryImpl) GetByUsername(username string) (*User, error) {
 	return user, nil
 }
 
+// GetUserBranches fetches the active branches a user is assigned to, default branch first
+func (r *authRepositoryImpl) GetUserBranches(userID string) ([]UserBranch, error) {
+	rows, err := r.db.Query(`
+		Select B.Id, B.Code, B.Name, Ub.Is_Default
+		From User_Branch Ub
+		Join Branch B On Ub.Branch_Id = B.Id
+		Where Ub.User_Id = $1 And B.Deleted_At Is Null
+		Order By Ub.Is_Default Desc, B.Code
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	branches := []UserBranch{}
+	for rows.Next() {
+		var branch UserBranch
+		if err := rows.Scan(&branch.ID, &branch.Code, &branch.Name, &branch.IsDefault); err != nil {
+			return nil, err
+		}
+		branches = append(branches, branch)
+	}
+	return branches, rows.Err()
+}
+
 //// GetRolesByID fetches role by user ID
 //func (r *authRepositoryImpl) GetRolesByID(userID string) ([]string, error) {
 //	var roles []string
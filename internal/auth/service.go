@@ -22,8 +22,21 @@ func NewAuthService(repo AuthRepository, redisClient *config.RedisClient) *AuthS
 	return &AuthService{repo: repo, redisClient: redisClient}
 }
 
+// branchClaims splits the user's branches into token claims
+func branchClaims(branches []UserBranch) ([]string, string) {
+	branchIDs := make([]string, 0, len(branches))
+	defaultBranch := ""
+	for _, branch := range branches {
+		branchIDs = append(branchIDs, branch.ID)
+		if branch.IsDefault {
+			defaultBranch = branch.ID
+		}
+	}
+	return branchIDs, defaultBranch
+}
+
 // LoginUser logs in a user
-func (s *AuthService) LoginUser(username, password string) (string, string, string, *dto.APIError, []*string) {
+func (s *AuthService) LoginUser(username, password string) (string, string, string, *dto.APIError, []*string, []UserBranch) {
 	// Fetch user from database
 	user, err := s.repo.GetByUsername(username)
 	if err != nil {
@@ -32,7 +45,7 @@ func (s *AuthService) LoginUser(username, password string) (string, string, stri
 			Details: map[string]string{
 				"general": "User tidak ditemukan",
 			},
-		}, nil
+		}, nil, nil
 	}
 
 	// Verify password
@@ -42,7 +55,7 @@ func (s *AuthService) LoginUser(username, password string) (string, string, stri
 			Details: map[string]string{
 				"general": "Username atau password salah",
 			},
-		}, nil
+		}, nil, nil
 	}
 
 	// Write use role if Is_{Role} is true
@@ -80,15 +93,27 @@ func (s *AuthService) LoginUser(username, password string) (string, string, stri
 		roles = nil
 	}
 
+	// Get the branches the user works in
+	branches, err := s.repo.GetUserBranches(user.ID.String())
+	if err != nil {
+		return "", "", "", &dto.APIError{
+			StatusCode: http.StatusInternalServerError,
+			Details: map[string]string{
+				"general": "Gagal login. Silahkan coba lagi",
+			},
+		}, nil, nil
+	}
+	branchIDs, defaultBranch := branchClaims(branches)
+
 	// Generate tokens
-	accessToken, err := utils.GenerateAccessToken(user.ID.String(), roles)
+	accessToken, err := utils.GenerateAccessToken(user.ID.String(), roles, branchIDs, defaultBranch)
 	if err != nil {
 		return "", "", "", &dto.APIError{
 			StatusCode: http.StatusInternalServerError,
 			Details: map[string]string{
 				"general": "Gagal login. Silahkan coba lagi",
 			},
-		}, nil
+		}, nil, nil
 	}
 
 	refreshToken, err := utils.GenerateRefreshToken(user.ID.String(), roles)
@@ -98,7 +123,7 @@ func (s *AuthService) LoginUser(username, password string) (string, string, stri
 			Details: map[string]string{
 				"general": "Gagal login. Silahkan coba lagi",
 			},
-		}, nil
+		}, nil, nil
 	}
 
 	// Store refresh token in Redis
@@ -109,9 +134,9 @@ func (s *AuthService) LoginUser(username, password string) (string, string, stri
 			Details: map[string]string{
 				"general": "Gagal login. Silahkan coba lagi",
 			},
-		}, nil
+		}, nil, nil
 	}
-	return accessToken, refreshToken, user.ID.String(), nil, roles
+	return accessToken, refreshToken, user.ID.String(), nil, roles, branches
 }
 
 // RefreshAuth refreshes the access tokenÏ
@@ -165,8 +190,20 @@ func (s *AuthService) RefreshAuth(refreshToken string) (string, *dto.APIError) {
 			},
 		}
 	}
+	// Reload branches so reassignments apply on the next refresh
+	branches, err := s.repo.GetUserBranches(userID)
+	if err != nil {
+		return "", &dto.APIError{
+			StatusCode: http.StatusInternalServerError,
+			Details: map[string]string{
+				"general": "Gagal refresh token",
+			},
+		}
+	}
+	branchIDs, defaultBranch := branchClaims(branches)
+
 	// Generate new access token
-	accessToken, err := utils.GenerateAccessToken(userID, roles)
+	accessToken, err := utils.GenerateAccessToken(userID, roles, branchIDs, defaultBranch)
 	if err != nil {
 		return "", &dto.APIError{
 			StatusCode: http.StatusInternalServerError,
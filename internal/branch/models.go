@@ -0,0 +1,81 @@
+package branch
+
+import (
+	"sinartimur-go/utils"
+)
+
+// Branch represents an outlet or warehouse site running its own transactions
+type Branch struct {
+	ID        string  `json:"id"`
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	Address   *string `json:"address"`
+	Telephone *string `json:"telephone"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+// GetBranchesRequest holds query parameters for listing branches
+type GetBranchesRequest struct {
+	Search string `json:"search" validate:"omitempty,max=255"`
+	utils.PaginationParameter
+}
+
+// CreateBranchRequest holds data needed to create a branch
+type CreateBranchRequest struct {
+	Code      string `json:"code" validate:"required,alphanum,max=10"`
+	Name      string `json:"name" validate:"required,max=100"`
+	Address   string `json:"address" validate:"omitempty"`
+	Telephone string `json:"telephone" validate:"omitempty,max=20"`
+}
+
+// UpdateBranchRequest holds data needed to update a branch
+type UpdateBranchRequest struct {
+	ID        string `json:"id" validate:"required,uuid"`
+	Code      string `json:"code" validate:"required,alphanum,max=10"`
+	Name      string `json:"name" validate:"required,max=100"`
+	Address   string `json:"address" validate:"omitempty"`
+	Telephone string `json:"telephone" validate:"omitempty,max=20"`
+}
+
+// UserBranch is a branch a user is assigned to
+type UserBranch struct {
+	BranchID  string `json:"branch_id"`
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// AssignUserBranchesRequest replaces the branches a user is assigned to
+type AssignUserBranchesRequest struct {
+	UserID          string   `json:"-" validate:"required,uuid"`
+	BranchIDs       []string `json:"branch_ids" validate:"required,min=1,dive,uuid"`
+	DefaultBranchID string   `json:"default_branch_id" validate:"required,uuid"`
+}
+
+// GetBranchSummaryRequest holds the period of the consolidated branch report
+type GetBranchSummaryRequest struct {
+	StartDate string `json:"start_date" validate:"omitempty,rfc3339"`
+	EndDate   string `json:"end_date" validate:"omitempty,rfc3339"`
+}
+
+// BranchSummary holds the totals of a single branch for the consolidated report
+type BranchSummary struct {
+	BranchID      string  `json:"branch_id"`
+	Code          string  `json:"code"`
+	Name          string  `json:"name"`
+	SalesCount    int     `json:"sales_count"`
+	SalesTotal    float64 `json:"sales_total"`
+	PurchaseCount int     `json:"purchase_count"`
+	PurchaseTotal float64 `json:"purchase_total"`
+	TotalIncome   float64 `json:"total_income"`
+	TotalExpense  float64 `json:"total_expense"`
+	NetAmount     float64 `json:"net_amount"`
+	StockValue    float64 `json:"stock_value"`
+}
+
+// BranchSummaryResponse is the consolidated cross-branch report
+type BranchSummaryResponse struct {
+	Branches []BranchSummary `json:"branches"`
+	Total    BranchSummary   `json:"total"`
+}
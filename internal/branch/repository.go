@@ -0,0 +1,254 @@
+package branch
+
+import (
+	"database/sql"
+	"fmt"
+	"sinartimur-go/utils"
+	"time"
+)
+
+// BranchRepository defines the interface for branch operations
+type BranchRepository interface {
+	GetAll(req GetBranchesRequest) ([]Branch, int, error)
+	GetByID(id string) (*Branch, error)
+	GetByCode(code string) (*Branch, error)
+	Create(req CreateBranchRequest) (*Branch, error)
+	Update(req UpdateBranchRequest) (*Branch, error)
+	Delete(id string) error
+	IsInUse(id string) (bool, error)
+	GetUserBranches(userID string) ([]UserBranch, error)
+	AssignUserBranches(req AssignUserBranchesRequest) error
+	GetSummary(startDate, endDate *time.Time) ([]BranchSummary, error)
+}
+
+// BranchRepositoryImpl implements the BranchRepository interface
+type BranchRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewBranchRepository creates a new branch repository instance
+func NewBranchRepository(db *sql.DB) BranchRepository {
+	return &BranchRepositoryImpl{db: db}
+}
+
+const branchColumns = "Id, Code, Name, Address, Telephone, Created_At, Updated_At"
+
+// branchSortColumns lists the columns branches can be sorted by
+var branchSortColumns = map[string]string{
+	"code":       "Code",
+	"name":       "Name",
+	"created_at": "Created_At",
+}
+
+// scanBranch scans a branch row selected with branchColumns
+func scanBranch(row interface{ Scan(...interface{}) error }) (*Branch, error) {
+	var branch Branch
+	if err := row.Scan(&branch.ID, &branch.Code, &branch.Name, &branch.Address, &branch.Telephone,
+		&branch.CreatedAt, &branch.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &branch, nil
+}
+
+// GetAll fetches branches with pagination
+func (r *BranchRepositoryImpl) GetAll(req GetBranchesRequest) ([]Branch, int, error) {
+	qb := utils.NewQueryBuilder("Select " + branchColumns + " From Branch Where Deleted_At Is Null")
+	qb.AddSearch(req.Search, "Code", "Name", "Address")
+
+	var totalItems int
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Branches", qb.Query.String())
+	if err := r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems); err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung cabang: %w", err)
+	}
+
+	if column, ok := branchSortColumns[req.SortBy]; ok && req.SortOrder != "" {
+		qb.Query.WriteString(fmt.Sprintf(" Order By %s %s", column, req.SortOrder))
+	} else {
+		qb.Query.WriteString(" Order By Code Asc")
+	}
+	qb.AddPagination(req.PaginationParameter)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil cabang: %w", err)
+	}
+	defer rows.Close()
+
+	branches := []Branch{}
+	for rows.Next() {
+		branch, errScan := scanBranch(rows)
+		if errScan != nil {
+			return nil, 0, fmt.Errorf("gagal membaca cabang: %w", errScan)
+		}
+		branches = append(branches, *branch)
+	}
+
+	return branches, totalItems, rows.Err()
+}
+
+// GetByID fetches a branch by ID
+func (r *BranchRepositoryImpl) GetByID(id string) (*Branch, error) {
+	return scanBranch(r.db.QueryRow("Select "+branchColumns+" From Branch Where Id = $1 And Deleted_At Is Null", id))
+}
+
+// GetByCode fetches a branch by code
+func (r *BranchRepositoryImpl) GetByCode(code string) (*Branch, error) {
+	return scanBranch(r.db.QueryRow("Select "+branchColumns+" From Branch Where Upper(Code) = Upper($1) And Deleted_At Is Null", code))
+}
+
+// Create creates a new branch
+func (r *BranchRepositoryImpl) Create(req CreateBranchRequest) (*Branch, error) {
+	return scanBranch(r.db.QueryRow(`
+		Insert Into Branch (Code, Name, Address, Telephone)
+		Values (Upper($1), $2, Nullif($3, ''), Nullif($4, ''))
+		Returning `+branchColumns,
+		req.Code, req.Name, req.Address, req.Telephone))
+}
+
+// Update updates an existing branch
+func (r *BranchRepositoryImpl) Update(req UpdateBranchRequest) (*Branch, error) {
+	return scanBranch(r.db.QueryRow(`
+		Update Branch
+		Set Code = Upper($1), Name = $2, Address = Nullif($3, ''), Telephone = Nullif($4, ''), Updated_At = Now()
+		Where Id = $5 And Deleted_At Is Null
+		Returning `+branchColumns,
+		req.Code, req.Name, req.Address, req.Telephone, req.ID))
+}
+
+// Delete soft deletes a branch and removes its user assignments
+func (r *BranchRepositoryImpl) Delete(id string) error {
+	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("Update Branch Set Deleted_At = Now() Where Id = $1", id); err != nil {
+			return err
+		}
+		_, err := tx.Exec("Delete From User_Branch Where Branch_Id = $1", id)
+		return err
+	})
+}
+
+// IsInUse checks whether a branch still has storages or open orders
+func (r *BranchRepositoryImpl) IsInUse(id string) (bool, error) {
+	var inUse bool
+	err := r.db.QueryRow(`
+		Select Exists(Select 1 From Storage Where Branch_Id = $1 And Deleted_At Is Null)
+			Or Exists(Select 1 From Sales_Order Where Branch_Id = $1 And Status = 'order')
+			Or Exists(Select 1 From Purchase_Order Where Branch_Id = $1 And Status = 'order')
+	`, id).Scan(&inUse)
+	return inUse, err
+}
+
+// GetUserBranches fetches the branches a user is assigned to, default branch first
+func (r *BranchRepositoryImpl) GetUserBranches(userID string) ([]UserBranch, error) {
+	rows, err := r.db.Query(`
+		Select B.Id, B.Code, B.Name, Ub.Is_Default
+		From User_Branch Ub
+		Join Branch B On Ub.Branch_Id = B.Id
+		Where Ub.User_Id = $1 And B.Deleted_At Is Null
+		Order By Ub.Is_Default Desc, B.Code
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	branches := []UserBranch{}
+	for rows.Next() {
+		var branch UserBranch
+		if err := rows.Scan(&branch.BranchID, &branch.Code, &branch.Name, &branch.IsDefault); err != nil {
+			return nil, err
+		}
+		branches = append(branches, branch)
+	}
+	return branches, rows.Err()
+}
+
+// AssignUserBranches replaces the branches a user is assigned to
+func (r *BranchRepositoryImpl) AssignUserBranches(req AssignUserBranchesRequest) error {
+	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("Delete From User_Branch Where User_Id = $1", req.UserID); err != nil {
+			return err
+		}
+
+		for _, branchID := range req.BranchIDs {
+			if _, err := tx.Exec(`
+				Insert Into User_Branch (User_Id, Branch_Id, Is_Default)
+				Values ($1, $2, $3)
+				On Conflict (User_Id, Branch_Id) Do Nothing
+			`, req.UserID, branchID, branchID == req.DefaultBranchID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSummary aggregates sales, purchases, finance and stock value per branch
+func (r *BranchRepositoryImpl) GetSummary(startDate, endDate *time.Time) ([]BranchSummary, error) {
+	rows, err := r.db.Query(`
+		Select
+			B.Id, B.Code, B.Name,
+			Coalesce(S.Sales_Count, 0), Coalesce(S.Sales_Total, 0),
+			Coalesce(P.Purchase_Count, 0), Coalesce(P.Purchase_Total, 0),
+			Coalesce(F.Total_Income, 0), Coalesce(F.Total_Expense, 0),
+			Coalesce(St.Stock_Value, 0)
+		From Branch B
+		Left Join (
+			Select Branch_Id, Count(*) As Sales_Count, Sum(Total_Amount) As Sales_Total
+			From Sales_Order
+			Where Cancelled_At Is Null
+				And ($1::Timestamptz Is Null Or Order_Date >= $1)
+				And ($2::Timestamptz Is Null Or Order_Date <= $2)
+			Group By Branch_Id
+		) S On S.Branch_Id = B.Id
+		Left Join (
+			Select Branch_Id, Count(*) As Purchase_Count, Sum(Total_Amount) As Purchase_Total
+			From Purchase_Order
+			Where Status <> 'cancelled'
+				And ($1::Timestamptz Is Null Or Order_Date >= $1)
+				And ($2::Timestamptz Is Null Or Order_Date <= $2)
+			Group By Branch_Id
+		) P On P.Branch_Id = B.Id
+		Left Join (
+			Select Branch_Id,
+				-- Customer payments settle sales already booked as income when invoiced
+				Sum(Case When Type = 'debit' And Customer_Payment_Id Is Null Then Amount Else 0 End) As Total_Income,
+				-- Credit note refunds pay back returns already booked as expense when returned
+				Sum(Case When Type = 'credit' And Credit_Note_Id Is Null Then Amount Else 0 End) As Total_Expense
+			From Financial_Transaction_Log
+			Where Deleted_At Is Null
+				And ($1::Timestamptz Is Null Or Transaction_Date >= $1)
+				And ($2::Timestamptz Is Null Or Transaction_Date <= $2)
+			Group By Branch_Id
+		) F On F.Branch_Id = B.Id
+		Left Join (
+			Select S.Branch_Id, Sum(Bs.Quantity * Pb.Unit_Price) As Stock_Value
+			From Batch_Storage Bs
+			Join Storage S On Bs.Storage_Id = S.Id
+			Join Product_Batch Pb On Bs.Batch_Id = Pb.Id
+			Where S.Deleted_At Is Null
+			Group By S.Branch_Id
+		) St On St.Branch_Id = B.Id
+		Where B.Deleted_At Is Null
+		Order By B.Code
+	`, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil ringkasan cabang: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []BranchSummary{}
+	for rows.Next() {
+		var summary BranchSummary
+		if err := rows.Scan(&summary.BranchID, &summary.Code, &summary.Name,
+			&summary.SalesCount, &summary.SalesTotal,
+			&summary.PurchaseCount, &summary.PurchaseTotal,
+			&summary.TotalIncome, &summary.TotalExpense,
+			&summary.StockValue); err != nil {
+			return nil, fmt.Errorf("gagal membaca ringkasan cabang: %w", err)
+		}
+		summary.NetAmount = summary.TotalIncome - summary.TotalExpense
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
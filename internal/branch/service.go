@@ -0,0 +1,188 @@
+package branch
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sinartimur-go/pkg/dto"
+	"time"
+)
+
+// BranchService is the service for branches and user branch assignments
+type BranchService struct {
+	repo BranchRepository
+}
+
+// NewBranchService creates a new instance of BranchService
+func NewBranchService(repo BranchRepository) *BranchService {
+	return &BranchService{repo: repo}
+}
+
+// GetAllBranches fetches branches with pagination
+func (s *BranchService) GetAllBranches(req GetBranchesRequest) ([]Branch, int, *dto.APIError) {
+	branches, totalItems, err := s.repo.GetAll(req)
+	if err != nil {
+		return nil, 0, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data cabang",
+		})
+	}
+	return branches, totalItems, nil
+}
+
+// GetBranchByID fetches a branch by ID
+func (s *BranchService) GetBranchByID(id string) (*Branch, *dto.APIError) {
+	branch, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Cabang tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data cabang",
+		})
+	}
+	return branch, nil
+}
+
+// CreateBranch creates a new branch
+func (s *BranchService) CreateBranch(req CreateBranchRequest) (*Branch, *dto.APIError) {
+	// Check if code is already used
+	if _, err := s.repo.GetByCode(req.Code); err == nil {
+		return nil, dto.NewAPIError(http.StatusConflict, map[string]string{
+			"code": "Kode cabang sudah digunakan",
+		})
+	}
+
+	branch, err := s.repo.Create(req)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membuat cabang",
+		})
+	}
+	return branch, nil
+}
+
+// UpdateBranch updates an existing branch
+func (s *BranchService) UpdateBranch(req UpdateBranchRequest) (*Branch, *dto.APIError) {
+	if _, apiErr := s.GetBranchByID(req.ID); apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Check if code is used by another branch
+	if existing, err := s.repo.GetByCode(req.Code); err == nil && existing.ID != req.ID {
+		return nil, dto.NewAPIError(http.StatusConflict, map[string]string{
+			"code": "Kode cabang sudah digunakan",
+		})
+	}
+
+	branch, err := s.repo.Update(req)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memperbarui cabang",
+		})
+	}
+	return branch, nil
+}
+
+// DeleteBranch soft deletes a branch that has no storages or open orders left
+func (s *BranchService) DeleteBranch(id string) *dto.APIError {
+	if _, apiErr := s.GetBranchByID(id); apiErr != nil {
+		return apiErr
+	}
+
+	inUse, err := s.repo.IsInUse(id)
+	if err != nil {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menghapus cabang",
+		})
+	}
+	if inUse {
+		return dto.NewAPIError(http.StatusConflict, map[string]string{
+			"general": "Cabang masih memiliki gudang atau pesanan aktif",
+		})
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menghapus cabang",
+		})
+	}
+	return nil
+}
+
+// GetUserBranches fetches the branches a user is assigned to
+func (s *BranchService) GetUserBranches(userID string) ([]UserBranch, *dto.APIError) {
+	branches, err := s.repo.GetUserBranches(userID)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil cabang user",
+		})
+	}
+	return branches, nil
+}
+
+// AssignUserBranches replaces the branches a user is assigned to.
+// The change applies to the user's next token refresh.
+func (s *BranchService) AssignUserBranches(req AssignUserBranchesRequest) ([]UserBranch, *dto.APIError) {
+	// The default branch must be one of the assigned branches
+	hasDefault := false
+	for _, branchID := range req.BranchIDs {
+		if _, apiErr := s.GetBranchByID(branchID); apiErr != nil {
+			return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"branch_ids": "Cabang " + branchID + " tidak ditemukan",
+			})
+		}
+		if branchID == req.DefaultBranchID {
+			hasDefault = true
+		}
+	}
+	if !hasDefault {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"default_branch_id": "Cabang utama harus termasuk dalam daftar cabang",
+		})
+	}
+
+	if err := s.repo.AssignUserBranches(req); err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menyimpan cabang user",
+		})
+	}
+
+	return s.GetUserBranches(req.UserID)
+}
+
+// GetBranchSummary builds the consolidated cross-branch report
+func (s *BranchService) GetBranchSummary(req GetBranchSummaryRequest) (*BranchSummaryResponse, *dto.APIError) {
+	var startDate, endDate *time.Time
+	if req.StartDate != "" {
+		t, _ := time.Parse(time.RFC3339, req.StartDate)
+		startDate = &t
+	}
+	if req.EndDate != "" {
+		t, _ := time.Parse(time.RFC3339, req.EndDate)
+		endDate = &t
+	}
+
+	summaries, err := s.repo.GetSummary(startDate, endDate)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil ringkasan cabang",
+		})
+	}
+
+	response := BranchSummaryResponse{Branches: summaries}
+	response.Total.Name = "Semua Cabang"
+	for _, summary := range summaries {
+		response.Total.SalesCount += summary.SalesCount
+		response.Total.SalesTotal += summary.SalesTotal
+		response.Total.PurchaseCount += summary.PurchaseCount
+		response.Total.PurchaseTotal += summary.PurchaseTotal
+		response.Total.TotalIncome += summary.TotalIncome
+		response.Total.TotalExpense += summary.TotalExpense
+		response.Total.NetAmount += summary.NetAmount
+		response.Total.StockValue += summary.StockValue
+	}
+
+	return &response, nil
+}
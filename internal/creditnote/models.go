@@ -0,0 +1,91 @@
+package creditnote
+
+import "sinartimur-go/utils"
+
+// How a credit note is settled with the customer
+const (
+	SettlementApplied  = "applied"  // Put against open paylater invoices of the customer
+	SettlementRefunded = "refunded" // Paid back to the customer
+)
+
+// CreditNote is a credit note (nota kredit) issued to the customer for a sales return
+type CreditNote struct {
+	ID                 string                 `json:"id"`
+	SerialID           string                 `json:"serial_id"`
+	BranchID           *string                `json:"branch_id,omitempty"`
+	BranchName         *string                `json:"branch_name,omitempty"`
+	SalesOrderReturnID string                 `json:"sales_order_return_id"`
+	ReturnSerialID     string                 `json:"return_serial_id"`
+	SalesOrderID       string                 `json:"sales_order_id"`
+	SalesOrderSerial   string                 `json:"sales_order_serial"`
+	CustomerID         string                 `json:"customer_id"`
+	CustomerName       string                 `json:"customer_name"`
+	CreditDate         string                 `json:"credit_date"`
+	Amount             float64                `json:"amount"`
+	Settlement         string                 `json:"settlement"`
+	RefundMethod       *string                `json:"refund_method,omitempty"`
+	RefundReference    *string                `json:"refund_reference,omitempty"`
+	Notes              *string                `json:"notes,omitempty"`
+	Status             string                 `json:"status"`
+	CreatedBy          *string                `json:"created_by,omitempty"`
+	CreatedByName      *string                `json:"created_by_name,omitempty"`
+	CreatedAt          string                 `json:"created_at"`
+	CancelledAt        *string                `json:"cancelled_at,omitempty"`
+	Allocations        []CreditNoteAllocation `json:"allocations,omitempty"`
+}
+
+// CreditNoteAllocation is the part of a credit note put against an invoice
+type CreditNoteAllocation struct {
+	ID                 string  `json:"id"`
+	SalesInvoiceID     string  `json:"sales_invoice_id"`
+	SalesInvoiceSerial string  `json:"sales_invoice_serial"`
+	InvoiceDate        string  `json:"invoice_date"`
+	DueDate            *string `json:"due_date,omitempty"`
+	InvoiceTotal       float64 `json:"invoice_total"`
+	Amount             float64 `json:"amount"`
+}
+
+// ReturnCredit is a sales return with what it credits the customer, the base of its credit note
+type ReturnCredit struct {
+	ReturnID     string
+	SerialID     string
+	SalesOrderID string
+	CustomerID   *string
+	BranchID     *string
+	Status       string
+	Cancelled    bool
+	Amount       float64
+	CreditNoteID *string // Set once a credit note was issued
+}
+
+// GetCreditNotesRequest holds query parameters for listing credit notes
+type GetCreditNotesRequest struct {
+	Search     string `json:"search" validate:"omitempty,max=255"`
+	CustomerID string `json:"customer_id" validate:"omitempty,uuid"`
+	Settlement string `json:"settlement" validate:"omitempty,oneof=applied refunded"`
+	Status     string `json:"status" validate:"omitempty,oneof=active cancelled"`
+	StartDate  string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate    string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	BranchID   string `json:"-"`
+	utils.PaginationParameter
+}
+
+// CreditNoteAllocationRequest is the amount of a credit note put against an invoice
+type CreditNoteAllocationRequest struct {
+	SalesInvoiceID string  `json:"sales_invoice_id" validate:"required,uuid"`
+	Amount         float64 `json:"amount" validate:"required,gt=0"`
+}
+
+// CreateCreditNoteRequest holds data needed to issue the credit note of a return, applied credit notes are
+// allocated over invoices adding up to the return amount, refunded ones name how the money was paid back
+type CreateCreditNoteRequest struct {
+	SalesOrderReturnID string                        `json:"-"`
+	Settlement         string                        `json:"settlement" validate:"required,oneof=applied refunded"`
+	RefundMethod       string                        `json:"refund_method" validate:"omitempty,oneof=cash transfer"`
+	RefundReference    string                        `json:"refund_reference" validate:"omitempty,max=255"`
+	Notes              string                        `json:"notes" validate:"omitempty,max=1000"`
+	Allocations        []CreditNoteAllocationRequest `json:"allocations" validate:"omitempty,dive"`
+	BranchID           string                        `json:"-"` // Branch of the return
+	CustomerID         string                        `json:"-"` // Customer of the return
+	Amount             float64                       `json:"-"` // Credited by the return
+}
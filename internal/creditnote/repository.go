@@ -0,0 +1,349 @@
+package creditnote
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sinartimur-go/internal/outbox"
+	"sinartimur-go/internal/payment"
+	"sinartimur-go/pkg/event"
+	"sinartimur-go/utils"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CreditNoteRepository defines the interface for credit note data operations
+type CreditNoteRepository interface {
+	GetAll(req GetCreditNotesRequest) ([]CreditNote, int, error)
+	GetByID(id string) (*CreditNote, error)
+	GetAllocations(id string) ([]CreditNoteAllocation, error)
+	GetReturnCredit(returnID string) (*ReturnCredit, error)
+	InvoiceBalances(ids []string, returnID string) ([]payment.InvoiceBalance, error)
+	Create(req CreateCreditNoteRequest, userID string) (string, error)
+}
+
+// CreditNoteRepositoryImpl implements the CreditNoteRepository interface
+type CreditNoteRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewCreditNoteRepository creates a new credit note repository instance
+func NewCreditNoteRepository(db *sql.DB) CreditNoteRepository {
+	return &CreditNoteRepositoryImpl{db: db}
+}
+
+// creditNoteStatusExpr reports a credit note as active until its return is cancelled
+const creditNoteStatusExpr = `Case When Cn.Cancelled_At Is Null Then 'active' Else 'cancelled' End`
+
+const creditNoteColumns = `Cn.Id, Cn.Serial_Id, Cn.Branch_Id, B.Name, Cn.Sales_Order_Return_Id, Coalesce(Sor.Serial_Id, ''),
+	So.Id, So.Serial_Id, Cn.Customer_Id, C.Name, Cn.Credit_Date, Cn.Amount, Cn.Settlement, Cn.Refund_Method,
+	Cn.Refund_Reference, Cn.Notes, ` + creditNoteStatusExpr + `, Cn.Created_By, Au.Username, Cn.Created_At, Cn.Cancelled_At`
+
+const creditNoteJoins = `
+	From Credit_Note Cn
+	Join Sales_Order_Return Sor On Sor.Id = Cn.Sales_Order_Return_Id
+	Join Sales_Order So On So.Id = Sor.Sales_Order_Id
+	Join Customer C On C.Id = Cn.Customer_Id
+	Left Join Branch B On B.Id = Cn.Branch_Id
+	Left Join Appuser Au On Au.Id = Cn.Created_By`
+
+// creditNoteSortColumns lists the columns credit notes can be sorted by
+var creditNoteSortColumns = map[string]string{
+	"serial_id":     "Cn.Serial_Id",
+	"customer_name": "C.Name",
+	"credit_date":   "Cn.Credit_Date",
+	"amount":        "Cn.Amount",
+	"created_at":    "Cn.Created_At",
+}
+
+// scanCreditNote scans a credit note row selected with creditNoteColumns
+func scanCreditNote(row interface{ Scan(...interface{}) error }) (*CreditNote, error) {
+	var cn CreditNote
+	var creditDate, createdAt time.Time
+	var cancelledAt sql.NullTime
+	err := row.Scan(&cn.ID, &cn.SerialID, &cn.BranchID, &cn.BranchName, &cn.SalesOrderReturnID, &cn.ReturnSerialID,
+		&cn.SalesOrderID, &cn.SalesOrderSerial, &cn.CustomerID, &cn.CustomerName, &creditDate, &cn.Amount,
+		&cn.Settlement, &cn.RefundMethod, &cn.RefundReference, &cn.Notes, &cn.Status, &cn.CreatedBy, &cn.CreatedByName,
+		&createdAt, &cancelledAt)
+	if err != nil {
+		return nil, err
+	}
+	cn.CreditDate = creditDate.Format(time.RFC3339)
+	cn.CreatedAt = createdAt.Format(time.RFC3339)
+	if cancelledAt.Valid {
+		cancelled := cancelledAt.Time.Format(time.RFC3339)
+		cn.CancelledAt = &cancelled
+	}
+	return &cn, nil
+}
+
+// GetAll fetches credit notes with pagination, branch users only see their branch
+func (r *CreditNoteRepositoryImpl) GetAll(req GetCreditNotesRequest) ([]CreditNote, int, error) {
+	qb := utils.NewQueryBuilder("Select " + creditNoteColumns + creditNoteJoins + " Where 1=1")
+	qb.AddSearch(req.Search, "Cn.Serial_Id", "C.Name", "Sor.Serial_Id", "Cn.Refund_Reference")
+	qb.AddFilter("Cn.Branch_Id =", req.BranchID)
+	qb.AddFilter("Cn.Customer_Id =", req.CustomerID)
+	qb.AddFilter("Cn.Settlement =", req.Settlement)
+	qb.AddFilter(creditNoteStatusExpr+" =", req.Status)
+	qb.AddFilter("Cn.Credit_Date >=", req.StartDate)
+	qb.AddFilterExpr("Cn.Credit_Date < $?::date + 1", req.EndDate)
+
+	var totalItems int
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Credit_Notes", qb.Query.String())
+	if err := r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems); err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung nota kredit: %w", err)
+	}
+
+	if column, ok := creditNoteSortColumns[req.SortBy]; ok && req.SortOrder != "" {
+		qb.Query.WriteString(fmt.Sprintf(" Order By %s %s", column, req.SortOrder))
+	} else {
+		qb.Query.WriteString(" Order By Cn.Credit_Date Desc, Cn.Serial_Id Desc")
+	}
+	qb.AddPagination(req.PaginationParameter)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil nota kredit: %w", err)
+	}
+	defer rows.Close()
+
+	creditNotes := []CreditNote{}
+	for rows.Next() {
+		cn, errScan := scanCreditNote(rows)
+		if errScan != nil {
+			return nil, 0, fmt.Errorf("gagal membaca nota kredit: %w", errScan)
+		}
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(*cn); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
+		creditNotes = append(creditNotes, *cn)
+	}
+
+	return creditNotes, totalItems, rows.Err()
+}
+
+// GetByID fetches a credit note header, sql.ErrNoRows means it does not exist
+func (r *CreditNoteRepositoryImpl) GetByID(id string) (*CreditNote, error) {
+	return scanCreditNote(r.db.QueryRow("Select "+creditNoteColumns+creditNoteJoins+" Where Cn.Id = $1", id))
+}
+
+// GetAllocations fetches the invoices a credit note was put against
+func (r *CreditNoteRepositoryImpl) GetAllocations(id string) ([]CreditNoteAllocation, error) {
+	rows, err := r.db.Query(`
+		Select Cna.Id, Si.Id, Si.Serial_Id, Si.Invoice_Date, So.Payment_Due_Date, Si.Total_Amount, Cna.Amount
+		From Credit_Note_Allocation Cna
+		Join Sales_Invoice Si On Si.Id = Cna.Sales_Invoice_Id
+		Join Sales_Order So On So.Id = Si.Sales_Order_Id
+		Where Cna.Credit_Note_Id = $1
+		Order By Si.Invoice_Date, Si.Serial_Id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil alokasi nota kredit: %w", err)
+	}
+	defer rows.Close()
+
+	allocations := []CreditNoteAllocation{}
+	for rows.Next() {
+		var a CreditNoteAllocation
+		var invoiceDate time.Time
+		var dueDate sql.NullTime
+		if errScan := rows.Scan(&a.ID, &a.SalesInvoiceID, &a.SalesInvoiceSerial, &invoiceDate, &dueDate,
+			&a.InvoiceTotal, &a.Amount); errScan != nil {
+			return nil, fmt.Errorf("gagal membaca alokasi nota kredit: %w", errScan)
+		}
+		a.InvoiceDate = invoiceDate.Format(time.RFC3339)
+		if dueDate.Valid {
+			due := dueDate.Time.Format(time.RFC3339)
+			a.DueDate = &due
+		}
+		allocations = append(allocations, a)
+	}
+	return allocations, rows.Err()
+}
+
+// GetReturnCredit fetches a sales return with the amount it credits, valued like the return itself at what was
+// charged for its line after discounts and tax, sql.ErrNoRows means the return does not exist
+func (r *CreditNoteRepositoryImpl) GetReturnCredit(returnID string) (*ReturnCredit, error) {
+	var rc ReturnCredit
+	err := r.db.QueryRow(`
+		Select Sor.Id, Coalesce(Sor.Serial_Id, ''), So.Id, So.Customer_Id, So.Branch_Id, Sor.Return_Status,
+			Sor.Cancelled_At Is Not Null,
+			Coalesce(
+				(Select Sir.Amount From Sales_Invoice_Return Sir Where Sir.Id = Sor.Id),
+				Round(Sor.Return_Quantity * Sod.Line_Total / Sod.Quantity, 2)
+			),
+			(Select Cn.Id From Credit_Note Cn Where Cn.Sales_Order_Return_Id = Sor.Id)
+		From Sales_Order_Return Sor
+		Join Sales_Order So On So.Id = Sor.Sales_Order_Id
+		Join Sales_Order_Detail Sod On Sod.Id = Sor.Sales_Detail_Id
+		Where Sor.Id = $1`, returnID).Scan(&rc.ReturnID, &rc.SerialID, &rc.SalesOrderID, &rc.CustomerID, &rc.BranchID,
+		&rc.Status, &rc.Cancelled, &rc.Amount, &rc.CreditNoteID)
+	if err != nil {
+		return nil, err
+	}
+	return &rc, nil
+}
+
+// InvoiceBalances fetches the outstanding amount of the given invoices once the credit note of the return is
+// issued, the return no longer credits its invoice then so its amount is outstanding again
+func (r *CreditNoteRepositoryImpl) InvoiceBalances(ids []string, returnID string) ([]payment.InvoiceBalance, error) {
+	rows, err := r.db.Query(`
+		Select B.Id, Si.Serial_Id, B.Customer_Id, B.Branch_Id, B.Payment_Method, B.Total_Amount,
+			Greatest(B.Total_Amount - B.Paid_Amount - B.Returned_Amount + Coalesce(Sic.Amount, 0), 0),
+			B.Payment_Status = 'cancelled'
+		From Sales_Invoice_Balance B
+		Join Sales_Invoice Si On Si.Id = B.Id
+		Left Join Sales_Invoice_Credit Sic On Sic.Id = $2 And Sic.Type = 'return' And Sic.Sales_Invoice_Id = B.Id
+			And Sic.Cancelled_At Is Null
+		Where B.Id = Any($1::uuid[])`, pq.Array(ids), returnID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil sisa tagihan: %w", err)
+	}
+	defer rows.Close()
+
+	var balances []payment.InvoiceBalance
+	for rows.Next() {
+		var b payment.InvoiceBalance
+		if err = rows.Scan(&b.SalesInvoiceID, &b.SerialID, &b.CustomerID, &b.BranchID, &b.PaymentMethod, &b.TotalAmount,
+			&b.OutstandingAmount, &b.Cancelled); err != nil {
+			return nil, fmt.Errorf("gagal membaca sisa tagihan: %w", err)
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+// Create issues the credit note of a return with its allocations, a refund is posted to the finance log.
+// sql.ErrNoRows means the return was cancelled or credited meanwhile, or an invoice no longer has the
+// allocated amount outstanding.
+func (r *CreditNoteRepositoryImpl) Create(req CreateCreditNoteRequest, userID string) (string, error) {
+	var id string
+	err := utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		// Lock the return so it can not be cancelled or credited twice while the credit note is issued
+		var credited bool
+		err := tx.QueryRow(`
+			Select Exists(Select 1 From Credit_Note Cn Where Cn.Sales_Order_Return_Id = Sor.Id)
+			From Sales_Order_Return Sor
+			Where Sor.Id = $1 And Sor.Return_Status = 'completed' And Sor.Cancelled_At Is Null
+			For Update`, req.SalesOrderReturnID).Scan(&credited)
+		if err != nil {
+			return err
+		}
+		if credited {
+			return sql.ErrNoRows
+		}
+
+		if len(req.Allocations) > 0 {
+			invoiceIDs := make([]string, 0, len(req.Allocations))
+			for _, allocation := range req.Allocations {
+				invoiceIDs = append(invoiceIDs, allocation.SalesInvoiceID)
+			}
+			// Lock the invoices so a payment can not settle the same outstanding amount
+			if _, err = tx.Exec("Select Id From Sales_Invoice Where Id = Any($1::uuid[]) Order By Id For Update",
+				pq.Array(invoiceIDs)); err != nil {
+				return fmt.Errorf("gagal mengunci faktur: %w", err)
+			}
+		}
+
+		serialID, err := utils.GenerateNextBranchSerialID(tx, "CN", req.BranchID)
+		if err != nil {
+			return fmt.Errorf("gagal membuat serial ID: %w", err)
+		}
+
+		var creditDate time.Time
+		err = tx.QueryRow(`
+			Insert Into Credit_Note (
+				Branch_Id, Serial_Id, Sales_Order_Return_Id, Customer_Id, Amount, Settlement, Refund_Method,
+				Refund_Reference, Notes, Created_By
+			) Values (
+				Nullif($1, '')::uuid, $2, $3, $4, $5, $6, Nullif($7, ''), Nullif($8, ''), Nullif($9, ''),
+				Nullif($10, '')::uuid
+			)
+			Returning Id, Credit_Date`,
+			req.BranchID, serialID, req.SalesOrderReturnID, req.CustomerID, req.Amount, req.Settlement,
+			req.RefundMethod, req.RefundReference, req.Notes, userID).Scan(&id, &creditDate)
+		if err != nil {
+			return fmt.Errorf("gagal membuat nota kredit: %w", err)
+		}
+
+		// The credit note now stands in for the return, so the balances read below no longer carry its credit
+		for _, allocation := range req.Allocations {
+			var outstanding float64
+			var status string
+			err = tx.QueryRow("Select Outstanding_Amount, Payment_Status From Sales_Invoice_Balance Where Id = $1",
+				allocation.SalesInvoiceID).Scan(&outstanding, &status)
+			if err != nil {
+				return fmt.Errorf("gagal memeriksa sisa tagihan: %w", err)
+			}
+			if status == "cancelled" || allocation.Amount > outstanding {
+				return sql.ErrNoRows
+			}
+
+			if _, err = tx.Exec(`
+				Insert Into Credit_Note_Allocation (Credit_Note_Id, Sales_Invoice_Id, Amount)
+				Values ($1, $2, $3)`,
+				id, allocation.SalesInvoiceID, allocation.Amount); err != nil {
+				return fmt.Errorf("gagal menambahkan alokasi nota kredit: %w", err)
+			}
+		}
+
+		// The return already reversed the sale, the posting records the money paid back
+		if req.Settlement == SettlementRefunded {
+			if _, err = tx.Exec(`
+				Insert Into Financial_Transaction_Log (
+					Branch_Id, User_Id, Amount, Type, Credit_Note_Id, Description, Transaction_Date, Is_System
+				) Values (Nullif($1, '')::uuid, Nullif($2, '')::uuid, $3, 'credit', $4, $5, $6, True)`,
+				req.BranchID, userID, req.Amount, id, fmt.Sprintf("Pengembalian Dana Nota Kredit %s", serialID),
+				creditDate); err != nil {
+				return fmt.Errorf("gagal mencatat transaksi keuangan: %w", err)
+			}
+		}
+
+		return outbox.Record(tx, event.CreditNoteCreated, map[string]interface{}{
+			"credit_note_id":        id,
+			"serial_id":             serialID,
+			"sales_order_return_id": req.SalesOrderReturnID,
+			"customer_id":           req.CustomerID,
+			"amount":                req.Amount,
+			"settlement":            req.Settlement,
+			"allocations":           req.Allocations,
+			"created_by":            userID,
+		})
+	})
+	return id, err
+}
+
+// CancelForReturn cancels the credit note of a return inside the transaction cancelling the return, its
+// allocations stop counting and a refund posting is reversed. A return without credit note is left alone.
+func CancelForReturn(tx *sql.Tx, returnID, userID string) error {
+	var id string
+	err := tx.QueryRow(`
+		Update Credit_Note
+		Set Cancelled_At = Now(), Cancelled_By = Nullif($1, '')::uuid
+		Where Sales_Order_Return_Id = $2 And Cancelled_At Is Null
+		Returning Id`,
+		userID, returnID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("gagal membatalkan nota kredit: %w", err)
+	}
+
+	if _, err = tx.Exec(`
+		Update Financial_Transaction_Log
+		Set Deleted_At = Now(), Edited_At = Now()
+		Where Credit_Note_Id = $1 And Deleted_At Is Null`, id); err != nil {
+		return fmt.Errorf("gagal membatalkan transaksi keuangan: %w", err)
+	}
+
+	return outbox.Record(tx, event.CreditNoteCancelled, map[string]string{
+		"credit_note_id":        id,
+		"sales_order_return_id": returnID,
+		"cancelled_by":          userID,
+	})
+}
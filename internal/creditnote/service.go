@@ -0,0 +1,219 @@
+package creditnote
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sinartimur-go/internal/payment"
+	"sinartimur-go/pkg/dto"
+)
+
+// CreditNoteService is the service for the credit notes of sales returns
+type CreditNoteService struct {
+	repo CreditNoteRepository
+}
+
+// NewCreditNoteService creates a new instance of CreditNoteService
+func NewCreditNoteService(repo CreditNoteRepository) *CreditNoteService {
+	return &CreditNoteService{repo: repo}
+}
+
+// cents rounds an amount to whole cents so sums of allocations compare exactly
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// GetAll fetches credit notes with pagination
+func (s *CreditNoteService) GetAll(req GetCreditNotesRequest) ([]CreditNote, int, *dto.APIError) {
+	creditNotes, totalItems, err := s.repo.GetAll(req)
+	if err != nil {
+		return nil, 0, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data nota kredit",
+		})
+	}
+	return creditNotes, totalItems, nil
+}
+
+// GetByID fetches a credit note with the invoices it was put against, hiding credit notes of other branches
+// from branch users
+func (s *CreditNoteService) GetByID(id, branchID string) (*CreditNote, *dto.APIError) {
+	creditNote, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Nota kredit tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data nota kredit",
+		})
+	}
+	if branchID != "" && creditNote.BranchID != nil && *creditNote.BranchID != branchID {
+		return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+			"general": "Nota kredit tidak ditemukan",
+		})
+	}
+
+	allocations, err := s.repo.GetAllocations(id)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil alokasi nota kredit",
+		})
+	}
+	creditNote.Allocations = allocations
+	return creditNote, nil
+}
+
+// validateAllocations checks that every allocated invoice is an open paylater invoice of the customer in the
+// branch of the return and that no invoice receives more than it has outstanding
+func (s *CreditNoteService) validateAllocations(req CreateCreditNoteRequest) *dto.APIError {
+	var total int64
+	seen := make(map[string]bool, len(req.Allocations))
+	ids := make([]string, 0, len(req.Allocations))
+	for _, allocation := range req.Allocations {
+		if seen[allocation.SalesInvoiceID] {
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": "Faktur tidak boleh dialokasikan lebih dari sekali",
+			})
+		}
+		seen[allocation.SalesInvoiceID] = true
+		ids = append(ids, allocation.SalesInvoiceID)
+		total += cents(allocation.Amount)
+	}
+	if total != cents(req.Amount) {
+		return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"allocations": fmt.Sprintf("Total alokasi harus sama dengan nilai retur %.2f", req.Amount),
+		})
+	}
+
+	balances, err := s.repo.InvoiceBalances(ids, req.SalesOrderReturnID)
+	if err != nil {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa sisa tagihan",
+		})
+	}
+	byID := make(map[string]payment.InvoiceBalance, len(balances))
+	for _, balance := range balances {
+		byID[balance.SalesInvoiceID] = balance
+	}
+
+	for _, allocation := range req.Allocations {
+		balance, ok := byID[allocation.SalesInvoiceID]
+		if !ok || balance.CustomerID != req.CustomerID ||
+			(balance.BranchID != nil && *balance.BranchID != req.BranchID) {
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": "Faktur tidak ditemukan untuk pelanggan ini: " + allocation.SalesInvoiceID,
+			})
+		}
+		switch {
+		case balance.Cancelled:
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": "Faktur " + balance.SerialID + " sudah dibatalkan",
+			})
+		case balance.PaymentMethod != "paylater":
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": "Faktur " + balance.SerialID + " adalah penjualan tunai",
+			})
+		case cents(allocation.Amount) > cents(balance.OutstandingAmount):
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": fmt.Sprintf("Alokasi faktur %s melebihi sisa tagihan %.2f", balance.SerialID,
+					balance.OutstandingAmount),
+			})
+		}
+	}
+	return nil
+}
+
+// Create issues the credit note of a completed return for the full amount it credits, either put against open
+// invoices of the customer or refunded
+func (s *CreditNoteService) Create(req CreateCreditNoteRequest, branchID, userID string) (*CreditNote, *dto.APIError) {
+	notFound := dto.NewAPIError(http.StatusNotFound, map[string]string{
+		"general": "Retur penjualan tidak ditemukan",
+	})
+	returnCredit, err := s.repo.GetReturnCredit(req.SalesOrderReturnID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, notFound
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data retur penjualan",
+		})
+	}
+	// Returns of other branches are hidden from branch users
+	if branchID != "" && (returnCredit.BranchID == nil || *returnCredit.BranchID != branchID) {
+		return nil, notFound
+	}
+
+	switch {
+	case returnCredit.Cancelled:
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Retur penjualan sudah dibatalkan",
+		})
+	case returnCredit.Status != "completed":
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Retur penjualan belum selesai",
+		})
+	case returnCredit.CreditNoteID != nil:
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Retur penjualan sudah memiliki nota kredit",
+		})
+	case returnCredit.CustomerID == nil:
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Pesanan retur tidak memiliki pelanggan",
+		})
+	case returnCredit.Amount <= 0:
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Retur penjualan tidak memiliki nilai",
+		})
+	}
+
+	// The credit note belongs to the customer and branch of the returned sale
+	req.CustomerID = *returnCredit.CustomerID
+	req.BranchID = ""
+	if returnCredit.BranchID != nil {
+		req.BranchID = *returnCredit.BranchID
+	}
+	req.Amount = returnCredit.Amount
+
+	if req.Settlement == SettlementRefunded {
+		if req.RefundMethod == "" {
+			return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"refund_method": "Cara pengembalian dana wajib diisi",
+			})
+		}
+		if len(req.Allocations) > 0 {
+			return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": "Nota kredit yang dikembalikan tidak dialokasikan ke faktur",
+			})
+		}
+	} else {
+		if len(req.Allocations) == 0 {
+			return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": "Alokasi faktur wajib diisi",
+			})
+		}
+		if req.RefundMethod != "" || req.RefundReference != "" {
+			return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"refund_method": "Pengembalian dana hanya untuk nota kredit yang dikembalikan",
+			})
+		}
+		if apiErr := s.validateAllocations(req); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+
+	id, err := s.repo.Create(req, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusConflict, map[string]string{
+				"general": "Retur atau sisa tagihan faktur sudah berubah, muat ulang data",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membuat nota kredit",
+		})
+	}
+	return s.GetByID(id, "")
+}
@@ -8,38 +8,55 @@ import (
 
 // Customer represents a customer entity
 type Customer struct {
-	ID        uuid.UUID  `json:"id"`
-	Name      string     `json:"name"`
-	Address   string     `json:"address"`
-	Telephone string     `json:"telephone"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	ID             uuid.UUID  `json:"id"`
+	Name           string     `json:"name"`
+	Address        string     `json:"address"`
+	Telephone      string     `json:"telephone"`
+	Email          string     `json:"email"`
+	CreditLimit    *float64   `json:"credit_limit,omitempty"` // Paylater limits, no limit when empty
+	MaxOverdueDays *int       `json:"max_overdue_days,omitempty"`
+	PriceListID    *string    `json:"price_list_id,omitempty"` // Prices offered on sales orders
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
 }
 
 // CreateCustomerRequest represents the data needed to create a customer
 type CreateCustomerRequest struct {
-	Name      string `json:"name" validate:"required,min=2,max=255"`
-	Address   string `json:"address" validate:"omitempty,max=1000"`
-	Telephone string `json:"telephone" validate:"omitempty,max=50"`
+	Name           string   `json:"name" validate:"required,min=2,max=255"`
+	Address        string   `json:"address" validate:"omitempty,max=1000"`
+	Telephone      string   `json:"telephone" validate:"omitempty,max=50"`
+	Email          string   `json:"email" validate:"omitempty,email,max=255"`
+	CreditLimit    *float64 `json:"credit_limit" validate:"omitempty,gte=0"` // Paylater limits, no limit when empty
+	MaxOverdueDays *int     `json:"max_overdue_days" validate:"omitempty,gte=0"`
+	PriceListID    *string  `json:"price_list_id" validate:"omitempty,uuid"` // Prices offered on sales orders
 }
 
 // UpdateCustomerRequest represents the data needed to update a customer
 type UpdateCustomerRequest struct {
-	ID        uuid.UUID `json:"id" validate:"required,uuid"`
-	Name      string    `json:"name" validate:"required,min=2,max=255"`
-	Address   string    `json:"address" validate:"omitempty,max=1000"`
-	Telephone string    `json:"telephone" validate:"omitempty,max=50"`
+	ID             uuid.UUID `json:"id" validate:"required,uuid"`
+	Name           string    `json:"name" validate:"required,min=2,max=255"`
+	Address        string    `json:"address" validate:"omitempty,max=1000"`
+	Telephone      string    `json:"telephone" validate:"omitempty,max=50"`
+	Email          string    `json:"email" validate:"omitempty,email,max=255"`
+	CreditLimit    *float64  `json:"credit_limit" validate:"omitempty,gte=0"` // Paylater limits, no limit when empty
+	MaxOverdueDays *int      `json:"max_overdue_days" validate:"omitempty,gte=0"`
+	PriceListID    *string   `json:"price_list_id" validate:"omitempty,uuid"` // Prices offered on sales orders
 }
 
 // GetCustomerResponse represents the customer data returned from read operations
 type GetCustomerResponse struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Address   string `json:"address"`
-	Telephone string `json:"telephone"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Address        string   `json:"address"`
+	Telephone      string   `json:"telephone"`
+	Email          string   `json:"email"`
+	CreditLimit    *float64 `json:"credit_limit,omitempty"` // Paylater limits, no limit when empty
+	MaxOverdueDays *int     `json:"max_overdue_days,omitempty"`
+	PriceListID    *string  `json:"price_list_id,omitempty"` // Prices offered on sales orders
+	PriceListName  *string  `json:"price_list_name,omitempty"`
+	CreatedAt      string   `json:"created_at"`
+	UpdatedAt      string   `json:"updated_at"`
 }
 
 // GetCustomerRequest represents the parameters for filtering customer queries
@@ -47,6 +64,7 @@ type GetCustomerRequest struct {
 	Name      string `json:"name,omitempty" validate:"omitempty,max=255"`
 	Address   string `json:"address,omitempty" validate:"omitempty,max=1000"`
 	Telephone string `json:"telephone,omitempty" validate:"omitempty,max=50"`
+	Search    string `json:"search,omitempty" validate:"omitempty,max=255"`
 	utils.PaginationParameter
 }
 
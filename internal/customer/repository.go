@@ -16,8 +16,12 @@ type CustomerRepository interface {
 	Create(req CreateCustomerRequest) error
 	Update(req UpdateCustomerRequest) error
 	Delete(req DeleteCustomerRequest) error
+	PriceListExists(id string) (bool, error)
 }
 
+// customerSearchFields are the columns matched by the fuzzy customer search
+var customerSearchFields = []string{"name", "address", "telephone"}
+
 type RepositoryImpl struct {
 	db *sql.DB
 }
@@ -28,12 +32,15 @@ func NewCustomerRepository(db *sql.DB) CustomerRepository {
 
 func (r *RepositoryImpl) GetAll(req GetCustomerRequest) ([]GetCustomerResponse, int, error) {
 	// Build the base query for selecting customer
-	queryBuilder := utils.NewQueryBuilder("SELECT id, name, address, telephone, created_at, updated_at FROM customer WHERE deleted_at IS NULL")
+	queryBuilder := utils.NewQueryBuilder(`SELECT id, name, address, telephone, COALESCE(email, '') AS email, credit_limit, max_overdue_days,
+		price_list_id, (SELECT pl.name FROM price_list pl WHERE pl.id = customer.price_list_id) AS price_list_name,
+		created_at, updated_at FROM customer WHERE deleted_at IS NULL`)
 
 	// Add filters based on the request parameters
 	queryBuilder.AddFilter("name ILIKE ", "%"+req.Name+"%")
 	queryBuilder.AddFilter("address ILIKE ", "%"+req.Address+"%")
 	queryBuilder.AddFilter("telephone ILIKE ", "%"+req.Telephone+"%")
+	queryBuilder.AddSearch(req.Search, customerSearchFields...)
 
 	// Build count query to get total items
 	countQuery, countParams := queryBuilder.Build()
@@ -53,9 +60,12 @@ func (r *RepositoryImpl) GetAll(req GetCustomerRequest) ([]GetCustomerResponse,
 			direction = "DESC"
 		}
 		queryBuilder.Query.WriteString(fmt.Sprintf(" ORDER BY %s %s", req.SortBy, direction))
+	} else if rank := queryBuilder.SearchRank(req.Search, customerSearchFields...); rank != "" {
+		// Rank search results by relevance when no explicit sort is requested
+		queryBuilder.Query.WriteString(" ORDER BY " + rank + " DESC, name")
 	}
 
-	queryBuilder.AddPagination(req.PageSize, req.Page)
+	queryBuilder.AddPagination(req.PaginationParameter)
 
 	// Execute the final query
 	query, params := queryBuilder.Build()
@@ -71,12 +81,19 @@ func (r *RepositoryImpl) GetAll(req GetCustomerRequest) ([]GetCustomerResponse,
 		var c GetCustomerResponse
 		var createdAt, updatedAt time.Time
 
-		if errScan := rows.Scan(&c.ID, &c.Name, &c.Address, &c.Telephone, &createdAt, &updatedAt); errScan != nil {
+		if errScan := rows.Scan(&c.ID, &c.Name, &c.Address, &c.Telephone, &c.Email, &c.CreditLimit, &c.MaxOverdueDays,
+			&c.PriceListID, &c.PriceListName, &createdAt, &updatedAt); errScan != nil {
 			return nil, 0, fmt.Errorf("gagal membaca data pelanggan: %w", errScan)
 		}
 
 		c.CreatedAt = createdAt.Format(time.RFC3339)
 		c.UpdatedAt = updatedAt.Format(time.RFC3339)
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(c); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		customer = append(customer, c)
 	}
 
@@ -89,7 +106,8 @@ func (r *RepositoryImpl) GetAll(req GetCustomerRequest) ([]GetCustomerResponse,
 
 func (r *RepositoryImpl) GetByID(id string) (*GetCustomerResponse, error) {
 	query := `
-		SELECT id, name, address, telephone, created_at, updated_at
+		SELECT id, name, address, telephone, COALESCE(email, ''), credit_limit, max_overdue_days, price_list_id,
+			(SELECT pl.name FROM price_list pl WHERE pl.id = customer.price_list_id), created_at, updated_at
 		FROM customer
 		WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -102,6 +120,11 @@ func (r *RepositoryImpl) GetByID(id string) (*GetCustomerResponse, error) {
 		&customer.Name,
 		&customer.Address,
 		&customer.Telephone,
+		&customer.Email,
+		&customer.CreditLimit,
+		&customer.MaxOverdueDays,
+		&customer.PriceListID,
+		&customer.PriceListName,
 		&createdAt,
 		&updatedAt,
 	)
@@ -121,7 +144,8 @@ func (r *RepositoryImpl) GetByID(id string) (*GetCustomerResponse, error) {
 
 func (r *RepositoryImpl) GetByName(name string) (*GetCustomerResponse, error) {
 	query := `
-		SELECT id, name, address, telephone, created_at, updated_at
+		SELECT id, name, address, telephone, COALESCE(email, ''), credit_limit, max_overdue_days, price_list_id,
+			(SELECT pl.name FROM price_list pl WHERE pl.id = customer.price_list_id), created_at, updated_at
 		FROM customer
 		WHERE name = $1 AND deleted_at IS NULL
 	`
@@ -134,6 +158,11 @@ func (r *RepositoryImpl) GetByName(name string) (*GetCustomerResponse, error) {
 		&customer.Name,
 		&customer.Address,
 		&customer.Telephone,
+		&customer.Email,
+		&customer.CreditLimit,
+		&customer.MaxOverdueDays,
+		&customer.PriceListID,
+		&customer.PriceListName,
 		&createdAt,
 		&updatedAt,
 	)
@@ -154,14 +183,15 @@ func (r *RepositoryImpl) GetByName(name string) (*GetCustomerResponse, error) {
 func (r *RepositoryImpl) Create(req CreateCustomerRequest) error {
 	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
 		query := `
-			INSERT INTO customer (id, name, address, telephone, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6)
+			INSERT INTO customer (id, name, address, telephone, email, credit_limit, max_overdue_days, price_list_id, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10)
 		`
 
 		id := uuid.New()
 		now := time.Now()
 
-		_, err := tx.Exec(query, id, req.Name, req.Address, req.Telephone, now, now)
+		_, err := tx.Exec(query, id, req.Name, req.Address, req.Telephone, req.Email, req.CreditLimit, req.MaxOverdueDays, req.PriceListID,
+			now, now)
 		if err != nil {
 			return fmt.Errorf("gagal membuat pelanggan baru: %w", err)
 		}
@@ -186,11 +216,13 @@ func (r *RepositoryImpl) Update(req UpdateCustomerRequest) error {
 		// Perform the update
 		updateQuery := `
 			UPDATE customer
-			SET name = $1, address = $2, telephone = $3, updated_at = $4
-			WHERE id = $5 AND deleted_at IS NULL
+			SET name = $1, address = $2, telephone = $3, email = NULLIF($4, ''), credit_limit = $5, max_overdue_days = $6,
+				price_list_id = $7, updated_at = $8
+			WHERE id = $9 AND deleted_at IS NULL
 		`
 
-		_, err = tx.Exec(updateQuery, req.Name, req.Address, req.Telephone, time.Now(), req.ID)
+		_, err = tx.Exec(updateQuery, req.Name, req.Address, req.Telephone, req.Email, req.CreditLimit, req.MaxOverdueDays,
+			req.PriceListID, time.Now(), req.ID)
 		if err != nil {
 			return fmt.Errorf("gagal memperbarui data pelanggan: %w", err)
 		}
@@ -225,3 +257,10 @@ func (r *RepositoryImpl) Delete(req DeleteCustomerRequest) error {
 		return nil
 	})
 }
+
+// PriceListExists checks whether a price list exists and is not deleted
+func (r *RepositoryImpl) PriceListExists(id string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM price_list WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists)
+	return exists, err
+}
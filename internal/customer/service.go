@@ -14,6 +14,31 @@ func NewCustomerService(repo CustomerRepository) *CustomerService {
 	return &CustomerService{repo: repo}
 }
 
+// checkPriceList checks that the price list assigned to a customer exists
+func (s *CustomerService) checkPriceList(id *string) *dto.APIError {
+	if id == nil {
+		return nil
+	}
+	exists, err := s.repo.PriceListExists(*id)
+	if err != nil {
+		return &dto.APIError{
+			StatusCode: 500,
+			Details: map[string]string{
+				"general": "Gagal memeriksa daftar harga",
+			},
+		}
+	}
+	if !exists {
+		return &dto.APIError{
+			StatusCode: 400,
+			Details: map[string]string{
+				"price_list_id": "Daftar harga tidak ditemukan",
+			},
+		}
+	}
+	return nil
+}
+
 // CreateCustomer creates a new customer
 func (s *CustomerService) CreateCustomer(request CreateCustomerRequest) *dto.APIError {
 	// Check if customer with the same name already exists
@@ -26,6 +51,9 @@ func (s *CustomerService) CreateCustomer(request CreateCustomerRequest) *dto.API
 			},
 		}
 	}
+	if apiErr := s.checkPriceList(request.PriceListID); apiErr != nil {
+		return apiErr
+	}
 
 	// Create the customer record
 	err = s.repo.Create(request)
@@ -63,6 +91,9 @@ func (s *CustomerService) UpdateCustomer(request UpdateCustomerRequest) *dto.API
 			},
 		}
 	}
+	if apiErr := s.checkPriceList(request.PriceListID); apiErr != nil {
+		return apiErr
+	}
 
 	err = s.repo.Update(request)
 	if err != nil {
@@ -0,0 +1,162 @@
+package document
+
+// Printable document types, also the Document_Type of a print record
+const (
+	TypeSalesInvoice  = "sales_invoice"
+	TypeDeliveryNote  = "delivery_note"
+	TypePurchaseOrder = "purchase_order"
+	TypeSalesReturn   = "sales_return"
+	TypeCreditNote    = "credit_note"
+)
+
+// TypeSalesOrder is a receipt source besides TypeSalesInvoice, sales orders have no PDF
+const TypeSalesOrder = "sales_order"
+
+// Thermal paper widths in millimetres and receipt output formats
+const (
+	ReceiptWidth58 = 58
+	ReceiptWidth80 = 80
+
+	ReceiptFormatESCPOS = "escpos"
+	ReceiptFormatText   = "text"
+)
+
+// CompanySetting is the letterhead printed on every document
+type CompanySetting struct {
+	Name      string  `json:"name"`
+	Address   *string `json:"address"`
+	Telephone *string `json:"telephone"`
+	Email     *string `json:"email"`
+	TaxNumber *string `json:"tax_number"`
+	Footer    *string `json:"footer"`
+	// Thermal receipt template
+	ReceiptWidth  int     `json:"receipt_width"`
+	ReceiptFooter *string `json:"receipt_footer"`
+	// PPN new sales and purchase orders start with
+	TaxRate   float64 `json:"tax_rate"`
+	TaxMode   string  `json:"tax_mode"`
+	UpdatedAt *string `json:"updated_at"`
+}
+
+// Letterhead is the company and branch data a document was issued with
+type Letterhead struct {
+	CompanySetting
+	BranchName      *string
+	BranchAddress   *string
+	BranchTelephone *string
+}
+
+// UpdateCompanySettingRequest holds the letterhead to save
+type UpdateCompanySettingRequest struct {
+	Name      string `json:"name" validate:"required,max=255"`
+	Address   string `json:"address" validate:"omitempty"`
+	Telephone string `json:"telephone" validate:"omitempty,max=50"`
+	Email     string `json:"email" validate:"omitempty,email"`
+	TaxNumber string `json:"tax_number" validate:"omitempty,max=50"`
+	Footer    string `json:"footer" validate:"omitempty,max=500"`
+	// Paper width of the thermal printer in millimetres
+	ReceiptWidth  int    `json:"receipt_width" validate:"omitempty,oneof=58 80"`
+	ReceiptFooter string `json:"receipt_footer" validate:"omitempty,max=255"`
+	// PPN in percent, exclusive when the mode is not given
+	TaxRate float64 `json:"tax_rate" validate:"gte=0,lte=100"`
+	TaxMode string  `json:"tax_mode" validate:"omitempty,oneof=exclusive inclusive"`
+}
+
+// DocumentHeader is the stored header of a printable document
+type DocumentHeader struct {
+	ID                   string
+	SerialID             string
+	Date                 string
+	SalesOrderID         string  // Set for sales documents
+	SalesDetailID        string  // Set for sales returns and credit notes
+	SalesInvoiceID       string  // Set for invoices and the delivery notes of an invoice
+	SalesInvoiceSerialID *string // Serial of SalesInvoiceID
+	BranchID             *string
+	BranchName           *string
+	BranchAddress        *string
+	BranchTelephone      *string
+	DriverName           string // Set for delivery notes
+	RecipientName        string // Set for delivery notes
+	Quantity             float64
+	Reason               *string
+	CreatedByName        *string
+	CancelledAt          *string
+	Totals               DocumentTotals // Stored totals, a sales return or credit note only has its Total
+	AmountTendered       *float64       // Cash handed over on a cash sale, set for sales orders and invoices
+	ChangeAmount         *float64       // Change given back of AmountTendered
+}
+
+// DocumentTotals is the price breakdown of a document, Discount adds up the line and document discounts
+type DocumentTotals struct {
+	Subtotal float64
+	Discount float64
+	TaxBase  float64 // DPP
+	Tax      float64 // PPN
+	TaxRate  float64
+	TaxMode  string
+	Total    float64
+}
+
+// Document is the printable content of a document, built from stored data only
+type Document struct {
+	Type         string
+	Title        string
+	SerialID     string
+	Date         string
+	BranchName   *string
+	Address      *string // Branch address, the company address is used when empty
+	Telephone    *string
+	PartyLabel   string
+	PartyName    string
+	PartyAddress *string
+	PartyPhone   *string
+	References   []DocumentField
+	Lines        []DocumentLine
+	ShowPrices   bool
+	Totals       DocumentTotals
+	Notes        string
+	Signatures   []Signature
+	Cancelled    bool
+}
+
+// DocumentField is a labelled value printed beside the party block
+type DocumentField struct {
+	Label string
+	Value string
+}
+
+// DocumentLine is a line item of a document
+type DocumentLine struct {
+	Name      string
+	SKU       string
+	Unit      string
+	Quantity  float64
+	UnitPrice float64
+	Discount  float64 // Line discount
+	Subtotal  float64 // Quantity times unit price, before the line discount
+}
+
+// Signature is a signature block, Name is printed under the line when known
+type Signature struct {
+	Label string
+	Name  string
+}
+
+// GetReceiptRequest holds the options of a thermal receipt
+type GetReceiptRequest struct {
+	Width    int    `json:"width" validate:"omitempty,oneof=58 80"`
+	Format   string `json:"format" validate:"omitempty,oneof=escpos text"`
+	BranchID string `json:"-"`
+}
+
+// Receipt is the content of a thermal receipt for a cash sale
+type Receipt struct {
+	SerialID     string
+	Date         string
+	Cashier      string
+	CustomerName string
+	Lines        []DocumentLine
+	Totals       DocumentTotals
+	Paid         float64
+	Change       float64
+}
@@ -0,0 +1,370 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"sinartimur-go/pkg/pricing"
+	"sinartimur-go/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Page layout in millimetres, A4 portrait
+const (
+	pageMargin   = 15.0
+	contentWidth = 180.0
+	lineHeight   = 5.0
+	footerSpace  = 20.0
+)
+
+// tableColumn is a column of the line item table
+type tableColumn struct {
+	header string
+	width  float64
+	align  string
+	value  func(index int, line DocumentLine) string
+}
+
+// pdfRenderer draws a document with its letterhead
+type pdfRenderer struct {
+	pdf     *fpdf.Fpdf
+	tr      func(string) string
+	doc     Document
+	company CompanySetting
+	reprint bool
+}
+
+// formatDate shows an RFC3339 value as DD/MM/YYYY
+func formatDate(value string) string {
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return date.Format("02/01/2006")
+}
+
+// renderPDF renders a document on A4 paper, reprints carry a COPY watermark.
+// The output depends on the stored data only, so a reprint matches the original apart from the watermark.
+func renderPDF(doc Document, company CompanySetting, reprint bool) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	r := &pdfRenderer{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor(""), doc: doc, company: company, reprint: reprint}
+
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, footerSpace)
+	pdf.AliasNbPages("")
+	pdf.SetTitle(doc.Title+" "+doc.SerialID, true)
+	pdf.SetAuthor(company.Name, true)
+	pdf.SetCatalogSort(true)
+	if date, err := time.Parse(time.RFC3339, doc.Date); err == nil {
+		pdf.SetCreationDate(date)
+		pdf.SetModificationDate(date)
+	}
+	pdf.SetHeaderFunc(r.header)
+	pdf.SetFooterFunc(r.footer)
+
+	pdf.AddPage()
+	r.title()
+	r.parties()
+	r.table()
+	r.totals()
+	r.signatures()
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// header draws the watermark and the letterhead on every page
+func (r *pdfRenderer) header() {
+	pdf := r.pdf
+	if r.reprint {
+		pdf.SetFont("Helvetica", "B", 96)
+		pdf.SetTextColor(190, 190, 190)
+		pdf.SetAlpha(0.35, "Normal")
+		pdf.TransformBegin()
+		pdf.TransformRotate(45, 105, 160)
+		pdf.Text(105-pdf.GetStringWidth("COPY")/2, 175, "COPY")
+		pdf.TransformEnd()
+		pdf.SetAlpha(1, "Normal")
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, r.tr(r.company.Name), "", 1, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 9)
+	address, telephone := r.doc.Address, r.doc.Telephone
+	if address == nil || *address == "" {
+		address = r.company.Address
+	}
+	if telephone == nil || *telephone == "" {
+		telephone = r.company.Telephone
+	}
+	if address != nil && *address != "" {
+		pdf.MultiCell(0, 4, r.tr(*address), "", "L", false)
+	}
+
+	var contacts []string
+	if telephone != nil && *telephone != "" {
+		contacts = append(contacts, "Telp. "+*telephone)
+	}
+	if r.company.Email != nil && *r.company.Email != "" {
+		contacts = append(contacts, "Email "+*r.company.Email)
+	}
+	if r.company.TaxNumber != nil && *r.company.TaxNumber != "" {
+		contacts = append(contacts, "NPWP "+*r.company.TaxNumber)
+	}
+	if len(contacts) > 0 {
+		pdf.CellFormat(0, 4, r.tr(strings.Join(contacts, "  |  ")), "", 1, "L", false, 0, "")
+	}
+	if r.doc.BranchName != nil && *r.doc.BranchName != "" {
+		pdf.CellFormat(0, 4, r.tr("Cabang "+*r.doc.BranchName), "", 1, "L", false, 0, "")
+	}
+
+	pdf.Ln(2)
+	pdf.SetLineWidth(0.5)
+	pdf.Line(pageMargin, pdf.GetY(), pageMargin+contentWidth, pdf.GetY())
+	pdf.SetLineWidth(0.2)
+	pdf.Ln(4)
+}
+
+// footer draws the company footer and the page number
+func (r *pdfRenderer) footer() {
+	pdf := r.pdf
+	pdf.SetY(-15)
+	pdf.SetFont("Helvetica", "I", 8)
+	if r.company.Footer != nil {
+		pdf.CellFormat(contentWidth-30, 4, r.tr(*r.company.Footer), "", 0, "L", false, 0, "")
+	} else {
+		pdf.CellFormat(contentWidth-30, 4, "", "", 0, "L", false, 0, "")
+	}
+	pdf.CellFormat(30, 4, fmt.Sprintf("Halaman %d/{nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+}
+
+// title draws the document title and number
+func (r *pdfRenderer) title() {
+	pdf := r.pdf
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 7, r.tr(r.doc.Title), "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 5, r.tr("No. "+r.doc.SerialID), "", 1, "C", false, 0, "")
+	if r.doc.Cancelled {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.SetTextColor(200, 0, 0)
+		pdf.CellFormat(0, 5, "DIBATALKAN", "", 1, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+	pdf.Ln(4)
+}
+
+// parties draws the customer or supplier on the left and the references on the right
+func (r *pdfRenderer) parties() {
+	pdf := r.pdf
+	top := pdf.GetY()
+
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.CellFormat(95, lineHeight, r.tr(r.doc.PartyLabel), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.MultiCell(95, lineHeight, r.tr(r.doc.PartyName), "", "L", false)
+	if r.doc.PartyAddress != nil && *r.doc.PartyAddress != "" {
+		pdf.MultiCell(95, lineHeight, r.tr(*r.doc.PartyAddress), "", "L", false)
+	}
+	if r.doc.PartyPhone != nil && *r.doc.PartyPhone != "" {
+		pdf.CellFormat(95, lineHeight, r.tr("Telp. "+*r.doc.PartyPhone), "", 1, "L", false, 0, "")
+	}
+	bottom := pdf.GetY()
+
+	pdf.SetY(top)
+	references := append([]DocumentField{{Label: "Tanggal", Value: formatDate(r.doc.Date)}}, r.doc.References...)
+	for _, field := range references {
+		pdf.SetX(pageMargin + 100)
+		pdf.CellFormat(30, lineHeight, r.tr(field.Label), "", 0, "L", false, 0, "")
+		pdf.CellFormat(50, lineHeight, r.tr(": "+field.Value), "", 1, "L", false, 0, "")
+	}
+
+	if pdf.GetY() > bottom {
+		bottom = pdf.GetY()
+	}
+	pdf.SetY(bottom + 4)
+}
+
+// columns returns the line item columns, delivery notes and returns print quantities only
+func (r *pdfRenderer) columns() []tableColumn {
+	number := func(index int, _ DocumentLine) string { return strconv.Itoa(index + 1) }
+	name := func(_ int, line DocumentLine) string { return line.Name }
+	sku := func(_ int, line DocumentLine) string { return line.SKU }
+	quantity := func(_ int, line DocumentLine) string { return utils.FormatNumber(line.Quantity) }
+	unit := func(_ int, line DocumentLine) string { return line.Unit }
+
+	if !r.doc.ShowPrices {
+		return []tableColumn{
+			{header: "No", width: 10, align: "C", value: number},
+			{header: "Nama Barang", width: 85, align: "L", value: name},
+			{header: "SKU Batch", width: 45, align: "L", value: sku},
+			{header: "Jumlah", width: 20, align: "R", value: quantity},
+			{header: "Satuan", width: 20, align: "L", value: unit},
+		}
+	}
+	price := func(_ int, line DocumentLine) string { return utils.FormatRupiah(line.UnitPrice) }
+	subtotal := func(_ int, line DocumentLine) string { return utils.FormatRupiah(line.Subtotal) }
+
+	// The discount column only shows when a line has a discount
+	for _, line := range r.doc.Lines {
+		if line.Discount > 0 {
+			return []tableColumn{
+				{header: "No", width: 10, align: "C", value: number},
+				{header: "Nama Barang", width: 45, align: "L", value: name},
+				{header: "Jumlah", width: 20, align: "R", value: quantity},
+				{header: "Satuan", width: 20, align: "L", value: unit},
+				{header: "Harga", width: 30, align: "R", value: price},
+				{header: "Diskon", width: 25, align: "R", value: func(_ int, line DocumentLine) string {
+					if line.Discount == 0 {
+						return "-"
+					}
+					return utils.FormatRupiah(line.Discount)
+				}},
+				{header: "Subtotal", width: 30, align: "R", value: subtotal},
+			}
+		}
+	}
+	return []tableColumn{
+		{header: "No", width: 10, align: "C", value: number},
+		{header: "Nama Barang", width: 70, align: "L", value: name},
+		{header: "Jumlah", width: 20, align: "R", value: quantity},
+		{header: "Satuan", width: 20, align: "L", value: unit},
+		{header: "Harga", width: 30, align: "R", value: price},
+		{header: "Subtotal", width: 30, align: "R", value: subtotal},
+	}
+}
+
+// tableHeader draws the header row of the line item table
+func (r *pdfRenderer) tableHeader(columns []tableColumn) {
+	pdf := r.pdf
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(230, 230, 230)
+	for _, column := range columns {
+		pdf.CellFormat(column.width, 7, column.header, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 9)
+}
+
+// table draws the line items, long product names wrap and the header repeats on every page
+func (r *pdfRenderer) table() {
+	pdf := r.pdf
+	columns := r.columns()
+	_, pageHeight := pdf.GetPageSize()
+	r.tableHeader(columns)
+
+	for i, line := range r.doc.Lines {
+		// Height of the row is set by its tallest wrapped cell
+		cells := make([][]string, len(columns))
+		rows := 1
+		for j, column := range columns {
+			cells[j] = pdf.SplitText(r.tr(column.value(i, line)), column.width-2)
+			if len(cells[j]) > rows {
+				rows = len(cells[j])
+			}
+		}
+		height := float64(rows)*lineHeight + 1
+
+		if pdf.GetY()+height > pageHeight-footerSpace {
+			pdf.AddPage()
+			r.tableHeader(columns)
+		}
+
+		x, y := pdf.GetX(), pdf.GetY()
+		for j, column := range columns {
+			pdf.Rect(x, y, column.width, height, "D")
+			for k, text := range cells[j] {
+				pdf.SetXY(x, y+0.5+float64(k)*lineHeight)
+				pdf.CellFormat(column.width, lineHeight, text, "", 0, column.align, false, 0, "")
+			}
+			x += column.width
+		}
+		pdf.SetXY(pageMargin, y+height)
+	}
+}
+
+// totalsBreakdown lists the rows printed above the grand total, none when the document has no discount or tax
+func totalsBreakdown(totals DocumentTotals) []DocumentField {
+	if totals.Discount == 0 && totals.Tax == 0 {
+		return nil
+	}
+
+	rows := []DocumentField{{Label: "Subtotal", Value: utils.FormatRupiah(totals.Subtotal)}}
+	if totals.Discount > 0 {
+		rows = append(rows, DocumentField{Label: "Diskon", Value: utils.FormatRupiah(-totals.Discount)})
+	}
+	if totals.Tax > 0 {
+		taxLabel := "PPN " + utils.FormatNumber(totals.TaxRate) + "%"
+		if totals.TaxMode == pricing.TaxInclusive {
+			taxLabel += " (termasuk)"
+		}
+		rows = append(rows,
+			DocumentField{Label: "DPP", Value: utils.FormatRupiah(totals.TaxBase)},
+			DocumentField{Label: taxLabel, Value: utils.FormatRupiah(totals.Tax)},
+		)
+	}
+	return rows
+}
+
+// totals draws the discount and tax breakdown and the grand total in figures and in words, followed by the notes
+func (r *pdfRenderer) totals() {
+	pdf := r.pdf
+	if r.doc.ShowPrices {
+		pdf.SetFont("Helvetica", "", 9)
+		for _, row := range totalsBreakdown(r.doc.Totals) {
+			pdf.CellFormat(contentWidth-30, 6, r.tr(row.Label), "1", 0, "R", false, 0, "")
+			pdf.CellFormat(30, 6, r.tr(row.Value), "1", 1, "R", false, 0, "")
+		}
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.CellFormat(contentWidth-30, 7, "Total", "1", 0, "R", false, 0, "")
+		pdf.CellFormat(30, 7, r.tr(utils.FormatRupiah(r.doc.Totals.Total)), "1", 1, "R", false, 0, "")
+		pdf.Ln(2)
+		pdf.SetFont("Helvetica", "I", 9)
+		pdf.MultiCell(0, lineHeight, r.tr("Terbilang: "+utils.Terbilang(r.doc.Totals.Total)), "", "L", false)
+	}
+
+	if r.doc.Notes != "" {
+		pdf.Ln(2)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.MultiCell(0, lineHeight, r.tr("Catatan: "+r.doc.Notes), "", "L", false)
+	}
+}
+
+// signatures draws the signature blocks side by side, on a new page when they do not fit
+func (r *pdfRenderer) signatures() {
+	if len(r.doc.Signatures) == 0 {
+		return
+	}
+
+	pdf := r.pdf
+	_, pageHeight := pdf.GetPageSize()
+	if pdf.GetY()+40 > pageHeight-footerSpace {
+		pdf.AddPage()
+	}
+
+	pdf.Ln(8)
+	width := contentWidth / float64(len(r.doc.Signatures))
+	top := pdf.GetY()
+	pdf.SetFont("Helvetica", "", 9)
+	for i, signature := range r.doc.Signatures {
+		x := pageMargin + float64(i)*width
+		pdf.SetXY(x, top)
+		pdf.CellFormat(width, lineHeight, r.tr(signature.Label), "", 0, "C", false, 0, "")
+
+		name := "(                              )"
+		if signature.Name != "" {
+			name = "( " + signature.Name + " )"
+		}
+		pdf.SetXY(x, top+25)
+		pdf.CellFormat(width, lineHeight, r.tr(name), "", 0, "C", false, 0, "")
+	}
+	pdf.SetY(top + 30)
+}
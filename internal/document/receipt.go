@@ -0,0 +1,180 @@
+package document
+
+import (
+	"bytes"
+	"sinartimur-go/utils"
+	"strings"
+	"time"
+)
+
+// ESC/POS commands used by the receipt
+var (
+	escInit       = []byte{0x1b, 0x40}
+	escAlign      = []byte{0x1b, 0x61} // followed by 0 left, 1 center, 2 right
+	escBold       = []byte{0x1b, 0x45} // followed by 1 on, 0 off
+	escTextSize   = []byte{0x1d, 0x21} // followed by 0x00 normal, 0x11 double width and height
+	escFeedAndCut = []byte{0x1d, 0x56, 0x42, 0x03}
+)
+
+// receiptColumns returns the characters per line of a paper width in font A
+func receiptColumns(width int) int {
+	if width == ReceiptWidth80 {
+		return 48
+	}
+	return 32
+}
+
+// receiptWriter writes receipt lines as ESC/POS or as plain text
+type receiptWriter struct {
+	buf     bytes.Buffer
+	columns int
+	escpos  bool
+}
+
+// command writes an ESC/POS command, plain text receipts skip it
+func (w *receiptWriter) command(command []byte, args ...byte) {
+	if w.escpos {
+		w.buf.Write(command)
+		w.buf.Write(args)
+	}
+}
+
+// ascii replaces characters a thermal printer code page may not have
+func ascii(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return ' '
+		}
+		if r < 0x20 || r > 0x7e {
+			return '?'
+		}
+		return r
+	}, text)
+}
+
+// line writes a line, centered lines are padded in plain text
+func (w *receiptWriter) line(text string, center bool) {
+	text = ascii(text)
+	if center && !w.escpos && len(text) < w.columns {
+		text = strings.Repeat(" ", (w.columns-len(text))/2) + text
+	}
+	w.buf.WriteString(text + "\n")
+}
+
+// wrapped writes a text wrapped at word boundaries to the line width
+func (w *receiptWriter) wrapped(text string, center bool) {
+	current := ""
+	for _, word := range strings.Fields(text) {
+		for len(word) > w.columns {
+			if current != "" {
+				w.line(current, center)
+				current = ""
+			}
+			w.line(word[:w.columns], center)
+			word = word[w.columns:]
+		}
+		if current == "" {
+			current = word
+		} else if len(current)+1+len(word) <= w.columns {
+			current += " " + word
+		} else {
+			w.line(current, center)
+			current = word
+		}
+	}
+	if current != "" {
+		w.line(current, center)
+	}
+}
+
+// pair writes a label on the left and a value on the right of one line
+func (w *receiptWriter) pair(label, value string) {
+	label, value = ascii(label), ascii(value)
+	space := w.columns - len(label) - len(value)
+	if space < 1 {
+		w.line(label, false)
+		space = w.columns - len(value)
+		label = ""
+	}
+	w.buf.WriteString(label + strings.Repeat(" ", space) + value + "\n")
+}
+
+// separator writes a dashed line
+func (w *receiptWriter) separator() {
+	w.buf.WriteString(strings.Repeat("-", w.columns) + "\n")
+}
+
+// renderReceipt renders a cash sale receipt for a thermal printer of the given paper width
+func renderReceipt(receipt Receipt, company CompanySetting, width int, escpos bool) []byte {
+	w := &receiptWriter{columns: receiptColumns(width), escpos: escpos}
+	w.command(escInit)
+
+	// Letterhead
+	w.command(escAlign, 1)
+	w.command(escBold, 1)
+	w.command(escTextSize, 0x11)
+	if escpos {
+		// Double width halves the characters per line
+		w.columns /= 2
+	}
+	w.wrapped(company.Name, true)
+	w.command(escTextSize, 0x00)
+	w.columns = receiptColumns(width)
+	w.command(escBold, 0)
+	if company.Address != nil && *company.Address != "" {
+		w.wrapped(*company.Address, true)
+	}
+	if company.Telephone != nil && *company.Telephone != "" {
+		w.wrapped("Telp. "+*company.Telephone, true)
+	}
+	w.command(escAlign, 0)
+	w.separator()
+
+	// Sale
+	date := receipt.Date
+	if parsed, err := time.Parse(time.RFC3339, receipt.Date); err == nil {
+		date = parsed.Format("02/01/2006 15:04")
+	}
+	w.pair("No.", receipt.SerialID)
+	w.pair("Tanggal", date)
+	w.pair("Kasir", receipt.Cashier)
+	if receipt.CustomerName != "" {
+		w.pair("Pelanggan", receipt.CustomerName)
+	}
+	w.separator()
+
+	for _, line := range receipt.Lines {
+		w.wrapped(line.Name, false)
+		w.pair("  "+utils.FormatNumber(line.Quantity)+" "+line.Unit+" x "+utils.FormatNumber(line.UnitPrice), utils.FormatNumber(line.Subtotal))
+		if line.Discount > 0 {
+			w.pair("  Diskon", utils.FormatNumber(-line.Discount))
+		}
+	}
+	w.separator()
+
+	// Payment
+	for _, row := range totalsBreakdown(receipt.Totals) {
+		w.pair(row.Label, row.Value)
+	}
+	w.command(escBold, 1)
+	w.pair("TOTAL", utils.FormatRupiah(receipt.Totals.Total))
+	w.command(escBold, 0)
+	w.pair("Tunai", utils.FormatRupiah(receipt.Paid))
+	w.pair("Kembali", utils.FormatRupiah(receipt.Change))
+	w.separator()
+
+	footer := "Terima kasih atas kunjungan Anda"
+	if company.ReceiptFooter != nil && *company.ReceiptFooter != "" {
+		footer = *company.ReceiptFooter
+	}
+	w.command(escAlign, 1)
+	w.wrapped(footer, true)
+	w.command(escAlign, 0)
+
+	if escpos {
+		w.command(escFeedAndCut)
+	} else {
+		w.buf.WriteString("\n\n")
+	}
+	return w.buf.Bytes()
+}
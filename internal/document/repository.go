@@ -0,0 +1,206 @@
+package document
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sinartimur-go/pkg/pricing"
+	"sinartimur-go/utils"
+)
+
+// DocumentRepository defines letterhead and document header operations
+type DocumentRepository interface {
+	GetCompanySetting() (*CompanySetting, error)
+	UpdateCompanySetting(req UpdateCompanySettingRequest) (*CompanySetting, error)
+	GetHeader(documentType, id string) (*DocumentHeader, error)
+	GetLetterhead(documentType, id string) (*Letterhead, error)
+	RecordPrint(documentType, id, userID string, render func(previousPrints int) error) error
+}
+
+// DocumentRepositoryImpl implements DocumentRepository
+type DocumentRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewDocumentRepository creates a new document repository instance
+func NewDocumentRepository(db *sql.DB) DocumentRepository {
+	return &DocumentRepositoryImpl{db: db}
+}
+
+const companySettingColumns = "Name, Address, Telephone, Email, Tax_Number, Footer, Receipt_Width, Receipt_Footer, Tax_Rate, Tax_Mode, Updated_At"
+
+// GetCompanySetting fetches the letterhead, an unset letterhead is returned empty
+func (r *DocumentRepositoryImpl) GetCompanySetting() (*CompanySetting, error) {
+	setting := CompanySetting{ReceiptWidth: ReceiptWidth58, TaxMode: pricing.TaxExclusive}
+	err := r.db.QueryRow("Select "+companySettingColumns+" From Company_Setting Where Id").Scan(
+		&setting.Name, &setting.Address, &setting.Telephone, &setting.Email, &setting.TaxNumber, &setting.Footer,
+		&setting.ReceiptWidth, &setting.ReceiptFooter, &setting.TaxRate, &setting.TaxMode, &setting.UpdatedAt,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("gagal mengambil pengaturan perusahaan: %w", err)
+	}
+	return &setting, nil
+}
+
+// UpdateCompanySetting saves the letterhead
+func (r *DocumentRepositoryImpl) UpdateCompanySetting(req UpdateCompanySettingRequest) (*CompanySetting, error) {
+	var setting CompanySetting
+	err := r.db.QueryRow(`
+		Insert Into Company_Setting (Id, Name, Address, Telephone, Email, Tax_Number, Footer, Receipt_Width, Receipt_Footer,
+			Tax_Rate, Tax_Mode, Updated_At)
+		Values (True, $1, Nullif($2, ''), Nullif($3, ''), Nullif($4, ''), Nullif($5, ''), Nullif($6, ''), $7, Nullif($8, ''),
+			$9, $10, Now())
+		On Conflict (Id) Do Update Set
+			Name = Excluded.Name, Address = Excluded.Address, Telephone = Excluded.Telephone,
+			Email = Excluded.Email, Tax_Number = Excluded.Tax_Number, Footer = Excluded.Footer,
+			Receipt_Width = Excluded.Receipt_Width, Receipt_Footer = Excluded.Receipt_Footer,
+			Tax_Rate = Excluded.Tax_Rate, Tax_Mode = Excluded.Tax_Mode, Updated_At = Excluded.Updated_At
+		Returning `+companySettingColumns,
+		req.Name, req.Address, req.Telephone, req.Email, req.TaxNumber, req.Footer, req.ReceiptWidth, req.ReceiptFooter,
+		req.TaxRate, req.TaxMode,
+	).Scan(
+		&setting.Name, &setting.Address, &setting.Telephone, &setting.Email, &setting.TaxNumber, &setting.Footer,
+		&setting.ReceiptWidth, &setting.ReceiptFooter, &setting.TaxRate, &setting.TaxMode, &setting.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("gagal menyimpan pengaturan perusahaan: %w", err)
+	}
+	return &setting, nil
+}
+
+// headerQueries select a DocumentHeader per document type, in the column order scanned by GetHeader
+var headerQueries = map[string]string{
+	TypeSalesOrder: `
+		Select So.Id, So.Serial_Id, So.Order_Date, So.Id, '', '', Null,
+			B.Id, B.Name, B.Address, B.Telephone, '', '', 0, Null, Au.Username, So.Cancelled_At,
+			So.Subtotal, So.Discount_Amount, So.Tax_Base, So.Tax_Amount, So.Tax_Rate, So.Tax_Mode, So.Total_Amount,
+			So.Amount_Tendered, So.Change_Amount
+		From Sales_Order So
+		Left Join Branch B On B.Id = So.Branch_Id
+		Left Join Appuser Au On Au.Id = So.Created_By
+		Where So.Id = $1`,
+	TypeSalesInvoice: `
+		Select Si.Id, Si.Serial_Id, Si.Invoice_Date, Si.Sales_Order_Id, '', Si.Id, Si.Serial_Id,
+			B.Id, B.Name, B.Address, B.Telephone, '', '', 0, Null, Au.Username, Si.Cancelled_At,
+			Si.Subtotal, Si.Discount_Amount, Si.Tax_Base, Si.Tax_Amount, Si.Tax_Rate, Si.Tax_Mode, Si.Total_Amount,
+			Si.Amount_Tendered, Si.Change_Amount
+		From Sales_Invoice Si
+		Join Sales_Order So On So.Id = Si.Sales_Order_Id
+		Left Join Branch B On B.Id = So.Branch_Id
+		Left Join Appuser Au On Au.Id = Si.Created_By
+		Where Si.Id = $1`,
+	TypeDeliveryNote: `
+		Select Dn.Id, Dn.Serial_Id, Dn.Delivery_Date, Dn.Sales_Order_Id, '', Coalesce(Si.Id::text, ''), Si.Serial_Id,
+			B.Id, B.Name, B.Address, B.Telephone, Dn.Driver_Name, Dn.Recipient_Name, 0, Null, Au.Username, Dn.Cancelled_At,
+			0, 0, 0, 0, 0, 'exclusive', 0, Null, Null
+		From Delivery_Note Dn
+		Join Sales_Order So On So.Id = Dn.Sales_Order_Id
+		Left Join Sales_Invoice Si On Si.Id = Dn.Sales_Invoice_Id
+		Left Join Branch B On B.Id = So.Branch_Id
+		Left Join Appuser Au On Au.Id = Dn.Created_By
+		Where Dn.Id = $1`,
+	TypeSalesReturn: `
+		Select Sor.Id, Sor.Serial_Id, Sor.Returned_At, Sor.Sales_Order_Id, Sor.Sales_Detail_Id, '', Null,
+			B.Id, B.Name, B.Address, B.Telephone, '', '', Sor.Return_Quantity, Sor.Return_Reason, Au.Username, Sor.Cancelled_At,
+			0, 0, 0, 0, 0, 'exclusive', Coalesce(
+				(Select Sir.Amount From Sales_Invoice_Return Sir Where Sir.Id = Sor.Id),
+				Round(Sor.Return_Quantity * Sod.Line_Total / Sod.Quantity, 2)
+			), Null, Null
+		From Sales_Order_Return Sor
+		Join Sales_Order So On So.Id = Sor.Sales_Order_Id
+		Join Sales_Order_Detail Sod On Sod.Id = Sor.Sales_Detail_Id
+		Left Join Branch B On B.Id = So.Branch_Id
+		Left Join Appuser Au On Au.Id = Sor.Returned_By
+		Where Sor.Id = $1`,
+	TypeCreditNote: `
+		Select Cn.Id, Cn.Serial_Id, Cn.Credit_Date, Sor.Sales_Order_Id, Sor.Sales_Detail_Id, '', Null,
+			B.Id, B.Name, B.Address, B.Telephone, '', '', Sor.Return_Quantity, Cn.Notes, Au.Username, Cn.Cancelled_At,
+			0, 0, 0, 0, 0, 'exclusive', Cn.Amount, Null, Null
+		From Credit_Note Cn
+		Join Sales_Order_Return Sor On Sor.Id = Cn.Sales_Order_Return_Id
+		Left Join Branch B On B.Id = Cn.Branch_Id
+		Left Join Appuser Au On Au.Id = Cn.Created_By
+		Where Cn.Id = $1`,
+	TypePurchaseOrder: `
+		Select Po.Id, Po.Serial_Id, Po.Order_Date, '', '', '', Null,
+			B.Id, B.Name, B.Address, B.Telephone, '', '', 0, Null, Au.Username, Po.Cancelled_At,
+			Po.Subtotal, Po.Discount_Amount, Po.Tax_Base, Po.Tax_Amount, Po.Tax_Rate, Po.Tax_Mode, Po.Total_Amount, Null, Null
+		From Purchase_Order Po
+		Left Join Branch B On B.Id = Po.Branch_Id
+		Left Join Appuser Au On Au.Id = Po.Created_By
+		Where Po.Id = $1`,
+}
+
+// GetHeader fetches the stored header of a document
+func (r *DocumentRepositoryImpl) GetHeader(documentType, id string) (*DocumentHeader, error) {
+	query, ok := headerQueries[documentType]
+	if !ok {
+		return nil, fmt.Errorf("jenis dokumen tidak dikenal: %s", documentType)
+	}
+
+	var header DocumentHeader
+	err := r.db.QueryRow(query, id).Scan(
+		&header.ID, &header.SerialID, &header.Date, &header.SalesOrderID, &header.SalesDetailID,
+		&header.SalesInvoiceID, &header.SalesInvoiceSerialID,
+		&header.BranchID, &header.BranchName, &header.BranchAddress, &header.BranchTelephone,
+		&header.DriverName, &header.RecipientName, &header.Quantity, &header.Reason,
+		&header.CreatedByName, &header.CancelledAt,
+		&header.Totals.Subtotal, &header.Totals.Discount, &header.Totals.TaxBase, &header.Totals.Tax,
+		&header.Totals.TaxRate, &header.Totals.TaxMode, &header.Totals.Total,
+		&header.AmountTendered, &header.ChangeAmount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// GetLetterhead fetches the letterhead a document was issued with, sql.ErrNoRows when none was taken.
+// The receipt width is a printer setting and always the current one.
+func (r *DocumentRepositoryImpl) GetLetterhead(documentType, id string) (*Letterhead, error) {
+	letterhead := Letterhead{CompanySetting: CompanySetting{ReceiptWidth: ReceiptWidth58, TaxMode: pricing.TaxExclusive}}
+	err := r.db.QueryRow(`
+		Select Dl.Name, Dl.Address, Dl.Telephone, Dl.Email, Dl.Tax_Number, Dl.Footer, Dl.Receipt_Footer,
+			Dl.Branch_Name, Dl.Branch_Address, Dl.Branch_Telephone, Coalesce(Cs.Receipt_Width, $3)
+		From Document_Letterhead Dl
+		Left Join Company_Setting Cs On Cs.Id
+		Where Dl.Document_Type = $1 And Dl.Document_Id = $2
+	`, documentType, id, ReceiptWidth58).Scan(
+		&letterhead.Name, &letterhead.Address, &letterhead.Telephone, &letterhead.Email, &letterhead.TaxNumber,
+		&letterhead.Footer, &letterhead.ReceiptFooter, &letterhead.BranchName, &letterhead.BranchAddress,
+		&letterhead.BranchTelephone, &letterhead.ReceiptWidth,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &letterhead, nil
+}
+
+// RecordPrint records a print of a document, render gets how often it was printed before.
+// The print is only recorded when render succeeds.
+func (r *DocumentRepositoryImpl) RecordPrint(documentType, id, userID string, render func(previousPrints int) error) error {
+	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		// Serialize prints of the same document so only one of them is the original
+		if _, err := tx.Exec("Select Pg_Advisory_Xact_Lock(Hashtext($1))", documentType+":"+id); err != nil {
+			return fmt.Errorf("gagal mencatat pencetakan dokumen: %w", err)
+		}
+		var previous int
+		if err := tx.QueryRow(`
+			Select Count(*) From Document_Print Where Document_Type = $1 And Document_Id = $2
+		`, documentType, id).Scan(&previous); err != nil {
+			return fmt.Errorf("gagal mencatat pencetakan dokumen: %w", err)
+		}
+
+		if err := render(previous); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+			Insert Into Document_Print (Document_Type, Document_Id, Printed_By)
+			Values ($1, $2, Nullif($3, '')::uuid)
+		`, documentType, id, userID); err != nil {
+			return fmt.Errorf("gagal mencatat pencetakan dokumen: %w", err)
+		}
+		return nil
+	})
+}
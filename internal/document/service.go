@@ -0,0 +1,439 @@
+package document
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sinartimur-go/internal/creditnote"
+	purchase_order "sinartimur-go/internal/purchase/purchase-order"
+	"sinartimur-go/internal/sales"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/pkg/pricing"
+	"sinartimur-go/utils"
+)
+
+// DocumentService is the service for the letterhead and printable documents
+type DocumentService struct {
+	repo              DocumentRepository
+	salesRepo         sales.SalesRepository
+	purchaseOrderRepo purchase_order.Repository
+	creditNoteRepo    creditnote.CreditNoteRepository
+}
+
+// NewDocumentService creates a new instance of DocumentService
+func NewDocumentService(repo DocumentRepository, salesRepo sales.SalesRepository, purchaseOrderRepo purchase_order.Repository, creditNoteRepo creditnote.CreditNoteRepository) *DocumentService {
+	return &DocumentService{repo: repo, salesRepo: salesRepo, purchaseOrderRepo: purchaseOrderRepo, creditNoteRepo: creditNoteRepo}
+}
+
+// documentTitles holds the printed title and not found message per document type
+var documentTitles = map[string][2]string{
+	TypeSalesInvoice:  {"FAKTUR PENJUALAN", "Faktur tidak ditemukan"},
+	TypeDeliveryNote:  {"SURAT JALAN", "Surat jalan tidak ditemukan"},
+	TypePurchaseOrder: {"PESANAN PEMBELIAN", "Pesanan pembelian tidak ditemukan"},
+	TypeSalesReturn:   {"NOTA RETUR PENJUALAN", "Retur penjualan tidak ditemukan"},
+	TypeCreditNote:    {"NOTA KREDIT", "Nota kredit tidak ditemukan"},
+}
+
+// GetCompanySetting fetches the letterhead
+func (s *DocumentService) GetCompanySetting() (*CompanySetting, *dto.APIError) {
+	setting, err := s.repo.GetCompanySetting()
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil pengaturan perusahaan",
+		})
+	}
+	return setting, nil
+}
+
+// UpdateCompanySetting saves the letterhead
+func (s *DocumentService) UpdateCompanySetting(req UpdateCompanySettingRequest) (*CompanySetting, *dto.APIError) {
+	if req.ReceiptWidth == 0 {
+		req.ReceiptWidth = ReceiptWidth58
+	}
+	if req.TaxMode == "" {
+		req.TaxMode = pricing.TaxExclusive
+	}
+
+	setting, err := s.repo.UpdateCompanySetting(req)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menyimpan pengaturan perusahaan",
+		})
+	}
+	return setting, nil
+}
+
+// RenderPDF renders a stored document as PDF and returns it with its file name.
+// Every rendered print is recorded, prints after the first carry a COPY watermark.
+func (s *DocumentService) RenderPDF(documentType, id, branchID, userID string) ([]byte, string, *dto.APIError) {
+	doc, company, apiErr := s.loadDocument(documentType, id, branchID)
+	if apiErr != nil {
+		return nil, "", apiErr
+	}
+
+	var content []byte
+	var errRender error
+	err := s.repo.RecordPrint(documentType, id, userID, func(previousPrints int) error {
+		content, errRender = renderPDF(*doc, *company, previousPrints > 0)
+		return errRender
+	})
+	if errRender != nil {
+		return nil, "", dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membuat PDF dokumen",
+		})
+	}
+	if err != nil {
+		return nil, "", dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mencatat pencetakan dokumen",
+		})
+	}
+	return content, doc.SerialID + ".pdf", nil
+}
+
+// RenderEmailPDF renders a stored document as PDF for an email attachment.
+// Emails are logged on their own, so this is neither recorded as a print nor watermarked.
+func (s *DocumentService) RenderEmailPDF(documentType, id, branchID string) ([]byte, string, *dto.APIError) {
+	doc, company, apiErr := s.loadDocument(documentType, id, branchID)
+	if apiErr != nil {
+		return nil, "", apiErr
+	}
+
+	content, err := renderPDF(*doc, *company, false)
+	if err != nil {
+		return nil, "", dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membuat PDF dokumen",
+		})
+	}
+	return content, doc.SerialID + ".pdf", nil
+}
+
+// loadDocument builds the printable content of a stored document together with the letterhead it was issued with
+func (s *DocumentService) loadDocument(documentType, id, branchID string) (*Document, *CompanySetting, *dto.APIError) {
+	titles, ok := documentTitles[documentType]
+	if !ok {
+		return nil, nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+			"general": "Jenis dokumen tidak dikenal",
+		})
+	}
+	notFound := dto.NewAPIError(http.StatusNotFound, map[string]string{"general": titles[1]})
+
+	header, err := s.repo.GetHeader(documentType, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, notFound
+		}
+		return nil, nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data dokumen",
+		})
+	}
+
+	// Documents of other branches are hidden from branch users
+	if branchID != "" && (header.BranchID == nil || *header.BranchID != branchID) {
+		return nil, nil, notFound
+	}
+
+	doc := Document{
+		Type:       documentType,
+		Title:      titles[0],
+		SerialID:   header.SerialID,
+		Date:       header.Date,
+		BranchName: header.BranchName,
+		Address:    header.BranchAddress,
+		Telephone:  header.BranchTelephone,
+		Cancelled:  header.CancelledAt != nil,
+		Totals:     header.Totals,
+	}
+
+	if documentType == TypePurchaseOrder {
+		err = s.fillPurchaseOrder(&doc, header)
+	} else {
+		err = s.fillSalesDocument(&doc, header)
+	}
+	if err != nil {
+		return nil, nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data dokumen",
+		})
+	}
+
+	letterhead, err := s.letterhead(documentType, header)
+	if err != nil {
+		return nil, nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil pengaturan perusahaan",
+		})
+	}
+	doc.BranchName, doc.Address, doc.Telephone = letterhead.BranchName, letterhead.BranchAddress, letterhead.BranchTelephone
+	return &doc, &letterhead.CompanySetting, nil
+}
+
+// letterhead returns the company and branch data a document was issued with,
+// documents issued without a letterhead are printed with the current one
+func (s *DocumentService) letterhead(documentType string, header *DocumentHeader) (*Letterhead, error) {
+	letterhead, err := s.repo.GetLetterhead(documentType, header.ID)
+	if err == nil {
+		return letterhead, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	company, err := s.repo.GetCompanySetting()
+	if err != nil {
+		return nil, err
+	}
+	return &Letterhead{
+		CompanySetting:  *company,
+		BranchName:      header.BranchName,
+		BranchAddress:   header.BranchAddress,
+		BranchTelephone: header.BranchTelephone,
+	}, nil
+}
+
+// fillSalesDocument fills an invoice, delivery note, return or credit note from its sales order
+func (s *DocumentService) fillSalesDocument(doc *Document, header *DocumentHeader) error {
+	order, err := s.salesRepo.GetSalesOrderWithDetails(header.SalesOrderID)
+	if err != nil {
+		return err
+	}
+
+	doc.PartyLabel = "Kepada Yth."
+	doc.PartyName = order.CustomerName
+	doc.PartyAddress = order.CustomerAddress
+	doc.PartyPhone = order.CustomerPhone
+	doc.References = []DocumentField{{Label: "No. Pesanan", Value: order.SerialID}}
+
+	createdBy := ""
+	if header.CreatedByName != nil {
+		createdBy = *header.CreatedByName
+	}
+
+	switch doc.Type {
+	case TypeSalesInvoice:
+		// An order can be invoiced in parts, an invoice only lists its invoiced lines
+		invoiceItems, err := s.salesRepo.GetSalesInvoiceItems(header.SalesInvoiceID)
+		if err != nil {
+			return err
+		}
+		doc.ShowPrices = true
+		doc.References = append(doc.References, DocumentField{Label: "Pembayaran", Value: paymentMethodLabel(order.PaymentMethod)})
+		if order.PaymentDueDate != nil {
+			doc.References = append(doc.References, DocumentField{Label: "Jatuh Tempo", Value: formatDate(*order.PaymentDueDate)})
+		}
+		for _, item := range invoiceItems {
+			doc.Lines = append(doc.Lines, DocumentLine{
+				Name: item.ProductName, SKU: item.BatchSKU, Unit: item.ProductUnit, Quantity: item.Quantity,
+				UnitPrice: item.UnitPrice, Discount: item.DiscountAmount, Subtotal: pricing.Round(item.Quantity * item.UnitPrice),
+			})
+		}
+		doc.Signatures = []Signature{{Label: "Penerima"}, {Label: "Hormat Kami", Name: createdBy}}
+
+	case TypeDeliveryNote:
+		if header.SalesInvoiceSerialID != nil {
+			doc.References = append(doc.References, DocumentField{Label: "No. Faktur", Value: *header.SalesInvoiceSerialID})
+		}
+		// A delivery note carries part of its invoice, it only lists its own lines
+		noteItems, err := s.salesRepo.GetDeliveryNoteItems(header.ID)
+		if err != nil {
+			return err
+		}
+		for _, item := range noteItems {
+			doc.Lines = append(doc.Lines, DocumentLine{
+				Name: item.ProductName, SKU: item.BatchSKU, Unit: item.ProductUnit, Quantity: item.Quantity,
+			})
+		}
+		doc.Signatures = []Signature{
+			{Label: "Sopir", Name: header.DriverName},
+			{Label: "Penerima", Name: header.RecipientName},
+			{Label: "Pemeriksa", Name: createdBy},
+		}
+
+	case TypeSalesReturn, TypeCreditNote:
+		// A return is credited at what was charged for its line after discounts and tax
+		for _, item := range order.Items {
+			if item.ID != header.SalesDetailID {
+				continue
+			}
+			doc.ShowPrices = true
+			doc.Lines = append(doc.Lines, DocumentLine{
+				Name: item.ProductName, SKU: item.BatchSKU, Unit: item.ProductUnit, Quantity: header.Quantity,
+				UnitPrice: pricing.Round(header.Totals.Total / header.Quantity), Subtotal: header.Totals.Total,
+			})
+		}
+		if header.Reason != nil {
+			doc.Notes = *header.Reason
+		}
+		doc.Signatures = []Signature{{Label: "Pelanggan"}, {Label: "Pemeriksa", Name: createdBy}}
+		if doc.Type == TypeCreditNote {
+			return s.fillCreditNote(doc, header.ID, createdBy)
+		}
+	}
+	return nil
+}
+
+// fillCreditNote adds the return a credit note was issued for and how it was settled, the invoices it was put
+// against or how the money was paid back
+func (s *DocumentService) fillCreditNote(doc *Document, id, createdBy string) error {
+	creditNote, err := s.creditNoteRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	doc.References = append(doc.References, DocumentField{Label: "No. Retur", Value: creditNote.ReturnSerialID})
+
+	if creditNote.Settlement == creditnote.SettlementRefunded {
+		refund := ""
+		if creditNote.RefundMethod != nil {
+			refund = paymentMethodLabel(*creditNote.RefundMethod)
+		}
+		if creditNote.RefundReference != nil {
+			refund += " " + *creditNote.RefundReference
+		}
+		doc.References = append(doc.References, DocumentField{Label: "Dikembalikan", Value: refund})
+	} else {
+		allocations, err := s.creditNoteRepo.GetAllocations(id)
+		if err != nil {
+			return err
+		}
+		for _, allocation := range allocations {
+			doc.References = append(doc.References, DocumentField{
+				Label: "Potong Faktur", Value: allocation.SalesInvoiceSerial + " " + utils.FormatRupiah(allocation.Amount),
+			})
+		}
+	}
+
+	doc.Signatures = []Signature{{Label: "Pelanggan"}, {Label: "Hormat Kami", Name: createdBy}}
+	return nil
+}
+
+// fillPurchaseOrder fills a purchase order from its details
+func (s *DocumentService) fillPurchaseOrder(doc *Document, header *DocumentHeader) error {
+	order, err := s.purchaseOrderRepo.GetByID(header.ID)
+	if err != nil {
+		return err
+	}
+
+	doc.ShowPrices = true
+	doc.PartyLabel = "Kepada Pemasok"
+	doc.PartyName = order.SupplierName
+	doc.PartyAddress = order.SupplierAddress
+	doc.PartyPhone = order.SupplierPhone
+	doc.References = []DocumentField{{Label: "Pembayaran", Value: paymentMethodLabel(order.PaymentMethod)}}
+	if order.PaymentDueDate != nil {
+		doc.References = append(doc.References, DocumentField{Label: "Jatuh Tempo", Value: formatDate(*order.PaymentDueDate)})
+	}
+
+	for _, item := range order.Items {
+		doc.Lines = append(doc.Lines, DocumentLine{
+			Name: item.ProductName, Quantity: item.Quantity, UnitPrice: item.Price,
+			Discount: item.DiscountAmount, Subtotal: pricing.Round(item.Quantity * item.Price),
+		})
+	}
+
+	checkedBy := ""
+	if order.CheckedByName != nil {
+		checkedBy = *order.CheckedByName
+	}
+	doc.Signatures = []Signature{
+		{Label: "Dibuat Oleh", Name: order.CreatedByName},
+		{Label: "Diperiksa Oleh", Name: checkedBy},
+		{Label: "Pemasok"},
+	}
+	return nil
+}
+
+// paymentMethodLabel shows a payment method in Indonesian
+func paymentMethodLabel(method string) string {
+	switch method {
+	case "cash":
+		return "Tunai"
+	case "paylater":
+		return "Tempo"
+	case "transfer":
+		return "Transfer"
+	default:
+		return method
+	}
+}
+
+// RenderReceipt renders the thermal receipt of a cash sale from its sales order or its invoice
+func (s *DocumentService) RenderReceipt(source, id string, req GetReceiptRequest) ([]byte, *dto.APIError) {
+	notFound := dto.NewAPIError(http.StatusNotFound, map[string]string{"general": "Pesanan penjualan tidak ditemukan"})
+	if source == TypeSalesInvoice {
+		notFound = dto.NewAPIError(http.StatusNotFound, map[string]string{"general": "Faktur tidak ditemukan"})
+	}
+
+	header, err := s.repo.GetHeader(source, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, notFound
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data dokumen",
+		})
+	}
+	if req.BranchID != "" && (header.BranchID == nil || *header.BranchID != req.BranchID) {
+		return nil, notFound
+	}
+	if header.CancelledAt != nil {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Penjualan sudah dibatalkan",
+		})
+	}
+
+	order, err := s.salesRepo.GetSalesOrderWithDetails(header.SalesOrderID)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data dokumen",
+		})
+	}
+	if order.PaymentMethod != "cash" {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Struk hanya tersedia untuk penjualan tunai",
+		})
+	}
+
+	receipt := Receipt{SerialID: header.SerialID, Date: header.Date, CustomerName: order.CustomerName, Totals: header.Totals}
+	if header.CreatedByName != nil {
+		receipt.Cashier = *header.CreatedByName
+	}
+	if source == TypeSalesInvoice {
+		// The receipt of an invoice lists only the lines it covers
+		items, errItems := s.salesRepo.GetSalesInvoiceItems(header.ID)
+		if errItems != nil {
+			return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+				"general": "Gagal mengambil data dokumen",
+			})
+		}
+		for _, item := range items {
+			receipt.Lines = append(receipt.Lines, DocumentLine{
+				Name: item.ProductName, SKU: item.BatchSKU, Unit: item.ProductUnit, Quantity: item.Quantity,
+				UnitPrice: item.UnitPrice, Discount: item.DiscountAmount, Subtotal: pricing.Round(item.Quantity * item.UnitPrice),
+			})
+		}
+	} else {
+		for _, item := range order.Items {
+			receipt.Lines = append(receipt.Lines, DocumentLine{
+				Name: item.ProductName, SKU: item.BatchSKU, Unit: item.ProductUnit, Quantity: item.Quantity,
+				UnitPrice: item.UnitPrice, Discount: item.DiscountAmount, Subtotal: pricing.Round(item.Quantity * item.UnitPrice),
+			})
+		}
+	}
+
+	// A sale recorded without the cash handed over is taken as paid exactly
+	receipt.Paid = receipt.Totals.Total
+	if header.AmountTendered != nil && header.ChangeAmount != nil {
+		receipt.Paid = *header.AmountTendered
+		receipt.Change = *header.ChangeAmount
+	}
+
+	letterhead, err := s.letterhead(source, header)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil pengaturan perusahaan",
+		})
+	}
+	width := req.Width
+	if width == 0 {
+		width = letterhead.ReceiptWidth
+	}
+
+	return renderReceipt(receipt, letterhead.CompanySetting, width, req.Format != ReceiptFormatText), nil
+}
@@ -0,0 +1,69 @@
+package email
+
+// Email statuses
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// SendDocumentRequest holds the recipients of a document email, an empty To sends to the customer or supplier
+type SendDocumentRequest struct {
+	To      string `json:"to" validate:"omitempty,email,max=255"`
+	Cc      string `json:"cc" validate:"omitempty,email,max=255"`
+	Message string `json:"message" validate:"omitempty,max=2000"` // Added to the templated body
+}
+
+// DocumentEmail is a queued or sent email of a document, the send log lists these
+type DocumentEmail struct {
+	ID             string  `json:"id"`
+	DocumentType   string  `json:"document_type"`
+	DocumentID     string  `json:"document_id"`
+	Recipient      string  `json:"recipient"`
+	Cc             *string `json:"cc,omitempty"`
+	Subject        string  `json:"subject"`
+	Body           string  `json:"body"`
+	AttachmentName string  `json:"attachment_name"`
+	Status         string  `json:"status"` // pending, sent, failed
+	Attempts       int     `json:"attempts"`
+	NextAttemptAt  *string `json:"next_attempt_at,omitempty"`
+	LastError      *string `json:"last_error,omitempty"`
+	SentBy         *string `json:"sent_by,omitempty"`
+	SentByName     *string `json:"sent_by_name,omitempty"`
+	SentAt         *string `json:"sent_at,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+// documentRecipient is the customer or supplier a document is addressed to
+type documentRecipient struct {
+	SerialID    string
+	PartyName   string
+	Email       *string
+	BranchID    *string
+	CancelledAt *string
+}
+
+// newEmail holds a rendered email to queue
+type newEmail struct {
+	DocumentType   string
+	DocumentID     string
+	Recipient      string
+	Cc             string
+	Subject        string
+	Body           string
+	AttachmentName string
+	Attachment     []byte
+	SentBy         string
+}
+
+// pendingEmail is a claimed email due to be sent
+type pendingEmail struct {
+	ID             string
+	Recipient      string
+	Cc             *string
+	Subject        string
+	Body           string
+	AttachmentName string
+	Attachment     []byte
+	Attempts       int
+}
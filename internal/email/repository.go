@@ -0,0 +1,188 @@
+package email
+
+import (
+	"database/sql"
+	"fmt"
+	"sinartimur-go/internal/document"
+	"time"
+)
+
+// EmailRepository defines the interface for document email operations
+type EmailRepository interface {
+	GetRecipient(documentType, id string) (*documentRecipient, error)
+	CreateEmail(email newEmail) (*DocumentEmail, error)
+	ClaimDueEmails(limit int, lease time.Duration) ([]pendingEmail, error)
+	RecordAttempt(id string, sendErr string, nextAttemptAt *time.Time) error
+	GetEmails(documentType, documentID string) ([]DocumentEmail, error)
+}
+
+// EmailRepositoryImpl implements the EmailRepository interface
+type EmailRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewEmailRepository creates a new email repository instance
+func NewEmailRepository(db *sql.DB) EmailRepository {
+	return &EmailRepositoryImpl{db: db}
+}
+
+// recipientQueries select a documentRecipient per document type
+var recipientQueries = map[string]string{
+	document.TypeSalesInvoice: `
+		Select Si.Serial_Id, Coalesce(C.Name, ''), C.Email, So.Branch_Id, Si.Cancelled_At
+		From Sales_Invoice Si
+		Join Sales_Order So On So.Id = Si.Sales_Order_Id
+		Left Join Customer C On C.Id = So.Customer_Id
+		Where Si.Id = $1`,
+	document.TypePurchaseOrder: `
+		Select Po.Serial_Id, Coalesce(S.Name, ''), S.Email, Po.Branch_Id, Po.Cancelled_At
+		From Purchase_Order Po
+		Left Join Supplier S On S.Id = Po.Supplier_Id
+		Where Po.Id = $1`,
+}
+
+// GetRecipient fetches the customer or supplier a document is addressed to
+func (r *EmailRepositoryImpl) GetRecipient(documentType, id string) (*documentRecipient, error) {
+	query, ok := recipientQueries[documentType]
+	if !ok {
+		return nil, fmt.Errorf("jenis dokumen tidak dapat dikirim: %s", documentType)
+	}
+
+	var recipient documentRecipient
+	err := r.db.QueryRow(query, id).Scan(
+		&recipient.SerialID, &recipient.PartyName, &recipient.Email, &recipient.BranchID, &recipient.CancelledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &recipient, nil
+}
+
+const emailColumns = `E.Id, E.Document_Type, E.Document_Id, E.Recipient, E.Cc, E.Subject, E.Body, E.Attachment_Name,
+	E.Status, E.Attempts, E.Next_Attempt_At, E.Last_Error, E.Sent_By, Au.Username, E.Sent_At, E.Created_At`
+
+// scanEmail scans a row selected with emailColumns
+func scanEmail(row interface{ Scan(...interface{}) error }) (*DocumentEmail, error) {
+	var e DocumentEmail
+	var nextAttemptAt, sentAt sql.NullTime
+	var createdAt time.Time
+
+	err := row.Scan(&e.ID, &e.DocumentType, &e.DocumentID, &e.Recipient, &e.Cc, &e.Subject, &e.Body, &e.AttachmentName,
+		&e.Status, &e.Attempts, &nextAttemptAt, &e.LastError, &e.SentBy, &e.SentByName, &sentAt, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if nextAttemptAt.Valid {
+		formatted := nextAttemptAt.Time.Format(time.RFC3339)
+		e.NextAttemptAt = &formatted
+	}
+	if sentAt.Valid {
+		formatted := sentAt.Time.Format(time.RFC3339)
+		e.SentAt = &formatted
+	}
+	e.CreatedAt = createdAt.Format(time.RFC3339)
+
+	return &e, nil
+}
+
+// CreateEmail queues a rendered email
+func (r *EmailRepositoryImpl) CreateEmail(email newEmail) (*DocumentEmail, error) {
+	row := r.db.QueryRow(`
+		With Inserted As (
+			Insert Into Document_Email (Document_Type, Document_Id, Recipient, Cc, Subject, Body, Attachment_Name, Attachment, Sent_By)
+			Values ($1, $2, $3, Nullif($4, ''), $5, $6, $7, $8, Nullif($9, '')::uuid)
+			Returning *
+		)
+		Select `+emailColumns+`
+		From Inserted E
+		Left Join Appuser Au On Au.Id = E.Sent_By`,
+		email.DocumentType, email.DocumentID, email.Recipient, email.Cc, email.Subject, email.Body,
+		email.AttachmentName, email.Attachment, email.SentBy)
+
+	created, err := scanEmail(row)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat antrian email: %w", err)
+	}
+	return created, nil
+}
+
+// ClaimDueEmails locks due emails for the lease duration so concurrent workers skip them
+func (r *EmailRepositoryImpl) ClaimDueEmails(limit int, lease time.Duration) ([]pendingEmail, error) {
+	rows, err := r.db.Query(`
+		With Due As (
+			Select Id From Document_Email
+			Where Status = 'pending' And Next_Attempt_At <= Now()
+			Order By Next_Attempt_At
+			Limit $1
+			For Update Skip Locked
+		)
+		Update Document_Email E
+		Set Next_Attempt_At = Now() + Make_Interval(Secs => $2), Updated_At = Now()
+		From Due
+		Where E.Id = Due.Id
+		Returning E.Id, E.Recipient, E.Cc, E.Subject, E.Body, E.Attachment_Name, E.Attachment, E.Attempts`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil antrian email: %w", err)
+	}
+	defer rows.Close()
+
+	var emails []pendingEmail
+	for rows.Next() {
+		var e pendingEmail
+		if errScan := rows.Scan(&e.ID, &e.Recipient, &e.Cc, &e.Subject, &e.Body, &e.AttachmentName, &e.Attachment, &e.Attempts); errScan != nil {
+			return nil, errScan
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+// RecordAttempt stores the outcome of a send, an empty sendErr marks the email as sent
+// and a failure without nextAttemptAt marks it as failed
+func (r *EmailRepositoryImpl) RecordAttempt(id string, sendErr string, nextAttemptAt *time.Time) error {
+	status := StatusPending
+	if sendErr == "" {
+		status = StatusSent
+	} else if nextAttemptAt == nil {
+		status = StatusFailed
+	}
+
+	_, err := r.db.Exec(`
+		Update Document_Email
+		Set Status = $1,
+		    Attempts = Attempts + 1,
+		    Next_Attempt_At = $2,
+		    Last_Error = Nullif($3, ''),
+		    Sent_At = Case When $1 = 'sent' Then Now() Else Sent_At End,
+		    Updated_At = Now()
+		Where Id = $4`,
+		status, nextAttemptAt, sendErr, id)
+	return err
+}
+
+// GetEmails fetches the send log of a document, newest first
+func (r *EmailRepositoryImpl) GetEmails(documentType, documentID string) ([]DocumentEmail, error) {
+	rows, err := r.db.Query(`
+		Select `+emailColumns+`
+		From Document_Email E
+		Left Join Appuser Au On Au.Id = E.Sent_By
+		Where E.Document_Type = $1 And E.Document_Id = $2
+		Order By E.Created_At Desc`,
+		documentType, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil log email: %w", err)
+	}
+	defer rows.Close()
+
+	emails := []DocumentEmail{}
+	for rows.Next() {
+		e, errScan := scanEmail(rows)
+		if errScan != nil {
+			return nil, fmt.Errorf("gagal membaca log email: %w", errScan)
+		}
+		emails = append(emails, *e)
+	}
+	return emails, rows.Err()
+}
@@ -0,0 +1,296 @@
+package email
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"sinartimur-go/internal/document"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/pkg/mailer"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// maxSendAttempts is the number of attempts before an email is marked as failed
+	maxSendAttempts = 5
+	// baseRetryDelay is doubled after every failed attempt, up to maxRetryDelay
+	baseRetryDelay = time.Minute
+	maxRetryDelay  = time.Hour
+	// pollInterval is how often the sender looks for due emails
+	pollInterval = 15 * time.Second
+	// claimLease hides a claimed email from other workers while it is being sent
+	claimLease = 2 * time.Minute
+	// claimBatchSize is the number of emails sent per pass
+	claimBatchSize = 10
+)
+
+// documentTemplate is the subject and body of the email of a document type
+type documentTemplate struct {
+	Party   string // What the recipient is called in error messages
+	Subject *template.Template
+	Body    *template.Template
+}
+
+// templateData is passed to the subject and body templates
+type templateData struct {
+	SerialID    string
+	PartyName   string
+	CompanyName string
+	Message     string
+}
+
+// documentTemplates holds the email templates of the document types that can be sent
+var documentTemplates = map[string]documentTemplate{
+	document.TypeSalesInvoice: {
+		Party:   "Pelanggan",
+		Subject: template.Must(template.New("subject").Parse(`Faktur {{.SerialID}}{{with .CompanyName}} - {{.}}{{end}}`)),
+		Body: template.Must(template.New("body").Parse(`Yth. {{.PartyName}},
+
+Terlampir faktur penjualan {{.SerialID}}{{with .CompanyName}} dari {{.}}{{end}}.
+{{with .Message}}
+{{.}}
+{{end}}
+Terima kasih atas kepercayaan Anda.
+
+Hormat kami,
+{{.CompanyName}}
+`)),
+	},
+	document.TypePurchaseOrder: {
+		Party:   "Pemasok",
+		Subject: template.Must(template.New("subject").Parse(`Pesanan Pembelian {{.SerialID}}{{with .CompanyName}} - {{.}}{{end}}`)),
+		Body: template.Must(template.New("body").Parse(`Yth. {{.PartyName}},
+
+Terlampir pesanan pembelian {{.SerialID}}. Mohon konfirmasi ketersediaan barang dan jadwal pengirimannya.
+{{with .Message}}
+{{.}}
+{{end}}
+Terima kasih atas kerja samanya.
+
+Hormat kami,
+{{.CompanyName}}
+`)),
+	},
+}
+
+// EmailService is the service for emailing documents
+type EmailService struct {
+	repo            EmailRepository
+	documentService *document.DocumentService
+	mailer          mailer.Mailer
+	notify          chan struct{}
+}
+
+// NewEmailService creates a new instance of EmailService, a nil mailer disables sending
+func NewEmailService(repo EmailRepository, documentService *document.DocumentService, sender mailer.Mailer) *EmailService {
+	return &EmailService{
+		repo:            repo,
+		documentService: documentService,
+		mailer:          sender,
+		notify:          make(chan struct{}, 1),
+	}
+}
+
+// getRecipient fetches the recipient of a document, hiding documents of other branches from branch users
+func (s *EmailService) getRecipient(documentType, id, branchID string) (*documentRecipient, *dto.APIError) {
+	notFound := dto.NewAPIError(http.StatusNotFound, map[string]string{"general": "Dokumen tidak ditemukan"})
+	if _, ok := documentTemplates[documentType]; !ok {
+		return nil, notFound
+	}
+
+	recipient, err := s.repo.GetRecipient(documentType, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, notFound
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data dokumen",
+		})
+	}
+	if branchID != "" && (recipient.BranchID == nil || *recipient.BranchID != branchID) {
+		return nil, notFound
+	}
+	return recipient, nil
+}
+
+// SendDocument renders a document as PDF and queues it for sending to its customer or supplier
+func (s *EmailService) SendDocument(documentType, id string, req SendDocumentRequest, branchID, userID string) (*DocumentEmail, *dto.APIError) {
+	if s.mailer == nil {
+		return nil, dto.NewAPIError(http.StatusServiceUnavailable, map[string]string{
+			"general": "Pengiriman email belum dikonfigurasi",
+		})
+	}
+
+	recipient, apiErr := s.getRecipient(documentType, id, branchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if recipient.CancelledAt != nil {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Dokumen yang dibatalkan tidak dapat dikirim",
+		})
+	}
+
+	tmpl := documentTemplates[documentType]
+	to := req.To
+	if to == "" {
+		if recipient.Email == nil || *recipient.Email == "" {
+			return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"to": tmpl.Party + " belum memiliki email, isi alamat tujuan",
+			})
+		}
+		to = *recipient.Email
+	}
+
+	company, apiErr := s.documentService.GetCompanySetting()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	data := templateData{
+		SerialID:    recipient.SerialID,
+		PartyName:   recipient.PartyName,
+		CompanyName: company.Name,
+		Message:     strings.TrimSpace(req.Message),
+	}
+	var subject, body bytes.Buffer
+	if err := tmpl.Subject.Execute(&subject, data); err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membuat isi email",
+		})
+	}
+	if err := tmpl.Body.Execute(&body, data); err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membuat isi email",
+		})
+	}
+
+	// The attachment is rendered once so every retry sends the same file
+	content, fileName, apiErr := s.documentService.RenderEmailPDF(documentType, id, branchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	queued, err := s.repo.CreateEmail(newEmail{
+		DocumentType:   documentType,
+		DocumentID:     id,
+		Recipient:      to,
+		Cc:             req.Cc,
+		Subject:        subject.String(),
+		Body:           body.String(),
+		AttachmentName: fileName,
+		Attachment:     content,
+		SentBy:         userID,
+	})
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengirim email",
+		})
+	}
+
+	s.wake()
+	return queued, nil
+}
+
+// GetDocumentEmails fetches the send log of a document
+func (s *EmailService) GetDocumentEmails(documentType, id, branchID string) ([]DocumentEmail, *dto.APIError) {
+	if _, apiErr := s.getRecipient(documentType, id, branchID); apiErr != nil {
+		return nil, apiErr
+	}
+
+	emails, err := s.repo.GetEmails(documentType, id)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil log email",
+		})
+	}
+	return emails, nil
+}
+
+// wake nudges the sender without blocking
+func (s *EmailService) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the sender in the background, nothing is sent without a mailer
+func (s *EmailService) Start() {
+	if s.mailer == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.sendDue()
+
+			select {
+			case <-ticker.C:
+			case <-s.notify:
+			}
+		}
+	}()
+}
+
+// sendDue sends every email that is due
+func (s *EmailService) sendDue() {
+	for {
+		emails, err := s.repo.ClaimDueEmails(claimBatchSize, claimLease)
+		if err != nil {
+			log.Printf("email: %v", err)
+			return
+		}
+
+		for _, e := range emails {
+			msg := mailer.Message{
+				To:      []string{e.Recipient},
+				Subject: e.Subject,
+				Body:    e.Body,
+				Attachments: []mailer.Attachment{
+					{Name: e.AttachmentName, ContentType: "application/pdf", Content: e.Attachment},
+				},
+			}
+			if e.Cc != nil {
+				msg.Cc = []string{*e.Cc}
+			}
+
+			// Schedule a retry with exponential backoff unless the attempts are exhausted
+			sendErr := ""
+			var nextAttemptAt *time.Time
+			if err := s.mailer.Send(msg); err != nil {
+				sendErr = err.Error()
+				if e.Attempts+1 < maxSendAttempts {
+					next := time.Now().Add(retryDelay(e.Attempts))
+					nextAttemptAt = &next
+				}
+			}
+
+			if err := s.repo.RecordAttempt(e.ID, sendErr, nextAttemptAt); err != nil {
+				log.Printf("email: failed to record email %s: %v", e.ID, err)
+			}
+		}
+
+		if len(emails) < claimBatchSize {
+			return
+		}
+	}
+}
+
+// retryDelay returns the wait before the next attempt after the given number of previous attempts
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 0; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
@@ -93,7 +93,7 @@ func (r *employeeRepositoryImpl) GetAll(req GetAllEmployeeRequest) ([]GetEmploye
 	}
 
 	// Add pagination
-	queryBuilder.AddPagination(req.PageSize, req.Page)
+	queryBuilder.AddPagination(req.PaginationParameter)
 
 	// Execute final query
 	query, params := queryBuilder.Build()
@@ -119,6 +119,12 @@ func (r *employeeRepositoryImpl) GetAll(req GetAllEmployeeRequest) ([]GetEmploye
 		if err != nil {
 			return nil, 0, fmt.Errorf("gagal membaca data karyawan: %w", err)
 		}
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(employee); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		employees = append(employees, employee)
 	}
 
@@ -31,6 +31,7 @@ type GetFinanceTransactionRequest struct {
 	IsSystem        *bool  `json:"is_system,omitempty"`
 	StartDate       string `json:"start_date,omitempty"`
 	EndDate         string `json:"end_date,omitempty"`
+	BranchID        string `json:"-"`
 	// Add pagination fields
 	utils.PaginationParameter
 }
@@ -43,6 +44,7 @@ type CreateFinanceTransactionRequest struct {
 	SalesOrderID    string  `json:"sales_order_id,omitempty" validate:"omitempty,uuid"`
 	Description     string  `json:"description" validate:"required"`
 	TransactionDate string  `json:"transaction_date" validate:"required,rfc3339"`
+	BranchID        string  `json:"-"`
 }
 
 // CancelFinanceTransactionRequest defines fields required to cancel a finance transaction
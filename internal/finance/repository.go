@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sinartimur-go/internal/outbox"
+	"sinartimur-go/pkg/event"
 	"sinartimur-go/utils"
 	"time"
 )
@@ -14,7 +16,7 @@ type FinanceTransactionRepository interface {
 	GetAll(req GetFinanceTransactionRequest) ([]GetFinanceTransactionResponse, int, error)
 	GetByID(id string) (*GetFinanceTransactionResponse, error)
 	Cancel(req CancelFinanceTransactionRequest, userID string) error
-	GetSummary(startDate, endDate time.Time) (*FinanceTransactionSummary, error)
+	GetSummary(startDate, endDate time.Time, branchID string) (*FinanceTransactionSummary, error)
 	RefreshFinanceTransactionView() error
 	GetFinanceTransactionViewLastRefreshed() (*time.Time, error)
 }
@@ -32,8 +34,8 @@ func NewFinanceTransactionRepository(db *sql.DB) FinanceTransactionRepository {
 func (r *financeTransactionRepositoryImpl) Create(req CreateFinanceTransactionRequest, userID string) error {
 	query := `
 		INSERT INTO Financial_Transaction_Log 
-		(User_Id, Amount, Type, Purchase_Order_Id, Sales_Order_Id, Description, Is_System, Transaction_Date) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		(User_Id, Amount, Type, Purchase_Order_Id, Sales_Order_Id, Description, Is_System, Transaction_Date, Branch_Id) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, '')::uuid)
 	`
 
 	// Prepare parameters
@@ -58,6 +60,7 @@ func (r *financeTransactionRepositoryImpl) Create(req CreateFinanceTransactionRe
 		req.Description,
 		isSystem,
 		req.TransactionDate,
+		req.BranchID,
 	)
 
 	if err != nil {
@@ -112,6 +115,10 @@ func (r *financeTransactionRepositoryImpl) GetAll(req GetFinanceTransactionReque
 		queryBuilder.AddFilter("Is_System =", *req.IsSystem)
 	}
 
+	if req.BranchID != "" {
+		queryBuilder.AddFilter("Branch_Id =", req.BranchID)
+	}
+
 	// Date range filters
 	if req.StartDate != "" {
 		queryBuilder.AddFilter("Transaction_Date >=", req.StartDate)
@@ -145,7 +152,7 @@ func (r *financeTransactionRepositoryImpl) GetAll(req GetFinanceTransactionReque
 	}
 
 	// Add pagination
-	queryBuilder.AddPagination(req.PageSize, req.Page)
+	queryBuilder.AddPagination(req.PaginationParameter)
 
 	// Execute final query
 	query, params := queryBuilder.Build()
@@ -188,6 +195,12 @@ func (r *financeTransactionRepositoryImpl) GetAll(req GetFinanceTransactionReque
 			tx.SalesOrderID = salesOrderID.String
 		}
 
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(tx); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		transactions = append(transactions, tx)
 	}
 
@@ -292,21 +305,24 @@ func (r *financeTransactionRepositoryImpl) Cancel(req CancelFinanceTransactionRe
 }
 
 // GetSummary retrieves a summary of financial transactions within a date range
-func (r *financeTransactionRepositoryImpl) GetSummary(startDate, endDate time.Time) (*FinanceTransactionSummary, error) {
+func (r *financeTransactionRepositoryImpl) GetSummary(startDate, endDate time.Time, branchID string) (*FinanceTransactionSummary, error) {
 	query := `
 		SELECT
-			COALESCE(SUM(CASE WHEN Type IN ('debit') THEN Amount ELSE 0 END), 0) AS total_income,
-			COALESCE(SUM(CASE WHEN Type IN ('credit') THEN Amount ELSE 0 END), 0) AS total_expense
+			-- Customer payments settle sales already booked as income when invoiced
+			COALESCE(SUM(CASE WHEN Type IN ('debit') AND Customer_Payment_Id IS NULL THEN Amount ELSE 0 END), 0) AS total_income,
+			-- Credit note refunds pay back returns already booked as expense when returned
+			COALESCE(SUM(CASE WHEN Type IN ('credit') AND Credit_Note_Id IS NULL THEN Amount ELSE 0 END), 0) AS total_expense
 		FROM
 			Financial_Transaction_Log
 		WHERE
 			Deleted_At IS NULL
 			AND Transaction_Date BETWEEN $1 AND $2
+			AND ($3 = '' OR Branch_Id::text = $3)
 	`
 
 	var summary FinanceTransactionSummary
 
-	err := r.db.QueryRow(query, startDate, endDate).Scan(
+	err := r.db.QueryRow(query, startDate, endDate, branchID).Scan(
 		&summary.TotalIncome,
 		&summary.TotalExpense,
 	)
@@ -349,6 +365,12 @@ func (r *financeTransactionRepositoryImpl) RefreshFinanceTransactionView() error
 		return err
 	}
 
+	// Record event in outbox
+	err = outbox.Record(tx, event.ViewRefreshed, map[string]string{"view_name": "finance_transaction_log_view"})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
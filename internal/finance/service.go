@@ -120,8 +120,8 @@ func (s *FinanceService) CancelFinanceTransaction(req CancelFinanceTransactionRe
 	return nil
 }
 
-// GetFinanceTransactionSummary retrieves financial summary for a date range
-func (s *FinanceService) GetFinanceTransactionSummary(startDate, endDate time.Time) (*FinanceTransactionSummary, *dto.APIError) {
+// GetFinanceTransactionSummary retrieves financial summary for a date range, an empty branch covers every branch
+func (s *FinanceService) GetFinanceTransactionSummary(startDate, endDate time.Time, branchID string) (*FinanceTransactionSummary, *dto.APIError) {
 	// Validate date range
 	if startDate.IsZero() || endDate.IsZero() {
 		return nil, dto.NewAPIError(400, map[string]string{
@@ -136,7 +136,7 @@ func (s *FinanceService) GetFinanceTransactionSummary(startDate, endDate time.Ti
 	}
 
 	// Get summary from repository
-	summary, err := s.repo.GetSummary(startDate, endDate)
+	summary, err := s.repo.GetSummary(startDate, endDate, branchID)
 	if err != nil {
 		return nil, dto.NewAPIError(500, map[string]string{
 			"general": "Gagal mengambil log transaksi: " + err.Error(),
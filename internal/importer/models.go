@@ -0,0 +1,113 @@
+package importer
+
+import "sinartimur-go/utils"
+
+// Resources that can be imported from a spreadsheet
+const (
+	ResourceProduct      = "product"
+	ResourceCustomer     = "customer"
+	ResourceSupplier     = "supplier"
+	ResourceEmployee     = "employee"
+	ResourceOpeningStock = "opening_stock"
+)
+
+// Import job statuses
+const (
+	StatusValidated = "validated" // dry run passed, ready to commit
+	StatusInvalid   = "invalid"   // dry run found row errors
+	StatusCommitted = "committed"
+	StatusFailed    = "failed" // commit was rolled back
+)
+
+// ImportJob is an uploaded file with its validation and commit result
+type ImportJob struct {
+	ID          string     `json:"id"`
+	Resource    string     `json:"resource"`
+	FileName    string     `json:"file_name"`
+	Status      string     `json:"status"`
+	BranchID    *string    `json:"branch_id"`
+	TotalRows   int        `json:"total_rows"`
+	ValidRows   int        `json:"valid_rows"`
+	ErrorRows   int        `json:"error_rows"`
+	Errors      []RowError `json:"errors"`
+	CreatedBy   *string    `json:"created_by"`
+	CreatedAt   string     `json:"created_at"`
+	CommittedAt *string    `json:"committed_at"`
+}
+
+// RowError is a validation error of a single cell, Row is the spreadsheet row number
+type RowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ImportData is the uploaded sheet, kept with the job until it is committed
+type ImportData struct {
+	Header []string   `json:"header"`
+	Rows   [][]string `json:"rows"`
+}
+
+// CreateImportRequest holds an uploaded file to validate and optionally commit
+type CreateImportRequest struct {
+	Resource string `json:"resource" validate:"required,oneof=product customer supplier employee opening_stock"`
+	FileName string `json:"file_name" validate:"required"`
+	DryRun   bool   `json:"dry_run"`
+	Rows     [][]string
+	BranchID string
+	UserID   string
+}
+
+// GetImportJobsRequest holds query parameters for listing import jobs
+type GetImportJobsRequest struct {
+	Resource string `json:"resource" validate:"omitempty,oneof=product customer supplier employee opening_stock"`
+	Status   string `json:"status" validate:"omitempty,oneof=validated invalid committed failed"`
+	utils.PaginationParameter
+}
+
+// ProductRow is a product line, category and unit are resolved by name
+type ProductRow struct {
+	Name        string `json:"name" validate:"required,max=255"`
+	Description string `json:"description" validate:"omitempty"`
+	Category    string `json:"category" validate:"required"`
+	Unit        string `json:"unit" validate:"required"`
+}
+
+// CustomerRow is a customer line
+type CustomerRow struct {
+	Name      string `json:"name" validate:"required,min=2,max=255"`
+	Address   string `json:"address" validate:"omitempty,max=1000"`
+	Telephone string `json:"telephone" validate:"omitempty,max=50"`
+	Email     string `json:"email" validate:"omitempty,email,max=255"`
+}
+
+// SupplierRow is a supplier line
+type SupplierRow struct {
+	Name      string `json:"name" validate:"required"`
+	Address   string `json:"address" validate:"omitempty"`
+	Telephone string `json:"telephone" validate:"omitempty"`
+	Email     string `json:"email" validate:"omitempty,email,max=255"`
+}
+
+// EmployeeRow is an employee line, the hired date may also be written as YYYY-MM-DD
+type EmployeeRow struct {
+	Name      string `json:"name" validate:"required"`
+	Phone     string `json:"phone" validate:"required,min=10,max=13"`
+	Nik       string `json:"nik" validate:"required,len=16"`
+	Position  string `json:"position" validate:"required"`
+	HiredDate string `json:"hired_date" validate:"required,rfc3339"`
+}
+
+// OpeningStockRow is the opening quantity of a product in a storage, both resolved by name
+type OpeningStockRow struct {
+	Storage   string  `json:"storage" validate:"required"`
+	Product   string  `json:"product" validate:"required"`
+	Quantity  float64 `json:"quantity" validate:"required,gt=0"`
+	UnitPrice float64 `json:"unit_price" validate:"gte=0"`
+}
+
+// StorageRef is a live storage with its branch
+type StorageRef struct {
+	ID       string
+	BranchID string
+}
@@ -0,0 +1,390 @@
+package importer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sinartimur-go/utils"
+	"strings"
+	"time"
+)
+
+// ImportRepository defines import job and bulk insert operations
+type ImportRepository interface {
+	Create(job ImportJob, data ImportData) (*ImportJob, error)
+	Update(job ImportJob) error
+	GetByID(id string) (*ImportJob, *ImportData, error)
+	GetAll(req GetImportJobsRequest) ([]ImportJob, int, error)
+	GetLookup(table, column string) (map[string]string, error)
+	GetStorages(branchID string) (map[string]StorageRef, error)
+	Commit(jobID string, insert func(tx *sql.Tx) error) error
+	InsertProducts(tx *sql.Tx, rows []ProductInsert) error
+	InsertCustomers(tx *sql.Tx, rows []CustomerRow) error
+	InsertSuppliers(tx *sql.Tx, rows []SupplierRow) error
+	InsertEmployees(tx *sql.Tx, rows []EmployeeRow) error
+	InsertOpeningStock(tx *sql.Tx, rows []OpeningStockInsert, userID string) error
+}
+
+// ProductInsert is a validated product line with resolved category and unit
+type ProductInsert struct {
+	Name        string
+	Description string
+	CategoryID  string
+	UnitID      string
+}
+
+// OpeningStockInsert is a validated opening stock line with resolved storage and product
+type OpeningStockInsert struct {
+	StorageID   string
+	BranchID    string
+	ProductID   string
+	ProductName string
+	Quantity    float64
+	UnitPrice   float64
+}
+
+// ImportRepositoryImpl implements ImportRepository
+type ImportRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewImportRepository creates a new import repository instance
+func NewImportRepository(db *sql.DB) ImportRepository {
+	return &ImportRepositoryImpl{db: db}
+}
+
+const importJobColumns = `Id, Resource, File_Name, Status, Branch_Id, Total_Rows, Valid_Rows, Error_Rows, Errors,
+	Created_By, Created_At, Committed_At`
+
+// scanImportJob scans a job row selected with importJobColumns
+func scanImportJob(row interface{ Scan(...interface{}) error }) (*ImportJob, error) {
+	var job ImportJob
+	var errorsJSON []byte
+	var branchID, createdBy sql.NullString
+	var committedAt sql.NullTime
+	if err := row.Scan(&job.ID, &job.Resource, &job.FileName, &job.Status, &branchID, &job.TotalRows,
+		&job.ValidRows, &job.ErrorRows, &errorsJSON, &createdBy, &job.CreatedAt, &committedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(errorsJSON, &job.Errors); err != nil {
+		return nil, fmt.Errorf("gagal membaca laporan impor: %w", err)
+	}
+	if branchID.Valid {
+		job.BranchID = &branchID.String
+	}
+	if createdBy.Valid {
+		job.CreatedBy = &createdBy.String
+	}
+	if committedAt.Valid {
+		formatted := committedAt.Time.Format(time.RFC3339)
+		job.CommittedAt = &formatted
+	}
+	return &job, nil
+}
+
+// Create stores a new import job with its uploaded sheet
+func (r *ImportRepositoryImpl) Create(job ImportJob, data ImportData) (*ImportJob, error) {
+	errorsJSON, err := json.Marshal(job.Errors)
+	if err != nil {
+		return nil, err
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var branchID, createdBy string
+	if job.BranchID != nil {
+		branchID = *job.BranchID
+	}
+	if job.CreatedBy != nil {
+		createdBy = *job.CreatedBy
+	}
+
+	row := r.db.QueryRow(`
+		Insert Into Import_Job (Resource, File_Name, Status, Branch_Id, Total_Rows, Valid_Rows, Error_Rows, Errors, Data, Created_By)
+		Values ($1, $2, $3, Nullif($4, '')::uuid, $5, $6, $7, $8, $9, Nullif($10, '')::uuid)
+		Returning `+importJobColumns,
+		job.Resource, job.FileName, job.Status, branchID, job.TotalRows, job.ValidRows, job.ErrorRows,
+		errorsJSON, dataJSON, createdBy)
+	created, err := scanImportJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("gagal menyimpan pekerjaan impor: %w", err)
+	}
+	return created, nil
+}
+
+// Update stores the status and report of an import job
+func (r *ImportRepositoryImpl) Update(job ImportJob) error {
+	errorsJSON, err := json.Marshal(job.Errors)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`
+		Update Import_Job
+		Set Status = $1, Valid_Rows = $2, Error_Rows = $3, Errors = $4
+		Where Id = $5 And Status <> $6
+	`, job.Status, job.ValidRows, job.ErrorRows, errorsJSON, job.ID, StatusCommitted)
+	if err != nil {
+		return fmt.Errorf("gagal memperbarui pekerjaan impor: %w", err)
+	}
+	return nil
+}
+
+// GetByID fetches an import job with its uploaded sheet
+func (r *ImportRepositoryImpl) GetByID(id string) (*ImportJob, *ImportData, error) {
+	var dataJSON []byte
+	row := r.db.QueryRow("Select "+importJobColumns+", Data From Import_Job Where Id = $1", id)
+	job, err := scanImportJob(scanAppender{row: row, extra: []interface{}{&dataJSON}})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var data ImportData
+	if err = json.Unmarshal(dataJSON, &data); err != nil {
+		return nil, nil, fmt.Errorf("gagal membaca data impor: %w", err)
+	}
+	return job, &data, nil
+}
+
+// scanAppender scans extra columns selected after importJobColumns
+type scanAppender struct {
+	row   interface{ Scan(...interface{}) error }
+	extra []interface{}
+}
+
+func (s scanAppender) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+// GetAll fetches import jobs with pagination, newest first
+func (r *ImportRepositoryImpl) GetAll(req GetImportJobsRequest) ([]ImportJob, int, error) {
+	qb := utils.NewQueryBuilder("Select " + importJobColumns + " From Import_Job Where 1=1")
+	qb.AddFilter("Resource =", req.Resource)
+	qb.AddFilter("Status =", req.Status)
+
+	var totalItems int
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Jobs", qb.Query.String())
+	if err := r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems); err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung pekerjaan impor: %w", err)
+	}
+
+	qb.Query.WriteString(" Order By Created_At Desc")
+	qb.AddPagination(req.PaginationParameter)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil pekerjaan impor: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []ImportJob{}
+	for rows.Next() {
+		job, errScan := scanImportJob(rows)
+		if errScan != nil {
+			return nil, 0, fmt.Errorf("gagal membaca pekerjaan impor: %w", errScan)
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, totalItems, rows.Err()
+}
+
+// lookupKey normalizes a name for case-insensitive lookups
+func lookupKey(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// GetLookup maps the normalized values of a column of live records to their ID
+func (r *ImportRepositoryImpl) GetLookup(table, column string) (map[string]string, error) {
+	rows, err := r.db.Query(fmt.Sprintf("Select Id, %s From %s Where Deleted_At Is Null", column, table))
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil data %s: %w", strings.ToLower(table), err)
+	}
+	defer rows.Close()
+
+	lookup := make(map[string]string)
+	for rows.Next() {
+		var id, value string
+		if err = rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+		lookup[lookupKey(value)] = id
+	}
+	return lookup, rows.Err()
+}
+
+// GetStorages maps the normalized names of live storages to the storage, limited to the branch when one is given
+func (r *ImportRepositoryImpl) GetStorages(branchID string) (map[string]StorageRef, error) {
+	qb := utils.NewQueryBuilder("Select Id, Name, Coalesce(Branch_Id::text, '') From Storage Where Deleted_At Is Null")
+	qb.AddFilter("Branch_Id =", branchID)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil data gudang: %w", err)
+	}
+	defer rows.Close()
+
+	storages := make(map[string]StorageRef)
+	for rows.Next() {
+		var storage StorageRef
+		var name string
+		if err = rows.Scan(&storage.ID, &name, &storage.BranchID); err != nil {
+			return nil, err
+		}
+		storages[lookupKey(name)] = storage
+	}
+	return storages, rows.Err()
+}
+
+// Commit runs the inserts of a job and marks it committed in one transaction. The job is locked first,
+// so a job committed twice at once is imported only once, ErrNotValidated when it is no longer validated.
+func (r *ImportRepositoryImpl) Commit(jobID string, insert func(tx *sql.Tx) error) error {
+	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		var status string
+		if err := tx.QueryRow("Select Status From Import_Job Where Id = $1 For Update", jobID).Scan(&status); err != nil {
+			return fmt.Errorf("gagal mengunci pekerjaan impor: %w", err)
+		}
+		if status != StatusValidated {
+			return ErrNotValidated
+		}
+
+		if err := insert(tx); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec("Update Import_Job Set Status = $1, Committed_At = Now(), Data = '{}' Where Id = $2", StatusCommitted, jobID)
+		return err
+	})
+}
+
+// InsertProducts inserts validated product lines
+func (r *ImportRepositoryImpl) InsertProducts(tx *sql.Tx, rows []ProductInsert) error {
+	for _, row := range rows {
+		_, err := tx.Exec("Insert Into Product (Name, Description, Category_Id, Unit_Id) Values ($1, $2, $3, $4)",
+			row.Name, row.Description, row.CategoryID, row.UnitID)
+		if err != nil {
+			return fmt.Errorf("gagal menyimpan produk %s: %w", row.Name, err)
+		}
+	}
+	return nil
+}
+
+// InsertCustomers inserts validated customer lines
+func (r *ImportRepositoryImpl) InsertCustomers(tx *sql.Tx, rows []CustomerRow) error {
+	for _, row := range rows {
+		_, err := tx.Exec("Insert Into Customer (Name, Address, Telephone, Email) Values ($1, $2, $3, Nullif($4, ''))",
+			row.Name, row.Address, row.Telephone, row.Email)
+		if err != nil {
+			return fmt.Errorf("gagal menyimpan pelanggan %s: %w", row.Name, err)
+		}
+	}
+	return nil
+}
+
+// InsertSuppliers inserts validated supplier lines
+func (r *ImportRepositoryImpl) InsertSuppliers(tx *sql.Tx, rows []SupplierRow) error {
+	for _, row := range rows {
+		_, err := tx.Exec("Insert Into Supplier (Name, Address, Telephone, Email) Values ($1, $2, $3, Nullif($4, ''))",
+			row.Name, row.Address, row.Telephone, row.Email)
+		if err != nil {
+			return fmt.Errorf("gagal menyimpan pemasok %s: %w", row.Name, err)
+		}
+	}
+	return nil
+}
+
+// InsertEmployees inserts validated employee lines
+func (r *ImportRepositoryImpl) InsertEmployees(tx *sql.Tx, rows []EmployeeRow) error {
+	for _, row := range rows {
+		_, err := tx.Exec("Insert Into Employee (Name, Position, Phone, Nik, Hired_Date) Values ($1, $2, $3, $4, $5)",
+			row.Name, row.Position, row.Phone, row.Nik, row.HiredDate)
+		if err != nil {
+			return fmt.Errorf("gagal menyimpan karyawan %s: %w", row.Name, err)
+		}
+	}
+	return nil
+}
+
+// InsertOpeningStock books opening stock as a completed purchase order per branch without supplier.
+// Every line becomes a batch in its storage, so stock is traced like any received purchase.
+func (r *ImportRepositoryImpl) InsertOpeningStock(tx *sql.Tx, rows []OpeningStockInsert, userID string) error {
+	byBranch := make(map[string][]OpeningStockInsert)
+	var branches []string
+	for _, row := range rows {
+		if _, ok := byBranch[row.BranchID]; !ok {
+			branches = append(branches, row.BranchID)
+		}
+		byBranch[row.BranchID] = append(byBranch[row.BranchID], row)
+	}
+
+	now := time.Now()
+	for _, branchID := range branches {
+		branchRows := byBranch[branchID]
+
+		var totalAmount float64
+		for _, row := range branchRows {
+			totalAmount += row.Quantity * row.UnitPrice
+		}
+
+		serialID, err := utils.GenerateNextBranchSerialID(tx, "PO", branchID)
+		if err != nil {
+			return err
+		}
+
+		var orderID string
+		err = tx.QueryRow(`
+			Insert Into Purchase_Order (Serial_Id, Branch_Id, Order_Date, Status, Total_Amount, Subtotal, Tax_Base, Payment_Method, Created_By, Checked_By)
+			Values ($1, Nullif($2, '')::uuid, $3, 'completed', $4, $4, $4, 'cash', $5, $5)
+			Returning Id
+		`, serialID, branchID, now, totalAmount, userID).Scan(&orderID)
+		if err != nil {
+			return fmt.Errorf("gagal membuat pesanan saldo awal: %w", err)
+		}
+
+		// Batch SKUs follow the purchase format with SA (saldo awal) in place of the supplier
+		serialParts := strings.Split(serialID, "-")
+		iteration := serialParts[len(serialParts)-1]
+		dateStr := fmt.Sprintf("%02d%02d%02d", now.Day(), now.Month(), now.Year()%100)
+
+		for i, row := range branchRows {
+			_, err = tx.Exec(`
+				Insert Into Purchase_Order_Detail (Purchase_Order_Id, Product_Id, Requested_Quantity, Unit_Price, Line_Total)
+				Values ($1, $2, $3, $4, $5)
+			`, orderID, row.ProductID, row.Quantity, row.UnitPrice, row.Quantity*row.UnitPrice)
+			if err != nil {
+				return fmt.Errorf("gagal menyimpan detail saldo awal: %w", err)
+			}
+
+			sku := strings.ToUpper(fmt.Sprintf("%s-SA%s-%s%03d", utils.GetAbbreviation(row.ProductName, 3), dateStr, iteration, i+1))
+			var batchID string
+			err = tx.QueryRow(`
+				Insert Into Product_Batch (Sku, Product_Id, Purchase_Order_Id, Initial_Quantity, Current_Quantity, Unit_Price)
+				Values ($1, $2, $3, $4, $4, $5)
+				Returning Id
+			`, sku, row.ProductID, orderID, row.Quantity, row.UnitPrice).Scan(&batchID)
+			if err != nil {
+				return fmt.Errorf("gagal membuat batch saldo awal: %w", err)
+			}
+
+			_, err = tx.Exec("Insert Into Batch_Storage (Batch_Id, Storage_Id, Quantity) Values ($1, $2, $3)",
+				batchID, row.StorageID, row.Quantity)
+			if err != nil {
+				return fmt.Errorf("gagal menyimpan stok gudang: %w", err)
+			}
+
+			_, err = tx.Exec(`
+				Insert Into Inventory_Log (Batch_Id, Storage_Id, User_Id, Purchase_Order_Id, Action, Quantity, Description, Log_Date)
+				Values ($1, $2, $3, $4, 'add', $5, 'Saldo awal dari impor', Now())
+			`, batchID, row.StorageID, userID, orderID, row.Quantity)
+			if err != nil {
+				return fmt.Errorf("gagal mencatat log inventaris: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
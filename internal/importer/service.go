@@ -0,0 +1,543 @@
+package importer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotValidated is returned when a job is committed while it is no longer waiting to be committed
+var ErrNotValidated = errors.New("impor tidak lagi menunggu disimpan")
+
+// ImportService is the service for spreadsheet imports of master data and opening stock
+type ImportService struct {
+	repo ImportRepository
+}
+
+// NewImportService creates a new instance of ImportService
+func NewImportService(repo ImportRepository) *ImportService {
+	return &ImportService{repo: repo}
+}
+
+// rowTemplates holds the row type per resource, its json tags are the spreadsheet columns
+var rowTemplates = map[string]interface{}{
+	ResourceProduct:      ProductRow{},
+	ResourceCustomer:     CustomerRow{},
+	ResourceSupplier:     SupplierRow{},
+	ResourceEmployee:     EmployeeRow{},
+	ResourceOpeningStock: OpeningStockRow{},
+}
+
+// maxImportRows caps the rows of a single file
+const maxImportRows = 5000
+
+// Columns returns the spreadsheet columns of a resource
+func Columns(resource string) []string {
+	template, ok := rowTemplates[resource]
+	if !ok {
+		return nil
+	}
+
+	rowType := reflect.TypeOf(template)
+	columns := make([]string, 0, rowType.NumField())
+	for i := 0; i < rowType.NumField(); i++ {
+		columns = append(columns, strings.Split(rowType.Field(i).Tag.Get("json"), ",")[0])
+	}
+	return columns
+}
+
+// normalizeHeader turns a header cell like "Unit Price" into the column name unit_price
+func normalizeHeader(cell string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(cell)), " ", "_")
+}
+
+// thousandsGrouped matches a whole number grouped with thousands dots, like 1.200 or 12.500.000
+var thousandsGrouped = regexp.MustCompile(`^-?[1-9][0-9]{0,2}(\.[0-9]{3})+$`)
+
+// parseNumber reads a number written the Indonesian way, with thousands dots and a decimal comma.
+// A value without a comma is only read as grouped when its dots split it into thousands, so 12.5 stays a decimal.
+func parseNumber(value string) (float64, error) {
+	value = strings.ReplaceAll(value, " ", "")
+	if strings.Contains(value, ",") || thousandsGrouped.MatchString(value) {
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.ReplaceAll(value, ",", ".")
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// decodeRow fills a row struct from the cells by column name and validates it.
+// Numbers are read with parseNumber and dates may be written as YYYY-MM-DD.
+func decodeRow(header []string, cells []string, row interface{}) map[string]string {
+	values := make(map[string]string)
+	for i, column := range header {
+		if i < len(cells) {
+			values[column] = strings.TrimSpace(cells[i])
+		}
+	}
+
+	fieldErrors := make(map[string]string)
+	rowValue := reflect.ValueOf(row).Elem()
+	rowType := rowValue.Type()
+	for i := 0; i < rowType.NumField(); i++ {
+		field := rowType.Field(i)
+		column := strings.Split(field.Tag.Get("json"), ",")[0]
+		value := values[column]
+
+		switch field.Type.Kind() {
+		case reflect.Float64:
+			if value == "" {
+				continue
+			}
+			number, err := parseNumber(value)
+			if err != nil {
+				fieldErrors[column] = "Harus berupa angka."
+				continue
+			}
+			rowValue.Field(i).SetFloat(number)
+		default:
+			if strings.Contains(field.Tag.Get("validate"), "rfc3339") {
+				if date, err := time.Parse("2006-01-02", value); err == nil {
+					value = date.Format(time.RFC3339)
+				}
+			}
+			rowValue.Field(i).SetString(value)
+		}
+	}
+
+	// Validation messages for cells that did parse
+	for field, message := range utils.ValidateStruct(row) {
+		if _, ok := fieldErrors[field]; !ok {
+			fieldErrors[field] = message
+		}
+	}
+	return fieldErrors
+}
+
+// sheet is a parsed upload with the header normalized and blank lines dropped
+type sheet struct {
+	header []string
+	rows   [][]string
+	lines  []int // spreadsheet row number per row
+}
+
+// parseSheet checks the header of an upload against the resource columns
+func parseSheet(resource string, data ImportData) (*sheet, []RowError) {
+	parsed := &sheet{}
+	for _, cell := range data.Header {
+		parsed.header = append(parsed.header, normalizeHeader(cell))
+	}
+
+	var rowErrors []RowError
+	for _, column := range Columns(resource) {
+		found := false
+		for _, headerColumn := range parsed.header {
+			if headerColumn == column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			rowErrors = append(rowErrors, RowError{Row: 1, Field: column, Message: "Kolom tidak ditemukan di header"})
+		}
+	}
+
+	for i, cells := range data.Rows {
+		if strings.TrimSpace(strings.Join(cells, "")) == "" {
+			continue
+		}
+		parsed.rows = append(parsed.rows, cells)
+		parsed.lines = append(parsed.lines, i+2)
+	}
+	return parsed, rowErrors
+}
+
+// validation is the outcome of validating a sheet, insert writes the valid rows inside the commit transaction
+type validation struct {
+	totalRows int
+	errorRows int
+	errors    []RowError
+	insert    func(tx *sql.Tx) error
+}
+
+// addErrors records the field errors of a row
+func (v *validation) addErrors(line int, fieldErrors map[string]string) {
+	if len(fieldErrors) == 0 {
+		return
+	}
+	v.errorRows++
+	for field, message := range fieldErrors {
+		v.errors = append(v.errors, RowError{Row: line, Field: field, Message: message})
+	}
+}
+
+// validate checks every row of a sheet against the validation rules and the current data
+func (s *ImportService) validate(resource string, data ImportData, branchID, userID string) (*validation, error) {
+	parsed, headerErrors := parseSheet(resource, data)
+	result := &validation{totalRows: len(parsed.rows), errors: headerErrors}
+	if len(headerErrors) > 0 {
+		result.errorRows = len(parsed.rows)
+		return result, nil
+	}
+
+	var err error
+	switch resource {
+	case ResourceProduct:
+		err = s.validateProducts(parsed, result)
+	case ResourceCustomer:
+		err = s.validateCustomers(parsed, result)
+	case ResourceSupplier:
+		err = s.validateSuppliers(parsed, result)
+	case ResourceEmployee:
+		err = s.validateEmployees(parsed, result)
+	case ResourceOpeningStock:
+		err = s.validateOpeningStock(parsed, result, branchID, userID)
+	default:
+		err = fmt.Errorf("resource impor tidak dikenal: %s", resource)
+	}
+	return result, err
+}
+
+func (s *ImportService) validateProducts(parsed *sheet, result *validation) error {
+	categories, err := s.repo.GetLookup("Category", "Name")
+	if err != nil {
+		return err
+	}
+	units, err := s.repo.GetLookup("Unit", "Name")
+	if err != nil {
+		return err
+	}
+	products, err := s.repo.GetLookup("Product", "Name")
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]int)
+	var inserts []ProductInsert
+	for i, cells := range parsed.rows {
+		var row ProductRow
+		fieldErrors := decodeRow(parsed.header, cells, &row)
+
+		key := lookupKey(row.Name)
+		if _, ok := fieldErrors["name"]; !ok {
+			if _, exists := products[key]; exists {
+				fieldErrors["name"] = "Nama produk sudah digunakan"
+			} else if line, duplicate := seen[key]; duplicate {
+				fieldErrors["name"] = fmt.Sprintf("Nama produk sama dengan baris %d", line)
+			}
+		}
+		categoryID, ok := categories[lookupKey(row.Category)]
+		if _, invalid := fieldErrors["category"]; !invalid && !ok {
+			fieldErrors["category"] = "Kategori tidak ditemukan"
+		}
+		unitID, ok := units[lookupKey(row.Unit)]
+		if _, invalid := fieldErrors["unit"]; !invalid && !ok {
+			fieldErrors["unit"] = "Satuan tidak ditemukan"
+		}
+
+		seen[key] = parsed.lines[i]
+		result.addErrors(parsed.lines[i], fieldErrors)
+		inserts = append(inserts, ProductInsert{Name: row.Name, Description: row.Description, CategoryID: categoryID, UnitID: unitID})
+	}
+
+	result.insert = func(tx *sql.Tx) error { return s.repo.InsertProducts(tx, inserts) }
+	return nil
+}
+
+func (s *ImportService) validateCustomers(parsed *sheet, result *validation) error {
+	customers, err := s.repo.GetLookup("Customer", "Name")
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]int)
+	var inserts []CustomerRow
+	for i, cells := range parsed.rows {
+		var row CustomerRow
+		fieldErrors := decodeRow(parsed.header, cells, &row)
+
+		key := lookupKey(row.Name)
+		if _, ok := fieldErrors["name"]; !ok {
+			if _, exists := customers[key]; exists {
+				fieldErrors["name"] = "Nama pelanggan sudah digunakan"
+			} else if line, duplicate := seen[key]; duplicate {
+				fieldErrors["name"] = fmt.Sprintf("Nama pelanggan sama dengan baris %d", line)
+			}
+		}
+
+		seen[key] = parsed.lines[i]
+		result.addErrors(parsed.lines[i], fieldErrors)
+		inserts = append(inserts, row)
+	}
+
+	result.insert = func(tx *sql.Tx) error { return s.repo.InsertCustomers(tx, inserts) }
+	return nil
+}
+
+func (s *ImportService) validateSuppliers(parsed *sheet, result *validation) error {
+	suppliers, err := s.repo.GetLookup("Supplier", "Name")
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]int)
+	var inserts []SupplierRow
+	for i, cells := range parsed.rows {
+		var row SupplierRow
+		fieldErrors := decodeRow(parsed.header, cells, &row)
+
+		key := lookupKey(row.Name)
+		if _, ok := fieldErrors["name"]; !ok {
+			if _, exists := suppliers[key]; exists {
+				fieldErrors["name"] = "Nama pemasok sudah digunakan"
+			} else if line, duplicate := seen[key]; duplicate {
+				fieldErrors["name"] = fmt.Sprintf("Nama pemasok sama dengan baris %d", line)
+			}
+		}
+
+		seen[key] = parsed.lines[i]
+		result.addErrors(parsed.lines[i], fieldErrors)
+		inserts = append(inserts, row)
+	}
+
+	result.insert = func(tx *sql.Tx) error { return s.repo.InsertSuppliers(tx, inserts) }
+	return nil
+}
+
+func (s *ImportService) validateEmployees(parsed *sheet, result *validation) error {
+	niks, err := s.repo.GetLookup("Employee", "Nik")
+	if err != nil {
+		return err
+	}
+	phones, err := s.repo.GetLookup("Employee", "Phone")
+	if err != nil {
+		return err
+	}
+
+	seenNik := make(map[string]int)
+	seenPhone := make(map[string]int)
+	var inserts []EmployeeRow
+	for i, cells := range parsed.rows {
+		var row EmployeeRow
+		fieldErrors := decodeRow(parsed.header, cells, &row)
+
+		if _, ok := fieldErrors["nik"]; !ok {
+			if _, exists := niks[lookupKey(row.Nik)]; exists {
+				fieldErrors["nik"] = "NIK sudah digunakan"
+			} else if line, duplicate := seenNik[row.Nik]; duplicate {
+				fieldErrors["nik"] = fmt.Sprintf("NIK sama dengan baris %d", line)
+			}
+		}
+		if _, ok := fieldErrors["phone"]; !ok {
+			if _, exists := phones[lookupKey(row.Phone)]; exists {
+				fieldErrors["phone"] = "Nomor telepon sudah digunakan"
+			} else if line, duplicate := seenPhone[row.Phone]; duplicate {
+				fieldErrors["phone"] = fmt.Sprintf("Nomor telepon sama dengan baris %d", line)
+			}
+		}
+
+		seenNik[row.Nik] = parsed.lines[i]
+		seenPhone[row.Phone] = parsed.lines[i]
+		result.addErrors(parsed.lines[i], fieldErrors)
+		inserts = append(inserts, row)
+	}
+
+	result.insert = func(tx *sql.Tx) error { return s.repo.InsertEmployees(tx, inserts) }
+	return nil
+}
+
+func (s *ImportService) validateOpeningStock(parsed *sheet, result *validation, branchID, userID string) error {
+	storages, err := s.repo.GetStorages(branchID)
+	if err != nil {
+		return err
+	}
+	products, err := s.repo.GetLookup("Product", "Name")
+	if err != nil {
+		return err
+	}
+
+	var inserts []OpeningStockInsert
+	for i, cells := range parsed.rows {
+		var row OpeningStockRow
+		fieldErrors := decodeRow(parsed.header, cells, &row)
+
+		storage, ok := storages[lookupKey(row.Storage)]
+		if _, invalid := fieldErrors["storage"]; !invalid && !ok {
+			fieldErrors["storage"] = "Gudang tidak ditemukan di cabang aktif"
+		}
+		productID, ok := products[lookupKey(row.Product)]
+		if _, invalid := fieldErrors["product"]; !invalid && !ok {
+			fieldErrors["product"] = "Produk tidak ditemukan"
+		}
+
+		result.addErrors(parsed.lines[i], fieldErrors)
+		inserts = append(inserts, OpeningStockInsert{
+			StorageID:   storage.ID,
+			BranchID:    storage.BranchID,
+			ProductID:   productID,
+			ProductName: row.Product,
+			Quantity:    row.Quantity,
+			UnitPrice:   row.UnitPrice,
+		})
+	}
+
+	result.insert = func(tx *sql.Tx) error { return s.repo.InsertOpeningStock(tx, inserts, userID) }
+	return nil
+}
+
+// applyValidation copies a validation outcome onto a job
+func applyValidation(job *ImportJob, result *validation) {
+	job.TotalRows = result.totalRows
+	job.ErrorRows = result.errorRows
+	job.ValidRows = result.totalRows - result.errorRows
+	job.Errors = result.errors
+	if job.Errors == nil {
+		job.Errors = []RowError{}
+	}
+
+	job.Status = StatusValidated
+	if len(job.Errors) > 0 {
+		job.Status = StatusInvalid
+	}
+}
+
+// CreateImport validates an uploaded sheet as a dry run and commits it right away unless DryRun is set
+func (s *ImportService) CreateImport(req CreateImportRequest) (*ImportJob, *dto.APIError) {
+	if len(req.Rows) == 0 {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"file": "File tidak memiliki header",
+		})
+	}
+	if len(req.Rows)-1 > maxImportRows {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"file": fmt.Sprintf("Maksimal %d baris per file", maxImportRows),
+		})
+	}
+
+	data := ImportData{Header: req.Rows[0], Rows: req.Rows[1:]}
+	result, err := s.validate(req.Resource, data, req.BranchID, req.UserID)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memvalidasi file impor",
+		})
+	}
+
+	job := ImportJob{Resource: req.Resource, FileName: req.FileName, CreatedBy: &req.UserID}
+	if req.BranchID != "" {
+		job.BranchID = &req.BranchID
+	}
+	applyValidation(&job, result)
+
+	created, err := s.repo.Create(job, data)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menyimpan pekerjaan impor",
+		})
+	}
+
+	if req.DryRun || created.Status != StatusValidated {
+		return created, nil
+	}
+	return s.commit(created, result)
+}
+
+// CommitImport re-validates a dry-run job against the current data and commits all rows in one transaction
+func (s *ImportService) CommitImport(id, branchID, userID string) (*ImportJob, *dto.APIError) {
+	job, data, apiErr := s.getImport(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if job.Status != StatusValidated {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Hanya impor yang lolos validasi yang dapat disimpan",
+		})
+	}
+
+	// The data may have changed since the dry run
+	if job.BranchID != nil {
+		branchID = *job.BranchID
+	}
+	result, err := s.validate(job.Resource, *data, branchID, userID)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memvalidasi file impor",
+		})
+	}
+	applyValidation(job, result)
+	if job.Status != StatusValidated {
+		if err = s.repo.Update(*job); err != nil {
+			return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+				"general": "Gagal memperbarui pekerjaan impor",
+			})
+		}
+		return job, nil
+	}
+
+	return s.commit(job, result)
+}
+
+// commit writes every row of a validated job, a failure rolls back all rows and marks the job failed
+func (s *ImportService) commit(job *ImportJob, result *validation) (*ImportJob, *dto.APIError) {
+	if err := s.repo.Commit(job.ID, result.insert); err != nil {
+		// Another commit of the job got there first
+		if errors.Is(err, ErrNotValidated) {
+			return nil, dto.NewAPIError(http.StatusConflict, map[string]string{
+				"general": "Impor ini sudah disimpan atau sedang disimpan",
+			})
+		}
+
+		log.Printf("import: failed to commit job %s: %v", job.ID, err)
+		job.Status = StatusFailed
+		job.Errors = []RowError{{Row: 0, Field: "general", Message: "Gagal menyimpan data impor, tidak ada baris yang disimpan"}}
+		if errUpdate := s.repo.Update(*job); errUpdate != nil {
+			return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+				"general": "Gagal memperbarui pekerjaan impor",
+			})
+		}
+		return job, nil
+	}
+
+	committed, _, apiErr := s.getImport(job.ID)
+	return committed, apiErr
+}
+
+// getImport fetches an import job with its uploaded sheet
+func (s *ImportService) getImport(id string) (*ImportJob, *ImportData, *dto.APIError) {
+	job, data, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Pekerjaan impor tidak ditemukan",
+			})
+		}
+		return nil, nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil pekerjaan impor",
+		})
+	}
+	return job, data, nil
+}
+
+// GetImport fetches an import job with its result report
+func (s *ImportService) GetImport(id string) (*ImportJob, *dto.APIError) {
+	job, _, apiErr := s.getImport(id)
+	return job, apiErr
+}
+
+// GetAllImports fetches import jobs with pagination
+func (s *ImportService) GetAllImports(req GetImportJobsRequest) ([]ImportJob, int, *dto.APIError) {
+	jobs, totalItems, err := s.repo.GetAll(req)
+	if err != nil {
+		return nil, 0, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil pekerjaan impor",
+		})
+	}
+	return jobs, totalItems, nil
+}
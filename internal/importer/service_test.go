@@ -0,0 +1,90 @@
+package importer
+
+import (
+	"os"
+	"sinartimur-go/utils"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Validation errors are keyed by json name once the app validators are registered
+	utils.RegisterCustomValidators()
+	os.Exit(m.Run())
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"1200", 1200},
+		{"1.200", 1200},
+		{"1.200,50", 1200.5},
+		{"12.500.000", 12500000},
+		{"12.500.000,75", 12500000.75},
+		{"1200,5", 1200.5},
+		{"0,25", 0.25},
+		{"1 200", 1200},
+		{"-1.200", -1200},
+		{"12.5", 12.5},
+		{"1200.5", 1200.5},
+		{"0.125", 0.125},
+	}
+
+	for _, tt := range tests {
+		got, err := parseNumber(tt.value)
+		if err != nil {
+			t.Errorf("parseNumber(%q) returned error: %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseNumber(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseNumberInvalid(t *testing.T) {
+	for _, value := range []string{"abc", "1.2.3,4,5", "Rp"} {
+		if _, err := parseNumber(value); err == nil {
+			t.Errorf("parseNumber(%q) expected an error", value)
+		}
+	}
+}
+
+func TestDecodeRowNumbers(t *testing.T) {
+	tests := []struct {
+		name      string
+		cells     []string
+		quantity  float64
+		unitPrice float64
+		errors    []string
+	}{
+		{name: "thousands and decimal", cells: []string{"Gudang", "Semen", "1.200", "1.200,50"}, quantity: 1200, unitPrice: 1200.5},
+		{name: "plain numbers", cells: []string{"Gudang", "Semen", "12", "65000"}, quantity: 12, unitPrice: 65000},
+		{name: "not a number", cells: []string{"Gudang", "Semen", "dua", "0"}, errors: []string{"quantity"}},
+		{name: "zero quantity", cells: []string{"Gudang", "Semen", "0", "0"}, errors: []string{"quantity"}},
+	}
+
+	header := []string{"storage", "product", "quantity", "unit_price"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var row OpeningStockRow
+			fieldErrors := decodeRow(header, tt.cells, &row)
+
+			if len(fieldErrors) != len(tt.errors) {
+				t.Fatalf("got errors %v, want errors on %v", fieldErrors, tt.errors)
+			}
+			for _, field := range tt.errors {
+				if _, ok := fieldErrors[field]; !ok {
+					t.Errorf("missing error on %s, got %v", field, fieldErrors)
+				}
+			}
+			if len(tt.errors) > 0 {
+				return
+			}
+			if row.Quantity != tt.quantity || row.UnitPrice != tt.unitPrice {
+				t.Errorf("got quantity %v and unit price %v, want %v and %v", row.Quantity, row.UnitPrice, tt.quantity, tt.unitPrice)
+			}
+		})
+	}
+}
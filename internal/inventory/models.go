@@ -29,6 +29,7 @@ type GetStorageResponse struct {
 type GetStorageRequest struct {
 	Name     string `json:"name" validate:"omitempty"`
 	Location string `json:"location" validate:"omitempty"`
+	BranchID string `json:"-"`
 	// Adding pagination fields
 	utils.PaginationParameter
 }
@@ -37,6 +38,7 @@ type GetStorageRequest struct {
 type CreateStorageRequest struct {
 	Name     string `json:"name" validate:"required,min=2,max=255"`
 	Location string `json:"location" validate:"required"`
+	BranchID string `json:"-"`
 }
 
 // UpdateStorageRequest holds data needed to update an existing storage
@@ -69,6 +71,7 @@ type GetAllBatchesRequest struct {
 	ProductID string `json:"product_id" validate:"omitempty,uuid"`
 	SKU       string `json:"sku" validate:"omitempty"`
 	StorageID string `json:"storage_id" validate:"omitempty,uuid"`
+	BranchID  string `json:"-"`
 	utils.PaginationParameter
 }
 
@@ -87,16 +90,18 @@ type GetAllBatchesRequest struct {
 
 // GetAllBatchResponse is used when returning batch data to clients
 type GetAllBatchResponse struct {
-	ID              string  `json:"id"`
-	SKU             string  `json:"sku"`
-	ProductID       string  `json:"product_id"`
-	ProductName     string  `json:"product_name"`
-	PurchaseOrderID *string `json:"purchase_order_id,omitempty"`
-	InitialQuantity float64 `json:"initial_quantity"`
-	CurrentQuantity float64 `json:"current_quantity"`
-	UnitPrice       float64 `json:"unit_price"`
-	CreatedAt       string  `json:"created_at"`
-	UpdatedAt       string  `json:"updated_at"`
+	ID                string  `json:"id"`
+	SKU               string  `json:"sku"`
+	ProductID         string  `json:"product_id"`
+	ProductName       string  `json:"product_name"`
+	PurchaseOrderID   *string `json:"purchase_order_id,omitempty"`
+	InitialQuantity   float64 `json:"initial_quantity"`
+	CurrentQuantity   float64 `json:"current_quantity"`
+	ReservedQuantity  float64 `json:"reserved_quantity"`  // Held by open sales orders
+	AvailableQuantity float64 `json:"available_quantity"` // Current minus reserved
+	UnitPrice         float64 `json:"unit_price"`
+	CreatedAt         string  `json:"created_at"`
+	UpdatedAt         string  `json:"updated_at"`
 }
 
 // BatchStorage represents the quantity of a product batch in a specific storage
@@ -116,6 +121,7 @@ type MoveBatchRequest struct {
 	TargetStorageID string  `json:"target_storage_id" validate:"required,uuid,nefield=SourceStorageID"`
 	Quantity        float64 `json:"quantity" validate:"required,gt=0"`
 	Description     string  `json:"description" validate:"omitempty"`
+	BranchID        string  `json:"-"`
 }
 
 // InventoryLog represents a record of inventory movement or change
@@ -142,6 +148,7 @@ type GetInventoryLogsRequest struct {
 	Action          string `json:"action" validate:"omitempty,oneof=add remove transfer return"`
 	FromDate        string `json:"from_date" validate:"omitempty,rfc3339"`
 	ToDate          string `json:"to_date" validate:"omitempty,rfc3339"`
+	BranchID        string `json:"-"`
 	utils.PaginationParameter
 }
 
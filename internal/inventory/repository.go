@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"sinartimur-go/internal/outbox"
+	"sinartimur-go/pkg/event"
 	"sinartimur-go/utils"
 	"time"
 )
@@ -58,6 +60,7 @@ func (r *StorageRepositoryImpl) GetAllStorages(req GetStorageRequest) ([]GetStor
 	if req.Location != "" {
 		qb.AddFilter("Location ILIKE ", `%`+req.Location+`%`)
 	}
+	qb.AddFilter("Branch_Id =", req.BranchID)
 
 	// Get count first
 	//countQuery := fmt.Sprintf("Select Count(*) From (%S) As Filtered_Storages", qb.Query.String())
@@ -75,7 +78,7 @@ func (r *StorageRepositoryImpl) GetAllStorages(req GetStorageRequest) ([]GetStor
 	}
 
 	// Add pagination
-	qb.AddPagination(req.PageSize, req.Page)
+	qb.AddPagination(req.PaginationParameter)
 
 	// Execute final query
 	query, params := qb.Build()
@@ -90,6 +93,12 @@ func (r *StorageRepositoryImpl) GetAllStorages(req GetStorageRequest) ([]GetStor
 		if errScan := rows.Scan(&storage.ID, &storage.Name, &storage.Location, &storage.CreatedAt, &storage.UpdatedAt); errScan != nil {
 			return nil, 0, errScan
 		}
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(storage); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		storages = append(storages, storage)
 	}
 
@@ -121,8 +130,8 @@ func (r *StorageRepositoryImpl) GetStorageByName(name string) (*Storage, error)
 // CreateStorage creates a new storage location
 func (r *StorageRepositoryImpl) CreateStorage(req CreateStorageRequest) (*GetStorageResponse, error) {
 	var storage GetStorageResponse
-	err := r.db.QueryRow("Insert Into Storage (Id, Name, Location) Values ($1, $2, $3) Returning Id, Name, Location, Created_At, Updated_At",
-		uuid.New().String(), req.Name, req.Location).
+	err := r.db.QueryRow("Insert Into Storage (Id, Name, Location, Branch_Id) Values ($1, $2, $3, $4) Returning Id, Name, Location, Created_At, Updated_At",
+		uuid.New().String(), req.Name, req.Location, req.BranchID).
 		Scan(&storage.ID, &storage.Name, &storage.Location, &storage.CreatedAt, &storage.UpdatedAt)
 	if err != nil {
 		return nil, err
@@ -151,6 +160,7 @@ func (r *StorageRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAl
 	qb := utils.NewQueryBuilder(`
         SELECT pb.id, pb.sku, pb.product_id, p.name AS product_name, 
                pb.purchase_order_id, pb.initial_quantity, pb.current_quantity, 
+               coalesce((SELECT sum(st.reserved) FROM batch_storage_stock st WHERE st.batch_id = pb.id), 0) AS reserved_quantity,
                pb.unit_price, pb.created_at, pb.updated_at
         FROM product_batch pb
         JOIN product p ON pb.product_id = p.id
@@ -168,6 +178,7 @@ func (r *StorageRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAl
 	if req.StorageID != "" {
 		qb.AddFilter("bs.storage_id =", req.StorageID)
 	}
+	qb.AddFilterExpr("bs.storage_id In (Select Id From Storage Where Branch_Id = $?)", req.BranchID)
 
 	// Get count first
 	countQuery := "SELECT COUNT(*) FROM (" + qb.Query.String() + ") AS filtered_batches"
@@ -184,7 +195,7 @@ func (r *StorageRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAl
 	}
 
 	// Add pagination
-	qb.AddPagination(req.PageSize, req.Page)
+	qb.AddPagination(req.PaginationParameter)
 
 	// Execute final query
 	query, params := qb.Build()
@@ -206,6 +217,7 @@ func (r *StorageRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAl
 			&purchaseOrderID,
 			&batch.InitialQuantity,
 			&batch.CurrentQuantity,
+			&batch.ReservedQuantity,
 			&batch.UnitPrice,
 			&batch.CreatedAt,
 			&batch.UpdatedAt,
@@ -217,6 +229,7 @@ func (r *StorageRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAl
 		if purchaseOrderID.Valid {
 			batch.PurchaseOrderID = &purchaseOrderID.String
 		}
+		batch.AvailableQuantity = batch.CurrentQuantity - batch.ReservedQuantity
 
 		batches = append(batches, batch)
 	}
@@ -271,19 +284,37 @@ func (r *StorageRepositoryImpl) LogInventoryMovement(log InventoryLog) error {
 func (r *StorageRepositoryImpl) MoveBatch(req MoveBatchRequest, userID string) error {
 	// Use a transaction to ensure data consistency
 	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		// The transfer belongs to the branch of the source storage
+		var branchID string
+		err := tx.QueryRow("Select Coalesce(Branch_Id::text, '') From Storage Where Id = $1 And Deleted_At Is Null", req.SourceStorageID).Scan(&branchID)
+		if err != nil {
+			return err
+		}
+		if req.BranchID != "" && branchID != req.BranchID {
+			return fmt.Errorf("gudang sumber tidak berada di cabang aktif")
+		}
+
 		// Get batch in source storage
 		var sourceBatchStorage BatchStorage
-		err := tx.QueryRow("Select Id, Batch_Id, Storage_Id, Quantity, Created_At, Updated_At From Batch_Storage Where Batch_Id = $1 And Storage_Id = $2",
+		err = tx.QueryRow("Select Id, Batch_Id, Storage_Id, Quantity, Created_At, Updated_At From Batch_Storage Where Batch_Id = $1 And Storage_Id = $2 For Update",
 			req.BatchID, req.SourceStorageID).
 			Scan(&sourceBatchStorage.ID, &sourceBatchStorage.BatchID, &sourceBatchStorage.StorageID, &sourceBatchStorage.Quantity, &sourceBatchStorage.CreatedAt, &sourceBatchStorage.UpdatedAt)
 		if err != nil {
 			return err
 		}
 
-		// Check if source has enough quantity
+		// Check if source has enough quantity, stock reserved by open sales orders stays where it is
 		if sourceBatchStorage.Quantity < req.Quantity {
 			return fmt.Errorf("kuantitas tidak mencukupi di gudang sumber")
 		}
+		var available float64
+		err = tx.QueryRow("Select Available From Batch_Storage_Stock Where Id = $1", sourceBatchStorage.ID).Scan(&available)
+		if err != nil {
+			return err
+		}
+		if available < req.Quantity {
+			return fmt.Errorf("kuantitas tersedia di gudang sumber hanya %g, sisanya sudah dipesan", available)
+		}
 
 		// Update source storage quantity
 		_, err = tx.Exec("Update Batch_Storage Set Quantity = Quantity - $1, Updated_At = Now() Where Id = $2",
@@ -313,14 +344,28 @@ func (r *StorageRepositoryImpl) MoveBatch(req MoveBatchRequest, userID string) e
 			return err
 		}
 
+		// Generate serial ID
+		serialID, err := utils.GenerateNextBranchSerialID(tx, "TRF", branchID)
+		if err != nil {
+			return err
+		}
+
 		// Log the movement
-		_, err = tx.Exec("Insert Into Inventory_Log (Id, Batch_Id, Storage_Id, Target_Storage_Id, User_Id, Action, Quantity, Log_Date, Description) Values ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
-			uuid.New().String(), req.BatchID, req.SourceStorageID, req.TargetStorageID, userID, "transfer", req.Quantity, time.Now(), req.Description)
+		_, err = tx.Exec("Insert Into Inventory_Log (Id, Serial_Id, Batch_Id, Storage_Id, Target_Storage_Id, User_Id, Action, Quantity, Log_Date, Description) Values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+			uuid.New().String(), serialID, req.BatchID, req.SourceStorageID, req.TargetStorageID, userID, "transfer", req.Quantity, time.Now(), req.Description)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		// Record event in outbox
+		return outbox.Record(tx, event.BatchMoved, map[string]interface{}{
+			"serial_id":         serialID,
+			"batch_id":          req.BatchID,
+			"source_storage_id": req.SourceStorageID,
+			"target_storage_id": req.TargetStorageID,
+			"quantity":          req.Quantity,
+			"moved_by":          userID,
+		})
 	})
 }
 
@@ -344,6 +389,12 @@ func (r *StorageRepositoryImpl) RefreshInventoryLogView() error {
 		return err
 	}
 
+	// Record event in outbox
+	err = outbox.Record(tx, event.ViewRefreshed, map[string]string{"view_name": "inventory_log_view"})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -365,7 +416,15 @@ func (r *StorageRepositoryImpl) GetInventoryLogs(req GetInventoryLogsRequest) ([
 	var totalItems int
 
 	// Build base query
-	qb := utils.NewQueryBuilder("SELECT * FROM inventory_log_view WHERE 1=1")
+	qb := utils.NewQueryBuilder(`
+        SELECT id, batch_id, batch_sku, product_id, product_name, storage_id, storage_name,
+               target_storage_id, target_storage_name, user_id, username, purchase_order_id,
+               sales_order_id, action, quantity, log_date, description, created_at
+        FROM inventory_log_view WHERE 1=1
+    `)
+
+	// Transfers show up in both the source and the target branch
+	qb.AddFilterExpr("(branch_id = $? Or target_storage_id In (Select Id From Storage Where Branch_Id = $?))", req.BranchID)
 
 	// Add filters
 	if req.BatchID != "" {
@@ -415,7 +474,7 @@ func (r *StorageRepositoryImpl) GetInventoryLogs(req GetInventoryLogsRequest) ([
 	}
 
 	// Add pagination
-	qb.AddPagination(req.PageSize, req.Page)
+	qb.AddPagination(req.PaginationParameter)
 
 	// Execute final query
 	query, params := qb.Build()
@@ -469,6 +528,12 @@ func (r *StorageRepositoryImpl) GetInventoryLogs(req GetInventoryLogsRequest) ([
 			log.SalesOrderID = &salesOrderID.String
 		}
 
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(log); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		logs = append(logs, log)
 	}
 
@@ -46,6 +46,13 @@ func (s *StorageService) GetStorageByID(id string) (*GetStorageResponse, *dto.AP
 
 // CreateStorage creates a new storage location
 func (s *StorageService) CreateStorage(req CreateStorageRequest) (*GetStorageResponse, *dto.APIError) {
+	// A storage always belongs to a branch
+	if req.BranchID == "" {
+		return nil, dto.NewAPIError(400, map[string]string{
+			"branch": "Pilih cabang aktif untuk gudang baru",
+		})
+	}
+
 	// Check if storage with same name already exists
 	existing, err := s.repo.GetStorageByName(req.Name)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -182,6 +189,11 @@ func (s *StorageService) MoveBatch(req MoveBatchRequest, userID string) *dto.API
 				"quantity": "Kuantitas tidak mencukupi di gudang sumber",
 			})
 		}
+		if strings.Contains(err.Error(), "cabang aktif") {
+			return dto.NewAPIError(403, map[string]string{
+				"source_storage_id": "Gudang sumber tidak berada di cabang aktif",
+			})
+		}
 		return dto.NewAPIError(500, map[string]string{
 			"general": "Gagal memindahkan batch: " + err.Error(),
 		})
@@ -0,0 +1,22 @@
+package numbering
+
+// DocumentNumbering is the numbering scheme of a document type
+type DocumentNumbering struct {
+	DocumentType string  `json:"document_type"`
+	Description  string  `json:"description"`
+	Prefix       string  `json:"prefix"`
+	ResetPeriod  string  `json:"reset_period"`
+	Padding      int     `json:"padding"`
+	BranchCode   *string `json:"branch_code"`
+	UpdatedAt    string  `json:"updated_at"`
+	NextSerialID string  `json:"next_serial_id"`
+}
+
+// UpdateDocumentNumberingRequest is the payload for changing the numbering scheme of a document type
+type UpdateDocumentNumberingRequest struct {
+	DocumentType string `json:"-" validate:"required,oneof=SO SI DN PO SR PR PAY ADJ TRF SQ CN"`
+	Prefix       string `json:"prefix" validate:"required,alphanum,max=10"`
+	ResetPeriod  string `json:"reset_period" validate:"required,oneof=daily monthly yearly"`
+	Padding      int    `json:"padding" validate:"required,min=1,max=8"`
+	BranchCode   string `json:"branch_code" validate:"omitempty,alphanum,max=10"`
+}
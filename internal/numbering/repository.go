@@ -0,0 +1,98 @@
+package numbering
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// NumberingRepository defines the interface for document numbering operations
+type NumberingRepository interface {
+	GetAll() ([]DocumentNumbering, error)
+	GetByType(documentType string) (*DocumentNumbering, error)
+	Update(req UpdateDocumentNumberingRequest, userID string) error
+	GetCounter(documentType, branchID string, year, month, day int) (int, error)
+	GetBranchCode(branchID string) (string, error)
+}
+
+// NumberingRepositoryImpl implements the NumberingRepository interface
+type NumberingRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewNumberingRepository creates a new numbering repository instance
+func NewNumberingRepository(db *sql.DB) NumberingRepository {
+	return &NumberingRepositoryImpl{db: db}
+}
+
+// GetAll fetches the numbering schemes of every document type
+func (r *NumberingRepositoryImpl) GetAll() ([]DocumentNumbering, error) {
+	rows, err := r.db.Query(`
+        Select Document_Type, Description, Prefix, Reset_Period, Padding, Branch_Code, Updated_At
+        From Document_Numbering
+        Order By Document_Type
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schemes := []DocumentNumbering{}
+	for rows.Next() {
+		var scheme DocumentNumbering
+		if err := rows.Scan(&scheme.DocumentType, &scheme.Description, &scheme.Prefix, &scheme.ResetPeriod,
+			&scheme.Padding, &scheme.BranchCode, &scheme.UpdatedAt); err != nil {
+			return nil, err
+		}
+		schemes = append(schemes, scheme)
+	}
+
+	return schemes, rows.Err()
+}
+
+// GetByType fetches the numbering scheme of a document type
+func (r *NumberingRepositoryImpl) GetByType(documentType string) (*DocumentNumbering, error) {
+	var scheme DocumentNumbering
+	err := r.db.QueryRow(`
+        Select Document_Type, Description, Prefix, Reset_Period, Padding, Branch_Code, Updated_At
+        From Document_Numbering
+        Where Document_Type = $1
+    `, documentType).Scan(&scheme.DocumentType, &scheme.Description, &scheme.Prefix, &scheme.ResetPeriod,
+		&scheme.Padding, &scheme.BranchCode, &scheme.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &scheme, nil
+}
+
+// Update changes the numbering scheme of a document type
+func (r *NumberingRepositoryImpl) Update(req UpdateDocumentNumberingRequest, userID string) error {
+	_, err := r.db.Exec(`
+        Update Document_Numbering
+        Set Prefix = $1, Reset_Period = $2, Padding = $3, Branch_Code = Nullif($4, ''),
+            Updated_By = $5, Updated_At = Now()
+        Where Document_Type = $6
+    `, req.Prefix, req.ResetPeriod, req.Padding, req.BranchCode, userID, req.DocumentType)
+	return err
+}
+
+// GetCounter fetches the last number issued for a document type by a branch in a period, zero if none was issued.
+// Documents without a branch have their own counter.
+func (r *NumberingRepositoryImpl) GetCounter(documentType, branchID string, year, month, day int) (int, error) {
+	var counter int
+	err := r.db.QueryRow(`
+        Select Counter
+        From Document_Counter
+        Where Document_Type = $1 And Year = $2 And Month = $3 And Day = $4 And Branch_Id Is Not Distinct From $5
+    `, documentType, year, month, day, sql.NullString{String: branchID, Valid: branchID != ""}).Scan(&counter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return counter, err
+}
+
+// GetBranchCode fetches the code a branch numbers its documents with
+func (r *NumberingRepositoryImpl) GetBranchCode(branchID string) (string, error) {
+	var code string
+	err := r.db.QueryRow("Select Code From Branch Where Id = $1", branchID).Scan(&code)
+	return code, err
+}
@@ -0,0 +1,133 @@
+package numbering
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/utils"
+	"time"
+)
+
+// NumberingService is the service for document numbering schemes
+type NumberingService struct {
+	repo NumberingRepository
+}
+
+// NewNumberingService creates a new instance of NumberingService
+func NewNumberingService(repo NumberingRepository) *NumberingService {
+	return &NumberingService{repo: repo}
+}
+
+// branchCode fetches the code documents of the branch are numbered with, empty without a branch
+func (s *NumberingService) branchCode(branchID string) (string, *dto.APIError) {
+	if branchID == "" {
+		return "", nil
+	}
+	code, err := s.repo.GetBranchCode(branchID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"branch_id": "Cabang tidak ditemukan",
+			})
+		}
+		return "", dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil kode cabang",
+		})
+	}
+	return code, nil
+}
+
+// withNextSerialID previews the number the next document of the type will get in the branch, numbered as
+// utils.GenerateNextBranchSerialID does with the branch code in place of the scheme's
+func (s *NumberingService) withNextSerialID(scheme *DocumentNumbering, branchID, branchCode string) error {
+	now := time.Now()
+	year, month, day := utils.SerialPeriod(scheme.ResetPeriod, now)
+
+	counter, err := s.repo.GetCounter(scheme.DocumentType, branchID, year, month, day)
+	if err != nil {
+		return err
+	}
+
+	numbering := utils.DocumentNumbering{
+		Prefix:      scheme.Prefix,
+		ResetPeriod: scheme.ResetPeriod,
+		Padding:     scheme.Padding,
+	}
+	if branchID != "" {
+		numbering.BranchCode = branchCode
+	} else if scheme.BranchCode != nil {
+		numbering.BranchCode = *scheme.BranchCode
+	}
+	scheme.NextSerialID = utils.FormatSerialID(numbering, now, counter+1)
+	return nil
+}
+
+// GetAllDocumentNumbering fetches the numbering schemes of every document type with the next number
+// of the given branch
+func (s *NumberingService) GetAllDocumentNumbering(branchID string) ([]DocumentNumbering, *dto.APIError) {
+	schemes, err := s.repo.GetAll()
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil format penomoran dokumen",
+		})
+	}
+
+	code, apiErr := s.branchCode(branchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	for i := range schemes {
+		if err := s.withNextSerialID(&schemes[i], branchID, code); err != nil {
+			return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+				"general": "Gagal mengambil format penomoran dokumen",
+			})
+		}
+	}
+
+	return schemes, nil
+}
+
+// UpdateDocumentNumbering changes the numbering scheme of a document type.
+// Counters are kept per period, so numbers already issued are never reused.
+func (s *NumberingService) UpdateDocumentNumbering(req UpdateDocumentNumberingRequest, userID, branchID string) (*DocumentNumbering, *dto.APIError) {
+	// Check if document type exists
+	if _, err := s.repo.GetByType(req.DocumentType); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"document_type": "Jenis dokumen tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil format penomoran dokumen",
+		})
+	}
+
+	// The branch is looked up before the scheme is changed
+	code, apiErr := s.branchCode(branchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if err := s.repo.Update(req, userID); err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memperbarui format penomoran dokumen",
+		})
+	}
+
+	scheme, err := s.repo.GetByType(req.DocumentType)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil format penomoran dokumen",
+		})
+	}
+
+	if err := s.withNextSerialID(scheme, branchID, code); err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil format penomoran dokumen",
+		})
+	}
+
+	return scheme, nil
+}
@@ -0,0 +1,21 @@
+package outbox
+
+import "sinartimur-go/pkg/event"
+
+// Message is an outbox row claimed for dispatch
+type Message struct {
+	Event    event.Event
+	Attempts int
+}
+
+// subscriber is an in-process consumer registered on the dispatcher
+type subscriber struct {
+	name    string
+	types   map[string]bool
+	handler event.Handler
+}
+
+// accepts reports whether the subscriber listens to the event type, no types means every event
+func (s subscriber) accepts(eventType string) bool {
+	return len(s.types) == 0 || s.types[eventType]
+}
@@ -0,0 +1,156 @@
+package outbox
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sinartimur-go/pkg/event"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotifyChannel is the Postgres channel every committed outbox event is announced on with its id
+const NotifyChannel = "outbox_event"
+
+// Record writes an event to the outbox as part of the caller's transaction,
+// so the event exists if and only if the transaction commits
+func Record(tx *sql.Tx, eventType string, data interface{}) error {
+	evt := event.Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		OccurredAt: time.Now(),
+		Data:       data,
+	}
+
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("gagal menyimpan event %s: %w", eventType, err)
+	}
+
+	_, err = tx.Exec(
+		"Insert Into Outbox_Event (Id, Event_Type, Payload, Created_At) Values ($1, $2, $3, $4)",
+		evt.ID, evt.Type, string(payload), evt.OccurredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("gagal menyimpan event %s: %w", eventType, err)
+	}
+	return nil
+}
+
+// OutboxRepository defines the interface for outbox dispatch operations
+type OutboxRepository interface {
+	ClaimPending(limit int, lease time.Duration) ([]Message, error)
+	GetConsumers(eventID string) (map[string]bool, error)
+	MarkConsumed(eventID string, subscriber string) error
+	MarkProcessed(eventID string) error
+	MarkFailed(eventID string, lastError string, nextAttemptAt time.Time) error
+	PurgeProcessed(before time.Time) (int64, error)
+}
+
+// OutboxRepositoryImpl implements the OutboxRepository interface
+type OutboxRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewOutboxRepository creates a new outbox repository instance
+func NewOutboxRepository(db *sql.DB) OutboxRepository {
+	return &OutboxRepositoryImpl{db: db}
+}
+
+// ClaimPending locks unprocessed events for the lease duration, oldest first
+func (r *OutboxRepositoryImpl) ClaimPending(limit int, lease time.Duration) ([]Message, error) {
+	rows, err := r.db.Query(`
+		With Due As (
+			Select Id From Outbox_Event
+			Where Processed_At Is Null And Next_Attempt_At <= Now()
+			Order By Created_At
+			Limit $1
+			For Update Skip Locked
+		)
+		Update Outbox_Event O
+		Set Next_Attempt_At = Now() + Make_Interval(Secs => $2)
+		From Due
+		Where O.Id = Due.Id
+		Returning O.Payload, O.Attempts`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil antrian event: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var payload []byte
+		var msg Message
+		if errScan := rows.Scan(&payload, &msg.Attempts); errScan != nil {
+			return nil, errScan
+		}
+		if errJSON := json.Unmarshal(payload, &msg.Event); errJSON != nil {
+			return nil, fmt.Errorf("gagal membaca event: %w", errJSON)
+		}
+		messages = append(messages, msg)
+	}
+
+	// The update does not preserve the order of the CTE
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Event.OccurredAt.Before(messages[j].Event.OccurredAt)
+	})
+	return messages, rows.Err()
+}
+
+// GetConsumers returns the subscribers that already handled the event
+func (r *OutboxRepositoryImpl) GetConsumers(eventID string) (map[string]bool, error) {
+	rows, err := r.db.Query("Select Subscriber From Outbox_Consumption Where Event_Id = $1", eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consumers := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if errScan := rows.Scan(&name); errScan != nil {
+			return nil, errScan
+		}
+		consumers[name] = true
+	}
+	return consumers, rows.Err()
+}
+
+// MarkConsumed records that a subscriber handled the event
+func (r *OutboxRepositoryImpl) MarkConsumed(eventID string, subscriber string) error {
+	_, err := r.db.Exec(
+		"Insert Into Outbox_Consumption (Event_Id, Subscriber) Values ($1, $2) On Conflict Do Nothing",
+		eventID, subscriber,
+	)
+	return err
+}
+
+// MarkProcessed marks the event as handled by every subscriber
+func (r *OutboxRepositoryImpl) MarkProcessed(eventID string) error {
+	_, err := r.db.Exec(
+		"Update Outbox_Event Set Processed_At = Now(), Attempts = Attempts + 1, Last_Error = Null Where Id = $1",
+		eventID,
+	)
+	return err
+}
+
+// MarkFailed schedules the event to be dispatched again
+func (r *OutboxRepositoryImpl) MarkFailed(eventID string, lastError string, nextAttemptAt time.Time) error {
+	_, err := r.db.Exec(
+		"Update Outbox_Event Set Attempts = Attempts + 1, Last_Error = $1, Next_Attempt_At = $2 Where Id = $3",
+		lastError, nextAttemptAt, eventID,
+	)
+	return err
+}
+
+// PurgeProcessed deletes events every subscriber handled before the given time, with their consumptions
+func (r *OutboxRepositoryImpl) PurgeProcessed(before time.Time) (int64, error) {
+	result, err := r.db.Exec("Delete From Outbox_Event Where Processed_At < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("gagal menghapus event lama: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,154 @@
+package outbox
+
+import (
+	"fmt"
+	"log"
+	"sinartimur-go/pkg/event"
+	"strings"
+	"time"
+)
+
+const (
+	// pollInterval is how often the dispatcher looks for new events
+	pollInterval = 2 * time.Second
+	// claimLease hides a claimed event from other dispatchers while its subscribers run
+	claimLease = time.Minute
+	// claimBatchSize is the number of events handled per pass
+	claimBatchSize = 50
+	// baseRetryDelay is doubled after every failed dispatch, up to maxRetryDelay
+	baseRetryDelay = 5 * time.Second
+	maxRetryDelay  = time.Hour
+	// retention is how long processed events are kept, webhook deliveries hold their own copy of the payload
+	retention = 7 * 24 * time.Hour
+	// purgeInterval is how often processed events past the retention are deleted
+	purgeInterval = time.Hour
+)
+
+// Dispatcher delivers outbox events to in-process subscribers at least once
+type Dispatcher struct {
+	repo        OutboxRepository
+	subscribers []subscriber
+}
+
+// NewDispatcher creates a new outbox dispatcher
+func NewDispatcher(repo OutboxRepository) *Dispatcher {
+	return &Dispatcher{repo: repo}
+}
+
+// Subscribe registers a handler under a stable name for the given event types, no types means every event.
+// The name is stored with each handled event, so renaming a subscriber makes it receive old events again.
+func (d *Dispatcher) Subscribe(name string, handler event.Handler, eventTypes ...string) {
+	types := make(map[string]bool, len(eventTypes))
+	for _, eventType := range eventTypes {
+		types[eventType] = true
+	}
+	d.subscribers = append(d.subscribers, subscriber{name: name, types: types, handler: handler})
+}
+
+// Start runs the dispatcher in the background, subscribers must be registered before
+func (d *Dispatcher) Start() {
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		var lastPurge time.Time
+		for {
+			d.dispatchPending()
+			if time.Since(lastPurge) >= purgeInterval {
+				d.purgeProcessed()
+				lastPurge = time.Now()
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// purgeProcessed deletes processed events older than the retention period
+func (d *Dispatcher) purgeProcessed() {
+	purged, err := d.repo.PurgeProcessed(time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("outbox: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("outbox: purged %d processed events", purged)
+	}
+}
+
+// dispatchPending handles every event that is due
+func (d *Dispatcher) dispatchPending() {
+	for {
+		messages, err := d.repo.ClaimPending(claimBatchSize, claimLease)
+		if err != nil {
+			log.Printf("outbox: %v", err)
+			return
+		}
+
+		for _, msg := range messages {
+			d.dispatch(msg)
+		}
+
+		if len(messages) < claimBatchSize {
+			return
+		}
+	}
+}
+
+// dispatch hands the event to every subscriber that has not handled it yet
+func (d *Dispatcher) dispatch(msg Message) {
+	consumers, err := d.repo.GetConsumers(msg.Event.ID)
+	if err != nil {
+		log.Printf("outbox: failed to load consumers of %s: %v", msg.Event.ID, err)
+		return
+	}
+
+	var failures []string
+	for _, sub := range d.subscribers {
+		if !sub.accepts(msg.Event.Type) || consumers[sub.name] {
+			continue
+		}
+
+		if err := safeHandle(sub.handler, msg.Event); err != nil {
+			failures = append(failures, sub.name+": "+err.Error())
+			continue
+		}
+
+		if err := d.repo.MarkConsumed(msg.Event.ID, sub.name); err != nil {
+			failures = append(failures, sub.name+": "+err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		next := time.Now().Add(retryDelay(msg.Attempts))
+		if err := d.repo.MarkFailed(msg.Event.ID, strings.Join(failures, "; "), next); err != nil {
+			log.Printf("outbox: failed to reschedule %s: %v", msg.Event.ID, err)
+		}
+		return
+	}
+
+	if err := d.repo.MarkProcessed(msg.Event.ID); err != nil {
+		log.Printf("outbox: failed to mark %s as processed: %v", msg.Event.ID, err)
+	}
+}
+
+// safeHandle runs a handler, turning a panic into an error so one subscriber cannot stop the dispatcher
+func safeHandle(handler event.Handler, evt event.Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return handler(evt)
+}
+
+// retryDelay returns the wait before the next dispatch after the given number of previous attempts
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 0; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,168 @@
+package payment
+
+import "sinartimur-go/utils"
+
+// Payment is a customer payment (pembayaran pelanggan) settling one or more paylater invoices
+type Payment struct {
+	ID            string              `json:"id"`
+	SerialID      string              `json:"serial_id"`
+	BranchID      *string             `json:"branch_id,omitempty"`
+	BranchName    *string             `json:"branch_name,omitempty"`
+	CustomerID    string              `json:"customer_id"`
+	CustomerName  string              `json:"customer_name"`
+	PaymentDate   string              `json:"payment_date"`
+	PaymentMethod string              `json:"payment_method"`
+	Amount        float64             `json:"amount"`
+	Reference     *string             `json:"reference,omitempty"`
+	Notes         *string             `json:"notes,omitempty"`
+	Status        string              `json:"status"`
+	CreatedBy     *string             `json:"created_by,omitempty"`
+	CreatedByName *string             `json:"created_by_name,omitempty"`
+	CreatedAt     string              `json:"created_at"`
+	CancelledAt   *string             `json:"cancelled_at,omitempty"`
+	Allocations   []PaymentAllocation `json:"allocations,omitempty"`
+}
+
+// PaymentAllocation is the part of a payment settling an invoice
+type PaymentAllocation struct {
+	ID                 string  `json:"id"`
+	SalesInvoiceID     string  `json:"sales_invoice_id"`
+	SalesInvoiceSerial string  `json:"sales_invoice_serial"`
+	InvoiceDate        string  `json:"invoice_date"`
+	DueDate            *string `json:"due_date,omitempty"`
+	InvoiceTotal       float64 `json:"invoice_total"`
+	Amount             float64 `json:"amount"`
+}
+
+// InvoiceBalance is the paid and outstanding amount of an invoice
+type InvoiceBalance struct {
+	SalesInvoiceID    string
+	SerialID          string
+	CustomerID        string
+	BranchID          *string
+	PaymentMethod     string
+	TotalAmount       float64
+	OutstandingAmount float64
+	Cancelled         bool
+}
+
+// GetPaymentsRequest holds query parameters for listing payments
+type GetPaymentsRequest struct {
+	Search     string `json:"search" validate:"omitempty,max=255"`
+	CustomerID string `json:"customer_id" validate:"omitempty,uuid"`
+	Status     string `json:"status" validate:"omitempty,oneof=active cancelled"`
+	StartDate  string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate    string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	BranchID   string `json:"-"`
+	utils.PaginationParameter
+}
+
+// PaymentAllocationRequest is the amount of a payment put on an invoice
+type PaymentAllocationRequest struct {
+	SalesInvoiceID string  `json:"sales_invoice_id" validate:"required,uuid"`
+	Amount         float64 `json:"amount" validate:"required,gt=0"`
+}
+
+// CreatePaymentRequest holds data needed to record a customer payment, the allocations must add up to the amount
+type CreatePaymentRequest struct {
+	CustomerID    string                     `json:"customer_id" validate:"required,uuid"`
+	PaymentDate   string                     `json:"payment_date" validate:"omitempty,datetime=2006-01-02"` // Today when empty
+	PaymentMethod string                     `json:"payment_method" validate:"required,oneof=cash transfer giro"`
+	Amount        float64                    `json:"amount" validate:"required,gt=0"`
+	Reference     string                     `json:"reference" validate:"omitempty,max=255"`
+	Notes         string                     `json:"notes" validate:"omitempty,max=1000"`
+	Allocations   []PaymentAllocationRequest `json:"allocations" validate:"required,min=1,dive"`
+	BranchID      string                     `json:"-"`
+}
+
+// CustomerBalanceRequest limits the ledger of a customer balance to a period
+type CustomerBalanceRequest struct {
+	CustomerID string `json:"-" validate:"required,uuid"`
+	StartDate  string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate    string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	BranchID   string `json:"-"`
+}
+
+// CustomerBalance is the receivable of a customer with the ledger that makes it up
+type CustomerBalance struct {
+	CustomerID     string         `json:"customer_id"`
+	CustomerName   string         `json:"customer_name"`
+	OpeningBalance float64        `json:"opening_balance"` // Receivable before StartDate
+	TotalInvoiced  float64        `json:"total_invoiced"`
+	TotalPaid      float64        `json:"total_paid"`
+	TotalReturned  float64        `json:"total_returned"`
+	ClosingBalance float64        `json:"closing_balance"`
+	OverdueAmount  float64        `json:"overdue_amount"` // Outstanding on invoices past their due date, as of today
+	Entries        []BalanceEntry `json:"entries"`
+}
+
+// BalanceEntry is an invoice, payment, return or credit note in the ledger of a customer with the balance after it
+type BalanceEntry struct {
+	Date       string  `json:"date"`
+	Type       string  `json:"type"` // invoice, payment, return or credit_note
+	DocumentID string  `json:"document_id"`
+	SerialID   string  `json:"serial_id"`
+	Debit      float64 `json:"debit"`
+	Credit     float64 `json:"credit"`
+	Balance    float64 `json:"balance"`
+}
+
+// Aging buckets of outstanding invoices by days past their due date
+const (
+	AgingCurrent = "current"
+	Aging1To30   = "1_30"
+	Aging31To60  = "31_60"
+	Aging61To90  = "61_90"
+	AgingOver90  = "over_90"
+)
+
+// AgingRequest holds the parameters of the receivable aging report
+type AgingRequest struct {
+	AsOf       string `json:"as_of" validate:"omitempty,datetime=2006-01-02"` // Today when empty
+	CustomerID string `json:"-" validate:"omitempty,uuid"`
+	BranchID   string `json:"-"`
+}
+
+// AgingInvoice is an outstanding paylater invoice as of the report date
+type AgingInvoice struct {
+	SalesInvoiceID    string  `json:"sales_invoice_id"`
+	SerialID          string  `json:"serial_id"`
+	SalesOrderSerial  string  `json:"sales_order_serial"`
+	CustomerID        string  `json:"customer_id"`
+	CustomerName      string  `json:"customer_name"`
+	InvoiceDate       string  `json:"invoice_date"`
+	DueDate           string  `json:"due_date"`
+	TotalAmount       float64 `json:"total_amount"`
+	PaidAmount        float64 `json:"paid_amount"`
+	ReturnedAmount    float64 `json:"returned_amount"`
+	OutstandingAmount float64 `json:"outstanding_amount"`
+	DaysPastDue       int     `json:"days_past_due"`
+	Bucket            string  `json:"bucket"`
+}
+
+// AgingCustomer is the outstanding receivable of a customer spread over the aging buckets
+type AgingCustomer struct {
+	CustomerID   string  `json:"customer_id"`
+	CustomerName string  `json:"customer_name"`
+	InvoiceCount int     `json:"invoice_count"`
+	Current      float64 `json:"current"`
+	Days1To30    float64 `json:"days_1_30"`
+	Days31To60   float64 `json:"days_31_60"`
+	Days61To90   float64 `json:"days_61_90"`
+	Over90       float64 `json:"over_90"`
+	Total        float64 `json:"total"`
+}
+
+// AgingReport is the receivable aging of every customer with an outstanding balance
+type AgingReport struct {
+	AsOf      string          `json:"as_of"`
+	Customers []AgingCustomer `json:"customers"`
+	Totals    AgingCustomer   `json:"totals"`
+}
+
+// AgingCustomerReport is the drill-down of the aging report into the invoices of a customer
+type AgingCustomerReport struct {
+	AsOf     string         `json:"as_of"`
+	Customer AgingCustomer  `json:"customer"`
+	Invoices []AgingInvoice `json:"invoices"`
+}
@@ -0,0 +1,429 @@
+package payment
+
+import (
+	"database/sql"
+	"fmt"
+	"sinartimur-go/internal/outbox"
+	"sinartimur-go/pkg/event"
+	"sinartimur-go/utils"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PaymentRepository defines the interface for customer payment data operations
+type PaymentRepository interface {
+	GetAll(req GetPaymentsRequest) ([]Payment, int, error)
+	GetByID(id string) (*Payment, error)
+	GetAllocations(id string) ([]PaymentAllocation, error)
+	Create(req CreatePaymentRequest, userID string) (string, error)
+	Cancel(id, userID string) error
+	InvoiceBalances(ids []string) ([]InvoiceBalance, error)
+	CustomerName(id string) (string, error)
+	CustomerBalance(req CustomerBalanceRequest) (*CustomerBalance, error)
+	AgingInvoices(req AgingRequest) ([]AgingInvoice, error)
+}
+
+// PaymentRepositoryImpl implements the PaymentRepository interface
+type PaymentRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewPaymentRepository creates a new payment repository instance
+func NewPaymentRepository(db *sql.DB) PaymentRepository {
+	return &PaymentRepositoryImpl{db: db}
+}
+
+// paymentStatusExpr reports a payment as active until it is cancelled
+const paymentStatusExpr = `Case When Cp.Cancelled_At Is Null Then 'active' Else 'cancelled' End`
+
+const paymentColumns = `Cp.Id, Cp.Serial_Id, Cp.Branch_Id, B.Name, Cp.Customer_Id, C.Name, Cp.Payment_Date, Cp.Payment_Method,
+	Cp.Amount, Cp.Reference, Cp.Notes, ` + paymentStatusExpr + `, Cp.Created_By, Au.Username, Cp.Created_At, Cp.Cancelled_At`
+
+const paymentJoins = `
+	From Customer_Payment Cp
+	Join Customer C On C.Id = Cp.Customer_Id
+	Left Join Branch B On B.Id = Cp.Branch_Id
+	Left Join Appuser Au On Au.Id = Cp.Created_By`
+
+// paymentSortColumns lists the columns payments can be sorted by
+var paymentSortColumns = map[string]string{
+	"serial_id":     "Cp.Serial_Id",
+	"customer_name": "C.Name",
+	"payment_date":  "Cp.Payment_Date",
+	"amount":        "Cp.Amount",
+	"created_at":    "Cp.Created_At",
+}
+
+// scanPayment scans a payment row selected with paymentColumns
+func scanPayment(row interface{ Scan(...interface{}) error }) (*Payment, error) {
+	var p Payment
+	var paymentDate, createdAt time.Time
+	var cancelledAt sql.NullTime
+	err := row.Scan(&p.ID, &p.SerialID, &p.BranchID, &p.BranchName, &p.CustomerID, &p.CustomerName, &paymentDate,
+		&p.PaymentMethod, &p.Amount, &p.Reference, &p.Notes, &p.Status, &p.CreatedBy, &p.CreatedByName, &createdAt,
+		&cancelledAt)
+	if err != nil {
+		return nil, err
+	}
+	p.PaymentDate = paymentDate.Format(time.RFC3339)
+	p.CreatedAt = createdAt.Format(time.RFC3339)
+	if cancelledAt.Valid {
+		cancelled := cancelledAt.Time.Format(time.RFC3339)
+		p.CancelledAt = &cancelled
+	}
+	return &p, nil
+}
+
+// GetAll fetches payments with pagination, branch users only see their branch
+func (r *PaymentRepositoryImpl) GetAll(req GetPaymentsRequest) ([]Payment, int, error) {
+	qb := utils.NewQueryBuilder("Select " + paymentColumns + paymentJoins + " Where 1=1")
+	qb.AddSearch(req.Search, "Cp.Serial_Id", "C.Name", "Cp.Reference")
+	qb.AddFilter("Cp.Branch_Id =", req.BranchID)
+	qb.AddFilter("Cp.Customer_Id =", req.CustomerID)
+	qb.AddFilter(paymentStatusExpr+" =", req.Status)
+	qb.AddFilter("Cp.Payment_Date >=", req.StartDate)
+	qb.AddFilterExpr("Cp.Payment_Date < $?::date + 1", req.EndDate)
+
+	var totalItems int
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Payments", qb.Query.String())
+	if err := r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems); err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung pembayaran: %w", err)
+	}
+
+	if column, ok := paymentSortColumns[req.SortBy]; ok && req.SortOrder != "" {
+		qb.Query.WriteString(fmt.Sprintf(" Order By %s %s", column, req.SortOrder))
+	} else {
+		qb.Query.WriteString(" Order By Cp.Payment_Date Desc, Cp.Serial_Id Desc")
+	}
+	qb.AddPagination(req.PaginationParameter)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil pembayaran: %w", err)
+	}
+	defer rows.Close()
+
+	payments := []Payment{}
+	for rows.Next() {
+		p, errScan := scanPayment(rows)
+		if errScan != nil {
+			return nil, 0, fmt.Errorf("gagal membaca pembayaran: %w", errScan)
+		}
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(*p); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
+		payments = append(payments, *p)
+	}
+
+	return payments, totalItems, rows.Err()
+}
+
+// GetByID fetches a payment header, sql.ErrNoRows means it does not exist
+func (r *PaymentRepositoryImpl) GetByID(id string) (*Payment, error) {
+	return scanPayment(r.db.QueryRow("Select "+paymentColumns+paymentJoins+" Where Cp.Id = $1", id))
+}
+
+// GetAllocations fetches the invoices a payment settles
+func (r *PaymentRepositoryImpl) GetAllocations(id string) ([]PaymentAllocation, error) {
+	rows, err := r.db.Query(`
+		Select Cpa.Id, Si.Id, Si.Serial_Id, Si.Invoice_Date, So.Payment_Due_Date, Si.Total_Amount, Cpa.Amount
+		From Customer_Payment_Allocation Cpa
+		Join Sales_Invoice Si On Si.Id = Cpa.Sales_Invoice_Id
+		Join Sales_Order So On So.Id = Si.Sales_Order_Id
+		Where Cpa.Customer_Payment_Id = $1
+		Order By Si.Invoice_Date, Si.Serial_Id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil alokasi pembayaran: %w", err)
+	}
+	defer rows.Close()
+
+	allocations := []PaymentAllocation{}
+	for rows.Next() {
+		var a PaymentAllocation
+		var invoiceDate time.Time
+		var dueDate sql.NullTime
+		if errScan := rows.Scan(&a.ID, &a.SalesInvoiceID, &a.SalesInvoiceSerial, &invoiceDate, &dueDate,
+			&a.InvoiceTotal, &a.Amount); errScan != nil {
+			return nil, fmt.Errorf("gagal membaca alokasi pembayaran: %w", errScan)
+		}
+		a.InvoiceDate = invoiceDate.Format(time.RFC3339)
+		if dueDate.Valid {
+			due := dueDate.Time.Format(time.RFC3339)
+			a.DueDate = &due
+		}
+		allocations = append(allocations, a)
+	}
+	return allocations, rows.Err()
+}
+
+// Create records a payment with its allocations and posts it to the finance log, sql.ErrNoRows means
+// an invoice was paid or cancelled meanwhile and no longer has the allocated amount outstanding
+func (r *PaymentRepositoryImpl) Create(req CreatePaymentRequest, userID string) (string, error) {
+	var id string
+	err := utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		invoiceIDs := make([]string, 0, len(req.Allocations))
+		for _, allocation := range req.Allocations {
+			invoiceIDs = append(invoiceIDs, allocation.SalesInvoiceID)
+		}
+
+		// Lock the invoices so two payments can not settle the same outstanding amount
+		if _, err := tx.Exec("Select Id From Sales_Invoice Where Id = Any($1::uuid[]) Order By Id For Update",
+			pq.Array(invoiceIDs)); err != nil {
+			return fmt.Errorf("gagal mengunci faktur: %w", err)
+		}
+		for _, allocation := range req.Allocations {
+			var outstanding float64
+			var status string
+			err := tx.QueryRow("Select Outstanding_Amount, Payment_Status From Sales_Invoice_Balance Where Id = $1",
+				allocation.SalesInvoiceID).Scan(&outstanding, &status)
+			if err != nil {
+				return fmt.Errorf("gagal memeriksa sisa tagihan: %w", err)
+			}
+			if status == "cancelled" || allocation.Amount > outstanding {
+				return sql.ErrNoRows
+			}
+		}
+
+		serialID, err := utils.GenerateNextBranchSerialID(tx, "PAY", req.BranchID)
+		if err != nil {
+			return fmt.Errorf("gagal membuat serial ID: %w", err)
+		}
+
+		var paymentDate time.Time
+		err = tx.QueryRow(`
+			Insert Into Customer_Payment (Branch_Id, Serial_Id, Customer_Id, Payment_Date, Payment_Method, Amount, Reference, Notes, Created_By)
+			Values ($1, $2, $3, Coalesce(Nullif($4, '')::timestamptz, Now()), $5, $6, Nullif($7, ''), Nullif($8, ''), Nullif($9, '')::uuid)
+			Returning Id, Payment_Date`,
+			req.BranchID, serialID, req.CustomerID, req.PaymentDate, req.PaymentMethod, req.Amount, req.Reference,
+			req.Notes, userID).Scan(&id, &paymentDate)
+		if err != nil {
+			return fmt.Errorf("gagal membuat pembayaran: %w", err)
+		}
+
+		for _, allocation := range req.Allocations {
+			if _, err = tx.Exec(`
+				Insert Into Customer_Payment_Allocation (Customer_Payment_Id, Sales_Invoice_Id, Amount)
+				Values ($1, $2, $3)`,
+				id, allocation.SalesInvoiceID, allocation.Amount); err != nil {
+				return fmt.Errorf("gagal menambahkan alokasi pembayaran: %w", err)
+			}
+		}
+
+		// The sale was booked as income when invoiced, the posting records the money received
+		if _, err = tx.Exec(`
+			Insert Into Financial_Transaction_Log (
+				Branch_Id, User_Id, Amount, Type, Customer_Payment_Id, Description, Transaction_Date, Is_System
+			) Values ($1, Nullif($2, '')::uuid, $3, 'debit', $4, $5, $6, True)`,
+			req.BranchID, userID, req.Amount, id, fmt.Sprintf("Pembayaran Pelanggan %s", serialID),
+			paymentDate); err != nil {
+			return fmt.Errorf("gagal mencatat transaksi keuangan: %w", err)
+		}
+
+		return outbox.Record(tx, event.CustomerPaymentCreated, map[string]interface{}{
+			"payment_id":  id,
+			"serial_id":   serialID,
+			"customer_id": req.CustomerID,
+			"amount":      req.Amount,
+			"allocations": req.Allocations,
+			"created_by":  userID,
+		})
+	})
+	return id, err
+}
+
+// Cancel cancels a payment and its finance posting, sql.ErrNoRows means it was already cancelled
+func (r *PaymentRepositoryImpl) Cancel(id, userID string) error {
+	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			Update Customer_Payment
+			Set Cancelled_At = Now(), Cancelled_By = Nullif($1, '')::uuid
+			Where Id = $2 And Cancelled_At Is Null`,
+			userID, id)
+		if err != nil {
+			return fmt.Errorf("gagal membatalkan pembayaran: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return sql.ErrNoRows
+		}
+
+		if _, err = tx.Exec(`
+			Update Financial_Transaction_Log
+			Set Deleted_At = Now(), Edited_At = Now()
+			Where Customer_Payment_Id = $1 And Deleted_At Is Null`, id); err != nil {
+			return fmt.Errorf("gagal membatalkan transaksi keuangan: %w", err)
+		}
+
+		return outbox.Record(tx, event.CustomerPaymentCancelled, map[string]string{
+			"payment_id":   id,
+			"cancelled_by": userID,
+		})
+	})
+}
+
+// InvoiceBalances fetches the outstanding amount of the given invoices, unknown invoices are left out
+func (r *PaymentRepositoryImpl) InvoiceBalances(ids []string) ([]InvoiceBalance, error) {
+	rows, err := r.db.Query(`
+		Select B.Id, Si.Serial_Id, B.Customer_Id, B.Branch_Id, B.Payment_Method, B.Total_Amount, B.Outstanding_Amount,
+			B.Payment_Status = 'cancelled'
+		From Sales_Invoice_Balance B
+		Join Sales_Invoice Si On Si.Id = B.Id
+		Where B.Id = Any($1::uuid[])`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil sisa tagihan: %w", err)
+	}
+	defer rows.Close()
+
+	var balances []InvoiceBalance
+	for rows.Next() {
+		var b InvoiceBalance
+		if err = rows.Scan(&b.SalesInvoiceID, &b.SerialID, &b.CustomerID, &b.BranchID, &b.PaymentMethod, &b.TotalAmount,
+			&b.OutstandingAmount, &b.Cancelled); err != nil {
+			return nil, fmt.Errorf("gagal membaca sisa tagihan: %w", err)
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+// CustomerName fetches the name of a customer, sql.ErrNoRows means it does not exist or is deleted
+func (r *PaymentRepositoryImpl) CustomerName(id string) (string, error) {
+	var name string
+	err := r.db.QueryRow("Select Name From Customer Where Id = $1 And Deleted_At Is Null", id).Scan(&name)
+	return name, err
+}
+
+// CustomerBalance builds the receivable ledger of a customer from its paylater invoices, payments, returns and
+// credit notes, the balance runs from the first entry so entries before StartDate make up the opening balance
+func (r *PaymentRepositoryImpl) CustomerBalance(req CustomerBalanceRequest) (*CustomerBalance, error) {
+	rows, err := r.db.Query(`
+		With Ledger As (
+			Select Si.Invoice_Date As Entry_Date, Si.Created_At, 'invoice' As Type, Si.Id, Si.Serial_Id,
+				Si.Total_Amount As Debit, 0::Numeric As Credit
+			From Sales_Invoice Si
+			Join Sales_Order So On So.Id = Si.Sales_Order_Id
+			Where So.Customer_Id = $1 And So.Payment_Method = 'paylater' And Si.Cancelled_At Is Null
+				And ($2 = '' Or So.Branch_Id = Nullif($2, '')::uuid)
+			Union All
+			Select Cp.Payment_Date, Cp.Created_At, 'payment', Cp.Id, Cp.Serial_Id, 0, Cp.Amount
+			From Customer_Payment Cp
+			Where Cp.Customer_Id = $1 And Cp.Cancelled_At Is Null
+				And ($2 = '' Or Cp.Branch_Id = Nullif($2, '')::uuid)
+			Union All
+			Select Sic.Credit_Date, Sic.Credit_Date, Sic.Type, Sic.Id, Sic.Serial_Id, 0, Sum(Sic.Amount)
+			From Sales_Invoice_Credit Sic
+			Join Sales_Invoice Si On Si.Id = Sic.Sales_Invoice_Id
+			Join Sales_Order So On So.Id = Si.Sales_Order_Id
+			Where So.Customer_Id = $1 And So.Payment_Method = 'paylater' And Si.Cancelled_At Is Null
+				And Sic.Cancelled_At Is Null And ($2 = '' Or So.Branch_Id = Nullif($2, '')::uuid)
+			Group By Sic.Credit_Date, Sic.Type, Sic.Id, Sic.Serial_Id
+		)
+		Select Entry_Date, Type, Id, Serial_Id, Debit, Credit,
+			Sum(Debit - Credit) Over (Order By Entry_Date, Created_At, Serial_Id),
+			($3 <> '' And Entry_Date < Nullif($3, '')::date)
+		From Ledger
+		Where $4 = '' Or Entry_Date < Nullif($4, '')::date + 1
+		Order By Entry_Date, Created_At, Serial_Id`,
+		req.CustomerID, req.BranchID, req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil buku piutang: %w", err)
+	}
+	defer rows.Close()
+
+	balance := CustomerBalance{CustomerID: req.CustomerID, Entries: []BalanceEntry{}}
+	for rows.Next() {
+		var entry BalanceEntry
+		var date time.Time
+		var beforeStart bool
+		if err = rows.Scan(&date, &entry.Type, &entry.DocumentID, &entry.SerialID, &entry.Debit, &entry.Credit,
+			&entry.Balance, &beforeStart); err != nil {
+			return nil, fmt.Errorf("gagal membaca buku piutang: %w", err)
+		}
+		if beforeStart {
+			balance.OpeningBalance = entry.Balance
+			continue
+		}
+		entry.Date = date.Format(time.RFC3339)
+		balance.TotalInvoiced += entry.Debit
+		if entry.Type == "return" || entry.Type == "credit_note" {
+			balance.TotalReturned += entry.Credit
+		} else {
+			balance.TotalPaid += entry.Credit
+		}
+		balance.Entries = append(balance.Entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("gagal membaca buku piutang: %w", err)
+	}
+	balance.ClosingBalance = balance.OpeningBalance + balance.TotalInvoiced - balance.TotalPaid - balance.TotalReturned
+
+	err = r.db.QueryRow(`
+		Select Coalesce(Sum(Outstanding_Amount), 0)
+		From Sales_Invoice_Balance
+		Where Customer_Id = $1 And Payment_Status = 'overdue'
+			And ($2 = '' Or Branch_Id = Nullif($2, '')::uuid)`,
+		req.CustomerID, req.BranchID).Scan(&balance.OverdueAmount)
+	if err != nil {
+		return nil, fmt.Errorf("gagal menghitung piutang jatuh tempo: %w", err)
+	}
+	return &balance, nil
+}
+
+// AgingInvoices fetches the paylater invoices still outstanding at the end of req.AsOf, counting only the
+// payments, returns, credit notes and cancellations made by then so past reports can be reproduced
+func (r *PaymentRepositoryImpl) AgingInvoices(req AgingRequest) ([]AgingInvoice, error) {
+	rows, err := r.db.Query(`
+		With Balances As (
+			Select Si.Id, Si.Serial_Id, So.Serial_Id As Sales_Order_Serial, So.Customer_Id, C.Name As Customer_Name,
+				Si.Invoice_Date, Coalesce(So.Payment_Due_Date, Si.Invoice_Date) As Due_Date, Si.Total_Amount,
+				(
+					Select Coalesce(Sum(Cpa.Amount), 0)
+					From Customer_Payment_Allocation Cpa
+					Join Customer_Payment Cp On Cp.Id = Cpa.Customer_Payment_Id
+					Where Cpa.Sales_Invoice_Id = Si.Id And Cp.Payment_Date < $1::date + 1
+						And (Cp.Cancelled_At Is Null Or Cp.Cancelled_At >= $1::date + 1)
+				) As Paid_Amount,
+				(
+					Select Coalesce(Sum(Sic.Amount), 0)
+					From Sales_Invoice_Credit Sic
+					Where Sic.Sales_Invoice_Id = Si.Id And Sic.Credit_Date < $1::date + 1
+						And (Sic.Cancelled_At Is Null Or Sic.Cancelled_At >= $1::date + 1)
+				) As Returned_Amount
+			From Sales_Invoice Si
+			Join Sales_Order So On So.Id = Si.Sales_Order_Id
+			Join Customer C On C.Id = So.Customer_Id
+			Where So.Payment_Method = 'paylater' And Si.Invoice_Date < $1::date + 1
+				And (Si.Cancelled_At Is Null Or Si.Cancelled_At >= $1::date + 1)
+				And ($2 = '' Or So.Branch_Id = Nullif($2, '')::uuid)
+				And ($3 = '' Or So.Customer_Id = Nullif($3, '')::uuid)
+		)
+		Select Id, Serial_Id, Sales_Order_Serial, Customer_Id, Customer_Name, Invoice_Date, Due_Date, Total_Amount,
+			Paid_Amount, Returned_Amount, Total_Amount - Paid_Amount - Returned_Amount, $1::date - Due_Date::date
+		From Balances
+		Where Total_Amount - Paid_Amount - Returned_Amount > 0
+		Order By Customer_Name, Customer_Id, Due_Date, Serial_Id`,
+		req.AsOf, req.BranchID, req.CustomerID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil umur piutang: %w", err)
+	}
+	defer rows.Close()
+
+	invoices := []AgingInvoice{}
+	for rows.Next() {
+		var invoice AgingInvoice
+		var invoiceDate, dueDate time.Time
+		if err = rows.Scan(&invoice.SalesInvoiceID, &invoice.SerialID, &invoice.SalesOrderSerial, &invoice.CustomerID,
+			&invoice.CustomerName, &invoiceDate, &dueDate, &invoice.TotalAmount, &invoice.PaidAmount,
+			&invoice.ReturnedAmount, &invoice.OutstandingAmount, &invoice.DaysPastDue); err != nil {
+			return nil, fmt.Errorf("gagal membaca umur piutang: %w", err)
+		}
+		invoice.InvoiceDate = invoiceDate.Format(time.RFC3339)
+		invoice.DueDate = dueDate.Format(time.RFC3339)
+		invoices = append(invoices, invoice)
+	}
+	return invoices, rows.Err()
+}
@@ -0,0 +1,337 @@
+package payment
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sinartimur-go/pkg/dto"
+	"sort"
+	"time"
+)
+
+// PaymentService is the service for customer payments and receivable balances
+type PaymentService struct {
+	repo PaymentRepository
+}
+
+// NewPaymentService creates a new instance of PaymentService
+func NewPaymentService(repo PaymentRepository) *PaymentService {
+	return &PaymentService{repo: repo}
+}
+
+// cents rounds an amount to whole cents so sums of allocations compare exactly
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// GetAll fetches payments with pagination
+func (s *PaymentService) GetAll(req GetPaymentsRequest) ([]Payment, int, *dto.APIError) {
+	payments, totalItems, err := s.repo.GetAll(req)
+	if err != nil {
+		return nil, 0, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data pembayaran",
+		})
+	}
+	return payments, totalItems, nil
+}
+
+// getPayment fetches a payment header, hiding payments of other branches from branch users
+func (s *PaymentService) getPayment(id, branchID string) (*Payment, *dto.APIError) {
+	payment, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Pembayaran tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data pembayaran",
+		})
+	}
+	if branchID != "" && payment.BranchID != nil && *payment.BranchID != branchID {
+		return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+			"general": "Pembayaran tidak ditemukan",
+		})
+	}
+	return payment, nil
+}
+
+// GetByID fetches a payment with the invoices it settles
+func (s *PaymentService) GetByID(id, branchID string) (*Payment, *dto.APIError) {
+	payment, apiErr := s.getPayment(id, branchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	allocations, err := s.repo.GetAllocations(id)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil alokasi pembayaran",
+		})
+	}
+	payment.Allocations = allocations
+	return payment, nil
+}
+
+// validateAllocations checks that every allocated invoice is an open paylater invoice of the customer
+// and that no invoice receives more than it has outstanding
+func (s *PaymentService) validateAllocations(req CreatePaymentRequest) *dto.APIError {
+	var total int64
+	seen := make(map[string]bool, len(req.Allocations))
+	ids := make([]string, 0, len(req.Allocations))
+	for _, allocation := range req.Allocations {
+		if seen[allocation.SalesInvoiceID] {
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": "Faktur tidak boleh dialokasikan lebih dari sekali",
+			})
+		}
+		seen[allocation.SalesInvoiceID] = true
+		ids = append(ids, allocation.SalesInvoiceID)
+		total += cents(allocation.Amount)
+	}
+	if total != cents(req.Amount) {
+		return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"allocations": "Total alokasi harus sama dengan jumlah pembayaran",
+		})
+	}
+
+	balances, err := s.repo.InvoiceBalances(ids)
+	if err != nil {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa sisa tagihan",
+		})
+	}
+	byID := make(map[string]InvoiceBalance, len(balances))
+	for _, balance := range balances {
+		byID[balance.SalesInvoiceID] = balance
+	}
+
+	for _, allocation := range req.Allocations {
+		balance, ok := byID[allocation.SalesInvoiceID]
+		if !ok || balance.CustomerID != req.CustomerID ||
+			(balance.BranchID != nil && *balance.BranchID != req.BranchID) {
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": "Faktur tidak ditemukan untuk pelanggan ini: " + allocation.SalesInvoiceID,
+			})
+		}
+		switch {
+		case balance.Cancelled:
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": "Faktur " + balance.SerialID + " sudah dibatalkan",
+			})
+		case balance.PaymentMethod != "paylater":
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": "Faktur " + balance.SerialID + " adalah penjualan tunai",
+			})
+		case cents(allocation.Amount) > cents(balance.OutstandingAmount):
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"allocations": fmt.Sprintf("Alokasi faktur %s melebihi sisa tagihan %.2f", balance.SerialID,
+					balance.OutstandingAmount),
+			})
+		}
+	}
+	return nil
+}
+
+// Create records a customer payment in the active branch and allocates it over paylater invoices
+func (s *PaymentService) Create(req CreatePaymentRequest, userID string) (*Payment, *dto.APIError) {
+	// Payments are numbered per branch like the invoices they settle
+	if req.BranchID == "" {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Pilih cabang aktif untuk mencatat pembayaran",
+		})
+	}
+
+	if _, err := s.repo.CustomerName(req.CustomerID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"customer_id": "Pelanggan tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa pelanggan",
+		})
+	}
+	if apiErr := s.validateAllocations(req); apiErr != nil {
+		return nil, apiErr
+	}
+
+	id, err := s.repo.Create(req, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusConflict, map[string]string{
+				"general": "Sisa tagihan faktur sudah berubah, muat ulang data",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mencatat pembayaran",
+		})
+	}
+	return s.GetByID(id, "")
+}
+
+// Cancel cancels a payment, the invoices it settled become outstanding again
+func (s *PaymentService) Cancel(id, branchID, userID string) (*Payment, *dto.APIError) {
+	payment, apiErr := s.getPayment(id, branchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if payment.CancelledAt != nil {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Pembayaran sudah dibatalkan",
+		})
+	}
+
+	if err := s.repo.Cancel(id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusConflict, map[string]string{
+				"general": "Pembayaran sudah dibatalkan, muat ulang data",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membatalkan pembayaran",
+		})
+	}
+	return s.GetByID(id, "")
+}
+
+// CustomerBalance fetches the receivable of a customer with its ledger over the requested period
+func (s *PaymentService) CustomerBalance(req CustomerBalanceRequest) (*CustomerBalance, *dto.APIError) {
+	name, err := s.repo.CustomerName(req.CustomerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Pelanggan tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa pelanggan",
+		})
+	}
+	if req.StartDate != "" && req.EndDate != "" && req.EndDate < req.StartDate {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"end_date": "Tanggal akhir tidak boleh sebelum tanggal awal",
+		})
+	}
+
+	balance, err := s.repo.CustomerBalance(req)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil saldo piutang pelanggan",
+		})
+	}
+	balance.CustomerName = name
+	return balance, nil
+}
+
+// agingBucket returns the aging bucket of an invoice the given number of days past its due date
+func agingBucket(daysPastDue int) string {
+	switch {
+	case daysPastDue <= 0:
+		return AgingCurrent
+	case daysPastDue <= 30:
+		return Aging1To30
+	case daysPastDue <= 60:
+		return Aging31To60
+	case daysPastDue <= 90:
+		return Aging61To90
+	default:
+		return AgingOver90
+	}
+}
+
+// addToAging adds the outstanding amount of an invoice to its bucket of a customer row
+func addToAging(row *AgingCustomer, invoice AgingInvoice) {
+	switch invoice.Bucket {
+	case AgingCurrent:
+		row.Current += invoice.OutstandingAmount
+	case Aging1To30:
+		row.Days1To30 += invoice.OutstandingAmount
+	case Aging31To60:
+		row.Days31To60 += invoice.OutstandingAmount
+	case Aging61To90:
+		row.Days61To90 += invoice.OutstandingAmount
+	default:
+		row.Over90 += invoice.OutstandingAmount
+	}
+	row.Total += invoice.OutstandingAmount
+	row.InvoiceCount++
+}
+
+// agingInvoices fetches the outstanding invoices as of the requested date, today when none is given
+func (s *PaymentService) agingInvoices(req *AgingRequest) ([]AgingInvoice, *dto.APIError) {
+	if req.AsOf == "" {
+		req.AsOf = time.Now().Format("2006-01-02")
+	}
+
+	invoices, err := s.repo.AgingInvoices(*req)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil umur piutang",
+		})
+	}
+	for i := range invoices {
+		invoices[i].Bucket = agingBucket(invoices[i].DaysPastDue)
+	}
+	return invoices, nil
+}
+
+// Aging reports the outstanding receivable of every customer bucketed by days past due, largest first
+func (s *PaymentService) Aging(req AgingRequest) (*AgingReport, *dto.APIError) {
+	invoices, apiErr := s.agingInvoices(&req)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	report := AgingReport{AsOf: req.AsOf, Customers: []AgingCustomer{}}
+	index := make(map[string]int)
+	for _, invoice := range invoices {
+		i, ok := index[invoice.CustomerID]
+		if !ok {
+			i = len(report.Customers)
+			index[invoice.CustomerID] = i
+			report.Customers = append(report.Customers, AgingCustomer{
+				CustomerID:   invoice.CustomerID,
+				CustomerName: invoice.CustomerName,
+			})
+		}
+		addToAging(&report.Customers[i], invoice)
+		addToAging(&report.Totals, invoice)
+	}
+	sort.SliceStable(report.Customers, func(i, j int) bool {
+		return report.Customers[i].Total > report.Customers[j].Total
+	})
+	return &report, nil
+}
+
+// CustomerAging drills the aging report down into the outstanding invoices of a customer
+func (s *PaymentService) CustomerAging(req AgingRequest) (*AgingCustomerReport, *dto.APIError) {
+	name, err := s.repo.CustomerName(req.CustomerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Pelanggan tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa pelanggan",
+		})
+	}
+
+	invoices, apiErr := s.agingInvoices(&req)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	report := AgingCustomerReport{
+		AsOf:     req.AsOf,
+		Customer: AgingCustomer{CustomerID: req.CustomerID, CustomerName: name},
+		Invoices: invoices,
+	}
+	for _, invoice := range invoices {
+		addToAging(&report.Customer, invoice)
+	}
+	return &report, nil
+}
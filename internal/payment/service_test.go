@@ -0,0 +1,27 @@
+package payment
+
+import "testing"
+
+func TestAgingBucket(t *testing.T) {
+	tests := []struct {
+		daysPastDue int
+		want        string
+	}{
+		{-5, AgingCurrent},
+		{0, AgingCurrent},
+		{1, Aging1To30},
+		{30, Aging1To30},
+		{31, Aging31To60},
+		{60, Aging31To60},
+		{61, Aging61To90},
+		{90, Aging61To90},
+		{91, AgingOver90},
+		{365, AgingOver90},
+	}
+
+	for _, tt := range tests {
+		if got := agingBucket(tt.daysPastDue); got != tt.want {
+			t.Errorf("agingBucket(%d) = %q, want %q", tt.daysPastDue, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package pricelist
+
+import "sinartimur-go/utils"
+
+// PriceList is a set of product prices offered to the customers assigned to it
+type PriceList struct {
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	Description   *string         `json:"description,omitempty"`
+	IsActive      bool            `json:"is_active"`
+	ItemCount     int             `json:"item_count"`
+	CustomerCount int             `json:"customer_count"`
+	CreatedAt     string          `json:"created_at"`
+	UpdatedAt     string          `json:"updated_at"`
+	Items         []PriceListItem `json:"items,omitempty"`
+}
+
+// PriceListItem is the price of a product from a minimum quantity, optionally within a validity period
+type PriceListItem struct {
+	ID          string  `json:"id"`
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	UnitName    *string `json:"unit_name,omitempty"`
+	MinQuantity float64 `json:"min_quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	ValidFrom   *string `json:"valid_from,omitempty"`
+	ValidUntil  *string `json:"valid_until,omitempty"`
+}
+
+// GetPriceListsRequest holds query parameters for listing price lists
+type GetPriceListsRequest struct {
+	Search   string `json:"search" validate:"omitempty,max=255"`
+	IsActive string `json:"is_active" validate:"omitempty,oneof=true false"`
+	utils.PaginationParameter
+}
+
+// PriceListItemRequest is a product price of a price list, the minimum quantity defaults to 1
+type PriceListItemRequest struct {
+	ProductID   string  `json:"product_id" validate:"required,uuid"`
+	MinQuantity float64 `json:"min_quantity" validate:"omitempty,gt=0"`
+	UnitPrice   float64 `json:"unit_price" validate:"required,gt=0"`
+	ValidFrom   string  `json:"valid_from" validate:"omitempty,datetime=2006-01-02"`
+	ValidUntil  string  `json:"valid_until" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// CreatePriceListRequest holds data needed to create a price list
+type CreatePriceListRequest struct {
+	Name        string                 `json:"name" validate:"required,min=2,max=255"`
+	Description string                 `json:"description" validate:"omitempty,max=1000"`
+	IsActive    *bool                  `json:"is_active"` // Active when not given
+	Items       []PriceListItemRequest `json:"items" validate:"omitempty,dive"`
+}
+
+// UpdatePriceListRequest replaces a price list and its prices
+type UpdatePriceListRequest struct {
+	ID          string                 `json:"-" validate:"required,uuid"`
+	Name        string                 `json:"name" validate:"required,min=2,max=255"`
+	Description string                 `json:"description" validate:"omitempty,max=1000"`
+	IsActive    *bool                  `json:"is_active"` // Active when not given
+	Items       []PriceListItemRequest `json:"items" validate:"omitempty,dive"`
+}
@@ -0,0 +1,262 @@
+package pricelist
+
+import (
+	"database/sql"
+	"fmt"
+	"sinartimur-go/utils"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PriceListRepository defines the interface for price list data operations
+type PriceListRepository interface {
+	GetAll(req GetPriceListsRequest) ([]PriceList, int, error)
+	GetByID(id string) (*PriceList, error)
+	GetItems(id string) ([]PriceListItem, error)
+	Create(req CreatePriceListRequest) (string, error)
+	Update(req UpdatePriceListRequest) error
+	Delete(id string) error
+	NameTaken(name, excludeID string) (bool, error)
+	MissingProducts(ids []string) ([]string, error)
+}
+
+// PriceListRepositoryImpl implements the PriceListRepository interface
+type PriceListRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewPriceListRepository creates a new price list repository instance
+func NewPriceListRepository(db *sql.DB) PriceListRepository {
+	return &PriceListRepositoryImpl{db: db}
+}
+
+const priceListColumns = `Pl.Id, Pl.Name, Pl.Description, Pl.Is_Active,
+	(Select Count(*) From Price_List_Item Pli Where Pli.Price_List_Id = Pl.Id),
+	(Select Count(*) From Customer C Where C.Price_List_Id = Pl.Id And C.Deleted_At Is Null),
+	Pl.Created_At, Pl.Updated_At`
+
+// priceListSortColumns lists the columns price lists can be sorted by
+var priceListSortColumns = map[string]string{
+	"name":       "Pl.Name",
+	"is_active":  "Pl.Is_Active",
+	"created_at": "Pl.Created_At",
+	"updated_at": "Pl.Updated_At",
+}
+
+// scanPriceList scans a price list row selected with priceListColumns
+func scanPriceList(row interface{ Scan(...interface{}) error }) (*PriceList, error) {
+	var pl PriceList
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&pl.ID, &pl.Name, &pl.Description, &pl.IsActive, &pl.ItemCount, &pl.CustomerCount,
+		&createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	pl.CreatedAt = createdAt.Format(time.RFC3339)
+	pl.UpdatedAt = updatedAt.Format(time.RFC3339)
+	return &pl, nil
+}
+
+// GetAll fetches price lists with pagination
+func (r *PriceListRepositoryImpl) GetAll(req GetPriceListsRequest) ([]PriceList, int, error) {
+	qb := utils.NewQueryBuilder("Select " + priceListColumns + " From Price_List Pl Where Pl.Deleted_At Is Null")
+	qb.AddSearch(req.Search, "Pl.Name", "Pl.Description")
+	qb.AddFilter("Pl.Is_Active =", req.IsActive)
+
+	var totalItems int
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Price_Lists", qb.Query.String())
+	if err := r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems); err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung daftar harga: %w", err)
+	}
+
+	if column, ok := priceListSortColumns[req.SortBy]; ok && req.SortOrder != "" {
+		qb.Query.WriteString(fmt.Sprintf(" Order By %s %s", column, req.SortOrder))
+	} else {
+		qb.Query.WriteString(" Order By Pl.Name")
+	}
+	qb.AddPagination(req.PaginationParameter)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil daftar harga: %w", err)
+	}
+	defer rows.Close()
+
+	priceLists := []PriceList{}
+	for rows.Next() {
+		pl, errScan := scanPriceList(rows)
+		if errScan != nil {
+			return nil, 0, fmt.Errorf("gagal membaca daftar harga: %w", errScan)
+		}
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(*pl); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
+		priceLists = append(priceLists, *pl)
+	}
+
+	return priceLists, totalItems, rows.Err()
+}
+
+// GetByID fetches a price list header, sql.ErrNoRows means it does not exist
+func (r *PriceListRepositoryImpl) GetByID(id string) (*PriceList, error) {
+	return scanPriceList(r.db.QueryRow("Select "+priceListColumns+" From Price_List Pl Where Pl.Id = $1 And Pl.Deleted_At Is Null", id))
+}
+
+// GetItems fetches the prices of a price list by product and quantity break
+func (r *PriceListRepositoryImpl) GetItems(id string) ([]PriceListItem, error) {
+	rows, err := r.db.Query(`
+		Select Pli.Id, Pli.Product_Id, P.Name, U.Name, Pli.Min_Quantity, Pli.Unit_Price, Pli.Valid_From, Pli.Valid_Until
+		From Price_List_Item Pli
+		Join Product P On P.Id = Pli.Product_Id
+		Left Join Unit U On U.Id = P.Unit_Id
+		Where Pli.Price_List_Id = $1
+		Order By P.Name, Pli.Min_Quantity, Pli.Valid_From Nulls First`, id)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil harga produk: %w", err)
+	}
+	defer rows.Close()
+
+	items := []PriceListItem{}
+	for rows.Next() {
+		var item PriceListItem
+		var validFrom, validUntil sql.NullTime
+		if errScan := rows.Scan(&item.ID, &item.ProductID, &item.ProductName, &item.UnitName,
+			&item.MinQuantity, &item.UnitPrice, &validFrom, &validUntil); errScan != nil {
+			return nil, fmt.Errorf("gagal membaca harga produk: %w", errScan)
+		}
+		if validFrom.Valid {
+			from := validFrom.Time.Format("2006-01-02")
+			item.ValidFrom = &from
+		}
+		if validUntil.Valid {
+			until := validUntil.Time.Format("2006-01-02")
+			item.ValidUntil = &until
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// insertItems inserts the product prices of a price list
+func insertItems(tx *sql.Tx, priceListID string, items []PriceListItemRequest) error {
+	for _, item := range items {
+		if _, err := tx.Exec(`
+			Insert Into Price_List_Item (Price_List_Id, Product_Id, Min_Quantity, Unit_Price, Valid_From, Valid_Until)
+			Values ($1, $2, $3, $4, Nullif($5, '')::date, Nullif($6, '')::date)`,
+			priceListID, item.ProductID, item.MinQuantity, item.UnitPrice, item.ValidFrom, item.ValidUntil); err != nil {
+			return fmt.Errorf("gagal menambahkan harga produk: %w", err)
+		}
+	}
+	return nil
+}
+
+// isActive defaults a missing active flag to true
+func isActive(flag *bool) bool {
+	return flag == nil || *flag
+}
+
+// Create creates a price list with its prices and returns its ID
+func (r *PriceListRepositoryImpl) Create(req CreatePriceListRequest) (string, error) {
+	var id string
+	err := utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+			Insert Into Price_List (Name, Description, Is_Active)
+			Values ($1, Nullif($2, ''), $3)
+			Returning Id`,
+			req.Name, req.Description, isActive(req.IsActive)).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("gagal membuat daftar harga: %w", err)
+		}
+		return insertItems(tx, id, req.Items)
+	})
+	return id, err
+}
+
+// Update replaces the header and prices of a price list, sql.ErrNoRows means it was deleted
+func (r *PriceListRepositoryImpl) Update(req UpdatePriceListRequest) error {
+	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			Update Price_List
+			Set Name = $1, Description = Nullif($2, ''), Is_Active = $3, Updated_At = Now()
+			Where Id = $4 And Deleted_At Is Null`,
+			req.Name, req.Description, isActive(req.IsActive), req.ID)
+		if err != nil {
+			return fmt.Errorf("gagal memperbarui daftar harga: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return sql.ErrNoRows
+		}
+
+		if _, err = tx.Exec("Delete From Price_List_Item Where Price_List_Id = $1", req.ID); err != nil {
+			return fmt.Errorf("gagal menghapus harga produk: %w", err)
+		}
+		return insertItems(tx, req.ID, req.Items)
+	})
+}
+
+// Delete soft deletes a price list and unassigns it from its customers, sql.ErrNoRows means it was already deleted
+func (r *PriceListRepositoryImpl) Delete(id string) error {
+	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		result, err := tx.Exec("Update Price_List Set Deleted_At = Now() Where Id = $1 And Deleted_At Is Null", id)
+		if err != nil {
+			return fmt.Errorf("gagal menghapus daftar harga: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return sql.ErrNoRows
+		}
+
+		if _, err = tx.Exec("Update Customer Set Price_List_Id = Null, Updated_At = Now() Where Price_List_Id = $1", id); err != nil {
+			return fmt.Errorf("gagal melepas daftar harga dari pelanggan: %w", err)
+		}
+		return nil
+	})
+}
+
+// NameTaken checks whether another price list already uses the name
+func (r *PriceListRepositoryImpl) NameTaken(name, excludeID string) (bool, error) {
+	var taken bool
+	err := r.db.QueryRow(`
+		Select Exists(
+			Select 1 From Price_List
+			Where Lower(Name) = Lower($1) And Deleted_At Is Null And Id::text <> $2
+		)`, name, excludeID).Scan(&taken)
+	return taken, err
+}
+
+// MissingProducts returns the given product IDs that do not exist or are deleted
+func (r *PriceListRepositoryImpl) MissingProducts(ids []string) ([]string, error) {
+	var found []string
+	rows, err := r.db.Query("Select Id From Product Where Id = Any($1::uuid[]) And Deleted_At Is Null", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		found = append(found, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+	var missing []string
+	for _, id := range ids {
+		if !exists[strings.ToLower(id)] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,168 @@
+package pricelist
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sinartimur-go/pkg/dto"
+	"strings"
+)
+
+// PriceListService is the service for price lists and their product prices
+type PriceListService struct {
+	repo PriceListRepository
+}
+
+// NewPriceListService creates a new instance of PriceListService
+func NewPriceListService(repo PriceListRepository) *PriceListService {
+	return &PriceListService{repo: repo}
+}
+
+// GetAll fetches price lists with pagination
+func (s *PriceListService) GetAll(req GetPriceListsRequest) ([]PriceList, int, *dto.APIError) {
+	priceLists, totalItems, err := s.repo.GetAll(req)
+	if err != nil {
+		return nil, 0, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data daftar harga",
+		})
+	}
+	return priceLists, totalItems, nil
+}
+
+// GetByID fetches a price list with its product prices
+func (s *PriceListService) GetByID(id string) (*PriceList, *dto.APIError) {
+	priceList, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Daftar harga tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data daftar harga",
+		})
+	}
+
+	items, err := s.repo.GetItems(id)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil harga produk",
+		})
+	}
+	priceList.Items = items
+	return priceList, nil
+}
+
+// periodsOverlap checks whether two validity periods share a day, an empty bound is open ended
+func periodsOverlap(a, b PriceListItemRequest) bool {
+	startsBeforeEnd := func(from, until string) bool {
+		return from == "" || until == "" || from <= until
+	}
+	return startsBeforeEnd(a.ValidFrom, b.ValidUntil) && startsBeforeEnd(b.ValidFrom, a.ValidUntil)
+}
+
+// validate checks the name and product prices of a price list, defaulting missing minimum quantities to 1.
+// A product may only have one price per minimum quantity on any day, so the applicable price is never ambiguous.
+func (s *PriceListService) validate(id, name string, items []PriceListItemRequest) *dto.APIError {
+	taken, err := s.repo.NameTaken(name, id)
+	if err != nil {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa nama daftar harga",
+		})
+	}
+	if taken {
+		return dto.NewAPIError(http.StatusConflict, map[string]string{
+			"name": "Nama daftar harga sudah digunakan",
+		})
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	productIDs := make([]string, 0, len(items))
+	for i := range items {
+		if items[i].MinQuantity == 0 {
+			items[i].MinQuantity = 1
+		}
+		if items[i].ValidFrom != "" && items[i].ValidUntil != "" && items[i].ValidUntil < items[i].ValidFrom {
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"items": fmt.Sprintf("Tanggal akhir berlaku baris %d tidak boleh sebelum tanggal mulai", i+1),
+			})
+		}
+		for j := 0; j < i; j++ {
+			if strings.EqualFold(items[j].ProductID, items[i].ProductID) && items[j].MinQuantity == items[i].MinQuantity &&
+				periodsOverlap(items[j], items[i]) {
+				return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+					"items": fmt.Sprintf("Baris %d dan %d memberi harga produk yang sama untuk jumlah minimum dan periode yang sama", j+1, i+1),
+				})
+			}
+		}
+		productIDs = append(productIDs, items[i].ProductID)
+	}
+
+	missing, err := s.repo.MissingProducts(productIDs)
+	if err != nil {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa produk",
+		})
+	}
+	if len(missing) > 0 {
+		return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"items": "Produk tidak ditemukan: " + strings.Join(missing, ", "),
+		})
+	}
+	return nil
+}
+
+// Create creates a price list with its product prices
+func (s *PriceListService) Create(req CreatePriceListRequest) (*PriceList, *dto.APIError) {
+	if apiErr := s.validate("", req.Name, req.Items); apiErr != nil {
+		return nil, apiErr
+	}
+
+	id, err := s.repo.Create(req)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membuat daftar harga",
+		})
+	}
+	return s.GetByID(id)
+}
+
+// Update replaces a price list and its product prices, orders already placed keep their prices
+func (s *PriceListService) Update(req UpdatePriceListRequest) (*PriceList, *dto.APIError) {
+	if _, apiErr := s.GetByID(req.ID); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := s.validate(req.ID, req.Name, req.Items); apiErr != nil {
+		return nil, apiErr
+	}
+
+	if err := s.repo.Update(req); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Daftar harga tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memperbarui daftar harga",
+		})
+	}
+	return s.GetByID(req.ID)
+}
+
+// Delete deletes a price list, its customers go back to prices typed on the order
+func (s *PriceListService) Delete(id string) *dto.APIError {
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Daftar harga tidak ditemukan",
+			})
+		}
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menghapus daftar harga",
+		})
+	}
+	return nil
+}
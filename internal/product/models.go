@@ -10,6 +10,7 @@ type GetProductRequest struct {
 	Name     string `json:"name,omitempty"`
 	Category string `json:"category,omitempty" validate:"omitempty,uuid"`
 	Unit     string `json:"unit,omitempty" validate:"omitempty,uuid"`
+	Search   string `json:"search,omitempty" validate:"omitempty,max=255"`
 	// Include PaginationParameter
 	utils.PaginationParameter
 }
@@ -69,19 +70,23 @@ type GetProductBatchesRequest struct {
 
 // ProductBatchResponse represents a batch of a product with storage info
 type ProductBatchResponse struct {
-	BatchID         uuid.UUID               `json:"batch_id"`
-	SKU             string                  `json:"sku"`
-	PurchaseOrderID uuid.UUID               `json:"purchase_order_id"`
-	InitialQuantity float64                 `json:"initial_quantity"`
-	CurrentQuantity float64                 `json:"current_quantity"`
-	UnitPrice       float64                 `json:"unit_price"`
-	CreatedAt       string                  `json:"created_at"`
-	StorageDetails  []ProductBatchInStorage `json:"storage_details,omitempty"`
+	BatchID           uuid.UUID               `json:"batch_id"`
+	SKU               string                  `json:"sku"`
+	PurchaseOrderID   uuid.UUID               `json:"purchase_order_id"`
+	InitialQuantity   float64                 `json:"initial_quantity"`
+	CurrentQuantity   float64                 `json:"current_quantity"`
+	ReservedQuantity  float64                 `json:"reserved_quantity"`  // Held by open sales orders
+	AvailableQuantity float64                 `json:"available_quantity"` // Current minus reserved
+	UnitPrice         float64                 `json:"unit_price"`
+	CreatedAt         string                  `json:"created_at"`
+	StorageDetails    []ProductBatchInStorage `json:"storage_details,omitempty"`
 }
 
 // ProductBatchInStorage represents quantity of a batch in a specific storage
 type ProductBatchInStorage struct {
-	StorageID   uuid.UUID `json:"storage_id"`
-	StorageName string    `json:"storage_name"`
-	Quantity    float64   `json:"quantity"`
+	StorageID         uuid.UUID `json:"storage_id"`
+	StorageName       string    `json:"storage_name"`
+	Quantity          float64   `json:"quantity"`           // On hand in the storage
+	ReservedQuantity  float64   `json:"reserved_quantity"`  // Held by open sales orders
+	AvailableQuantity float64   `json:"available_quantity"` // On hand minus reserved
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sinartimur-go/internal/category"
 	"sinartimur-go/internal/unit"
+	"sinartimur-go/utils"
 	"strings"
 )
 
@@ -20,6 +21,19 @@ type ProductRepository interface {
 	GetProductBatches(req GetProductBatchesRequest) ([]ProductBatchResponse, int, error)
 }
 
+// productSearchFields are the columns matched by the fuzzy product search
+var productSearchFields = []string{
+	"P.Name",
+	"P.Description",
+	"C.Name",
+	"U.Name",
+}
+
+// productSearchRelated lets a product be found by the SKU of any of its batches
+var productSearchRelated = []utils.RelatedSearch{
+	{From: "Product_Batch Pb Where Pb.Product_Id = P.Id", Column: "Pb.Sku"},
+}
+
 type ProductRepositoryImpl struct {
 	db *sql.DB
 }
@@ -33,68 +47,51 @@ func (r *ProductRepositoryImpl) GetAll(req GetProductRequest) ([]GetProductRespo
 	var products []GetProductResponse
 	var totalItems int
 
-	// Using strings.Builder for query construction
-	var queryBuilder strings.Builder
-	var countQueryBuilder strings.Builder
-
-	// Base queries
-	queryBuilder.WriteString("Select P.Id, P.Name, P.Description, C.Name As Category, Category_Id, U.Name As Unit,Unit_Id, P.Created_At, P.Updated_At From Product P Join Category C On P.Category_Id = C.Id Join Unit U On P.Unit_Id = U.Id Where P.Deleted_At Is Null")
-	countQueryBuilder.WriteString("Select Count(Id) From Product Where Deleted_At Is Null")
+	// Base query
+	qb := utils.NewQueryBuilder("Select P.Id, P.Name, P.Description, C.Name As Category, Category_Id, U.Name As Unit,Unit_Id, P.Created_At, P.Updated_At From Product P Join Category C On P.Category_Id = C.Id Join Unit U On P.Unit_Id = U.Id Where P.Deleted_At Is Null")
 
 	// Apply filters
 	if req.Name != "" {
-		queryBuilder.WriteString(" AND p.name ILIKE '%")
-		queryBuilder.WriteString(req.Name)
-		queryBuilder.WriteString("%'")
-
-		countQueryBuilder.WriteString(" AND name ILIKE '%")
-		countQueryBuilder.WriteString(req.Name)
-		countQueryBuilder.WriteString("%'")
+		qb.AddFilter("P.Name ILIKE", "%"+req.Name+"%")
 	}
 
 	if req.Category != "" {
-		queryBuilder.WriteString(" AND p.category_id = '")
-		queryBuilder.WriteString(req.Category)
-		queryBuilder.WriteString("'")
-
-		countQueryBuilder.WriteString(" AND category_id = '")
-		countQueryBuilder.WriteString(req.Category)
-		countQueryBuilder.WriteString("'")
+		qb.AddFilter("P.Category_Id =", req.Category)
 	}
 
 	if req.Unit != "" {
-		queryBuilder.WriteString(" AND p.unit_id = '")
-		queryBuilder.WriteString(req.Unit)
-		queryBuilder.WriteString("'")
+		qb.AddFilter("P.Unit_Id =", req.Unit)
+	}
+
+	qb.AddSearchRelated(req.Search, productSearchRelated, productSearchFields...)
 
-		countQueryBuilder.WriteString(" AND unit_id = '")
-		countQueryBuilder.WriteString(req.Unit)
-		countQueryBuilder.WriteString("'")
+	// Execute count query first
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Products", qb.Query.String())
+	err := r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// Add sorting
+	// Add sorting, search results are ranked by relevance unless a sort is requested
 	if req.SortBy != "" {
-		queryBuilder.WriteString(" ORDER BY ")
-		queryBuilder.WriteString(req.SortBy)
+		qb.Query.WriteString(" ORDER BY ")
+		qb.Query.WriteString(req.SortBy)
 		if req.SortOrder != "" {
-			queryBuilder.WriteString(" ")
-			queryBuilder.WriteString(req.SortOrder)
+			qb.Query.WriteString(" ")
+			qb.Query.WriteString(req.SortOrder)
 		}
+	} else if rank := qb.SearchRank(req.Search, productSearchFields...); rank != "" {
+		qb.Query.WriteString(" ORDER BY " + rank + " DESC, P.Name")
 	} else {
-		queryBuilder.WriteString(" ORDER BY P.Name")
+		qb.Query.WriteString(" ORDER BY P.Name")
 	}
 
 	// Add pagination
-	queryBuilder.WriteString(fmt.Sprintf(" LIMIT %d OFFSET %d", req.PageSize, (req.Page-1)*req.PageSize))
-
-	// Execute count query first
-	err := r.db.QueryRow(countQueryBuilder.String()).Scan(&totalItems)
-	if err != nil {
-		return nil, 0, err
-	}
+	qb.AddPagination(req.PaginationParameter)
 
 	// Execute main query
-	rows, err := r.db.Query(queryBuilder.String())
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -117,6 +114,12 @@ func (r *ProductRepositoryImpl) GetAll(req GetProductRequest) ([]GetProductRespo
 			return nil, 0, err
 		}
 
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(product); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		products = append(products, product)
 	}
 
@@ -274,7 +277,9 @@ func (r *ProductRepositoryImpl) GetProductBatches(req GetProductBatchesRequest)
 	// Build query for batches with pagination
 	var queryBuilder strings.Builder
 	queryBuilder.WriteString(`
-		Select Id, Sku, Purchase_Order_Id, Initial_Quantity, Current_Quantity, Unit_Price, Created_At
+		Select Id, Sku, Purchase_Order_Id, Initial_Quantity, Current_Quantity,
+			Coalesce((Select Sum(St.Reserved) From Batch_Storage_Stock St Where St.Batch_Id = Product_Batch.Id), 0),
+			Unit_Price, Created_At
 		From Product_Batch
 		Where Product_Id = $1
 		Order By Created_At Desc
@@ -294,19 +299,21 @@ func (r *ProductRepositoryImpl) GetProductBatches(req GetProductBatchesRequest)
 			&batch.PurchaseOrderID,
 			&batch.InitialQuantity,
 			&batch.CurrentQuantity,
+			&batch.ReservedQuantity,
 			&batch.UnitPrice,
 			&batch.CreatedAt,
 		)
 		if err != nil {
 			return nil, 0, err
 		}
+		batch.AvailableQuantity = batch.CurrentQuantity - batch.ReservedQuantity
 
 		// Get storage information for this batch
 		storageQuery := `
-			Select Bs.Storage_Id, S.Name, Bs.Quantity
-			From Batch_Storage Bs
-			Join Storage S On Bs.Storage_Id = S.Id
-			Where Bs.Batch_Id = $1 And S.Deleted_At Is Null
+			Select St.Storage_Id, S.Name, St.On_Hand, St.Reserved, St.Available
+			From Batch_Storage_Stock St
+			Join Storage S On St.Storage_Id = S.Id
+			Where St.Batch_Id = $1 And S.Deleted_At Is Null
 		`
 		storageRows, errQ := r.db.Query(storageQuery, batch.BatchID)
 		if errQ != nil {
@@ -315,7 +322,7 @@ func (r *ProductRepositoryImpl) GetProductBatches(req GetProductBatchesRequest)
 
 		for storageRows.Next() {
 			var storage ProductBatchInStorage
-			err = storageRows.Scan(&storage.StorageID, &storage.StorageName, &storage.Quantity)
+			err = storageRows.Scan(&storage.StorageID, &storage.StorageName, &storage.Quantity, &storage.ReservedQuantity, &storage.AvailableQuantity)
 			if err != nil {
 				storageRows.Close()
 				return nil, 0, err
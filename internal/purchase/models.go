@@ -10,6 +10,7 @@ type GetSupplierResponse struct {
 	Name      string `json:"name"`
 	Address   string `json:"address"`
 	Telephone string `json:"telephone"`
+	Email     string `json:"email"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 }
@@ -18,6 +19,7 @@ type GetSupplierResponse struct {
 type GetSupplierRequest struct {
 	Name      string `json:"name,omitempty" validate:"omitempty"`
 	Telephone string `json:"telephone,omitempty" validate:"omitempty"`
+	Search    string `json:"search,omitempty" validate:"omitempty,max=255"`
 	utils.PaginationParameter
 }
 
@@ -26,6 +28,7 @@ type CreateSupplierRequest struct {
 	Name      string `json:"name" validate:"required"`
 	Address   string `json:"address,omitempty" validate:"omitempty"`
 	Telephone string `json:"telephone,omitempty" validate:"omitempty"`
+	Email     string `json:"email,omitempty" validate:"omitempty,email,max=255"`
 }
 
 // UpdateSupplierRequest represents the request for updating a supplier
@@ -34,6 +37,7 @@ type UpdateSupplierRequest struct {
 	Name      string `json:"name,omitempty" validate:"omitempty"`
 	Address   string `json:"address,omitempty" validate:"omitempty"`
 	Telephone string `json:"telephone,omitempty" validate:"omitempty"`
+	Email     string `json:"email,omitempty" validate:"omitempty,email,max=255"`
 }
 
 // DeleteSupplierRequest represents the request for deleting a supplier
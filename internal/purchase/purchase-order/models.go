@@ -20,12 +20,21 @@ type CreatePurchaseOrderRequest struct {
 	PaymentMethod  string                           `json:"payment_method" validate:"required,oneof=cash credit"`
 	PaymentDueDate string                           `json:"payment_due_date" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
 	Items          []CreatePurchaseOrderItemRequest `json:"items" validate:"required,dive"`
+	BranchID       string                           `json:"-"`
+
+	// Order discount taken after the line discounts, tax defaults to the company setting
+	DiscountType  string   `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue float64  `json:"discount_value,omitempty" validate:"gte=0"`
+	TaxRate       *float64 `json:"tax_rate,omitempty" validate:"omitempty,gte=0,lte=100"`
+	TaxMode       string   `json:"tax_mode,omitempty" validate:"omitempty,oneof=exclusive inclusive"`
 }
 
 type CreatePurchaseOrderItemRequest struct {
-	ProductID string  `json:"product_id" validate:"required,uuid"`
-	Quantity  float64 `json:"quantity" validate:"required,gt=0"`
-	Price     float64 `json:"price" validate:"required,gt=0"`
+	ProductID     string  `json:"product_id" validate:"required,uuid"`
+	Quantity      float64 `json:"quantity" validate:"required,gt=0"`
+	Price         float64 `json:"price" validate:"required,gt=0"`
+	DiscountType  string  `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue float64 `json:"discount_value,omitempty" validate:"gte=0"`
 }
 
 type UpdatePurchaseOrderRequest struct {
@@ -35,12 +44,23 @@ type UpdatePurchaseOrderRequest struct {
 	PaymentMethod  string `json:"payment_method" validate:"omitempty,oneof=cash credit"`
 	PaymentDueDate string `json:"payment_due_date" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
 	CheckedBy      string `json:"checked_by" validate:"omitempty,uuid"`
+
+	// Order discount and tax, a discount value of 0 removes the discount
+	DiscountType  *string  `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue *float64 `json:"discount_value,omitempty" validate:"omitempty,gte=0"`
+	TaxRate       *float64 `json:"tax_rate,omitempty" validate:"omitempty,gte=0,lte=100"`
+	TaxMode       string   `json:"tax_mode,omitempty" validate:"omitempty,oneof=exclusive inclusive"`
+	BranchID      string   `json:"-"`
 }
 
+// UpdatePurchaseOrderItemRequest replaces a line, leaving the discount out removes it
 type UpdatePurchaseOrderItemRequest struct {
-	ID       string  `json:"id" validate:"required,uuid"`
-	Quantity float64 `json:"quantity" validate:"required,gt=0"`
-	Price    float64 `json:"price" validate:"required,gt=0"`
+	ID            string  `json:"id" validate:"required,uuid"`
+	Quantity      float64 `json:"quantity" validate:"required,gt=0"`
+	Price         float64 `json:"price" validate:"required,gt=0"`
+	DiscountType  string  `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue float64 `json:"discount_value,omitempty" validate:"gte=0"`
+	BranchID      string  `json:"-"`
 }
 
 type ReceivedItemRequest struct {
@@ -55,6 +75,7 @@ type ReceivedItemRequest struct {
 type CompletePurchaseOrderRequest struct {
 	PurchaseOrderID string `json:"purchase_order_id" validate:"required,uuid"`
 	StorageID       string `json:"storage_id" validate:"required,uuid"`
+	BranchID        string `json:"-"`
 }
 
 type CreateReturnPurchaseOrderItemRequest struct {
@@ -62,10 +83,12 @@ type CreateReturnPurchaseOrderItemRequest struct {
 	ProductDetailID string  `json:"product_detail_id" validate:"required,uuid"`
 	ReturnQuantity  float64 `json:"return_quantity" validate:"required,gt=0"`
 	Reason          string  `json:"reason"`
+	BranchID        string  `json:"-"`
 }
 
 type CancelReturnPurchaseOrderItemRequest struct {
 	ReturnID string `json:"return_id" validate:"required,uuid"`
+	BranchID string `json:"-"`
 }
 
 type CreateReturnPurchaseOrderItemResponse struct {
@@ -84,12 +107,14 @@ type GetPurchaseOrderRequest struct {
 	Status     string `json:"status" validate:"omitempty,oneof=ordered completed partially_returned returned cancelled"`
 	FromDate   string `json:"from_date" validate:"omitempty,datetime=2006-01-02"`
 	ToDate     string `json:"to_date" validate:"omitempty,datetime=2006-01-02"`
+	BranchID   string `json:"-"`
 	utils.PaginationParameter
 }
 
 type GetPurchaseOrderReturnRequest struct {
 	FromDate string `json:"from_date" validate:"omitempty,datetime=2006-01-02"`
 	ToDate   string `json:"to_date" validate:"omitempty,datetime=2006-01-02"`
+	BranchID string `json:"-"`
 	utils.PaginationParameter
 }
 
@@ -104,6 +129,14 @@ type GetPurchaseOrderDetailResponse struct {
 	OrderDate       string              `json:"order_date"`
 	Status          string              `json:"status"`
 	TotalAmount     float64             `json:"total_amount"`
+	DiscountType    *string             `json:"discount_type,omitempty"` // Order discount taken after the line discounts
+	DiscountValue   float64             `json:"discount_value"`
+	TaxRate         float64             `json:"tax_rate"`
+	TaxMode         string              `json:"tax_mode"`
+	Subtotal        float64             `json:"subtotal"`        // Lines before discounts
+	DiscountAmount  float64             `json:"discount_amount"` // Line and order discounts
+	TaxBase         float64             `json:"tax_base"`        // DPP
+	TaxAmount       float64             `json:"tax_amount"`      // PPN
 	PaymentMethod   string              `json:"payment_method"`
 	PaymentDueDate  *string             `json:"payment_due_date,omitempty"`
 	CreatedBy       string              `json:"created_by"`
@@ -127,8 +160,12 @@ type PurchaseOrderItem struct {
 	ProductName      string   `json:"product_name"`
 	Quantity         float64  `json:"quantity"`
 	Price            float64  `json:"price"`
+	DiscountType     *string  `json:"discount_type,omitempty"`
+	DiscountValue    float64  `json:"discount_value"`
+	DiscountAmount   float64  `json:"discount_amount"`
+	LineTotal        float64  `json:"line_total"` // Share of the order grand total after the order discount and tax
 	ReceivedQuantity *float64 `json:"received_quantity,omitempty"`
-	CurrentQuantity  float64 `json:"current_quantity,omitempty"`
+	CurrentQuantity  float64  `json:"current_quantity,omitempty"`
 	CreatedAt        string   `json:"created_at"`
 	UpdatedAt        string   `json:"updated_at"`
 
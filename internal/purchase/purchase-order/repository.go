@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sinartimur-go/internal/product"
+	"sinartimur-go/pkg/pricing"
 	"sinartimur-go/utils"
 	"strings"
 	"time"
@@ -17,6 +18,7 @@ type Repository interface {
 	// Basic CRUD operations
 	GetAll(req GetPurchaseOrderRequest) ([]GetPurchaseOrderResponse, int, error)
 	GetByID(id string) (*GetPurchaseOrderDetailResponse, error)
+	GetDocumentBranch(documentType, id string) (string, error)
 
 	// Core purchase order operations with transaction support
 	Create(req CreatePurchaseOrderRequest, userID string, tx *sql.Tx) (string, error)
@@ -71,6 +73,7 @@ func (r *RepositoryImpl) Create(req CreatePurchaseOrderRequest, userID string, t
 	var executor interface {
 		QueryRow(string, ...interface{}) *sql.Row
 		Exec(string, ...interface{}) (sql.Result, error)
+		Query(string, ...interface{}) (*sql.Rows, error)
 	}
 
 	if tx != nil {
@@ -106,7 +109,7 @@ func (r *RepositoryImpl) Create(req CreatePurchaseOrderRequest, userID string, t
 	// Generate Serial ID
 	var serialID string
 	if tx != nil {
-		serialID, err = utils.GenerateNextSerialID(tx, "PO")
+		serialID, err = utils.GenerateNextBranchSerialID(tx, "PO", req.BranchID)
 		if err != nil {
 			return "", fmt.Errorf("failed to generate serial ID: %w", err)
 		}
@@ -114,7 +117,7 @@ func (r *RepositoryImpl) Create(req CreatePurchaseOrderRequest, userID string, t
 		// If no transaction provided, create one temporarily just for serial ID generation
 		err = utils.WithTransaction(r.DB, func(tempTx *sql.Tx) error {
 			var genErr error
-			serialID, genErr = utils.GenerateNextSerialID(tempTx, "PO")
+			serialID, genErr = utils.GenerateNextBranchSerialID(tempTx, "PO", req.BranchID)
 			return genErr
 		})
 		if err != nil {
@@ -122,24 +125,40 @@ func (r *RepositoryImpl) Create(req CreatePurchaseOrderRequest, userID string, t
 		}
 	}
 
-	// Calculate total amount
-	var totalAmount float64
+	// Validate discounts, the tax starts from the company setting unless the order sets its own
+	if err := checkDiscount(req.DiscountType, req.DiscountValue); err != nil {
+		return "", err
+	}
 	for _, item := range req.Items {
-		totalAmount += item.Quantity * item.Price
+		if err := checkDiscount(item.DiscountType, item.DiscountValue); err != nil {
+			return "", err
+		}
 	}
 
-	// Insert purchase order
+	tax, err := pricing.DefaultTax(executor)
+	if err != nil {
+		return "", fmt.Errorf("failed to get default tax: %w", err)
+	}
+	if req.TaxRate != nil {
+		tax.Rate = *req.TaxRate
+	}
+	if req.TaxMode != "" {
+		tax.Mode = req.TaxMode
+	}
+
+	// Insert purchase order, the totals are filled in once the items are in
 	var orderID string
 	err = executor.QueryRow(`
         Insert Into Purchase_Order (
             Serial_Id, Supplier_Id, Order_Date, Status, 
             Total_Amount, Payment_Method, Payment_Due_Date, 
-            Created_By
+            Created_By, Branch_Id, Discount_Type, Discount_Value, Tax_Rate, Tax_Mode
         )
-        Values ($1, $2, $3, $4, $5, $6, $7, $8)
+        Values ($1, $2, $3, $4, 0, $5, $6, $7, $8, Nullif($9, ''), $10, $11, $12)
         Returning Id
     `, serialID, req.SupplierID, orderDate, "order",
-		totalAmount, req.PaymentMethod, paymentDueDate, userID).Scan(&orderID)
+		req.PaymentMethod, paymentDueDate, userID, req.BranchID,
+		req.DiscountType, req.DiscountValue, tax.Rate, tax.Mode).Scan(&orderID)
 
 	if err != nil {
 		return "", err
@@ -150,16 +169,20 @@ func (r *RepositoryImpl) Create(req CreatePurchaseOrderRequest, userID string, t
 		_, err = executor.Exec(`
             Insert Into Purchase_Order_Detail (
                 Purchase_Order_Id, Product_Id, 
-                Requested_Quantity, Unit_Price
+                Requested_Quantity, Unit_Price, Discount_Type, Discount_Value
             )
-            Values ($1, $2, $3, $4)
-        `, orderID, item.ProductID, item.Quantity, item.Price)
+            Values ($1, $2, $3, $4, Nullif($5, ''), $6)
+        `, orderID, item.ProductID, item.Quantity, item.Price, item.DiscountType, item.DiscountValue)
 
 		if err != nil {
 			return "", fmt.Errorf("failed to add order item: %w", err)
 		}
 	}
 
+	if err := recalculatePurchaseOrder(executor, orderID); err != nil {
+		return "", err
+	}
+
 	return orderID, nil
 }
 
@@ -176,7 +199,9 @@ func (r *RepositoryImpl) GetByID(id string) (*GetPurchaseOrderDetailResponse, er
             Po.Checked_By, U2.Username As Checkedbyname,
             Po.Created_At, Po.Updated_At,
             S.Address As Supplieraddress, S.Telephone As Supplierphone,
-            Po.Cancelled_At, Po.Cancelled_By, U3.Username As Cancelledbyname
+            Po.Cancelled_At, Po.Cancelled_By, U3.Username As Cancelledbyname,
+            Po.Discount_Type, Po.Discount_Value, Po.Tax_Rate, Po.Tax_Mode,
+            Po.Subtotal, Po.Discount_Amount, Po.Tax_Base, Po.Tax_Amount
         From Purchase_Order Po
         Left Join Supplier S On Po.Supplier_Id = S.Id
         Left Join Appuser U On Po.Created_By = U.Id
@@ -191,6 +216,8 @@ func (r *RepositoryImpl) GetByID(id string) (*GetPurchaseOrderDetailResponse, er
 		&po.CreatedAt, &po.UpdatedAt,
 		&po.SupplierAddress, &po.SupplierPhone,
 		&po.CancelledAt, &po.CancelledBy, &po.CancelledByName,
+		&po.DiscountType, &po.DiscountValue, &po.TaxRate, &po.TaxMode,
+		&po.Subtotal, &po.DiscountAmount, &po.TaxBase, &po.TaxAmount,
 	)
 
 	if err != nil {
@@ -205,6 +232,7 @@ func (r *RepositoryImpl) GetByID(id string) (*GetPurchaseOrderDetailResponse, er
         Select 
             Pod.Id, Pod.Product_Id, P.Name As Productname,
             Pod.Requested_Quantity, Pod.Unit_Price,
+            Pod.Discount_Type, Pod.Discount_Value, Pod.Discount_Amount, Pod.Line_Total,
             (Select pb.current_quantity
             From product_batch pb 
             Where pb.purchase_order_id = Pod.purchase_order_id
@@ -239,6 +267,7 @@ func (r *RepositoryImpl) GetByID(id string) (*GetPurchaseOrderDetailResponse, er
         From Purchase_Order_Detail Pod
         Join Product P On Pod.Product_Id = P.Id
         Where Pod.Purchase_Order_Id = $1
+        Order By Pod.Created_At, Pod.Id
     `, id)
 
 	if err != nil {
@@ -253,7 +282,9 @@ func (r *RepositoryImpl) GetByID(id string) (*GetPurchaseOrderDetailResponse, er
 
 		err := rows.Scan(
 			&item.ID, &item.ProductID, &item.ProductName,
-			&item.Quantity, &item.Price, &item.CurrentQuantity,
+			&item.Quantity, &item.Price,
+			&item.DiscountType, &item.DiscountValue, &item.DiscountAmount, &item.LineTotal,
+			&item.CurrentQuantity,
 			&item.ReturnQuantity,
 			&returnID, &returnReason, &returnedAt, &returnedBy,
 			&item.CreatedAt, &item.UpdatedAt,
@@ -402,19 +433,43 @@ func (r *RepositoryImpl) LogFinancialTransaction(userID string, amount float64,
 	return nil
 }
 
-// ReturnPurchaseOrderItem returns a purchase order item
-func (r *RepositoryImpl) ReturnPurchaseOrderItem(req CreateReturnPurchaseOrderItemRequest, userID string, tx *sql.Tx) error {
-	var executor interface {
-		QueryRow(string, ...interface{}) *sql.Row
-		Exec(string, ...interface{}) (sql.Result, error)
-		Query(string, ...interface{}) (*sql.Rows, error)
+// Purchase documents whose branch can be looked up, lines and returns belong to the branch of their order
+const (
+	DocumentPurchaseOrder     = "purchase_order"
+	DocumentPurchaseOrderItem = "purchase_order_item"
+	DocumentPurchaseReturn    = "purchase_return"
+)
+
+// documentBranchQueries resolve the branch of each purchase document through its order
+var documentBranchQueries = map[string]string{
+	DocumentPurchaseOrder:     "Select Coalesce(Branch_Id::text, '') From Purchase_Order Where Id = $1",
+	DocumentPurchaseOrderItem: "Select Coalesce(Po.Branch_Id::text, '') From Purchase_Order_Detail Pod Join Purchase_Order Po On Po.Id = Pod.Purchase_Order_Id Where Pod.Id = $1",
+	DocumentPurchaseReturn:    "Select Coalesce(Po.Branch_Id::text, '') From Purchase_Order_Return Pr Join Purchase_Order Po On Po.Id = Pr.Purchase_Order_Id Where Pr.Id = $1",
+}
+
+// GetDocumentBranch returns the branch of a purchase document, sql.ErrNoRows when it does not exist
+func (r *RepositoryImpl) GetDocumentBranch(documentType, id string) (string, error) {
+	query, ok := documentBranchQueries[documentType]
+	if !ok {
+		return "", fmt.Errorf("unknown document type %s", documentType)
 	}
 
-	if tx != nil {
-		executor = tx
-	} else {
-		executor = r.DB
+	var branchID string
+	if err := r.DB.QueryRow(query, id).Scan(&branchID); err != nil {
+		return "", err
+	}
+	return branchID, nil
+}
+
+// ReturnPurchaseOrderItem returns a purchase order item
+func (r *RepositoryImpl) ReturnPurchaseOrderItem(req CreateReturnPurchaseOrderItemRequest, userID string, tx *sql.Tx) error {
+	// The return serial, stock and ledger changes are only consistent inside a single transaction
+	if tx == nil {
+		return utils.WithTransaction(r.DB, func(tx *sql.Tx) error {
+			return r.ReturnPurchaseOrderItem(req, userID, tx)
+		})
 	}
+	executor := tx
 
 	// 1. Verify the purchase order exists and has status 'completed'
 	var serialID string
@@ -445,7 +500,7 @@ func (r *RepositoryImpl) ReturnPurchaseOrderItem(req CreateReturnPurchaseOrderIt
 
 	var unitPrice float64
 	err = executor.QueryRow(`
-        SELECT pod.product_id, p.name, pb.id, bs.storage_id, pb.current_quantity, pod.unit_price
+        SELECT pod.product_id, p.name, pb.id, bs.storage_id, pb.current_quantity, pod.line_total / pod.requested_quantity
         FROM purchase_order_detail pod
         JOIN product p ON pod.product_id = p.id
         JOIN product_batch pb ON pb.purchase_order_id = pod.purchase_order_id AND pb.product_id = pod.product_id
@@ -484,13 +539,24 @@ func (r *RepositoryImpl) ReturnPurchaseOrderItem(req CreateReturnPurchaseOrderIt
 		return fmt.Errorf("cannot return more than available quantity (%f)", availableQuantity)
 	}
 
-	// 5. Create the return record
+	// 5. Create the return record, numbered in the branch of the purchase order
+	var branchID string
+	err = executor.QueryRow("Select Coalesce(Branch_Id::text, '') From Purchase_Order Where Id = $1", req.PurchaseOrderID).Scan(&branchID)
+	if err != nil {
+		return fmt.Errorf("failed to get purchase order branch: %w", err)
+	}
+
+	returnSerialID, err := utils.GenerateNextBranchSerialID(tx, "PR", branchID)
+	if err != nil {
+		return fmt.Errorf("failed to generate serial ID: %w", err)
+	}
+
 	returnID := uuid.New()
 	_, err = executor.Exec(`
         INSERT INTO purchase_order_return
-        (id, purchase_order_id, product_detail_id, return_quantity, reason, status, returned_by)
-        VALUES ($1, $2, $3, $4, $5, 'returned', $6)
-    `, returnID, req.PurchaseOrderID, req.ProductDetailID, req.ReturnQuantity, req.Reason, userID)
+        (id, serial_id, purchase_order_id, product_detail_id, return_quantity, reason, status, returned_by)
+        VALUES ($1, $2, $3, $4, $5, $6, 'returned', $7)
+    `, returnID, returnSerialID, req.PurchaseOrderID, req.ProductDetailID, req.ReturnQuantity, req.Reason, userID)
 
 	if err != nil {
 		return fmt.Errorf("failed to create return record: %w", err)
@@ -546,8 +612,8 @@ func (r *RepositoryImpl) ReturnPurchaseOrderItem(req CreateReturnPurchaseOrderIt
 		return fmt.Errorf("failed to log inventory change: %w", err)
 	}
 
-	// 10. financial log
-	amount := req.ReturnQuantity * unitPrice
+	// 10. financial log, valued at what was paid for the line after discounts and tax
+	amount := pricing.Round(req.ReturnQuantity * unitPrice)
 	if _, err := executor.Exec(`
 			INSERT INTO Financial_Transaction_Log
 			  (user_id, amount, type, purchase_order_id, description, is_system)
@@ -640,11 +706,12 @@ func (r *RepositoryImpl) CancelReturnPurchaseOrderItem(req CancelReturnPurchaseO
 
 	var unitPrice float64
 	if err := executor.QueryRow(`
-        SELECT unit_price
-        FROM product_batch
-        WHERE id = $1
-    `, batchID).Scan(&unitPrice); err != nil {
-		return fmt.Errorf("failed to get batch unit price: %w", err)
+        SELECT pod.line_total / pod.requested_quantity
+        FROM purchase_order_return por
+        JOIN purchase_order_detail pod ON pod.id = por.product_detail_id
+        WHERE por.id = $1
+    `, req.ReturnID).Scan(&unitPrice); err != nil {
+		return fmt.Errorf("failed to get returned item price: %w", err)
 	}
 
 	// 4. Update the return record status to cancelled
@@ -698,7 +765,7 @@ func (r *RepositoryImpl) CancelReturnPurchaseOrderItem(req CancelReturnPurchaseO
 	}
 
 	// 8. financial log for cancelling the return
-	amount := returnQuantity * unitPrice
+	amount := pricing.Round(returnQuantity * unitPrice)
 	if _, err := executor.Exec(`
 			INSERT INTO Financial_Transaction_Log
 			  (user_id, amount, type, purchase_order_id, description, is_system, transaction_date)
@@ -790,6 +857,7 @@ func (r *RepositoryImpl) Update(req UpdatePurchaseOrderRequest, tx *sql.Tx) (str
 	var executor interface {
 		Exec(string, ...interface{}) (sql.Result, error)
 		QueryRow(string, ...interface{}) *sql.Row
+		Query(string, ...interface{}) (*sql.Rows, error)
 	}
 
 	if tx != nil {
@@ -839,6 +907,52 @@ func (r *RepositoryImpl) Update(req UpdatePurchaseOrderRequest, tx *sql.Tx) (str
 		paramCount++
 	}
 
+	// Discounts and tax can only change while the order has not been received
+	repriced := req.DiscountType != nil || req.DiscountValue != nil || req.TaxRate != nil || req.TaxMode != ""
+	if repriced {
+		var status, discountType string
+		var discountValue float64
+		err := executor.QueryRow(`
+            Select Status, Coalesce(Discount_Type, ''), Discount_Value From Purchase_Order Where Id = $1
+        `, req.ID).Scan(&status, &discountType, &discountValue)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return "", fmt.Errorf("purchase order tidak ditemukan")
+			}
+			return "", fmt.Errorf("failed to check purchase order status: %w", err)
+		}
+		if status != "order" {
+			return "", fmt.Errorf("diskon dan pajak hanya dapat diubah pada pesanan pembelian berstatus 'order'")
+		}
+
+		if req.DiscountType != nil {
+			discountType = *req.DiscountType
+		}
+		if req.DiscountValue != nil {
+			discountValue = *req.DiscountValue
+		}
+		if discountValue == 0 {
+			discountType = ""
+		}
+		if err := checkDiscount(discountType, discountValue); err != nil {
+			return "", err
+		}
+		query += fmt.Sprintf(", Discount_Type = Nullif($%d, ''), Discount_Value = $%d", paramCount, paramCount+1)
+		params = append(params, discountType, discountValue)
+		paramCount += 2
+
+		if req.TaxRate != nil {
+			query += fmt.Sprintf(", Tax_Rate = $%d", paramCount)
+			params = append(params, *req.TaxRate)
+			paramCount++
+		}
+		if req.TaxMode != "" {
+			query += fmt.Sprintf(", Tax_Mode = $%d", paramCount)
+			params = append(params, req.TaxMode)
+			paramCount++
+		}
+	}
+
 	// Add WHERE clause
 	query += fmt.Sprintf(" WHERE Id = $%d", paramCount)
 	params = append(params, req.ID)
@@ -859,6 +973,12 @@ func (r *RepositoryImpl) Update(req UpdatePurchaseOrderRequest, tx *sql.Tx) (str
 		return "", fmt.Errorf("purchase order tidak ditemukan")
 	}
 
+	if repriced {
+		if err := recalculatePurchaseOrder(executor, req.ID); err != nil {
+			return "", err
+		}
+	}
+
 	return req.ID, nil
 }
 
@@ -1051,6 +1171,7 @@ func (r *RepositoryImpl) AddPurchaseOrderItem(orderID string, req CreatePurchase
 	var executor interface {
 		Exec(string, ...interface{}) (sql.Result, error)
 		QueryRow(string, ...interface{}) *sql.Row
+		Query(string, ...interface{}) (*sql.Rows, error)
 	}
 
 	if tx != nil {
@@ -1077,31 +1198,24 @@ func (r *RepositoryImpl) AddPurchaseOrderItem(orderID string, req CreatePurchase
 		return fmt.Errorf("can only add items to purchase orders with status 'order'")
 	}
 
+	if err := checkDiscount(req.DiscountType, req.DiscountValue); err != nil {
+		return err
+	}
+
 	// Add the item
 	_, err = executor.Exec(`
         Insert Into Purchase_Order_Detail (
             Purchase_Order_Id, Product_Id, 
-            Requested_Quantity, Unit_Price
+            Requested_Quantity, Unit_Price, Discount_Type, Discount_Value
         )
-        Values ($1, $2, $3, $4)
-    `, orderID, req.ProductID, req.Quantity, req.Price)
+        Values ($1, $2, $3, $4, Nullif($5, ''), $6)
+    `, orderID, req.ProductID, req.Quantity, req.Price, req.DiscountType, req.DiscountValue)
 
 	if err != nil {
 		return fmt.Errorf("failed to add order item: %w", err)
 	}
 
-	// Update total amount
-	_, err = executor.Exec(`
-        Update Purchase_Order
-        Set Total_Amount = Total_Amount + $1, Updated_At = Now()
-        Where Id = $2
-    `, req.Quantity*req.Price, orderID)
-
-	if err != nil {
-		return fmt.Errorf("failed to update order total amount: %w", err)
-	}
-
-	return nil
+	return recalculatePurchaseOrder(executor, orderID)
 }
 
 // UpdatePurchaseOrderItem updates a purchase order item
@@ -1109,6 +1223,7 @@ func (r *RepositoryImpl) UpdatePurchaseOrderItem(req UpdatePurchaseOrderItemRequ
 	var executor interface {
 		Exec(string, ...interface{}) (sql.Result, error)
 		QueryRow(string, ...interface{}) *sql.Row
+		Query(string, ...interface{}) (*sql.Rows, error)
 	}
 
 	if tx != nil {
@@ -1119,12 +1234,11 @@ func (r *RepositoryImpl) UpdatePurchaseOrderItem(req UpdatePurchaseOrderItemRequ
 
 	// Get current item details
 	var orderID string
-	var oldQuantity, oldPrice float64
 	err := executor.QueryRow(`
-        Select Purchase_Order_Id, Requested_Quantity, Unit_Price
+        Select Purchase_Order_Id
         From Purchase_Order_Detail
         Where Id = $1
-    `, req.ID).Scan(&orderID, &oldQuantity, &oldPrice)
+    `, req.ID).Scan(&orderID)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -1148,12 +1262,16 @@ func (r *RepositoryImpl) UpdatePurchaseOrderItem(req UpdatePurchaseOrderItemRequ
 		return fmt.Errorf("can only update items for purchase orders with status 'order'")
 	}
 
+	if err := checkDiscount(req.DiscountType, req.DiscountValue); err != nil {
+		return err
+	}
+
 	// Update the item
 	result, err := executor.Exec(`
         Update Purchase_Order_Detail
-        Set Requested_Quantity = $1, Unit_Price = $2, Updated_At = Now()
-        Where Id = $3
-    `, req.Quantity, req.Price, req.ID)
+        Set Requested_Quantity = $1, Unit_Price = $2, Discount_Type = Nullif($3, ''), Discount_Value = $4, Updated_At = Now()
+        Where Id = $5
+    `, req.Quantity, req.Price, req.DiscountType, req.DiscountValue, req.ID)
 
 	if err != nil {
 		return fmt.Errorf("failed to update order item: %w", err)
@@ -1168,23 +1286,7 @@ func (r *RepositoryImpl) UpdatePurchaseOrderItem(req UpdatePurchaseOrderItemRequ
 		return fmt.Errorf("purchase order item not found")
 	}
 
-	// Calculate the change in total amount
-	oldTotal := oldQuantity * oldPrice
-	newTotal := req.Quantity * req.Price
-	amountDiff := newTotal - oldTotal
-
-	// Update the order's total amount
-	_, err = executor.Exec(`
-        Update Purchase_Order
-        Set Total_Amount = Total_Amount + $1, Updated_At = Now()
-        Where Id = $2
-    `, amountDiff, orderID)
-
-	if err != nil {
-		return fmt.Errorf("failed to update order total amount: %w", err)
-	}
-
-	return nil
+	return recalculatePurchaseOrder(executor, orderID)
 }
 
 // RemovePurchaseOrderItem removes an item from a purchase order
@@ -1192,6 +1294,7 @@ func (r *RepositoryImpl) RemovePurchaseOrderItem(id string, tx *sql.Tx) error {
 	var executor interface {
 		Exec(string, ...interface{}) (sql.Result, error)
 		QueryRow(string, ...interface{}) *sql.Row
+		Query(string, ...interface{}) (*sql.Rows, error)
 	}
 
 	if tx != nil {
@@ -1202,12 +1305,11 @@ func (r *RepositoryImpl) RemovePurchaseOrderItem(id string, tx *sql.Tx) error {
 
 	// Get current item details
 	var orderID string
-	var quantity, price float64
 	err := executor.QueryRow(`
-        Select Purchase_Order_Id, Requested_Quantity, Unit_Price
+        Select Purchase_Order_Id
         From Purchase_Order_Detail
         Where Id = $1
-    `, id).Scan(&orderID, &quantity, &price)
+    `, id).Scan(&orderID)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -1250,18 +1352,80 @@ func (r *RepositoryImpl) RemovePurchaseOrderItem(id string, tx *sql.Tx) error {
 		return fmt.Errorf("purchase order item not found")
 	}
 
-	// Update the order's total amount
-	total := quantity * price
-	_, err = executor.Exec(`
-        Update Purchase_Order
-        Set Total_Amount = Total_Amount - $1, Updated_At = Now()
-        Where Id = $2
-    `, total, orderID)
+	return recalculatePurchaseOrder(executor, orderID)
+}
 
+// checkDiscount checks a requested discount, a value needs its type and a percentage can not go over 100
+func checkDiscount(discountType string, value float64) error {
+	if discountType == "" && value > 0 {
+		return fmt.Errorf("jenis diskon harus diisi")
+	}
+	if discountType == pricing.DiscountPercent && value > 100 {
+		return fmt.Errorf("diskon persen tidak boleh lebih dari 100")
+	}
+	return nil
+}
+
+// recalculatePurchaseOrder prices an order again after its items, discounts or tax changed
+// and stores the line totals and the breakdown, Total_Amount being the grand total
+func recalculatePurchaseOrder(executor interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+	QueryRow(string, ...interface{}) *sql.Row
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, orderID string) error {
+	var discount pricing.Discount
+	var tax pricing.Tax
+	err := executor.QueryRow(`
+        Select Coalesce(Discount_Type, ''), Discount_Value, Tax_Rate, Tax_Mode
+        From Purchase_Order
+        Where Id = $1
+    `, orderID).Scan(&discount.Type, &discount.Value, &tax.Rate, &tax.Mode)
 	if err != nil {
-		return fmt.Errorf("failed to update order total amount: %w", err)
+		return fmt.Errorf("failed to get order discount and tax: %w", err)
 	}
 
+	rows, err := executor.Query(`
+        Select Id, Requested_Quantity, Unit_Price, Coalesce(Discount_Type, ''), Discount_Value
+        From Purchase_Order_Detail
+        Where Purchase_Order_Id = $1
+        Order By Created_At, Id
+    `, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to get order items: %w", err)
+	}
+	var detailIDs []string
+	var lines []pricing.Line
+	for rows.Next() {
+		var id string
+		var line pricing.Line
+		if err := rows.Scan(&id, &line.Quantity, &line.UnitPrice, &line.Discount.Type, &line.Discount.Value); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan order item: %w", err)
+		}
+		detailIDs = append(detailIDs, id)
+		lines = append(lines, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read order items: %w", err)
+	}
+
+	totals := pricing.Calculate(lines, discount, tax)
+	for i, id := range detailIDs {
+		if _, err := executor.Exec(`
+            Update Purchase_Order_Detail Set Discount_Amount = $1, Line_Total = $2 Where Id = $3
+        `, totals.Lines[i].DiscountAmount, totals.Lines[i].Total, id); err != nil {
+			return fmt.Errorf("failed to update order item total: %w", err)
+		}
+	}
+
+	if _, err := executor.Exec(`
+        Update Purchase_Order
+        Set Subtotal = $1, Discount_Amount = $2, Tax_Base = $3, Tax_Amount = $4, Total_Amount = $5, Updated_At = Now()
+        Where Id = $6
+    `, totals.Subtotal, totals.DiscountAmount, totals.TaxBase, totals.TaxAmount, totals.GrandTotal, orderID); err != nil {
+		return fmt.Errorf("failed to update order total amount: %w", err)
+	}
 	return nil
 }
 
@@ -1302,6 +1466,11 @@ func (r *RepositoryImpl) GetAll(req GetPurchaseOrderRequest) ([]GetPurchaseOrder
 		countQb.AddFilter("po.Status =", req.Status)
 	}
 
+	if req.BranchID != "" {
+		qb.AddFilter("po.Branch_Id =", req.BranchID)
+		countQb.AddFilter("po.Branch_Id =", req.BranchID)
+	}
+
 	if req.FromDate != "" {
 		fromDate, err := time.Parse("2006-01-02", req.FromDate)
 		if err == nil {
@@ -1346,7 +1515,7 @@ func (r *RepositoryImpl) GetAll(req GetPurchaseOrderRequest) ([]GetPurchaseOrder
 	qb.Query.WriteString(fmt.Sprintf(" ORDER BY %s %s", sortField, sortOrder))
 
 	// Add pagination
-	qb.AddPagination(req.PageSize, req.Page)
+	qb.AddPagination(req.PaginationParameter)
 
 	// Execute count query
 	var totalItems int
@@ -1389,6 +1558,12 @@ func (r *RepositoryImpl) GetAll(req GetPurchaseOrderRequest) ([]GetPurchaseOrder
 			order.SupplierName = supplierName.String
 		}
 
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(order); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		orders = append(orders, order)
 	}
 
@@ -1446,11 +1621,16 @@ func (r *RepositoryImpl) GetAllReturns(req GetPurchaseOrderReturnRequest) ([]Get
 		}
 	}
 
+	if req.BranchID != "" {
+		qb.AddFilter("po.Branch_Id =", req.BranchID)
+		countQb.AddFilter("po.Branch_Id =", req.BranchID)
+	}
+
 	// Add order by
 	qb.Query.WriteString(" ORDER BY por.Returned_At DESC")
 
 	// Add pagination
-	qb.AddPagination(req.PageSize, req.Page)
+	qb.AddPagination(req.PaginationParameter)
 
 	// Execute count query
 	var totalItems int
@@ -1485,6 +1665,12 @@ func (r *RepositoryImpl) GetAllReturns(req GetPurchaseOrderReturnRequest) ([]Get
 			return nil, 0, fmt.Errorf("failed to scan purchase order return: %w", err)
 		}
 
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(ret); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		returns = append(returns, ret)
 	}
 
@@ -1502,11 +1688,11 @@ func (r *RepositoryImpl) GenerateBatchSKU(productName, supplierName, serialID st
 	// Format date: DDMMYY
 	dateStr := fmt.Sprintf("%02d%02d%02d", date.Day(), date.Month(), date.Year()%100)
 
-	// Extract iteration number from serial ID (PO-20250417-XXXX)
+	// Extract iteration number from serial ID (PO-20250417-XXXX or PO-PST-20250417-XXXX)
 	iterNumber := "0000"
 	parts := strings.Split(serialID, "-")
-	if len(parts) == 3 {
-		iterNumber = parts[2]
+	if len(parts) >= 3 {
+		iterNumber = parts[len(parts)-1]
 	}
 
 	// Format: PROD-SUPPDDMMYY-XXXX (XXXX is the PO iteration number)
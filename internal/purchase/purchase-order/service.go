@@ -2,8 +2,12 @@ package purchase_order
 
 import (
 	"database/sql"
+	"errors"
+	"net/http"
+	"sinartimur-go/internal/outbox"
 	"sinartimur-go/internal/product"
 	"sinartimur-go/pkg/dto"
+	"sinartimur-go/pkg/event"
 )
 
 // PurchaseOrderService handles business logic for purchase orders
@@ -20,8 +24,35 @@ func NewPurchaseOrderService(repo Repository, db *sql.DB) *PurchaseOrderService
 	}
 }
 
+// checkBranch hides purchase orders of other branches from callers with an active branch
+func (s *PurchaseOrderService) checkBranch(documentType, id, branchID string) *dto.APIError {
+	if branchID == "" {
+		return nil
+	}
+
+	documentBranch, err := s.repo.GetDocumentBranch(documentType, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": err.Error(),
+		})
+	}
+	if err != nil || documentBranch != branchID {
+		return dto.NewAPIError(http.StatusNotFound, map[string]string{
+			"general": "Purchase Order tidak ditemukan",
+		})
+	}
+	return nil
+}
+
 // Create handles creating a purchase order
 func (s *PurchaseOrderService) Create(req CreatePurchaseOrderRequest, userID string) (*CreatePurchaseOrderResponse, *dto.APIError) {
+	// Orders are numbered and reported per branch
+	if req.BranchID == "" {
+		return nil, dto.NewAPIError(400, map[string]string{
+			"branch": "Pilih cabang aktif untuk membuat pesanan pembelian",
+		})
+	}
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -45,6 +76,20 @@ func (s *PurchaseOrderService) Create(req CreatePurchaseOrderRequest, userID str
 		}
 	}
 
+	// Record event in outbox
+	if err := outbox.Record(tx, event.PurchaseOrderCreated, map[string]string{
+		"purchase_order_id": purchaseOrderID,
+		"supplier_id":       req.SupplierID,
+		"created_by":        userID,
+	}); err != nil {
+		return nil, &dto.APIError{
+			StatusCode: 500,
+			Details: map[string]string{
+				"general": err.Error(),
+			},
+		}
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return nil, &dto.APIError{
@@ -87,7 +132,11 @@ func (s *PurchaseOrderService) Create(req CreatePurchaseOrderRequest, userID str
 }
 
 // GetPurchaseOrderDetail fetches details of a purchase order
-func (s *PurchaseOrderService) GetPurchaseOrderDetail(id string) (*GetPurchaseOrderDetailResponse, *dto.APIError) {
+func (s *PurchaseOrderService) GetPurchaseOrderDetail(id, branchID string) (*GetPurchaseOrderDetailResponse, *dto.APIError) {
+	if apiErr := s.checkBranch(DocumentPurchaseOrder, id, branchID); apiErr != nil {
+		return nil, apiErr
+	}
+
 	po, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, &dto.APIError{
@@ -140,6 +189,10 @@ func (s *PurchaseOrderService) GetPurchaseOrderDetail(id string) (*GetPurchaseOr
 
 // CreateReturnItem handles creating a purchase order return
 func (s *PurchaseOrderService) CreateReturnItem(req CreateReturnPurchaseOrderItemRequest, userID string) *dto.APIError {
+	if apiErr := s.checkBranch(DocumentPurchaseOrder, req.PurchaseOrderID, req.BranchID); apiErr != nil {
+		return apiErr
+	}
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -177,6 +230,10 @@ func (s *PurchaseOrderService) CreateReturnItem(req CreateReturnPurchaseOrderIte
 
 // CancelReturnItem handles cancelling a purchase order return
 func (s *PurchaseOrderService) CancelReturnItem(req CancelReturnPurchaseOrderItemRequest, userID string) *dto.APIError {
+	if apiErr := s.checkBranch(DocumentPurchaseReturn, req.ReturnID, req.BranchID); apiErr != nil {
+		return apiErr
+	}
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -214,6 +271,10 @@ func (s *PurchaseOrderService) CancelReturnItem(req CancelReturnPurchaseOrderIte
 
 // Update handles updating a purchase order
 func (s *PurchaseOrderService) Update(req UpdatePurchaseOrderRequest) (*UpdatePurchaseOrderResponse, *dto.APIError) {
+	if apiErr := s.checkBranch(DocumentPurchaseOrder, req.ID, req.BranchID); apiErr != nil {
+		return nil, apiErr
+	}
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -279,7 +340,11 @@ func (s *PurchaseOrderService) Update(req UpdatePurchaseOrderRequest) (*UpdatePu
 }
 
 // CheckPurchaseOrder handles checking a purchase order
-func (s *PurchaseOrderService) CheckPurchaseOrder(id string, userID string) *dto.APIError {
+func (s *PurchaseOrderService) CheckPurchaseOrder(id, branchID, userID string) *dto.APIError {
+	if apiErr := s.checkBranch(DocumentPurchaseOrder, id, branchID); apiErr != nil {
+		return apiErr
+	}
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -316,7 +381,11 @@ func (s *PurchaseOrderService) CheckPurchaseOrder(id string, userID string) *dto
 }
 
 // CancelPurchaseOrder handles cancelling a purchase order
-func (s *PurchaseOrderService) CancelPurchaseOrder(id string, userID string) (*CancelPurchaseOrderResponse, *dto.APIError) {
+func (s *PurchaseOrderService) CancelPurchaseOrder(id, branchID, userID string) (*CancelPurchaseOrderResponse, *dto.APIError) {
+	if apiErr := s.checkBranch(DocumentPurchaseOrder, id, branchID); apiErr != nil {
+		return nil, apiErr
+	}
+
 	// Get purchase order before cancellation to return its details later
 	purchaseOrder, err := s.repo.GetByID(id)
 	if err != nil {
@@ -350,6 +419,20 @@ func (s *PurchaseOrderService) CancelPurchaseOrder(id string, userID string) (*C
 		}
 	}
 
+	// Record event in outbox
+	if err := outbox.Record(tx, event.PurchaseOrderCancelled, map[string]string{
+		"purchase_order_id": id,
+		"serial_id":         purchaseOrder.SerialID,
+		"cancelled_by":      userID,
+	}); err != nil {
+		return nil, &dto.APIError{
+			StatusCode: 500,
+			Details: map[string]string{
+				"general": err.Error(),
+			},
+		}
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return nil, &dto.APIError{
@@ -410,7 +493,11 @@ func (s *PurchaseOrderService) GetAllReturns(req GetPurchaseOrderReturnRequest)
 }
 
 // AddPurchaseOrderItem adds an item to a purchase order
-func (s *PurchaseOrderService) AddPurchaseOrderItem(orderID string, req CreatePurchaseOrderItemRequest) *dto.APIError {
+func (s *PurchaseOrderService) AddPurchaseOrderItem(orderID, branchID string, req CreatePurchaseOrderItemRequest) *dto.APIError {
+	if apiErr := s.checkBranch(DocumentPurchaseOrder, orderID, branchID); apiErr != nil {
+		return apiErr
+	}
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -448,6 +535,10 @@ func (s *PurchaseOrderService) AddPurchaseOrderItem(orderID string, req CreatePu
 
 // UpdatePurchaseOrderItem updates a purchase order item
 func (s *PurchaseOrderService) UpdatePurchaseOrderItem(req UpdatePurchaseOrderItemRequest) *dto.APIError {
+	if apiErr := s.checkBranch(DocumentPurchaseOrderItem, req.ID, req.BranchID); apiErr != nil {
+		return apiErr
+	}
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -484,7 +575,11 @@ func (s *PurchaseOrderService) UpdatePurchaseOrderItem(req UpdatePurchaseOrderIt
 }
 
 // RemovePurchaseOrderItem removes a purchase order item
-func (s *PurchaseOrderService) RemovePurchaseOrderItem(id string) *dto.APIError {
+func (s *PurchaseOrderService) RemovePurchaseOrderItem(id, branchID string) *dto.APIError {
+	if apiErr := s.checkBranch(DocumentPurchaseOrderItem, id, branchID); apiErr != nil {
+		return apiErr
+	}
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -536,6 +631,10 @@ func (s *PurchaseOrderService) GetAllProducts(req product.GetProductRequest) ([]
 
 // CompleteFullPurchaseOrder handles completing an entire purchase order at once
 func (s *PurchaseOrderService) CompleteFullPurchaseOrder(req CompletePurchaseOrderRequest, userID string) *dto.APIError {
+	if apiErr := s.checkBranch(DocumentPurchaseOrder, req.PurchaseOrderID, req.BranchID); apiErr != nil {
+		return apiErr
+	}
+
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -558,6 +657,20 @@ func (s *PurchaseOrderService) CompleteFullPurchaseOrder(req CompletePurchaseOrd
 		}
 	}
 
+	// Record event in outbox
+	if err := outbox.Record(tx, event.PurchaseOrderCompleted, map[string]string{
+		"purchase_order_id": req.PurchaseOrderID,
+		"storage_id":        req.StorageID,
+		"completed_by":      userID,
+	}); err != nil {
+		return &dto.APIError{
+			StatusCode: 500,
+			Details: map[string]string{
+				"general": err.Error(),
+			},
+		}
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return &dto.APIError{
@@ -18,6 +18,9 @@ type SupplierRepository interface {
 	Delete(id string) error
 }
 
+// supplierSearchFields are the columns matched by the fuzzy supplier search
+var supplierSearchFields = []string{"Name", "Address", "Telephone"}
+
 // SupplierRepositoryImpl implements SupplierRepository
 type SupplierRepositoryImpl struct {
 	db *sql.DB
@@ -45,6 +48,8 @@ func (r *SupplierRepositoryImpl) GetAll(req GetSupplierRequest) ([]GetSupplierRe
 		countBuilder.AddFilter("Telephone ILIKE", "%"+req.Telephone+"%")
 	}
 
+	countBuilder.AddSearch(req.Search, supplierSearchFields...)
+
 	countQuery, countParams := countBuilder.Build()
 
 	// Execute count query
@@ -56,7 +61,7 @@ func (r *SupplierRepositoryImpl) GetAll(req GetSupplierRequest) ([]GetSupplierRe
 
 	// Build main query
 	queryBuilder := utils.NewQueryBuilder(`
-		Select Id, Name, Address, Telephone, Created_At, Updated_At
+		Select Id, Name, Address, Telephone, Coalesce(Email, ''), Created_At, Updated_At
 		From Supplier
 		Where Deleted_At Is Null
 	`)
@@ -69,11 +74,17 @@ func (r *SupplierRepositoryImpl) GetAll(req GetSupplierRequest) ([]GetSupplierRe
 		queryBuilder.AddFilter("Telephone ILIKE", "%"+req.Telephone+"%")
 	}
 
-	// Add sorting
-	queryBuilder.Query.WriteString(" ORDER BY Created_At DESC")
+	queryBuilder.AddSearch(req.Search, supplierSearchFields...)
+
+	// Add sorting, search results are ranked by relevance
+	if rank := queryBuilder.SearchRank(req.Search, supplierSearchFields...); rank != "" {
+		queryBuilder.Query.WriteString(" ORDER BY " + rank + " DESC, Created_At DESC")
+	} else {
+		queryBuilder.Query.WriteString(" ORDER BY Created_At DESC")
+	}
 
 	// Add pagination
-	mainQuery, queryParams := queryBuilder.AddPagination(req.PageSize, req.Page).Build()
+	mainQuery, queryParams := queryBuilder.AddPagination(req.PaginationParameter).Build()
 	// Execute main query
 	rows, err := r.db.Query(mainQuery, queryParams...)
 	if err != nil {
@@ -92,6 +103,7 @@ func (r *SupplierRepositoryImpl) GetAll(req GetSupplierRequest) ([]GetSupplierRe
 			&supplier.Name,
 			&supplier.Address,
 			&supplier.Telephone,
+			&supplier.Email,
 			&createdAt,
 			&updatedAt,
 		)
@@ -101,6 +113,12 @@ func (r *SupplierRepositoryImpl) GetAll(req GetSupplierRequest) ([]GetSupplierRe
 
 		supplier.CreatedAt = createdAt.Format(time.RFC3339)
 		supplier.UpdatedAt = updatedAt.Format(time.RFC3339)
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(supplier); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		suppliers = append(suppliers, supplier)
 	}
 
@@ -117,7 +135,7 @@ func (r *SupplierRepositoryImpl) GetByID(id string) (*GetSupplierResponse, error
 	var createdAt, updatedAt time.Time
 
 	err := r.db.QueryRow(`
-		Select Id, Name, Address, Telephone, Created_At, Updated_At
+		Select Id, Name, Address, Telephone, Coalesce(Email, ''), Created_At, Updated_At
 		From Supplier
 		Where Id = $1 And Deleted_At Is Null
 	`, id).Scan(
@@ -125,6 +143,7 @@ func (r *SupplierRepositoryImpl) GetByID(id string) (*GetSupplierResponse, error
 		&supplier.Name,
 		&supplier.Address,
 		&supplier.Telephone,
+		&supplier.Email,
 		&createdAt,
 		&updatedAt,
 	)
@@ -148,7 +167,7 @@ func (r *SupplierRepositoryImpl) GetByName(name string) (*GetSupplierResponse, e
 	var createdAt, updatedAt time.Time
 
 	err := r.db.QueryRow(`
-		Select Id, Name, Address, Telephone, Created_At, Updated_At
+		Select Id, Name, Address, Telephone, Coalesce(Email, ''), Created_At, Updated_At
 		From Supplier
 		Where Name = $1 And Deleted_At Is Null
 	`, name).Scan(
@@ -156,6 +175,7 @@ func (r *SupplierRepositoryImpl) GetByName(name string) (*GetSupplierResponse, e
 		&supplier.Name,
 		&supplier.Address,
 		&supplier.Telephone,
+		&supplier.Email,
 		&createdAt,
 		&updatedAt,
 	)
@@ -176,9 +196,9 @@ func (r *SupplierRepositoryImpl) GetByName(name string) (*GetSupplierResponse, e
 // Create inserts a new supplier
 func (r *SupplierRepositoryImpl) Create(req CreateSupplierRequest) error {
 	_, err := r.db.Exec(`
-		Insert Into Supplier (Name, Address, Telephone)
-		Values ($1, $2, $3)
-	`, req.Name, req.Address, req.Telephone)
+		Insert Into Supplier (Name, Address, Telephone, Email)
+		Values ($1, $2, $3, Nullif($4, ''))
+	`, req.Name, req.Address, req.Telephone, req.Email)
 
 	if err != nil {
 		return fmt.Errorf("create supplier: %w", err)
@@ -211,11 +231,16 @@ func (r *SupplierRepositoryImpl) Update(req UpdateSupplierRequest) error {
 		telephone = current.Telephone
 	}
 
+	email := req.Email
+	if email == "" {
+		email = current.Email
+	}
+
 	_, err = r.db.Exec(`
 		Update Supplier
-		Set Name = $1, Address = $2, Telephone = $3, Updated_At = Current_Timestamp
-		Where Id = $4 And Deleted_At Is Null
-	`, name, address, telephone, req.ID)
+		Set Name = $1, Address = $2, Telephone = $3, Email = Nullif($4, ''), Updated_At = Current_Timestamp
+		Where Id = $5 And Deleted_At Is Null
+	`, name, address, telephone, email, req.ID)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -0,0 +1,127 @@
+package quotation
+
+import (
+	"sinartimur-go/internal/sales"
+	"sinartimur-go/utils"
+)
+
+// Quotation statuses, expired is not stored but derived from the validity date
+const (
+	StatusDraft    = "draft"
+	StatusSent     = "sent"
+	StatusAccepted = "accepted"
+	StatusRejected = "rejected"
+	StatusExpired  = "expired"
+)
+
+// Quotation is a price quotation (penawaran harga) offered to a customer before an order
+type Quotation struct {
+	ID                 string          `json:"id"`
+	SerialID           string          `json:"serial_id"`
+	BranchID           *string         `json:"branch_id,omitempty"`
+	BranchName         *string         `json:"branch_name,omitempty"`
+	CustomerID         string          `json:"customer_id"`
+	CustomerName       string          `json:"customer_name"`
+	QuotationDate      string          `json:"quotation_date"`
+	ValidUntil         string          `json:"valid_until"`
+	Status             string          `json:"status"`
+	PaymentMethod      string          `json:"payment_method"`
+	PaymentTermDays    *int            `json:"payment_term_days,omitempty"`
+	Notes              *string         `json:"notes,omitempty"`
+	DiscountType       *string         `json:"discount_type,omitempty"` // Quotation discount taken after the line discounts
+	DiscountValue      float64         `json:"discount_value"`
+	TaxRate            float64         `json:"tax_rate"`
+	TaxMode            string          `json:"tax_mode"`
+	Subtotal           float64         `json:"subtotal"`        // Lines before discounts
+	DiscountAmount     float64         `json:"discount_amount"` // Line and quotation discounts
+	TaxBase            float64         `json:"tax_base"`        // DPP
+	TaxAmount          float64         `json:"tax_amount"`      // PPN
+	TotalAmount        float64         `json:"total_amount"`
+	SalesOrderID       *string         `json:"sales_order_id,omitempty"`
+	SalesOrderSerialID *string         `json:"sales_order_serial_id,omitempty"`
+	CreatedBy          *string         `json:"created_by,omitempty"`
+	CreatedByName      *string         `json:"created_by_name,omitempty"`
+	CreatedAt          string          `json:"created_at"`
+	UpdatedAt          string          `json:"updated_at"`
+	Items              []QuotationItem `json:"items,omitempty"`
+}
+
+// QuotationItem is a quoted product, stock is only picked once the quotation becomes an order
+type QuotationItem struct {
+	ID          string  `json:"id"`
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	UnitName    *string `json:"unit_name,omitempty"`
+	Quantity    float64 `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	TotalPrice  float64 `json:"total_price"`
+
+	// Discount of the line and its share of the quotation grand total after the quotation discount and tax
+	DiscountType   *string `json:"discount_type,omitempty"`
+	DiscountValue  float64 `json:"discount_value"`
+	DiscountAmount float64 `json:"discount_amount"`
+	LineTotal      float64 `json:"line_total"`
+}
+
+// GetQuotationsRequest holds query parameters for listing quotations
+type GetQuotationsRequest struct {
+	Search     string `json:"search" validate:"omitempty,max=255"`
+	Status     string `json:"status" validate:"omitempty,oneof=draft sent accepted rejected expired"`
+	CustomerID string `json:"customer_id" validate:"omitempty,uuid"`
+	BranchID   string `json:"-"`
+	utils.PaginationParameter
+}
+
+// QuotationItemRequest is a quoted product line
+type QuotationItemRequest struct {
+	ProductID     string  `json:"product_id" validate:"required,uuid"`
+	Quantity      float64 `json:"quantity" validate:"required,gt=0"`
+	UnitPrice     float64 `json:"unit_price" validate:"required,gt=0"`
+	DiscountType  string  `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue float64 `json:"discount_value,omitempty" validate:"gte=0"`
+}
+
+// CreateQuotationRequest holds data needed to create a quotation
+type CreateQuotationRequest struct {
+	CustomerID      string                 `json:"customer_id" validate:"required,uuid"`
+	ValidUntil      string                 `json:"valid_until" validate:"required,datetime=2006-01-02"`
+	PaymentMethod   string                 `json:"payment_method" validate:"required,oneof=cash paylater"`
+	PaymentTermDays int                    `json:"payment_term_days" validate:"omitempty,gt=0,lte=365"`
+	Notes           string                 `json:"notes" validate:"omitempty,max=1000"`
+	Items           []QuotationItemRequest `json:"items" validate:"required,min=1,dive"`
+	// Quotation discount taken after the line discounts, tax defaults to the company setting
+	DiscountType  string   `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue float64  `json:"discount_value,omitempty" validate:"gte=0"`
+	TaxRate       *float64 `json:"tax_rate,omitempty" validate:"omitempty,gte=0,lte=100"`
+	TaxMode       string   `json:"tax_mode,omitempty" validate:"omitempty,oneof=exclusive inclusive"`
+	BranchID      string   `json:"-"`
+}
+
+// UpdateQuotationRequest replaces a draft quotation and its items
+type UpdateQuotationRequest struct {
+	ID              string                 `json:"-" validate:"required,uuid"`
+	CustomerID      string                 `json:"customer_id" validate:"required,uuid"`
+	ValidUntil      string                 `json:"valid_until" validate:"required,datetime=2006-01-02"`
+	PaymentMethod   string                 `json:"payment_method" validate:"required,oneof=cash paylater"`
+	PaymentTermDays int                    `json:"payment_term_days" validate:"omitempty,gt=0,lte=365"`
+	Notes           string                 `json:"notes" validate:"omitempty,max=1000"`
+	Items           []QuotationItemRequest `json:"items" validate:"required,min=1,dive"`
+	// Quotation discount taken after the line discounts, tax defaults to the company setting
+	DiscountType  string   `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue float64  `json:"discount_value,omitempty" validate:"gte=0"`
+	TaxRate       *float64 `json:"tax_rate,omitempty" validate:"omitempty,gte=0,lte=100"`
+	TaxMode       string   `json:"tax_mode,omitempty" validate:"omitempty,oneof=exclusive inclusive"`
+	BranchID      string   `json:"-"`
+}
+
+// UpdateQuotationStatusRequest marks a quotation as sent to or rejected by the customer
+type UpdateQuotationStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=sent rejected"`
+}
+
+// ConvertQuotationRequest holds the options of converting a quotation into a sales order
+type ConvertQuotationRequest struct {
+	StorageID     string `json:"storage_id" validate:"omitempty,uuid"` // Preferred storage when allocating stock
+	CreateInvoice bool   `json:"create_invoice" validate:"omitempty"`
+	sales.CreditOverrideRequest
+}
@@ -0,0 +1,310 @@
+package quotation
+
+import (
+	"database/sql"
+	"fmt"
+	"sinartimur-go/pkg/pricing"
+	"sinartimur-go/utils"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// QuotationRepository defines the interface for quotation data operations
+type QuotationRepository interface {
+	GetAll(req GetQuotationsRequest) ([]Quotation, int, error)
+	GetByID(id string) (*Quotation, error)
+	GetItems(id string) ([]QuotationItem, error)
+	Create(req CreateQuotationRequest, userID string) (string, error)
+	Update(req UpdateQuotationRequest) error
+	UpdateStatus(id, status string) error
+	CustomerExists(id string) (bool, error)
+	MissingProducts(ids []string) ([]string, error)
+}
+
+// QuotationRepositoryImpl implements the QuotationRepository interface
+type QuotationRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewQuotationRepository creates a new quotation repository instance
+func NewQuotationRepository(db *sql.DB) QuotationRepository {
+	return &QuotationRepositoryImpl{db: db}
+}
+
+// quotationStatusExpr reports open quotations past their validity date as expired
+const quotationStatusExpr = `Case When Q.Status In ('draft', 'sent') And Q.Valid_Until < Current_Date Then 'expired' Else Q.Status End`
+
+const quotationColumns = `Q.Id, Q.Serial_Id, Q.Branch_Id, B.Name, Q.Customer_Id, C.Name, Q.Quotation_Date, Q.Valid_Until,
+	` + quotationStatusExpr + `, Q.Payment_Method, Q.Payment_Term_Days, Q.Notes,
+	Q.Discount_Type, Q.Discount_Value, Q.Tax_Rate, Q.Tax_Mode, Q.Subtotal, Q.Discount_Amount, Q.Tax_Base, Q.Tax_Amount, Q.Total_Amount,
+	Q.Sales_Order_Id, So.Serial_Id, Q.Created_By, Au.Username, Q.Created_At, Q.Updated_At`
+
+const quotationJoins = `
+	From Sales_Quotation Q
+	Join Customer C On C.Id = Q.Customer_Id
+	Left Join Branch B On B.Id = Q.Branch_Id
+	Left Join Sales_Order So On So.Id = Q.Sales_Order_Id
+	Left Join Appuser Au On Au.Id = Q.Created_By`
+
+// quotationSortColumns lists the columns quotations can be sorted by
+var quotationSortColumns = map[string]string{
+	"serial_id":      "Q.Serial_Id",
+	"customer_name":  "C.Name",
+	"quotation_date": "Q.Quotation_Date",
+	"valid_until":    "Q.Valid_Until",
+	"total_amount":   "Q.Total_Amount",
+	"created_at":     "Q.Created_At",
+}
+
+// scanQuotation scans a quotation row selected with quotationColumns
+func scanQuotation(row interface{ Scan(...interface{}) error }) (*Quotation, error) {
+	var q Quotation
+	var quotationDate, validUntil, createdAt, updatedAt time.Time
+	err := row.Scan(&q.ID, &q.SerialID, &q.BranchID, &q.BranchName, &q.CustomerID, &q.CustomerName, &quotationDate,
+		&validUntil, &q.Status, &q.PaymentMethod, &q.PaymentTermDays, &q.Notes,
+		&q.DiscountType, &q.DiscountValue, &q.TaxRate, &q.TaxMode, &q.Subtotal, &q.DiscountAmount, &q.TaxBase, &q.TaxAmount,
+		&q.TotalAmount,
+		&q.SalesOrderID, &q.SalesOrderSerialID, &q.CreatedBy, &q.CreatedByName, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	q.QuotationDate = quotationDate.Format(time.RFC3339)
+	q.ValidUntil = validUntil.Format("2006-01-02")
+	q.CreatedAt = createdAt.Format(time.RFC3339)
+	q.UpdatedAt = updatedAt.Format(time.RFC3339)
+	return &q, nil
+}
+
+// GetAll fetches quotations with pagination, branch users only see their branch
+func (r *QuotationRepositoryImpl) GetAll(req GetQuotationsRequest) ([]Quotation, int, error) {
+	qb := utils.NewQueryBuilder("Select " + quotationColumns + quotationJoins + " Where 1=1")
+	qb.AddSearch(req.Search, "Q.Serial_Id", "C.Name", "Q.Notes")
+	qb.AddFilter("Q.Branch_Id =", req.BranchID)
+	qb.AddFilter("Q.Customer_Id =", req.CustomerID)
+	qb.AddFilter(quotationStatusExpr+" =", req.Status)
+
+	var totalItems int
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Quotations", qb.Query.String())
+	if err := r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems); err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung penawaran: %w", err)
+	}
+
+	if column, ok := quotationSortColumns[req.SortBy]; ok && req.SortOrder != "" {
+		qb.Query.WriteString(fmt.Sprintf(" Order By %s %s", column, req.SortOrder))
+	} else {
+		qb.Query.WriteString(" Order By Q.Created_At Desc")
+	}
+	qb.AddPagination(req.PaginationParameter)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil penawaran: %w", err)
+	}
+	defer rows.Close()
+
+	quotations := []Quotation{}
+	for rows.Next() {
+		q, errScan := scanQuotation(rows)
+		if errScan != nil {
+			return nil, 0, fmt.Errorf("gagal membaca penawaran: %w", errScan)
+		}
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(*q); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
+		quotations = append(quotations, *q)
+	}
+
+	return quotations, totalItems, rows.Err()
+}
+
+// GetByID fetches a quotation header, sql.ErrNoRows means it does not exist
+func (r *QuotationRepositoryImpl) GetByID(id string) (*Quotation, error) {
+	return scanQuotation(r.db.QueryRow("Select "+quotationColumns+quotationJoins+" Where Q.Id = $1", id))
+}
+
+// GetItems fetches the quoted products of a quotation in the order they were entered
+func (r *QuotationRepositoryImpl) GetItems(id string) ([]QuotationItem, error) {
+	rows, err := r.db.Query(`
+		Select Qi.Id, Qi.Product_Id, P.Name, U.Name, Qi.Quantity, Qi.Unit_Price,
+			Qi.Discount_Type, Qi.Discount_Value, Qi.Discount_Amount, Qi.Line_Total
+		From Sales_Quotation_Item Qi
+		Join Product P On P.Id = Qi.Product_Id
+		Left Join Unit U On U.Id = P.Unit_Id
+		Where Qi.Sales_Quotation_Id = $1
+		Order By Qi.Created_At, Qi.Id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil item penawaran: %w", err)
+	}
+	defer rows.Close()
+
+	items := []QuotationItem{}
+	for rows.Next() {
+		var item QuotationItem
+		if errScan := rows.Scan(&item.ID, &item.ProductID, &item.ProductName, &item.UnitName,
+			&item.Quantity, &item.UnitPrice, &item.DiscountType, &item.DiscountValue, &item.DiscountAmount,
+			&item.LineTotal); errScan != nil {
+			return nil, fmt.Errorf("gagal membaca item penawaran: %w", errScan)
+		}
+		item.TotalPrice = item.Quantity * item.UnitPrice
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// saveItems inserts the quoted products of a quotation and stores its totals, priced with pricing.Calculate
+// as the sales order it turns into. Without a tax rate or mode the company setting is used.
+func saveItems(tx *sql.Tx, quotationID string, items []QuotationItemRequest, discount pricing.Discount, taxRate *float64, taxMode string) error {
+	tax, err := pricing.DefaultTax(tx)
+	if err != nil {
+		return fmt.Errorf("gagal mengambil pengaturan pajak: %w", err)
+	}
+	if taxRate != nil {
+		tax.Rate = *taxRate
+	}
+	if taxMode != "" {
+		tax.Mode = taxMode
+	}
+
+	lines := make([]pricing.Line, len(items))
+	for i, item := range items {
+		lines[i] = pricing.Line{
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+			Discount:  pricing.Discount{Type: item.DiscountType, Value: item.DiscountValue},
+		}
+	}
+	totals := pricing.Calculate(lines, discount, tax)
+
+	for i, item := range items {
+		if _, err = tx.Exec(`
+			Insert Into Sales_Quotation_Item (
+				Sales_Quotation_Id, Product_Id, Quantity, Unit_Price, Discount_Type, Discount_Value, Discount_Amount, Line_Total
+			) Values ($1, $2, $3, $4, Nullif($5, ''), $6, $7, $8)`,
+			quotationID, item.ProductID, item.Quantity, item.UnitPrice, item.DiscountType, item.DiscountValue,
+			totals.Lines[i].DiscountAmount, totals.Lines[i].Total); err != nil {
+			return fmt.Errorf("gagal menambahkan item penawaran: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		Update Sales_Quotation
+		Set Discount_Type = Nullif($1, ''), Discount_Value = $2, Tax_Rate = $3, Tax_Mode = $4, Subtotal = $5,
+			Discount_Amount = $6, Tax_Base = $7, Tax_Amount = $8, Total_Amount = $9
+		Where Id = $10`,
+		discount.Type, discount.Value, tax.Rate, tax.Mode, totals.Subtotal,
+		totals.DiscountAmount, totals.TaxBase, totals.TaxAmount, totals.GrandTotal, quotationID)
+	if err != nil {
+		return fmt.Errorf("gagal menyimpan total penawaran: %w", err)
+	}
+	return nil
+}
+
+// Create creates a draft quotation with its items and returns its ID
+func (r *QuotationRepositoryImpl) Create(req CreateQuotationRequest, userID string) (string, error) {
+	var id string
+	err := utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		serialID, err := utils.GenerateNextBranchSerialID(tx, "SQ", req.BranchID)
+		if err != nil {
+			return fmt.Errorf("gagal membuat serial ID: %w", err)
+		}
+
+		err = tx.QueryRow(`
+			Insert Into Sales_Quotation (Branch_Id, Serial_Id, Customer_Id, Valid_Until, Payment_Method, Payment_Term_Days, Notes, Created_By)
+			Values ($1, $2, $3, $4, $5, Nullif($6, 0), Nullif($7, ''), Nullif($8, '')::uuid)
+			Returning Id`,
+			req.BranchID, serialID, req.CustomerID, req.ValidUntil, req.PaymentMethod, req.PaymentTermDays,
+			req.Notes, userID).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("gagal membuat penawaran: %w", err)
+		}
+
+		return saveItems(tx, id, req.Items, pricing.Discount{Type: req.DiscountType, Value: req.DiscountValue},
+			req.TaxRate, req.TaxMode)
+	})
+	return id, err
+}
+
+// Update replaces the header and items of a quotation still in draft
+func (r *QuotationRepositoryImpl) Update(req UpdateQuotationRequest) error {
+	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			Update Sales_Quotation
+			Set Customer_Id = $1, Valid_Until = $2, Payment_Method = $3, Payment_Term_Days = Nullif($4, 0),
+				Notes = Nullif($5, ''), Updated_At = Now()
+			Where Id = $6 And Status = 'draft'`,
+			req.CustomerID, req.ValidUntil, req.PaymentMethod, req.PaymentTermDays, req.Notes, req.ID)
+		if err != nil {
+			return fmt.Errorf("gagal memperbarui penawaran: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return sql.ErrNoRows
+		}
+
+		if _, err = tx.Exec("Delete From Sales_Quotation_Item Where Sales_Quotation_Id = $1", req.ID); err != nil {
+			return fmt.Errorf("gagal menghapus item penawaran: %w", err)
+		}
+		return saveItems(tx, req.ID, req.Items, pricing.Discount{Type: req.DiscountType, Value: req.DiscountValue},
+			req.TaxRate, req.TaxMode)
+	})
+}
+
+// UpdateStatus moves an open quotation to a new status, sql.ErrNoRows means it is no longer open
+func (r *QuotationRepositoryImpl) UpdateStatus(id, status string) error {
+	result, err := r.db.Exec(`
+		Update Sales_Quotation
+		Set Status = $1, Updated_At = Now()
+		Where Id = $2 And Status In ('draft', 'sent')`,
+		status, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CustomerExists checks whether a customer exists and is not deleted
+func (r *QuotationRepositoryImpl) CustomerExists(id string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow("Select Exists(Select 1 From Customer Where Id = $1 And Deleted_At Is Null)", id).Scan(&exists)
+	return exists, err
+}
+
+// MissingProducts returns the given product IDs that do not exist or are deleted
+func (r *QuotationRepositoryImpl) MissingProducts(ids []string) ([]string, error) {
+	var found []string
+	rows, err := r.db.Query("Select Id From Product Where Id = Any($1::uuid[]) And Deleted_At Is Null", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		found = append(found, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+	var missing []string
+	for _, id := range ids {
+		if !exists[strings.ToLower(id)] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,310 @@
+package quotation
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sinartimur-go/internal/sales"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/pkg/pricing"
+	"strings"
+	"time"
+)
+
+// QuotationService is the service for sales quotations and their conversion into sales orders
+type QuotationService struct {
+	repo         QuotationRepository
+	salesService *sales.SalesService
+}
+
+// NewQuotationService creates a new instance of QuotationService
+func NewQuotationService(repo QuotationRepository, salesService *sales.SalesService) *QuotationService {
+	return &QuotationService{repo: repo, salesService: salesService}
+}
+
+// GetAll fetches quotations with pagination
+func (s *QuotationService) GetAll(req GetQuotationsRequest) ([]Quotation, int, *dto.APIError) {
+	quotations, totalItems, err := s.repo.GetAll(req)
+	if err != nil {
+		return nil, 0, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data penawaran",
+		})
+	}
+	return quotations, totalItems, nil
+}
+
+// getQuotation fetches a quotation header, hiding quotations of other branches from branch users
+func (s *QuotationService) getQuotation(id, branchID string) (*Quotation, *dto.APIError) {
+	quotation, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Penawaran tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data penawaran",
+		})
+	}
+	if branchID != "" && quotation.BranchID != nil && *quotation.BranchID != branchID {
+		return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+			"general": "Penawaran tidak ditemukan",
+		})
+	}
+	return quotation, nil
+}
+
+// GetByID fetches a quotation with its items
+func (s *QuotationService) GetByID(id, branchID string) (*Quotation, *dto.APIError) {
+	quotation, apiErr := s.getQuotation(id, branchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	items, err := s.repo.GetItems(id)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil item penawaran",
+		})
+	}
+	quotation.Items = items
+	return quotation, nil
+}
+
+// validate checks the customer, validity date, payment terms and products of a quotation
+func (s *QuotationService) validate(customerID, validUntil, paymentMethod string, paymentTermDays int, items []QuotationItemRequest) *dto.APIError {
+	date, err := time.ParseInLocation("2006-01-02", validUntil, time.Local)
+	if err != nil {
+		return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"valid_until": "Format tanggal berlaku tidak valid",
+		})
+	}
+	year, month, day := time.Now().Date()
+	if date.Before(time.Date(year, month, day, 0, 0, 0, 0, time.Local)) {
+		return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"valid_until": "Tanggal berlaku tidak boleh sebelum hari ini",
+		})
+	}
+
+	if paymentMethod == "paylater" && paymentTermDays == 0 {
+		return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"payment_term_days": "Jangka waktu pembayaran diperlukan untuk metode pembayaran paylater",
+		})
+	}
+
+	exists, err := s.repo.CustomerExists(customerID)
+	if err != nil {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa pelanggan",
+		})
+	}
+	if !exists {
+		return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"customer_id": "Pelanggan tidak ditemukan",
+		})
+	}
+
+	productIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	missing, err := s.repo.MissingProducts(productIDs)
+	if err != nil {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa produk",
+		})
+	}
+	if len(missing) > 0 {
+		return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"items": "Produk tidak ditemukan: " + strings.Join(missing, ", "),
+		})
+	}
+	return nil
+}
+
+// checkDiscount checks a discount the way sales orders do, an empty message when it is valid
+func checkDiscount(discountType string, value float64) string {
+	if discountType == "" && value > 0 {
+		return "Jenis diskon harus diisi"
+	}
+	if discountType == pricing.DiscountPercent && value > 100 {
+		return "Diskon persen tidak boleh lebih dari 100"
+	}
+	return ""
+}
+
+// validateDiscounts checks the quotation discount and the discount of every line
+func validateDiscounts(discountType string, discountValue float64, items []QuotationItemRequest) *dto.APIError {
+	if message := checkDiscount(discountType, discountValue); message != "" {
+		return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"discount_value": message,
+		})
+	}
+	for i, item := range items {
+		if message := checkDiscount(item.DiscountType, item.DiscountValue); message != "" {
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"items": fmt.Sprintf("Item %d: %s", i+1, message),
+			})
+		}
+	}
+	return nil
+}
+
+// Create creates a draft quotation in the active branch
+func (s *QuotationService) Create(req CreateQuotationRequest, userID string) (*Quotation, *dto.APIError) {
+	// Quotations are numbered per branch like the orders they turn into
+	if req.BranchID == "" {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Pilih cabang aktif untuk membuat penawaran",
+		})
+	}
+	if apiErr := s.validate(req.CustomerID, req.ValidUntil, req.PaymentMethod, req.PaymentTermDays, req.Items); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := validateDiscounts(req.DiscountType, req.DiscountValue, req.Items); apiErr != nil {
+		return nil, apiErr
+	}
+
+	id, err := s.repo.Create(req, userID)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membuat penawaran",
+		})
+	}
+	return s.GetByID(id, "")
+}
+
+// Update replaces a quotation that is still in draft
+func (s *QuotationService) Update(req UpdateQuotationRequest) (*Quotation, *dto.APIError) {
+	quotation, apiErr := s.getQuotation(req.ID, req.BranchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if quotation.Status != StatusDraft {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Hanya penawaran berstatus draft yang dapat diubah",
+		})
+	}
+	if apiErr = s.validate(req.CustomerID, req.ValidUntil, req.PaymentMethod, req.PaymentTermDays, req.Items); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr = validateDiscounts(req.DiscountType, req.DiscountValue, req.Items); apiErr != nil {
+		return nil, apiErr
+	}
+
+	if err := s.repo.Update(req); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusConflict, map[string]string{
+				"general": "Penawaran sudah berubah status, muat ulang data",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memperbarui penawaran",
+		})
+	}
+	return s.GetByID(req.ID, "")
+}
+
+// UpdateStatus marks a draft quotation as sent, or an open quotation as rejected
+func (s *QuotationService) UpdateStatus(id string, req UpdateQuotationStatusRequest, branchID string) (*Quotation, *dto.APIError) {
+	quotation, apiErr := s.getQuotation(id, branchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	switch {
+	case quotation.Status == StatusExpired:
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Penawaran sudah kedaluwarsa",
+		})
+	case req.Status == StatusSent && quotation.Status != StatusDraft,
+		req.Status == StatusRejected && quotation.Status != StatusDraft && quotation.Status != StatusSent:
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"status": "Status penawaran " + quotation.Status + " tidak dapat diubah menjadi " + req.Status,
+		})
+	}
+
+	if err := s.repo.UpdateStatus(id, req.Status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusConflict, map[string]string{
+				"general": "Penawaran sudah berubah status, muat ulang data",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memperbarui status penawaran",
+		})
+	}
+	return s.GetByID(id, "")
+}
+
+// Convert creates a sales order from an open quotation, carrying over its customer, prices, discounts, tax
+// and payment terms so the order totals match the quotation
+func (s *QuotationService) Convert(id string, req ConvertQuotationRequest, branchID, userID string) (*sales.CreateSalesOrderResponse, *dto.APIError) {
+	quotation, apiErr := s.GetByID(id, branchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	switch quotation.Status {
+	case StatusDraft, StatusSent:
+	case StatusAccepted:
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Penawaran sudah dikonversi menjadi pesanan",
+		})
+	case StatusExpired:
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Penawaran sudah kedaluwarsa",
+		})
+	default:
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Penawaran yang ditolak tidak dapat dikonversi",
+		})
+	}
+	if quotation.BranchID == nil {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": "Penawaran tidak memiliki cabang",
+		})
+	}
+
+	order := sales.CreateSalesOrderRequest{
+		CustomerID:    quotation.CustomerID,
+		PaymentMethod: quotation.PaymentMethod,
+		CreateInvoice: req.CreateInvoice,
+		BranchID:      *quotation.BranchID,
+		QuotationID:   quotation.ID,
+		DiscountValue: quotation.DiscountValue,
+		TaxRate:       &quotation.TaxRate,
+		TaxMode:       quotation.TaxMode,
+
+		CreditOverrideRequest: req.CreditOverrideRequest,
+	}
+	if quotation.DiscountType != nil {
+		order.DiscountType = *quotation.DiscountType
+	}
+	// The payment term counts from the day the order is placed
+	if quotation.PaymentMethod == "paylater" && quotation.PaymentTermDays != nil {
+		order.PaymentDueDate = time.Now().AddDate(0, 0, *quotation.PaymentTermDays).Format(time.RFC3339)
+	}
+	for _, item := range quotation.Items {
+		line := sales.SalesOrderItemRequest{
+			ProductID:     item.ProductID,
+			StorageID:     req.StorageID,
+			Quantity:      item.Quantity,
+			UnitPrice:     item.UnitPrice,
+			DiscountValue: item.DiscountValue,
+		}
+		if item.DiscountType != nil {
+			line.DiscountType = *item.DiscountType
+		}
+		order.Items = append(order.Items, line)
+	}
+
+	created, err := s.salesService.CreateSalesOrder(order, userID)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"general": err.Error(),
+		})
+	}
+	return created, nil
+}
@@ -55,11 +55,12 @@ type SalesOrderStorage struct {
 // GetSalesOrdersRequest defines the parameters for fetching sales orders
 type GetSalesOrdersRequest struct {
 	CustomerID    string `json:"customer_id,omitempty" validate:"omitempty,uuid"`
-	Status        string `json:"status,omitempty" validate:"omitempty,oneof=purchase-order invoice delivery partial_return return cancel"`
+	Status        string `json:"status,omitempty" validate:"omitempty,oneof=purchase-order partially_invoiced invoice partially_delivered delivery partial_return return cancel"`
 	PaymentMethod string `json:"payment_method,omitempty" validate:"omitempty,oneof=cash paylater"`
 	StartDate     string `json:"start_date,omitempty" validate:"omitempty,rfc3339"`
 	EndDate       string `json:"end_date,omitempty" validate:"omitempty,rfc3339"`
 	SerialID      string `json:"serial_id,omitempty"`
+	BranchID      string `json:"-"`
 	utils.PaginationParameter
 }
 
@@ -103,6 +104,25 @@ type SalesOrderItem struct {
 	MaxQuantity    float64 `json:"max_quantity"`
 	StorageID      string  `json:"storage_id"`
 	StorageName    string  `json:"storage_name"`
+	ReservedUntil  *string `json:"reserved_until,omitempty"` // Set while the item holds a stock reservation
+
+	// Price list price the line was offered, and whether its unit price was typed over it
+	ListPrice       *float64 `json:"list_price,omitempty"`
+	PriceOverridden bool     `json:"price_overridden"`
+
+	// Discount of the line and its share of the order grand total after the order discount and tax
+	DiscountType   *string `json:"discount_type,omitempty"`
+	DiscountValue  float64 `json:"discount_value"`
+	DiscountAmount float64 `json:"discount_amount"`
+	LineTotal      float64 `json:"line_total"`
+
+	// Progress billing, the part of the line covered by active invoices
+	InvoicedQuantity   float64 `json:"invoiced_quantity"`
+	RemainingToInvoice float64 `json:"remaining_to_invoice"`
+
+	// Partial deliveries, the part of the line carried by active delivery notes
+	DeliveredQuantity  float64 `json:"delivered_quantity"`
+	RemainingToDeliver float64 `json:"remaining_to_deliver"`
 
 	// Return information (only populated for returned orders)
 	ReturnID       string  `json:"return_id,omitempty"`
@@ -119,6 +139,8 @@ type GetSalesOrderDetailResponse struct {
 	SalesInvoiceSerialID *string `json:"sales_invoice_serial_id,omitempty"`
 	DeliveryNoteID       *string `json:"delivery_note_id,omitempty"`
 	DeliveryNoteSerialID *string `json:"delivery_note_serial_id,omitempty"`
+	QuotationID          *string `json:"quotation_id,omitempty"`
+	QuotationSerialID    *string `json:"quotation_serial_id,omitempty"`
 	SerialID             string  `json:"serial_id"`
 	CustomerID           string  `json:"customer_id"`
 	CustomerName         string  `json:"customer_name"`
@@ -126,6 +148,14 @@ type GetSalesOrderDetailResponse struct {
 	CustomerAddress      *string `json:"customer_address,omitempty"`
 	OrderDate            string  `json:"order_date"`
 	Status               string  `json:"status"`
+	DiscountType         *string `json:"discount_type,omitempty"` // Order discount taken after the line discounts
+	DiscountValue        float64 `json:"discount_value"`
+	TaxRate              float64 `json:"tax_rate"`
+	TaxMode              string  `json:"tax_mode"`
+	Subtotal             float64 `json:"subtotal"`        // Lines before discounts
+	DiscountAmount       float64 `json:"discount_amount"` // Line and order discounts
+	TaxBase              float64 `json:"tax_base"`        // DPP
+	TaxAmount            float64 `json:"tax_amount"`      // PPN
 	TotalAmount          float64 `json:"total_amount"`
 	PaymentMethod        string  `json:"payment_method"`
 	PaymentDueDate       *string `json:"payment_due_date,omitempty"`
@@ -140,6 +170,55 @@ type GetSalesOrderDetailResponse struct {
 
 	// Order items/details
 	Items []SalesOrderItem `json:"items"`
+
+	// Every invoice of the order, the latest active one is also in SalesInvoiceID
+	Invoices []SalesOrderInvoice `json:"invoices"`
+
+	// Every delivery note of the order, the latest active one is also in DeliveryNoteID
+	DeliveryNotes []SalesOrderDeliveryNote `json:"delivery_notes"`
+
+	// Approvals that let the order through over the credit limits of the customer
+	CreditOverrides []SalesOrderCreditOverride `json:"credit_overrides"`
+}
+
+// SalesOrderInvoice is an invoice listed on its sales order
+type SalesOrderInvoice struct {
+	ID          string  `json:"id"`
+	SerialID    string  `json:"serial_id"`
+	InvoiceDate string  `json:"invoice_date"`
+	TotalAmount float64 `json:"total_amount"`
+	CancelledAt *string `json:"cancelled_at,omitempty"`
+}
+
+// SalesOrderDeliveryNote is a delivery note listed on its sales order
+type SalesOrderDeliveryNote struct {
+	ID             string  `json:"id"`
+	SerialID       string  `json:"serial_id"`
+	SalesInvoiceID string  `json:"sales_invoice_id"`
+	DeliveryDate   string  `json:"delivery_date"`
+	CancelledAt    *string `json:"cancelled_at,omitempty"`
+}
+
+// SalesOrderCreditOverride is an approval letting a paylater order through over the credit limits of the customer
+type SalesOrderCreditOverride struct {
+	ID                string   `json:"id"`
+	OrderAmount       float64  `json:"order_amount"`
+	OutstandingAmount float64  `json:"outstanding_amount"`
+	CreditLimit       *float64 `json:"credit_limit,omitempty"`
+	OverdueDays       int      `json:"overdue_days"`
+	MaxOverdueDays    *int     `json:"max_overdue_days,omitempty"`
+	Reason            string   `json:"reason"`
+	ApprovedBy        *string  `json:"approved_by,omitempty"`
+	ApprovedByName    *string  `json:"approved_by_name,omitempty"`
+	CreatedAt         string   `json:"created_at"`
+}
+
+// CreditOverrideRequest asks to let a paylater order through over the credit limits of the customer,
+// only callers allowed to approve credit overrides may set it
+type CreditOverrideRequest struct {
+	CreditOverride       bool   `json:"credit_override"`
+	CreditOverrideReason string `json:"credit_override_reason,omitempty" validate:"required_if=CreditOverride true,max=1000"`
+	CanOverrideCredit    bool   `json:"-"` // Set from the roles of the caller
 }
 
 // CreateSalesOrderRequest defines the request for creating a sales purchase-order
@@ -149,13 +228,49 @@ type CreateSalesOrderRequest struct {
 	PaymentDueDate string                  `json:"payment_due_date,omitempty" validate:"omitempty,rfc3339"`
 	Items          []SalesOrderItemRequest `json:"items" validate:"required,min=1,dive"`
 	CreateInvoice  bool                    `json:"create_invoice" validate:"omitempty"`
-}
-
-// SalesOrderItemRequest defines an item in a create sales purchase-order request
+	// Order discount taken after the line discounts, tax defaults to the company setting
+	DiscountType  string   `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue float64  `json:"discount_value,omitempty" validate:"gte=0"`
+	TaxRate       *float64 `json:"tax_rate,omitempty" validate:"omitempty,gte=0,lte=100"`
+	TaxMode       string   `json:"tax_mode,omitempty" validate:"omitempty,oneof=exclusive inclusive"`
+	// Cash handed over on a cash sale, the change is worked out from the total
+	AmountTendered *float64 `json:"amount_tendered,omitempty" validate:"omitempty,gte=0"`
+	BranchID       string   `json:"-"`
+	QuotationID    string   `json:"-"` // Set when the order is converted from a quotation
+	CreditOverrideRequest
+}
+
+// SalesOrderItemRequest defines an item in a create sales purchase-order request.
+// Either a batch storage is picked by hand, or a product is given and its stock is allocated oldest batch first.
 type SalesOrderItemRequest struct {
-	BatchStorageID string  `json:"batch_storage_id" validate:"required,uuid"`
+	BatchStorageID string  `json:"batch_storage_id,omitempty" validate:"required_without=ProductID,excluded_with=ProductID,omitempty,uuid"`
+	ProductID      string  `json:"product_id,omitempty" validate:"required_without=BatchStorageID,omitempty,uuid"`
+	StorageID      string  `json:"storage_id,omitempty" validate:"omitempty,uuid"` // Preferred storage when allocating
 	Quantity       float64 `json:"quantity" validate:"required,gt=0"`
-	UnitPrice      float64 `json:"unit_price" validate:"required,gt=0"`
+	UnitPrice      float64 `json:"unit_price" validate:"omitempty,gt=0"` // Taken from the customer's price list when empty
+	DiscountType   string  `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue  float64 `json:"discount_value,omitempty" validate:"gte=0"` // An amount is split over the batches by quantity
+}
+
+// SalesOrderAllocation is a batch storage a created order line takes its stock from
+type SalesOrderAllocation struct {
+	SalesDetailID   string   `json:"sales_detail_id"`
+	ProductID       string   `json:"product_id"`
+	ProductName     string   `json:"product_name"`
+	BatchStorageID  string   `json:"batch_storage_id"`
+	BatchID         string   `json:"batch_id"`
+	BatchSKU        string   `json:"batch_sku"`
+	StorageID       string   `json:"storage_id"`
+	StorageName     string   `json:"storage_name"`
+	Quantity        float64  `json:"quantity"`
+	UnitPrice       float64  `json:"unit_price"`
+	ListPrice       *float64 `json:"list_price,omitempty"` // Price list price of the customer for the line
+	PriceOverridden bool     `json:"price_overridden"`     // The unit price was typed over the list price
+	DiscountType    string   `json:"discount_type,omitempty"`
+	DiscountValue   float64  `json:"discount_value"`
+	DiscountAmount  float64  `json:"discount_amount"`
+	LineTotal       float64  `json:"line_total"` // Share of the order grand total
+	Allocated       bool     `json:"allocated"`  // False when the batch storage was picked by hand
 }
 
 // CreateSalesOrderResponse defines the response for creating a sales purchase-order
@@ -167,10 +282,27 @@ type CreateSalesOrderResponse struct {
 	Status          string  `json:"status"`
 	PaymentMethod   string  `json:"payment_method"`
 	PaymentDueDate  string  `json:"payment_due_date,omitempty"`
+	DiscountType    string  `json:"discount_type,omitempty"`
+	DiscountValue   float64 `json:"discount_value"`
+	TaxRate         float64 `json:"tax_rate"`
+	TaxMode         string  `json:"tax_mode"`
+	Subtotal        float64 `json:"subtotal"`
+	DiscountAmount  float64 `json:"discount_amount"`
+	TaxBase         float64 `json:"tax_base"`
+	TaxAmount       float64 `json:"tax_amount"`
 	TotalAmount     float64 `json:"total_amount"`
 	CreatedAt       string  `json:"created_at"`
 	InvoiceID       string  `json:"invoice_id,omitempty"`
 	InvoiceSerialID string  `json:"invoice_serial_id,omitempty"`
+	QuotationID     string  `json:"quotation_id,omitempty"`
+	CreditOverride  bool    `json:"credit_override,omitempty"` // The order went over the credit limits with approval
+
+	// Cash handed over and the change given back on a cash sale
+	AmountTendered *float64 `json:"amount_tendered,omitempty"`
+	ChangeAmount   *float64 `json:"change_amount,omitempty"`
+
+	// Order lines with the batches they were taken from
+	Allocations []SalesOrderAllocation `json:"allocations"`
 }
 
 // UpdateSalesOrderRequest defines the request for updating a sales purchase-order
@@ -179,6 +311,12 @@ type UpdateSalesOrderRequest struct {
 	CustomerID     string `json:"customer_id,omitempty" validate:"omitempty,uuid"`
 	PaymentMethod  string `json:"payment_method,omitempty" validate:"omitempty,oneof=cash paylater"`
 	PaymentDueDate string `json:"payment_due_date,omitempty" validate:"omitempty,rfc3339"`
+	// Order discount and tax, a discount value of 0 removes the discount
+	DiscountType  *string  `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue *float64 `json:"discount_value,omitempty" validate:"omitempty,gte=0"`
+	TaxRate       *float64 `json:"tax_rate,omitempty" validate:"omitempty,gte=0,lte=100"`
+	TaxMode       string   `json:"tax_mode,omitempty" validate:"omitempty,oneof=exclusive inclusive"`
+	BranchID      string   `json:"-"`
 }
 
 // UpdateSalesOrderResponse defines the response for updating a sales purchase-order
@@ -189,6 +327,15 @@ type UpdateSalesOrderResponse struct {
 	Status         string  `json:"status"`
 	PaymentMethod  string  `json:"payment_method"`
 	PaymentDueDate *string `json:"payment_due_date,omitempty"`
+	DiscountType   *string `json:"discount_type,omitempty"`
+	DiscountValue  float64 `json:"discount_value"`
+	TaxRate        float64 `json:"tax_rate"`
+	TaxMode        string  `json:"tax_mode"`
+	Subtotal       float64 `json:"subtotal"`
+	DiscountAmount float64 `json:"discount_amount"`
+	TaxBase        float64 `json:"tax_base"`
+	TaxAmount      float64 `json:"tax_amount"`
+	TotalAmount    float64 `json:"total_amount"`
 	UpdatedAt      string  `json:"updated_at"`
 }
 
@@ -197,7 +344,11 @@ type AddSalesOrderItemRequest struct {
 	SalesOrderID   string  `json:"sales_order_id" validate:"required,uuid"`
 	BatchStorageID string  `json:"batch_storage_id" validate:"required,uuid"` // Primary reference
 	Quantity       float64 `json:"quantity" validate:"required,gt=0"`
-	UnitPrice      float64 `json:"unit_price" validate:"required,gt=0"`
+	UnitPrice      float64 `json:"unit_price" validate:"omitempty,gt=0"` // Taken from the customer's price list when empty
+	DiscountType   string  `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue  float64 `json:"discount_value,omitempty" validate:"gte=0"`
+	BranchID       string  `json:"-"`
+	CreditOverrideRequest
 }
 
 // UpdateSalesOrderItemRequest defines the request for updating an item in a sales purchase-order
@@ -207,30 +358,45 @@ type UpdateSalesOrderItemRequest struct {
 	BatchStorageID string  `json:"batch_storage_id" validate:"omitempty,uuid"`
 	Quantity       float64 `json:"quantity" validate:"omitempty,gt=0"`
 	UnitPrice      float64 `json:"unit_price" validate:"omitempty,gt=0"`
+	// A discount value of 0 removes the discount of the line
+	DiscountType  *string  `json:"discount_type,omitempty" validate:"omitempty,oneof=percent amount"`
+	DiscountValue *float64 `json:"discount_value,omitempty" validate:"omitempty,gte=0"`
+	BranchID      string   `json:"-"`
+	CreditOverrideRequest
 }
 
 // DeleteSalesOrderItemRequest defines the request for deleting an item from a sales purchase-order
 type DeleteSalesOrderItemRequest struct {
 	SalesOrderID string `json:"sales_order_id" validate:"required,uuid"`
 	DetailID     string `json:"detail_id" validate:"required,uuid"`
+	BranchID     string `json:"-"`
 }
 
 // CancelSalesOrderRequest defines the request for cancelling a sales purchase-order
 type CancelSalesOrderRequest struct {
 	SalesOrderID string `json:"sales_order_id" validate:"required,uuid"`
+	BranchID     string `json:"-"`
 }
 
 // UpdateAndCreateItemResponse defines the response for updating or adding an item
 type UpdateAndCreateItemResponse struct {
-	DetailID       string  `json:"detail_id"`
-	ProductID      string  `json:"product_id"`
-	ProductName    string  `json:"product_name"`
-	BatchID        string  `json:"batch_id"`
-	BatchSKU       string  `json:"batch_sku"`
-	BatchStorageID string  `json:"batch_storage_id"`
-	Quantity       float64 `json:"quantity"`
-	UnitPrice      float64 `json:"unit_price"`
-	TotalPrice     float64 `json:"total_price"`
+	DetailID        string   `json:"detail_id"`
+	ProductID       string   `json:"product_id"`
+	ProductName     string   `json:"product_name"`
+	BatchID         string   `json:"batch_id"`
+	BatchSKU        string   `json:"batch_sku"`
+	BatchStorageID  string   `json:"batch_storage_id"`
+	Quantity        float64  `json:"quantity"`
+	UnitPrice       float64  `json:"unit_price"`
+	TotalPrice      float64  `json:"total_price"`
+	ListPrice       *float64 `json:"list_price,omitempty"` // Price list price of the customer for the line
+	PriceOverridden bool     `json:"price_overridden"`     // The unit price was typed over the list price
+	DiscountType    string   `json:"discount_type,omitempty"`
+	DiscountValue   float64  `json:"discount_value"`
+	DiscountAmount  float64  `json:"discount_amount"`
+	LineTotal       float64  `json:"line_total"`                // Share of the order grand total
+	OrderTotal      float64  `json:"order_total"`               // Grand total of the order after the change
+	CreditOverride  bool     `json:"credit_override,omitempty"` // The item went over the credit limits with approval
 }
 
 // SalesInvoice represents a sales invoice entity from the database
@@ -249,29 +415,39 @@ type SalesInvoice struct {
 
 // GetSalesInvoicesRequest defines parameters for fetching sales invoices
 type GetSalesInvoicesRequest struct {
-	CustomerID string `json:"customer_id,omitempty" validate:"omitempty,uuid"`
-	StartDate  string `json:"start_date,omitempty" validate:"omitempty,rfc3339"`
-	EndDate    string `json:"end_date,omitempty" validate:"omitempty,rfc3339"`
-	SerialID   string `json:"serial_id,omitempty"`
-	Status     string `json:"status,omitempty" validate:"omitempty,oneof=active cancelled partially_returned returned"`
+	CustomerID    string `json:"customer_id,omitempty" validate:"omitempty,uuid"`
+	StartDate     string `json:"start_date,omitempty" validate:"omitempty,rfc3339"`
+	EndDate       string `json:"end_date,omitempty" validate:"omitempty,rfc3339"`
+	SerialID      string `json:"serial_id,omitempty"`
+	Status        string `json:"status,omitempty" validate:"omitempty,oneof=active cancelled partially_returned returned"`
+	PaymentStatus string `json:"payment_status,omitempty" validate:"omitempty,oneof=unpaid partially_paid paid overdue"`
+	BranchID      string `json:"-"`
 	utils.PaginationParameter
 }
 
 // GetSalesInvoicesResponse defines the response for fetching sales invoices
 type GetSalesInvoicesResponse struct {
-	ID               string  `json:"id"`
-	SerialID         string  `json:"serial_id"`
-	SalesOrderID     string  `json:"sales_order_id"`
-	SalesOrderSerial string  `json:"sales_order_serial"`
-	CustomerID       string  `json:"customer_id"`
-	CustomerName     string  `json:"customer_name"`
-	InvoiceDate      string  `json:"invoice_date"`
-	TotalAmount      float64 `json:"total_amount"`
-	Status           string  `json:"status"`
-	HasDeliveryNote  bool    `json:"has_delivery_note"`
-	CreatedBy        string  `json:"created_by"`
-	CreatedAt        string  `json:"created_at"`
-	CancelledAt      string  `json:"cancelled_at,omitempty"`
+	ID                string  `json:"id"`
+	SerialID          string  `json:"serial_id"`
+	SalesOrderID      string  `json:"sales_order_id"`
+	SalesOrderSerial  string  `json:"sales_order_serial"`
+	CustomerID        string  `json:"customer_id"`
+	CustomerName      string  `json:"customer_name"`
+	InvoiceDate       string  `json:"invoice_date"`
+	Subtotal          float64 `json:"subtotal"`
+	DiscountAmount    float64 `json:"discount_amount"`
+	TaxBase           float64 `json:"tax_base"`   // DPP
+	TaxAmount         float64 `json:"tax_amount"` // PPN
+	TotalAmount       float64 `json:"total_amount"`
+	PaidAmount        float64 `json:"paid_amount"`
+	OutstandingAmount float64 `json:"outstanding_amount"`
+	DueDate           *string `json:"due_date,omitempty"`
+	PaymentStatus     string  `json:"payment_status"` // unpaid, partially_paid, paid, overdue or cancelled
+	Status            string  `json:"status"`
+	HasDeliveryNote   bool    `json:"has_delivery_note"`
+	CreatedBy         string  `json:"created_by"`
+	CreatedAt         string  `json:"created_at"`
+	CancelledAt       string  `json:"cancelled_at,omitempty"`
 }
 
 // SalesInvoicePaginatedResponse defines a paginated response for sales invoices
@@ -282,22 +458,39 @@ type SalesInvoicePaginatedResponse struct {
 
 // SalesInvoiceItemResponse defines the detail items in a sales invoice
 type SalesInvoiceItemResponse struct {
-	ID             string  `json:"id"`
-	SalesOrderID   string  `json:"sales_order_id"`
-	ProductID      string  `json:"product_id"`
-	ProductName    string  `json:"product_name"`
-	BatchID        string  `json:"batch_id"`
-	BatchSKU       string  `json:"batch_sku"`
-	BatchStorageID string  `json:"batch_storage_id"`
-	Quantity       float64 `json:"quantity"`
-	ReturnedQty    float64 `json:"returned_qty"`
-	UnitPrice      float64 `json:"unit_price"`
-	TotalPrice     float64 `json:"total_price"`
+	ID                 string  `json:"id"`
+	SalesInvoiceID     string  `json:"sales_invoice_id"`
+	SalesOrderID       string  `json:"sales_order_id"`
+	SalesOrderDetailID string  `json:"sales_order_detail_id"`
+	ProductID          string  `json:"product_id"`
+	ProductName        string  `json:"product_name"`
+	ProductUnit        string  `json:"product_unit"`
+	BatchID            string  `json:"batch_id"`
+	BatchSKU           string  `json:"batch_sku"`
+	BatchStorageID     string  `json:"batch_storage_id"`
+	Quantity           float64 `json:"quantity"`
+	UnitPrice          float64 `json:"unit_price"`
+	TotalPrice         float64 `json:"total_price"`
+	DiscountAmount     float64 `json:"discount_amount"`
+	LineTotal          float64 `json:"line_total"`           // Share of the invoice grand total
+	DeliveredQuantity  float64 `json:"delivered_quantity"`   // Carried by active delivery notes of the invoice
+	RemainingToDeliver float64 `json:"remaining_to_deliver"` // Still to put on a delivery note
 }
 
-// CreateSalesInvoiceRequest defines the request for creating a sales invoice
+// CreateSalesInvoiceRequest defines the request for creating a sales invoice.
+// Without items the invoice covers everything of the order not invoiced yet.
 type CreateSalesInvoiceRequest struct {
-	SalesOrderID string `json:"sales_order_id" validate:"required,uuid"`
+	SalesOrderID string                    `json:"sales_order_id" validate:"required,uuid"`
+	Items        []SalesInvoiceItemRequest `json:"items,omitempty" validate:"omitempty,dive"`
+	// Cash handed over on a cash sale, the change is worked out from the invoice total
+	AmountTendered *float64 `json:"amount_tendered,omitempty" validate:"omitempty,gte=0"`
+	BranchID       string   `json:"-"`
+}
+
+// SalesInvoiceItemRequest is an order line and the quantity of it to invoice
+type SalesInvoiceItemRequest struct {
+	SalesOrderDetailID string  `json:"sales_order_detail_id" validate:"required,uuid"`
+	Quantity           float64 `json:"quantity" validate:"required,gt=0"`
 }
 
 // CreateSalesInvoiceResponse defines the response for creating a sales invoice
@@ -309,15 +502,30 @@ type CreateSalesInvoiceResponse struct {
 	CustomerID       string  `json:"customer_id"`
 	CustomerName     string  `json:"customer_name"`
 	InvoiceDate      string  `json:"invoice_date"`
+	TaxRate          float64 `json:"tax_rate"`
+	TaxMode          string  `json:"tax_mode"`
+	Subtotal         float64 `json:"subtotal"`
+	DiscountAmount   float64 `json:"discount_amount"`
+	TaxBase          float64 `json:"tax_base"`
+	TaxAmount        float64 `json:"tax_amount"`
 	TotalAmount      float64 `json:"total_amount"`
 	Status           string  `json:"status"`
+	OrderStatus      string  `json:"order_status"` // partially_invoiced while lines remain to be invoiced
 	CreatedBy        string  `json:"created_by"`
 	CreatedAt        string  `json:"created_at"`
+
+	// Cash handed over and the change given back on a cash sale
+	AmountTendered *float64 `json:"amount_tendered,omitempty"`
+	ChangeAmount   *float64 `json:"change_amount,omitempty"`
+
+	// Invoiced order lines
+	Items []SalesInvoiceItemResponse `json:"items"`
 }
 
 // CancelSalesInvoiceRequest defines the request for cancelling a sales invoice
 type CancelSalesInvoiceRequest struct {
 	InvoiceID string `json:"invoice_id" validate:"required,uuid"`
+	BranchID  string `json:"-"`
 }
 
 // ReturnItemRequest defines the request for returning items from a sales order
@@ -326,11 +534,13 @@ type ReturnItemRequest struct {
 	SalesOrderDetailID string  `json:"sales_order_detail_id" validate:"required,uuid"`
 	Quantity           float64 `json:"quantity" validate:"required,gt=0"`
 	ReturnReason       string  `json:"return_reason" validate:"required"`
+	BranchID           string  `json:"-"`
 }
 
 // CancelReturnRequest defines the request for cancelling a return
 type CancelReturnRequest struct {
 	ReturnID string `json:"return_id" validate:"required,uuid"`
+	BranchID string `json:"-"`
 }
 
 // SalesOrderReturn represents a sales purchase-order return entity
@@ -366,12 +576,41 @@ type DeliveryNote struct {
 	CancelledBy    *string `json:"cancelled_by,omitempty"`
 }
 
-// CreateDeliveryNoteRequest defines the request for creating a delivery note
+// DeliveryNoteItem is a line of a delivery note
+type DeliveryNoteItem struct {
+	ID                 string  `json:"id"`
+	DeliveryNoteID     string  `json:"delivery_note_id"`
+	SalesOrderDetailID string  `json:"sales_order_detail_id"`
+	ProductID          string  `json:"product_id"`
+	ProductName        string  `json:"product_name"`
+	ProductUnit        string  `json:"product_unit"`
+	BatchID            string  `json:"batch_id"`
+	BatchSKU           string  `json:"batch_sku"`
+	BatchStorageID     *string `json:"batch_storage_id,omitempty"`
+	StorageID          *string `json:"storage_id,omitempty"`
+	StorageName        *string `json:"storage_name,omitempty"`
+	Quantity           float64 `json:"quantity"`
+	UnitPrice          float64 `json:"unit_price"`
+	TotalPrice         float64 `json:"total_price"`
+}
+
+// CreateDeliveryNoteRequest defines the request for creating a delivery note.
+// Without items the delivery note carries everything of the invoice not delivered yet.
 type CreateDeliveryNoteRequest struct {
-	SalesInvoiceID string `json:"sales_invoice_id" validate:"required,uuid"`
-	DriverName     string `json:"driver_name" validate:"required"`
-	RecipientName  string `json:"recipient_name" validate:"required"`
-	DeliveryDate   string `json:"delivery_date,omitempty" validate:"omitempty,rfc3339"`
+	SalesInvoiceID string                    `json:"sales_invoice_id" validate:"required,uuid"`
+	DriverName     string                    `json:"driver_name" validate:"required"`
+	RecipientName  string                    `json:"recipient_name" validate:"required"`
+	DeliveryDate   string                    `json:"delivery_date,omitempty" validate:"omitempty,rfc3339"`
+	Items          []DeliveryNoteItemRequest `json:"items,omitempty" validate:"omitempty,dive"`
+	BranchID       string                    `json:"-"`
+}
+
+// DeliveryNoteItemRequest is a line of the invoice to put on a delivery note.
+// Without a storage the goods are loaded from the storage the order line was issued from.
+type DeliveryNoteItemRequest struct {
+	SalesOrderDetailID string  `json:"sales_order_detail_id" validate:"required,uuid"`
+	Quantity           float64 `json:"quantity" validate:"required,gt=0"`
+	StorageID          string  `json:"storage_id,omitempty" validate:"omitempty,uuid"`
 }
 
 // CreateDeliveryNoteResponse defines the response for creating a delivery note
@@ -385,13 +624,17 @@ type CreateDeliveryNoteResponse struct {
 	DeliveryDate       string `json:"delivery_date"`
 	DriverName         string `json:"driver_name"`
 	RecipientName      string `json:"recipient_name"`
+	OrderStatus        string `json:"order_status"`
 	CreatedBy          string `json:"created_by"`
 	CreatedAt          string `json:"created_at"`
+
+	Items []DeliveryNoteItem `json:"items"`
 }
 
 // CancelDeliveryNoteRequest defines the request for cancelling a delivery note
 type CancelDeliveryNoteRequest struct {
 	DeliveryNoteID string `json:"delivery_note_id" validate:"required,uuid"`
+	BranchID       string `json:"-"`
 }
 
 // GetDeliveryNotesRequest defines parameters for fetching delivery notes
@@ -402,24 +645,28 @@ type GetDeliveryNotesRequest struct {
 	EndDate        string `json:"end_date,omitempty" validate:"omitempty,rfc3339"`
 	SerialID       string `json:"serial_id,omitempty"`
 	Status         string `json:"status,omitempty" validate:"omitempty,oneof=active cancelled partially_returned returned"`
+	BranchID       string `json:"-"`
 	utils.PaginationParameter
 }
 
 // GetDeliveryNotesResponse defines the response for fetching delivery notes
 type GetDeliveryNotesResponse struct {
-	ID                 string `json:"id"`
-	SerialID           string `json:"serial_id"`
-	SalesOrderID       string `json:"sales_order_id"`
-	SalesOrderSerial   string `json:"sales_order_serial"`
-	SalesInvoiceID     string `json:"sales_invoice_id"`
-	SalesInvoiceSerial string `json:"sales_invoice_serial"`
-	DeliveryDate       string `json:"delivery_date"`
-	DriverName         string `json:"driver_name"`
-	RecipientName      string `json:"recipient_name"`
-	Status             string `json:"status"`
-	CreatedBy          string `json:"created_by"`
-	CreatedAt          string `json:"created_at"`
-	CancelledAt        string `json:"cancelled_at,omitempty"`
+	ID                 string  `json:"id"`
+	SerialID           string  `json:"serial_id"`
+	SalesOrderID       string  `json:"sales_order_id"`
+	SalesOrderSerial   string  `json:"sales_order_serial"`
+	SalesInvoiceID     string  `json:"sales_invoice_id"`
+	SalesInvoiceSerial string  `json:"sales_invoice_serial"`
+	DeliveryDate       string  `json:"delivery_date"`
+	DriverName         string  `json:"driver_name"`
+	RecipientName      string  `json:"recipient_name"`
+	CustomerID         string  `json:"customer_id"`
+	CustomerName       string  `json:"customer_name"`
+	TotalQuantity      float64 `json:"total_quantity"`
+	Status             string  `json:"status"`
+	CreatedBy          string  `json:"created_by"`
+	CreatedAt          string  `json:"created_at"`
+	CancelledAt        string  `json:"cancelled_at,omitempty"`
 }
 
 // DeliveryNotePaginatedResponse defines a paginated response for delivery notes
@@ -430,19 +677,25 @@ type DeliveryNotePaginatedResponse struct {
 
 // GetAllBatchesRequest holds query parameters for batch search
 type GetAllBatchesRequest struct {
-	Search string `json:"search" validate:"omitempty"`
+	Search     string  `json:"search" validate:"omitempty"`
+	CustomerID string  `json:"customer_id" validate:"omitempty,uuid"` // Suggests the customer's list prices
+	Quantity   float64 `json:"quantity" validate:"omitempty,gt=0"`    // Quantity the list prices break on, 1 when empty
+	BranchID   string  `json:"-"`
 	utils.PaginationParameter
 }
 
 type GetAllBatchesStorageItem struct {
-	BatchStorageID string  `json:"batch_storage_id"`
-	BatchID        string  `json:"batch_id"`
-	BatchSKU       string  `json:"batch_sku"`
-	Quantity       float64 `json:"quantity"`
-	Price          float64 `json:"price"`
-	ProductID      string  `json:"product_id"`
-	ProductName    string  `json:"product_name"`
-	CreatedAt      string  `json:"created_at"`
+	BatchStorageID    string   `json:"batch_storage_id"`
+	BatchID           string   `json:"batch_id"`
+	BatchSKU          string   `json:"batch_sku"`
+	Quantity          float64  `json:"quantity"`           // On hand in the storage
+	ReservedQuantity  float64  `json:"reserved_quantity"`  // Held by open sales orders
+	AvailableQuantity float64  `json:"available_quantity"` // On hand minus reserved
+	Price             float64  `json:"price"`
+	ListPrice         *float64 `json:"list_price,omitempty"` // Suggested price from the customer's price list
+	ProductID         string   `json:"product_id"`
+	ProductName       string   `json:"product_name"`
+	CreatedAt         string   `json:"created_at"`
 }
 
 // GetAllBatchesResponse is used when returning batch data to clients
@@ -456,6 +709,7 @@ type GetAllBatchesResponse struct {
 // ReturnInvoiceItemsResponse defines the response for returning items from a sales order
 type ReturnInvoiceItemsResponse struct {
 	ReturnID      string  `json:"return_id"`
+	SerialID      string  `json:"serial_id"`
 	SalesOrderID  string  `json:"sales_order_id"`
 	ReturnedItems float64 `json:"returned_items"`
 	TotalQuantity float64 `json:"total_quantity"`
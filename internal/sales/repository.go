@@ -4,6 +4,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
+	"sinartimur-go/internal/creditnote"
+	"sinartimur-go/internal/outbox"
+	"sinartimur-go/pkg/event"
+	"sinartimur-go/pkg/pricing"
 	"sinartimur-go/utils"
 	"sort"
 	"strconv"
@@ -19,17 +24,19 @@ type SalesRepository interface {
 	GetSalesOrderByID(id string) (*SalesOrder, error)
 	GetSalesOrderItems(salesOrderID string) ([]SalesOrderItem, error)
 	GetSalesOrderWithDetails(salesOrderID string) (*GetSalesOrderDetailResponse, error)
+	GetDocumentBranch(documentType, id string) (string, error)
 	CreateSalesOrder(req CreateSalesOrderRequest, userID string) (*CreateSalesOrderResponse, error)
 	UpdateSalesOrder(req UpdateSalesOrderRequest) (*UpdateSalesOrderResponse, error)
 	CancelSalesOrder(req CancelSalesOrderRequest, userID string) error
 
 	// Sales Order Item operations
-	AddItemToSalesOrder(req AddSalesOrderItemRequest) (*UpdateAndCreateItemResponse, error)
-	UpdateSalesOrderItem(req UpdateSalesOrderItemRequest) (*UpdateAndCreateItemResponse, error)
+	AddItemToSalesOrder(req AddSalesOrderItemRequest, userID string) (*UpdateAndCreateItemResponse, error)
+	UpdateSalesOrderItem(req UpdateSalesOrderItemRequest, userID string) (*UpdateAndCreateItemResponse, error)
 	DeleteSalesOrderItem(req DeleteSalesOrderItemRequest) error
 
 	// Invoice operations
 	GetSalesInvoices(req GetSalesInvoicesRequest) ([]GetSalesInvoicesResponse, int, error)
+	GetSalesInvoiceItems(invoiceID string) ([]SalesInvoiceItemResponse, error)
 	CreateSalesInvoice(req CreateSalesInvoiceRequest, userID string,
 		tx *sql.Tx) (*CreateSalesInvoiceResponse, error)
 	CancelSalesInvoice(req CancelSalesInvoiceRequest, userID string) error
@@ -39,19 +46,26 @@ type SalesRepository interface {
 	CancelSalesOrderReturn(req CancelReturnRequest, userID string) error
 
 	// Delivery Note operations
+	GetDeliveryNotes(req GetDeliveryNotesRequest) ([]GetDeliveryNotesResponse, int, error)
+	GetDeliveryNoteItems(deliveryNoteID string) ([]DeliveryNoteItem, error)
 	CreateDeliveryNote(req CreateDeliveryNoteRequest, userID string) (*CreateDeliveryNoteResponse, error)
 	CancelDeliveryNote(req CancelDeliveryNoteRequest, userID string) error
 
 	// Batch operations
 	GetAllBatches(req GetAllBatchesRequest) ([]GetAllBatchesResponse, int, error)
+
+	// Stock reservation operations
+	ExpireReservations(limit int) (int, error)
 }
 
 type SalesRepositoryImpl struct {
 	db *sql.DB
+	// reservationTTL is how long an open order holds its stock
+	reservationTTL time.Duration
 }
 
-func NewSalesRepository(db *sql.DB) SalesRepository {
-	return &SalesRepositoryImpl{db: db}
+func NewSalesRepository(db *sql.DB, reservationTTL time.Duration) SalesRepository {
+	return &SalesRepositoryImpl{db: db, reservationTTL: reservationTTL}
 }
 
 // GetAllBatches retrieves all product batches with pagination and filtering
@@ -65,10 +79,12 @@ func (r *SalesRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAllB
 	qb := utils.NewQueryBuilder(`
         Select Bs.Id, Pb.Id As Batch_Id, Pb.Sku, Pb.Product_Id, P.Name As Product_Name, 
                Pb.Current_Quantity, Pb.Unit_Price, Pb.Created_At, 
-               Bs.Storage_Id, S.Name As Storage_Name, S.Location As Storage_Location, Bs.Quantity
+               Bs.Storage_Id, S.Name As Storage_Name, S.Location As Storage_Location, Bs.Quantity,
+               St.Reserved, St.Available
         From Product_Batch Pb
         Join Product P On Pb.Product_Id = P.Id
         Join Batch_Storage Bs On Pb.Id = Bs.Batch_Id
+        Join Batch_Storage_Stock St On St.Id = Bs.Id
         Join Storage S On Bs.Storage_Id = S.Id
         Where Pb.Current_Quantity > 0 And Bs.Quantity > 0
     `)
@@ -80,22 +96,19 @@ func (r *SalesRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAllB
 		qb.Params = append(qb.Params, searchTerm)
 		qb.Count++
 	}
+	qb.AddFilter("S.Branch_Id =", req.BranchID)
 
 	// Get count first (count distinct storage_ids to get number of storage groups)
-	countQuery := "Select Count(Distinct Bs.Storage_Id) From Product_Batch Pb Join Batch_Storage Bs On Pb.Id = Bs.Batch_Id Join Product P On Pb.Product_Id = P.Id Where Pb.Current_Quantity > 0 And Bs.Quantity > 0"
+	countQb := utils.NewQueryBuilder("Select Count(Distinct Bs.Storage_Id) From Product_Batch Pb Join Batch_Storage Bs On Pb.Id = Bs.Batch_Id Join Product P On Pb.Product_Id = P.Id Join Storage S On Bs.Storage_Id = S.Id Where Pb.Current_Quantity > 0 And Bs.Quantity > 0")
 
-	// Add search condition to count query if needed
+	// Add search and branch conditions to count query if needed
 	if req.Search != "" {
-		countQuery += " And (pb.sku Ilike $1 Or p.name Ilike $1)"
-		err := r.db.QueryRow(countQuery, "%"+req.Search+"%").Scan(&totalItems)
-		if err != nil {
-			return nil, 0, err
-		}
-	} else {
-		err := r.db.QueryRow(countQuery).Scan(&totalItems)
-		if err != nil {
-			return nil, 0, err
-		}
+		countQb.AddFilterExpr("(pb.sku Ilike $? Or p.name Ilike $?)", "%"+req.Search+"%")
+	}
+	countQb.AddFilter("S.Branch_Id =", req.BranchID)
+	countQuery, countParams := countQb.Build()
+	if err := r.db.QueryRow(countQuery, countParams...).Scan(&totalItems); err != nil {
+		return nil, 0, err
 	}
 
 	// Add sorting
@@ -112,12 +125,13 @@ func (r *SalesRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAllB
 
 	// Sanitize the sort fields to prevent SQL injection
 	validSortFields := map[string]string{
-		"sku":              "pb.sku",
-		"product_name":     "p.name",
-		"current_quantity": "bs.quantity",
-		"unit_price":       "pb.unit_price",
-		"created_at":       "pb.created_at",
-		"storage_name":     "s.name",
+		"sku":                "pb.sku",
+		"product_name":       "p.name",
+		"current_quantity":   "bs.quantity",
+		"available_quantity": "st.available",
+		"unit_price":         "pb.unit_price",
+		"created_at":         "pb.created_at",
+		"storage_name":       "s.name",
 	}
 
 	// Use the mapped field if valid, otherwise default to storage name
@@ -146,7 +160,7 @@ func (r *SalesRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAllB
 	// Map results to storage groups
 	for rows.Next() {
 		var storageID, storageName, storageLocation, id, batchID, sku, productID, productName string
-		var quantity, unitPrice float64
+		var quantity, reserved, available, unitPrice float64
 		var createdAt time.Time
 
 		err := rows.Scan(
@@ -162,6 +176,8 @@ func (r *SalesRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAllB
 			&storageName,
 			&storageLocation,
 			&quantity,
+			&reserved,
+			&available,
 		)
 		if err != nil {
 			return nil, 0, err
@@ -181,17 +197,45 @@ func (r *SalesRepositoryImpl) GetAllBatches(req GetAllBatchesRequest) ([]GetAllB
 
 		// Add batch item to storage group
 		storageGroup.GetAllBatchesStorageItems = append(storageGroup.GetAllBatchesStorageItems, GetAllBatchesStorageItem{
-			BatchStorageID: id,
-			BatchID:        batchID,
-			BatchSKU:       sku,
-			Quantity:       quantity,
-			Price:          unitPrice,
-			ProductID:      productID,
-			ProductName:    productName,
-			CreatedAt:      createdAt.Format(time.RFC3339),
+			BatchStorageID:    id,
+			BatchID:           batchID,
+			BatchSKU:          sku,
+			Quantity:          quantity,
+			ReservedQuantity:  reserved,
+			AvailableQuantity: available,
+			Price:             unitPrice,
+			ProductID:         productID,
+			ProductName:       productName,
+			CreatedAt:         createdAt.Format(time.RFC3339),
 		})
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// Suggest the customer's list price of every product for the quantity being ordered
+	if req.CustomerID != "" {
+		quantity := req.Quantity
+		if quantity <= 0 {
+			quantity = 1
+		}
+		listPrices := make(map[string]*float64)
+		for _, storageGroup := range storageMap {
+			for i, item := range storageGroup.GetAllBatchesStorageItems {
+				listPrice, ok := listPrices[item.ProductID]
+				if !ok {
+					listPrice, err = customerListPrice(r.db, req.CustomerID, item.ProductID, quantity)
+					if err != nil {
+						return nil, 0, err
+					}
+					listPrices[item.ProductID] = listPrice
+				}
+				storageGroup.GetAllBatchesStorageItems[i].ListPrice = listPrice
+			}
+		}
+	}
+
 	// Convert map to slice
 	result := make([]GetAllBatchesResponse, 0, len(storageMap))
 	for _, storageGroup := range storageMap {
@@ -265,6 +309,12 @@ func (r *SalesRepositoryImpl) GetSalesOrders(req GetSalesOrdersRequest) ([]GetSa
 		countQb.AddFilter(condition, likeValue)
 	}
 
+	if req.BranchID != "" {
+		condition := "so.branch_id ="
+		qb.AddFilter(condition, req.BranchID)
+		countQb.AddFilter(condition, req.BranchID)
+	}
+
 	if req.StartDate != "" {
 		startDate, err := time.Parse(time.RFC3339, req.StartDate)
 		if err == nil {
@@ -298,7 +348,7 @@ func (r *SalesRepositoryImpl) GetSalesOrders(req GetSalesOrdersRequest) ([]GetSa
 	qb.Query.WriteString(fmt.Sprintf(" ORDER BY %s %s", sortBy, sortOrder))
 
 	// Add pagination to main query only
-	qb.AddPagination(req.PageSize, req.Page)
+	qb.AddPagination(req.PaginationParameter)
 
 	// Build final queries
 	query, params := qb.Build()
@@ -343,6 +393,12 @@ func (r *SalesRepositoryImpl) GetSalesOrders(req GetSalesOrdersRequest) ([]GetSa
 			return nil, 0, fmt.Errorf("error scanning sales order row: %w", errScan)
 		}
 
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(order); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		orders = append(orders, order)
 	}
 
@@ -362,9 +418,12 @@ func (r *SalesRepositoryImpl) GetSalesOrderWithDetails(salesOrderID string) (*Ge
         SELECT 
             So.Id, So.Serial_Id, So.Customer_Id, C.Name, C.Telephone, C.Address,
             So.Order_Date, So.Status, So.Payment_Method, So.Payment_Due_Date, 
+            So.Discount_Type, So.Discount_Value, So.Tax_Rate, So.Tax_Mode,
+            So.Subtotal, So.Discount_Amount, So.Tax_Base, So.Tax_Amount,
             So.Total_Amount, So.Created_By, Au.Username, So.Created_At, So.Updated_At, So.Cancelled_At,
             Si.Id, Si.Serial_Id AS Sales_Invoice_Serial_Id,
             Dn.Id, Dn.Serial_Id AS Delivery_Note_Serial_Id,
+            Sq.Id, Sq.Serial_Id AS Quotation_Serial_Id,
             So.Cancelled_At,
             Au.Username AS Created_By_Name, 
             Au2.Username AS Cancelled_By_Name
@@ -386,6 +445,7 @@ func (r *SalesRepositoryImpl) GetSalesOrderWithDetails(salesOrderID string) (*Ge
             ORDER BY Created_At DESC
             LIMIT 1
         ) Dn ON TRUE
+        LEFT JOIN Sales_Quotation Sq ON Sq.Sales_Order_Id = So.Id
         WHERE So.Id = $1
     `, salesOrderID).Scan(
 		&response.ID,
@@ -398,6 +458,14 @@ func (r *SalesRepositoryImpl) GetSalesOrderWithDetails(salesOrderID string) (*Ge
 		&response.Status,
 		&response.PaymentMethod,
 		&response.PaymentDueDate,
+		&response.DiscountType,
+		&response.DiscountValue,
+		&response.TaxRate,
+		&response.TaxMode,
+		&response.Subtotal,
+		&response.DiscountAmount,
+		&response.TaxBase,
+		&response.TaxAmount,
 		&response.TotalAmount,
 		&response.CreatedBy,
 		&response.CreatedByName,
@@ -408,6 +476,8 @@ func (r *SalesRepositoryImpl) GetSalesOrderWithDetails(salesOrderID string) (*Ge
 		&response.SalesInvoiceSerialID,
 		&response.DeliveryNoteID,
 		&response.DeliveryNoteSerialID,
+		&response.QuotationID,
+		&response.QuotationSerialID,
 		&response.CancelledAt,
 		&response.CreatedByName,
 		&response.CancelledByName,
@@ -482,6 +552,99 @@ func (r *SalesRepositoryImpl) GetSalesOrderWithDetails(salesOrderID string) (*Ge
 		}
 	}
 	response.Items = items
+
+	// List every invoice, an order can be invoiced in several parts
+	invoiceRows, err := r.db.Query(`
+        Select Id, Serial_Id, Invoice_Date, Total_Amount, Cancelled_At
+        From Sales_Invoice
+        Where Sales_Order_Id = $1
+        Order By Invoice_Date, Serial_Id
+    `, salesOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order invoices: %w", err)
+	}
+	defer invoiceRows.Close()
+
+	response.Invoices = []SalesOrderInvoice{}
+	for invoiceRows.Next() {
+		var invoice SalesOrderInvoice
+		var invoiceDate time.Time
+		var cancelledAt sql.NullTime
+		if errScan := invoiceRows.Scan(&invoice.ID, &invoice.SerialID, &invoiceDate, &invoice.TotalAmount, &cancelledAt); errScan != nil {
+			return nil, fmt.Errorf("error scanning order invoice: %w", errScan)
+		}
+		invoice.InvoiceDate = invoiceDate.Format(time.RFC3339)
+		if cancelledAt.Valid {
+			cancelled := cancelledAt.Time.Format(time.RFC3339)
+			invoice.CancelledAt = &cancelled
+		}
+		response.Invoices = append(response.Invoices, invoice)
+	}
+	if err = invoiceRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order invoices: %w", err)
+	}
+
+	// List every delivery note, an invoice can be delivered in several parts
+	deliveryRows, err := r.db.Query(`
+        Select Id, Serial_Id, Coalesce(Sales_Invoice_Id::text, ''), Delivery_Date, Cancelled_At
+        From Delivery_Note
+        Where Sales_Order_Id = $1
+        Order By Delivery_Date, Serial_Id
+    `, salesOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order delivery notes: %w", err)
+	}
+	defer deliveryRows.Close()
+
+	response.DeliveryNotes = []SalesOrderDeliveryNote{}
+	for deliveryRows.Next() {
+		var note SalesOrderDeliveryNote
+		var deliveryDate time.Time
+		var cancelledAt sql.NullTime
+		if errScan := deliveryRows.Scan(&note.ID, &note.SerialID, &note.SalesInvoiceID, &deliveryDate, &cancelledAt); errScan != nil {
+			return nil, fmt.Errorf("error scanning order delivery note: %w", errScan)
+		}
+		note.DeliveryDate = deliveryDate.Format(time.RFC3339)
+		if cancelledAt.Valid {
+			cancelled := cancelledAt.Time.Format(time.RFC3339)
+			note.CancelledAt = &cancelled
+		}
+		response.DeliveryNotes = append(response.DeliveryNotes, note)
+	}
+	if err = deliveryRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order delivery notes: %w", err)
+	}
+
+	// List the credit overrides that let the order through
+	overrideRows, err := r.db.Query(`
+        Select Soco.Id, Soco.Order_Amount, Soco.Outstanding_Amount, Soco.Credit_Limit, Soco.Overdue_Days,
+               Soco.Max_Overdue_Days, Soco.Reason, Soco.Approved_By, Au.Username, Soco.Created_At
+        From Sales_Order_Credit_Override Soco
+        Left Join Appuser Au On Au.Id = Soco.Approved_By
+        Where Soco.Sales_Order_Id = $1
+        Order By Soco.Created_At
+    `, salesOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order credit overrides: %w", err)
+	}
+	defer overrideRows.Close()
+
+	response.CreditOverrides = []SalesOrderCreditOverride{}
+	for overrideRows.Next() {
+		var override SalesOrderCreditOverride
+		var createdAt time.Time
+		if errScan := overrideRows.Scan(&override.ID, &override.OrderAmount, &override.OutstandingAmount,
+			&override.CreditLimit, &override.OverdueDays, &override.MaxOverdueDays, &override.Reason,
+			&override.ApprovedBy, &override.ApprovedByName, &createdAt); errScan != nil {
+			return nil, fmt.Errorf("error scanning order credit override: %w", errScan)
+		}
+		override.CreatedAt = createdAt.Format(time.RFC3339)
+		response.CreditOverrides = append(response.CreditOverrides, override)
+	}
+	if err = overrideRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order credit overrides: %w", err)
+	}
+
 	return &response, nil
 }
 
@@ -533,9 +696,16 @@ func (r *SalesRepositoryImpl) GetSalesOrderItems(salesOrderID string) ([]SalesOr
                S.Id As Storage_Id, S.Name As Storage_Name,
                Sod.Quantity, Sod.Unit_Price,
                (Sod.Quantity * Sod.Unit_Price) As Total_Price,
-               Bs.Quantity + Sod.Quantity As Max_Quantity
+               Sod.List_Price, Sod.Price_Overridden,
+               Sod.Discount_Type, Sod.Discount_Value, Sod.Discount_Amount, Sod.Line_Total,
+               St.Available + Coalesce(Sr.Quantity, 0) As Max_Quantity,
+               Sr.Expires_At As Reserved_Until,
+               Sod.Invoiced_Quantity, Sod.Quantity - Sod.Invoiced_Quantity As Remaining_To_Invoice,
+               Sod.Delivered_Quantity, Sod.Quantity - Sod.Delivered_Quantity As Remaining_To_Deliver
         From Sales_Order_Detail Sod
         Join Batch_Storage Bs On Sod.Batch_Storage_Id = Bs.Id
+        Join Batch_Storage_Stock St On St.Id = Bs.Id
+        Left Join Stock_Reservation Sr On Sr.Sales_Order_Detail_Id = Sod.Id And Sr.Expires_At > Now()
         Join Product_Batch Pb On Bs.Batch_Id = Pb.Id
         Join Product P On Pb.Product_Id = P.Id
         Join Unit U On P.Unit_Id = U.Id
@@ -566,7 +736,18 @@ func (r *SalesRepositoryImpl) GetSalesOrderItems(salesOrderID string) ([]SalesOr
 			&item.Quantity,
 			&item.UnitPrice,
 			&item.TotalPrice,
+			&item.ListPrice,
+			&item.PriceOverridden,
+			&item.DiscountType,
+			&item.DiscountValue,
+			&item.DiscountAmount,
+			&item.LineTotal,
 			&item.MaxQuantity,
+			&item.ReservedUntil,
+			&item.InvoicedQuantity,
+			&item.RemainingToInvoice,
+			&item.DeliveredQuantity,
+			&item.RemainingToDeliver,
 		)
 		if errScan != nil {
 			return nil, fmt.Errorf("error scanning sales order detail row: %w", errScan)
@@ -582,6 +763,258 @@ func (r *SalesRepositoryImpl) GetSalesOrderItems(salesOrderID string) ([]SalesOr
 	return items, nil
 }
 
+// batchAllocationOrder is the order batches are taken in when allocating, oldest batch first (FIFO).
+// Batches carry no expiry date yet; once they do, ordering by it here turns this into FEFO.
+const batchAllocationOrder = "Pb.Created_At, Pb.Sku"
+
+// batchStorageAllocationColumns select a SalesOrderAllocation without its detail and price, with the available quantity
+const batchStorageAllocationColumns = `Bs.Id, St.Available, Bs.Batch_Id, Pb.Sku, Pb.Product_Id, P.Name, Bs.Storage_Id, S.Name
+	From Batch_Storage Bs
+	Join Batch_Storage_Stock St On St.Id = Bs.Id
+	Join Product_Batch Pb On Pb.Id = Bs.Batch_Id
+	Join Product P On P.Id = Pb.Product_Id
+	Join Storage S On S.Id = Bs.Storage_Id`
+
+// Reservations do not touch Batch_Storage, so its rows are locked by one statement and the available
+// quantity is read by a later one, which sees the reservations committed while waiting for the lock.
+
+// lockBatchStorage locks a batch storage so its stock can be reserved or issued
+func lockBatchStorage(tx *sql.Tx, batchStorageID string) error {
+	var id string
+	err := tx.QueryRow("Select Id From Batch_Storage Where Id = $1 For Update", batchStorageID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("batch storage dengan ID %s tidak ditemukan", batchStorageID)
+		}
+		return fmt.Errorf("gagal mengunci stok batch: %w", err)
+	}
+	return nil
+}
+
+// availableQuantity reads the stock of a batch storage not reserved by open orders, lock it first
+func availableQuantity(tx *sql.Tx, batchStorageID string) (float64, error) {
+	var available float64
+	if err := tx.QueryRow("Select Available From Batch_Storage_Stock Where Id = $1", batchStorageID).Scan(&available); err != nil {
+		return 0, fmt.Errorf("gagal memeriksa ketersediaan stok: %w", err)
+	}
+	return available, nil
+}
+
+// reserveStock holds stock of a batch storage for an order detail until the reservation expires,
+// reserving again replaces the detail's reservation and restarts its expiry
+func (r *SalesRepositoryImpl) reserveStock(tx *sql.Tx, detailID, batchStorageID string, quantity float64) error {
+	_, err := tx.Exec(`
+		Insert Into Stock_Reservation (Sales_Order_Detail_Id, Batch_Storage_Id, Quantity, Expires_At)
+		Values ($1, $2, $3, Now() + Make_Interval(Secs => $4))
+		On Conflict (Sales_Order_Detail_Id) Do Update
+		Set Batch_Storage_Id = Excluded.Batch_Storage_Id, Quantity = Excluded.Quantity,
+			Expires_At = Excluded.Expires_At, Created_At = Now()`,
+		detailID, batchStorageID, quantity, r.reservationTTL.Seconds())
+	if err != nil {
+		return fmt.Errorf("gagal memesan stok: %w", err)
+	}
+	return nil
+}
+
+// releaseDetailReservation drops the reservation of an order detail
+func releaseDetailReservation(tx *sql.Tx, detailID string) error {
+	if _, err := tx.Exec("Delete From Stock_Reservation Where Sales_Order_Detail_Id = $1", detailID); err != nil {
+		return fmt.Errorf("gagal melepas stok yang dipesan: %w", err)
+	}
+	return nil
+}
+
+// releaseOrderReservations drops every reservation of a sales order
+func releaseOrderReservations(tx *sql.Tx, salesOrderID string) error {
+	_, err := tx.Exec(`
+		Delete From Stock_Reservation
+		Where Sales_Order_Detail_Id In (Select Id From Sales_Order_Detail Where Sales_Order_Id = $1)`,
+		salesOrderID)
+	if err != nil {
+		return fmt.Errorf("gagal melepas stok yang dipesan: %w", err)
+	}
+	return nil
+}
+
+// consumeDetailReservation takes an invoiced quantity off the reservation of an order detail
+func consumeDetailReservation(tx *sql.Tx, detailID string, quantity float64) error {
+	if _, err := tx.Exec(`
+		Delete From Stock_Reservation Where Sales_Order_Detail_Id = $1 And Quantity <= $2`,
+		detailID, quantity); err != nil {
+		return fmt.Errorf("gagal melepas stok yang dipesan: %w", err)
+	}
+	if _, err := tx.Exec(`
+		Update Stock_Reservation Set Quantity = Quantity - $2 Where Sales_Order_Detail_Id = $1`,
+		detailID, quantity); err != nil {
+		return fmt.Errorf("gagal melepas stok yang dipesan: %w", err)
+	}
+	return nil
+}
+
+// pickBatchStorage takes the quantity from a batch storage picked by hand, which must be in an active storage of the branch
+func pickBatchStorage(tx *sql.Tx, batchStorageID, branchID string, quantity float64) (*SalesOrderAllocation, error) {
+	if err := lockBatchStorage(tx, batchStorageID); err != nil {
+		return nil, err
+	}
+
+	var allocation SalesOrderAllocation
+	var availableQty float64
+	err := tx.QueryRow("Select "+batchStorageAllocationColumns+" Where Bs.Id = $1 And S.Deleted_At Is Null And S.Branch_Id = $2",
+		batchStorageID, branchID).Scan(
+		&allocation.BatchStorageID, &availableQty, &allocation.BatchID, &allocation.BatchSKU,
+		&allocation.ProductID, &allocation.ProductName, &allocation.StorageID, &allocation.StorageName,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("batch storage tidak ditemukan di cabang pesanan")
+		}
+		return nil, fmt.Errorf("gagal mengambil informasi batch: %w", err)
+	}
+
+	// Verify batch has enough quantity
+	if availableQty < quantity {
+		return nil, fmt.Errorf("stok tidak cukup untuk %s: tersedia %g, diminta %g", allocation.ProductName, availableQty, quantity)
+	}
+
+	allocation.Quantity = quantity
+	return &allocation, nil
+}
+
+// allocateBatchStorages spreads the quantity of a product over its batch storages in the branch,
+// oldest batch first and the preferred storage before the others
+func allocateBatchStorages(tx *sql.Tx, productID, preferredStorageID, branchID string, quantity float64) ([]SalesOrderAllocation, error) {
+	const productStorages = `
+		From Batch_Storage Bs
+		Join Product_Batch Pb On Pb.Id = Bs.Batch_Id
+		Join Storage S On S.Id = Bs.Storage_Id
+		Where Pb.Product_Id = $1 And S.Deleted_At Is Null And S.Branch_Id = $2`
+	if _, err := tx.Exec("Select Bs.Id "+productStorages+" Order By Bs.Id For Update Of Bs", productID, branchID); err != nil {
+		return nil, fmt.Errorf("gagal mengunci stok produk: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		Select `+batchStorageAllocationColumns+`
+		Where Pb.Product_Id = $1 And St.Available > 0 And S.Deleted_At Is Null And S.Branch_Id = $2
+		Order By (Bs.Storage_Id::text = $3) Desc, `+batchAllocationOrder,
+		productID, branchID, preferredStorageID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil stok produk: %w", err)
+	}
+	defer rows.Close()
+
+	var allocations []SalesOrderAllocation
+	var productName string
+	var available float64
+	remaining := quantity
+	for rows.Next() {
+		var allocation SalesOrderAllocation
+		var batchQty float64
+		if errScan := rows.Scan(
+			&allocation.BatchStorageID, &batchQty, &allocation.BatchID, &allocation.BatchSKU,
+			&allocation.ProductID, &allocation.ProductName, &allocation.StorageID, &allocation.StorageName,
+		); errScan != nil {
+			return nil, fmt.Errorf("gagal membaca stok produk: %w", errScan)
+		}
+		productName = allocation.ProductName
+		available += batchQty
+
+		if remaining <= 0 {
+			continue
+		}
+		allocation.Quantity = math.Min(remaining, batchQty)
+		allocation.Allocated = true
+		remaining = math.Round((remaining-allocation.Quantity)*100) / 100
+		allocations = append(allocations, allocation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("gagal membaca stok produk: %w", err)
+	}
+
+	if remaining > 0 {
+		if productName == "" {
+			if errName := tx.QueryRow("Select Name From Product Where Id = $1", productID).Scan(&productName); errName != nil {
+				return nil, fmt.Errorf("produk dengan ID %s tidak ditemukan", productID)
+			}
+		}
+		return nil, fmt.Errorf("stok tidak cukup untuk %s: tersedia %g, diminta %g", productName, available, quantity)
+	}
+	return allocations, nil
+}
+
+// ExpireReservations drops up to limit reservations of open orders that passed their expiry,
+// their stock already stopped counting as reserved so this only records which orders let go of it
+func (r *SalesRepositoryImpl) ExpireReservations(limit int) (int, error) {
+	var expired int
+	err := utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query(`
+			With Expired As (
+				Delete From Stock_Reservation
+				Where Id In (
+					Select Id From Stock_Reservation
+					Where Expires_At <= Now()
+					Order By Expires_At
+					Limit $1
+					For Update Skip Locked
+				)
+				Returning Sales_Order_Detail_Id, Batch_Storage_Id, Quantity
+			)
+			Select Sod.Sales_Order_Id, So.Serial_Id, E.Sales_Order_Detail_Id, E.Batch_Storage_Id, E.Quantity
+			From Expired E
+			Join Sales_Order_Detail Sod On Sod.Id = E.Sales_Order_Detail_Id
+			Join Sales_Order So On So.Id = Sod.Sales_Order_Id
+			Order By So.Serial_Id`, limit)
+		if err != nil {
+			return fmt.Errorf("gagal menghapus pemesanan stok yang kedaluwarsa: %w", err)
+		}
+		defer rows.Close()
+
+		type releasedLine struct {
+			SalesOrderDetailID string  `json:"sales_order_detail_id"`
+			BatchStorageID     string  `json:"batch_storage_id"`
+			Quantity           float64 `json:"quantity"`
+		}
+		type releasedOrder struct {
+			SalesOrderID     string         `json:"sales_order_id"`
+			SalesOrderSerial string         `json:"sales_order_serial"`
+			Items            []releasedLine `json:"items"`
+		}
+
+		var orders []*releasedOrder
+		byOrder := make(map[string]*releasedOrder)
+		for rows.Next() {
+			var orderID, serialID string
+			var line releasedLine
+			if errScan := rows.Scan(&orderID, &serialID, &line.SalesOrderDetailID, &line.BatchStorageID, &line.Quantity); errScan != nil {
+				return fmt.Errorf("gagal membaca pemesanan stok yang kedaluwarsa: %w", errScan)
+			}
+			order, ok := byOrder[orderID]
+			if !ok {
+				order = &releasedOrder{SalesOrderID: orderID, SalesOrderSerial: serialID}
+				byOrder[orderID] = order
+				orders = append(orders, order)
+			}
+			order.Items = append(order.Items, line)
+			expired++
+		}
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("gagal membaca pemesanan stok yang kedaluwarsa: %w", err)
+		}
+		rows.Close()
+
+		// Record event in outbox, one per order
+		for _, order := range orders {
+			if err = outbox.Record(tx, event.SalesOrderReservationExpired, order); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
+
 // CreateSalesOrder creates a new sales order with its details
 func (r *SalesRepositoryImpl) CreateSalesOrder(req CreateSalesOrderRequest, userID string) (*CreateSalesOrderResponse, error) {
 	var response CreateSalesOrderResponse
@@ -593,11 +1026,62 @@ func (r *SalesRepositoryImpl) CreateSalesOrder(req CreateSalesOrderRequest, user
 		var paymentDueDate sql.NullTime
 		var status string
 
-		// Calculate total amount for the order
-		var totalAmount float64
-		for _, item := range req.Items {
-			totalAmount += item.Quantity * item.UnitPrice
+		// Orders keep the tax they were created with, the company setting gives the default
+		if err := checkDiscount(req.DiscountType, req.DiscountValue); err != nil {
+			return err
+		}
+		tax, errTax := pricing.DefaultTax(tx)
+		if errTax != nil {
+			return fmt.Errorf("gagal mengambil pengaturan pajak: %w", errTax)
 		}
+		if req.TaxRate != nil {
+			tax.Rate = *req.TaxRate
+		}
+		if req.TaxMode != "" {
+			tax.Mode = req.TaxMode
+		}
+
+		// Price the items from the customer's price list, prices typed by hand are kept and flagged when they differ
+		listPrices := make([]*float64, len(req.Items))
+		lines := make([]pricing.Line, len(req.Items))
+		for i := range req.Items {
+			item := &req.Items[i]
+			if errDiscount := checkDiscount(item.DiscountType, item.DiscountValue); errDiscount != nil {
+				return fmt.Errorf("item %d: %w", i+1, errDiscount)
+			}
+			productID := item.ProductID
+			if item.BatchStorageID != "" {
+				errProduct := tx.QueryRow(`
+					Select Pb.Product_Id
+					From Batch_Storage Bs
+					Join Product_Batch Pb On Pb.Id = Bs.Batch_Id
+					Where Bs.Id = $1`, item.BatchStorageID).Scan(&productID)
+				if errProduct != nil {
+					if errors.Is(errProduct, sql.ErrNoRows) {
+						return fmt.Errorf("batch storage tidak ditemukan")
+					}
+					return fmt.Errorf("gagal mengambil informasi batch storage: %w", errProduct)
+				}
+			}
+
+			listPrice, errPrice := customerListPrice(tx, req.CustomerID, productID, item.Quantity)
+			if errPrice != nil {
+				return errPrice
+			}
+			if item.UnitPrice <= 0 {
+				if listPrice == nil {
+					return fmt.Errorf("harga satuan item %d harus diisi, produk tidak ada di daftar harga pelanggan", i+1)
+				}
+				item.UnitPrice = *listPrice
+			}
+			listPrices[i] = listPrice
+			lines[i] = pricing.Line{
+				Quantity:  item.Quantity,
+				UnitPrice: item.UnitPrice,
+				Discount:  pricing.Discount{Type: item.DiscountType, Value: item.DiscountValue},
+			}
+		}
+		totalAmount := pricing.Calculate(lines, pricing.Discount{Type: req.DiscountType, Value: req.DiscountValue}, tax).GrandTotal
 
 		// Convert payment due date if provided
 		if req.PaymentDueDate != "" {
@@ -608,15 +1092,16 @@ func (r *SalesRepositoryImpl) CreateSalesOrder(req CreateSalesOrderRequest, user
 			paymentDueDate = sql.NullTime{Time: dueDate, Valid: true}
 		}
 
-		serialID, errSerial := utils.GenerateNextSerialID(tx, "SO")
+		serialID, errSerial := utils.GenerateNextBranchSerialID(tx, "SO", req.BranchID)
 		if errSerial != nil {
 			return fmt.Errorf("gagal membuat serial ID: %w", errSerial)
 		}
 
 		// Insert sales order
 		orderQuery := `
-		Insert Into Sales_Order (Customer_Id, Serial_Id, Payment_Method, Payment_Due_Date, Created_By, Status, Total_Amount)
-		Values ($1, $2, $3, $4, $5, 'order', $6)
+		Insert Into Sales_Order (Customer_Id, Serial_Id, Payment_Method, Payment_Due_Date, Created_By, Status, Total_Amount, Branch_Id,
+			Discount_Type, Discount_Value, Tax_Rate, Tax_Mode)
+		Values ($1, $2, $3, $4, $5, 'order', $6, $7, Nullif($8, ''), $9, $10, $11)
 		Returning Id, Serial_Id, Order_Date, Created_At, Status`
 
 		errOrder := tx.QueryRow(
@@ -627,12 +1112,42 @@ func (r *SalesRepositoryImpl) CreateSalesOrder(req CreateSalesOrderRequest, user
 			paymentDueDate,
 			userID,
 			totalAmount,
+			req.BranchID,
+			req.DiscountType,
+			req.DiscountValue,
+			tax.Rate,
+			tax.Mode,
 		).Scan(&orderID, &serialID, &orderDate, &response.CreatedAt, &status)
 
 		if errOrder != nil {
 			return fmt.Errorf("gagal membuat pesanan: %w", errOrder)
 		}
 
+		// Paylater orders are held to the credit limits of the customer
+		if req.PaymentMethod == "paylater" {
+			overridden, errCredit := checkCustomerCredit(tx, req.CustomerID, orderID, totalAmount, req.CreditOverrideRequest, userID)
+			if errCredit != nil {
+				return errCredit
+			}
+			response.CreditOverride = overridden
+		}
+
+		// Link the quotation the order was converted from, a quotation is converted once and only while still valid
+		if req.QuotationID != "" {
+			result, errQuotation := tx.Exec(`
+				Update Sales_Quotation
+				Set Status = 'accepted', Sales_Order_Id = $1, Updated_At = Now()
+				Where Id = $2 And Status In ('draft', 'sent') And Sales_Order_Id Is Null And Valid_Until >= Current_Date`,
+				orderID, req.QuotationID)
+			if errQuotation != nil {
+				return fmt.Errorf("gagal menautkan penawaran: %w", errQuotation)
+			}
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				return fmt.Errorf("penawaran sudah dikonversi atau tidak berlaku lagi")
+			}
+			response.QuotationID = req.QuotationID
+		}
+
 		// Get customer name
 		var customerName string
 		errCustomer := tx.QueryRow("Select Name From Customer Where Id = $1", req.CustomerID).Scan(&customerName)
@@ -640,81 +1155,97 @@ func (r *SalesRepositoryImpl) CreateSalesOrder(req CreateSalesOrderRequest, user
 			return fmt.Errorf("gagal mendapatkan data pelanggan: %w", errCustomer)
 		}
 
-		// Process each item
-		for _, item := range req.Items {
-			// We'll use batch_storage_id directly
-			var batchID, productID string
-			var availableQty, unitPrice float64
-			var productName, batchSKU string
-
-			// Get batch and product information from batch_storage ID
-			errBatch := tx.QueryRow(`
-				Select Bs.Quantity, Bs.Batch_Id, Pb.Product_Id, P.Name, Pb.Sku, Pb.Unit_Price
-				From Batch_Storage Bs
-				Join Product_Batch Pb On Bs.Batch_Id = Pb.Id
-				Join Product P On Pb.Product_Id = P.Id
-				Where Bs.Id = $1
-			`, item.BatchStorageID).Scan(&availableQty, &batchID, &productID, &productName, &batchSKU, &unitPrice)
-
-			if errBatch != nil {
-				if errors.Is(errBatch, sql.ErrNoRows) {
-					return fmt.Errorf("batch storage dengan ID %s tidak ditemukan", item.BatchStorageID)
+		// Process each item, items without a batch storage are allocated over the product's batches
+		for i, item := range req.Items {
+			var allocations []SalesOrderAllocation
+			if item.BatchStorageID != "" {
+				allocation, errPick := pickBatchStorage(tx, item.BatchStorageID, req.BranchID, item.Quantity)
+				if errPick != nil {
+					return errPick
+				}
+				allocations = []SalesOrderAllocation{*allocation}
+			} else {
+				var errAllocate error
+				allocations, errAllocate = allocateBatchStorages(tx, item.ProductID, item.StorageID, req.BranchID, item.Quantity)
+				if errAllocate != nil {
+					return errAllocate
 				}
-				return fmt.Errorf("gagal mengambil informasi batch: %w", errBatch)
-			}
-
-			// Verify batch has enough quantity
-			if availableQty < item.Quantity {
-				return fmt.Errorf("stok tidak cukup untuk %s: tersedia %g, diminta %g", productName, availableQty, item.Quantity)
 			}
 
-			// Insert order detail with batch_storage_id
-			var detailID string
-			errDetail := tx.QueryRow(`
-				Insert Into Sales_Order_Detail 
-				(Sales_Order_Id, Batch_Storage_Id, Quantity, Unit_Price) 
-				Values ($1, $2, $3, $4) 
-				Returning Id`,
-				orderID, item.BatchStorageID, item.Quantity, item.UnitPrice).Scan(&detailID)
+			// A discount amount is split over the batches by quantity, the last batch takes what is left
+			remainingDiscount := item.DiscountValue
+			for a, allocation := range allocations {
+				allocation.UnitPrice = item.UnitPrice
+				allocation.ListPrice = listPrices[i]
+				allocation.PriceOverridden = priceOverridden(item.UnitPrice, listPrices[i])
+				allocation.DiscountType = item.DiscountType
+				allocation.DiscountValue = item.DiscountValue
+				if item.DiscountType == pricing.DiscountAmount {
+					allocation.DiscountValue = pricing.Round(item.DiscountValue * allocation.Quantity / item.Quantity)
+					if a == len(allocations)-1 {
+						allocation.DiscountValue = pricing.Round(remainingDiscount)
+					}
+					remainingDiscount -= allocation.DiscountValue
+				}
 
-			if errDetail != nil {
-				return fmt.Errorf("gagal menambahkan detail pesanan: %w", errDetail)
-			}
+				// Insert order detail with batch_storage_id
+				errDetail := tx.QueryRow(`
+					Insert Into Sales_Order_Detail 
+					(Sales_Order_Id, Batch_Storage_Id, Quantity, Unit_Price, List_Price, Price_Overridden, Discount_Type, Discount_Value) 
+					Values ($1, $2, $3, $4, $5, $6, Nullif($7, ''), $8) 
+					Returning Id`,
+					orderID, allocation.BatchStorageID, allocation.Quantity, allocation.UnitPrice, allocation.ListPrice,
+					allocation.PriceOverridden, allocation.DiscountType, allocation.DiscountValue).Scan(&allocation.SalesDetailID)
+
+				if errDetail != nil {
+					return fmt.Errorf("gagal menambahkan detail pesanan: %w", errDetail)
+				}
 
-			// Update batch_storage quantity
-			_, errBatchStorage := tx.Exec(`
-					Update Batch_Storage 
-					Set Quantity = Quantity - $1 
-					Where Id = $2
-				`, item.Quantity, item.BatchStorageID)
+				// Hold the stock until the order is invoiced, cancelled or its reservation expires
+				if errReserve := r.reserveStock(tx, allocation.SalesDetailID, allocation.BatchStorageID, allocation.Quantity); errReserve != nil {
+					return errReserve
+				}
 
-			if errBatchStorage != nil {
-				return fmt.Errorf("gagal memperbarui kuantitas batch storage: %w", errBatchStorage)
+				response.Allocations = append(response.Allocations, allocation)
 			}
+		}
 
-			// Update product_batch current_quantity
-			_, errBatchQuantity := tx.Exec(`
-					Update Product_Batch 
-					Set Current_Quantity = Current_Quantity - $1 
-					Where Id = $2
-				`, item.Quantity, batchID)
+		// Price the stored lines, the order discount and tax are spread over them
+		orderPricing, errPricing := recalculateSalesOrder(tx, orderID)
+		if errPricing != nil {
+			return errPricing
+		}
+		for a := range response.Allocations {
+			line := orderPricing.line(response.Allocations[a].SalesDetailID)
+			response.Allocations[a].DiscountAmount = line.DiscountAmount
+			response.Allocations[a].LineTotal = line.Total
+		}
 
-			if errBatchQuantity != nil {
-				return fmt.Errorf("gagal memperbarui kuantitas batch: %w", errBatchQuantity)
+		// Record the cash handed over, kept on the order until its total changes
+		changeAmount, errChange := cashChange(req.PaymentMethod, req.AmountTendered, orderPricing.Totals.GrandTotal)
+		if errChange != nil {
+			return errChange
+		}
+		if changeAmount != nil {
+			if _, errTender := tx.Exec("Update Sales_Order Set Amount_Tendered = $1, Change_Amount = $2 Where Id = $3",
+				*req.AmountTendered, *changeAmount, orderID); errTender != nil {
+				return fmt.Errorf("gagal menyimpan jumlah bayar: %w", errTender)
 			}
+			response.AmountTendered = req.AmountTendered
+			response.ChangeAmount = changeAmount
+		}
 
-			// If req.CreateInvoice is true, create a invoice
-			if req.CreateInvoice {
-				// use CreateSalesInvoice function to create invoice
-				invoiceReq := CreateSalesInvoiceRequest{
-					SalesOrderID: orderID,
-				}
-
-				_, errCreateInvoice := r.CreateSalesInvoice(invoiceReq, userID, tx)
-				if errCreateInvoice != nil {
-					return fmt.Errorf("gagal membuat faktur penjualan: %w", errCreateInvoice)
-				}
+		// If req.CreateInvoice is true, invoice the order once all lines are in
+		if req.CreateInvoice {
+			invoice, errCreateInvoice := r.CreateSalesInvoice(CreateSalesInvoiceRequest{
+				SalesOrderID:   orderID,
+				AmountTendered: req.AmountTendered,
+			}, userID, tx)
+			if errCreateInvoice != nil {
+				return fmt.Errorf("gagal membuat faktur penjualan: %w", errCreateInvoice)
 			}
+			response.InvoiceID = invoice.ID
+			response.InvoiceSerialID = invoice.SerialID
 		}
 
 		// Set response data
@@ -727,8 +1258,18 @@ func (r *SalesRepositoryImpl) CreateSalesOrder(req CreateSalesOrderRequest, user
 		if paymentDueDate.Valid {
 			response.PaymentDueDate = paymentDueDate.Time.Format(time.RFC3339)
 		}
-		response.TotalAmount = totalAmount
-		return nil
+		response.DiscountType = req.DiscountType
+		response.DiscountValue = req.DiscountValue
+		response.TaxRate = tax.Rate
+		response.TaxMode = tax.Mode
+		response.Subtotal = orderPricing.Totals.Subtotal
+		response.DiscountAmount = orderPricing.Totals.DiscountAmount
+		response.TaxBase = orderPricing.Totals.TaxBase
+		response.TaxAmount = orderPricing.Totals.TaxAmount
+		response.TotalAmount = orderPricing.Totals.GrandTotal
+
+		// Record event in outbox
+		return outbox.Record(tx, event.SalesOrderCreated, response)
 	})
 
 	if err != nil {
@@ -781,14 +1322,70 @@ func (r *SalesRepositoryImpl) UpdateSalesOrder(req UpdateSalesOrderRequest) (*Up
 		paramCount++
 	}
 
+	// A discount value of 0 removes the order discount
+	if req.DiscountType != nil || req.DiscountValue != nil {
+		var discountType string
+		var discountValue float64
+		if errDiscount := r.db.QueryRow("Select Coalesce(Discount_Type, ''), Discount_Value From Sales_Order Where Id = $1",
+			req.ID).Scan(&discountType, &discountValue); errDiscount != nil {
+			return nil, fmt.Errorf("gagal memeriksa diskon pesanan: %w", errDiscount)
+		}
+		if req.DiscountType != nil {
+			discountType = *req.DiscountType
+		}
+		if req.DiscountValue != nil {
+			discountValue = *req.DiscountValue
+		}
+		if discountValue == 0 {
+			discountType = ""
+		}
+		if errDiscount := checkDiscount(discountType, discountValue); errDiscount != nil {
+			return nil, errDiscount
+		}
+		setValues = append(setValues, fmt.Sprintf("discount_type = Nullif($%d, '')", paramCount), fmt.Sprintf("discount_value = $%d", paramCount+1))
+		params = append(params, discountType, discountValue)
+		paramCount += 2
+	}
+
+	if req.TaxRate != nil {
+		setValues = append(setValues, fmt.Sprintf("tax_rate = $%d", paramCount))
+		params = append(params, *req.TaxRate)
+		paramCount++
+	}
+
+	if req.TaxMode != "" {
+		setValues = append(setValues, fmt.Sprintf("tax_mode = $%d", paramCount))
+		params = append(params, req.TaxMode)
+		paramCount++
+	}
+
 	// Construct final query
-	query := "Update Sales_Order Set " + strings.Join(setValues, ", ") + " WHERE id = $" + strconv.Itoa(paramCount) + " RETURNING id, serial_id, customer_id, status, payment_method, payment_due_date"
+	query := "Update Sales_Order Set " + strings.Join(setValues, ", ") + " WHERE id = $" + strconv.Itoa(paramCount) +
+		" RETURNING id, serial_id, customer_id, status, payment_method, payment_due_date, discount_type, discount_value, tax_rate, tax_mode"
 	params = append(params, req.ID)
 
-	errUpdate := r.db.QueryRow(query, params...).Scan(&response.ID, &response.SerialID, &response.CustomerID,
-		&response.Status, &response.PaymentMethod, &response.PaymentDueDate)
-	if errUpdate != nil {
-		return nil, fmt.Errorf("gagal memperbarui pesanan: %w", errUpdate)
+	err := utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		errUpdate := tx.QueryRow(query, params...).Scan(&response.ID, &response.SerialID, &response.CustomerID,
+			&response.Status, &response.PaymentMethod, &response.PaymentDueDate, &response.DiscountType,
+			&response.DiscountValue, &response.TaxRate, &response.TaxMode)
+		if errUpdate != nil {
+			return fmt.Errorf("gagal memperbarui pesanan: %w", errUpdate)
+		}
+
+		// The order discount and tax change the totals of every line
+		orderPricing, errPricing := recalculateSalesOrder(tx, req.ID)
+		if errPricing != nil {
+			return errPricing
+		}
+		response.Subtotal = orderPricing.Totals.Subtotal
+		response.DiscountAmount = orderPricing.Totals.DiscountAmount
+		response.TaxBase = orderPricing.Totals.TaxBase
+		response.TaxAmount = orderPricing.Totals.TaxAmount
+		response.TotalAmount = orderPricing.Totals.GrandTotal
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &response, nil
@@ -825,67 +1422,21 @@ func (r *SalesRepositoryImpl) CancelSalesOrder(req CancelSalesOrderRequest, user
 			return fmt.Errorf("gagal membatalkan pesanan: %w", errCancel)
 		}
 
-		// Get all order details to restore inventory
-		rows, errDetails := tx.Query(`
-            Select Sod.Id, Sod.Batch_Id, Sod.Batch_Storage_Id, Sod.Quantity 
-            From Sales_Order_Detail Sod
-            Where Sod.Sales_Order_Id = $1
-        `, req.SalesOrderID)
-
-		if errDetails != nil {
-			return fmt.Errorf("gagal mengambil detail pesanan: %w", errDetails)
-		}
-		defer rows.Close()
-
-		// Collect all details first before processing them
-		type orderDetail struct {
-			detailID       string
-			batchID        string
-			batchStorageID string
-			quantity       float64
-		}
-
-		var details []orderDetail
-		for rows.Next() {
-			var detail orderDetail
-			if err := rows.Scan(&detail.detailID, &detail.batchID, &detail.batchStorageID, &detail.quantity); err != nil {
-				return fmt.Errorf("gagal membaca detail pesanan: %w", err)
-			}
-			details = append(details, detail)
-		}
-
-		if err := rows.Err(); err != nil {
-			return fmt.Errorf("terjadi kesalahan saat memproses detail pesanan: %w", err)
-		}
-
-		// Now process each detail
-		for _, detail := range details {
-			// Restore inventory in product_batch
-			_, errRestore := tx.Exec(
-				"Update Product_Batch Set Current_Quantity = Current_Quantity + $1 Where Id = $2",
-				detail.quantity, detail.batchID,
-			)
-			if errRestore != nil {
-				return fmt.Errorf("gagal mengembalikan stok produk: %w", errRestore)
-			}
-
-			// Restore inventory in batch_storage directly using batch_storage_id
-			_, errBatchStorage := tx.Exec(`
-                Update Batch_Storage 
-                Set Quantity = Quantity + $1 
-                Where Id = $2
-            `, detail.quantity, detail.batchStorageID)
-			if errBatchStorage != nil {
-				return fmt.Errorf("gagal mengembalikan stok di lokasi penyimpanan: %w", errBatchStorage)
-			}
+		// The stock was only reserved, releasing it makes it available again
+		if err := releaseOrderReservations(tx, req.SalesOrderID); err != nil {
+			return err
 		}
 
-		return nil
+		// Record event in outbox
+		return outbox.Record(tx, event.SalesOrderCancelled, map[string]string{
+			"sales_order_id": req.SalesOrderID,
+			"cancelled_by":   userID,
+		})
 	})
 }
 
 // AddItemToSalesOrder adds a new item to an existing sales order
-func (r *SalesRepositoryImpl) AddItemToSalesOrder(req AddSalesOrderItemRequest) (*UpdateAndCreateItemResponse, error) {
+func (r *SalesRepositoryImpl) AddItemToSalesOrder(req AddSalesOrderItemRequest, userID string) (*UpdateAndCreateItemResponse, error) {
 	var response UpdateAndCreateItemResponse
 	var status string
 
@@ -916,90 +1467,115 @@ func (r *SalesRepositoryImpl) AddItemToSalesOrder(req AddSalesOrderItemRequest)
 	// Get batch_storage information including product and batch details
 	var batchStorageID, batchID, productID, productName, batchSKU string
 	var storageID string
-	var storageQty, unitPrice float64
+	var unitPrice float64
 
 	errBatchStorage := r.db.QueryRow(`
-        Select Bs.Id, Bs.Batch_Id, Pb.Product_Id, P.Name, Pb.Sku, Bs.Storage_Id, Bs.Quantity, Pb.Unit_Price
+        Select Bs.Id, Bs.Batch_Id, Pb.Product_Id, P.Name, Pb.Sku, Bs.Storage_Id, Pb.Unit_Price
         From Batch_Storage Bs
         Join Product_Batch Pb On Bs.Batch_Id = Pb.Id
         Join Product P On Pb.Product_Id = P.Id
-        Where Bs.Id = $1
-    `, req.BatchStorageID).Scan(
+        Join Storage S On S.Id = Bs.Storage_Id
+        Where Bs.Id = $1 And S.Deleted_At Is Null
+          And S.Branch_Id = (Select So.Branch_Id From Sales_Order So Where So.Id = $2)
+    `, req.BatchStorageID, req.SalesOrderID).Scan(
 		&batchStorageID,
 		&batchID,
 		&productID,
 		&productName,
 		&batchSKU,
 		&storageID,
-		&storageQty,
 		&unitPrice,
 	)
 
 	if errBatchStorage != nil {
 		if errors.Is(errBatchStorage, sql.ErrNoRows) {
-			return nil, fmt.Errorf("batch storage tidak ditemukan")
+			return nil, fmt.Errorf("batch storage tidak ditemukan di cabang pesanan")
 		}
 		return nil, fmt.Errorf("gagal mengambil informasi batch storage: %w", errBatchStorage)
 	}
 
-	// Check if quantity requested is available
-	if storageQty < req.Quantity {
-		return nil, fmt.Errorf("jumlah yang diminta (%g) melebihi stok yang tersedia (%g)", req.Quantity, storageQty)
-	}
-
-	// Use provided unit price instead of batch price if specified
-	if req.UnitPrice <= 0 {
-		req.UnitPrice = unitPrice
-	}
-
 	// Execute transaction
 	err := utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		// Check if quantity requested is available
+		if err := lockBatchStorage(tx, batchStorageID); err != nil {
+			return err
+		}
+		availableQty, err := availableQuantity(tx, batchStorageID)
+		if err != nil {
+			return err
+		}
+		if availableQty < req.Quantity {
+			return fmt.Errorf("jumlah yang diminta (%g) melebihi stok yang tersedia (%g)", req.Quantity, availableQty)
+		}
+
+		// Items added to paylater orders are held to the credit limits of the customer
+		var customerID, paymentMethod string
+		if err = tx.QueryRow("Select Customer_Id, Payment_Method From Sales_Order Where Id = $1",
+			req.SalesOrderID).Scan(&customerID, &paymentMethod); err != nil {
+			return fmt.Errorf("gagal memeriksa pesanan: %w", err)
+		}
+
+		// Without a price the item takes the customer's list price, or else the batch price
+		listPrice, err := customerListPrice(tx, customerID, productID, req.Quantity)
+		if err != nil {
+			return err
+		}
+		if req.UnitPrice <= 0 {
+			req.UnitPrice = unitPrice
+			if listPrice != nil {
+				req.UnitPrice = *listPrice
+			}
+		}
+		overridden := priceOverridden(req.UnitPrice, listPrice)
+		if err = checkDiscount(req.DiscountType, req.DiscountValue); err != nil {
+			return err
+		}
+
+		if paymentMethod == "paylater" {
+			// The item brings in what the grand total grows by, the order discount and tax included
+			current, errPricing := loadSalesOrderPricing(tx, req.SalesOrderID)
+			if errPricing != nil {
+				return errPricing
+			}
+			added := pricing.Calculate(append(current.Lines, pricing.Line{
+				Quantity:  req.Quantity,
+				UnitPrice: req.UnitPrice,
+				Discount:  pricing.Discount{Type: req.DiscountType, Value: req.DiscountValue},
+			}), current.Discount, current.Tax)
+
+			overridden, errCredit := checkCustomerCredit(tx, customerID, req.SalesOrderID, added.GrandTotal,
+				req.CreditOverrideRequest, userID)
+			if errCredit != nil {
+				return errCredit
+			}
+			response.CreditOverride = overridden
+		}
+
 		// Create a new sales order detail entry with batch_storage_id
 		var detailID string
 		errDetail := tx.QueryRow(`
             Insert Into Sales_Order_Detail 
-            (Sales_Order_Id, Batch_Storage_Id, Quantity, Unit_Price) 
-            Values ($1, $2, $3, $4) 
+            (Sales_Order_Id, Batch_Storage_Id, Quantity, Unit_Price, List_Price, Price_Overridden, Discount_Type, Discount_Value) 
+            Values ($1, $2, $3, $4, $5, $6, Nullif($7, ''), $8) 
             Returning Id`,
-			req.SalesOrderID, batchStorageID, req.Quantity, req.UnitPrice,
+			req.SalesOrderID, batchStorageID, req.Quantity, req.UnitPrice, listPrice, overridden,
+			req.DiscountType, req.DiscountValue,
 		).Scan(&detailID)
 		if errDetail != nil {
 			return fmt.Errorf("gagal menambahkan item ke pesanan: %w", errDetail)
 		}
 
-		// Update product_batch quantity
-		_, errBatchUpdate := tx.Exec(
-			"Update Product_Batch Set Current_Quantity = Current_Quantity - $1 Where Id = $2",
-			req.Quantity, batchID,
-		)
-		if errBatchUpdate != nil {
-			return fmt.Errorf("gagal memperbarui stok batch: %w", errBatchUpdate)
+		// Hold the stock until the order is invoiced
+		if err := r.reserveStock(tx, detailID, batchStorageID, req.Quantity); err != nil {
+			return err
 		}
 
-		// Update batch_storage quantity
-		_, errBatchStorageUpdate := tx.Exec(
-			"Update Batch_Storage Set Quantity = Quantity - $1 Where Id = $2",
-			req.Quantity, batchStorageID,
-		)
-		if errBatchStorageUpdate != nil {
-			return fmt.Errorf("gagal memperbarui stok di lokasi penyimpanan: %w", errBatchStorageUpdate)
-		}
-
-		// Update the order's total_amount
-		_, errTotal := tx.Exec(`
-            Update Sales_Order 
-            Set Total_Amount = (
-                Select Coalesce(Sum(Quantity * Unit_Price), 0) 
-                From Sales_Order_Detail 
-                Where Sales_Order_Id = $1
-            ),
-            Updated_At = Now()
-            Where Id = $1`,
-			req.SalesOrderID,
-		)
-		if errTotal != nil {
-			return fmt.Errorf("gagal memperbarui total harga pesanan: %w", errTotal)
+		// Price the order again, the order discount and tax are spread over the new line too
+		orderPricing, err := recalculateSalesOrder(tx, req.SalesOrderID)
+		if err != nil {
+			return err
 		}
+		line := orderPricing.line(detailID)
 
 		// Set response values
 		response.DetailID = detailID
@@ -1011,6 +1587,13 @@ func (r *SalesRepositoryImpl) AddItemToSalesOrder(req AddSalesOrderItemRequest)
 		response.Quantity = req.Quantity
 		response.UnitPrice = req.UnitPrice
 		response.TotalPrice = req.Quantity * req.UnitPrice
+		response.ListPrice = listPrice
+		response.PriceOverridden = overridden
+		response.DiscountType = req.DiscountType
+		response.DiscountValue = req.DiscountValue
+		response.DiscountAmount = line.DiscountAmount
+		response.LineTotal = line.Total
+		response.OrderTotal = orderPricing.Totals.GrandTotal
 
 		return nil
 	})
@@ -1022,7 +1605,7 @@ func (r *SalesRepositoryImpl) AddItemToSalesOrder(req AddSalesOrderItemRequest)
 	return &response, nil
 }
 
-// DeleteSalesOrderItem deletes an item from a sales order and restores inventory
+// DeleteSalesOrderItem deletes an item from a sales order and releases its reserved stock
 func (r *SalesRepositoryImpl) DeleteSalesOrderItem(req DeleteSalesOrderItemRequest) error {
 	// Check if sales order exists and is in editable state
 	var status string
@@ -1040,17 +1623,13 @@ func (r *SalesRepositoryImpl) DeleteSalesOrderItem(req DeleteSalesOrderItemReque
 	}
 
 	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
-		// Get details for the item to be deleted including batch_storage_id
-		var batchID string
-		var batchStorageID string
-		var quantity, unitPrice float64
+		// Check the item to be deleted belongs to the order
+		var detailID string
 		queryErr := tx.QueryRow(`
-            Select 
-                Bs.Batch_Id, Sod.Batch_Storage_Id, Sod.Quantity, Sod.Unit_Price
+            Select Sod.Id
             From Sales_Order_Detail Sod
-            Join Batch_Storage Bs On Sod.Batch_Storage_Id = Bs.Id
             Where Sod.Id = $1 And Sod.Sales_Order_Id = $2
-        `, req.DetailID, req.SalesOrderID).Scan(&batchID, &batchStorageID, &quantity, &unitPrice)
+        `, req.DetailID, req.SalesOrderID).Scan(&detailID)
 
 		if queryErr != nil {
 			if errors.Is(queryErr, sql.ErrNoRows) {
@@ -1059,26 +1638,7 @@ func (r *SalesRepositoryImpl) DeleteSalesOrderItem(req DeleteSalesOrderItemReque
 			return fmt.Errorf("gagal mengambil detail item: %w", queryErr)
 		}
 
-		// Update batch_storage to restore quantity directly using batch_storage_id
-		_, updateErr := tx.Exec(`
-            Update Batch_Storage
-            Set Quantity = Quantity + $1
-            Where Id = $2
-        `, quantity, batchStorageID)
-		if updateErr != nil {
-			return fmt.Errorf("gagal memulihkan kuantitas di penyimpanan: %w", updateErr)
-		}
-
-		// Update product_batch to restore total quantity
-		_, updateErr = tx.Exec(`
-            Update Product_Batch
-            Set Current_Quantity = Current_Quantity + $1
-            Where Id = $2
-        `, quantity, batchID)
-		if updateErr != nil {
-			return fmt.Errorf("gagal memulihkan kuantitas batch: %w", updateErr)
-		}
-
+		// Deleting the detail also drops its stock reservation
 		// Delete the order detail
 		_, deleteDetailErr := tx.Exec(`
             Delete From Sales_Order_Detail
@@ -1088,30 +1648,25 @@ func (r *SalesRepositoryImpl) DeleteSalesOrderItem(req DeleteSalesOrderItemReque
 			return fmt.Errorf("gagal menghapus item pesanan: %w", deleteDetailErr)
 		}
 
-		// Update total order amount
-		_, updateOrderErr := tx.Exec(`
-            Update Sales_Order
-            Set Total_Amount = Total_Amount - $1,
-                Updated_At = Current_Timestamp
-            Where Id = $2
-        `, quantity*unitPrice, req.SalesOrderID)
-		if updateOrderErr != nil {
-			return fmt.Errorf("gagal memperbarui total pesanan: %w", updateOrderErr)
-		}
-
-		return nil
+		// Price the order again, an amount order discount is now spread over fewer lines
+		_, updateOrderErr := recalculateSalesOrder(tx, req.SalesOrderID)
+		return updateOrderErr
 	})
 }
 
 // UpdateSalesOrderItem updates an item in a sales order with new quantity or price
-func (r *SalesRepositoryImpl) UpdateSalesOrderItem(req UpdateSalesOrderItemRequest) (*UpdateAndCreateItemResponse, error) {
+func (r *SalesRepositoryImpl) UpdateSalesOrderItem(req UpdateSalesOrderItemRequest, userID string) (*UpdateAndCreateItemResponse, error) {
 	var response UpdateAndCreateItemResponse
-	var status string
+	var status, customerID string
 	var currentQty, currentPrice float64
+	var currentListPrice sql.NullFloat64
+	var currentOverridden bool
+	var currentDiscountType string
+	var currentDiscountValue float64
 	var batchStorageID string
 
 	// Check if sales order exists and if it's in a modifiable state
-	errCheck := r.db.QueryRow("Select Status From Sales_Order Where Id = $1", req.SalesOrderID).Scan(&status)
+	errCheck := r.db.QueryRow("Select Status, Customer_Id From Sales_Order Where Id = $1", req.SalesOrderID).Scan(&status, &customerID)
 	if errCheck != nil {
 		if errors.Is(errCheck, sql.ErrNoRows) {
 			return nil, fmt.Errorf("pesanan tidak ditemukan")
@@ -1126,13 +1681,18 @@ func (r *SalesRepositoryImpl) UpdateSalesOrderItem(req UpdateSalesOrderItemReque
 
 	// Get current detail information including batch_storage_id
 	errDetail := r.db.QueryRow(`
-		Select Sod.Quantity, Sod.Unit_Price, Sod.Batch_Storage_Id 
+		Select Sod.Quantity, Sod.Unit_Price, Sod.Batch_Storage_Id, Sod.List_Price, Sod.Price_Overridden,
+			Coalesce(Sod.Discount_Type, ''), Sod.Discount_Value
 		From Sales_Order_Detail Sod
 		Where Sod.Id = $1 And Sod.Sales_Order_Id = $2
 	`, req.DetailID, req.SalesOrderID).Scan(
 		&currentQty,
 		&currentPrice,
 		&batchStorageID,
+		&currentListPrice,
+		&currentOverridden,
+		&currentDiscountType,
+		&currentDiscountValue,
 	)
 
 	if errDetail != nil {
@@ -1174,12 +1734,14 @@ func (r *SalesRepositoryImpl) UpdateSalesOrderItem(req UpdateSalesOrderItemReque
 			Select Bs.Batch_Id, Pb.Product_Id, Bs.Storage_Id
 			From Batch_Storage Bs
 			Join Product_Batch Pb On Bs.Batch_Id = Pb.Id
-			Where Bs.Id = $1
-		`, req.BatchStorageID).Scan(&newBatchID, &newProductID, &newStorageID)
+			Join Storage S On S.Id = Bs.Storage_Id
+			Where Bs.Id = $1 And S.Deleted_At Is Null
+			  And S.Branch_Id = (Select So.Branch_Id From Sales_Order So Where So.Id = $2)
+		`, req.BatchStorageID, req.SalesOrderID).Scan(&newBatchID, &newProductID, &newStorageID)
 
 		if errBatchStorage != nil {
 			if errors.Is(errBatchStorage, sql.ErrNoRows) {
-				return nil, fmt.Errorf("lokasi batch baru tidak ditemukan")
+				return nil, fmt.Errorf("lokasi batch baru tidak ditemukan di cabang pesanan")
 			}
 			return nil, fmt.Errorf("gagal mendapatkan informasi lokasi batch baru: %w", errBatchStorage)
 		}
@@ -1202,177 +1764,133 @@ func (r *SalesRepositoryImpl) UpdateSalesOrderItem(req UpdateSalesOrderItemReque
 		newPrice = req.UnitPrice
 	}
 
+	// Lines priced from the price list follow its quantity breaks, prices typed by hand are kept
+	listPrice, errPrice := customerListPrice(r.db, customerID, productID, newQty)
+	if errPrice != nil {
+		return nil, errPrice
+	}
+	if req.UnitPrice <= 0 && currentListPrice.Valid && !currentOverridden && listPrice != nil {
+		newPrice = *listPrice
+	}
+	overridden := priceOverridden(newPrice, listPrice)
+
+	// The discount is kept unless given, a value of 0 removes it
+	newDiscountType := currentDiscountType
+	newDiscountValue := currentDiscountValue
+	if req.DiscountType != nil {
+		newDiscountType = *req.DiscountType
+	}
+	if req.DiscountValue != nil {
+		newDiscountValue = *req.DiscountValue
+	}
+	if newDiscountValue == 0 {
+		newDiscountType = ""
+	}
+	if errDiscount := checkDiscount(newDiscountType, newDiscountValue); errDiscount != nil {
+		return nil, errDiscount
+	}
+
+	// Every change prices the whole order again, the order discount and tax are spread over all lines
+	var orderPricing *salesOrderPricing
+
+	// A paylater order growing past its previous total is held to the credit limits of the customer again
+	checkCredit := func(tx *sql.Tx) error {
+		var paymentMethod string
+		var previousTotal float64
+		if err := tx.QueryRow("Select Payment_Method, Total_Amount From Sales_Order Where Id = $1 For Update",
+			req.SalesOrderID).Scan(&paymentMethod, &previousTotal); err != nil {
+			return fmt.Errorf("gagal memeriksa pesanan: %w", err)
+		}
+
+		var errTotal error
+		orderPricing, errTotal = recalculateSalesOrder(tx, req.SalesOrderID)
+		if errTotal != nil {
+			return errTotal
+		}
+		if paymentMethod != "paylater" || orderPricing.Totals.GrandTotal <= previousTotal {
+			return nil
+		}
+
+		overridden, errCredit := checkCustomerCredit(tx, customerID, req.SalesOrderID,
+			orderPricing.Totals.GrandTotal, req.CreditOverrideRequest, userID)
+		if errCredit != nil {
+			return errCredit
+		}
+		response.CreditOverride = overridden
+		return nil
+	}
+
 	// If quantity is unchanged and only price is updated, and no storage change, simple update
 	if newQty == currentQty && !isChangingStorage {
 		err := utils.WithTransaction(r.db, func(tx *sql.Tx) error {
-			// Update the sales order detail with new price
+			// Update the sales order detail with new price and discount
 			_, errUpdate := tx.Exec(`
 				Update Sales_Order_Detail 
-				Set Unit_Price = $1, Updated_At = Now()
-				Where Id = $2
-			`, newPrice, req.DetailID)
+				Set Unit_Price = $1, List_Price = $2, Price_Overridden = $3, Discount_Type = Nullif($4, ''),
+					Discount_Value = $5, Updated_At = Now()
+				Where Id = $6
+			`, newPrice, listPrice, overridden, newDiscountType, newDiscountValue, req.DetailID)
 			if errUpdate != nil {
 				return fmt.Errorf("gagal memperbarui harga item: %w", errUpdate)
 			}
 
-			// Update the order's total_amount
-			_, errTotal := tx.Exec(`
-				Update Sales_Order Set Total_Amount = (
-					Select Coalesce(Sum(Quantity * Unit_Price), 0)
-					From Sales_Order_Detail
-					Where Sales_Order_Id = $1
-				), Updated_At = Now()
-				Where Id = $1
-			`, req.SalesOrderID)
-			if errTotal != nil {
-				return fmt.Errorf("gagal memperbarui total harga pesanan: %w", errTotal)
-			}
-
-			return nil
+			return checkCredit(tx)
 		})
 
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		// Calculate quantity difference
-		qtyDifference := newQty - currentQty
-
 		// Execute complex update transaction
 		err := utils.WithTransaction(r.db, func(tx *sql.Tx) error {
-			// If we're changing storage location
+			targetBatchStorageID := batchStorageID
 			if isChangingStorage {
-				// Return quantity to original batch and storage
-				_, errRestore := tx.Exec(`
-					Update Product_Batch 
-					Set Current_Quantity = Current_Quantity + $1 
-					Where Id = $2
-				`, currentQty, batchID)
-				if errRestore != nil {
-					return fmt.Errorf("gagal mengembalikan stok batch lama: %w", errRestore)
-				}
-
-				_, errRestoreStorage := tx.Exec(`
-					Update Batch_Storage 
-					Set Quantity = Quantity + $1 
-					Where Id = $2
-				`, currentQty, batchStorageID)
-				if errRestoreStorage != nil {
-					return fmt.Errorf("gagal mengembalikan stok di lokasi penyimpanan lama: %w", errRestoreStorage)
-				}
-
-				// Validate new batch storage has enough quantity
-				var availableQty float64
-				errAvail := tx.QueryRow(`
-					Select Quantity From Batch_Storage Where Id = $1
-				`, req.BatchStorageID).Scan(&availableQty)
-				if errAvail != nil {
-					return fmt.Errorf("gagal memeriksa ketersediaan stok di lokasi baru: %w", errAvail)
-				}
+				targetBatchStorageID = req.BatchStorageID
+			}
 
-				if availableQty < newQty {
+			// The detail's own reservation does not count against it, release it and reserve again
+			if err := lockBatchStorage(tx, targetBatchStorageID); err != nil {
+				return err
+			}
+			if err := releaseDetailReservation(tx, req.DetailID); err != nil {
+				return err
+			}
+			availableQty, err := availableQuantity(tx, targetBatchStorageID)
+			if err != nil {
+				return err
+			}
+			if availableQty < newQty {
+				if isChangingStorage {
 					return fmt.Errorf("stok tidak cukup di lokasi baru: tersedia %g, diminta %g", availableQty, newQty)
 				}
+				return fmt.Errorf("stok tidak mencukupi, tersedia: %g, diminta: %g", availableQty, newQty)
+			}
 
-				// Take quantity from new batch and storage
-				_, errDeduct := tx.Exec(`
-					Update Product_Batch 
-					Set Current_Quantity = Current_Quantity - $1 
-					Where Id = $2
-				`, newQty, newBatchID)
-				if errDeduct != nil {
-					return fmt.Errorf("gagal mengurangi stok batch baru: %w", errDeduct)
-				}
-
-				_, errDeductStorage := tx.Exec(`
-					Update Batch_Storage 
-					Set Quantity = Quantity - $1 
-					Where Id = $2
-				`, newQty, req.BatchStorageID)
-				if errDeductStorage != nil {
-					return fmt.Errorf("gagal mengurangi stok di lokasi penyimpanan baru: %w", errDeductStorage)
-				}
+			// Update sales order detail with the batch storage, quantity, price and discount
+			_, errUpdateDetail := tx.Exec(`
+				Update Sales_Order_Detail 
+				Set Batch_Storage_Id = $1, Quantity = $2, Unit_Price = $3, List_Price = $4, Price_Overridden = $5,
+					Discount_Type = Nullif($6, ''), Discount_Value = $7, Updated_At = Now()
+				Where Id = $8
+			`, targetBatchStorageID, newQty, newPrice, listPrice, overridden, newDiscountType, newDiscountValue, req.DetailID)
+			if errUpdateDetail != nil {
+				return fmt.Errorf("gagal memperbarui detail pesanan: %w", errUpdateDetail)
+			}
 
-				// Update sales order detail with new batch storage
-				_, errUpdateDetail := tx.Exec(`
-					Update Sales_Order_Detail 
-					Set Batch_Storage_Id = $1, Quantity = $2, Unit_Price = $3, Updated_At = Now()
-					Where Id = $4
-				`, req.BatchStorageID, newQty, newPrice, req.DetailID)
-				if errUpdateDetail != nil {
-					return fmt.Errorf("gagal memperbarui detail pesanan: %w", errUpdateDetail)
-				}
+			if err = r.reserveStock(tx, req.DetailID, targetBatchStorageID, newQty); err != nil {
+				return err
+			}
 
+			if isChangingStorage {
 				// Update response with new batch info
 				batchID = newBatchID
 				// Get updated batch SKU
 				if err := tx.QueryRow("Select Sku From Product_Batch Where Id = $1", newBatchID).Scan(&batchSKU); err != nil {
 					return fmt.Errorf("gagal mendapatkan informasi SKU batch baru: %w", err)
 				}
-			} else {
-				// Just updating quantity of the same batch_storage
-				if qtyDifference != 0 {
-					// Check if we have enough quantity if increasing
-					if qtyDifference > 0 {
-						var availableQty float64
-						errAvail := tx.QueryRow(`
-							Select Quantity From Batch_Storage Where Id = $1
-						`, batchStorageID).Scan(&availableQty)
-						if errAvail != nil {
-							return fmt.Errorf("gagal memeriksa ketersediaan stok: %w", errAvail)
-						}
-
-						if availableQty < qtyDifference {
-							return fmt.Errorf("stok tidak mencukupi, tersedia: %g, diminta tambahan: %g", availableQty, qtyDifference)
-						}
-					}
-
-					// Update product_batch total quantity
-					_, err := tx.Exec(`
-						Update Product_Batch 
-						Set Current_Quantity = Current_Quantity - $1 
-						Where Id = $2
-					`, qtyDifference, batchID)
-					if err != nil {
-						return fmt.Errorf("gagal memperbarui stok batch: %w", err)
-					}
-
-					// Update batch_storage quantity
-					_, err = tx.Exec(`
-						Update Batch_Storage 
-						Set Quantity = Quantity - $1 
-						Where Id = $2
-					`, qtyDifference, batchStorageID)
-					if err != nil {
-						return fmt.Errorf("gagal memperbarui stok di lokasi penyimpanan: %w", err)
-					}
-				}
-
-				// Update sales order detail with new quantity/price
-				_, err := tx.Exec(`
-					Update Sales_Order_Detail 
-					Set Quantity = $1, Unit_Price = $2, Updated_At = Now()
-					Where Id = $3
-				`, newQty, newPrice, req.DetailID)
-				if err != nil {
-					return fmt.Errorf("gagal memperbarui detail pesanan: %w", err)
-				}
 			}
 
-			// Update the order's total_amount
-			_, err := tx.Exec(`
-				Update Sales_Order Set Total_Amount = (
-					Select Coalesce(Sum(Quantity * Unit_Price), 0)
-					From Sales_Order_Detail
-					Where Sales_Order_Id = $1
-				), Updated_At = Now()
-				Where Id = $1
-			`, req.SalesOrderID)
-			if err != nil {
-				return fmt.Errorf("gagal memperbarui total harga pesanan: %w", err)
-			}
-
-			return nil
+			return checkCredit(tx)
 		})
 
 		if err != nil {
@@ -1394,6 +1912,14 @@ func (r *SalesRepositoryImpl) UpdateSalesOrderItem(req UpdateSalesOrderItemReque
 	response.Quantity = newQty
 	response.UnitPrice = newPrice
 	response.TotalPrice = newQty * newPrice
+	response.ListPrice = listPrice
+	response.PriceOverridden = overridden
+	response.DiscountType = newDiscountType
+	response.DiscountValue = newDiscountValue
+	line := orderPricing.line(req.DetailID)
+	response.DiscountAmount = line.DiscountAmount
+	response.LineTotal = line.Total
+	response.OrderTotal = orderPricing.Totals.GrandTotal
 
 	return &response, nil
 }
@@ -1403,7 +1929,8 @@ func (r *SalesRepositoryImpl) GetSalesInvoices(req GetSalesInvoicesRequest) ([]G
 	// Build base query for fetching sales invoices
 	baseQuery := `
         Select Si.Id, Si.Serial_Id, Si.Sales_Order_Id, So.Serial_Id As Sales_Order_Serial,
-               So.Customer_Id, C.Name As Customer_Name, Si.Invoice_Date, Si.Total_Amount,
+               So.Customer_Id, C.Name As Customer_Name, Si.Invoice_Date,
+               Si.Subtotal, Si.Discount_Amount, Si.Tax_Base, Si.Tax_Amount, Si.Total_Amount,
                Case 
                  When Si.Cancelled_At Is Not Null Then 'cancelled'
                  When Exists(Select 1 From Sales_Order_Return Sor 
@@ -1420,10 +1947,12 @@ func (r *SalesRepositoryImpl) GetSalesInvoices(req GetSalesInvoicesRequest) ([]G
                  Else 'active'
                End As Status,
                Exists(Select 1 From Delivery_Note Dn Where Dn.Sales_Invoice_Id = Si.Id And Dn.Cancelled_At Is Null) As Has_Delivery_Note,
+               Sib.Paid_Amount, Sib.Outstanding_Amount, Sib.Due_Date, Sib.Payment_Status,
                Si.Created_By, Si.Created_At, Si.Cancelled_At
         From Sales_Invoice Si
         Join Sales_Order So On Si.Sales_Order_Id = So.Id
         Join Customer C On So.Customer_Id = C.Id
+        Join Sales_Invoice_Balance Sib On Sib.Id = Si.Id
         Where 1=1`
 
 	// Create count query
@@ -1432,6 +1961,7 @@ func (r *SalesRepositoryImpl) GetSalesInvoices(req GetSalesInvoicesRequest) ([]G
         From Sales_Invoice Si
         Join Sales_Order So On Si.Sales_Order_Id = So.Id
         Join Customer C On So.Customer_Id = C.Id
+        Join Sales_Invoice_Balance Sib On Sib.Id = Si.Id
         Where 1=1`
 
 	// Initialize query builders
@@ -1456,11 +1986,21 @@ func (r *SalesRepositoryImpl) GetSalesInvoices(req GetSalesInvoicesRequest) ([]G
 		// Handle partially_returned and returned statuses with additional subqueries if needed
 	}
 
+	if req.PaymentStatus != "" {
+		qb.AddFilter("sib.payment_status =", req.PaymentStatus)
+		countQb.AddFilter("sib.payment_status =", req.PaymentStatus)
+	}
+
 	if req.SerialID != "" {
 		qb.AddFilter("si.serial_id ILIKE", "%"+req.SerialID+"%")
 		countQb.AddFilter("si.serial_id ILIKE", "%"+req.SerialID+"%")
 	}
 
+	if req.BranchID != "" {
+		qb.AddFilter("so.branch_id =", req.BranchID)
+		countQb.AddFilter("so.branch_id =", req.BranchID)
+	}
+
 	if req.StartDate != "" {
 		startDate, err := time.Parse(time.RFC3339, req.StartDate)
 		if err == nil {
@@ -1489,6 +2029,8 @@ func (r *SalesRepositoryImpl) GetSalesInvoices(req GetSalesInvoicesRequest) ([]G
 			sortBy = "c.name"
 		case "status":
 			sortBy = "status"
+		case "outstanding_amount", "due_date":
+			sortBy = "sib." + req.SortBy
 		}
 	}
 
@@ -1501,15 +2043,7 @@ func (r *SalesRepositoryImpl) GetSalesInvoices(req GetSalesInvoicesRequest) ([]G
 	qb.Query.WriteString(fmt.Sprintf(" ORDER BY %s %s", sortBy, sortOrder))
 
 	// Add pagination to main query
-	pageSize := req.PageSize
-	if pageSize <= 0 {
-		pageSize = utils.DefaultPageSize
-	}
-	page := req.Page
-	if page <= 0 {
-		page = utils.DefaultPage
-	}
-	qb.AddPagination(pageSize, page)
+	qb.AddPagination(req.PaginationParameter)
 
 	// Build final queries
 	query, params := qb.Build()
@@ -1534,7 +2068,7 @@ func (r *SalesRepositoryImpl) GetSalesInvoices(req GetSalesInvoicesRequest) ([]G
 	for rows.Next() {
 		var invoice GetSalesInvoicesResponse
 		var invoiceDate, createdAt time.Time
-		var cancelledAt sql.NullTime
+		var cancelledAt, dueDate sql.NullTime
 		var hasDeliveryNote bool
 
 		err := rows.Scan(
@@ -1545,9 +2079,17 @@ func (r *SalesRepositoryImpl) GetSalesInvoices(req GetSalesInvoicesRequest) ([]G
 			&invoice.CustomerID,
 			&invoice.CustomerName,
 			&invoiceDate,
+			&invoice.Subtotal,
+			&invoice.DiscountAmount,
+			&invoice.TaxBase,
+			&invoice.TaxAmount,
 			&invoice.TotalAmount,
 			&invoice.Status,
 			&hasDeliveryNote,
+			&invoice.PaidAmount,
+			&invoice.OutstandingAmount,
+			&dueDate,
+			&invoice.PaymentStatus,
 			&invoice.CreatedBy,
 			&createdAt,
 			&cancelledAt,
@@ -1560,11 +2102,21 @@ func (r *SalesRepositoryImpl) GetSalesInvoices(req GetSalesInvoicesRequest) ([]G
 		invoice.InvoiceDate = invoiceDate.Format(time.RFC3339)
 		invoice.CreatedAt = createdAt.Format(time.RFC3339)
 		invoice.HasDeliveryNote = hasDeliveryNote
+		if dueDate.Valid {
+			due := dueDate.Time.Format(time.RFC3339)
+			invoice.DueDate = &due
+		}
 
 		if cancelledAt.Valid {
 			invoice.CancelledAt = cancelledAt.Time.Format(time.RFC3339)
 		}
 
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(invoice); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		invoices = append(invoices, invoice)
 	}
 
@@ -1575,24 +2127,381 @@ func (r *SalesRepositoryImpl) GetSalesInvoices(req GetSalesInvoicesRequest) ([]G
 	return invoices, totalItems, nil
 }
 
+// Sales documents whose branch can be looked up, every one of them belongs to the branch of its order
+const (
+	DocumentSalesOrder   = "sales_order"
+	DocumentSalesInvoice = "sales_invoice"
+	DocumentDeliveryNote = "delivery_note"
+	DocumentSalesReturn  = "sales_return"
+)
+
+// documentBranchQueries resolve the branch of each sales document through its order
+var documentBranchQueries = map[string]string{
+	DocumentSalesOrder:   "Select Coalesce(Branch_Id::text, '') From Sales_Order Where Id = $1",
+	DocumentSalesInvoice: "Select Coalesce(So.Branch_Id::text, '') From Sales_Invoice Si Join Sales_Order So On So.Id = Si.Sales_Order_Id Where Si.Id = $1",
+	DocumentDeliveryNote: "Select Coalesce(So.Branch_Id::text, '') From Delivery_Note Dn Join Sales_Order So On So.Id = Dn.Sales_Order_Id Where Dn.Id = $1",
+	DocumentSalesReturn:  "Select Coalesce(So.Branch_Id::text, '') From Sales_Order_Return Sr Join Sales_Order So On So.Id = Sr.Sales_Order_Id Where Sr.Id = $1",
+}
+
+// GetDocumentBranch returns the branch of a sales document, sql.ErrNoRows when it does not exist
+func (r *SalesRepositoryImpl) GetDocumentBranch(documentType, id string) (string, error) {
+	query, ok := documentBranchQueries[documentType]
+	if !ok {
+		return "", fmt.Errorf("jenis dokumen %s tidak dikenal", documentType)
+	}
+
+	var branchID string
+	if err := r.db.QueryRow(query, id).Scan(&branchID); err != nil {
+		return "", err
+	}
+	return branchID, nil
+}
+
+// salesOrderBranchID returns the branch of a sales order, documents derived from it are numbered in that branch
+func salesOrderBranchID(tx *sql.Tx, salesOrderID string) (string, error) {
+	var branchID string
+	err := tx.QueryRow("Select Coalesce(Branch_Id::text, '') From Sales_Order Where Id = $1", salesOrderID).Scan(&branchID)
+	if err != nil {
+		return "", fmt.Errorf("gagal mengambil cabang pesanan: %w", err)
+	}
+	return branchID, nil
+}
+
+// fulfilmentOrderStatus derives the status of an order from how much of its lines is invoiced and delivered,
+// once anything is delivered the delivery progress is reported
+func fulfilmentOrderStatus(tx *sql.Tx, salesOrderID string) (string, error) {
+	var invoiced, toInvoice, delivered, toDeliver bool
+	err := tx.QueryRow(`
+		Select Coalesce(Bool_Or(Invoiced_Quantity > 0), False), Coalesce(Bool_Or(Invoiced_Quantity < Quantity), False),
+			Coalesce(Bool_Or(Delivered_Quantity > 0), False), Coalesce(Bool_Or(Delivered_Quantity < Quantity), False)
+		From Sales_Order_Detail
+		Where Sales_Order_Id = $1`, salesOrderID).Scan(&invoiced, &toInvoice, &delivered, &toDeliver)
+	if err != nil {
+		return "", fmt.Errorf("gagal memeriksa kuantitas terfaktur: %w", err)
+	}
+
+	switch {
+	case delivered && toDeliver:
+		return "partially_delivered", nil
+	case delivered:
+		return "delivery", nil
+	case !invoiced:
+		return "order", nil
+	case toInvoice:
+		return "partially_invoiced", nil
+	default:
+		return "invoice", nil
+	}
+}
+
+// customerListPrice fetches the price of a product on the price list of a customer for a quantity today,
+// nil when the list has no price for it
+func customerListPrice(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, customerID, productID string, quantity float64) (*float64, error) {
+	var price sql.NullFloat64
+	err := q.QueryRow("Select Customer_List_Price($1, $2, $3)", customerID, productID, quantity).Scan(&price)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil harga dari daftar harga: %w", err)
+	}
+	if !price.Valid {
+		return nil, nil
+	}
+	return &price.Float64, nil
+}
+
+// priceOverridden reports whether a unit price was typed over the list price of its line
+func priceOverridden(unitPrice float64, listPrice *float64) bool {
+	return listPrice != nil && math.Round(unitPrice*100) != math.Round(*listPrice*100)
+}
+
+// checkDiscount checks a requested discount, a value needs its type and a percentage can not go over 100
+func checkDiscount(discountType string, value float64) error {
+	if discountType == "" && value > 0 {
+		return fmt.Errorf("jenis diskon harus diisi")
+	}
+	if discountType == pricing.DiscountPercent && value > 100 {
+		return fmt.Errorf("diskon persen tidak boleh lebih dari 100")
+	}
+	return nil
+}
+
+// cashChange checks the cash handed over on a sale against its total and returns the change, nil when no tender was given
+func cashChange(paymentMethod string, tendered *float64, total float64) (*float64, error) {
+	if tendered == nil {
+		return nil, nil
+	}
+	if paymentMethod != "cash" {
+		return nil, fmt.Errorf("jumlah bayar hanya untuk penjualan tunai")
+	}
+	if *tendered < total {
+		return nil, fmt.Errorf("jumlah bayar kurang dari total")
+	}
+	change := pricing.Round(*tendered - total)
+	return &change, nil
+}
+
+// salesOrderPricing is a sales order as priced by pricing.Calculate, DetailIDs line up with Lines
+type salesOrderPricing struct {
+	DetailIDs []string
+	Lines     []pricing.Line
+	Discount  pricing.Discount
+	Tax       pricing.Tax
+	Totals    pricing.Totals
+}
+
+// line returns the totals of an order line
+func (p *salesOrderPricing) line(detailID string) pricing.LineTotals {
+	for i, id := range p.DetailIDs {
+		if id == detailID {
+			return p.Totals.Lines[i]
+		}
+	}
+	return pricing.LineTotals{}
+}
+
+// loadSalesOrderPricing reads the discount and tax of an order with its lines and prices them
+func loadSalesOrderPricing(tx *sql.Tx, salesOrderID string) (*salesOrderPricing, error) {
+	var p salesOrderPricing
+	err := tx.QueryRow("Select Coalesce(Discount_Type, ''), Discount_Value, Tax_Rate, Tax_Mode From Sales_Order Where Id = $1",
+		salesOrderID).Scan(&p.Discount.Type, &p.Discount.Value, &p.Tax.Rate, &p.Tax.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil diskon dan pajak pesanan: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		Select Id, Quantity, Unit_Price, Coalesce(Discount_Type, ''), Discount_Value
+		From Sales_Order_Detail
+		Where Sales_Order_Id = $1
+		Order By Created_At, Id`, salesOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil item pesanan: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var line pricing.Line
+		if err = rows.Scan(&id, &line.Quantity, &line.UnitPrice, &line.Discount.Type, &line.Discount.Value); err != nil {
+			return nil, fmt.Errorf("gagal membaca item pesanan: %w", err)
+		}
+		p.DetailIDs = append(p.DetailIDs, id)
+		p.Lines = append(p.Lines, line)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("gagal membaca item pesanan: %w", err)
+	}
+
+	p.Totals = pricing.Calculate(p.Lines, p.Discount, p.Tax)
+	return &p, nil
+}
+
+// recalculateSalesOrder prices an order again after its lines, discounts or tax changed and stores the totals
+func recalculateSalesOrder(tx *sql.Tx, salesOrderID string) (*salesOrderPricing, error) {
+	p, err := loadSalesOrderPricing(tx, salesOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, detailID := range p.DetailIDs {
+		if _, err = tx.Exec("Update Sales_Order_Detail Set Discount_Amount = $1, Line_Total = $2 Where Id = $3",
+			p.Totals.Lines[i].DiscountAmount, p.Totals.Lines[i].Total, detailID); err != nil {
+			return nil, fmt.Errorf("gagal memperbarui total item pesanan: %w", err)
+		}
+	}
+
+	if _, err = tx.Exec(`
+		Update Sales_Order
+		Set Subtotal = $1, Discount_Amount = $2, Tax_Base = $3, Tax_Amount = $4, Total_Amount = $5, Updated_At = Now(),
+			-- A cash tender no longer adds up once the total changed
+			Amount_Tendered = Case When Total_Amount = $5 Then Amount_Tendered End,
+			Change_Amount = Case When Total_Amount = $5 Then Change_Amount End
+		Where Id = $6`,
+		p.Totals.Subtotal, p.Totals.DiscountAmount, p.Totals.TaxBase, p.Totals.TaxAmount, p.Totals.GrandTotal,
+		salesOrderID); err != nil {
+		return nil, fmt.Errorf("gagal memperbarui total harga pesanan: %w", err)
+	}
+	return p, nil
+}
+
+// checkCustomerCredit checks the paylater amount of an order against the credit limit and maximum overdue days of
+// the customer, lines of the order already stored are left out of what the customer owes as the amount covers them.
+// The customer is locked so concurrent orders are checked one after another.
+// Going over is only allowed with an approved override, which is recorded on the order and reported as true.
+func checkCustomerCredit(tx *sql.Tx, customerID, salesOrderID string, amount float64, override CreditOverrideRequest, userID string) (bool, error) {
+	var creditLimit sql.NullFloat64
+	var maxOverdueDays sql.NullInt64
+	err := tx.QueryRow("Select Credit_Limit, Max_Overdue_Days From Customer Where Id = $1 For No Key Update",
+		customerID).Scan(&creditLimit, &maxOverdueDays)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("pelanggan tidak ditemukan")
+		}
+		return false, fmt.Errorf("gagal memeriksa batas kredit pelanggan: %w", err)
+	}
+	if !creditLimit.Valid && !maxOverdueDays.Valid {
+		return false, nil
+	}
+
+	// The customer owes its open paylater receivables plus the part of its paylater orders not invoiced yet,
+	// valued at the line totals after discounts and tax
+	var outstanding float64
+	var overdueDays int
+	err = tx.QueryRow(`
+		Select (
+				Select Coalesce(Sum(Outstanding_Amount), 0)
+				From Sales_Invoice_Balance
+				Where Customer_Id = $1 And Payment_Method = 'paylater' And Payment_Status <> 'cancelled'
+			) + (
+				Select Coalesce(Sum((Sod.Quantity - Sod.Invoiced_Quantity) * Sod.Line_Total / Sod.Quantity), 0)
+				From Sales_Order_Detail Sod
+				Join Sales_Order So On So.Id = Sod.Sales_Order_Id
+				Where So.Customer_Id = $1 And So.Payment_Method = 'paylater' And So.Cancelled_At Is Null
+					And So.Id <> $2
+			), (
+				Select Coalesce(Max(Current_Date - Due_Date::date), 0)
+				From Sales_Invoice_Balance
+				Where Customer_Id = $1 And Payment_Status = 'overdue'
+			)`, customerID, salesOrderID).Scan(&outstanding, &overdueDays)
+	if err != nil {
+		return false, fmt.Errorf("gagal menghitung piutang pelanggan: %w", err)
+	}
+
+	var violations []string
+	if creditLimit.Valid && math.Round((outstanding+amount)*100) > math.Round(creditLimit.Float64*100) {
+		violations = append(violations, fmt.Sprintf("piutang %s ditambah %s melebihi batas kredit %s",
+			utils.FormatRupiah(outstanding), utils.FormatRupiah(amount), utils.FormatRupiah(creditLimit.Float64)))
+	}
+	if maxOverdueDays.Valid && int64(overdueDays) > maxOverdueDays.Int64 {
+		violations = append(violations, fmt.Sprintf("faktur tertua sudah %d hari lewat jatuh tempo dari batas %d hari",
+			overdueDays, maxOverdueDays.Int64))
+	}
+	if len(violations) == 0 {
+		return false, nil
+	}
+	if !override.CreditOverride {
+		return false, fmt.Errorf("pesanan paylater ditolak, %s. Minta persetujuan override kredit", strings.Join(violations, " dan "))
+	}
+
+	var overrideID string
+	err = tx.QueryRow(`
+		Insert Into Sales_Order_Credit_Override (
+			Sales_Order_Id, Customer_Id, Order_Amount, Outstanding_Amount, Credit_Limit, Overdue_Days, Max_Overdue_Days,
+			Reason, Approved_By
+		) Values ($1, $2, $3, $4, $5, $6, $7, $8, Nullif($9, '')::uuid)
+		Returning Id`,
+		salesOrderID, customerID, amount, outstanding, creditLimit, overdueDays, maxOverdueDays,
+		override.CreditOverrideReason, userID).Scan(&overrideID)
+	if err != nil {
+		return false, fmt.Errorf("gagal mencatat persetujuan override kredit: %w", err)
+	}
+
+	errRecord := outbox.Record(tx, event.SalesOrderCreditOverridden, map[string]interface{}{
+		"override_id":        overrideID,
+		"sales_order_id":     salesOrderID,
+		"customer_id":        customerID,
+		"order_amount":       amount,
+		"outstanding_amount": outstanding,
+		"violations":         violations,
+		"reason":             override.CreditOverrideReason,
+		"approved_by":        userID,
+	})
+	return errRecord == nil, errRecord
+}
+
+// returnAmount values a sales return at what was charged for its line after discounts and tax, the same amount
+// it credits on the invoice that billed the line
+func returnAmount(tx *sql.Tx, returnID string) (float64, error) {
+	var amount float64
+	err := tx.QueryRow(`
+		Select Coalesce(
+			(Select Sir.Amount From Sales_Invoice_Return Sir Where Sir.Id = Sor.Id),
+			Round(Sor.Return_Quantity * Sod.Line_Total / Sod.Quantity, 2)
+		)
+		From Sales_Order_Return Sor
+		Join Sales_Order_Detail Sod On Sod.Id = Sor.Sales_Detail_Id
+		Where Sor.Id = $1`, returnID).Scan(&amount)
+	if err != nil {
+		return 0, fmt.Errorf("gagal menghitung nilai retur: %w", err)
+	}
+	return amount, nil
+}
+
+// salesInvoiceItems reads the lines of an invoice with their product and batch
+func salesInvoiceItems(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, invoiceID string) ([]SalesInvoiceItemResponse, error) {
+	rows, err := q.Query(`
+		Select Sid.Id, Sid.Sales_Invoice_Id, Sod.Sales_Order_Id, Sod.Id,
+			P.Id, P.Name, Coalesce(U.Name, ''), Pb.Id, Pb.Sku, Sod.Batch_Storage_Id,
+			Sid.Quantity, Sid.Unit_Price, Sid.Discount_Amount, Sid.Line_Total,
+			(Select Coalesce(Sum(Dnd.Quantity), 0) From Delivery_Note_Detail Dnd
+				Join Delivery_Note Dn On Dn.Id = Dnd.Delivery_Note_Id
+				Where Dn.Sales_Invoice_Id = Sid.Sales_Invoice_Id And Dn.Cancelled_At Is Null
+				And Dnd.Sales_Order_Detail_Id = Sid.Sales_Order_Detail_Id)
+		From Sales_Invoice_Detail Sid
+		Join Sales_Order_Detail Sod On Sod.Id = Sid.Sales_Order_Detail_Id
+		Join Batch_Storage Bs On Bs.Id = Sod.Batch_Storage_Id
+		Join Product_Batch Pb On Pb.Id = Bs.Batch_Id
+		Join Product P On P.Id = Pb.Product_Id
+		Left Join Unit U On U.Id = P.Unit_Id
+		Where Sid.Sales_Invoice_Id = $1
+		Order By Sod.Created_At, Sod.Id`, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil item faktur: %w", err)
+	}
+	defer rows.Close()
+
+	items := []SalesInvoiceItemResponse{}
+	for rows.Next() {
+		var item SalesInvoiceItemResponse
+		if err = rows.Scan(&item.ID, &item.SalesInvoiceID, &item.SalesOrderID, &item.SalesOrderDetailID,
+			&item.ProductID, &item.ProductName, &item.ProductUnit, &item.BatchID, &item.BatchSKU, &item.BatchStorageID,
+			&item.Quantity, &item.UnitPrice, &item.DiscountAmount, &item.LineTotal, &item.DeliveredQuantity); err != nil {
+			return nil, fmt.Errorf("gagal membaca item faktur: %w", err)
+		}
+		item.TotalPrice = item.Quantity * item.UnitPrice
+		item.RemainingToDeliver = item.Quantity - item.DeliveredQuantity
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetSalesInvoiceItems retrieves the order lines and quantities an invoice covers
+func (r *SalesRepositoryImpl) GetSalesInvoiceItems(invoiceID string) ([]SalesInvoiceItemResponse, error) {
+	var exists bool
+	if err := r.db.QueryRow("Select Exists(Select 1 From Sales_Invoice Where Id = $1)", invoiceID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("gagal memeriksa faktur: %w", err)
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	return salesInvoiceItems(r.db, invoiceID)
+}
+
 // CreateSalesInvoice creates a new invoice from a order
 func (r *SalesRepositoryImpl) CreateSalesInvoice(req CreateSalesInvoiceRequest, userID string, tx *sql.Tx) (*CreateSalesInvoiceResponse, error) {
 	var response CreateSalesInvoiceResponse
 
 	// Function to execute the invoice creation logic
 	createInvoiceFunc := func(tx *sql.Tx) error {
-		// Check if order exists and is in 'order' status
+		// Lock the order so invoices of it are numbered and counted one after another
 		var orderStatus, orderSerial string
 		var customerId string
 		var customerName string
-		var totalAmount float64
+		var paymentMethod string
+		var orderDiscount pricing.Discount
+		var tax pricing.Tax
+		var orderSubtotal, orderDiscountAmount float64
 
 		err := tx.QueryRow(`
-            Select So.Status, So.Serial_Id, So.Customer_Id, C.Name, So.Total_Amount
+            Select So.Status, So.Serial_Id, So.Customer_Id, C.Name, So.Payment_Method,
+                Coalesce(So.Discount_Type, ''), So.Discount_Value, So.Tax_Rate, So.Tax_Mode, So.Subtotal, So.Discount_Amount
             From Sales_Order So
             Join Customer C On So.Customer_Id = C.Id
             Where So.Id = $1
-        `, req.SalesOrderID).Scan(&orderStatus, &orderSerial, &customerId, &customerName, &totalAmount)
+            For Update Of So
+        `, req.SalesOrderID).Scan(&orderStatus, &orderSerial, &customerId, &customerName, &paymentMethod,
+			&orderDiscount.Type, &orderDiscount.Value, &tax.Rate, &tax.Mode, &orderSubtotal, &orderDiscountAmount)
 
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
@@ -1601,69 +2510,27 @@ func (r *SalesRepositoryImpl) CreateSalesInvoice(req CreateSalesInvoiceRequest,
 			return fmt.Errorf("gagal memeriksa pesanan: %w", err)
 		}
 
-		// Validate order status
-		if orderStatus != "order" {
+		// Validate order status, a partially invoiced or delivered order can be invoiced further
+		if orderStatus != "order" && orderStatus != "partially_invoiced" && orderStatus != "partially_delivered" {
 			return fmt.Errorf("pesanan dalam status %s", orderStatus)
 		}
 
-		// Check if invoice already exists for this order
-		var existingInvoice string
-		err = tx.QueryRow(`
-            Select Id From Sales_Invoice 
-            Where Sales_Order_Id = $1 And Cancelled_At Is Null
-        `, req.SalesOrderID).Scan(&existingInvoice)
-
-		if err != nil && !errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("gagal memeriksa faktur yang ada: %w", err)
-		}
-
-		if existingInvoice != "" {
-			return fmt.Errorf("faktur sudah ada untuk pesanan ini")
-		}
-
-		var invoiceID string
-		var invoiceDate time.Time
-
-		// Generate a new serial ID for the invoice
-		serialID, err := utils.GenerateNextSerialID(tx, "SI")
-		if err != nil {
-			return fmt.Errorf("gagal membuat ID faktur: %w", err)
-		}
-
-		// Create invoice
-		err = tx.QueryRow(`
-            Insert Into Sales_Invoice (
-                Sales_Order_Id, Serial_Id, Total_Amount, Created_By
-            ) Values ($1, $2, $3, $4)
-            Returning Id, Serial_Id, Invoice_Date
-        `, req.SalesOrderID, serialID, totalAmount, userID).Scan(&invoiceID, &serialID, &invoiceDate)
-
-		if err != nil {
-			return fmt.Errorf("gagal membuat faktur: %w", err)
-		}
-
-		// Update order status to 'invoice'
-		_, err = tx.Exec(`
-            Update Sales_Order 
-            Set Status = 'invoice', Updated_At = Now() 
-            Where Id = $1
-        `, req.SalesOrderID)
-
-		if err != nil {
-			return fmt.Errorf("gagal memperbarui status pesanan: %w", err)
-		}
-
-		// Get all order details for logging - collect items first to avoid connection issues
+		// Get the order lines still to invoice - collect items first to avoid connection issues
 		type detailItem struct {
 			detailID       string
 			batchStorageID string
 			batchID        string
 			storageID      string
+			productName    string
+			remaining      float64
 			quantity       float64
 			unitPrice      float64
+			orderQuantity  float64
+			discount       pricing.Discount
+			discountAmount float64 // Discount of the whole order line
 		}
 
-		var items []detailItem
+		var lines []detailItem
 
 		rows, err := tx.Query(`
             Select 
@@ -1671,15 +2538,23 @@ func (r *SalesRepositoryImpl) CreateSalesInvoice(req CreateSalesInvoiceRequest,
                 Sod.Batch_Storage_Id, 
                 Bs.Batch_Id,
                 Bs.Storage_Id,
-                Sod.Quantity, 
-                Sod.Unit_Price
+                P.Name,
+                Sod.Quantity - Sod.Invoiced_Quantity, 
+                Sod.Unit_Price,
+                Sod.Quantity,
+                Coalesce(Sod.Discount_Type, ''),
+                Sod.Discount_Value,
+                Sod.Discount_Amount
             From Sales_Order_Detail Sod
             Join Batch_Storage Bs On Sod.Batch_Storage_Id = Bs.Id
+            Join Product_Batch Pb On Bs.Batch_Id = Pb.Id
+            Join Product P On Pb.Product_Id = P.Id
             Where Sod.Sales_Order_Id = $1
+            Order By Sod.Batch_Storage_Id
         `, req.SalesOrderID)
 
 		if err != nil {
-			return fmt.Errorf("gagal mengambil detail pesanan untuk pencatatan: %w", err)
+			return fmt.Errorf("gagal mengambil detail pesanan untuk dikeluarkan: %w", err)
 		}
 
 		// Important: properly close rows when done
@@ -1687,18 +2562,23 @@ func (r *SalesRepositoryImpl) CreateSalesInvoice(req CreateSalesInvoiceRequest,
 
 		// Read all items at once to avoid connection issues
 		for rows.Next() {
-			var item detailItem
+			var line detailItem
 			if err := rows.Scan(
-				&item.detailID,
-				&item.batchStorageID,
-				&item.batchID,
-				&item.storageID,
-				&item.quantity,
-				&item.unitPrice,
+				&line.detailID,
+				&line.batchStorageID,
+				&line.batchID,
+				&line.storageID,
+				&line.productName,
+				&line.remaining,
+				&line.unitPrice,
+				&line.orderQuantity,
+				&line.discount.Type,
+				&line.discount.Value,
+				&line.discountAmount,
 			); err != nil {
 				return fmt.Errorf("gagal memindai detail item: %w", err)
 			}
-			items = append(items, item)
+			lines = append(lines, line)
 		}
 
 		// Check for any errors during row iteration
@@ -1706,8 +2586,157 @@ func (r *SalesRepositoryImpl) CreateSalesInvoice(req CreateSalesInvoiceRequest,
 			return fmt.Errorf("terjadi kesalahan saat membaca detail item: %w", err)
 		}
 
-		// Now that rows are closed, process the items for logging
-		for _, item := range items {
+		// Without chosen items everything left is invoiced, otherwise only the chosen quantities
+		requested := make(map[string]float64, len(req.Items))
+		for _, item := range req.Items {
+			detailID := strings.ToLower(item.SalesOrderDetailID)
+			if _, duplicate := requested[detailID]; duplicate {
+				return fmt.Errorf("item pesanan %s dipilih lebih dari sekali", item.SalesOrderDetailID)
+			}
+			requested[detailID] = item.Quantity
+		}
+
+		var items []detailItem
+		var orderLineDiscount float64
+		for _, line := range lines {
+			orderLineDiscount += line.discountAmount
+		}
+		for _, line := range lines {
+			quantity := line.remaining
+			if len(req.Items) > 0 {
+				var chosen bool
+				if quantity, chosen = requested[line.detailID]; !chosen {
+					continue
+				}
+				delete(requested, line.detailID)
+				if quantity > line.remaining {
+					return fmt.Errorf("kuantitas faktur untuk %s melebihi sisa yang belum difakturkan: sisa %g, diminta %g",
+						line.productName, line.remaining, quantity)
+				}
+			}
+			if quantity <= 0 {
+				continue
+			}
+			line.quantity = quantity
+			items = append(items, line)
+		}
+		for _, item := range req.Items {
+			if _, unknown := requested[strings.ToLower(item.SalesOrderDetailID)]; unknown {
+				return fmt.Errorf("item pesanan %s tidak ditemukan di pesanan ini", item.SalesOrderDetailID)
+			}
+		}
+		if len(items) == 0 {
+			return fmt.Errorf("tidak ada item pesanan yang tersisa untuk difakturkan")
+		}
+
+		// Every invoice is priced on its own at the tax of the order. Percentages apply as they are,
+		// discount amounts are shared out by the invoiced quantity and the net amount of the lines.
+		invoiceLines := make([]pricing.Line, len(items))
+		for i, item := range items {
+			discount := item.discount
+			if discount.Type == pricing.DiscountAmount {
+				discount.Value = pricing.Round(item.discountAmount * item.quantity / item.orderQuantity)
+			}
+			invoiceLines[i] = pricing.Line{Quantity: item.quantity, UnitPrice: item.unitPrice, Discount: discount}
+		}
+		invoiceDiscount := orderDiscount
+		if invoiceDiscount.Type == pricing.DiscountAmount {
+			invoiceDiscount.Value = 0
+			beforeOrderDiscount := pricing.Calculate(invoiceLines, pricing.Discount{}, pricing.Tax{})
+			if orderNet := orderSubtotal - orderLineDiscount; orderNet > 0 {
+				invoiceDiscount.Value = pricing.Round((orderDiscountAmount - orderLineDiscount) *
+					(beforeOrderDiscount.Subtotal - beforeOrderDiscount.DiscountAmount) / orderNet)
+			}
+		}
+		totals := pricing.Calculate(invoiceLines, invoiceDiscount, tax)
+
+		var invoiceID string
+		var invoiceDate time.Time
+
+		// Generate a new serial ID for the invoice
+		branchID, err := salesOrderBranchID(tx, req.SalesOrderID)
+		if err != nil {
+			return err
+		}
+		serialID, err := utils.GenerateNextBranchSerialID(tx, "SI", branchID)
+		if err != nil {
+			return fmt.Errorf("gagal membuat ID faktur: %w", err)
+		}
+
+		// The cash handed over for the invoice is printed on its receipt with the change
+		changeAmount, err := cashChange(paymentMethod, req.AmountTendered, totals.GrandTotal)
+		if err != nil {
+			return err
+		}
+
+		// Create invoice
+		err = tx.QueryRow(`
+            Insert Into Sales_Invoice (
+                Sales_Order_Id, Serial_Id, Total_Amount, Created_By,
+                Subtotal, Discount_Amount, Tax_Base, Tax_Amount, Tax_Rate, Tax_Mode, Amount_Tendered, Change_Amount
+            ) Values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+            Returning Id, Serial_Id, Invoice_Date
+        `, req.SalesOrderID, serialID, totals.GrandTotal, userID,
+			totals.Subtotal, totals.DiscountAmount, totals.TaxBase, totals.TaxAmount, tax.Rate, tax.Mode,
+			req.AmountTendered, changeAmount,
+		).Scan(&invoiceID, &serialID, &invoiceDate)
+
+		if err != nil {
+			return fmt.Errorf("gagal membuat faktur: %w", err)
+		}
+
+		// Invoice the lines and take them out of stock, a reservation that expired may have lost its stock
+		for i, item := range items {
+			var invoiceDetailID string
+			if err = tx.QueryRow(`
+                Insert Into Sales_Invoice_Detail (Sales_Invoice_Id, Sales_Order_Detail_Id, Quantity, Unit_Price, Discount_Amount, Line_Total)
+                Values ($1, $2, $3, $4, $5, $6)
+                Returning Id
+            `, invoiceID, item.detailID, item.quantity, item.unitPrice,
+				totals.Lines[i].DiscountAmount, totals.Lines[i].Total).Scan(&invoiceDetailID); err != nil {
+				return fmt.Errorf("gagal menambahkan item faktur: %w", err)
+			}
+
+			if _, err = tx.Exec(`
+                Update Sales_Order_Detail 
+                Set Invoiced_Quantity = Invoiced_Quantity + $1, Updated_At = Now() 
+                Where Id = $2
+            `, item.quantity, item.detailID); err != nil {
+				return fmt.Errorf("gagal memperbarui kuantitas terfaktur: %w", err)
+			}
+
+			// The invoiced part of the reservation becomes the issued stock
+			if err = consumeDetailReservation(tx, item.detailID, item.quantity); err != nil {
+				return err
+			}
+
+			if err = lockBatchStorage(tx, item.batchStorageID); err != nil {
+				return err
+			}
+			availableQty, err := availableQuantity(tx, item.batchStorageID)
+			if err != nil {
+				return err
+			}
+			if availableQty < item.quantity {
+				return fmt.Errorf("stok tidak cukup untuk %s: tersedia %g, diminta %g", item.productName, availableQty, item.quantity)
+			}
+
+			if _, err = tx.Exec(`
+                Update Batch_Storage 
+                Set Quantity = Quantity - $1 
+                Where Id = $2
+            `, item.quantity, item.batchStorageID); err != nil {
+				return fmt.Errorf("gagal memperbarui kuantitas batch storage: %w", err)
+			}
+
+			if _, err = tx.Exec(`
+                Update Product_Batch 
+                Set Current_Quantity = Current_Quantity - $1 
+                Where Id = $2
+            `, item.quantity, item.batchID); err != nil {
+				return fmt.Errorf("gagal memperbarui kuantitas batch: %w", err)
+			}
+
 			// Log inventory movement
 			_, err = tx.Exec(`
                 Insert Into Inventory_Log (
@@ -1733,6 +2762,19 @@ func (r *SalesRepositoryImpl) CreateSalesInvoice(req CreateSalesInvoiceRequest,
 			}
 		}
 
+		// The order is fully invoiced once no line has anything left
+		orderStatus, err = fulfilmentOrderStatus(tx, req.SalesOrderID)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(`
+            Update Sales_Order 
+            Set Status = $1, Updated_At = Now() 
+            Where Id = $2
+        `, orderStatus, req.SalesOrderID); err != nil {
+			return fmt.Errorf("gagal memperbarui status pesanan: %w", err)
+		}
+
 		// Create financial transaction log
 		_, err = tx.Exec(`
             Insert Into Financial_Transaction_Log (
@@ -1746,7 +2788,7 @@ func (r *SalesRepositoryImpl) CreateSalesInvoice(req CreateSalesInvoiceRequest,
             ) Values ($1, $2, $3, $4, $5, $6, $7)
         `,
 			userID,
-			totalAmount,
+			totals.GrandTotal,
 			"debit",
 			req.SalesOrderID,
 			fmt.Sprintf("Penjualan  %s", serialID),
@@ -1757,6 +2799,11 @@ func (r *SalesRepositoryImpl) CreateSalesInvoice(req CreateSalesInvoiceRequest,
 			return fmt.Errorf("gagal mencatat transaksi keuangan: %w", err)
 		}
 
+		invoiceItems, err := salesInvoiceItems(tx, invoiceID)
+		if err != nil {
+			return err
+		}
+
 		// Set response
 		response.ID = invoiceID
 		response.SerialID = serialID
@@ -1765,12 +2812,23 @@ func (r *SalesRepositoryImpl) CreateSalesInvoice(req CreateSalesInvoiceRequest,
 		response.CustomerID = customerId
 		response.CustomerName = customerName
 		response.InvoiceDate = invoiceDate.Format(time.RFC3339)
-		response.TotalAmount = totalAmount
+		response.TaxRate = tax.Rate
+		response.TaxMode = tax.Mode
+		response.Subtotal = totals.Subtotal
+		response.DiscountAmount = totals.DiscountAmount
+		response.TaxBase = totals.TaxBase
+		response.TaxAmount = totals.TaxAmount
+		response.TotalAmount = totals.GrandTotal
 		response.Status = "active"
+		response.OrderStatus = orderStatus
 		response.CreatedBy = userID
 		response.CreatedAt = invoiceDate.Format(time.RFC3339)
+		response.AmountTendered = req.AmountTendered
+		response.ChangeAmount = changeAmount
+		response.Items = invoiceItems
 
-		return nil
+		// Record event in outbox
+		return outbox.Record(tx, event.SalesInvoiceCreated, response)
 	}
 
 	// If a transaction was provided, use it directly
@@ -1816,70 +2874,196 @@ func (r *SalesRepositoryImpl) CreateSalesInvoice(req CreateSalesInvoiceRequest,
 
 // CancelSalesInvoice cancels an existing sales invoice
 func (r *SalesRepositoryImpl) CancelSalesInvoice(req CancelSalesInvoiceRequest, userID string) error {
-	// Check if invoice exists
-	var salesOrderID string
-	var cancelled bool
+	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		var salesOrderID, invoiceSerialID, orderStatus string
+		var cancelled bool
 
-	err := r.db.QueryRow(`
-        Select Sales_Order_Id, Cancelled_At Is Not Null 
-        From Sales_Invoice 
-        Where Id = $1
-    `, req.InvoiceID).Scan(&salesOrderID, &cancelled)
+		// Lock the order first, as invoicing does, so its invoiced quantities and status are not changed meanwhile
+		err := tx.QueryRow("Select Sales_Order_Id From Sales_Invoice Where Id = $1", req.InvoiceID).Scan(&salesOrderID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("faktur tidak ditemukan")
+			}
+			return fmt.Errorf("gagal memeriksa faktur: %w", err)
+		}
+		if err = tx.QueryRow("Select Status From Sales_Order Where Id = $1 For Update", salesOrderID).Scan(&orderStatus); err != nil {
+			return fmt.Errorf("gagal mengunci pesanan: %w", err)
+		}
 
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("faktur tidak ditemukan")
+		// Payments and credit notes lock the invoices they are put against
+		err = tx.QueryRow(`
+            Select Serial_Id, Cancelled_At Is Not Null
+            From Sales_Invoice
+            Where Id = $1
+            For Update
+        `, req.InvoiceID).Scan(&invoiceSerialID, &cancelled)
+		if err != nil {
+			return fmt.Errorf("gagal memeriksa faktur: %w", err)
 		}
-		return fmt.Errorf("gagal memeriksa faktur: %w", err)
-	}
 
-	// Check if already cancelled
-	if cancelled {
-		return fmt.Errorf("faktur ini sudah dibatalkan")
-	}
+		// Check if already cancelled
+		if cancelled {
+			return fmt.Errorf("faktur ini sudah dibatalkan")
+		}
+
+		// Check if delivery note exists
+		var deliveryExists bool
+		err = tx.QueryRow(`
+            Select Exists(
+                Select 1 From Delivery_Note
+                Where Sales_Invoice_Id = $1 And Cancelled_At Is Null
+            )
+        `, req.InvoiceID).Scan(&deliveryExists)
+
+		if err != nil {
+			return fmt.Errorf("gagal memeriksa surat jalan: %w", err)
+		}
 
-	// Check if delivery note exists
-	var deliveryExists bool
-	err = r.db.QueryRow(`
-        Select Exists(
-            Select 1 From Delivery_Note 
-            Where Sales_Invoice_Id = $1 And Cancelled_At Is Null
-        )
-    `, req.InvoiceID).Scan(&deliveryExists)
+		if deliveryExists {
+			return fmt.Errorf("tidak dapat membatalkan faktur karena sudah memiliki surat jalan aktif")
+		}
 
-	if err != nil {
-		return fmt.Errorf("gagal memeriksa surat jalan: %w", err)
-	}
+		// Returned items are already back in stock
+		if orderStatus != "invoice" && orderStatus != "partially_invoiced" && orderStatus != "partially_delivered" {
+			return fmt.Errorf("tidak dapat membatalkan faktur untuk pesanan dalam status %s", orderStatus)
+		}
 
-	if deliveryExists {
-		return fmt.Errorf("tidak dapat membatalkan faktur karena sudah memiliki surat jalan aktif")
-	}
+		// Money put against the invoice has to be taken off it first
+		var paid, credited bool
+		err = tx.QueryRow(`
+            Select
+                Exists(
+                    Select 1 From Customer_Payment_Allocation Cpa
+                    Join Customer_Payment Cp On Cp.Id = Cpa.Customer_Payment_Id
+                    Where Cpa.Sales_Invoice_Id = $1 And Cp.Cancelled_At Is Null
+                ),
+                Exists(
+                    Select 1 From Credit_Note_Allocation Cna
+                    Join Credit_Note Cn On Cn.Id = Cna.Credit_Note_Id
+                    Where Cna.Sales_Invoice_Id = $1 And Cn.Cancelled_At Is Null
+                )
+        `, req.InvoiceID).Scan(&paid, &credited)
+		if err != nil {
+			return fmt.Errorf("gagal memeriksa pembayaran faktur: %w", err)
+		}
+		if paid {
+			return fmt.Errorf("tidak dapat membatalkan faktur karena sudah memiliki pembayaran aktif")
+		}
+		if credited {
+			return fmt.Errorf("tidak dapat membatalkan faktur karena sudah memiliki nota kredit aktif")
+		}
 
-	// Execute transaction
-	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
 		// Mark invoice as cancelled
-		_, err := tx.Exec(`
+		result, err := tx.Exec(`
             Update Sales_Invoice 
             Set Cancelled_At = Now(), Cancelled_By = $1 
-            Where Id = $2
+            Where Id = $2 And Cancelled_At Is Null
         `, userID, req.InvoiceID)
 
 		if err != nil {
 			return fmt.Errorf("gagal membatalkan faktur: %w", err)
 		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return fmt.Errorf("faktur ini sudah dibatalkan")
+		}
 
-		// Revert sales order to 'order' status
-		_, err = tx.Exec(`
-            Update Sales_Order 
-            Set Status = 'order', Updated_At = Now() 
-            Where Id = $1
-        `, salesOrderID)
+		// Put the invoiced lines back in stock, the order reserves them again
+		type detailItem struct {
+			detailID       string
+			batchStorageID string
+			batchID        string
+			storageID      string
+			quantity       float64
+			remaining      float64
+		}
+
+		var items []detailItem
+		rows, err := tx.Query(`
+            Select Sod.Id, Sod.Batch_Storage_Id, Bs.Batch_Id, Bs.Storage_Id, Sid.Quantity,
+                Sod.Quantity - Sod.Invoiced_Quantity + Sid.Quantity
+            From Sales_Invoice_Detail Sid
+            Join Sales_Order_Detail Sod On Sod.Id = Sid.Sales_Order_Detail_Id
+            Join Batch_Storage Bs On Sod.Batch_Storage_Id = Bs.Id
+            Where Sid.Sales_Invoice_Id = $1
+            Order By Sod.Batch_Storage_Id
+        `, req.InvoiceID)
+		if err != nil {
+			return fmt.Errorf("gagal mengambil detail faktur: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var item detailItem
+			if err := rows.Scan(&item.detailID, &item.batchStorageID, &item.batchID, &item.storageID,
+				&item.quantity, &item.remaining); err != nil {
+				return fmt.Errorf("gagal membaca detail faktur: %w", err)
+			}
+			items = append(items, item)
+		}
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("terjadi kesalahan saat memproses detail faktur: %w", err)
+		}
+
+		for _, item := range items {
+			if _, err = tx.Exec(`
+                Update Batch_Storage 
+                Set Quantity = Quantity + $1 
+                Where Id = $2
+            `, item.quantity, item.batchStorageID); err != nil {
+				return fmt.Errorf("gagal mengembalikan stok di lokasi penyimpanan: %w", err)
+			}
+
+			if _, err = tx.Exec(`
+                Update Product_Batch 
+                Set Current_Quantity = Current_Quantity + $1 
+                Where Id = $2
+            `, item.quantity, item.batchID); err != nil {
+				return fmt.Errorf("gagal mengembalikan stok produk: %w", err)
+			}
+
+			if _, err = tx.Exec(`
+                Insert Into Inventory_Log (
+                    Batch_Id, Storage_Id, User_Id, Sales_Order_Id, Action, Quantity, Description
+                ) Values ($1, $2, $3, $4, 'add', $5, $6)
+            `, item.batchID, item.storageID, userID, salesOrderID, item.quantity,
+				fmt.Sprintf("Pembatalan Faktur %s", invoiceSerialID)); err != nil {
+				return fmt.Errorf("gagal mencatat log inventaris: %w", err)
+			}
+
+			if _, err = tx.Exec(`
+                Update Sales_Order_Detail 
+                Set Invoiced_Quantity = Invoiced_Quantity - $1, Updated_At = Now() 
+                Where Id = $2
+            `, item.quantity, item.detailID); err != nil {
+				return fmt.Errorf("gagal memperbarui kuantitas terfaktur: %w", err)
+			}
+
+			// The reservation covers everything of the line not invoiced anymore
+			if err = r.reserveStock(tx, item.detailID, item.batchStorageID, item.remaining); err != nil {
+				return err
+			}
+		}
 
+		// Revert the order status depending on the invoices and delivery notes left
+		newStatus, err := fulfilmentOrderStatus(tx, salesOrderID)
 		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(`
+            Update Sales_Order 
+            Set Status = $1, Updated_At = Now() 
+            Where Id = $2
+        `, newStatus, salesOrderID); err != nil {
 			return fmt.Errorf("gagal memperbarui status pesanan: %w", err)
 		}
 
-		return nil
+		// Record event in outbox
+		return outbox.Record(tx, event.SalesInvoiceCancelled, map[string]string{
+			"invoice_id":     req.InvoiceID,
+			"sales_order_id": salesOrderID,
+			"order_status":   newStatus,
+			"cancelled_by":   userID,
+		})
 	})
 }
 
@@ -1973,16 +3157,27 @@ func (r *SalesRepositoryImpl) ReturnItemFromSalesOrder(req ReturnItemRequest, us
 		// Calculate remaining quantity
 		remainingQty := currentQuantity - previousReturnedQty - req.Quantity
 
+		// Generate serial ID
+		branchID, err := salesOrderBranchID(tx, req.SalesOrderID)
+		if err != nil {
+			return err
+		}
+		returnSerialID, err := utils.GenerateNextBranchSerialID(tx, "SR", branchID)
+		if err != nil {
+			return err
+		}
+		response.SerialID = returnSerialID
+
 		// Insert return record
 		if _, err := tx.Exec(`
             Insert Into Sales_Order_Return (
-                Id, Return_Source, Sales_Order_Id, Sales_Detail_Id,
+                Id, Serial_Id, Return_Source, Sales_Order_Id, Sales_Detail_Id,
                 Return_Quantity, Remaining_Quantity, Return_Reason, 
                 Return_Status, Returned_By, Returned_At, Delivery_Note_Id
             ) Values (
-                $1, $2, $3, $4, $5, $6, $7, 'completed', $8, Now(), $9
+                $1, $2, $3, $4, $5, $6, $7, $8, 'completed', $9, Now(), $10
             )
-        `, returnID, returnSource, req.SalesOrderID, req.SalesOrderDetailID,
+        `, returnID, returnSerialID, returnSource, req.SalesOrderID, req.SalesOrderDetailID,
 			req.Quantity, remainingQty, req.ReturnReason, userID,
 			sql.NullString{String: deliveryNoteID.String, Valid: deliveryNoteID.Valid}); err != nil {
 			return fmt.Errorf("failed to create return record: %w", err)
@@ -2050,13 +3245,9 @@ func (r *SalesRepositoryImpl) ReturnItemFromSalesOrder(req ReturnItemRequest, us
 		}
 
 		// 2) financial log for the return (refund)
-		var unitPrice float64
-		if err := tx.QueryRow(`
-        SELECT Sod.Unit_Price
-        FROM Sales_Order_Detail Sod
-        WHERE Sod.Id = $1 AND Sod.Sales_Order_Id = $2
-    `, req.SalesOrderDetailID, req.SalesOrderID).Scan(&unitPrice); err != nil {
-			return fmt.Errorf("gagal mengambil harga satuan untuk retur: %w", err)
+		refundAmount, err := returnAmount(tx, returnID)
+		if err != nil {
+			return err
 		}
 
 		if _, err := tx.Exec(`
@@ -2065,7 +3256,7 @@ func (r *SalesRepositoryImpl) ReturnItemFromSalesOrder(req ReturnItemRequest, us
             Description, Transaction_Date, Is_System
         ) VALUES ($1,$2,$3,$4,$5,$6,$7)
     `, userID,
-			req.Quantity*unitPrice,
+			refundAmount,
 			"credit",
 			req.SalesOrderID,
 			fmt.Sprintf("Retur Barang Penjualan %s", serialID),
@@ -2119,7 +3310,15 @@ func (r *SalesRepositoryImpl) ReturnItemFromSalesOrder(req ReturnItemRequest, us
 			return fmt.Errorf("failed to update order status: %w", err)
 		}
 
-		return nil
+		// Record event in outbox
+		return outbox.Record(tx, event.SalesReturnCreated, map[string]interface{}{
+			"return_id":             response.ReturnID,
+			"sales_order_id":        req.SalesOrderID,
+			"sales_order_detail_id": req.SalesOrderDetailID,
+			"quantity":              req.Quantity,
+			"is_full_return":        response.IsFullReturn,
+			"returned_by":           userID,
+		})
 	})
 
 	if err != nil {
@@ -2301,13 +3500,9 @@ func (r *SalesRepositoryImpl) CancelSalesOrderReturn(req CancelReturnRequest, us
 					return fmt.Errorf("failed to log inventory change: %w", err)
 				}
 
-				var unitPrice float64
-				if err := tx.QueryRow(`
-        SELECT Sod.Unit_Price
-        FROM Sales_Order_Detail Sod
-        WHERE Sod.Id = $1
-    `, salesDetailID).Scan(&unitPrice); err != nil {
-					return fmt.Errorf("gagal mengambil harga satuan untuk batal retur: %w", err)
+				refundAmount, err := returnAmount(tx, req.ReturnID)
+				if err != nil {
+					return err
 				}
 
 				if _, err := tx.Exec(`
@@ -2316,7 +3511,7 @@ func (r *SalesRepositoryImpl) CancelSalesOrderReturn(req CancelReturnRequest, us
             Description, Transaction_Date, Is_System
         ) VALUES ($1,$2,$3,$4,$5,$6,$7)
     `, userID,
-					returnQuantity*unitPrice,
+					refundAmount,
 					"debit",
 					salesOrderID,
 					fmt.Sprintf("Batal Retur %s", req.ReturnID),
@@ -2344,6 +3539,11 @@ func (r *SalesRepositoryImpl) CancelSalesOrderReturn(req CancelReturnRequest, us
 			return fmt.Errorf("failed to mark return as cancelled: %w", err)
 		}
 
+		// A credit note issued for the return no longer holds once the goods go back to the customer
+		if err := creditnote.CancelForReturn(tx, req.ReturnID, userID); err != nil {
+			return err
+		}
+
 		// Continue with the rest of the function to update order status...
 
 		// The remaining code is unchanged
@@ -2393,25 +3593,12 @@ func (r *SalesRepositoryImpl) CancelSalesOrderReturn(req CancelReturnRequest, us
 				newStatus = "partially_returned"
 			}
 		} else {
-			// Determine appropriate status based on delivery note/invoice existence
-			var hasDelivery bool
-			var hasInvoice bool
-
-			if err := tx.QueryRow(`
-                Select 
-                    Exists(Select 1 From Delivery_Note Where Sales_Order_Id = $1 And Cancelled_At Is Null),
-                    Exists(Select 1 From Sales_Invoice Where Sales_Order_Id = $1 And Cancelled_At Is Null)
-            `, salesOrderID).Scan(&hasDelivery, &hasInvoice); err != nil {
-				return fmt.Errorf("failed to check order documents: %w", err)
-			}
-
-			if hasDelivery {
-				newStatus = "delivery"
-			} else if hasInvoice {
-				newStatus = "invoice"
-			} else {
-				newStatus = "order"
+			// Determine appropriate status based on the invoiced and delivered quantities
+			status, err := fulfilmentOrderStatus(tx, salesOrderID)
+			if err != nil {
+				return err
 			}
+			newStatus = status
 		}
 
 		// Update order status
@@ -2424,18 +3611,189 @@ func (r *SalesRepositoryImpl) CancelSalesOrderReturn(req CancelReturnRequest, us
 			return fmt.Errorf("failed to update order status: %w", err)
 		}
 
-		return nil
+		// Record event in outbox
+		return outbox.Record(tx, event.SalesReturnCancelled, map[string]string{
+			"return_id":      req.ReturnID,
+			"sales_order_id": salesOrderID,
+			"cancelled_by":   userID,
+		})
 	})
 }
 
-// CreateDeliveryNote creates a new delivery note from a sales invoice
+// deliveryNoteStatusExpr derives the status of a delivery note from its cancellation and the returns made against it
+const deliveryNoteStatusExpr = `Case
+	When Dn.Cancelled_At Is Not Null Then 'cancelled'
+	When Dnq.Returned > 0 And Dnq.Returned >= Dnq.Quantity Then 'returned'
+	When Dnq.Returned > 0 Then 'partially_returned'
+	Else 'active' End`
+
+const deliveryNoteJoins = `
+	From Delivery_Note Dn
+	Join Sales_Order So On So.Id = Dn.Sales_Order_Id
+	Join Customer C On C.Id = So.Customer_Id
+	Left Join Sales_Invoice Si On Si.Id = Dn.Sales_Invoice_Id
+	Join Lateral (
+		Select Coalesce(Sum(Dnd.Quantity), 0) As Quantity,
+			(Select Coalesce(Sum(R.Return_Quantity), 0) From Sales_Order_Return R
+				Where R.Delivery_Note_Id = Dn.Id And R.Return_Status = 'completed' And R.Cancelled_At Is Null) As Returned
+		From Delivery_Note_Detail Dnd
+		Where Dnd.Delivery_Note_Id = Dn.Id
+	) Dnq On True`
+
+// deliveryNoteSortColumns lists the columns delivery notes can be sorted by
+var deliveryNoteSortColumns = map[string]string{
+	"serial_id":     "Dn.Serial_Id",
+	"delivery_date": "Dn.Delivery_Date",
+	"customer_name": "C.Name",
+	"driver_name":   "Dn.Driver_Name",
+	"created_at":    "Dn.Created_At",
+}
+
+// GetDeliveryNotes fetches delivery notes with pagination, branch users only see their branch
+func (r *SalesRepositoryImpl) GetDeliveryNotes(req GetDeliveryNotesRequest) ([]GetDeliveryNotesResponse, int, error) {
+	qb := utils.NewQueryBuilder(`
+		Select Dn.Id, Dn.Serial_Id, Dn.Sales_Order_Id, So.Serial_Id, Coalesce(Dn.Sales_Invoice_Id::text, ''),
+			Coalesce(Si.Serial_Id, ''), Dn.Delivery_Date, Dn.Driver_Name, Dn.Recipient_Name, So.Customer_Id, C.Name,
+			Dnq.Quantity, ` + deliveryNoteStatusExpr + `, Dn.Created_By, Dn.Created_At, Dn.Cancelled_At` +
+		deliveryNoteJoins + `
+		Where 1=1`)
+	qb.AddFilter("Dn.Sales_Order_Id =", req.SalesOrderID)
+	qb.AddFilter("Dn.Sales_Invoice_Id =", req.SalesInvoiceID)
+	qb.AddFilter("So.Branch_Id =", req.BranchID)
+	if req.SerialID != "" {
+		qb.AddFilter("Dn.Serial_Id ILIKE", "%"+req.SerialID+"%")
+	}
+	if startDate, err := time.Parse(time.RFC3339, req.StartDate); err == nil {
+		qb.AddFilter("Dn.Delivery_Date >=", startDate)
+	}
+	if endDate, err := time.Parse(time.RFC3339, req.EndDate); err == nil {
+		// Add one day to include the entire end date
+		qb.AddFilter("Dn.Delivery_Date <", endDate.Add(24*time.Hour))
+	}
+	qb.AddFilter(deliveryNoteStatusExpr+" =", req.Status)
+
+	var totalItems int
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Delivery_Notes", qb.Query.String())
+	if err := r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems); err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung surat jalan: %w", err)
+	}
+
+	if column, ok := deliveryNoteSortColumns[req.SortBy]; ok && req.SortOrder != "" {
+		qb.Query.WriteString(fmt.Sprintf(" Order By %s %s", column, req.SortOrder))
+	} else {
+		qb.Query.WriteString(" Order By Dn.Created_At Desc")
+	}
+	qb.AddPagination(req.PaginationParameter)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil surat jalan: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []GetDeliveryNotesResponse{}
+	for rows.Next() {
+		var note GetDeliveryNotesResponse
+		var deliveryDate, createdAt time.Time
+		var cancelledAt sql.NullTime
+		if err = rows.Scan(&note.ID, &note.SerialID, &note.SalesOrderID, &note.SalesOrderSerial, &note.SalesInvoiceID,
+			&note.SalesInvoiceSerial, &deliveryDate, &note.DriverName, &note.RecipientName, &note.CustomerID,
+			&note.CustomerName, &note.TotalQuantity, &note.Status, &note.CreatedBy, &createdAt, &cancelledAt); err != nil {
+			return nil, 0, fmt.Errorf("gagal membaca surat jalan: %w", err)
+		}
+		note.DeliveryDate = deliveryDate.Format(time.RFC3339)
+		note.CreatedAt = createdAt.Format(time.RFC3339)
+		if cancelledAt.Valid {
+			note.CancelledAt = cancelledAt.Time.Format(time.RFC3339)
+		}
+		if req.Export != nil {
+			if errExport := req.Export.WriteRow(note); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
+		notes = append(notes, note)
+	}
+
+	return notes, totalItems, rows.Err()
+}
+
+// deliveryNoteItems reads the lines of a delivery note with their product and source storage
+func deliveryNoteItems(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, deliveryNoteID string) ([]DeliveryNoteItem, error) {
+	rows, err := q.Query(`
+		Select Dnd.Id, Dnd.Delivery_Note_Id, Sod.Id, P.Id, P.Name, Coalesce(U.Name, ''), Pb.Id, Pb.Sku,
+			Dnd.Batch_Storage_Id, S.Id, S.Name, Dnd.Quantity, Sod.Unit_Price
+		From Delivery_Note_Detail Dnd
+		Join Sales_Order_Detail Sod On Sod.Id = Dnd.Sales_Order_Detail_Id
+		Join Batch_Storage Obs On Obs.Id = Sod.Batch_Storage_Id
+		Join Product_Batch Pb On Pb.Id = Obs.Batch_Id
+		Join Product P On P.Id = Pb.Product_Id
+		Left Join Unit U On U.Id = P.Unit_Id
+		Left Join Batch_Storage Bs On Bs.Id = Dnd.Batch_Storage_Id
+		Left Join Storage S On S.Id = Bs.Storage_Id
+		Where Dnd.Delivery_Note_Id = $1
+		Order By Sod.Created_At, Sod.Id`, deliveryNoteID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil item surat jalan: %w", err)
+	}
+	defer rows.Close()
+
+	items := []DeliveryNoteItem{}
+	for rows.Next() {
+		var item DeliveryNoteItem
+		if err = rows.Scan(&item.ID, &item.DeliveryNoteID, &item.SalesOrderDetailID, &item.ProductID, &item.ProductName,
+			&item.ProductUnit, &item.BatchID, &item.BatchSKU, &item.BatchStorageID, &item.StorageID, &item.StorageName,
+			&item.Quantity, &item.UnitPrice); err != nil {
+			return nil, fmt.Errorf("gagal membaca item surat jalan: %w", err)
+		}
+		item.TotalPrice = item.Quantity * item.UnitPrice
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetDeliveryNoteItems fetches the lines of a delivery note, sql.ErrNoRows means it does not exist
+func (r *SalesRepositoryImpl) GetDeliveryNoteItems(deliveryNoteID string) ([]DeliveryNoteItem, error) {
+	var exists bool
+	if err := r.db.QueryRow("Select Exists(Select 1 From Delivery_Note Where Id = $1)", deliveryNoteID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("gagal memeriksa surat jalan: %w", err)
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	return deliveryNoteItems(r.db, deliveryNoteID)
+}
+
+// moveIssuedStock books goods issued from one batch storage as loaded from another storage of the same batch,
+// the stock goes back to the storage the order line was issued from and leaves the storage it was loaded from
+func moveIssuedStock(tx *sql.Tx, batchID, fromBatchStorageID, fromStorageID, toBatchStorageID, toStorageID, userID,
+	salesOrderID, description string, quantity float64) error {
+	if _, err := tx.Exec("Update Batch_Storage Set Quantity = Quantity - $1 Where Id = $2", quantity, fromBatchStorageID); err != nil {
+		return fmt.Errorf("gagal memperbarui kuantitas batch storage: %w", err)
+	}
+	if _, err := tx.Exec("Update Batch_Storage Set Quantity = Quantity + $1 Where Id = $2", quantity, toBatchStorageID); err != nil {
+		return fmt.Errorf("gagal memperbarui kuantitas batch storage: %w", err)
+	}
+	if _, err := tx.Exec(`
+		Insert Into Inventory_Log (
+			Batch_Id, Storage_Id, Target_Storage_Id, User_Id, Sales_Order_Id, Action, Quantity, Description
+		) Values ($1, $2, $3, $4, $5, 'transfer', $6, $7)`,
+		batchID, fromStorageID, toStorageID, userID, salesOrderID, quantity, description); err != nil {
+		return fmt.Errorf("gagal mencatat log inventaris: %w", err)
+	}
+	return nil
+}
+
+// CreateDeliveryNote creates a new delivery note from a sales invoice, carrying the chosen lines or
+// everything of the invoice not delivered yet
 func (r *SalesRepositoryImpl) CreateDeliveryNote(req CreateDeliveryNoteRequest, userID string) (*CreateDeliveryNoteResponse, error) {
-	// Verify the invoice exists and is not cancelled
+	// Verify the invoice exists
 	var salesOrderID string
 	var invoiceSerialID string
 	var salesOrderSerialID string
-	var hasDeliveryNote bool
-	var invoiceCancelled bool
 	var customerName string
 
 	err := r.db.QueryRow(`
@@ -2443,17 +3801,12 @@ func (r *SalesRepositoryImpl) CreateDeliveryNote(req CreateDeliveryNoteRequest,
             S.Id, 
             I.Serial_Id, 
             S.Serial_Id,
-            C.Name As Customer_Name,
-            I.Cancelled_At Is Not Null,
-            Exists (
-                Select 1 From Delivery_Note Dn 
-                Where Dn.Sales_Invoice_Id = I.Id And Dn.Cancelled_At Is Null
-            )
+            C.Name As Customer_Name
         From Sales_Invoice I
         Join Sales_Order S On I.Sales_Order_Id = S.Id
         Join Customer C On S.Customer_Id = C.Id
         Where I.Id = $1
-    `, req.SalesInvoiceID).Scan(&salesOrderID, &invoiceSerialID, &salesOrderSerialID, &customerName, &invoiceCancelled, &hasDeliveryNote)
+    `, req.SalesInvoiceID).Scan(&salesOrderID, &invoiceSerialID, &salesOrderSerialID, &customerName)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -2462,47 +3815,205 @@ func (r *SalesRepositoryImpl) CreateDeliveryNote(req CreateDeliveryNoteRequest,
 		return nil, fmt.Errorf("gagal memeriksa faktur penjualan: %w", err)
 	}
 
-	if invoiceCancelled {
-		return nil, errors.New("tidak dapat membuat surat jalan dari faktur yang sudah dibatalkan")
-	}
-
-	if hasDeliveryNote {
-		return nil, errors.New("faktur ini sudah memiliki surat jalan aktif")
-	}
-
 	// Create transaction to handle serial number generation and delivery note creation
 	var response CreateDeliveryNoteResponse
 	err = utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		// Lock the order so its delivered quantities are not changed by another delivery note or a cancelled invoice
+		if _, err := tx.Exec("Select 1 From Sales_Order Where Id = $1 For Update", salesOrderID); err != nil {
+			return fmt.Errorf("gagal mengunci pesanan: %w", err)
+		}
+
+		var invoiceCancelled bool
+		if err := tx.QueryRow("Select Cancelled_At Is Not Null From Sales_Invoice Where Id = $1",
+			req.SalesInvoiceID).Scan(&invoiceCancelled); err != nil {
+			return fmt.Errorf("gagal memeriksa faktur penjualan: %w", err)
+		}
+		if invoiceCancelled {
+			return errors.New("tidak dapat membuat surat jalan dari faktur yang sudah dibatalkan")
+		}
+
+		branchID, err := salesOrderBranchID(tx, salesOrderID)
+		if err != nil {
+			return err
+		}
+
+		// Get the invoice lines still to deliver
+		type deliveryLine struct {
+			detailID       string
+			batchStorageID string
+			batchID        string
+			storageID      string
+			productName    string
+			remaining      float64
+			quantity       float64
+			source         DeliveryNoteItemRequest
+		}
+
+		var lines []deliveryLine
+		rows, err := tx.Query(`
+            Select Sod.Id, Sod.Batch_Storage_Id, Bs.Batch_Id, Bs.Storage_Id, P.Name,
+                Sid.Quantity - (
+                    Select Coalesce(Sum(Dnd.Quantity), 0) From Delivery_Note_Detail Dnd
+                    Join Delivery_Note Dn On Dn.Id = Dnd.Delivery_Note_Id
+                    Where Dn.Sales_Invoice_Id = Sid.Sales_Invoice_Id And Dn.Cancelled_At Is Null
+                    And Dnd.Sales_Order_Detail_Id = Sod.Id
+                )
+            From Sales_Invoice_Detail Sid
+            Join Sales_Order_Detail Sod On Sod.Id = Sid.Sales_Order_Detail_Id
+            Join Batch_Storage Bs On Bs.Id = Sod.Batch_Storage_Id
+            Join Product_Batch Pb On Pb.Id = Bs.Batch_Id
+            Join Product P On P.Id = Pb.Product_Id
+            Where Sid.Sales_Invoice_Id = $1
+            Order By Sod.Batch_Storage_Id
+        `, req.SalesInvoiceID)
+		if err != nil {
+			return fmt.Errorf("gagal mengambil item faktur untuk dikirim: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var line deliveryLine
+			if err := rows.Scan(&line.detailID, &line.batchStorageID, &line.batchID, &line.storageID,
+				&line.productName, &line.remaining); err != nil {
+				return fmt.Errorf("gagal memindai item faktur: %w", err)
+			}
+			lines = append(lines, line)
+		}
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("terjadi kesalahan saat membaca item faktur: %w", err)
+		}
+
+		// Without chosen items everything left is delivered, otherwise only the chosen quantities
+		requested := make(map[string]DeliveryNoteItemRequest, len(req.Items))
+		for _, item := range req.Items {
+			detailID := strings.ToLower(item.SalesOrderDetailID)
+			if _, duplicate := requested[detailID]; duplicate {
+				return fmt.Errorf("item pesanan %s dipilih lebih dari sekali", item.SalesOrderDetailID)
+			}
+			requested[detailID] = item
+		}
+
+		var items []deliveryLine
+		for _, line := range lines {
+			line.quantity = line.remaining
+			if len(req.Items) > 0 {
+				chosen, ok := requested[line.detailID]
+				if !ok {
+					continue
+				}
+				delete(requested, line.detailID)
+				if chosen.Quantity > line.remaining {
+					return fmt.Errorf("kuantitas surat jalan untuk %s melebihi sisa yang belum dikirim: sisa %g, diminta %g",
+						line.productName, line.remaining, chosen.Quantity)
+				}
+				line.quantity = chosen.Quantity
+				line.source = chosen
+			}
+			if line.quantity <= 0 {
+				continue
+			}
+			items = append(items, line)
+		}
+		for _, item := range req.Items {
+			if _, unknown := requested[strings.ToLower(item.SalesOrderDetailID)]; unknown {
+				return fmt.Errorf("item pesanan %s tidak ditemukan di faktur ini", item.SalesOrderDetailID)
+			}
+		}
+		if len(items) == 0 {
+			return errors.New("tidak ada item faktur yang tersisa untuk dikirim")
+		}
+
 		// Get next serial number for delivery note
-		serialID, err := utils.GenerateNextSerialID(tx, "DN")
+		serialID, err := utils.GenerateNextBranchSerialID(tx, "DN", branchID)
 		if err != nil {
 			return fmt.Errorf("gagal membuat nomor surat jalan: %w", err)
 		}
 
 		// Create delivery note
 		var deliveryNoteID string
+		var deliveryDate, createdAt time.Time
 		err = tx.QueryRow(`
             Insert Into Delivery_Note (
                 Serial_Id, Sales_Order_Id, Sales_Invoice_Id, 
                 Delivery_Date, Driver_Name, Recipient_Name, Created_By
-            ) Values ($1, $2, $3, $4, $5, $6, $7)
-            Returning Id
+            ) Values ($1, $2, $3, Coalesce(Nullif($4, '')::timestamptz, Now()), $5, $6, $7)
+            Returning Id, Delivery_Date, Created_At
         `, serialID, salesOrderID, req.SalesInvoiceID, req.DeliveryDate,
-			req.DriverName, req.RecipientName, userID).Scan(&deliveryNoteID)
+			req.DriverName, req.RecipientName, userID).Scan(&deliveryNoteID, &deliveryDate, &createdAt)
 
 		if err != nil {
 			return fmt.Errorf("gagal membuat surat jalan: %w", err)
 		}
 
-		// Update sales order status to 'delivery'
+		for _, item := range items {
+			// Goods loaded from another storage of the batch are taken from there instead
+			sourceBatchStorageID := item.batchStorageID
+			if item.source.StorageID != "" && !strings.EqualFold(item.source.StorageID, item.storageID) {
+				err = tx.QueryRow(`
+                    Select Bs.Id
+                    From Batch_Storage Bs
+                    Join Storage S On S.Id = Bs.Storage_Id
+                    Where Bs.Batch_Id = $1 And Bs.Storage_Id = $2 And S.Deleted_At Is Null
+                    And ($3 = '' Or S.Branch_Id = Nullif($3, '')::uuid)
+                `, item.batchID, item.source.StorageID, branchID).Scan(&sourceBatchStorageID)
+				if err != nil {
+					if errors.Is(err, sql.ErrNoRows) {
+						return fmt.Errorf("batch %s tidak tersedia di gudang %s", item.productName, item.source.StorageID)
+					}
+					return fmt.Errorf("gagal memeriksa gudang asal: %w", err)
+				}
+
+				if err = lockBatchStorage(tx, sourceBatchStorageID); err != nil {
+					return err
+				}
+				availableQty, err := availableQuantity(tx, sourceBatchStorageID)
+				if err != nil {
+					return err
+				}
+				if availableQty < item.quantity {
+					return fmt.Errorf("stok tidak cukup di gudang asal untuk %s: tersedia %g, diminta %g",
+						item.productName, availableQty, item.quantity)
+				}
+				if err = moveIssuedStock(tx, item.batchID, sourceBatchStorageID, item.source.StorageID, item.batchStorageID,
+					item.storageID, userID, salesOrderID, fmt.Sprintf("Surat Jalan %s", serialID), item.quantity); err != nil {
+					return err
+				}
+			}
+
+			if _, err = tx.Exec(`
+                Insert Into Delivery_Note_Detail (Delivery_Note_Id, Sales_Order_Detail_Id, Batch_Storage_Id, Quantity)
+                Values ($1, $2, $3, $4)
+            `, deliveryNoteID, item.detailID, sourceBatchStorageID, item.quantity); err != nil {
+				return fmt.Errorf("gagal menambahkan item surat jalan: %w", err)
+			}
+
+			if _, err = tx.Exec(`
+                Update Sales_Order_Detail 
+                Set Delivered_Quantity = Delivered_Quantity + $1, Updated_At = Now() 
+                Where Id = $2
+            `, item.quantity, item.detailID); err != nil {
+				return fmt.Errorf("gagal memperbarui kuantitas terkirim: %w", err)
+			}
+		}
+
+		// The order is fully delivered once no line has anything left to deliver, a returned order keeps its status
+		orderStatus, err := fulfilmentOrderStatus(tx, salesOrderID)
+		if err != nil {
+			return err
+		}
 		if _, err = tx.Exec(`
             Update Sales_Order 
-            Set Status = 'delivery', Updated_At = Now() 
-            Where Id = $1
-        `, salesOrderID); err != nil {
+            Set Status = $1, Updated_At = Now() 
+            Where Id = $2 And Status In ('invoice', 'partially_invoiced', 'partially_delivered', 'delivery')
+        `, orderStatus, salesOrderID); err != nil {
 			return fmt.Errorf("gagal memperbarui status pesanan: %w", err)
 		}
 
+		noteItems, err := deliveryNoteItems(tx, deliveryNoteID)
+		if err != nil {
+			return err
+		}
+
 		// Set response fields
 		response = CreateDeliveryNoteResponse{
 			ID:                 deliveryNoteID,
@@ -2511,14 +4022,17 @@ func (r *SalesRepositoryImpl) CreateDeliveryNote(req CreateDeliveryNoteRequest,
 			SalesOrderSerial:   salesOrderSerialID,
 			SalesInvoiceID:     req.SalesInvoiceID,
 			SalesInvoiceSerial: invoiceSerialID,
-			DeliveryDate:       req.DeliveryDate,
+			DeliveryDate:       deliveryDate.Format(time.RFC3339),
 			DriverName:         req.DriverName,
 			RecipientName:      req.RecipientName,
+			OrderStatus:        orderStatus,
 			CreatedBy:          userID,
-			CreatedAt:          time.Now().Format(time.RFC3339),
+			CreatedAt:          createdAt.Format(time.RFC3339),
+			Items:              noteItems,
 		}
 
-		return nil
+		// Record event in outbox
+		return outbox.Record(tx, event.DeliveryNoteCreated, response)
 	})
 
 	if err != nil {
@@ -2528,16 +4042,17 @@ func (r *SalesRepositoryImpl) CreateDeliveryNote(req CreateDeliveryNoteRequest,
 	return &response, nil
 }
 
-// CancelDeliveryNote cancels an existing delivery note
+// CancelDeliveryNote cancels an existing delivery note, its lines become outstanding to deliver again
 func (r *SalesRepositoryImpl) CancelDeliveryNote(req CancelDeliveryNoteRequest, userID string) error {
 	// Verify the delivery note exists and is not already cancelled
-	var salesOrderID string
+	var salesOrderID, serialID string
 	var hasReturn bool
 	var isCancelled bool
 
 	err := r.db.QueryRow(`
         Select 
             Dn.Sales_Order_Id, 
+            Dn.Serial_Id,
             Dn.Cancelled_At Is Not Null,
             Exists (
                 Select 1 From Sales_Order_Return R
@@ -2547,7 +4062,7 @@ func (r *SalesRepositoryImpl) CancelDeliveryNote(req CancelDeliveryNoteRequest,
             )
         From Delivery_Note Dn
         Where Dn.Id = $1
-    `, req.DeliveryNoteID).Scan(&salesOrderID, &isCancelled, &hasReturn)
+    `, req.DeliveryNoteID).Scan(&salesOrderID, &serialID, &isCancelled, &hasReturn)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -2566,26 +4081,112 @@ func (r *SalesRepositoryImpl) CancelDeliveryNote(req CancelDeliveryNoteRequest,
 
 	// Execute transaction
 	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		// Lock the order so its delivered quantities are not changed by another delivery note meanwhile
+		if _, err := tx.Exec("Select 1 From Sales_Order Where Id = $1 For Update", salesOrderID); err != nil {
+			return fmt.Errorf("gagal mengunci pesanan: %w", err)
+		}
+
 		// Mark delivery note as cancelled
-		if _, err := tx.Exec(`
+		result, err := tx.Exec(`
             Update Delivery_Note
             Set Cancelled_At = Now(),
                 Cancelled_By = $1
-            Where Id = $2
-        `, userID, req.DeliveryNoteID); err != nil {
+            Where Id = $2 And Cancelled_At Is Null
+        `, userID, req.DeliveryNoteID)
+		if err != nil {
 			return fmt.Errorf("gagal membatalkan surat jalan: %w", err)
 		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return errors.New("surat jalan sudah dibatalkan sebelumnya")
+		}
 
-		// Update sales order status back to 'invoice'
-		if _, err := tx.Exec(`
+		// Read the lines back, goods loaded from another storage are booked back to it
+		type deliveryLine struct {
+			detailID             string
+			batchID              string
+			batchStorageID       string
+			storageID            string
+			sourceBatchStorageID sql.NullString
+			sourceStorageID      sql.NullString
+			quantity             float64
+		}
+
+		var lines []deliveryLine
+		rows, err := tx.Query(`
+            Select Sod.Id, Bs.Batch_Id, Bs.Id, Bs.Storage_Id, Src.Id, Src.Storage_Id, Dnd.Quantity
+            From Delivery_Note_Detail Dnd
+            Join Sales_Order_Detail Sod On Sod.Id = Dnd.Sales_Order_Detail_Id
+            Join Batch_Storage Bs On Bs.Id = Sod.Batch_Storage_Id
+            Left Join Batch_Storage Src On Src.Id = Dnd.Batch_Storage_Id
+            Where Dnd.Delivery_Note_Id = $1
+            Order By Bs.Id
+        `, req.DeliveryNoteID)
+		if err != nil {
+			return fmt.Errorf("gagal mengambil item surat jalan: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var line deliveryLine
+			if err := rows.Scan(&line.detailID, &line.batchID, &line.batchStorageID, &line.storageID,
+				&line.sourceBatchStorageID, &line.sourceStorageID, &line.quantity); err != nil {
+				return fmt.Errorf("gagal membaca item surat jalan: %w", err)
+			}
+			lines = append(lines, line)
+		}
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("terjadi kesalahan saat membaca item surat jalan: %w", err)
+		}
+
+		for _, line := range lines {
+			if line.sourceBatchStorageID.Valid && line.sourceBatchStorageID.String != line.batchStorageID {
+				if err = lockBatchStorage(tx, line.batchStorageID); err != nil {
+					return err
+				}
+				availableQty, err := availableQuantity(tx, line.batchStorageID)
+				if err != nil {
+					return err
+				}
+				if availableQty < line.quantity {
+					return fmt.Errorf("stok tidak cukup untuk mengembalikan barang ke gudang asal: tersedia %g, dibutuhkan %g",
+						availableQty, line.quantity)
+				}
+				if err = moveIssuedStock(tx, line.batchID, line.batchStorageID, line.storageID, line.sourceBatchStorageID.String,
+					line.sourceStorageID.String, userID, salesOrderID, fmt.Sprintf("Pembatalan Surat Jalan %s", serialID),
+					line.quantity); err != nil {
+					return err
+				}
+			}
+
+			if _, err = tx.Exec(`
+                Update Sales_Order_Detail 
+                Set Delivered_Quantity = Delivered_Quantity - $1, Updated_At = Now() 
+                Where Id = $2
+            `, line.quantity, line.detailID); err != nil {
+				return fmt.Errorf("gagal memperbarui kuantitas terkirim: %w", err)
+			}
+		}
+
+		// Revert the order status depending on the delivery notes left, a returned order keeps its status
+		newStatus, err := fulfilmentOrderStatus(tx, salesOrderID)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(`
             Update Sales_Order
-            Set Status = 'invoice',
+            Set Status = $1,
                 Updated_At = Now()
-            Where Id = $1
-        `, salesOrderID); err != nil {
+            Where Id = $2 And Status In ('invoice', 'partially_invoiced', 'partially_delivered', 'delivery')
+        `, newStatus, salesOrderID); err != nil {
 			return fmt.Errorf("gagal memperbarui status pesanan: %w", err)
 		}
 
-		return nil
+		// Record event in outbox
+		return outbox.Record(tx, event.DeliveryNoteCancelled, map[string]string{
+			"delivery_note_id": req.DeliveryNoteID,
+			"sales_order_id":   salesOrderID,
+			"order_status":     newStatus,
+			"cancelled_by":     userID,
+		})
 	})
 }
@@ -1,11 +1,21 @@
 package sales
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 )
 
+const (
+	// reservationSweepInterval is how often expired stock reservations are swept
+	reservationSweepInterval = time.Minute
+
+	// reservationSweepBatchSize is the number of reservations dropped per statement
+	reservationSweepBatchSize = 500
+)
+
 // SalesService is the service for the Sales domain.
 type SalesService struct {
 	repo SalesRepository
@@ -16,6 +26,64 @@ func NewSalesService(repo SalesRepository) *SalesService {
 	return &SalesService{repo: repo}
 }
 
+// Start sweeps expired stock reservations in the background. Expired reservations already stop
+// counting as reserved, sweeping records which orders let go of their stock.
+func (s *SalesService) Start() {
+	go func() {
+		ticker := time.NewTicker(reservationSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			s.expireReservations()
+			<-ticker.C
+		}
+	}()
+}
+
+// expireReservations drops every expired reservation
+func (s *SalesService) expireReservations() {
+	for {
+		expired, err := s.repo.ExpireReservations(reservationSweepBatchSize)
+		if err != nil {
+			log.Printf("sales: %v", err)
+			return
+		}
+		if expired > 0 {
+			log.Printf("sales: released %d expired stock reservations", expired)
+		}
+		if expired < reservationSweepBatchSize {
+			return
+		}
+	}
+}
+
+// ErrNotFound is wrapped by the errors of documents that do not exist or belong to another branch than the active one
+var ErrNotFound = errors.New("tidak ditemukan")
+
+// documentLabels name the sales documents in not found errors
+var documentLabels = map[string]string{
+	DocumentSalesOrder:   "pesanan",
+	DocumentSalesInvoice: "faktur",
+	DocumentDeliveryNote: "surat jalan",
+	DocumentSalesReturn:  "retur",
+}
+
+// checkBranch hides documents of other branches from callers with an active branch
+func (s *SalesService) checkBranch(documentType, id, branchID string) error {
+	if branchID == "" {
+		return nil
+	}
+
+	documentBranch, err := s.repo.GetDocumentBranch(documentType, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil || documentBranch != branchID {
+		return fmt.Errorf("%s %w", documentLabels[documentType], ErrNotFound)
+	}
+	return nil
+}
+
 // GetSalesOrders retrieves a paginated list of sales orders with optional filtering
 func (s *SalesService) GetSalesOrders(req GetSalesOrdersRequest) ([]GetSalesOrdersResponse, int, error) {
 	return s.repo.GetSalesOrders(req)
@@ -27,7 +95,11 @@ func (s *SalesService) GetAllBatches(req GetAllBatchesRequest) ([]GetAllBatchesR
 }
 
 // GetSalesOrderDetail retrieves detailed information about a sales purchase-order including its items
-func (s *SalesService) GetSalesOrderDetail(orderID string) (*GetSalesOrderDetailResponse, error) {
+func (s *SalesService) GetSalesOrderDetail(orderID, branchID string) (*GetSalesOrderDetailResponse, error) {
+	if err := s.checkBranch(DocumentSalesOrder, orderID, branchID); err != nil {
+		return nil, err
+	}
+
 	var result *GetSalesOrderDetailResponse
 	// Get the sales purchase-order header information
 	result, err := s.repo.GetSalesOrderWithDetails(orderID)
@@ -46,8 +118,35 @@ func (s *SalesService) GetSalesOrderDetail(orderID string) (*GetSalesOrderDetail
 	return result, nil
 }
 
+// creditOverrideRoles are the roles allowed to approve paylater orders over the credit limits, admin always may
+var creditOverrideRoles = []string{"finance"}
+
+// CanOverrideCredit checks whether any of the caller's roles may approve a credit override
+func CanOverrideCredit(roles []string) bool {
+	for _, role := range roles {
+		if role == "admin" {
+			return true
+		}
+		for _, allowed := range creditOverrideRoles {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // CreateSalesOrder creates a new sales purchase-order with items and optional invoice creation
 func (s *SalesService) CreateSalesOrder(req CreateSalesOrderRequest, userID string) (*CreateSalesOrderResponse, error) {
+	// Orders are numbered and reported per branch
+	if req.BranchID == "" {
+		return nil, fmt.Errorf("pilih cabang aktif untuk membuat pesanan")
+	}
+
+	if req.CreditOverride && !req.CanOverrideCredit {
+		return nil, fmt.Errorf("anda tidak memiliki izin untuk menyetujui override kredit")
+	}
+
 	// Validate payment information
 	if req.PaymentMethod == "paylater" && req.PaymentDueDate == "" {
 		return nil, fmt.Errorf("tanggal jatuh tempo pembayaran diperlukan untuk metode pembayaran paylater")
@@ -92,6 +191,10 @@ func (s *SalesService) UpdateSalesOrder(req UpdateSalesOrderRequest) (*UpdateSal
 		}
 	}
 
+	if err := s.checkBranch(DocumentSalesOrder, req.ID, req.BranchID); err != nil {
+		return nil, err
+	}
+
 	return s.repo.UpdateSalesOrder(req)
 }
 
@@ -102,11 +205,15 @@ func (s *SalesService) CancelSalesOrder(req CancelSalesOrderRequest, userID stri
 		return fmt.Errorf("ID pesanan tidak boleh kosong")
 	}
 
+	if err := s.checkBranch(DocumentSalesOrder, req.SalesOrderID, req.BranchID); err != nil {
+		return err
+	}
+
 	return s.repo.CancelSalesOrder(req, userID)
 }
 
 // AddSalesOrderItem adds a new item to an existing sales purchase-order
-func (s *SalesService) AddSalesOrderItem(req AddSalesOrderItemRequest) (*UpdateAndCreateItemResponse, error) {
+func (s *SalesService) AddSalesOrderItem(req AddSalesOrderItemRequest, userID string) (*UpdateAndCreateItemResponse, error) {
 	// Validate basic parameters
 	if req.SalesOrderID == "" {
 		return nil, fmt.Errorf("ID pesanan tidak boleh kosong")
@@ -126,11 +233,19 @@ func (s *SalesService) AddSalesOrderItem(req AddSalesOrderItemRequest) (*UpdateA
 		return nil, fmt.Errorf("harga satuan tidak boleh negatif")
 	}
 
-	return s.repo.AddItemToSalesOrder(req)
+	if req.CreditOverride && !req.CanOverrideCredit {
+		return nil, fmt.Errorf("anda tidak memiliki izin untuk menyetujui override kredit")
+	}
+
+	if err := s.checkBranch(DocumentSalesOrder, req.SalesOrderID, req.BranchID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.AddItemToSalesOrder(req, userID)
 }
 
 // UpdateSalesOrderItem updates an existing item in a sales purchase-order
-func (s *SalesService) UpdateSalesOrderItem(req UpdateSalesOrderItemRequest) (*UpdateAndCreateItemResponse, error) {
+func (s *SalesService) UpdateSalesOrderItem(req UpdateSalesOrderItemRequest, userID string) (*UpdateAndCreateItemResponse, error) {
 	// Validate basic parameters
 	if req.SalesOrderID == "" || req.DetailID == "" {
 		return nil, fmt.Errorf("ID pesanan dan ID detail harus diisi")
@@ -146,11 +261,22 @@ func (s *SalesService) UpdateSalesOrderItem(req UpdateSalesOrderItemRequest) (*U
 		return nil, fmt.Errorf("harga satuan tidak boleh negatif")
 	}
 
-	return s.repo.UpdateSalesOrderItem(req)
+	if req.CreditOverride && !req.CanOverrideCredit {
+		return nil, fmt.Errorf("anda tidak memiliki izin untuk menyetujui override kredit")
+	}
+
+	if err := s.checkBranch(DocumentSalesOrder, req.SalesOrderID, req.BranchID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.UpdateSalesOrderItem(req, userID)
 }
 
 // DeleteSalesOrderItem removes an item from a sales purchase-order and restores inventory
 func (s *SalesService) DeleteSalesOrderItem(req DeleteSalesOrderItemRequest) error {
+	if err := s.checkBranch(DocumentSalesOrder, req.SalesOrderID, req.BranchID); err != nil {
+		return err
+	}
 	return s.repo.DeleteSalesOrderItem(req)
 }
 
@@ -165,8 +291,27 @@ func (s *SalesService) GetSalesInvoices(req GetSalesInvoicesRequest) ([]GetSales
 	return invoices, totalItems, nil
 }
 
+// GetSalesInvoiceItems retrieves the order lines and quantities covered by an invoice
+func (s *SalesService) GetSalesInvoiceItems(invoiceID, branchID string) ([]SalesInvoiceItemResponse, error) {
+	if err := s.checkBranch(DocumentSalesInvoice, invoiceID, branchID); err != nil {
+		return nil, err
+	}
+
+	items, err := s.repo.GetSalesInvoiceItems(invoiceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("faktur %w", ErrNotFound)
+		}
+		return nil, err
+	}
+	return items, nil
+}
+
 // CreateSalesInvoice creates a new invoice for a sales purchase-order
 func (s *SalesService) CreateSalesInvoice(req CreateSalesInvoiceRequest, userID string) (*CreateSalesInvoiceResponse, error) {
+	if err := s.checkBranch(DocumentSalesOrder, req.SalesOrderID, req.BranchID); err != nil {
+		return nil, err
+	}
 
 	response, err := s.repo.CreateSalesInvoice(req, userID, nil)
 	if err != nil {
@@ -183,6 +328,10 @@ func (s *SalesService) CancelSalesInvoice(req CancelSalesInvoiceRequest, userID
 		return errors.New("ID faktur wajib diisi")
 	}
 
+	if err := s.checkBranch(DocumentSalesInvoice, req.InvoiceID, req.BranchID); err != nil {
+		return err
+	}
+
 	// Call repository to cancel invoice
 	err := s.repo.CancelSalesInvoice(req, userID)
 	if err != nil {
@@ -194,6 +343,10 @@ func (s *SalesService) CancelSalesInvoice(req CancelSalesInvoiceRequest, userID
 
 // ReturnInvoiceItems processes returns for invoice items
 func (s *SalesService) ReturnInvoiceItems(req ReturnItemRequest, userID string) (*ReturnInvoiceItemsResponse, error) {
+	if err := s.checkBranch(DocumentSalesOrder, req.SalesOrderID, req.BranchID); err != nil {
+		return nil, err
+	}
+
 	// Call repository to process returns
 	response, err := s.repo.ReturnItemFromSalesOrder(req, userID)
 	if err != nil {
@@ -205,6 +358,10 @@ func (s *SalesService) ReturnInvoiceItems(req ReturnItemRequest, userID string)
 
 // CancelReturn cancels a previously processed return
 func (s *SalesService) CancelReturn(req CancelReturnRequest, userID string) error {
+	if err := s.checkBranch(DocumentSalesReturn, req.ReturnID, req.BranchID); err != nil {
+		return err
+	}
+
 	// Call repository to cancel return
 	err := s.repo.CancelSalesOrderReturn(req, userID)
 	if err != nil {
@@ -214,6 +371,27 @@ func (s *SalesService) CancelReturn(req CancelReturnRequest, userID string) erro
 	return nil
 }
 
+// GetDeliveryNotes returns a paginated list of delivery notes
+func (s *SalesService) GetDeliveryNotes(req GetDeliveryNotesRequest) ([]GetDeliveryNotesResponse, int, error) {
+	return s.repo.GetDeliveryNotes(req)
+}
+
+// GetDeliveryNoteItems retrieves the order lines and quantities carried by a delivery note
+func (s *SalesService) GetDeliveryNoteItems(deliveryNoteID, branchID string) ([]DeliveryNoteItem, error) {
+	if err := s.checkBranch(DocumentDeliveryNote, deliveryNoteID, branchID); err != nil {
+		return nil, err
+	}
+
+	items, err := s.repo.GetDeliveryNoteItems(deliveryNoteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("surat jalan %w", ErrNotFound)
+		}
+		return nil, err
+	}
+	return items, nil
+}
+
 // CreateDeliveryNote creates a new delivery note for a sales invoice
 func (s *SalesService) CreateDeliveryNote(req CreateDeliveryNoteRequest, userID string) (*CreateDeliveryNoteResponse, error) {
 	// Validate request
@@ -238,6 +416,10 @@ func (s *SalesService) CreateDeliveryNote(req CreateDeliveryNoteRequest, userID
 		}
 	}
 
+	if err := s.checkBranch(DocumentSalesInvoice, req.SalesInvoiceID, req.BranchID); err != nil {
+		return nil, err
+	}
+
 	// Call repository to create delivery note
 	response, err := s.repo.CreateDeliveryNote(req, userID)
 	if err != nil {
@@ -254,6 +436,10 @@ func (s *SalesService) CancelDeliveryNote(req CancelDeliveryNoteRequest, userID
 		return errors.New("ID surat jalan wajib diisi")
 	}
 
+	if err := s.checkBranch(DocumentDeliveryNote, req.DeliveryNoteID, req.BranchID); err != nil {
+		return err
+	}
+
 	// Call repository to cancel delivery note
 	err := s.repo.CancelDeliveryNote(req, userID)
 	if err != nil {
@@ -0,0 +1,60 @@
+package search
+
+// Result types returned by the global search
+const (
+	TypeSalesOrder    = "sales_order"
+	TypeSalesInvoice  = "sales_invoice"
+	TypeDeliveryNote  = "delivery_note"
+	TypePurchaseOrder = "purchase_order"
+	TypeBatch         = "batch"
+	TypeCustomer      = "customer"
+	TypeSupplier      = "supplier"
+	TypeProduct       = "product"
+	TypeEmployee      = "employee"
+)
+
+// typeRoles maps each result type to the roles allowed to see it, admin can always see everything
+var typeRoles = map[string][]string{
+	TypeSalesOrder:    {"sales"},
+	TypeSalesInvoice:  {"sales"},
+	TypeDeliveryNote:  {"sales"},
+	TypePurchaseOrder: {"purchase"},
+	TypeBatch:         {"inventory", "sales"},
+	TypeCustomer:      {"sales"},
+	TypeSupplier:      {"purchase"},
+	TypeProduct:       {"inventory", "sales", "purchase"},
+	TypeEmployee:      {"hr"},
+}
+
+// searchTypes is the order in which result groups are returned
+var searchTypes = []string{
+	TypeSalesOrder,
+	TypeSalesInvoice,
+	TypeDeliveryNote,
+	TypePurchaseOrder,
+	TypeBatch,
+	TypeCustomer,
+	TypeSupplier,
+	TypeProduct,
+	TypeEmployee,
+}
+
+// GlobalSearchRequest holds query parameters for the global search
+type GlobalSearchRequest struct {
+	Query    string `json:"q" validate:"required,min=2,max=100"`
+	Limit    int    `json:"limit" validate:"omitempty,min=1,max=20"`
+	BranchID string `json:"-"`
+}
+
+// GlobalSearchResult is a single typed search hit.
+// ID is the record to open, ParentType and ParentID point to the owning document for nested records.
+type GlobalSearchResult struct {
+	Type        string  `json:"type"`
+	ID          string  `json:"id"`
+	Code        string  `json:"code"`
+	Title       string  `json:"title"`
+	Description string  `json:"description,omitempty"`
+	ParentType  *string `json:"parent_type,omitempty"`
+	ParentID    *string `json:"parent_id,omitempty"`
+	Score       float64 `json:"score"`
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"database/sql"
+	"fmt"
+	"sinartimur-go/utils"
+	"strings"
+)
+
+// SearchRepository defines the interface for global search data operations
+type SearchRepository interface {
+	Search(resultType string, query string, limit int, branchID string) ([]GlobalSearchResult, error)
+}
+
+// SearchRepositoryImpl implements the SearchRepository interface
+type SearchRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewSearchRepository creates a new search repository instance
+func NewSearchRepository(db *sql.DB) SearchRepository {
+	return &SearchRepositoryImpl{db: db}
+}
+
+// searchQueries holds one query per result type.
+// Every query selects Id, Code, Title, Description, Parent_Id and Score, where
+// $1 is the escaped ILIKE pattern, $2 is the raw search text and $3 is the limit.
+// Cancelled documents are left out, a serial number should lead to the document that still counts.
+var searchQueries = map[string]string{
+	TypeSalesOrder: `
+		Select So.Id, So.Serial_Id, Coalesce(C.Name, '-'), So.Status, Null,
+		       Case When Upper(So.Serial_Id) = Upper($2) Then 2
+		            Else Greatest(Similarity(So.Serial_Id, $2), Coalesce(Word_Similarity($2, C.Name), 0)) End As Score
+		From Sales_Order So
+		Left Join Customer C On So.Customer_Id = C.Id
+		Where So.Cancelled_At Is Null And (So.Serial_Id ILIKE $1 Or C.Name ILIKE $1 Or $2 <% C.Name)`,
+	TypeSalesInvoice: `
+		Select Si.Id, Si.Serial_Id, Coalesce(C.Name, '-'), So.Serial_Id, So.Id,
+		       Case When Upper(Si.Serial_Id) = Upper($2) Then 2 Else Similarity(Si.Serial_Id, $2) End As Score
+		From Sales_Invoice Si
+		Join Sales_Order So On Si.Sales_Order_Id = So.Id
+		Left Join Customer C On So.Customer_Id = C.Id
+		Where Si.Cancelled_At Is Null And Si.Serial_Id ILIKE $1`,
+	TypeDeliveryNote: `
+		Select Dn.Id, Dn.Serial_Id, Dn.Recipient_Name, So.Serial_Id, So.Id,
+		       Case When Upper(Dn.Serial_Id) = Upper($2) Then 2 Else Similarity(Dn.Serial_Id, $2) End As Score
+		From Delivery_Note Dn
+		Join Sales_Order So On Dn.Sales_Order_Id = So.Id
+		Where Dn.Cancelled_At Is Null And Dn.Serial_Id ILIKE $1`,
+	TypePurchaseOrder: `
+		Select Po.Id, Po.Serial_Id, Coalesce(S.Name, '-'), Po.Status, Null,
+		       Case When Upper(Po.Serial_Id) = Upper($2) Then 2
+		            Else Greatest(Similarity(Po.Serial_Id, $2), Coalesce(Word_Similarity($2, S.Name), 0)) End As Score
+		From Purchase_Order Po
+		Left Join Supplier S On Po.Supplier_Id = S.Id
+		Where Po.Cancelled_At Is Null And (Po.Serial_Id ILIKE $1 Or S.Name ILIKE $1 Or $2 <% S.Name)`,
+	TypeBatch: `
+		Select Pb.Id, Pb.Sku, P.Name,
+		       Concat('Stok: ', Pb.Current_Quantity, ', tersedia: ',
+		              Pb.Current_Quantity - Coalesce((Select Sum(St.Reserved) From Batch_Storage_Stock St Where St.Batch_Id = Pb.Id), 0)),
+		       P.Id,
+		       Case When Upper(Pb.Sku) = Upper($2) Then 2 Else Similarity(Pb.Sku, $2) End As Score
+		From Product_Batch Pb
+		Join Product P On Pb.Product_Id = P.Id
+		Where Pb.Sku ILIKE $1`,
+	TypeCustomer: `
+		Select Id, '', Name, Coalesce(Address, ''), Null,
+		       Greatest(Word_Similarity($2, Name), Coalesce(Similarity(Telephone, $2), 0)) As Score
+		From Customer
+		Where Deleted_At Is Null And (Name ILIKE $1 Or Telephone ILIKE $1 Or $2 <% Name)`,
+	TypeSupplier: `
+		Select Id, '', Name, Coalesce(Address, ''), Null,
+		       Greatest(Word_Similarity($2, Name), Coalesce(Similarity(Telephone, $2), 0)) As Score
+		From Supplier
+		Where Deleted_At Is Null And (Name ILIKE $1 Or Telephone ILIKE $1 Or $2 <% Name)`,
+	TypeProduct: `
+		Select P.Id, '', P.Name, Concat(C.Name, ' / ', U.Name), Null,
+		       Word_Similarity($2, P.Name) As Score
+		From Product P
+		Join Category C On P.Category_Id = C.Id
+		Join Unit U On P.Unit_Id = U.Id
+		Where P.Deleted_At Is Null And (P.Name ILIKE $1 Or $2 <% P.Name)`,
+	TypeEmployee: `
+		Select Id, Nik, Name, Position, Null,
+		       Case When Nik = $2 Then 2 Else Word_Similarity($2, Name) End As Score
+		From Employee
+		Where Deleted_At Is Null And (Name ILIKE $1 Or Nik ILIKE $1 Or $2 <% Name)`,
+}
+
+// branchColumns holds the branch column of result types that belong to a branch
+var branchColumns = map[string]string{
+	TypeSalesOrder:    "So.Branch_Id",
+	TypeSalesInvoice:  "So.Branch_Id",
+	TypeDeliveryNote:  "So.Branch_Id",
+	TypePurchaseOrder: "Po.Branch_Id",
+}
+
+// parentTypes maps result types to the document type their Parent_Id points to
+var parentTypes = map[string]string{
+	TypeSalesInvoice: TypeSalesOrder,
+	TypeDeliveryNote: TypeSalesOrder,
+	TypeBatch:        TypeProduct,
+}
+
+// Search runs the query for a single result type, documents are limited to the branch when one is given
+func (r *SearchRepositoryImpl) Search(resultType string, query string, limit int, branchID string) ([]GlobalSearchResult, error) {
+	baseQuery, ok := searchQueries[resultType]
+	if !ok {
+		return nil, fmt.Errorf("tipe pencarian tidak dikenal: %s", resultType)
+	}
+
+	query = strings.TrimSpace(query)
+	params := []interface{}{"%" + utils.EscapeLike(query) + "%", query, limit}
+	if column, ok := branchColumns[resultType]; ok && branchID != "" {
+		baseQuery += " And " + column + " = $4"
+		params = append(params, branchID)
+	}
+
+	rows, err := r.db.Query(baseQuery+" Order By Score Desc Limit $3", params...)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mencari %s: %w", resultType, err)
+	}
+	defer rows.Close()
+
+	var results []GlobalSearchResult
+	for rows.Next() {
+		var result GlobalSearchResult
+		var parentID sql.NullString
+		if errScan := rows.Scan(&result.ID, &result.Code, &result.Title, &result.Description, &parentID, &result.Score); errScan != nil {
+			return nil, fmt.Errorf("gagal membaca hasil pencarian %s: %w", resultType, errScan)
+		}
+
+		result.Type = resultType
+		if parentID.Valid {
+			parentType := parentTypes[resultType]
+			result.ParentType = &parentType
+			result.ParentID = &parentID.String
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,66 @@
+package search
+
+import (
+	"net/http"
+	"sinartimur-go/pkg/dto"
+	"sort"
+)
+
+// defaultSearchLimit is the number of results returned per type when no limit is given
+const defaultSearchLimit = 5
+
+// SearchService is the service for the global search
+type SearchService struct {
+	repo SearchRepository
+}
+
+// NewSearchService creates a new instance of SearchService
+func NewSearchService(repo SearchRepository) *SearchService {
+	return &SearchService{repo: repo}
+}
+
+// canSearchType checks whether any of the caller's roles may see the result type
+func canSearchType(resultType string, roles []string) bool {
+	for _, role := range roles {
+		if role == "admin" {
+			return true
+		}
+		for _, allowed := range typeRoles[resultType] {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Search looks up the query across every document type the caller is allowed to see
+func (s *SearchService) Search(req GlobalSearchRequest, roles []string) ([]GlobalSearchResult, *dto.APIError) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	results := []GlobalSearchResult{}
+	for _, resultType := range searchTypes {
+		// Skip types hidden from the caller's roles
+		if !canSearchType(resultType, roles) {
+			continue
+		}
+
+		typeResults, err := s.repo.Search(resultType, req.Query, limit, req.BranchID)
+		if err != nil {
+			return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+				"general": "Gagal melakukan pencarian",
+			})
+		}
+		results = append(results, typeResults...)
+	}
+
+	// Best matches first, exact serial or SKU hits score highest
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results, nil
+}
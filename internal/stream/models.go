@@ -0,0 +1,71 @@
+package stream
+
+import (
+	"sinartimur-go/pkg/event"
+	"strings"
+)
+
+// Topics a client can subscribe to
+const (
+	TopicStock    = "stock"
+	TopicSales    = "sales"
+	TopicPurchase = "purchase"
+	TopicReport   = "report"
+)
+
+// Topics lists every topic in the order they are documented
+var Topics = []string{TopicStock, TopicSales, TopicPurchase, TopicReport}
+
+// topicRoles maps each topic to the roles allowed to receive it, admin can always receive everything
+var topicRoles = map[string][]string{
+	TopicStock:    {"inventory", "sales", "purchase"},
+	TopicSales:    {"sales"},
+	TopicPurchase: {"purchase"},
+	TopicReport:   {"inventory", "finance"},
+}
+
+// viewRoles narrows the report topic to the roles that own each materialized view
+var viewRoles = map[string][]string{
+	"inventory_log_view":           {"inventory"},
+	"finance_transaction_log_view": {"finance"},
+}
+
+// topicOf returns the topic an event type is published on, or an empty string if it is not streamed
+func topicOf(eventType string) string {
+	switch {
+	case eventType == event.StockChanged || eventType == event.BatchMoved:
+		return TopicStock
+	case strings.HasPrefix(eventType, "sales_"), strings.HasPrefix(eventType, "delivery_note."):
+		return TopicSales
+	case strings.HasPrefix(eventType, "purchase_"):
+		return TopicPurchase
+	case eventType == event.ViewRefreshed:
+		return TopicReport
+	}
+	return ""
+}
+
+// StreamRequest holds query parameters for opening an event stream
+type StreamRequest struct {
+	Topics    []string `json:"topics" validate:"omitempty,dive,oneof=stock sales purchase report"`
+	StorageID string   `json:"storage_id" validate:"omitempty,uuid"`
+	ProductID string   `json:"product_id" validate:"omitempty,uuid"`
+	BranchID  string   `json:"-"`
+}
+
+// StreamEvent is an outbox event with the branches of the storages and orders it refers to
+type StreamEvent struct {
+	event.Event
+	Branches []string
+}
+
+// Client is a single connected stream receiving the events matching its filter
+type Client struct {
+	Events    chan event.Event
+	topics    map[string]bool
+	roles     []string
+	storageID string
+	productID string
+	branchID  string // Empty for admins watching every branch
+	closed    bool
+}
@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// StreamRepository defines the interface for reading the events announced to the stream
+type StreamRepository interface {
+	GetEvent(id string) (*StreamEvent, error)
+}
+
+// StreamRepositoryImpl implements the StreamRepository interface
+type StreamRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewStreamRepository creates a new stream repository instance
+func NewStreamRepository(db *sql.DB) StreamRepository {
+	return &StreamRepositoryImpl{db: db}
+}
+
+// GetEvent loads an outbox event by its id with the branches of the storages, sales order or purchase order in its data.
+// Sales events name their order as sales_order_id, except a created order which is the payload itself.
+func (r *StreamRepositoryImpl) GetEvent(id string) (*StreamEvent, error) {
+	var payload []byte
+	var evt StreamEvent
+	err := r.db.QueryRow(`
+		Select O.Payload, Array(
+			Select Distinct B.Branch_Id::text From (
+				Select S.Branch_Id From Storage S
+				Where S.Id::text In (D.Data->>'storage_id', D.Data->>'source_storage_id', D.Data->>'target_storage_id')
+				Union All
+				Select So.Branch_Id From Sales_Order So
+				Where So.Id::text = Coalesce(D.Data->>'sales_order_id', Case When O.Event_Type = 'sales_order.created' Then D.Data->>'id' End)
+				Union All
+				Select Po.Branch_Id From Purchase_Order Po
+				Where Po.Id::text = D.Data->>'purchase_order_id'
+			) B
+			Where B.Branch_Id Is Not Null
+		)
+		From Outbox_Event O
+		Cross Join Lateral (Select O.Payload->'data' As Data) D
+		Where O.Id = $1`, id).Scan(&payload, pq.Array(&evt.Branches))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(payload, &evt.Event); err != nil {
+		return nil, fmt.Errorf("gagal membaca event: %w", err)
+	}
+	return &evt, nil
+}
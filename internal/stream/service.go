@@ -0,0 +1,207 @@
+package stream
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sinartimur-go/internal/outbox"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/pkg/event"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// clientBuffer is the number of events queued per client before it is considered too slow and dropped
+	clientBuffer = 64
+	// listenerPingInterval checks an idle listener connection so a dead one is noticed and reopened
+	listenerPingInterval = 90 * time.Second
+)
+
+// StreamService fans out outbox events to the server-sent event clients connected to this instance
+type StreamService struct {
+	repo    StreamRepository
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+}
+
+// NewStreamService creates a new instance of StreamService
+func NewStreamService(repo StreamRepository) *StreamService {
+	return &StreamService{repo: repo, clients: make(map[*Client]struct{})}
+}
+
+// Start listens for committed outbox events in the background. Every instance listens on its own,
+// unlike the outbox dispatcher which hands each event to a single instance.
+func (s *StreamService) Start(listener *pq.Listener) error {
+	if err := listener.Listen(outbox.NotifyChannel); err != nil {
+		return fmt.Errorf("gagal mendengarkan event: %w", err)
+	}
+
+	go func() {
+		for {
+			select {
+			case notification := <-listener.Notify:
+				// A nil notification means the connection was reopened, clients resync on reconnect
+				if notification == nil {
+					continue
+				}
+				s.forward(notification.Extra)
+			case <-time.After(listenerPingInterval):
+				go func() {
+					if err := listener.Ping(); err != nil {
+						log.Printf("stream: listener ping failed: %v", err)
+					}
+				}()
+			}
+		}
+	}()
+	return nil
+}
+
+// forward loads an announced event and pushes it to the matching clients
+func (s *StreamService) forward(eventID string) {
+	evt, err := s.repo.GetEvent(eventID)
+	if err != nil {
+		log.Printf("stream: failed to load event %s: %v", eventID, err)
+		return
+	}
+	s.HandleEvent(*evt)
+}
+
+// hasRole checks whether any of the caller's roles is admin or one of the allowed roles
+func hasRole(roles []string, allowed []string) bool {
+	for _, role := range roles {
+		if role == "admin" {
+			return true
+		}
+		for _, a := range allowed {
+			if role == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Subscribe registers a client for the requested topics, defaulting to every topic the caller's roles allow
+func (s *StreamService) Subscribe(req StreamRequest, roles []string) (*Client, *dto.APIError) {
+	topics := req.Topics
+	if len(topics) == 0 {
+		for _, topic := range Topics {
+			if hasRole(roles, topicRoles[topic]) {
+				topics = append(topics, topic)
+			}
+		}
+	}
+	if len(topics) == 0 {
+		return nil, dto.NewAPIError(http.StatusUnauthorized, map[string]string{
+			"general": "Akses Tidak Diizinkan",
+		})
+	}
+
+	client := &Client{
+		Events:    make(chan event.Event, clientBuffer),
+		topics:    make(map[string]bool),
+		roles:     roles,
+		storageID: req.StorageID,
+		productID: req.ProductID,
+		branchID:  req.BranchID,
+	}
+	for _, topic := range topics {
+		if !hasRole(roles, topicRoles[topic]) {
+			return nil, dto.NewAPIError(http.StatusUnauthorized, map[string]string{
+				"topics": fmt.Sprintf("Akses topik %s tidak diizinkan", topic),
+			})
+		}
+		client.topics[topic] = true
+	}
+
+	s.mu.Lock()
+	s.clients[client] = struct{}{}
+	s.mu.Unlock()
+	return client, nil
+}
+
+// Unsubscribe removes a client and closes its channel
+func (s *StreamService) Unsubscribe(client *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(client)
+}
+
+// remove must be called with the write lock held
+func (s *StreamService) remove(client *Client) {
+	if client.closed {
+		return
+	}
+	delete(s.clients, client)
+	client.closed = true
+	close(client.Events)
+}
+
+// HandleEvent pushes the event to every matching client, clients that miss events reload their data on reconnect
+func (s *StreamService) HandleEvent(evt StreamEvent) {
+	topic := topicOf(evt.Type)
+	if topic == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client := range s.clients {
+		if !client.matches(topic, evt) {
+			continue
+		}
+		select {
+		case client.Events <- evt.Event:
+		default:
+			// Drop slow clients instead of blocking the dispatcher, they reconnect and resync
+			log.Printf("stream: client buffer full, disconnecting")
+			s.remove(client)
+		}
+	}
+}
+
+// matches checks the client's topics, roles, branch and storage or product filter against the event
+func (c *Client) matches(topic string, evt StreamEvent) bool {
+	if !c.topics[topic] {
+		return false
+	}
+
+	// Report refreshes only name the view, the other events are kept within the branches they touch
+	if c.branchID != "" && topic != TopicReport && !containsBranch(evt.Branches, c.branchID) {
+		return false
+	}
+
+	data, _ := evt.Data.(map[string]interface{})
+
+	switch topic {
+	case TopicReport:
+		// Each view is only visible to the role that owns it
+		view, _ := data["view_name"].(string)
+		return hasRole(c.roles, viewRoles[view])
+	case TopicStock:
+		if c.storageID != "" &&
+			data["storage_id"] != c.storageID &&
+			data["source_storage_id"] != c.storageID &&
+			data["target_storage_id"] != c.storageID {
+			return false
+		}
+		if c.productID != "" && data["product_id"] != c.productID {
+			return false
+		}
+	}
+	return true
+}
+
+// containsBranch checks whether the branch is one of the event's branches
+func containsBranch(branches []string, branchID string) bool {
+	for _, b := range branches {
+		if b == branchID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package trash
+
+import "sinartimur-go/utils"
+
+// Master data resources that can be restored from or purged out of the trash
+const (
+	ResourceCategory = "category"
+	ResourceUnit     = "unit"
+	ResourceProduct  = "product"
+	ResourceStorage  = "storage"
+	ResourceCustomer = "customer"
+	ResourceSupplier = "supplier"
+	ResourceEmployee = "employee"
+)
+
+// Resources lists every resource with a trash
+var Resources = []string{
+	ResourceCategory,
+	ResourceUnit,
+	ResourceProduct,
+	ResourceStorage,
+	ResourceCustomer,
+	ResourceSupplier,
+	ResourceEmployee,
+}
+
+// TrashItem is a soft-deleted master data record
+type TrashItem struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	DeletedAt   string `json:"deleted_at"`
+}
+
+// GetTrashRequest holds query parameters for listing deleted records
+type GetTrashRequest struct {
+	Resource string `json:"-"`
+	Search   string `json:"search" validate:"omitempty,max=100"`
+	BranchID string `json:"-"`
+	utils.PaginationParameter
+}
+
+// TrashReference counts the records still pointing at a deleted record
+type TrashReference struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,262 @@
+package trash
+
+import (
+	"database/sql"
+	"fmt"
+	"sinartimur-go/utils"
+)
+
+// TrashRepository defines operations on soft-deleted master data
+type TrashRepository interface {
+	GetAll(req GetTrashRequest) ([]TrashItem, int, error)
+	GetByID(resourceName, id, branchID string) (*TrashItem, error)
+	GetConflicts(resourceName, id string) ([]string, error)
+	GetReferences(resourceName, id string) ([]TrashReference, error)
+	Restore(resourceName, id string) error
+	Purge(resourceName, id string) error
+}
+
+// TrashRepositoryImpl implements TrashRepository
+type TrashRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewTrashRepository creates a new trash repository instance
+func NewTrashRepository(db *sql.DB) TrashRepository {
+	return &TrashRepositoryImpl{db: db}
+}
+
+// reference counts the rows of another table that point at the record in $1
+type reference struct {
+	label string
+	query string
+}
+
+// resource describes how a master data table is shown and checked in the trash
+type resource struct {
+	table       string
+	description string   // SQL expression shown as the description
+	conflicts   []string // columns that must stay unique among live records
+	branch      string   // branch column, empty for data shared by every branch
+	references  []reference
+}
+
+// resources holds the trash configuration per resource.
+// Purge is blocked by any reference, the foreign keys cascade and would take documents or batches with them.
+var resources = map[string]resource{
+	ResourceCategory: {
+		table:       "Category",
+		description: "Coalesce(Description, '')",
+		conflicts:   []string{"Name"},
+		references: []reference{
+			{label: "produk", query: "Select Count(*) From Product Where Category_Id = $1"},
+		},
+	},
+	ResourceUnit: {
+		table:       "Unit",
+		description: "Coalesce(Description, '')",
+		conflicts:   []string{"Name"},
+		references: []reference{
+			{label: "produk", query: "Select Count(*) From Product Where Unit_Id = $1"},
+		},
+	},
+	ResourceProduct: {
+		table:       "Product",
+		description: "Coalesce(Description, '')",
+		conflicts:   []string{"Name"},
+		references: []reference{
+			{label: "batch produk", query: "Select Count(*) From Product_Batch Where Product_Id = $1"},
+			{label: "detail pesanan pembelian", query: "Select Count(*) From Purchase_Order_Detail Where Product_Id = $1"},
+			{label: "item penawaran penjualan", query: "Select Count(*) From Sales_Quotation_Item Where Product_Id = $1"},
+			{label: "item daftar harga", query: "Select Count(*) From Price_List_Item Where Product_Id = $1"},
+		},
+	},
+	ResourceStorage: {
+		table:       "Storage",
+		description: "Location",
+		conflicts:   []string{"Name"},
+		branch:      "Branch_Id",
+		references: []reference{
+			{label: "stok batch di gudang", query: "Select Count(*) From Batch_Storage Where Storage_Id = $1"},
+		},
+	},
+	ResourceCustomer: {
+		table:       "Customer",
+		description: "Coalesce(Address, '')",
+		conflicts:   []string{"Name"},
+		references: []reference{
+			{label: "pesanan penjualan", query: "Select Count(*) From Sales_Order Where Customer_Id = $1"},
+			{label: "penawaran penjualan", query: "Select Count(*) From Sales_Quotation Where Customer_Id = $1"},
+			{label: "pembayaran pelanggan", query: "Select Count(*) From Customer_Payment Where Customer_Id = $1"},
+			{label: "nota kredit", query: "Select Count(*) From Credit_Note Where Customer_Id = $1"},
+		},
+	},
+	ResourceSupplier: {
+		table:       "Supplier",
+		description: "Coalesce(Address, '')",
+		conflicts:   []string{"Name"},
+		references: []reference{
+			{label: "pesanan pembelian", query: "Select Count(*) From Purchase_Order Where Supplier_Id = $1"},
+		},
+	},
+	ResourceEmployee: {
+		table:       "Employee",
+		description: "Nik || ' - ' || Position",
+		conflicts:   []string{"Nik", "Phone"},
+		references: []reference{
+			{label: "gaji", query: "Select Count(*) From Wage Where Employee_Id = $1"},
+			{label: "absensi", query: "Select Count(*) From Attendance Where Employee_Id = $1"},
+		},
+	},
+}
+
+// trashSortColumns lists the columns deleted records can be sorted by
+var trashSortColumns = map[string]string{
+	"name":       "Name",
+	"deleted_at": "Deleted_At",
+}
+
+// getResource returns the configuration of a resource
+func getResource(resourceName string) (resource, error) {
+	res, ok := resources[resourceName]
+	if !ok {
+		return resource{}, fmt.Errorf("resource tidak dikenal: %s", resourceName)
+	}
+	return res, nil
+}
+
+// GetAll fetches the deleted records of a resource with pagination
+func (r *TrashRepositoryImpl) GetAll(req GetTrashRequest) ([]TrashItem, int, error) {
+	res, err := getResource(req.Resource)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	qb := utils.NewQueryBuilder(fmt.Sprintf("Select Id, Name, %s, Deleted_At From %s Where Deleted_At Is Not Null", res.description, res.table))
+	qb.AddSearch(req.Search, "Name")
+	if res.branch != "" {
+		qb.AddFilter(res.branch+" =", req.BranchID)
+	}
+
+	var totalItems int
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Trash", qb.Query.String())
+	if err = r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems); err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung data terhapus: %w", err)
+	}
+
+	if column, ok := trashSortColumns[req.SortBy]; ok && req.SortOrder != "" {
+		qb.Query.WriteString(fmt.Sprintf(" Order By %s %s", column, req.SortOrder))
+	} else {
+		qb.Query.WriteString(" Order By Deleted_At Desc")
+	}
+	qb.AddPagination(req.PaginationParameter)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil data terhapus: %w", err)
+	}
+	defer rows.Close()
+
+	items := []TrashItem{}
+	for rows.Next() {
+		var item TrashItem
+		if errScan := rows.Scan(&item.ID, &item.Name, &item.Description, &item.DeletedAt); errScan != nil {
+			return nil, 0, fmt.Errorf("gagal membaca data terhapus: %w", errScan)
+		}
+		items = append(items, item)
+	}
+
+	return items, totalItems, rows.Err()
+}
+
+// GetByID fetches a deleted record, records of another branch are not found
+func (r *TrashRepositoryImpl) GetByID(resourceName, id, branchID string) (*TrashItem, error) {
+	res, err := getResource(resourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	qb := utils.NewQueryBuilder(fmt.Sprintf("Select Id, Name, %s, Deleted_At From %s Where Deleted_At Is Not Null", res.description, res.table))
+	qb.AddFilter("Id =", id)
+	if res.branch != "" {
+		qb.AddFilter(res.branch+" =", branchID)
+	}
+
+	var item TrashItem
+	query, params := qb.Build()
+	err = r.db.QueryRow(query, params...).Scan(&item.ID, &item.Name, &item.Description, &item.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetConflicts returns the unique columns a live record already uses, names differing only in case count as taken
+func (r *TrashRepositoryImpl) GetConflicts(resourceName, id string) ([]string, error) {
+	res, err := getResource(resourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for _, column := range res.conflicts {
+		var exists bool
+		query := fmt.Sprintf(`
+			Select Exists(
+				Select 1 From %[1]s
+				Where Deleted_At Is Null And Id <> $1 And Lower(%[2]s) = (Select Lower(%[2]s) From %[1]s Where Id = $1)
+			)`, res.table, column)
+		if err = r.db.QueryRow(query, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("gagal memeriksa duplikasi data: %w", err)
+		}
+		if exists {
+			conflicts = append(conflicts, column)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// GetReferences returns the records still pointing at a deleted record
+func (r *TrashRepositoryImpl) GetReferences(resourceName, id string) ([]TrashReference, error) {
+	res, err := getResource(resourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var references []TrashReference
+	for _, ref := range res.references {
+		var count int
+		if err = r.db.QueryRow(ref.query, id).Scan(&count); err != nil {
+			return nil, fmt.Errorf("gagal memeriksa referensi %s: %w", ref.label, err)
+		}
+		if count > 0 {
+			references = append(references, TrashReference{Label: ref.label, Count: count})
+		}
+	}
+
+	return references, nil
+}
+
+// Restore clears the deletion mark of a record
+func (r *TrashRepositoryImpl) Restore(resourceName, id string) error {
+	res, err := getResource(resourceName)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(fmt.Sprintf("Update %s Set Deleted_At = Null, Updated_At = Now() Where Id = $1 And Deleted_At Is Not Null", res.table), id)
+	return err
+}
+
+// Purge permanently deletes a record that is in the trash
+func (r *TrashRepositoryImpl) Purge(resourceName, id string) error {
+	res, err := getResource(resourceName)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(fmt.Sprintf("Delete From %s Where Id = $1 And Deleted_At Is Not Null", res.table), id)
+	return err
+}
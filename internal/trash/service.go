@@ -0,0 +1,115 @@
+package trash
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sinartimur-go/pkg/dto"
+	"strings"
+)
+
+// TrashService is the service for listing, restoring and purging soft-deleted master data
+type TrashService struct {
+	repo TrashRepository
+}
+
+// NewTrashService creates a new instance of TrashService
+func NewTrashService(repo TrashRepository) *TrashService {
+	return &TrashService{repo: repo}
+}
+
+// conflictMessages holds the restore error per unique column
+var conflictMessages = map[string]string{
+	"Name":  "Nama sudah digunakan oleh data aktif",
+	"Nik":   "NIK sudah digunakan oleh karyawan aktif",
+	"Phone": "Nomor telepon sudah digunakan oleh karyawan aktif",
+}
+
+// GetTrash fetches the deleted records of a resource with pagination
+func (s *TrashService) GetTrash(req GetTrashRequest) ([]TrashItem, int, *dto.APIError) {
+	items, totalItems, err := s.repo.GetAll(req)
+	if err != nil {
+		return nil, 0, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data terhapus",
+		})
+	}
+	return items, totalItems, nil
+}
+
+// getDeleted fetches a deleted record or the matching API error
+func (s *TrashService) getDeleted(resourceName, id, branchID string) (*TrashItem, *dto.APIError) {
+	item, err := s.repo.GetByID(resourceName, id, branchID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Data tidak ditemukan di tempat sampah",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data terhapus",
+		})
+	}
+	return item, nil
+}
+
+// RestoreItem restores a deleted record unless a live record already uses its name
+func (s *TrashService) RestoreItem(resourceName, id, branchID string) (*TrashItem, *dto.APIError) {
+	item, apiErr := s.getDeleted(resourceName, id, branchID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	conflicts, err := s.repo.GetConflicts(resourceName, id)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa duplikasi data",
+		})
+	}
+	if len(conflicts) > 0 {
+		details := make(map[string]string)
+		for _, column := range conflicts {
+			details[strings.ToLower(column)] = conflictMessages[column]
+		}
+		return nil, dto.NewAPIError(http.StatusConflict, details)
+	}
+
+	if err = s.repo.Restore(resourceName, id); err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memulihkan data",
+		})
+	}
+
+	item.DeletedAt = ""
+	return item, nil
+}
+
+// PurgeItem permanently deletes a record from the trash, blocked while other records still reference it
+func (s *TrashService) PurgeItem(resourceName, id, branchID string) *dto.APIError {
+	if _, apiErr := s.getDeleted(resourceName, id, branchID); apiErr != nil {
+		return apiErr
+	}
+
+	references, err := s.repo.GetReferences(resourceName, id)
+	if err != nil {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal memeriksa referensi data",
+		})
+	}
+	if len(references) > 0 {
+		var usages []string
+		for _, ref := range references {
+			usages = append(usages, fmt.Sprintf("%d %s", ref.Count, ref.Label))
+		}
+		return dto.NewAPIError(http.StatusConflict, map[string]string{
+			"general": "Data masih digunakan oleh " + strings.Join(usages, ", "),
+		})
+	}
+
+	if err = s.repo.Purge(resourceName, id); err != nil {
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menghapus data secara permanen",
+		})
+	}
+	return nil
+}
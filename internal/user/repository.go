@@ -101,7 +101,7 @@ func (r *userRepositoryImpl) GetAll(req GetAllUserRequest) ([]*GetUserResponse,
 	}
 
 	// Add pagination
-	queryBuilder.AddPagination(req.PageSize, req.Page)
+	queryBuilder.AddPagination(req.PaginationParameter)
 
 	// Execute final query
 	query, params := queryBuilder.Build()
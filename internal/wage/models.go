@@ -1,6 +1,9 @@
 package wage
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"sinartimur-go/utils"
+)
 
 type Wage struct {
 	ID          uuid.UUID `json:"id"`
@@ -64,6 +67,9 @@ type GetWageRequest struct {
 	PageSize   int    `json:"page_size" validate:"omitempty,numeric,min=1"`
 	SortBy     string `json:"sort_by" validate:"omitempty,oneof=id employee_id total_amount month year created_at updated_at"`
 	SortOrder  string `json:"sort_order" validate:"omitempty,oneof=asc desc"`
+
+	// Export receives every matching wage instead of a page when the list is downloaded
+	Export utils.RowWriter `json:"-" validate:"-"`
 }
 type WageDetailRequest struct {
 	ComponentName string  `json:"component_name" validate:"required"`
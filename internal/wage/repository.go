@@ -175,10 +175,21 @@ func (r *WageRepositoryImpl) GetAll(request GetWageRequest) ([]GetWageResponse,
 		query += ` ORDER BY W.Created_At DESC`
 	}
 
-	query += ` LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
-	args = append(args, request.PageSize, (request.Page-1)*request.PageSize)
+	// Exports stream every row, lists default to the first page
+	limitArgs := args
+	if request.Export == nil {
+		page, pageSize := request.Page, request.PageSize
+		if pageSize <= 0 {
+			pageSize = utils.DefaultPageSize
+		}
+		if page < 1 {
+			page = utils.DefaultPage
+		}
+		query += ` LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
+		limitArgs = append(limitArgs, pageSize, (page-1)*pageSize)
+	}
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.Query(query, limitArgs...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -190,10 +201,16 @@ func (r *WageRepositoryImpl) GetAll(request GetWageRequest) ([]GetWageResponse,
 		if err != nil {
 			return nil, 0, err
 		}
+		if request.Export != nil {
+			if errExport := request.Export.WriteRow(wage); errExport != nil {
+				return nil, 0, errExport
+			}
+			continue
+		}
 		wages = append(wages, wage)
 	}
 
-	err = r.db.QueryRow(countQuery, args[:argIndex-1]...).Scan(&totalItems)
+	err = r.db.QueryRow(countQuery, args...).Scan(&totalItems)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"sinartimur-go/utils"
+)
+
+// Subscription represents a webhook endpoint subscribed to a set of event types
+type Subscription struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	Secret    string   `json:"-"`
+	Events    []string `json:"events"`
+	IsActive  bool     `json:"is_active"`
+	CreatedBy *string  `json:"created_by,omitempty"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+// CreateSubscriptionRequest holds data needed to create a webhook subscription
+type CreateSubscriptionRequest struct {
+	Name     string   `json:"name" validate:"required,min=2,max=100"`
+	URL      string   `json:"url" validate:"required,url,max=1000"`
+	Secret   string   `json:"secret" validate:"omitempty,min=16,max=255"`
+	Events   []string `json:"events" validate:"required,min=1,dive,required"`
+	IsActive *bool    `json:"is_active" validate:"omitempty"`
+}
+
+// UpdateSubscriptionRequest holds data needed to update a webhook subscription, an empty secret keeps the current one
+type UpdateSubscriptionRequest struct {
+	ID       string   `json:"id" validate:"required,uuid"`
+	Name     string   `json:"name" validate:"required,min=2,max=100"`
+	URL      string   `json:"url" validate:"required,url,max=1000"`
+	Secret   string   `json:"secret" validate:"omitempty,min=16,max=255"`
+	Events   []string `json:"events" validate:"required,min=1,dive,required"`
+	IsActive bool     `json:"is_active"`
+}
+
+// CreateSubscriptionResponse is returned once on creation and is the only time the secret is shown
+type CreateSubscriptionResponse struct {
+	Subscription
+	Secret string `json:"secret"`
+}
+
+// GetSubscriptionsRequest holds query parameters for listing subscriptions
+type GetSubscriptionsRequest struct {
+	Event string `json:"event" validate:"omitempty"`
+	utils.PaginationParameter
+}
+
+// Delivery represents a single attempt sequence to deliver an event to a subscription
+type Delivery struct {
+	ID             string  `json:"id"`
+	SubscriptionID string  `json:"subscription_id"`
+	EventID        string  `json:"event_id"`
+	EventType      string  `json:"event_type"`
+	Payload        string  `json:"payload"`
+	Status         string  `json:"status"` // pending, success, failed
+	Attempts       int     `json:"attempts"`
+	NextAttemptAt  *string `json:"next_attempt_at,omitempty"`
+	ResponseStatus *int    `json:"response_status,omitempty"`
+	ResponseBody   *string `json:"response_body,omitempty"`
+	LastError      *string `json:"last_error,omitempty"`
+	RedeliveryOf   *string `json:"redelivery_of,omitempty"`
+	DeliveredAt    *string `json:"delivered_at,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+// GetDeliveriesRequest holds query parameters for the delivery log
+type GetDeliveriesRequest struct {
+	SubscriptionID string `json:"subscription_id" validate:"omitempty,uuid"`
+	EventType      string `json:"event_type" validate:"omitempty"`
+	Status         string `json:"status" validate:"omitempty,oneof=pending success failed"`
+	utils.PaginationParameter
+}
+
+// pendingDelivery is a claimed delivery together with its destination
+type pendingDelivery struct {
+	ID        string
+	EventID   string
+	EventType string
+	Payload   []byte
+	Attempts  int
+	URL       string
+	Secret    string
+}
+
+// DeliveryResult records the outcome of one delivery attempt
+type DeliveryResult struct {
+	Success        bool
+	ResponseStatus int
+	ResponseBody   string
+	Error          string
+}
@@ -0,0 +1,312 @@
+package webhook
+
+import (
+	"database/sql"
+	"fmt"
+	"sinartimur-go/utils"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WebhookRepository defines the interface for webhook data operations
+type WebhookRepository interface {
+	// Subscription CRUD operations
+	GetAllSubscriptions(req GetSubscriptionsRequest) ([]Subscription, int, error)
+	GetSubscriptionByID(id string) (*Subscription, error)
+	CreateSubscription(req CreateSubscriptionRequest, secret string, userID string) (*Subscription, error)
+	UpdateSubscription(req UpdateSubscriptionRequest) (*Subscription, error)
+	DeleteSubscription(id string) error
+
+	// Delivery operations
+	CreateDeliveries(eventID string, eventType string, payload []byte) (int, error)
+	ClaimDueDeliveries(limit int, lease time.Duration) ([]pendingDelivery, error)
+	RecordDeliveryAttempt(id string, result DeliveryResult, nextAttemptAt *time.Time) error
+	GetDeliveries(req GetDeliveriesRequest) ([]Delivery, int, error)
+	GetDeliveryByID(id string) (*Delivery, error)
+	Redeliver(id string) (*Delivery, error)
+}
+
+// WebhookRepositoryImpl implements the WebhookRepository interface
+type WebhookRepositoryImpl struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new webhook repository instance
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &WebhookRepositoryImpl{db: db}
+}
+
+const subscriptionColumns = "Id, Name, Url, Secret, Events, Is_Active, Created_By, Created_At, Updated_At"
+
+// scanSubscription scans a row selected with subscriptionColumns
+func scanSubscription(row interface{ Scan(...interface{}) error }) (*Subscription, error) {
+	var sub Subscription
+	var createdBy sql.NullString
+	var createdAt, updatedAt time.Time
+	if err := row.Scan(&sub.ID, &sub.Name, &sub.URL, &sub.Secret, pq.Array(&sub.Events), &sub.IsActive, &createdBy, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		sub.CreatedBy = &createdBy.String
+	}
+	sub.CreatedAt = createdAt.Format(time.RFC3339)
+	sub.UpdatedAt = updatedAt.Format(time.RFC3339)
+	return &sub, nil
+}
+
+// GetAllSubscriptions fetches all webhook subscriptions with pagination
+func (r *WebhookRepositoryImpl) GetAllSubscriptions(req GetSubscriptionsRequest) ([]Subscription, int, error) {
+	qb := utils.NewQueryBuilder("Select " + subscriptionColumns + " From Webhook_Subscription Where Deleted_At Is Null")
+	if req.Event != "" {
+		qb.AddFilter("Events @>", pq.Array([]string{req.Event}))
+	}
+
+	var totalItems int
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Subscriptions", qb.Query.String())
+	if err := r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems); err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung webhook: %w", err)
+	}
+
+	qb.Query.WriteString(" Order By Created_At Desc")
+	qb.AddPagination(req.PaginationParameter)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil webhook: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []Subscription{}
+	for rows.Next() {
+		sub, errScan := scanSubscription(rows)
+		if errScan != nil {
+			return nil, 0, fmt.Errorf("gagal membaca webhook: %w", errScan)
+		}
+		subscriptions = append(subscriptions, *sub)
+	}
+
+	return subscriptions, totalItems, rows.Err()
+}
+
+// GetSubscriptionByID fetches a webhook subscription by ID
+func (r *WebhookRepositoryImpl) GetSubscriptionByID(id string) (*Subscription, error) {
+	row := r.db.QueryRow("Select "+subscriptionColumns+" From Webhook_Subscription Where Id = $1 And Deleted_At Is Null", id)
+	return scanSubscription(row)
+}
+
+// CreateSubscription creates a new webhook subscription
+func (r *WebhookRepositoryImpl) CreateSubscription(req CreateSubscriptionRequest, secret string, userID string) (*Subscription, error) {
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	row := r.db.QueryRow(`
+		Insert Into Webhook_Subscription (Name, Url, Secret, Events, Is_Active, Created_By)
+		Values ($1, $2, $3, $4, $5, $6)
+		Returning `+subscriptionColumns,
+		req.Name, req.URL, secret, pq.Array(req.Events), isActive, userID)
+	return scanSubscription(row)
+}
+
+// UpdateSubscription updates an existing webhook subscription
+func (r *WebhookRepositoryImpl) UpdateSubscription(req UpdateSubscriptionRequest) (*Subscription, error) {
+	row := r.db.QueryRow(`
+		Update Webhook_Subscription
+		Set Name = $1, Url = $2, Secret = Coalesce(Nullif($3, ''), Secret), Events = $4, Is_Active = $5, Updated_At = Now()
+		Where Id = $6 And Deleted_At Is Null
+		Returning `+subscriptionColumns,
+		req.Name, req.URL, req.Secret, pq.Array(req.Events), req.IsActive, req.ID)
+	return scanSubscription(row)
+}
+
+// DeleteSubscription soft deletes a webhook subscription and drops its pending deliveries
+func (r *WebhookRepositoryImpl) DeleteSubscription(id string) error {
+	return utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		result, err := tx.Exec("Update Webhook_Subscription Set Deleted_At = Now(), Is_Active = False Where Id = $1 And Deleted_At Is Null", id)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return sql.ErrNoRows
+		}
+
+		_, err = tx.Exec("Update Webhook_Delivery Set Status = 'failed', Last_Error = 'subscription deleted', Next_Attempt_At = Null Where Subscription_Id = $1 And Status = 'pending'", id)
+		return err
+	})
+}
+
+// CreateDeliveries queues the event for every active subscription listening to its type
+func (r *WebhookRepositoryImpl) CreateDeliveries(eventID string, eventType string, payload []byte) (int, error) {
+	result, err := r.db.Exec(`
+		Insert Into Webhook_Delivery (Subscription_Id, Event_Id, Event_Type, Payload)
+		Select Id, $1, $2, $3
+		From Webhook_Subscription
+		Where Is_Active And Deleted_At Is Null And $2 = Any(Events)
+		On Conflict (Subscription_Id, Event_Id) Where Redelivery_Of Is Null Do Nothing`,
+		eventID, eventType, string(payload))
+	if err != nil {
+		return 0, fmt.Errorf("gagal membuat pengiriman webhook: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+// ClaimDueDeliveries locks due deliveries for the lease duration so concurrent workers skip them
+func (r *WebhookRepositoryImpl) ClaimDueDeliveries(limit int, lease time.Duration) ([]pendingDelivery, error) {
+	rows, err := r.db.Query(`
+		With Due As (
+			Select Id From Webhook_Delivery
+			Where Status = 'pending' And Next_Attempt_At <= Now()
+			Order By Next_Attempt_At
+			Limit $1
+			For Update Skip Locked
+		)
+		Update Webhook_Delivery D
+		Set Next_Attempt_At = Now() + Make_Interval(Secs => $2), Updated_At = Now()
+		From Due, Webhook_Subscription S
+		Where D.Id = Due.Id And S.Id = D.Subscription_Id
+		Returning D.Id, D.Event_Id, D.Event_Type, D.Payload, D.Attempts, S.Url, S.Secret`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil antrian webhook: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []pendingDelivery
+	for rows.Next() {
+		var d pendingDelivery
+		if errScan := rows.Scan(&d.ID, &d.EventID, &d.EventType, &d.Payload, &d.Attempts, &d.URL, &d.Secret); errScan != nil {
+			return nil, errScan
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordDeliveryAttempt stores the outcome of an attempt, a nil nextAttemptAt on failure marks the delivery as failed
+func (r *WebhookRepositoryImpl) RecordDeliveryAttempt(id string, result DeliveryResult, nextAttemptAt *time.Time) error {
+	status := "pending"
+	if result.Success {
+		status = "success"
+	} else if nextAttemptAt == nil {
+		status = "failed"
+	}
+
+	_, err := r.db.Exec(`
+		Update Webhook_Delivery
+		Set Status = $1,
+		    Attempts = Attempts + 1,
+		    Next_Attempt_At = $2,
+		    Response_Status = Nullif($3, 0),
+		    Response_Body = Nullif($4, ''),
+		    Last_Error = Nullif($5, ''),
+		    Delivered_At = Case When $1 = 'success' Then Now() Else Delivered_At End,
+		    Updated_At = Now()
+		Where Id = $6`,
+		status, nextAttemptAt, result.ResponseStatus, result.ResponseBody, result.Error, id)
+	return err
+}
+
+const deliveryColumns = `Id, Subscription_Id, Event_Id, Event_Type, Payload, Status, Attempts, Next_Attempt_At,
+	Response_Status, Response_Body, Last_Error, Redelivery_Of, Delivered_At, Created_At`
+
+// scanDelivery scans a row selected with deliveryColumns
+func scanDelivery(row interface{ Scan(...interface{}) error }) (*Delivery, error) {
+	var d Delivery
+	var nextAttemptAt, deliveredAt sql.NullTime
+	var responseStatus sql.NullInt64
+	var responseBody, lastError, redeliveryOf sql.NullString
+	var createdAt time.Time
+
+	err := row.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &nextAttemptAt,
+		&responseStatus, &responseBody, &lastError, &redeliveryOf, &deliveredAt, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if nextAttemptAt.Valid {
+		formatted := nextAttemptAt.Time.Format(time.RFC3339)
+		d.NextAttemptAt = &formatted
+	}
+	if deliveredAt.Valid {
+		formatted := deliveredAt.Time.Format(time.RFC3339)
+		d.DeliveredAt = &formatted
+	}
+	if responseStatus.Valid {
+		code := int(responseStatus.Int64)
+		d.ResponseStatus = &code
+	}
+	if responseBody.Valid {
+		d.ResponseBody = &responseBody.String
+	}
+	if lastError.Valid {
+		d.LastError = &lastError.String
+	}
+	if redeliveryOf.Valid {
+		d.RedeliveryOf = &redeliveryOf.String
+	}
+	d.CreatedAt = createdAt.Format(time.RFC3339)
+
+	return &d, nil
+}
+
+// GetDeliveries fetches the delivery log with filtering and pagination
+func (r *WebhookRepositoryImpl) GetDeliveries(req GetDeliveriesRequest) ([]Delivery, int, error) {
+	qb := utils.NewQueryBuilder("Select " + deliveryColumns + " From Webhook_Delivery Where 1=1")
+	if req.SubscriptionID != "" {
+		qb.AddFilter("Subscription_Id =", req.SubscriptionID)
+	}
+	if req.EventType != "" {
+		qb.AddFilter("Event_Type =", req.EventType)
+	}
+	if req.Status != "" {
+		qb.AddFilter("Status =", req.Status)
+	}
+
+	var totalItems int
+	countQuery := fmt.Sprintf("Select Count(*) From (%s) As Filtered_Deliveries", qb.Query.String())
+	if err := r.db.QueryRow(countQuery, qb.Params...).Scan(&totalItems); err != nil {
+		return nil, 0, fmt.Errorf("gagal menghitung log webhook: %w", err)
+	}
+
+	qb.Query.WriteString(" Order By Created_At Desc")
+	qb.AddPagination(req.PaginationParameter)
+
+	query, params := qb.Build()
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal mengambil log webhook: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		d, errScan := scanDelivery(rows)
+		if errScan != nil {
+			return nil, 0, fmt.Errorf("gagal membaca log webhook: %w", errScan)
+		}
+		deliveries = append(deliveries, *d)
+	}
+
+	return deliveries, totalItems, rows.Err()
+}
+
+// GetDeliveryByID fetches a single delivery
+func (r *WebhookRepositoryImpl) GetDeliveryByID(id string) (*Delivery, error) {
+	return scanDelivery(r.db.QueryRow("Select "+deliveryColumns+" From Webhook_Delivery Where Id = $1", id))
+}
+
+// Redeliver queues a fresh copy of a delivery, keeping the original in the log
+func (r *WebhookRepositoryImpl) Redeliver(id string) (*Delivery, error) {
+	row := r.db.QueryRow(`
+		Insert Into Webhook_Delivery (Subscription_Id, Event_Id, Event_Type, Payload, Redelivery_Of)
+		Select D.Subscription_Id, D.Event_Id, D.Event_Type, D.Payload, D.Id
+		From Webhook_Delivery D
+		Join Webhook_Subscription S On S.Id = D.Subscription_Id And S.Deleted_At Is Null
+		Where D.Id = $1
+		Returning `+deliveryColumns, id)
+	return scanDelivery(row)
+}
@@ -0,0 +1,327 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sinartimur-go/pkg/dto"
+	"sinartimur-go/pkg/event"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxDeliveryAttempts is the number of attempts before a delivery is marked as failed
+	maxDeliveryAttempts = 8
+	// baseRetryDelay is doubled after every failed attempt, up to maxRetryDelay
+	baseRetryDelay = 30 * time.Second
+	maxRetryDelay  = 6 * time.Hour
+	// pollInterval is how often the dispatcher looks for due deliveries
+	pollInterval = 10 * time.Second
+	// claimLease hides a claimed delivery from other workers while it is being sent
+	claimLease = 2 * time.Minute
+	// claimBatchSize is the number of deliveries sent per dispatcher pass
+	claimBatchSize = 20
+	// maxResponseBodySize is how much of the receiver's response is kept in the log
+	maxResponseBodySize = 1024
+)
+
+// WebhookService is the service for webhook subscriptions and deliveries
+type WebhookService struct {
+	repo   WebhookRepository
+	client *http.Client
+	notify chan struct{}
+}
+
+// NewWebhookService creates a new instance of WebhookService
+func NewWebhookService(repo WebhookRepository) *WebhookService {
+	return &WebhookService{
+		repo:   repo,
+		client: &http.Client{Timeout: 10 * time.Second},
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// validateEvents checks that every requested event type exists
+func validateEvents(events []string) *dto.APIError {
+	for _, requested := range events {
+		known := false
+		for _, eventType := range event.Types {
+			if requested == eventType {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return dto.NewAPIError(http.StatusBadRequest, map[string]string{
+				"events": "Tipe event tidak dikenal: " + requested,
+			})
+		}
+	}
+	return nil
+}
+
+// generateSecret creates a random signing secret
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// GetAllSubscriptions fetches all webhook subscriptions
+func (s *WebhookService) GetAllSubscriptions(req GetSubscriptionsRequest) ([]Subscription, int, *dto.APIError) {
+	subscriptions, totalItems, err := s.repo.GetAllSubscriptions(req)
+	if err != nil {
+		return nil, 0, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data webhook",
+		})
+	}
+	return subscriptions, totalItems, nil
+}
+
+// GetSubscriptionByID fetches a webhook subscription by ID
+func (s *WebhookService) GetSubscriptionByID(id string) (*Subscription, *dto.APIError) {
+	subscription, err := s.repo.GetSubscriptionByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Webhook tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil data webhook",
+		})
+	}
+	return subscription, nil
+}
+
+// CreateSubscription creates a new webhook subscription, generating a secret when none is given
+func (s *WebhookService) CreateSubscription(req CreateSubscriptionRequest, userID string) (*CreateSubscriptionResponse, *dto.APIError) {
+	if apiErr := validateEvents(req.Events); apiErr != nil {
+		return nil, apiErr
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		generated, err := generateSecret()
+		if err != nil {
+			return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+				"general": "Gagal membuat secret webhook",
+			})
+		}
+		secret = generated
+	}
+
+	subscription, err := s.repo.CreateSubscription(req, secret, userID)
+	if err != nil {
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal membuat webhook",
+		})
+	}
+
+	return &CreateSubscriptionResponse{Subscription: *subscription, Secret: secret}, nil
+}
+
+// UpdateSubscription updates an existing webhook subscription
+func (s *WebhookService) UpdateSubscription(req UpdateSubscriptionRequest) (*Subscription, *dto.APIError) {
+	if apiErr := validateEvents(req.Events); apiErr != nil {
+		return nil, apiErr
+	}
+
+	subscription, err := s.repo.UpdateSubscription(req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Webhook tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengupdate webhook",
+		})
+	}
+	return subscription, nil
+}
+
+// DeleteSubscription deletes a webhook subscription
+func (s *WebhookService) DeleteSubscription(id string) *dto.APIError {
+	if err := s.repo.DeleteSubscription(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Webhook tidak ditemukan",
+			})
+		}
+		return dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal menghapus webhook",
+		})
+	}
+	return nil
+}
+
+// GetDeliveries fetches the webhook delivery log
+func (s *WebhookService) GetDeliveries(req GetDeliveriesRequest) ([]Delivery, int, *dto.APIError) {
+	deliveries, totalItems, err := s.repo.GetDeliveries(req)
+	if err != nil {
+		return nil, 0, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengambil log webhook",
+		})
+	}
+	return deliveries, totalItems, nil
+}
+
+// Redeliver queues a delivery again regardless of its previous outcome
+func (s *WebhookService) Redeliver(id string) (*Delivery, *dto.APIError) {
+	delivery, err := s.repo.Redeliver(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, dto.NewAPIError(http.StatusNotFound, map[string]string{
+				"general": "Pengiriman webhook tidak ditemukan",
+			})
+		}
+		return nil, dto.NewAPIError(http.StatusInternalServerError, map[string]string{
+			"general": "Gagal mengirim ulang webhook",
+		})
+	}
+
+	s.wake()
+	return delivery, nil
+}
+
+// HandleEvent queues an outbox event for every matching subscription.
+// It is idempotent on the event ID, so redelivery by the outbox does not duplicate webhooks.
+func (s *WebhookService) HandleEvent(evt event.Event) error {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("gagal membaca event %s: %w", evt.Type, err)
+	}
+
+	queued, err := s.repo.CreateDeliveries(evt.ID, evt.Type, payload)
+	if err != nil {
+		return err
+	}
+
+	if queued > 0 {
+		s.wake()
+	}
+	return nil
+}
+
+// wake nudges the dispatcher without blocking
+func (s *WebhookService) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the delivery dispatcher in the background
+func (s *WebhookService) Start() {
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.dispatchDue()
+
+			select {
+			case <-ticker.C:
+			case <-s.notify:
+			}
+		}
+	}()
+}
+
+// dispatchDue sends every delivery that is due
+func (s *WebhookService) dispatchDue() {
+	for {
+		deliveries, err := s.repo.ClaimDueDeliveries(claimBatchSize, claimLease)
+		if err != nil {
+			log.Printf("webhook: %v", err)
+			return
+		}
+
+		for _, d := range deliveries {
+			result := s.send(d)
+
+			// Schedule a retry with exponential backoff unless the attempts are exhausted
+			var nextAttemptAt *time.Time
+			if !result.Success && d.Attempts+1 < maxDeliveryAttempts {
+				next := time.Now().Add(retryDelay(d.Attempts))
+				nextAttemptAt = &next
+			}
+
+			if err := s.repo.RecordDeliveryAttempt(d.ID, result, nextAttemptAt); err != nil {
+				log.Printf("webhook: failed to record delivery %s: %v", d.ID, err)
+			}
+		}
+
+		if len(deliveries) < claimBatchSize {
+			return
+		}
+	}
+}
+
+// retryDelay returns the wait before the next attempt after the given number of previous attempts
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 0; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
+
+// Sign computes the signature sent in X-Webhook-Signature, receivers verify it over "<timestamp>.<body>"
+func Sign(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// send performs a single delivery attempt
+func (s *WebhookService) send(d pendingDelivery) DeliveryResult {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return DeliveryResult{Error: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Sinartimur-Webhook/1.0")
+	req.Header.Set("X-Webhook-Id", d.ID)
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(d.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return DeliveryResult{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	result := DeliveryResult{
+		Success:        resp.StatusCode >= 200 && resp.StatusCode < 300,
+		ResponseStatus: resp.StatusCode,
+		ResponseBody:   strings.ReplaceAll(strings.ToValidUTF8(string(body), ""), "\x00", ""),
+	}
+	if !result.Success {
+		result.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return result
+}
@@ -47,7 +47,7 @@ func CORSMiddleware() func(http.Handler) http.Handler {
 	return handlers.CORS(
 		handlers.AllowedOrigins([]string{"http://52.76.42.12", "http://localhost:5173"}),
 		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", BranchHeader}),
 		handlers.ExposedHeaders([]string{"Set-Cookie"}),
 		handlers.AllowCredentials(),
 	)
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sinartimur-go/pkg/dto"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// BranchHeader is the request header a client uses to pick its active branch
+const BranchHeader = "X-Branch-Id"
+
+// withBranch adds the caller's branches and active branch to the context.
+// Without a header the default branch is active, admins without a header get an empty active branch which means every branch.
+func withBranch(ctx context.Context, r *http.Request, claims jwt.MapClaims, isAdmin bool) (context.Context, *dto.APIError) {
+	var branches []string
+	if branchClaims, ok := claims["branches"].([]interface{}); ok {
+		for _, branch := range branchClaims {
+			if branchID, ok := branch.(string); ok {
+				branches = append(branches, branchID)
+			}
+		}
+	}
+	ctx = context.WithValue(ctx, "branches", branches)
+
+	activeBranch := r.Header.Get(BranchHeader)
+	if activeBranch == "" {
+		if isAdmin {
+			return context.WithValue(ctx, "branch_id", ""), nil
+		}
+		activeBranch, _ = claims["default_branch"].(string)
+		if activeBranch == "" && len(branches) > 0 {
+			activeBranch = branches[0]
+		}
+	}
+
+	if activeBranch == "" {
+		return nil, dto.NewAPIError(http.StatusUnauthorized, map[string]string{
+			"general": "User belum ditugaskan ke cabang manapun",
+		})
+	}
+
+	// Admins may open any branch, other users only the branches they are assigned to
+	if !isAdmin {
+		assigned := false
+		for _, branchID := range branches {
+			if branchID == activeBranch {
+				assigned = true
+				break
+			}
+		}
+		if !assigned {
+			return nil, dto.NewAPIError(http.StatusUnauthorized, map[string]string{
+				"general": "Akses cabang tidak diizinkan",
+			})
+		}
+	}
+
+	return context.WithValue(ctx, "branch_id", activeBranch), nil
+}
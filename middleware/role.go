@@ -74,15 +74,37 @@ func RoleMiddleware(roles ...string) func(http.Handler) http.Handler {
 			ctx := context.WithValue(r.Context(), "user_id", userID)
 
 			rolesClaim, ok := claims["roles"].([]interface{})
+
+			// Expose the caller's roles so handlers can filter what they return
+			roleNames := make([]string, 0, len(rolesClaim))
+			for _, role := range rolesClaim {
+				roleNames = append(roleNames, role.(string))
+			}
+			ctx = context.WithValue(ctx, "roles", roleNames)
+
 			for _, role := range rolesClaim {
 				roleStr := role.(string)
 				if roleStr == "admin" {
-					next.ServeHTTP(w, r.WithContext(ctx))
+					branchCtx, apiErr := withBranch(ctx, r, claims, true)
+					if apiErr != nil {
+						utils.ErrorJSON(w, apiErr)
+						return
+					}
+					next.ServeHTTP(w, r.WithContext(branchCtx))
 					return
 				}
+			}
+
+			for _, role := range rolesClaim {
+				roleStr := role.(string)
 				for _, requiredRole := range roles {
 					if roleStr == requiredRole {
-						next.ServeHTTP(w, r.WithContext(ctx))
+						branchCtx, apiErr := withBranch(ctx, r, claims, false)
+						if apiErr != nil {
+							utils.ErrorJSON(w, apiErr)
+							return
+						}
+						next.ServeHTTP(w, r.WithContext(branchCtx))
 						return
 					}
 				}
@@ -147,7 +169,12 @@ func RoleOrPathMiddleware(pathRoleMap map[string][]string, defaultRoles ...strin
 			for _, role := range rolesClaim {
 				roleStr := role.(string)
 				if roleStr == "admin" {
-					next.ServeHTTP(w, r.WithContext(ctx))
+					branchCtx, apiErr := withBranch(ctx, r, claims, true)
+					if apiErr != nil {
+						utils.ErrorJSON(w, apiErr)
+						return
+					}
+					next.ServeHTTP(w, r.WithContext(branchCtx))
 					return
 				}
 			}
@@ -166,7 +193,12 @@ func RoleOrPathMiddleware(pathRoleMap map[string][]string, defaultRoles ...strin
 				roleStr := role.(string)
 				for _, requiredRole := range allowedRoles {
 					if roleStr == requiredRole {
-						next.ServeHTTP(w, r.WithContext(ctx))
+						branchCtx, apiErr := withBranch(ctx, r, claims, false)
+						if apiErr != nil {
+							utils.ErrorJSON(w, apiErr)
+							return
+						}
+						next.ServeHTTP(w, r.WithContext(branchCtx))
 						return
 					}
 				}
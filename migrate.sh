@@ -18,6 +18,18 @@ else
     exit 1
 fi
 
+# Incremental migrations are idempotent so they can run on fresh and existing databases
+for MIGRATION in ~/app/migrations/[0-9]*.sql; do
+    [ -f "$MIGRATION" ] || continue
+    echo "Running migration $(basename "$MIGRATION")..."
+    docker exec -i $DB_CONTAINER psql -U $POSTGRES_USER -d $POSTGRES_DB -v ON_ERROR_STOP=1 -f - < "$MIGRATION"
+
+    if [ $? -ne 0 ]; then
+        echo "❌ Migration $(basename "$MIGRATION") failed"
+        exit 1
+    fi
+done
+
 # Seed is not needed
 # if [ -f migrations/seed.sql ]; then
 #   echo "Running seed data..."
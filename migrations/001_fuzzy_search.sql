@@ -0,0 +1,20 @@
+-- Fuzzy search for products, customers and suppliers
+Create Extension If Not Exists Pg_Trgm;
+
+Create Index If Not Exists Idx_Product_Name_Trgm On Product Using Gin (Name Gin_Trgm_Ops);
+
+Create Index If Not Exists Idx_Product_Description_Trgm On Product Using Gin (Description Gin_Trgm_Ops);
+
+Create Index If Not Exists Idx_Category_Name_Trgm On Category Using Gin (Name Gin_Trgm_Ops);
+
+Create Index If Not Exists Idx_Unit_Name_Trgm On Unit Using Gin (Name Gin_Trgm_Ops);
+
+Create Index If Not Exists Idx_Product_Batch_Sku_Trgm On Product_Batch Using Gin (Sku Gin_Trgm_Ops);
+
+Create Index If Not Exists Idx_Customer_Name_Trgm On Customer Using Gin (Name Gin_Trgm_Ops);
+
+Create Index If Not Exists Idx_Customer_Address_Trgm On Customer Using Gin (Address Gin_Trgm_Ops);
+
+Create Index If Not Exists Idx_Supplier_Name_Trgm On Supplier Using Gin (Name Gin_Trgm_Ops);
+
+Create Index If Not Exists Idx_Supplier_Address_Trgm On Supplier Using Gin (Address Gin_Trgm_Ops);
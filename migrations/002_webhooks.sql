@@ -0,0 +1,37 @@
+-- Outbound webhook subscriptions and delivery log
+Create Table If Not Exists
+    Webhook_Subscription (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Name VARCHAR(100) Not Null,
+        Url TEXT Not Null,
+        Secret VARCHAR(255) Not Null,
+        Events TEXT[] Not Null,
+        Is_Active BOOLEAN Default True,
+        Created_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp,
+        Deleted_At Timestamptz Default Null
+    );
+
+Create Table If Not Exists
+    Webhook_Delivery (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Subscription_Id Uuid Not Null References Webhook_Subscription (Id) On Delete Cascade,
+        Event_Id Uuid Not Null,
+        Event_Type VARCHAR(100) Not Null,
+        Payload JSONB Not Null,
+        Status VARCHAR(20) Not Null Default 'pending' CHECK (Status IN ('pending', 'success', 'failed')), -- pending, success, failed
+        Attempts INT Not Null Default 0,
+        Next_Attempt_At Timestamptz Default Current_Timestamp,
+        Response_Status INT Default Null,
+        Response_Body TEXT Default Null,
+        Last_Error TEXT Default Null,
+        Redelivery_Of Uuid References Webhook_Delivery (Id) On Delete Set Null,
+        Delivered_At Timestamptz Default Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp
+    );
+
+Create Index If Not Exists Idx_Webhook_Delivery_Due On Webhook_Delivery (Status, Next_Attempt_At);
+
+Create Index If Not Exists Idx_Webhook_Delivery_Subscription_Id On Webhook_Delivery (Subscription_Id);
@@ -0,0 +1,25 @@
+-- Transactional outbox for domain events
+Create Table If Not Exists
+    Outbox_Event (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Event_Type VARCHAR(100) Not Null,
+        Payload JSONB Not Null,
+        Attempts INT Not Null Default 0,
+        Next_Attempt_At Timestamptz Not Null Default Current_Timestamp,
+        Last_Error TEXT Default Null,
+        Processed_At Timestamptz Default Null,
+        Created_At Timestamptz Default Current_Timestamp
+    );
+
+-- Subscribers that already handled an event, so a retry only reaches the ones that failed
+Create Table If Not Exists
+    Outbox_Consumption (
+        Event_Id Uuid References Outbox_Event (Id) On Delete Cascade,
+        Subscriber VARCHAR(100) Not Null,
+        Processed_At Timestamptz Default Current_Timestamp,
+        Primary Key (Event_Id, Subscriber)
+    );
+
+Create Index If Not Exists Idx_Outbox_Event_Pending On Outbox_Event (Next_Attempt_At) Where Processed_At Is Null;
+
+Create Unique Index If Not Exists Idx_Webhook_Delivery_Event On Webhook_Delivery (Subscription_Id, Event_Id) Where Redelivery_Of Is Null;
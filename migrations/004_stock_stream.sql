@@ -0,0 +1,43 @@
+-- Record every Batch_Storage quantity change in the outbox, so stock updates are streamed whichever code path made them
+Create Or Replace Function Record_Batch_Storage_Change () Returns Trigger As $$
+Declare
+    New_Event_Id Uuid := Uuid_Generate_V4 ();
+    Previous_Quantity Numeric(15, 2) := 0;
+Begin
+    If Tg_Op = 'UPDATE' Then
+        If Old.Quantity = New.Quantity Then
+            Return New;
+        End If;
+        Previous_Quantity := Old.Quantity;
+    End If;
+
+    Insert Into Outbox_Event (Id, Event_Type, Payload)
+    Select
+        New_Event_Id,
+        'inventory.stock_changed',
+        Json_Build_Object(
+            'id', New_Event_Id,
+            'type', 'inventory.stock_changed',
+            'occurred_at', Current_Timestamp,
+            'data', Json_Build_Object(
+                'batch_storage_id', New.Id,
+                'batch_id', New.Batch_Id,
+                'storage_id', New.Storage_Id,
+                'product_id', Pb.Product_Id,
+                'sku', Pb.Sku,
+                'quantity', New.Quantity,
+                'previous_quantity', Previous_Quantity
+            )
+        )
+    From Product_Batch Pb
+    Where Pb.Id = New.Batch_Id;
+
+    Return New;
+End;
+$$ Language Plpgsql;
+
+Drop Trigger If Exists Trg_Batch_Storage_Change On Batch_Storage;
+
+Create Trigger Trg_Batch_Storage_Change
+After Insert Or Update Of Quantity On Batch_Storage
+For Each Row Execute Function Record_Batch_Storage_Change ();
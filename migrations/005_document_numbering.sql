@@ -0,0 +1,50 @@
+-- Configurable numbering schemes per document type
+Alter Table Document_Counter Drop Constraint If Exists Document_Counter_Document_Type_Check;
+
+Alter Table Document_Counter Add Constraint Document_Counter_Document_Type_Check CHECK (
+    Document_Type IN ('SO', 'SI', 'DN', 'PO', 'SR', 'PR', 'PAY', 'ADJ', 'TRF')
+);
+
+Create Table If Not Exists
+    Document_Numbering (
+        Document_Type VARCHAR(10) Primary Key CHECK (
+            Document_Type IN ('SO', 'SI', 'DN', 'PO', 'SR', 'PR', 'PAY', 'ADJ', 'TRF')
+        ),
+        Description VARCHAR(100) Not Null,
+        Prefix VARCHAR(10) Not Null,
+        Reset_Period VARCHAR(10) Not Null Default 'daily' CHECK (Reset_Period IN ('daily', 'monthly', 'yearly')), -- daily, monthly, yearly
+        Padding INT Not Null Default 4 CHECK (Padding Between 1 And 8),
+        Branch_Code VARCHAR(10) Default Null,
+        Updated_By Uuid References Appuser (Id) On Delete Set Null,
+        Updated_At Timestamptz Default Current_Timestamp
+    );
+
+Insert Into
+    Document_Numbering (Document_Type, Description, Prefix)
+Values
+    ('SO', 'Pesanan Penjualan', 'SO'),
+    ('SI', 'Faktur Penjualan', 'SI'),
+    ('DN', 'Surat Jalan', 'DN'),
+    ('PO', 'Pesanan Pembelian', 'PO'),
+    ('SR', 'Retur Penjualan', 'SR'),
+    ('PR', 'Retur Pembelian', 'PR'),
+    ('PAY', 'Pembayaran', 'PAY'),
+    ('ADJ', 'Penyesuaian Stok', 'ADJ'),
+    ('TRF', 'Transfer Stok', 'TRF')
+On Conflict (Document_Type) Do Nothing;
+
+-- Room for prefixes and branch codes
+Alter Table Sales_Order Alter Column Serial_Id Type VARCHAR(50);
+
+Alter Table Sales_Invoice Alter Column Serial_Id Type VARCHAR(50);
+
+Alter Table Delivery_Note Alter Column Serial_Id Type VARCHAR(50);
+
+Alter Table Purchase_Order Alter Column Serial_Id Type VARCHAR(50);
+
+-- Returns and stock transfers get their own numbers
+Alter Table Sales_Order_Return Add Column If Not Exists Serial_Id VARCHAR(50) Unique;
+
+Alter Table Purchase_Order_Return Add Column If Not Exists Serial_Id VARCHAR(50) Unique;
+
+Alter Table Inventory_Log Add Column If Not Exists Serial_Id VARCHAR(50) Default Null;
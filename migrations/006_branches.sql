@@ -0,0 +1,201 @@
+-- Multi-branch support
+Create Table If Not Exists
+    Branch (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Code VARCHAR(10) Unique Not Null,
+        Name VARCHAR(100) Not Null,
+        Address TEXT Default Null,
+        Telephone VARCHAR(20) Default Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp,
+        Deleted_At Timestamptz Default Null
+    );
+
+Create Table If Not Exists
+    User_Branch (
+        User_Id Uuid References Appuser (Id) On Delete Cascade,
+        Branch_Id Uuid References Branch (Id) On Delete Cascade,
+        Is_Default BOOLEAN Default False,
+        Created_At Timestamptz Default Current_Timestamp,
+        Primary Key (User_Id, Branch_Id)
+    );
+
+Insert Into
+    Branch (Code, Name)
+Values
+    ('PST', 'Pusat')
+On Conflict (Code) Do Nothing;
+
+Alter Table Storage Add Column If Not Exists Branch_Id Uuid References Branch (Id) On Delete Restrict;
+
+Alter Table Sales_Order Add Column If Not Exists Branch_Id Uuid References Branch (Id) On Delete Restrict;
+
+Alter Table Purchase_Order Add Column If Not Exists Branch_Id Uuid References Branch (Id) On Delete Restrict;
+
+Alter Table Financial_Transaction_Log Add Column If Not Exists Branch_Id Uuid References Branch (Id) On Delete Restrict;
+
+Alter Table Document_Counter Add Column If Not Exists Branch_Id Uuid References Branch (Id) On Delete Cascade;
+
+-- Existing data and users belong to the head office
+Update Storage Set Branch_Id = (Select Id From Branch Where Code = 'PST') Where Branch_Id Is Null;
+
+Update Sales_Order Set Branch_Id = (Select Id From Branch Where Code = 'PST') Where Branch_Id Is Null;
+
+Update Purchase_Order Set Branch_Id = (Select Id From Branch Where Code = 'PST') Where Branch_Id Is Null;
+
+Update Financial_Transaction_Log Ft
+Set Branch_Id = Coalesce(
+        (Select So.Branch_Id From Sales_Order So Where So.Id = Ft.Sales_Order_Id),
+        (Select Po.Branch_Id From Purchase_Order Po Where Po.Id = Ft.Purchase_Order_Id),
+        (Select Id From Branch Where Code = 'PST')
+    )
+Where Branch_Id Is Null;
+
+Insert Into User_Branch (User_Id, Branch_Id, Is_Default)
+Select U.Id, B.Id, True
+From Appuser U
+Cross Join Branch B
+Where B.Code = 'PST'
+    And Not Exists (Select 1 From User_Branch Ub Where Ub.User_Id = U.Id)
+On Conflict (User_Id, Branch_Id) Do Nothing;
+
+-- Counters are kept per branch
+Alter Table Document_Counter Drop Constraint If Exists Document_Counter_Document_Type_Year_Month_Day_Key;
+
+Alter Table Document_Counter Drop Constraint If Exists Document_Counter_Document_Type_Branch_Id_Year_Month_Day_Key;
+
+Alter Table Document_Counter Add Constraint Document_Counter_Document_Type_Branch_Id_Year_Month_Day_Key
+    Unique Nulls Not Distinct (Document_Type, Branch_Id, Year, Month, Day);
+
+-- Materialized views expose the branch, rebuilt only once
+Do $$
+Begin
+    If Not Exists (
+        Select 1 From Pg_Attribute Where Attrelid = 'inventory_log_view'::Regclass And Attname = 'branch_id'
+    ) Then
+        Drop Materialized View If Exists inventory_log_view;
+
+        CREATE MATERIALIZED VIEW inventory_log_view AS
+        SELECT
+            il.id,
+            s1.branch_id,
+            il.batch_id,
+            pb.sku AS batch_sku,
+            pb.product_id,
+            p.name AS product_name,
+            il.storage_id,
+            s1.name AS storage_name,
+            il.target_storage_id,
+            s2.name AS target_storage_name,
+            il.user_id,
+            a.username,
+            il.purchase_order_id,
+            il.sales_order_id,
+            il.action,
+            il.quantity,
+            il.log_date,
+            il.description,
+            il.created_at
+        FROM
+            inventory_log il
+            LEFT JOIN product_batch pb ON il.batch_id = pb.id
+            LEFT JOIN product p ON pb.product_id = p.id
+            LEFT JOIN storage s1 ON il.storage_id = s1.id
+            LEFT JOIN storage s2 ON il.target_storage_id = s2.id
+            LEFT JOIN appuser a ON il.user_id = a.id
+        WITH
+            DATA;
+
+        CREATE INDEX idx_inventory_log_view_product_id ON inventory_log_view (product_id);
+
+        CREATE INDEX idx_inventory_log_view_storage_id ON inventory_log_view (storage_id);
+
+        CREATE INDEX idx_inventory_log_view_action ON inventory_log_view (action);
+
+        CREATE INDEX idx_inventory_log_view_log_date ON inventory_log_view (log_date);
+
+        CREATE INDEX idx_inventory_log_view_user_id ON inventory_log_view (user_id);
+
+        CREATE INDEX idx_inventory_log_view_batch_id ON inventory_log_view (batch_id);
+    End If;
+End $$;
+
+Do $$
+Begin
+    If Not Exists (
+        Select 1 From Pg_Attribute Where Attrelid = 'finance_transaction_log_view'::Regclass And Attname = 'branch_id'
+    ) Then
+        Drop Materialized View If Exists finance_transaction_log_view;
+
+        CREATE MATERIALIZED VIEW finance_transaction_log_view AS
+        SELECT
+            ft.Id,
+            ft.Branch_Id,
+            ft.User_Id,
+            u.Username,
+            ft.Amount,
+            ft.Type,
+            ft.Purchase_Order_Id,
+            ft.Sales_Order_Id,
+            ft.Description,
+            ft.Is_System,
+            ft.Transaction_Date,
+            ft.Created_At,
+            ft.Edited_At,
+            ft.Deleted_At
+        FROM
+            Financial_Transaction_Log ft
+            LEFT JOIN Appuser u ON ft.User_Id = u.Id
+            LEFT JOIN Purchase_Order po ON ft.Purchase_Order_Id = po.Id
+            LEFT JOIN Sales_Order so ON ft.Sales_Order_Id = so.Id
+        WITH
+            DATA;
+
+        CREATE INDEX idx_finance_transaction_log_view_id ON finance_transaction_log_view (id);
+
+        CREATE INDEX idx_finance_transaction_log_view_user_id ON finance_transaction_log_view (user_id);
+
+        CREATE INDEX idx_finance_transaction_log_view_type ON finance_transaction_log_view (type);
+
+        CREATE INDEX idx_finance_transaction_log_view_date ON finance_transaction_log_view (transaction_date);
+
+        CREATE INDEX idx_finance_transaction_log_view_purchase_id ON finance_transaction_log_view (purchase_order_id);
+
+        CREATE INDEX idx_finance_transaction_log_view_sales_id ON finance_transaction_log_view (sales_order_id);
+    End If;
+End $$;
+
+CREATE INDEX IF NOT EXISTS idx_inventory_log_view_branch_id ON inventory_log_view (branch_id);
+
+CREATE INDEX IF NOT EXISTS idx_finance_transaction_log_view_branch_id ON finance_transaction_log_view (branch_id);
+
+Create Index If Not Exists Idx_Storage_Branch_Id On Storage (Branch_Id);
+
+Create Index If Not Exists Idx_Sales_Order_Branch_Id On Sales_Order (Branch_Id);
+
+Create Index If Not Exists Idx_Purchase_Order_Branch_Id On Purchase_Order (Branch_Id);
+
+Create Index If Not Exists Idx_Financial_Transaction_Log_Branch_Id On Financial_Transaction_Log (Branch_Id);
+
+Create Index If Not Exists Idx_User_Branch_Branch_Id On User_Branch (Branch_Id);
+
+-- System finance logs take the branch of the order they belong to
+Create Or Replace Function Set_Financial_Transaction_Branch () Returns Trigger As $$
+Begin
+    If New.Branch_Id Is Null And New.Sales_Order_Id Is Not Null Then
+        Select Branch_Id Into New.Branch_Id From Sales_Order Where Id = New.Sales_Order_Id;
+    End If;
+
+    If New.Branch_Id Is Null And New.Purchase_Order_Id Is Not Null Then
+        Select Branch_Id Into New.Branch_Id From Purchase_Order Where Id = New.Purchase_Order_Id;
+    End If;
+
+    Return New;
+End;
+$$ Language Plpgsql;
+
+Drop Trigger If Exists Trg_Financial_Transaction_Branch On Financial_Transaction_Log;
+
+Create Trigger Trg_Financial_Transaction_Branch
+Before Insert On Financial_Transaction_Log
+For Each Row Execute Function Set_Financial_Transaction_Branch ();
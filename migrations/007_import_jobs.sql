@@ -0,0 +1,19 @@
+-- Bulk spreadsheet imports of master data and opening stock
+Create Table If Not Exists
+    Import_Job (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Resource VARCHAR(20) Not Null Check (Resource In ('product', 'customer', 'supplier', 'employee', 'opening_stock')),
+        File_Name VARCHAR(255) Not Null,
+        Status VARCHAR(20) Not Null Check (Status In ('validated', 'invalid', 'committed', 'failed')),
+        Branch_Id Uuid References Branch (Id) On Delete Set Null,
+        Total_Rows INT Not Null Default 0,
+        Valid_Rows INT Not Null Default 0,
+        Error_Rows INT Not Null Default 0,
+        Errors Jsonb Not Null Default '[]',
+        Data Jsonb Not Null Default '{}',
+        Created_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Committed_At Timestamptz Default Null
+    );
+
+Create Index If Not Exists Idx_Import_Job_Created_At On Import_Job (Created_At Desc);
@@ -0,0 +1,26 @@
+-- Printable documents
+
+-- Company letterhead printed on documents, a single row
+Create Table If Not Exists
+    Company_Setting (
+        Id BOOLEAN Primary Key Default True Check (Id),
+        Name VARCHAR(255) Not Null,
+        Address TEXT Default Null,
+        Telephone VARCHAR(50) Default Null,
+        Email VARCHAR(255) Default Null,
+        Tax_Number VARCHAR(50) Default Null,
+        Footer VARCHAR(500) Default Null,
+        Updated_At Timestamptz Default Current_Timestamp
+    );
+
+-- Prints of documents, every print after the first is a copy
+Create Table If Not Exists
+    Document_Print (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Document_Type VARCHAR(20) Not Null Check (Document_Type In ('sales_invoice', 'delivery_note', 'purchase_order', 'sales_return')),
+        Document_Id Uuid Not Null,
+        Printed_By Uuid References Appuser (Id) On Delete Set Null,
+        Printed_At Timestamptz Default Current_Timestamp
+    );
+
+Create Index If Not Exists Idx_Document_Print_Document On Document_Print (Document_Type, Document_Id);
@@ -0,0 +1,4 @@
+-- Thermal receipt template
+Alter Table Company_Setting Add Column If Not Exists Receipt_Width INT Not Null Default 58 Check (Receipt_Width In (58, 80));
+
+Alter Table Company_Setting Add Column If Not Exists Receipt_Footer VARCHAR(255) Default Null;
@@ -0,0 +1,30 @@
+-- Email addresses of customers and suppliers
+Alter Table Customer Add Column If Not Exists Email VARCHAR(255);
+
+Alter Table Supplier Add Column If Not Exists Email VARCHAR(255);
+
+-- Emails of documents, queued and retried until sent, also the send log
+Create Table If Not Exists
+    Document_Email (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Document_Type VARCHAR(30) Not Null,
+        Document_Id Uuid Not Null,
+        Recipient VARCHAR(255) Not Null,
+        Cc VARCHAR(255) Default Null,
+        Subject VARCHAR(255) Not Null,
+        Body TEXT Not Null,
+        Attachment_Name VARCHAR(255) Not Null,
+        Attachment BYTEA Not Null,
+        Status VARCHAR(20) Not Null Default 'pending' Check (Status In ('pending', 'sent', 'failed')),
+        Attempts INT Not Null Default 0,
+        Next_Attempt_At Timestamptz Default Current_Timestamp,
+        Last_Error TEXT Default Null,
+        Sent_By Uuid References Appuser (Id) On Delete Set Null,
+        Sent_At Timestamptz Default Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp
+    );
+
+Create Index If Not Exists Idx_Document_Email_Due On Document_Email (Status, Next_Attempt_At);
+
+Create Index If Not Exists Idx_Document_Email_Document On Document_Email (Document_Type, Document_Id);
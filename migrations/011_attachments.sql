@@ -0,0 +1,16 @@
+-- Files attached to documents and records, the content lives in the file storage under Storage_Key
+Create Table If Not Exists
+    Attachment (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Parent_Type VARCHAR(30) Not Null Check (Parent_Type In ('sales_order', 'sales_invoice', 'delivery_note', 'sales_return', 'purchase_order', 'finance_transaction', 'employee')),
+        Parent_Id Uuid Not Null,
+        File_Name VARCHAR(255) Not Null,
+        Content_Type VARCHAR(100) Not Null,
+        Size BIGINT Not Null,
+        Description VARCHAR(255) Default Null,
+        Storage_Key TEXT Not Null Unique,
+        Uploaded_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp
+    );
+
+Create Index If Not Exists Idx_Attachment_Parent On Attachment (Parent_Type, Parent_Id);
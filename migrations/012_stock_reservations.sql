@@ -0,0 +1,65 @@
+-- Open sales orders reserve stock instead of taking it out of Batch_Storage, the invoice issues it.
+-- A reservation no longer counts once Expires_At has passed, expired rows are swept by the backend.
+Do $$
+Begin
+    If Not Exists (Select 1 From Information_Schema.Tables Where Table_Name = 'stock_reservation') Then
+        Create Table Stock_Reservation (
+            Id Uuid Primary Key Default Uuid_Generate_V4 (),
+            Sales_Order_Detail_Id Uuid Not Null Unique References Sales_Order_Detail (Id) On Delete Cascade,
+            Batch_Storage_Id Uuid Not Null References Batch_Storage (Id) On Delete Cascade,
+            Quantity NUMERIC(15, 2) Not Null Check (Quantity > 0),
+            Expires_At Timestamptz Not Null,
+            Created_At Timestamptz Default Current_Timestamp
+        );
+
+        -- Open orders took their stock when they were saved, put it back and reserve it instead
+        Update Batch_Storage Bs
+        Set Quantity = Bs.Quantity + O.Quantity
+        From (
+            Select Sod.Batch_Storage_Id, Sum(Sod.Quantity) As Quantity
+            From Sales_Order_Detail Sod
+            Join Sales_Order So On So.Id = Sod.Sales_Order_Id
+            Where So.Status = 'order'
+            Group By Sod.Batch_Storage_Id
+        ) O
+        Where Bs.Id = O.Batch_Storage_Id;
+
+        Update Product_Batch Pb
+        Set Current_Quantity = Pb.Current_Quantity + O.Quantity
+        From (
+            Select Bs.Batch_Id, Sum(Sod.Quantity) As Quantity
+            From Sales_Order_Detail Sod
+            Join Sales_Order So On So.Id = Sod.Sales_Order_Id
+            Join Batch_Storage Bs On Bs.Id = Sod.Batch_Storage_Id
+            Where So.Status = 'order'
+            Group By Bs.Batch_Id
+        ) O
+        Where Pb.Id = O.Batch_Id;
+
+        Insert Into Stock_Reservation (Sales_Order_Detail_Id, Batch_Storage_Id, Quantity, Expires_At)
+        Select Sod.Id, Sod.Batch_Storage_Id, Sod.Quantity, Current_Timestamp + Interval '72 hours'
+        From Sales_Order_Detail Sod
+        Join Sales_Order So On So.Id = Sod.Sales_Order_Id
+        Where So.Status = 'order' And Sod.Quantity > 0;
+    End If;
+End $$;
+
+Create Index If Not Exists Idx_Stock_Reservation_Batch_Storage On Stock_Reservation (Batch_Storage_Id, Expires_At);
+
+Create Index If Not Exists Idx_Stock_Reservation_Expires_At On Stock_Reservation (Expires_At);
+
+-- On hand, reserved and available quantity of every batch storage
+Create Or Replace View Batch_Storage_Stock As
+Select
+    Bs.Id,
+    Bs.Batch_Id,
+    Bs.Storage_Id,
+    Bs.Quantity As On_Hand,
+    R.Reserved,
+    Bs.Quantity - R.Reserved As Available
+From Batch_Storage Bs
+Cross Join Lateral (
+    Select Coalesce(Sum(Sr.Quantity), 0) As Reserved
+    From Stock_Reservation Sr
+    Where Sr.Batch_Storage_Id = Bs.Id And Sr.Expires_At > Current_Timestamp
+) R;
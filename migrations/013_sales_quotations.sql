@@ -0,0 +1,54 @@
+-- Price quotations sent to customers before they commit, an accepted quotation is converted into a sales order
+Alter Table Document_Counter Drop Constraint If Exists Document_Counter_Document_Type_Check;
+
+Alter Table Document_Counter Add Constraint Document_Counter_Document_Type_Check CHECK (
+    Document_Type IN ('SO', 'SI', 'DN', 'PO', 'SR', 'PR', 'PAY', 'ADJ', 'TRF', 'SQ')
+);
+
+Alter Table Document_Numbering Drop Constraint If Exists Document_Numbering_Document_Type_Check;
+
+Alter Table Document_Numbering Add Constraint Document_Numbering_Document_Type_Check CHECK (
+    Document_Type IN ('SO', 'SI', 'DN', 'PO', 'SR', 'PR', 'PAY', 'ADJ', 'TRF', 'SQ')
+);
+
+Insert Into
+    Document_Numbering (Document_Type, Description, Prefix)
+Values
+    ('SQ', 'Penawaran Harga', 'SQ')
+On Conflict (Document_Type) Do Nothing;
+
+-- A quotation past Valid_Until while still draft or sent is reported as expired
+Create Table If Not Exists
+    Sales_Quotation (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Branch_Id Uuid References Branch (Id) On Delete Restrict,
+        Serial_Id VARCHAR(50) Not Null Unique,
+        Customer_Id Uuid Not Null References Customer (Id) On Delete Restrict,
+        Quotation_Date Timestamptz Default Current_Timestamp,
+        Valid_Until Date Not Null,
+        Status VARCHAR(20) Not Null Default 'draft' Check (Status In ('draft', 'sent', 'accepted', 'rejected')),
+        Payment_Method VARCHAR(50) Not Null Check (Payment_Method In ('cash', 'paylater')),
+        Payment_Term_Days INT Default Null Check (Payment_Term_Days > 0), -- Days from the order to its payment due date
+        Notes TEXT Default Null,
+        Total_Amount NUMERIC(15, 2) Not Null Default 0,
+        Sales_Order_Id Uuid Unique References Sales_Order (Id) On Delete Set Null, -- Set once converted
+        Created_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp
+    );
+
+Create Table If Not Exists
+    Sales_Quotation_Item (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Sales_Quotation_Id Uuid Not Null References Sales_Quotation (Id) On Delete Cascade,
+        Product_Id Uuid Not Null References Product (Id) On Delete Restrict,
+        Quantity NUMERIC(15, 2) Not Null Check (Quantity > 0),
+        Unit_Price NUMERIC(15, 2) Not Null Check (Unit_Price >= 0),
+        Created_At Timestamptz Default Current_Timestamp
+    );
+
+Create Index If Not Exists Idx_Sales_Quotation_Branch_Id On Sales_Quotation (Branch_Id);
+
+Create Index If Not Exists Idx_Sales_Quotation_Customer_Id On Sales_Quotation (Customer_Id);
+
+Create Index If Not Exists Idx_Sales_Quotation_Item_Quotation_Id On Sales_Quotation_Item (Sales_Quotation_Id);
@@ -0,0 +1,61 @@
+-- Progress billing, a sales order can be invoiced over several invoices each covering chosen lines and quantities
+Alter Table Sales_Order Drop Constraint If Exists Sales_Order_Status_Check;
+
+Alter Table Sales_Order Add Constraint Sales_Order_Status_Check CHECK (
+    Status IN (
+        'order',
+        'partially_invoiced',
+        'invoice',
+        'completed',
+        'partially_returned',
+        'returned',
+        'cancelled',
+        'delivery'
+    )
+);
+
+Alter Table Sales_Order_Detail Add Column If Not Exists Invoiced_Quantity NUMERIC(15, 2) Not Null Default 0; -- Sum of the active invoice lines
+
+Create Table If Not Exists
+    Sales_Invoice_Detail (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Sales_Invoice_Id Uuid Not Null References Sales_Invoice (Id) On Delete Cascade,
+        Sales_Order_Detail_Id Uuid Not Null References Sales_Order_Detail (Id) On Delete Cascade,
+        Quantity NUMERIC(15, 2) Not Null Check (Quantity > 0),
+        Unit_Price NUMERIC(15, 2) Not Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Unique (Sales_Invoice_Id, Sales_Order_Detail_Id)
+    );
+
+-- Invoices created before covered their whole order
+Insert Into
+    Sales_Invoice_Detail (Sales_Invoice_Id, Sales_Order_Detail_Id, Quantity, Unit_Price, Created_At)
+Select
+    Si.Id, Sod.Id, Sod.Quantity, Sod.Unit_Price, Si.Created_At
+From
+    Sales_Invoice Si
+    Join Sales_Order_Detail Sod On Sod.Sales_Order_Id = Si.Sales_Order_Id
+Where
+    Sod.Quantity > 0
+    And Not Exists (
+        Select 1 From Sales_Invoice_Detail Sid Where Sid.Sales_Invoice_Id = Si.Id
+    );
+
+Update Sales_Order_Detail Sod
+Set
+    Invoiced_Quantity = Coalesce(I.Quantity, 0)
+From
+    Sales_Order_Detail D
+    Left Join (
+        Select Sid.Sales_Order_Detail_Id, Sum(Sid.Quantity) As Quantity
+        From Sales_Invoice_Detail Sid
+        Join Sales_Invoice Si On Si.Id = Sid.Sales_Invoice_Id
+        Where Si.Cancelled_At Is Null
+        Group By Sid.Sales_Order_Detail_Id
+    ) I On I.Sales_Order_Detail_Id = D.Id
+Where
+    D.Id = Sod.Id;
+
+Create Index If Not Exists Idx_Sales_Invoice_Detail_Invoice_Id On Sales_Invoice_Detail (Sales_Invoice_Id);
+
+Create Index If Not Exists Idx_Sales_Invoice_Detail_Order_Detail_Id On Sales_Invoice_Detail (Sales_Order_Detail_Id);
@@ -0,0 +1,72 @@
+-- Partial deliveries, an invoice can be delivered over several delivery notes each carrying chosen lines and quantities
+Alter Table Sales_Order Drop Constraint If Exists Sales_Order_Status_Check;
+
+Alter Table Sales_Order Add Constraint Sales_Order_Status_Check CHECK (
+    Status IN (
+        'order',
+        'partially_invoiced',
+        'invoice',
+        'partially_delivered',
+        'completed',
+        'partially_returned',
+        'returned',
+        'cancelled',
+        'delivery'
+    )
+);
+
+Alter Table Sales_Order_Detail Add Column If Not Exists Delivered_Quantity NUMERIC(15, 2) Not Null Default 0; -- Sum of the active delivery note lines
+
+Create Table If Not Exists
+    Delivery_Note_Detail (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Delivery_Note_Id Uuid Not Null References Delivery_Note (Id) On Delete Cascade,
+        Sales_Order_Detail_Id Uuid Not Null References Sales_Order_Detail (Id) On Delete Cascade,
+        Batch_Storage_Id Uuid References Batch_Storage (Id) On Delete Set Null, -- Storage the goods were loaded from
+        Quantity NUMERIC(15, 2) Not Null Check (Quantity > 0),
+        Created_At Timestamptz Default Current_Timestamp,
+        Unique (Delivery_Note_Id, Sales_Order_Detail_Id)
+    );
+
+-- Delivery notes created before carried their whole invoice
+Insert Into
+    Delivery_Note_Detail (Delivery_Note_Id, Sales_Order_Detail_Id, Batch_Storage_Id, Quantity, Created_At)
+Select
+    Dn.Id, Sid.Sales_Order_Detail_Id, Sod.Batch_Storage_Id, Sid.Quantity, Dn.Created_At
+From
+    Delivery_Note Dn
+    Join Sales_Invoice_Detail Sid On Sid.Sales_Invoice_Id = Dn.Sales_Invoice_Id
+    Join Sales_Order_Detail Sod On Sod.Id = Sid.Sales_Order_Detail_Id
+Where
+    Not Exists (
+        Select 1 From Delivery_Note_Detail Dnd Where Dnd.Delivery_Note_Id = Dn.Id
+    );
+
+Update Sales_Order_Detail Sod
+Set
+    Delivered_Quantity = Coalesce(D.Quantity, 0)
+From
+    Sales_Order_Detail S
+    Left Join (
+        Select Dnd.Sales_Order_Detail_Id, Sum(Dnd.Quantity) As Quantity
+        From Delivery_Note_Detail Dnd
+        Join Delivery_Note Dn On Dn.Id = Dnd.Delivery_Note_Id
+        Where Dn.Cancelled_At Is Null
+        Group By Dnd.Sales_Order_Detail_Id
+    ) D On D.Sales_Order_Detail_Id = S.Id
+Where
+    S.Id = Sod.Id;
+
+-- Orders with some invoices delivered are partially delivered, delivery now means fully delivered
+Update Sales_Order So
+Set
+    Status = 'partially_delivered'
+Where
+    So.Status In ('invoice', 'partially_invoiced')
+    And Exists (
+        Select 1 From Sales_Order_Detail Sod Where Sod.Sales_Order_Id = So.Id And Sod.Delivered_Quantity > 0
+    );
+
+Create Index If Not Exists Idx_Delivery_Note_Detail_Delivery_Note_Id On Delivery_Note_Detail (Delivery_Note_Id);
+
+Create Index If Not Exists Idx_Delivery_Note_Detail_Order_Detail_Id On Delivery_Note_Detail (Sales_Order_Detail_Id);
\ No newline at end of file
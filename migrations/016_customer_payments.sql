@@ -0,0 +1,73 @@
+-- Customer payments settling paylater invoices, a payment is allocated over one or more invoices
+Create Table If Not Exists
+    Customer_Payment (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Branch_Id Uuid References Branch (Id) On Delete Restrict,
+        Serial_Id VARCHAR(50) Not Null Unique,
+        Customer_Id Uuid Not Null References Customer (Id) On Delete Restrict,
+        Payment_Date Timestamptz Not Null Default Current_Timestamp,
+        Payment_Method VARCHAR(20) Not Null Check (Payment_Method In ('cash', 'transfer', 'giro')),
+        Amount NUMERIC(15, 2) Not Null Check (Amount > 0),
+        Reference VARCHAR(255) Default Null, -- Transfer or giro number
+        Notes TEXT Default Null,
+        Created_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Cancelled_At Timestamptz Default Null,
+        Cancelled_By Uuid References Appuser (Id) On Delete Set Null
+    );
+
+Create Table If Not Exists
+    Customer_Payment_Allocation (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Customer_Payment_Id Uuid Not Null References Customer_Payment (Id) On Delete Cascade,
+        Sales_Invoice_Id Uuid Not Null References Sales_Invoice (Id) On Delete Restrict,
+        Amount NUMERIC(15, 2) Not Null Check (Amount > 0),
+        Created_At Timestamptz Default Current_Timestamp,
+        Unique (Customer_Payment_Id, Sales_Invoice_Id)
+    );
+
+-- Payments are posted to the finance log, they settle income booked when the invoice was made
+Alter Table Financial_Transaction_Log Add Column If Not Exists Customer_Payment_Id Uuid References Customer_Payment (Id) On Delete Set Null;
+
+-- Paid and outstanding amount of every invoice, cash sales are settled when invoiced
+Create Or Replace View Sales_Invoice_Balance As
+Select
+    Si.Id,
+    Si.Sales_Order_Id,
+    So.Customer_Id,
+    So.Branch_Id,
+    So.Payment_Method,
+    So.Payment_Due_Date As Due_Date,
+    Si.Total_Amount,
+    P.Paid_Amount,
+    Si.Total_Amount - P.Paid_Amount As Outstanding_Amount,
+    Case
+        When Si.Cancelled_At Is Not Null Then 'cancelled'
+        When P.Paid_Amount >= Si.Total_Amount Then 'paid'
+        When So.Payment_Due_Date < Current_Timestamp Then 'overdue'
+        When P.Paid_Amount > 0 Then 'partially_paid'
+        Else 'unpaid'
+    End As Payment_Status
+From Sales_Invoice Si
+Join Sales_Order So On So.Id = Si.Sales_Order_Id
+Cross Join Lateral (
+    Select Case
+        When So.Payment_Method = 'cash' Then Si.Total_Amount
+        Else (
+            Select Coalesce(Sum(Cpa.Amount), 0)
+            From Customer_Payment_Allocation Cpa
+            Join Customer_Payment Cp On Cp.Id = Cpa.Customer_Payment_Id
+            Where Cpa.Sales_Invoice_Id = Si.Id And Cp.Cancelled_At Is Null
+        )
+    End As Paid_Amount
+) P;
+
+Create Index If Not Exists Idx_Customer_Payment_Customer_Id On Customer_Payment (Customer_Id);
+
+Create Index If Not Exists Idx_Customer_Payment_Branch_Id On Customer_Payment (Branch_Id);
+
+Create Index If Not Exists Idx_Customer_Payment_Allocation_Payment_Id On Customer_Payment_Allocation (Customer_Payment_Id);
+
+Create Index If Not Exists Idx_Customer_Payment_Allocation_Invoice_Id On Customer_Payment_Allocation (Sales_Invoice_Id);
+
+Create Index If Not Exists Idx_Financial_Transaction_Log_Customer_Payment_Id On Financial_Transaction_Log (Customer_Payment_Id);
\ No newline at end of file
@@ -0,0 +1,64 @@
+-- Sales returns credit the invoice that billed the returned line, through their delivery note when they have one
+-- and otherwise the latest invoice carrying the line
+Create Or Replace View Sales_Invoice_Return As
+Select
+    Sor.Id,
+    Sor.Serial_Id,
+    Sor.Sales_Order_Id,
+    L.Sales_Invoice_Id,
+    Sor.Return_Quantity * L.Unit_Price As Amount,
+    Sor.Returned_At,
+    Sor.Cancelled_At
+From Sales_Order_Return Sor
+Left Join Delivery_Note Dn On Dn.Id = Sor.Delivery_Note_Id
+Join Lateral (
+    Select Sid.Sales_Invoice_Id, Sid.Unit_Price
+    From Sales_Invoice_Detail Sid
+    Join Sales_Invoice Si On Si.Id = Sid.Sales_Invoice_Id
+    Where Sid.Sales_Order_Detail_Id = Sor.Sales_Detail_Id
+        And (Dn.Sales_Invoice_Id Is Null Or Sid.Sales_Invoice_Id = Dn.Sales_Invoice_Id)
+    Order By Si.Cancelled_At Is Null Desc, Si.Invoice_Date Desc, Si.Created_At Desc
+    Limit 1
+) L On True
+Where Sor.Return_Status <> 'pending';
+
+-- Returned goods reduce what the customer owes on the invoice
+Drop View If Exists Sales_Invoice_Balance;
+
+Create View Sales_Invoice_Balance As
+Select
+    Si.Id,
+    Si.Sales_Order_Id,
+    So.Customer_Id,
+    So.Branch_Id,
+    So.Payment_Method,
+    So.Payment_Due_Date As Due_Date,
+    Si.Total_Amount,
+    P.Paid_Amount,
+    R.Returned_Amount,
+    Greatest(Si.Total_Amount - P.Paid_Amount - R.Returned_Amount, 0) As Outstanding_Amount,
+    Case
+        When Si.Cancelled_At Is Not Null Then 'cancelled'
+        When P.Paid_Amount + R.Returned_Amount >= Si.Total_Amount Then 'paid'
+        When So.Payment_Due_Date < Current_Timestamp Then 'overdue'
+        When P.Paid_Amount > 0 Then 'partially_paid'
+        Else 'unpaid'
+    End As Payment_Status
+From Sales_Invoice Si
+Join Sales_Order So On So.Id = Si.Sales_Order_Id
+Cross Join Lateral (
+    Select Coalesce(Sum(Sir.Amount), 0) As Returned_Amount
+    From Sales_Invoice_Return Sir
+    Where Sir.Sales_Invoice_Id = Si.Id And Sir.Cancelled_At Is Null
+) R
+Cross Join Lateral (
+    Select Case
+        When So.Payment_Method = 'cash' Then Greatest(Si.Total_Amount - R.Returned_Amount, 0)
+        Else (
+            Select Coalesce(Sum(Cpa.Amount), 0)
+            From Customer_Payment_Allocation Cpa
+            Join Customer_Payment Cp On Cp.Id = Cpa.Customer_Payment_Id
+            Where Cpa.Sales_Invoice_Id = Si.Id And Cp.Cancelled_At Is Null
+        )
+    End As Paid_Amount
+) P;
@@ -0,0 +1,22 @@
+-- Credit limits of paylater customers, no limit when null
+Alter Table Customer Add Column If Not Exists Credit_Limit NUMERIC(15, 2) Default Null Check (Credit_Limit >= 0); -- Maximum outstanding paylater amount
+
+Alter Table Customer Add Column If Not Exists Max_Overdue_Days INT Default Null Check (Max_Overdue_Days >= 0); -- Oldest overdue invoice allowed before new paylater orders are blocked
+
+-- Paylater orders let through over the credit limit or overdue days of the customer, with who approved them
+Create Table If Not Exists
+    Sales_Order_Credit_Override (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Sales_Order_Id Uuid Not Null References Sales_Order (Id) On Delete Cascade,
+        Customer_Id Uuid Not Null References Customer (Id) On Delete Restrict,
+        Order_Amount NUMERIC(15, 2) Not Null, -- Amount the order or added item brought in
+        Outstanding_Amount NUMERIC(15, 2) Not Null, -- Receivable and uninvoiced paylater orders before it
+        Credit_Limit NUMERIC(15, 2) Default Null,
+        Overdue_Days INT Not Null Default 0,
+        Max_Overdue_Days INT Default Null,
+        Reason TEXT Not Null,
+        Approved_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp
+    );
+
+Create Index If Not Exists Idx_Sales_Order_Credit_Override_Order_Id On Sales_Order_Credit_Override (Sales_Order_Id);
@@ -0,0 +1,51 @@
+-- Price lists, customers on a list are offered its prices instead of typed ones
+Create Table If Not Exists
+    Price_List (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Name VARCHAR(255) Not Null,
+        Description TEXT,
+        Is_Active BOOLEAN Not Null Default True,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp,
+        Deleted_At Timestamptz Default Null
+    );
+
+Create Unique Index If Not Exists Idx_Price_List_Name On Price_List (Lower(Name)) Where Deleted_At Is Null;
+
+-- Product prices of a list, the row with the highest minimum quantity reached applies
+Create Table If Not Exists
+    Price_List_Item (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Price_List_Id Uuid Not Null References Price_List (Id) On Delete Cascade,
+        Product_Id Uuid Not Null References Product (Id) On Delete Cascade,
+        Min_Quantity NUMERIC(15, 2) Not Null Default 1 Check (Min_Quantity > 0),
+        Unit_Price NUMERIC(15, 2) Not Null Check (Unit_Price > 0),
+        Valid_From Date Default Null, -- Open ended when null
+        Valid_Until Date Default Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Check (Valid_Until Is Null Or Valid_From Is Null Or Valid_Until >= Valid_From)
+    );
+
+Create Index If Not Exists Idx_Price_List_Item_List_Product On Price_List_Item (Price_List_Id, Product_Id);
+
+Alter Table Customer Add Column If Not Exists Price_List_Id Uuid Default Null References Price_List (Id) On Delete Set Null;
+
+-- Order lines remember the list price they were offered and whether it was changed by hand
+Alter Table Sales_Order_Detail Add Column If Not Exists List_Price NUMERIC(15, 2) Default Null;
+
+Alter Table Sales_Order_Detail Add Column If Not Exists Price_Overridden BOOLEAN Not Null Default False;
+
+-- Price of a product on the active price list of a customer for a quantity on a date, null when the list has none
+Create Or Replace Function Customer_List_Price (P_Customer_Id Uuid, P_Product_Id Uuid, P_Quantity NUMERIC, P_Date Date Default Current_Date) Returns NUMERIC As $$
+    Select Pli.Unit_Price
+    From Customer C
+    Join Price_List Pl On Pl.Id = C.Price_List_Id And Pl.Is_Active And Pl.Deleted_At Is Null
+    Join Price_List_Item Pli On Pli.Price_List_Id = Pl.Id
+    Where C.Id = P_Customer_Id
+        And Pli.Product_Id = P_Product_Id
+        And Pli.Min_Quantity <= P_Quantity
+        And (Pli.Valid_From Is Null Or Pli.Valid_From <= P_Date)
+        And (Pli.Valid_Until Is Null Or Pli.Valid_Until >= P_Date)
+    Order By Pli.Min_Quantity Desc, Pli.Valid_From Desc Nulls Last
+    Limit 1
+$$ Language Sql Stable;
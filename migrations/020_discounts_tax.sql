@@ -0,0 +1,117 @@
+-- PPN new sales and purchase orders start with, orders keep the rate and mode they were created with
+Alter Table Company_Setting Add Column If Not Exists Tax_Rate NUMERIC(5, 2) Not Null Default 0 Check (Tax_Rate >= 0 And Tax_Rate <= 100);
+
+Alter Table Company_Setting Add Column If Not Exists Tax_Mode VARCHAR(20) Not Null Default 'exclusive' Check (Tax_Mode In ('exclusive', 'inclusive'));
+
+-- Order discounts and tax, Total_Amount stays the grand total
+Alter Table Sales_Order Add Column If Not Exists Discount_Type VARCHAR(20) Default Null Check (Discount_Type In ('percent', 'amount'));
+
+Alter Table Sales_Order Add Column If Not Exists Discount_Value NUMERIC(15, 2) Not Null Default 0 Check (Discount_Value >= 0);
+
+Alter Table Sales_Order Add Column If Not Exists Tax_Rate NUMERIC(5, 2) Not Null Default 0 Check (Tax_Rate >= 0 And Tax_Rate <= 100);
+
+Alter Table Sales_Order Add Column If Not Exists Tax_Mode VARCHAR(20) Not Null Default 'exclusive' Check (Tax_Mode In ('exclusive', 'inclusive'));
+
+Alter Table Sales_Order Add Column If Not Exists Subtotal NUMERIC(15, 2) Default Null; -- Lines before discounts
+
+Alter Table Sales_Order Add Column If Not Exists Discount_Amount NUMERIC(15, 2) Not Null Default 0; -- Line and order discounts
+
+Alter Table Sales_Order Add Column If Not Exists Tax_Base NUMERIC(15, 2) Default Null; -- DPP
+
+Alter Table Sales_Order Add Column If Not Exists Tax_Amount NUMERIC(15, 2) Not Null Default 0; -- PPN
+
+Alter Table Sales_Order_Detail Add Column If Not Exists Discount_Type VARCHAR(20) Default Null Check (Discount_Type In ('percent', 'amount'));
+
+Alter Table Sales_Order_Detail Add Column If Not Exists Discount_Value NUMERIC(15, 2) Not Null Default 0 Check (Discount_Value >= 0);
+
+Alter Table Sales_Order_Detail Add Column If Not Exists Discount_Amount NUMERIC(15, 2) Not Null Default 0;
+
+Alter Table Sales_Order_Detail Add Column If Not Exists Line_Total NUMERIC(15, 2) Default Null; -- Share of the grand total
+
+Alter Table Sales_Invoice Add Column If Not Exists Subtotal NUMERIC(15, 2) Default Null;
+
+Alter Table Sales_Invoice Add Column If Not Exists Discount_Amount NUMERIC(15, 2) Not Null Default 0;
+
+Alter Table Sales_Invoice Add Column If Not Exists Tax_Base NUMERIC(15, 2) Default Null;
+
+Alter Table Sales_Invoice Add Column If Not Exists Tax_Amount NUMERIC(15, 2) Not Null Default 0;
+
+Alter Table Sales_Invoice Add Column If Not Exists Tax_Rate NUMERIC(5, 2) Not Null Default 0;
+
+Alter Table Sales_Invoice Add Column If Not Exists Tax_Mode VARCHAR(20) Not Null Default 'exclusive';
+
+Alter Table Sales_Invoice_Detail Add Column If Not Exists Discount_Amount NUMERIC(15, 2) Not Null Default 0;
+
+Alter Table Sales_Invoice_Detail Add Column If Not Exists Line_Total NUMERIC(15, 2) Default Null;
+
+Alter Table Purchase_Order Add Column If Not Exists Discount_Type VARCHAR(20) Default Null Check (Discount_Type In ('percent', 'amount'));
+
+Alter Table Purchase_Order Add Column If Not Exists Discount_Value NUMERIC(15, 2) Not Null Default 0 Check (Discount_Value >= 0);
+
+Alter Table Purchase_Order Add Column If Not Exists Tax_Rate NUMERIC(5, 2) Not Null Default 0 Check (Tax_Rate >= 0 And Tax_Rate <= 100);
+
+Alter Table Purchase_Order Add Column If Not Exists Tax_Mode VARCHAR(20) Not Null Default 'exclusive' Check (Tax_Mode In ('exclusive', 'inclusive'));
+
+Alter Table Purchase_Order Add Column If Not Exists Subtotal NUMERIC(15, 2) Default Null;
+
+Alter Table Purchase_Order Add Column If Not Exists Discount_Amount NUMERIC(15, 2) Not Null Default 0;
+
+Alter Table Purchase_Order Add Column If Not Exists Tax_Base NUMERIC(15, 2) Default Null;
+
+Alter Table Purchase_Order Add Column If Not Exists Tax_Amount NUMERIC(15, 2) Not Null Default 0;
+
+Alter Table Purchase_Order_Detail Add Column If Not Exists Discount_Type VARCHAR(20) Default Null Check (Discount_Type In ('percent', 'amount'));
+
+Alter Table Purchase_Order_Detail Add Column If Not Exists Discount_Value NUMERIC(15, 2) Not Null Default 0 Check (Discount_Value >= 0);
+
+Alter Table Purchase_Order_Detail Add Column If Not Exists Discount_Amount NUMERIC(15, 2) Not Null Default 0;
+
+Alter Table Purchase_Order_Detail Add Column If Not Exists Line_Total NUMERIC(15, 2) Default Null;
+
+-- Documents from before discounts and tax were priced at quantity times unit price
+Update Sales_Order Set Subtotal = Total_Amount, Tax_Base = Total_Amount Where Subtotal Is Null;
+
+Update Sales_Order_Detail Set Line_Total = Quantity * Unit_Price Where Line_Total Is Null;
+
+Update Sales_Invoice Set Subtotal = Total_Amount, Tax_Base = Total_Amount Where Subtotal Is Null;
+
+Update Sales_Invoice_Detail Set Line_Total = Quantity * Unit_Price Where Line_Total Is Null;
+
+Update Purchase_Order Set Subtotal = Total_Amount, Tax_Base = Total_Amount Where Subtotal Is Null;
+
+Update Purchase_Order_Detail Set Line_Total = Requested_Quantity * Unit_Price Where Line_Total Is Null;
+
+Alter Table Sales_Order Alter Column Subtotal Set Default 0, Alter Column Subtotal Set Not Null, Alter Column Tax_Base Set Default 0, Alter Column Tax_Base Set Not Null;
+
+Alter Table Sales_Order_Detail Alter Column Line_Total Set Default 0, Alter Column Line_Total Set Not Null;
+
+Alter Table Sales_Invoice Alter Column Subtotal Set Default 0, Alter Column Subtotal Set Not Null, Alter Column Tax_Base Set Default 0, Alter Column Tax_Base Set Not Null;
+
+Alter Table Sales_Invoice_Detail Alter Column Line_Total Set Default 0, Alter Column Line_Total Set Not Null;
+
+Alter Table Purchase_Order Alter Column Subtotal Set Default 0, Alter Column Subtotal Set Not Null, Alter Column Tax_Base Set Default 0, Alter Column Tax_Base Set Not Null;
+
+Alter Table Purchase_Order_Detail Alter Column Line_Total Set Default 0, Alter Column Line_Total Set Not Null;
+
+-- Returns credit the invoice at what was charged for the line after discounts and tax
+Create Or Replace View Sales_Invoice_Return As
+Select
+    Sor.Id,
+    Sor.Serial_Id,
+    Sor.Sales_Order_Id,
+    L.Sales_Invoice_Id,
+    Round(Sor.Return_Quantity * L.Line_Total / L.Quantity, 2) As Amount,
+    Sor.Returned_At,
+    Sor.Cancelled_At
+From Sales_Order_Return Sor
+Left Join Delivery_Note Dn On Dn.Id = Sor.Delivery_Note_Id
+Join Lateral (
+    Select Sid.Sales_Invoice_Id, Sid.Quantity, Sid.Line_Total
+    From Sales_Invoice_Detail Sid
+    Join Sales_Invoice Si On Si.Id = Sid.Sales_Invoice_Id
+    Where Sid.Sales_Order_Detail_Id = Sor.Sales_Detail_Id
+        And (Dn.Sales_Invoice_Id Is Null Or Sid.Sales_Invoice_Id = Dn.Sales_Invoice_Id)
+    Order By Si.Cancelled_At Is Null Desc, Si.Invoice_Date Desc, Si.Created_At Desc
+    Limit 1
+) L On True
+Where Sor.Return_Status <> 'pending';
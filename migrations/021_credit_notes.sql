@@ -0,0 +1,132 @@
+-- Credit notes (nota kredit) for sales returns, numbered with their own serial type
+Alter Table Document_Counter Drop Constraint If Exists Document_Counter_Document_Type_Check;
+
+Alter Table Document_Counter Add Constraint Document_Counter_Document_Type_Check CHECK (
+    Document_Type IN ('SO', 'SI', 'DN', 'PO', 'SR', 'PR', 'PAY', 'ADJ', 'TRF', 'SQ', 'CN')
+);
+
+Alter Table Document_Numbering Drop Constraint If Exists Document_Numbering_Document_Type_Check;
+
+Alter Table Document_Numbering Add Constraint Document_Numbering_Document_Type_Check CHECK (
+    Document_Type IN ('SO', 'SI', 'DN', 'PO', 'SR', 'PR', 'PAY', 'ADJ', 'TRF', 'SQ', 'CN')
+);
+
+Insert Into
+    Document_Numbering (Document_Type, Description, Prefix)
+Values
+    ('CN', 'Nota Kredit', 'CN')
+On Conflict (Document_Type) Do Nothing;
+
+Alter Table Document_Print Drop Constraint If Exists Document_Print_Document_Type_Check;
+
+Alter Table Document_Print Add Constraint Document_Print_Document_Type_Check Check (
+    Document_Type In ('sales_invoice', 'delivery_note', 'purchase_order', 'sales_return', 'credit_note')
+);
+
+-- A return gets at most one credit note, applied to open paylater invoices of the customer or refunded.
+-- It is cancelled together with its return.
+Create Table If Not Exists
+    Credit_Note (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Branch_Id Uuid References Branch (Id) On Delete Restrict,
+        Serial_Id VARCHAR(50) Not Null Unique,
+        Sales_Order_Return_Id Uuid Not Null Unique References Sales_Order_Return (Id) On Delete Restrict,
+        Customer_Id Uuid Not Null References Customer (Id) On Delete Restrict,
+        Credit_Date Timestamptz Not Null Default Current_Timestamp,
+        Amount NUMERIC(15, 2) Not Null Check (Amount > 0),
+        Settlement VARCHAR(20) Not Null Check (Settlement In ('applied', 'refunded')),
+        Refund_Method VARCHAR(20) Default Null Check (Refund_Method In ('cash', 'transfer')), -- Set when refunded
+        Refund_Reference VARCHAR(255) Default Null, -- Transfer number
+        Notes TEXT Default Null,
+        Created_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Cancelled_At Timestamptz Default Null,
+        Cancelled_By Uuid References Appuser (Id) On Delete Set Null
+    );
+
+Create Table If Not Exists
+    Credit_Note_Allocation (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Credit_Note_Id Uuid Not Null References Credit_Note (Id) On Delete Cascade,
+        Sales_Invoice_Id Uuid Not Null References Sales_Invoice (Id) On Delete Restrict,
+        Amount NUMERIC(15, 2) Not Null Check (Amount > 0),
+        Created_At Timestamptz Default Current_Timestamp,
+        Unique (Credit_Note_Id, Sales_Invoice_Id)
+    );
+
+-- Refunds are posted to the finance log as money paid out
+Alter Table Financial_Transaction_Log Add Column If Not Exists Credit_Note_Id Uuid References Credit_Note (Id) On Delete Set Null;
+
+-- Credits on invoices, a return credits the invoice that billed its line until a credit note is issued for it,
+-- from then on the credit note allocations take its place
+Create Or Replace View Sales_Invoice_Credit As
+Select
+    Sir.Id,
+    Sir.Serial_Id,
+    'return' As Type,
+    Sir.Sales_Invoice_Id,
+    Sir.Amount,
+    Sir.Returned_At As Credit_Date,
+    Coalesce(Cn.Credit_Date, Sir.Cancelled_At) As Cancelled_At
+From Sales_Invoice_Return Sir
+Left Join Credit_Note Cn On Cn.Sales_Order_Return_Id = Sir.Id
+Union All
+Select
+    Cn.Id,
+    Cn.Serial_Id,
+    'credit_note',
+    Cna.Sales_Invoice_Id,
+    Cna.Amount,
+    Cn.Credit_Date,
+    Cn.Cancelled_At
+From Credit_Note_Allocation Cna
+Join Credit_Note Cn On Cn.Id = Cna.Credit_Note_Id;
+
+-- Paid, returned and outstanding amount of every invoice, cash sales are settled when invoiced
+Create Or Replace View Sales_Invoice_Balance As
+Select
+    Si.Id,
+    Si.Sales_Order_Id,
+    So.Customer_Id,
+    So.Branch_Id,
+    So.Payment_Method,
+    So.Payment_Due_Date As Due_Date,
+    Si.Total_Amount,
+    P.Paid_Amount,
+    R.Returned_Amount,
+    Greatest(Si.Total_Amount - P.Paid_Amount - R.Returned_Amount, 0) As Outstanding_Amount,
+    Case
+        When Si.Cancelled_At Is Not Null Then 'cancelled'
+        When P.Paid_Amount + R.Returned_Amount >= Si.Total_Amount Then 'paid'
+        When So.Payment_Due_Date < Current_Timestamp Then 'overdue'
+        When P.Paid_Amount > 0 Then 'partially_paid'
+        Else 'unpaid'
+    End As Payment_Status
+From Sales_Invoice Si
+Join Sales_Order So On So.Id = Si.Sales_Order_Id
+Cross Join Lateral (
+    Select Coalesce(Sum(Sic.Amount), 0) As Returned_Amount
+    From Sales_Invoice_Credit Sic
+    Where Sic.Sales_Invoice_Id = Si.Id And Sic.Cancelled_At Is Null
+) R
+Cross Join Lateral (
+    Select Case
+        When So.Payment_Method = 'cash' Then Greatest(Si.Total_Amount - R.Returned_Amount, 0)
+        Else (
+            Select Coalesce(Sum(Cpa.Amount), 0)
+            From Customer_Payment_Allocation Cpa
+            Join Customer_Payment Cp On Cp.Id = Cpa.Customer_Payment_Id
+            Where Cpa.Sales_Invoice_Id = Si.Id And Cp.Cancelled_At Is Null
+        )
+    End As Paid_Amount
+) P;
+
+Create Index If Not Exists Idx_Credit_Note_Customer_Id On Credit_Note (Customer_Id);
+
+Create Index If Not Exists Idx_Credit_Note_Branch_Id On Credit_Note (Branch_Id);
+
+Create Index If Not Exists Idx_Credit_Note_Allocation_Credit_Note_Id On Credit_Note_Allocation (Credit_Note_Id);
+
+Create Index If Not Exists Idx_Credit_Note_Allocation_Invoice_Id On Credit_Note_Allocation (Sales_Invoice_Id);
+
+Create Index If Not Exists Idx_Financial_Transaction_Log_Credit_Note_Id On Financial_Transaction_Log (Credit_Note_Id);
@@ -0,0 +1,30 @@
+-- Typo matching uses the "<%" operator, which the trigram indexes serve, at the threshold of utils.SearchSimilarityThreshold
+Do $$
+Begin
+    -- Load pg_trgm so its settings are known before they are stored
+    Perform Word_Similarity('a', 'a');
+    Execute Format('Alter Database %I Set pg_trgm.word_similarity_threshold = 0.3', Current_Database());
+End
+$$;
+
+-- Word prefix matching of the fuzzy search
+Create Index If Not Exists Idx_Product_Name_Fts On Product Using Gin (To_Tsvector('simple', Name));
+
+Create Index If Not Exists Idx_Product_Description_Fts On Product Using Gin (To_Tsvector('simple', Description));
+
+Create Index If Not Exists Idx_Category_Name_Fts On Category Using Gin (To_Tsvector('simple', Name));
+
+Create Index If Not Exists Idx_Unit_Name_Fts On Unit Using Gin (To_Tsvector('simple', Name));
+
+Create Index If Not Exists Idx_Customer_Name_Fts On Customer Using Gin (To_Tsvector('simple', Name));
+
+Create Index If Not Exists Idx_Customer_Address_Fts On Customer Using Gin (To_Tsvector('simple', Address));
+
+Create Index If Not Exists Idx_Supplier_Name_Fts On Supplier Using Gin (To_Tsvector('simple', Name));
+
+Create Index If Not Exists Idx_Supplier_Address_Fts On Supplier Using Gin (To_Tsvector('simple', Address));
+
+-- Telephone numbers are searched along with names and addresses
+Create Index If Not Exists Idx_Customer_Telephone_Trgm On Customer Using Gin (Telephone Gin_Trgm_Ops);
+
+Create Index If Not Exists Idx_Supplier_Telephone_Trgm On Supplier Using Gin (Telephone Gin_Trgm_Ops);
@@ -0,0 +1,17 @@
+-- Announce every committed outbox event, so each app instance streams it to its own clients
+-- while the dispatcher still hands it to a single instance for the at-least-once subscribers
+Create Or Replace Function Notify_Outbox_Event () Returns Trigger As $$
+Begin
+    Perform Pg_Notify('outbox_event', New.Id::Text);
+    Return New;
+End;
+$$ Language Plpgsql;
+
+Drop Trigger If Exists Trg_Outbox_Event_Notify On Outbox_Event;
+
+Create Trigger Trg_Outbox_Event_Notify
+After Insert On Outbox_Event
+For Each Row Execute Function Notify_Outbox_Event ();
+
+-- Processed events are purged once they are past the retention period
+Create Index If Not Exists Idx_Outbox_Event_Processed On Outbox_Event (Processed_At) Where Processed_At Is Not Null;
@@ -0,0 +1,78 @@
+-- Letterhead a document was issued with, so reprints keep the company and branch data of the original
+Create Table If Not Exists
+    Document_Letterhead (
+        Document_Type VARCHAR(20) Not Null,
+        Document_Id Uuid Not Null,
+        Name VARCHAR(255) Not Null,
+        Address TEXT Default Null,
+        Telephone VARCHAR(50) Default Null,
+        Email VARCHAR(255) Default Null,
+        Tax_Number VARCHAR(50) Default Null,
+        Footer VARCHAR(500) Default Null,
+        Receipt_Footer VARCHAR(255) Default Null,
+        Branch_Name VARCHAR(255) Default Null,
+        Branch_Address TEXT Default Null,
+        Branch_Telephone VARCHAR(50) Default Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Primary Key (Document_Type, Document_Id)
+    );
+
+-- Take the letterhead of a document when it is issued, the document type is the trigger argument.
+-- Documents without one, issued before this migration or before the letterhead was set up, print with the current one.
+Create Or Replace Function Snapshot_Document_Letterhead () Returns Trigger As $$
+Declare
+    Document_Branch_Id Uuid;
+Begin
+    If Tg_Table_Name In ('sales_order', 'purchase_order', 'credit_note') Then
+        Document_Branch_Id := New.Branch_Id;
+    Else
+        Select Branch_Id Into Document_Branch_Id From Sales_Order Where Id = New.Sales_Order_Id;
+    End If;
+
+    Insert Into Document_Letterhead (Document_Type, Document_Id, Name, Address, Telephone, Email, Tax_Number, Footer,
+        Receipt_Footer, Branch_Name, Branch_Address, Branch_Telephone)
+    Select Tg_Argv[0], New.Id, Cs.Name, Cs.Address, Cs.Telephone, Cs.Email, Cs.Tax_Number, Cs.Footer,
+        Cs.Receipt_Footer, B.Name, B.Address, B.Telephone
+    From Company_Setting Cs
+    Left Join Branch B On B.Id = Document_Branch_Id
+    On Conflict Do Nothing;
+
+    Return New;
+End;
+$$ Language Plpgsql;
+
+Drop Trigger If Exists Trg_Sales_Order_Letterhead On Sales_Order;
+
+Create Trigger Trg_Sales_Order_Letterhead
+After Insert On Sales_Order
+For Each Row Execute Function Snapshot_Document_Letterhead ('sales_order');
+
+Drop Trigger If Exists Trg_Sales_Invoice_Letterhead On Sales_Invoice;
+
+Create Trigger Trg_Sales_Invoice_Letterhead
+After Insert On Sales_Invoice
+For Each Row Execute Function Snapshot_Document_Letterhead ('sales_invoice');
+
+Drop Trigger If Exists Trg_Delivery_Note_Letterhead On Delivery_Note;
+
+Create Trigger Trg_Delivery_Note_Letterhead
+After Insert On Delivery_Note
+For Each Row Execute Function Snapshot_Document_Letterhead ('delivery_note');
+
+Drop Trigger If Exists Trg_Sales_Return_Letterhead On Sales_Order_Return;
+
+Create Trigger Trg_Sales_Return_Letterhead
+After Insert On Sales_Order_Return
+For Each Row Execute Function Snapshot_Document_Letterhead ('sales_return');
+
+Drop Trigger If Exists Trg_Credit_Note_Letterhead On Credit_Note;
+
+Create Trigger Trg_Credit_Note_Letterhead
+After Insert On Credit_Note
+For Each Row Execute Function Snapshot_Document_Letterhead ('credit_note');
+
+Drop Trigger If Exists Trg_Purchase_Order_Letterhead On Purchase_Order;
+
+Create Trigger Trg_Purchase_Order_Letterhead
+After Insert On Purchase_Order
+For Each Row Execute Function Snapshot_Document_Letterhead ('purchase_order');
@@ -0,0 +1,8 @@
+-- Cash handed over on a cash sale and the change given back, printed on its receipt
+Alter Table Sales_Order Add Column If Not Exists Amount_Tendered NUMERIC(15, 2) Default Null;
+
+Alter Table Sales_Order Add Column If Not Exists Change_Amount NUMERIC(15, 2) Default Null;
+
+Alter Table Sales_Invoice Add Column If Not Exists Amount_Tendered NUMERIC(15, 2) Default Null;
+
+Alter Table Sales_Invoice Add Column If Not Exists Change_Amount NUMERIC(15, 2) Default Null;
@@ -0,0 +1,5 @@
+-- Products on a price list are not deleted along with their prices, purging them is refused instead
+Alter Table Price_List_Item Drop Constraint If Exists Price_List_Item_Product_Id_Fkey;
+
+Alter Table Price_List_Item Add Constraint Price_List_Item_Product_Id_Fkey
+    Foreign Key (Product_Id) References Product (Id) On Delete Restrict;
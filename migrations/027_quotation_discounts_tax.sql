@@ -0,0 +1,33 @@
+-- Quotations are priced like the sales orders they turn into, with line and quotation discounts and PPN
+Alter Table Sales_Quotation Add Column If Not Exists Discount_Type VARCHAR(20) Default Null Check (Discount_Type In ('percent', 'amount'));
+
+Alter Table Sales_Quotation Add Column If Not Exists Discount_Value NUMERIC(15, 2) Not Null Default 0 Check (Discount_Value >= 0);
+
+Alter Table Sales_Quotation Add Column If Not Exists Tax_Rate NUMERIC(5, 2) Not Null Default 0 Check (Tax_Rate >= 0 And Tax_Rate <= 100);
+
+Alter Table Sales_Quotation Add Column If Not Exists Tax_Mode VARCHAR(20) Not Null Default 'exclusive' Check (Tax_Mode In ('exclusive', 'inclusive'));
+
+Alter Table Sales_Quotation Add Column If Not Exists Subtotal NUMERIC(15, 2) Default Null; -- Lines before discounts
+
+Alter Table Sales_Quotation Add Column If Not Exists Discount_Amount NUMERIC(15, 2) Not Null Default 0; -- Line and quotation discounts
+
+Alter Table Sales_Quotation Add Column If Not Exists Tax_Base NUMERIC(15, 2) Default Null; -- DPP
+
+Alter Table Sales_Quotation Add Column If Not Exists Tax_Amount NUMERIC(15, 2) Not Null Default 0; -- PPN
+
+Alter Table Sales_Quotation_Item Add Column If Not Exists Discount_Type VARCHAR(20) Default Null Check (Discount_Type In ('percent', 'amount'));
+
+Alter Table Sales_Quotation_Item Add Column If Not Exists Discount_Value NUMERIC(15, 2) Not Null Default 0 Check (Discount_Value >= 0);
+
+Alter Table Sales_Quotation_Item Add Column If Not Exists Discount_Amount NUMERIC(15, 2) Not Null Default 0;
+
+Alter Table Sales_Quotation_Item Add Column If Not Exists Line_Total NUMERIC(15, 2) Default Null; -- Share of the grand total
+
+-- Quotations from before discounts and tax were priced at quantity times unit price
+Update Sales_Quotation Set Subtotal = Total_Amount, Tax_Base = Total_Amount Where Subtotal Is Null;
+
+Update Sales_Quotation_Item Set Line_Total = Quantity * Unit_Price Where Line_Total Is Null;
+
+Alter Table Sales_Quotation Alter Column Subtotal Set Default 0, Alter Column Subtotal Set Not Null, Alter Column Tax_Base Set Default 0, Alter Column Tax_Base Set Not Null;
+
+Alter Table Sales_Quotation_Item Alter Column Line_Total Set Default 0, Alter Column Line_Total Set Not Null;
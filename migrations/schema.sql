@@ -1,6 +1,18 @@
 -- Enable UUID Extension
 Create Extension If Not Exists "uuid-ossp";
 
+-- Enable Trigram Extension for fuzzy search
+Create Extension If Not Exists Pg_Trgm;
+
+-- Typo matching uses the "<%" operator, which the trigram indexes serve, at the threshold of utils.SearchSimilarityThreshold
+Do $$
+Begin
+    -- Load pg_trgm so its settings are known before they are stored
+    Perform Word_Similarity('a', 'a');
+    Execute Format('Alter Database %I Set pg_trgm.word_similarity_threshold = 0.3', Current_Database());
+End
+$$;
+
 -- Table: Admin
 Create Table
     Appuser (
@@ -18,6 +30,34 @@ Create Table
         Updated_At Timestamptz Default Current_Timestamp
     );
 
+-- Table: Branch
+Create Table
+    Branch (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Code VARCHAR(10) Unique Not Null,
+        Name VARCHAR(100) Not Null,
+        Address TEXT Default Null,
+        Telephone VARCHAR(20) Default Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp,
+        Deleted_At Timestamptz Default Null
+    );
+
+-- Branches a user works in, the default one is active when the client does not pick one
+Create Table
+    User_Branch (
+        User_Id Uuid References Appuser (Id) On Delete Cascade,
+        Branch_Id Uuid References Branch (Id) On Delete Cascade,
+        Is_Default BOOLEAN Default False,
+        Created_At Timestamptz Default Current_Timestamp,
+        Primary Key (User_Id, Branch_Id)
+    );
+
+Insert Into
+    Branch (Code, Name)
+Values
+    ('PST', 'Pusat');
+
 -- Table: HR Management
 Create Table
     Employee (
@@ -104,6 +144,7 @@ Create Table
 Create Table
     Storage (
         Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Branch_Id Uuid References Branch (Id) On Delete Restrict,
         Name VARCHAR(255) Not Null,
         Location TEXT Not Null,
         Created_At Timestamptz Default Current_Timestamp,
@@ -111,12 +152,28 @@ Create Table
         Deleted_At Timestamptz Default Null
     );
 
+-- Price lists, customers on a list are offered its prices instead of typed ones
+Create Table
+    Price_List (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Name VARCHAR(255) Not Null,
+        Description TEXT,
+        Is_Active BOOLEAN Not Null Default True,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp,
+        Deleted_At Timestamptz Default Null
+    );
+
 Create Table
     Customer (
         Id Uuid Primary Key Default Uuid_Generate_V4 (),
         Name VARCHAR(255) Not Null,
         Address TEXT,
         Telephone VARCHAR(50),
+        Email VARCHAR(255),
+        Credit_Limit NUMERIC(15, 2) Default Null Check (Credit_Limit >= 0), -- Maximum outstanding paylater amount, no limit when null
+        Max_Overdue_Days INT Default Null Check (Max_Overdue_Days >= 0), -- Oldest overdue invoice allowed before new paylater orders are blocked
+        Price_List_Id Uuid Default Null References Price_List (Id) On Delete Set Null,
         Created_At Timestamptz Default Current_Timestamp,
         Updated_At Timestamptz Default Current_Timestamp,
         Deleted_At Timestamptz Default Null
@@ -129,6 +186,7 @@ Create Table
         Name VARCHAR(255) Not Null,
         Address TEXT,
         Telephone VARCHAR(50),
+        Email VARCHAR(255),
         Created_At Timestamptz Default Current_Timestamp,
         Updated_At Timestamptz Default Current_Timestamp,
         Deleted_At Timestamptz Default Null
@@ -137,7 +195,8 @@ Create Table
 Create Table
     Purchase_Order (
         Id Uuid Primary Key Default Uuid_Generate_V4 (),
-        Serial_Id VARCHAR(20) Unique,
+        Branch_Id Uuid References Branch (Id) On Delete Restrict,
+        Serial_Id VARCHAR(50) Unique,
         Supplier_Id Uuid Default Null References Supplier (Id) On Delete Set Null,
         Order_Date Timestamptz Default Current_Timestamp,
         Status VARCHAR(50) Not Null CHECK (
@@ -149,7 +208,15 @@ Create Table
                 'cancelled'
             )
         ), -- order, completed, partially_returned, returned, cancelled
-        Total_Amount NUMERIC(15, 2) Not Null,
+        Discount_Type VARCHAR(20) Default Null Check (Discount_Type In ('percent', 'amount')),
+        Discount_Value NUMERIC(15, 2) Not Null Default 0 Check (Discount_Value >= 0),
+        Tax_Rate NUMERIC(5, 2) Not Null Default 0 Check (Tax_Rate >= 0 And Tax_Rate <= 100),
+        Tax_Mode VARCHAR(20) Not Null Default 'exclusive' Check (Tax_Mode In ('exclusive', 'inclusive')),
+        Subtotal NUMERIC(15, 2) Not Null Default 0, -- Lines before discounts
+        Discount_Amount NUMERIC(15, 2) Not Null Default 0, -- Line and order discounts
+        Tax_Base NUMERIC(15, 2) Not Null Default 0, -- DPP
+        Tax_Amount NUMERIC(15, 2) Not Null Default 0, -- PPN
+        Total_Amount NUMERIC(15, 2) Not Null, -- Grand total
         Payment_Method VARCHAR(50) Not Null, -- cash, credit
         Payment_Due_Date Timestamptz Default Null,
         Created_By Uuid Not Null References Appuser (Id) On Delete Set Null,
@@ -167,6 +234,10 @@ Create Table
         Product_Id Uuid References Product (Id) On Delete Cascade,
         Requested_Quantity NUMERIC(15, 2) Not Null,
         Unit_Price NUMERIC(15, 2) Not Null,
+        Discount_Type VARCHAR(20) Default Null Check (Discount_Type In ('percent', 'amount')),
+        Discount_Value NUMERIC(15, 2) Not Null Default 0 Check (Discount_Value >= 0),
+        Discount_Amount NUMERIC(15, 2) Not Null Default 0,
+        Line_Total NUMERIC(15, 2) Not Null Default 0, -- Share of the grand total
         Created_At Timestamptz Default Current_Timestamp,
         Updated_At Timestamptz Default Current_Timestamp
     );
@@ -200,6 +271,7 @@ CREATE TABLE
 Create Table
     Purchase_Order_Return (
         Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Serial_Id VARCHAR(50) Unique,
         Purchase_Order_Id Uuid References Purchase_Order (Id) On Delete Cascade,
         Product_Detail_Id Uuid References Purchase_Order_Detail (Id) On Delete Cascade,
         Return_Quantity NUMERIC(15, 2) Not Null,
@@ -224,13 +296,16 @@ Create Table
 CREATE TABLE
     Sales_Order (
         Id UUID PRIMARY KEY DEFAULT UUID_GENERATE_V4 (),
-        Serial_Id VARCHAR(20) UNIQUE,
+        Branch_Id UUID REFERENCES Branch (Id) ON DELETE RESTRICT,
+        Serial_Id VARCHAR(50) UNIQUE,
         Customer_Id UUID REFERENCES Customer (Id) ON DELETE SET NULL,
         Order_Date TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
         Status VARCHAR(50) NOT NULL CHECK (
             Status IN (
                 'order',
+                'partially_invoiced',
                 'invoice',
+                'partially_delivered',
                 'completed',
                 'partially_returned',
                 'returned',
@@ -240,7 +315,17 @@ CREATE TABLE
         ), -- ordered, completed, partially_returned, returned, cancelled
         Payment_Method VARCHAR(50) NOT NULL, -- cash, paylater
         Payment_Due_Date TIMESTAMPTZ DEFAULT NULL,
-        Total_Amount NUMERIC(15, 2) NOT NULL,
+        Discount_Type VARCHAR(20) DEFAULT NULL CHECK (Discount_Type IN ('percent', 'amount')),
+        Discount_Value NUMERIC(15, 2) NOT NULL DEFAULT 0 CHECK (Discount_Value >= 0),
+        Tax_Rate NUMERIC(5, 2) NOT NULL DEFAULT 0 CHECK (Tax_Rate >= 0 AND Tax_Rate <= 100),
+        Tax_Mode VARCHAR(20) NOT NULL DEFAULT 'exclusive' CHECK (Tax_Mode IN ('exclusive', 'inclusive')),
+        Subtotal NUMERIC(15, 2) NOT NULL DEFAULT 0, -- Lines before discounts
+        Discount_Amount NUMERIC(15, 2) NOT NULL DEFAULT 0, -- Line and order discounts
+        Tax_Base NUMERIC(15, 2) NOT NULL DEFAULT 0, -- DPP
+        Tax_Amount NUMERIC(15, 2) NOT NULL DEFAULT 0, -- PPN
+        Total_Amount NUMERIC(15, 2) NOT NULL, -- Grand total
+        Amount_Tendered NUMERIC(15, 2) DEFAULT NULL, -- Cash handed over on a cash sale
+        Change_Amount NUMERIC(15, 2) DEFAULT NULL,
         Created_By UUID NOT NULL REFERENCES Appuser (Id) ON DELETE SET NULL,
         Created_At TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
         Updated_At TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
@@ -255,6 +340,14 @@ CREATE TABLE
         Batch_Storage_Id UUID REFERENCES Batch_Storage (Id) ON DELETE CASCADE,
         Quantity NUMERIC(15, 2) NOT NULL,
         Unit_Price NUMERIC(15, 2) NOT NULL,
+        Invoiced_Quantity NUMERIC(15, 2) NOT NULL DEFAULT 0, -- Sum of the active invoice lines
+        Delivered_Quantity NUMERIC(15, 2) NOT NULL DEFAULT 0, -- Sum of the active delivery note lines
+        List_Price NUMERIC(15, 2) DEFAULT NULL, -- Price list price offered when the line was priced
+        Price_Overridden BOOLEAN NOT NULL DEFAULT FALSE, -- Unit price typed by hand over the list price
+        Discount_Type VARCHAR(20) DEFAULT NULL CHECK (Discount_Type IN ('percent', 'amount')),
+        Discount_Value NUMERIC(15, 2) NOT NULL DEFAULT 0 CHECK (Discount_Value >= 0),
+        Discount_Amount NUMERIC(15, 2) NOT NULL DEFAULT 0,
+        Line_Total NUMERIC(15, 2) NOT NULL DEFAULT 0, -- Share of the grand total
         Created_At TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
         Updated_At TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
     );
@@ -275,9 +368,17 @@ CREATE TABLE
     Sales_Invoice (
         Id UUID PRIMARY KEY DEFAULT UUID_GENERATE_V4 (),
         Sales_Order_Id UUID REFERENCES Sales_Order (Id) ON DELETE CASCADE,
-        Serial_Id VARCHAR(20) UNIQUE,
+        Serial_Id VARCHAR(50) UNIQUE,
         Invoice_Date TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-        Total_Amount NUMERIC(15, 2) NOT NULL,
+        Tax_Rate NUMERIC(5, 2) NOT NULL DEFAULT 0,
+        Tax_Mode VARCHAR(20) NOT NULL DEFAULT 'exclusive',
+        Subtotal NUMERIC(15, 2) NOT NULL DEFAULT 0, -- Lines before discounts
+        Discount_Amount NUMERIC(15, 2) NOT NULL DEFAULT 0, -- Line and order discounts
+        Tax_Base NUMERIC(15, 2) NOT NULL DEFAULT 0, -- DPP
+        Tax_Amount NUMERIC(15, 2) NOT NULL DEFAULT 0, -- PPN
+        Total_Amount NUMERIC(15, 2) NOT NULL, -- Grand total
+        Amount_Tendered NUMERIC(15, 2) DEFAULT NULL, -- Cash handed over on a cash sale
+        Change_Amount NUMERIC(15, 2) DEFAULT NULL,
         Created_By UUID NOT NULL REFERENCES Appuser (Id) ON DELETE SET NULL,
         Created_At TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
         Updated_At TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
@@ -285,10 +386,24 @@ CREATE TABLE
         Cancelled_By UUID REFERENCES Appuser (Id) ON DELETE SET NULL
     );
 
+-- Invoice lines, an order can be invoiced over several invoices
+CREATE TABLE
+    Sales_Invoice_Detail (
+        Id UUID PRIMARY KEY DEFAULT UUID_GENERATE_V4 (),
+        Sales_Invoice_Id UUID NOT NULL REFERENCES Sales_Invoice (Id) ON DELETE CASCADE,
+        Sales_Order_Detail_Id UUID NOT NULL REFERENCES Sales_Order_Detail (Id) ON DELETE CASCADE,
+        Quantity NUMERIC(15, 2) NOT NULL CHECK (Quantity > 0),
+        Unit_Price NUMERIC(15, 2) NOT NULL,
+        Discount_Amount NUMERIC(15, 2) NOT NULL DEFAULT 0,
+        Line_Total NUMERIC(15, 2) NOT NULL DEFAULT 0, -- Share of the invoice grand total
+        Created_At TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (Sales_Invoice_Id, Sales_Order_Detail_Id)
+    );
+
 CREATE TABLE
     Delivery_Note (
         Id UUID PRIMARY KEY DEFAULT UUID_GENERATE_V4 (),
-        Serial_Id VARCHAR(20) UNIQUE,
+        Serial_Id VARCHAR(50) UNIQUE,
         Sales_Order_Id UUID REFERENCES Sales_Order (Id) ON DELETE CASCADE,
         Sales_Invoice_Id UUID REFERENCES Sales_Invoice (Id) ON DELETE SET NULL,
         Delivery_Date TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
@@ -301,9 +416,22 @@ CREATE TABLE
         Cancelled_By UUID REFERENCES Appuser (Id) ON DELETE SET NULL
     );
 
+-- Delivery note lines, an invoice can be delivered over several delivery notes
+CREATE TABLE
+    Delivery_Note_Detail (
+        Id UUID PRIMARY KEY DEFAULT UUID_GENERATE_V4 (),
+        Delivery_Note_Id UUID NOT NULL REFERENCES Delivery_Note (Id) ON DELETE CASCADE,
+        Sales_Order_Detail_Id UUID NOT NULL REFERENCES Sales_Order_Detail (Id) ON DELETE CASCADE,
+        Batch_Storage_Id UUID REFERENCES Batch_Storage (Id) ON DELETE SET NULL, -- Storage the goods were loaded from
+        Quantity NUMERIC(15, 2) NOT NULL CHECK (Quantity > 0),
+        Created_At TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (Delivery_Note_Id, Sales_Order_Detail_Id)
+    );
+
 CREATE TABLE
     Sales_Order_Return (
         Id UUID PRIMARY KEY DEFAULT UUID_GENERATE_V4 (),
+        Serial_Id VARCHAR(50) UNIQUE,
         Return_Source VARCHAR(20) NOT NULL DEFAULT 'invoice',
         Delivery_Note_Id UUID REFERENCES Delivery_Note (Id) ON DELETE CASCADE,
         Sales_Order_Id UUID REFERENCES Sales_Order (Id) ON DELETE CASCADE,
@@ -332,6 +460,7 @@ CREATE TABLE
 Create Table
     Inventory_Log (
         Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Serial_Id VARCHAR(50) Default Null, -- Set for stock transfers
         Batch_Id Uuid References Product_Batch (Id) On Delete Set Null,
         Storage_Id Uuid References Storage (Id) On Delete Set Null,
         User_Id Uuid References Appuser (Id),
@@ -351,6 +480,7 @@ Create Table
 Create Table
     Financial_Transaction_Log (
         Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Branch_Id Uuid References Branch (Id) On Delete Restrict,
         User_Id Uuid References Appuser (Id),
         Amount NUMERIC(15, 2) Not Null,
         Type VARCHAR(50) Not Null CHECK (Type IN ('debit', 'credit')),
@@ -366,13 +496,16 @@ Create Table
 
 CREATE TABLE
     Document_Counter (
-        Document_Type VARCHAR(10) CHECK (Document_Type IN ('SO', 'SI', 'DN', 'PO')), -- 'SO', 'SI', 'DN', 'PO'
+        Document_Type VARCHAR(10) CHECK (
+            Document_Type IN ('SO', 'SI', 'DN', 'PO', 'SR', 'PR', 'PAY', 'ADJ', 'TRF', 'SQ', 'CN')
+        ), -- 'SO', 'SI', 'DN', 'PO', 'SR', 'PR', 'PAY', 'ADJ', 'TRF', 'SQ', 'CN'
+        Branch_Id UUID REFERENCES Branch (Id) ON DELETE CASCADE, -- Null for documents outside any branch
         Year INT NOT NULL,
         Month INT NOT NULL,
         Day INT NOT NULL,
         Counter INT NOT NULL DEFAULT 1,
         Last_Updated TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-        UNIQUE (Document_Type, Year, Month, Day)
+        UNIQUE NULLS NOT DISTINCT (Document_Type, Branch_Id, Year, Month, Day)
     );
 
 -- Initialize counters
@@ -434,10 +567,464 @@ VALUES
         )
     );
 
+-- Table: Document Numbering, one editable scheme per document type
+Create Table
+    Document_Numbering (
+        Document_Type VARCHAR(10) Primary Key CHECK (
+            Document_Type IN ('SO', 'SI', 'DN', 'PO', 'SR', 'PR', 'PAY', 'ADJ', 'TRF', 'SQ', 'CN')
+        ),
+        Description VARCHAR(100) Not Null,
+        Prefix VARCHAR(10) Not Null,
+        Reset_Period VARCHAR(10) Not Null Default 'daily' CHECK (Reset_Period IN ('daily', 'monthly', 'yearly')), -- daily, monthly, yearly
+        Padding INT Not Null Default 4 CHECK (Padding Between 1 And 8),
+        Branch_Code VARCHAR(10) Default Null,
+        Updated_By Uuid References Appuser (Id) On Delete Set Null,
+        Updated_At Timestamptz Default Current_Timestamp
+    );
+
+Insert Into
+    Document_Numbering (Document_Type, Description, Prefix)
+Values
+    ('SO', 'Pesanan Penjualan', 'SO'),
+    ('SI', 'Faktur Penjualan', 'SI'),
+    ('DN', 'Surat Jalan', 'DN'),
+    ('PO', 'Pesanan Pembelian', 'PO'),
+    ('SR', 'Retur Penjualan', 'SR'),
+    ('PR', 'Retur Pembelian', 'PR'),
+    ('PAY', 'Pembayaran', 'PAY'),
+    ('ADJ', 'Penyesuaian Stok', 'ADJ'),
+    ('TRF', 'Transfer Stok', 'TRF'),
+    ('SQ', 'Penawaran Harga', 'SQ'),
+    ('CN', 'Nota Kredit', 'CN');
+
+-- Table: Webhooks
+Create Table
+    Webhook_Subscription (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Name VARCHAR(100) Not Null,
+        Url TEXT Not Null,
+        Secret VARCHAR(255) Not Null,
+        Events TEXT[] Not Null,
+        Is_Active BOOLEAN Default True,
+        Created_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp,
+        Deleted_At Timestamptz Default Null
+    );
+
+Create Table
+    Webhook_Delivery (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Subscription_Id Uuid Not Null References Webhook_Subscription (Id) On Delete Cascade,
+        Event_Id Uuid Not Null,
+        Event_Type VARCHAR(100) Not Null,
+        Payload JSONB Not Null,
+        Status VARCHAR(20) Not Null Default 'pending' CHECK (Status IN ('pending', 'success', 'failed')), -- pending, success, failed
+        Attempts INT Not Null Default 0,
+        Next_Attempt_At Timestamptz Default Current_Timestamp,
+        Response_Status INT Default Null,
+        Response_Body TEXT Default Null,
+        Last_Error TEXT Default Null,
+        Redelivery_Of Uuid References Webhook_Delivery (Id) On Delete Set Null,
+        Delivered_At Timestamptz Default Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp
+    );
+
+-- Table: Transactional Outbox
+Create Table
+    Outbox_Event (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Event_Type VARCHAR(100) Not Null,
+        Payload JSONB Not Null,
+        Attempts INT Not Null Default 0,
+        Next_Attempt_At Timestamptz Not Null Default Current_Timestamp,
+        Last_Error TEXT Default Null,
+        Processed_At Timestamptz Default Null,
+        Created_At Timestamptz Default Current_Timestamp
+    );
+
+-- Subscribers that already handled an event, so a retry only reaches the ones that failed
+Create Table
+    Outbox_Consumption (
+        Event_Id Uuid References Outbox_Event (Id) On Delete Cascade,
+        Subscriber VARCHAR(100) Not Null,
+        Processed_At Timestamptz Default Current_Timestamp,
+        Primary Key (Event_Id, Subscriber)
+    );
+
+Create Table If Not Exists
+    Import_Job (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Resource VARCHAR(20) Not Null Check (Resource In ('product', 'customer', 'supplier', 'employee', 'opening_stock')),
+        File_Name VARCHAR(255) Not Null,
+        Status VARCHAR(20) Not Null Check (Status In ('validated', 'invalid', 'committed', 'failed')),
+        Branch_Id Uuid References Branch (Id) On Delete Set Null,
+        Total_Rows INT Not Null Default 0,
+        Valid_Rows INT Not Null Default 0,
+        Error_Rows INT Not Null Default 0,
+        Errors Jsonb Not Null Default '[]',
+        Data Jsonb Not Null Default '{}',
+        Created_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Committed_At Timestamptz Default Null
+    );
+
+-- Company letterhead printed on documents, a single row
+Create Table If Not Exists
+    Company_Setting (
+        Id BOOLEAN Primary Key Default True Check (Id),
+        Name VARCHAR(255) Not Null,
+        Address TEXT Default Null,
+        Telephone VARCHAR(50) Default Null,
+        Email VARCHAR(255) Default Null,
+        Tax_Number VARCHAR(50) Default Null,
+        Footer VARCHAR(500) Default Null,
+        Receipt_Width INT Not Null Default 58 Check (Receipt_Width In (58, 80)),
+        Receipt_Footer VARCHAR(255) Default Null,
+        Tax_Rate NUMERIC(5, 2) Not Null Default 0 Check (Tax_Rate >= 0 And Tax_Rate <= 100), -- PPN new orders start with
+        Tax_Mode VARCHAR(20) Not Null Default 'exclusive' Check (Tax_Mode In ('exclusive', 'inclusive')),
+        Updated_At Timestamptz Default Current_Timestamp
+    );
+
+-- Prints of documents, every print after the first is a copy
+Create Table If Not Exists
+    Document_Print (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Document_Type VARCHAR(20) Not Null Check (Document_Type In ('sales_invoice', 'delivery_note', 'purchase_order', 'sales_return', 'credit_note')),
+        Document_Id Uuid Not Null,
+        Printed_By Uuid References Appuser (Id) On Delete Set Null,
+        Printed_At Timestamptz Default Current_Timestamp
+    );
+
+-- Letterhead a document was issued with, so reprints keep the company and branch data of the original
+Create Table If Not Exists
+    Document_Letterhead (
+        Document_Type VARCHAR(20) Not Null,
+        Document_Id Uuid Not Null,
+        Name VARCHAR(255) Not Null,
+        Address TEXT Default Null,
+        Telephone VARCHAR(50) Default Null,
+        Email VARCHAR(255) Default Null,
+        Tax_Number VARCHAR(50) Default Null,
+        Footer VARCHAR(500) Default Null,
+        Receipt_Footer VARCHAR(255) Default Null,
+        Branch_Name VARCHAR(255) Default Null,
+        Branch_Address TEXT Default Null,
+        Branch_Telephone VARCHAR(50) Default Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Primary Key (Document_Type, Document_Id)
+    );
+
+-- Emails of documents, queued and retried until sent, also the send log
+Create Table If Not Exists
+    Document_Email (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Document_Type VARCHAR(30) Not Null,
+        Document_Id Uuid Not Null,
+        Recipient VARCHAR(255) Not Null,
+        Cc VARCHAR(255) Default Null,
+        Subject VARCHAR(255) Not Null,
+        Body TEXT Not Null,
+        Attachment_Name VARCHAR(255) Not Null,
+        Attachment BYTEA Not Null,
+        Status VARCHAR(20) Not Null Default 'pending' Check (Status In ('pending', 'sent', 'failed')),
+        Attempts INT Not Null Default 0,
+        Next_Attempt_At Timestamptz Default Current_Timestamp,
+        Last_Error TEXT Default Null,
+        Sent_By Uuid References Appuser (Id) On Delete Set Null,
+        Sent_At Timestamptz Default Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp
+    );
+
+-- Files attached to documents and records, the content lives in the file storage under Storage_Key
+Create Table If Not Exists
+    Attachment (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Parent_Type VARCHAR(30) Not Null Check (Parent_Type In ('sales_order', 'sales_invoice', 'delivery_note', 'sales_return', 'purchase_order', 'finance_transaction', 'employee')),
+        Parent_Id Uuid Not Null,
+        File_Name VARCHAR(255) Not Null,
+        Content_Type VARCHAR(100) Not Null,
+        Size BIGINT Not Null,
+        Description VARCHAR(255) Default Null,
+        Storage_Key TEXT Not Null Unique,
+        Uploaded_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp
+    );
+
+-- Stock held by open sales orders, a reservation no longer counts once Expires_At has passed
+Create Table If Not Exists
+    Stock_Reservation (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Sales_Order_Detail_Id Uuid Not Null Unique References Sales_Order_Detail (Id) On Delete Cascade,
+        Batch_Storage_Id Uuid Not Null References Batch_Storage (Id) On Delete Cascade,
+        Quantity NUMERIC(15, 2) Not Null Check (Quantity > 0),
+        Expires_At Timestamptz Not Null,
+        Created_At Timestamptz Default Current_Timestamp
+    );
+
+-- On hand, reserved and available quantity of every batch storage
+Create Or Replace View Batch_Storage_Stock As
+Select
+    Bs.Id,
+    Bs.Batch_Id,
+    Bs.Storage_Id,
+    Bs.Quantity As On_Hand,
+    R.Reserved,
+    Bs.Quantity - R.Reserved As Available
+From Batch_Storage Bs
+Cross Join Lateral (
+    Select Coalesce(Sum(Sr.Quantity), 0) As Reserved
+    From Stock_Reservation Sr
+    Where Sr.Batch_Storage_Id = Bs.Id And Sr.Expires_At > Current_Timestamp
+) R;
+
+-- Table: Sales Quotation, a quotation past Valid_Until while still draft or sent is reported as expired
+Create Table
+    Sales_Quotation (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Branch_Id Uuid References Branch (Id) On Delete Restrict,
+        Serial_Id VARCHAR(50) Not Null Unique,
+        Customer_Id Uuid Not Null References Customer (Id) On Delete Restrict,
+        Quotation_Date Timestamptz Default Current_Timestamp,
+        Valid_Until Date Not Null,
+        Status VARCHAR(20) Not Null Default 'draft' Check (Status In ('draft', 'sent', 'accepted', 'rejected')),
+        Payment_Method VARCHAR(50) Not Null Check (Payment_Method In ('cash', 'paylater')),
+        Payment_Term_Days INT Default Null Check (Payment_Term_Days > 0), -- Days from the order to its payment due date
+        Notes TEXT Default Null,
+        Discount_Type VARCHAR(20) Default Null Check (Discount_Type In ('percent', 'amount')),
+        Discount_Value NUMERIC(15, 2) Not Null Default 0 Check (Discount_Value >= 0),
+        Tax_Rate NUMERIC(5, 2) Not Null Default 0 Check (Tax_Rate >= 0 And Tax_Rate <= 100),
+        Tax_Mode VARCHAR(20) Not Null Default 'exclusive' Check (Tax_Mode In ('exclusive', 'inclusive')),
+        Subtotal NUMERIC(15, 2) Not Null Default 0, -- Lines before discounts
+        Discount_Amount NUMERIC(15, 2) Not Null Default 0, -- Line and quotation discounts
+        Tax_Base NUMERIC(15, 2) Not Null Default 0, -- DPP
+        Tax_Amount NUMERIC(15, 2) Not Null Default 0, -- PPN
+        Total_Amount NUMERIC(15, 2) Not Null Default 0, -- Grand total
+        Sales_Order_Id Uuid Unique References Sales_Order (Id) On Delete Set Null, -- Set once converted
+        Created_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Updated_At Timestamptz Default Current_Timestamp
+    );
+
+Create Table
+    Sales_Quotation_Item (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Sales_Quotation_Id Uuid Not Null References Sales_Quotation (Id) On Delete Cascade,
+        Product_Id Uuid Not Null References Product (Id) On Delete Restrict,
+        Quantity NUMERIC(15, 2) Not Null Check (Quantity > 0),
+        Unit_Price NUMERIC(15, 2) Not Null Check (Unit_Price >= 0),
+        Discount_Type VARCHAR(20) Default Null Check (Discount_Type In ('percent', 'amount')),
+        Discount_Value NUMERIC(15, 2) Not Null Default 0 Check (Discount_Value >= 0),
+        Discount_Amount NUMERIC(15, 2) Not Null Default 0,
+        Line_Total NUMERIC(15, 2) Not Null Default 0, -- Share of the grand total
+        Created_At Timestamptz Default Current_Timestamp
+    );
+
+-- Table: Customer Payment, settles paylater invoices over one or more allocations
+Create Table
+    Customer_Payment (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Branch_Id Uuid References Branch (Id) On Delete Restrict,
+        Serial_Id VARCHAR(50) Not Null Unique,
+        Customer_Id Uuid Not Null References Customer (Id) On Delete Restrict,
+        Payment_Date Timestamptz Not Null Default Current_Timestamp,
+        Payment_Method VARCHAR(20) Not Null Check (Payment_Method In ('cash', 'transfer', 'giro')),
+        Amount NUMERIC(15, 2) Not Null Check (Amount > 0),
+        Reference VARCHAR(255) Default Null, -- Transfer or giro number
+        Notes TEXT Default Null,
+        Created_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Cancelled_At Timestamptz Default Null,
+        Cancelled_By Uuid References Appuser (Id) On Delete Set Null
+    );
+
+Create Table
+    Customer_Payment_Allocation (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Customer_Payment_Id Uuid Not Null References Customer_Payment (Id) On Delete Cascade,
+        Sales_Invoice_Id Uuid Not Null References Sales_Invoice (Id) On Delete Restrict,
+        Amount NUMERIC(15, 2) Not Null Check (Amount > 0),
+        Created_At Timestamptz Default Current_Timestamp,
+        Unique (Customer_Payment_Id, Sales_Invoice_Id)
+    );
+
+-- Payments are posted to the finance log, they settle income booked when the invoice was made
+Alter Table Financial_Transaction_Log Add Column Customer_Payment_Id Uuid References Customer_Payment (Id) On Delete Set Null;
+
+-- Sales returns credit the invoice that billed the returned line, through their delivery note when they have one
+-- and otherwise the latest invoice carrying the line, at what was charged for it after discounts and tax
+Create Or Replace View Sales_Invoice_Return As
+Select
+    Sor.Id,
+    Sor.Serial_Id,
+    Sor.Sales_Order_Id,
+    L.Sales_Invoice_Id,
+    Round(Sor.Return_Quantity * L.Line_Total / L.Quantity, 2) As Amount,
+    Sor.Returned_At,
+    Sor.Cancelled_At
+From Sales_Order_Return Sor
+Left Join Delivery_Note Dn On Dn.Id = Sor.Delivery_Note_Id
+Join Lateral (
+    Select Sid.Sales_Invoice_Id, Sid.Quantity, Sid.Line_Total
+    From Sales_Invoice_Detail Sid
+    Join Sales_Invoice Si On Si.Id = Sid.Sales_Invoice_Id
+    Where Sid.Sales_Order_Detail_Id = Sor.Sales_Detail_Id
+        And (Dn.Sales_Invoice_Id Is Null Or Sid.Sales_Invoice_Id = Dn.Sales_Invoice_Id)
+    Order By Si.Cancelled_At Is Null Desc, Si.Invoice_Date Desc, Si.Created_At Desc
+    Limit 1
+) L On True
+Where Sor.Return_Status <> 'pending';
+
+-- A return gets at most one credit note, applied to open paylater invoices of the customer or refunded.
+-- It is cancelled together with its return.
+Create Table
+    Credit_Note (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Branch_Id Uuid References Branch (Id) On Delete Restrict,
+        Serial_Id VARCHAR(50) Not Null Unique,
+        Sales_Order_Return_Id Uuid Not Null Unique References Sales_Order_Return (Id) On Delete Restrict,
+        Customer_Id Uuid Not Null References Customer (Id) On Delete Restrict,
+        Credit_Date Timestamptz Not Null Default Current_Timestamp,
+        Amount NUMERIC(15, 2) Not Null Check (Amount > 0),
+        Settlement VARCHAR(20) Not Null Check (Settlement In ('applied', 'refunded')),
+        Refund_Method VARCHAR(20) Default Null Check (Refund_Method In ('cash', 'transfer')), -- Set when refunded
+        Refund_Reference VARCHAR(255) Default Null, -- Transfer number
+        Notes TEXT Default Null,
+        Created_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Cancelled_At Timestamptz Default Null,
+        Cancelled_By Uuid References Appuser (Id) On Delete Set Null
+    );
+
+Create Table
+    Credit_Note_Allocation (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Credit_Note_Id Uuid Not Null References Credit_Note (Id) On Delete Cascade,
+        Sales_Invoice_Id Uuid Not Null References Sales_Invoice (Id) On Delete Restrict,
+        Amount NUMERIC(15, 2) Not Null Check (Amount > 0),
+        Created_At Timestamptz Default Current_Timestamp,
+        Unique (Credit_Note_Id, Sales_Invoice_Id)
+    );
+
+-- Refunds are posted to the finance log as money paid out
+Alter Table Financial_Transaction_Log Add Column Credit_Note_Id Uuid References Credit_Note (Id) On Delete Set Null;
+
+-- Credits on invoices, a return credits the invoice that billed its line until a credit note is issued for it,
+-- from then on the credit note allocations take its place
+Create Or Replace View Sales_Invoice_Credit As
+Select
+    Sir.Id,
+    Sir.Serial_Id,
+    'return' As Type,
+    Sir.Sales_Invoice_Id,
+    Sir.Amount,
+    Sir.Returned_At As Credit_Date,
+    Coalesce(Cn.Credit_Date, Sir.Cancelled_At) As Cancelled_At
+From Sales_Invoice_Return Sir
+Left Join Credit_Note Cn On Cn.Sales_Order_Return_Id = Sir.Id
+Union All
+Select
+    Cn.Id,
+    Cn.Serial_Id,
+    'credit_note',
+    Cna.Sales_Invoice_Id,
+    Cna.Amount,
+    Cn.Credit_Date,
+    Cn.Cancelled_At
+From Credit_Note_Allocation Cna
+Join Credit_Note Cn On Cn.Id = Cna.Credit_Note_Id;
+
+-- Paid, returned and outstanding amount of every invoice, cash sales are settled when invoiced
+Create View Sales_Invoice_Balance As
+Select
+    Si.Id,
+    Si.Sales_Order_Id,
+    So.Customer_Id,
+    So.Branch_Id,
+    So.Payment_Method,
+    So.Payment_Due_Date As Due_Date,
+    Si.Total_Amount,
+    P.Paid_Amount,
+    R.Returned_Amount,
+    Greatest(Si.Total_Amount - P.Paid_Amount - R.Returned_Amount, 0) As Outstanding_Amount,
+    Case
+        When Si.Cancelled_At Is Not Null Then 'cancelled'
+        When P.Paid_Amount + R.Returned_Amount >= Si.Total_Amount Then 'paid'
+        When So.Payment_Due_Date < Current_Timestamp Then 'overdue'
+        When P.Paid_Amount > 0 Then 'partially_paid'
+        Else 'unpaid'
+    End As Payment_Status
+From Sales_Invoice Si
+Join Sales_Order So On So.Id = Si.Sales_Order_Id
+Cross Join Lateral (
+    Select Coalesce(Sum(Sic.Amount), 0) As Returned_Amount
+    From Sales_Invoice_Credit Sic
+    Where Sic.Sales_Invoice_Id = Si.Id And Sic.Cancelled_At Is Null
+) R
+Cross Join Lateral (
+    Select Case
+        When So.Payment_Method = 'cash' Then Greatest(Si.Total_Amount - R.Returned_Amount, 0)
+        Else (
+            Select Coalesce(Sum(Cpa.Amount), 0)
+            From Customer_Payment_Allocation Cpa
+            Join Customer_Payment Cp On Cp.Id = Cpa.Customer_Payment_Id
+            Where Cpa.Sales_Invoice_Id = Si.Id And Cp.Cancelled_At Is Null
+        )
+    End As Paid_Amount
+) P;
+
+-- Paylater orders let through over the credit limit or overdue days of the customer, with who approved them
+Create Table
+    Sales_Order_Credit_Override (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Sales_Order_Id Uuid Not Null References Sales_Order (Id) On Delete Cascade,
+        Customer_Id Uuid Not Null References Customer (Id) On Delete Restrict,
+        Order_Amount NUMERIC(15, 2) Not Null, -- Amount the order or added item brought in
+        Outstanding_Amount NUMERIC(15, 2) Not Null, -- Receivable and uninvoiced paylater orders before it
+        Credit_Limit NUMERIC(15, 2) Default Null,
+        Overdue_Days INT Not Null Default 0,
+        Max_Overdue_Days INT Default Null,
+        Reason TEXT Not Null,
+        Approved_By Uuid References Appuser (Id) On Delete Set Null,
+        Created_At Timestamptz Default Current_Timestamp
+    );
+
+-- Product prices of a list, the row with the highest minimum quantity reached applies
+Create Table
+    Price_List_Item (
+        Id Uuid Primary Key Default Uuid_Generate_V4 (),
+        Price_List_Id Uuid Not Null References Price_List (Id) On Delete Cascade,
+        Product_Id Uuid Not Null References Product (Id) On Delete Restrict,
+        Min_Quantity NUMERIC(15, 2) Not Null Default 1 Check (Min_Quantity > 0),
+        Unit_Price NUMERIC(15, 2) Not Null Check (Unit_Price > 0),
+        Valid_From Date Default Null, -- Open ended when null
+        Valid_Until Date Default Null,
+        Created_At Timestamptz Default Current_Timestamp,
+        Check (Valid_Until Is Null Or Valid_From Is Null Or Valid_Until >= Valid_From)
+    );
+
+-- Price of a product on the active price list of a customer for a quantity on a date, null when the list has none
+Create Or Replace Function Customer_List_Price (P_Customer_Id Uuid, P_Product_Id Uuid, P_Quantity NUMERIC, P_Date Date Default Current_Date) Returns NUMERIC As $$
+    Select Pli.Unit_Price
+    From Customer C
+    Join Price_List Pl On Pl.Id = C.Price_List_Id And Pl.Is_Active And Pl.Deleted_At Is Null
+    Join Price_List_Item Pli On Pli.Price_List_Id = Pl.Id
+    Where C.Id = P_Customer_Id
+        And Pli.Product_Id = P_Product_Id
+        And Pli.Min_Quantity <= P_Quantity
+        And (Pli.Valid_From Is Null Or Pli.Valid_From <= P_Date)
+        And (Pli.Valid_Until Is Null Or Pli.Valid_Until >= P_Date)
+    Order By Pli.Min_Quantity Desc, Pli.Valid_From Desc Nulls Last
+    Limit 1
+$$ Language Sql Stable;
+
 -- Create materialized view for inventory logs with joined data
 CREATE MATERIALIZED VIEW inventory_log_view AS
 SELECT
     il.id,
+    s1.branch_id,
     il.batch_id,
     pb.sku AS batch_sku,
     pb.product_id,
@@ -485,6 +1072,7 @@ SET
 CREATE MATERIALIZED VIEW finance_transaction_log_view AS
 SELECT
     ft.Id,
+    ft.Branch_Id,
     ft.User_Id,
     u.Username,
     ft.Amount,
@@ -617,4 +1205,258 @@ CREATE INDEX Idx_Sales_Order_Return_Batch_Return_Id ON Sales_Order_Return_Batch
 
 CREATE INDEX Idx_Attendance_Employee_Id ON Attendance (Employee_Id);
 
-CREATE INDEX Idx_Attendance_Date ON Attendance (Attendance_Date);
\ No newline at end of file
+CREATE INDEX Idx_Attendance_Date ON Attendance (Attendance_Date);
+
+CREATE INDEX Idx_Product_Name_Trgm ON Product USING GIN (Name Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Product_Description_Trgm ON Product USING GIN (Description Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Category_Name_Trgm ON Category USING GIN (Name Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Unit_Name_Trgm ON Unit USING GIN (Name Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Product_Batch_Sku_Trgm ON Product_Batch USING GIN (Sku Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Customer_Name_Trgm ON Customer USING GIN (Name Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Customer_Address_Trgm ON Customer USING GIN (Address Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Supplier_Name_Trgm ON Supplier USING GIN (Name Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Supplier_Address_Trgm ON Supplier USING GIN (Address Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Customer_Telephone_Trgm ON Customer USING GIN (Telephone Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Supplier_Telephone_Trgm ON Supplier USING GIN (Telephone Gin_Trgm_Ops);
+
+CREATE INDEX Idx_Product_Name_Fts ON Product USING GIN (To_Tsvector('simple', Name));
+
+CREATE INDEX Idx_Product_Description_Fts ON Product USING GIN (To_Tsvector('simple', Description));
+
+CREATE INDEX Idx_Category_Name_Fts ON Category USING GIN (To_Tsvector('simple', Name));
+
+CREATE INDEX Idx_Unit_Name_Fts ON Unit USING GIN (To_Tsvector('simple', Name));
+
+CREATE INDEX Idx_Customer_Name_Fts ON Customer USING GIN (To_Tsvector('simple', Name));
+
+CREATE INDEX Idx_Customer_Address_Fts ON Customer USING GIN (To_Tsvector('simple', Address));
+
+CREATE INDEX Idx_Supplier_Name_Fts ON Supplier USING GIN (To_Tsvector('simple', Name));
+
+CREATE INDEX Idx_Supplier_Address_Fts ON Supplier USING GIN (To_Tsvector('simple', Address));
+
+CREATE INDEX Idx_Webhook_Delivery_Due ON Webhook_Delivery (Status, Next_Attempt_At);
+
+CREATE INDEX Idx_Webhook_Delivery_Subscription_Id ON Webhook_Delivery (Subscription_Id);
+
+CREATE INDEX Idx_Outbox_Event_Pending ON Outbox_Event (Next_Attempt_At) WHERE Processed_At Is Null;
+
+CREATE INDEX Idx_Outbox_Event_Processed ON Outbox_Event (Processed_At) WHERE Processed_At Is Not Null;
+
+CREATE UNIQUE INDEX Idx_Webhook_Delivery_Event ON Webhook_Delivery (Subscription_Id, Event_Id) WHERE Redelivery_Of Is Null;
+-- Record every Batch_Storage quantity change in the outbox, so stock updates are streamed whichever code path made them
+Create Or Replace Function Record_Batch_Storage_Change () Returns Trigger As $$
+Declare
+    New_Event_Id Uuid := Uuid_Generate_V4 ();
+    Previous_Quantity Numeric(15, 2) := 0;
+Begin
+    If Tg_Op = 'UPDATE' Then
+        If Old.Quantity = New.Quantity Then
+            Return New;
+        End If;
+        Previous_Quantity := Old.Quantity;
+    End If;
+
+    Insert Into Outbox_Event (Id, Event_Type, Payload)
+    Select
+        New_Event_Id,
+        'inventory.stock_changed',
+        Json_Build_Object(
+            'id', New_Event_Id,
+            'type', 'inventory.stock_changed',
+            'occurred_at', Current_Timestamp,
+            'data', Json_Build_Object(
+                'batch_storage_id', New.Id,
+                'batch_id', New.Batch_Id,
+                'storage_id', New.Storage_Id,
+                'product_id', Pb.Product_Id,
+                'sku', Pb.Sku,
+                'quantity', New.Quantity,
+                'previous_quantity', Previous_Quantity
+            )
+        )
+    From Product_Batch Pb
+    Where Pb.Id = New.Batch_Id;
+
+    Return New;
+End;
+$$ Language Plpgsql;
+
+Drop Trigger If Exists Trg_Batch_Storage_Change On Batch_Storage;
+
+Create Trigger Trg_Batch_Storage_Change
+After Insert Or Update Of Quantity On Batch_Storage
+For Each Row Execute Function Record_Batch_Storage_Change ();
+
+-- Announce every committed outbox event, so each app instance streams it to its own clients
+Create Or Replace Function Notify_Outbox_Event () Returns Trigger As $$
+Begin
+    Perform Pg_Notify('outbox_event', New.Id::Text);
+    Return New;
+End;
+$$ Language Plpgsql;
+
+Drop Trigger If Exists Trg_Outbox_Event_Notify On Outbox_Event;
+
+Create Trigger Trg_Outbox_Event_Notify
+After Insert On Outbox_Event
+For Each Row Execute Function Notify_Outbox_Event ();
+
+CREATE INDEX idx_inventory_log_view_branch_id ON inventory_log_view (branch_id);
+
+CREATE INDEX idx_finance_transaction_log_view_branch_id ON finance_transaction_log_view (branch_id);
+
+Create Index Idx_Storage_Branch_Id On Storage (Branch_Id);
+
+Create Index Idx_Sales_Order_Branch_Id On Sales_Order (Branch_Id);
+
+Create Index Idx_Purchase_Order_Branch_Id On Purchase_Order (Branch_Id);
+
+Create Index Idx_Financial_Transaction_Log_Branch_Id On Financial_Transaction_Log (Branch_Id);
+
+Create Index Idx_User_Branch_Branch_Id On User_Branch (Branch_Id);
+
+-- System finance logs take the branch of the order they belong to
+Create Or Replace Function Set_Financial_Transaction_Branch () Returns Trigger As $$
+Begin
+    If New.Branch_Id Is Null And New.Sales_Order_Id Is Not Null Then
+        Select Branch_Id Into New.Branch_Id From Sales_Order Where Id = New.Sales_Order_Id;
+    End If;
+
+    If New.Branch_Id Is Null And New.Purchase_Order_Id Is Not Null Then
+        Select Branch_Id Into New.Branch_Id From Purchase_Order Where Id = New.Purchase_Order_Id;
+    End If;
+
+    Return New;
+End;
+$$ Language Plpgsql;
+
+Drop Trigger If Exists Trg_Financial_Transaction_Branch On Financial_Transaction_Log;
+
+Create Trigger Trg_Financial_Transaction_Branch
+Before Insert On Financial_Transaction_Log
+For Each Row Execute Function Set_Financial_Transaction_Branch ();
+
+-- Take the letterhead of a document when it is issued, the document type is the trigger argument.
+-- Documents without one, issued before this migration or before the letterhead was set up, print with the current one.
+Create Or Replace Function Snapshot_Document_Letterhead () Returns Trigger As $$
+Declare
+    Document_Branch_Id Uuid;
+Begin
+    If Tg_Table_Name In ('sales_order', 'purchase_order', 'credit_note') Then
+        Document_Branch_Id := New.Branch_Id;
+    Else
+        Select Branch_Id Into Document_Branch_Id From Sales_Order Where Id = New.Sales_Order_Id;
+    End If;
+
+    Insert Into Document_Letterhead (Document_Type, Document_Id, Name, Address, Telephone, Email, Tax_Number, Footer,
+        Receipt_Footer, Branch_Name, Branch_Address, Branch_Telephone)
+    Select Tg_Argv[0], New.Id, Cs.Name, Cs.Address, Cs.Telephone, Cs.Email, Cs.Tax_Number, Cs.Footer,
+        Cs.Receipt_Footer, B.Name, B.Address, B.Telephone
+    From Company_Setting Cs
+    Left Join Branch B On B.Id = Document_Branch_Id
+    On Conflict Do Nothing;
+
+    Return New;
+End;
+$$ Language Plpgsql;
+
+Drop Trigger If Exists Trg_Sales_Order_Letterhead On Sales_Order;
+
+Create Trigger Trg_Sales_Order_Letterhead
+After Insert On Sales_Order
+For Each Row Execute Function Snapshot_Document_Letterhead ('sales_order');
+
+Drop Trigger If Exists Trg_Sales_Invoice_Letterhead On Sales_Invoice;
+
+Create Trigger Trg_Sales_Invoice_Letterhead
+After Insert On Sales_Invoice
+For Each Row Execute Function Snapshot_Document_Letterhead ('sales_invoice');
+
+Drop Trigger If Exists Trg_Delivery_Note_Letterhead On Delivery_Note;
+
+Create Trigger Trg_Delivery_Note_Letterhead
+After Insert On Delivery_Note
+For Each Row Execute Function Snapshot_Document_Letterhead ('delivery_note');
+
+Drop Trigger If Exists Trg_Sales_Return_Letterhead On Sales_Order_Return;
+
+Create Trigger Trg_Sales_Return_Letterhead
+After Insert On Sales_Order_Return
+For Each Row Execute Function Snapshot_Document_Letterhead ('sales_return');
+
+Drop Trigger If Exists Trg_Credit_Note_Letterhead On Credit_Note;
+
+Create Trigger Trg_Credit_Note_Letterhead
+After Insert On Credit_Note
+For Each Row Execute Function Snapshot_Document_Letterhead ('credit_note');
+
+Drop Trigger If Exists Trg_Purchase_Order_Letterhead On Purchase_Order;
+
+Create Trigger Trg_Purchase_Order_Letterhead
+After Insert On Purchase_Order
+For Each Row Execute Function Snapshot_Document_Letterhead ('purchase_order');
+
+Create Index If Not Exists Idx_Import_Job_Created_At On Import_Job (Created_At Desc);
+
+Create Index If Not Exists Idx_Document_Print_Document On Document_Print (Document_Type, Document_Id);
+
+Create Index If Not Exists Idx_Document_Email_Due On Document_Email (Status, Next_Attempt_At);
+
+Create Index If Not Exists Idx_Document_Email_Document On Document_Email (Document_Type, Document_Id);
+
+Create Index If Not Exists Idx_Attachment_Parent On Attachment (Parent_Type, Parent_Id);
+
+Create Index Idx_Stock_Reservation_Batch_Storage On Stock_Reservation (Batch_Storage_Id, Expires_At);
+
+Create Index Idx_Stock_Reservation_Expires_At On Stock_Reservation (Expires_At);
+
+Create Index Idx_Sales_Quotation_Branch_Id On Sales_Quotation (Branch_Id);
+
+Create Index Idx_Sales_Quotation_Customer_Id On Sales_Quotation (Customer_Id);
+
+Create Index Idx_Sales_Quotation_Item_Quotation_Id On Sales_Quotation_Item (Sales_Quotation_Id);
+
+Create Index Idx_Sales_Invoice_Detail_Invoice_Id On Sales_Invoice_Detail (Sales_Invoice_Id);
+
+Create Index Idx_Sales_Invoice_Detail_Order_Detail_Id On Sales_Invoice_Detail (Sales_Order_Detail_Id);
+
+Create Index Idx_Delivery_Note_Detail_Delivery_Note_Id On Delivery_Note_Detail (Delivery_Note_Id);
+
+Create Index Idx_Delivery_Note_Detail_Order_Detail_Id On Delivery_Note_Detail (Sales_Order_Detail_Id);
+
+Create Index Idx_Customer_Payment_Customer_Id On Customer_Payment (Customer_Id);
+
+Create Index Idx_Customer_Payment_Branch_Id On Customer_Payment (Branch_Id);
+
+Create Index Idx_Customer_Payment_Allocation_Payment_Id On Customer_Payment_Allocation (Customer_Payment_Id);
+
+Create Index Idx_Customer_Payment_Allocation_Invoice_Id On Customer_Payment_Allocation (Sales_Invoice_Id);
+
+Create Index Idx_Financial_Transaction_Log_Customer_Payment_Id On Financial_Transaction_Log (Customer_Payment_Id);
+
+Create Index Idx_Sales_Order_Credit_Override_Order_Id On Sales_Order_Credit_Override (Sales_Order_Id);
+
+Create Unique Index Idx_Price_List_Name On Price_List (Lower(Name)) Where Deleted_At Is Null;
+
+Create Index Idx_Price_List_Item_List_Product On Price_List_Item (Price_List_Id, Product_Id);
+
+Create Index Idx_Credit_Note_Customer_Id On Credit_Note (Customer_Id);
+
+Create Index Idx_Credit_Note_Branch_Id On Credit_Note (Branch_Id);
+
+Create Index Idx_Credit_Note_Allocation_Credit_Note_Id On Credit_Note_Allocation (Credit_Note_Id);
+
+Create Index Idx_Credit_Note_Allocation_Invoice_Id On Credit_Note_Allocation (Sales_Invoice_Id);
+
+Create Index Idx_Financial_Transaction_Log_Credit_Note_Id On Financial_Transaction_Log (Credit_Note_Id);
\ No newline at end of file
@@ -0,0 +1,63 @@
+package event
+
+import "time"
+
+// Event types recorded inside the transaction that caused them
+const (
+	SalesOrderCreated            = "sales_order.created"
+	SalesOrderCancelled          = "sales_order.cancelled"
+	SalesOrderReservationExpired = "sales_order.reservation_expired" // the order no longer holds its stock
+	SalesOrderCreditOverridden   = "sales_order.credit_overridden"   // a paylater order went over the customer's credit limits with approval
+	SalesInvoiceCreated          = "sales_invoice.created"
+	SalesInvoiceCancelled        = "sales_invoice.cancelled"
+	SalesReturnCreated           = "sales_return.created"
+	SalesReturnCancelled         = "sales_return.cancelled"
+	DeliveryNoteCreated          = "delivery_note.created"
+	DeliveryNoteCancelled        = "delivery_note.cancelled"
+	CustomerPaymentCreated       = "customer_payment.created"
+	CustomerPaymentCancelled     = "customer_payment.cancelled"
+	CreditNoteCreated            = "credit_note.created"
+	CreditNoteCancelled          = "credit_note.cancelled" // cancelled together with its sales return
+	PurchaseOrderCreated         = "purchase_order.created"
+	PurchaseOrderCancelled       = "purchase_order.cancelled"
+	PurchaseOrderCompleted       = "purchase_order.completed"
+	BatchMoved                   = "inventory.batch_moved"
+	StockChanged                 = "inventory.stock_changed" // recorded by a trigger on Batch_Storage
+	ViewRefreshed                = "report.view_refreshed"
+)
+
+// Types lists every event type that can be subscribed to
+var Types = []string{
+	SalesOrderCreated,
+	SalesOrderCancelled,
+	SalesOrderReservationExpired,
+	SalesOrderCreditOverridden,
+	SalesInvoiceCreated,
+	SalesInvoiceCancelled,
+	SalesReturnCreated,
+	SalesReturnCancelled,
+	DeliveryNoteCreated,
+	DeliveryNoteCancelled,
+	CustomerPaymentCreated,
+	CustomerPaymentCancelled,
+	CreditNoteCreated,
+	CreditNoteCancelled,
+	PurchaseOrderCreated,
+	PurchaseOrderCancelled,
+	PurchaseOrderCompleted,
+	BatchMoved,
+	StockChanged,
+	ViewRefreshed,
+}
+
+// Event is a domain event as delivered to subscribers
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// Handler reacts to a delivered event, returning an error makes the event be delivered again later.
+// Events are delivered at least once, so handlers must be idempotent on Event.ID.
+type Handler func(evt Event) error
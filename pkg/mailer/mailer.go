@@ -0,0 +1,181 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is an email with optional attachments
+type Message struct {
+	To          []string
+	Cc          []string
+	Subject     string
+	Body        string // Plain text
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// Mailer sends emails, implementations must be safe for concurrent use
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer sends emails through an SMTP server. Without a username it sends unauthenticated,
+// which is what local catchers such as MailHog expect.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string // Address or "Name <address>"
+}
+
+// Send delivers a message, STARTTLS is used when the server offers it
+func (m *SMTPMailer) Send(msg Message) error {
+	from, err := parseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", m.From, err)
+	}
+
+	recipients := make([]string, 0, len(msg.To)+len(msg.Cc))
+	for _, address := range append(append([]string{}, msg.To...), msg.Cc...) {
+		parsed, errParse := parseAddress(address)
+		if errParse != nil {
+			return fmt.Errorf("invalid recipient %q: %w", address, errParse)
+		}
+		recipients = append(recipients, parsed)
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("message has no recipients")
+	}
+
+	content, err := m.build(msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, from, recipients, content)
+}
+
+// parseAddress returns the bare address of "address" or "Name <address>"
+func parseAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if start := strings.LastIndex(address, "<"); start >= 0 && strings.HasSuffix(address, ">") {
+		address = address[start+1 : len(address)-1]
+	}
+	if strings.ContainsAny(address, " \r\n<>") || !strings.Contains(address, "@") {
+		return "", fmt.Errorf("not an email address")
+	}
+	return address, nil
+}
+
+// build renders the message as MIME, a multipart/mixed body is used when there are attachments
+func (m *SMTPMailer) build(msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		// Header values must not break out into new headers
+		value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+
+	header("From", m.From)
+	header("To", strings.Join(msg.To, ", "))
+	if len(msg.Cc) > 0 {
+		header("Cc", strings.Join(msg.Cc, ", "))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	header("Message-ID", "<"+id+"@"+m.Host+">")
+	header("MIME-Version", "1.0")
+
+	if len(msg.Attachments) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err = writeQuotedPrintable(&buf, msg.Body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	boundary, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	header("Content-Type", "multipart/mixed; boundary=\""+boundary+"\"")
+	buf.WriteString("\r\n")
+
+	buf.WriteString("--" + boundary + "\r\n")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+	if err = writeQuotedPrintable(&buf, msg.Body); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\r\n")
+
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		buf.WriteString("--" + boundary + "\r\n")
+		header("Content-Type", mime.FormatMediaType(contentType, map[string]string{"name": attachment.Name}))
+		header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+		header("Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n")
+
+		// Base64 lines are limited to 76 characters
+		encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		buf.WriteString(encoded + "\r\n")
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable writes a text body with CRLF line endings
+func writeQuotedPrintable(buf *bytes.Buffer, body string) error {
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	writer := quotedprintable.NewWriter(buf)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// randomToken returns a random hex string for message IDs and MIME boundaries
+func randomToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
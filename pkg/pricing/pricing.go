@@ -0,0 +1,140 @@
+package pricing
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+)
+
+// Discount types of a line or an order
+const (
+	DiscountPercent = "percent"
+	DiscountAmount  = "amount"
+)
+
+// Tax modes, exclusive adds the tax on top of the prices while inclusive prices already carry it
+const (
+	TaxExclusive = "exclusive"
+	TaxInclusive = "inclusive"
+)
+
+// Discount is a percentage or a fixed amount taken off a line or an order, no discount when the type is empty
+type Discount struct {
+	Type  string
+	Value float64
+}
+
+// Amount is the discount taken off a base amount, never more than the base itself
+func (d Discount) Amount(base float64) float64 {
+	var amount float64
+	switch d.Type {
+	case DiscountPercent:
+		amount = Round(base * d.Value / 100)
+	case DiscountAmount:
+		amount = Round(d.Value)
+	}
+	return math.Max(0, math.Min(amount, base))
+}
+
+// Tax is the PPN rate in percent and whether the prices already include it
+type Tax struct {
+	Rate float64
+	Mode string
+}
+
+// Line is a priced quantity with its own discount
+type Line struct {
+	Quantity  float64
+	UnitPrice float64
+	Discount  Discount
+}
+
+// LineTotals is the outcome of a line, Total is its share of the grand total after the order discount and the tax
+type LineTotals struct {
+	Subtotal       float64
+	DiscountAmount float64
+	Total          float64
+}
+
+// Totals is the breakdown of a document, DiscountAmount adds up the line and order discounts
+type Totals struct {
+	Subtotal       float64
+	DiscountAmount float64
+	TaxBase        float64 // DPP
+	TaxAmount      float64 // PPN
+	GrandTotal     float64
+	Lines          []LineTotals
+}
+
+// Round rounds an amount to cents
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// Calculate prices the lines, takes the order discount off what is left after the line discounts
+// and applies the tax. The grand total is spread over the lines by their net amount so returns
+// and partial invoices can value a line at what was really charged for it.
+func Calculate(lines []Line, discount Discount, tax Tax) Totals {
+	totals := Totals{Lines: make([]LineTotals, len(lines))}
+
+	var net float64
+	for i, line := range lines {
+		subtotal := Round(line.Quantity * line.UnitPrice)
+		lineDiscount := line.Discount.Amount(subtotal)
+		totals.Lines[i] = LineTotals{Subtotal: subtotal, DiscountAmount: lineDiscount}
+		totals.Subtotal += subtotal
+		totals.DiscountAmount += lineDiscount
+		net += subtotal - lineDiscount
+	}
+	net = Round(net)
+
+	orderDiscount := discount.Amount(net)
+	totals.Subtotal = Round(totals.Subtotal)
+	totals.DiscountAmount = Round(totals.DiscountAmount + orderDiscount)
+	afterDiscount := Round(net - orderDiscount)
+
+	if tax.Mode == TaxInclusive {
+		totals.GrandTotal = afterDiscount
+		totals.TaxBase = Round(afterDiscount * 100 / (100 + tax.Rate))
+		totals.TaxAmount = Round(afterDiscount - totals.TaxBase)
+	} else {
+		totals.TaxBase = afterDiscount
+		totals.TaxAmount = Round(afterDiscount * tax.Rate / 100)
+		totals.GrandTotal = Round(afterDiscount + totals.TaxAmount)
+	}
+
+	// The rounding remainder goes to the last line carrying an amount
+	if net > 0 {
+		remaining := totals.GrandTotal
+		last := -1
+		for i, line := range totals.Lines {
+			if line.Subtotal-line.DiscountAmount > 0 {
+				last = i
+			}
+		}
+		for i := range totals.Lines {
+			lineNet := totals.Lines[i].Subtotal - totals.Lines[i].DiscountAmount
+			if i == last {
+				totals.Lines[i].Total = Round(remaining)
+				break
+			}
+			share := Round(totals.GrandTotal * lineNet / net)
+			totals.Lines[i].Total = share
+			remaining -= share
+		}
+	}
+	return totals
+}
+
+// DefaultTax reads the tax rate and mode new documents start with from the company setting,
+// no tax when the company has not been set up
+func DefaultTax(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}) (Tax, error) {
+	tax := Tax{Mode: TaxExclusive}
+	err := q.QueryRow("Select Tax_Rate, Tax_Mode From Company_Setting Where Id").Scan(&tax.Rate, &tax.Mode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tax, nil
+	}
+	return tax, err
+}
@@ -0,0 +1,138 @@
+package pricing
+
+import "testing"
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []Line
+		discount   Discount
+		tax        Tax
+		subtotal   float64
+		discounts  float64
+		taxBase    float64
+		taxAmount  float64
+		grandTotal float64
+		lineTotals []float64
+	}{
+		{
+			name:     "no discount and no tax",
+			lines:    []Line{{Quantity: 2, UnitPrice: 10000}, {Quantity: 1, UnitPrice: 5000}},
+			tax:      Tax{Mode: TaxExclusive},
+			subtotal: 25000, taxBase: 25000, grandTotal: 25000,
+			lineTotals: []float64{20000, 5000},
+		},
+		{
+			name:     "zero percent discounts",
+			lines:    []Line{{Quantity: 1, UnitPrice: 10000, Discount: Discount{Type: DiscountPercent}}},
+			discount: Discount{Type: DiscountPercent},
+			tax:      Tax{Mode: TaxExclusive},
+			subtotal: 10000, taxBase: 10000, grandTotal: 10000,
+			lineTotals: []float64{10000},
+		},
+		{
+			name: "full line discount leaves the line out of the spread",
+			lines: []Line{
+				{Quantity: 1, UnitPrice: 10000, Discount: Discount{Type: DiscountPercent, Value: 100}},
+				{Quantity: 1, UnitPrice: 5000},
+			},
+			tax:      Tax{Mode: TaxExclusive},
+			subtotal: 15000, discounts: 10000, taxBase: 5000, grandTotal: 5000,
+			lineTotals: []float64{0, 5000},
+		},
+		{
+			name:     "full order discount",
+			lines:    []Line{{Quantity: 1, UnitPrice: 6000}, {Quantity: 2, UnitPrice: 2000}},
+			discount: Discount{Type: DiscountPercent, Value: 100},
+			tax:      Tax{Rate: 11, Mode: TaxExclusive},
+			subtotal: 10000, discounts: 10000,
+			lineTotals: []float64{0, 0},
+		},
+		{
+			name:     "amount discount is capped at the line",
+			lines:    []Line{{Quantity: 1, UnitPrice: 5000, Discount: Discount{Type: DiscountAmount, Value: 7000}}},
+			tax:      Tax{Mode: TaxExclusive},
+			subtotal: 5000, discounts: 5000,
+			lineTotals: []float64{0},
+		},
+		{
+			name:     "half cent discount rounds up",
+			lines:    []Line{{Quantity: 1, UnitPrice: 999, Discount: Discount{Type: DiscountPercent, Value: 12.5}}},
+			tax:      Tax{Mode: TaxExclusive},
+			subtotal: 999, discounts: 124.88, taxBase: 874.12, grandTotal: 874.12,
+			lineTotals: []float64{874.12},
+		},
+		{
+			name:     "exclusive tax is added on top",
+			lines:    []Line{{Quantity: 1, UnitPrice: 100000}},
+			tax:      Tax{Rate: 11, Mode: TaxExclusive},
+			subtotal: 100000, taxBase: 100000, taxAmount: 11000, grandTotal: 111000,
+			lineTotals: []float64{111000},
+		},
+		{
+			name:     "inclusive tax is taken out of the prices",
+			lines:    []Line{{Quantity: 1, UnitPrice: 10000}},
+			tax:      Tax{Rate: 11, Mode: TaxInclusive},
+			subtotal: 10000, taxBase: 9009.01, taxAmount: 990.99, grandTotal: 10000,
+			lineTotals: []float64{10000},
+		},
+		{
+			name:     "rounding remainder goes to the last line",
+			lines:    []Line{{Quantity: 1, UnitPrice: 1}, {Quantity: 1, UnitPrice: 1}, {Quantity: 1, UnitPrice: 1}},
+			discount: Discount{Type: DiscountAmount, Value: 1},
+			tax:      Tax{Mode: TaxExclusive},
+			subtotal: 3, discounts: 1, taxBase: 2, grandTotal: 2,
+			lineTotals: []float64{0.67, 0.67, 0.66},
+		},
+		{
+			name:     "remainder skips trailing lines without an amount",
+			lines:    []Line{{Quantity: 1, UnitPrice: 1}, {Quantity: 2, UnitPrice: 1}, {Quantity: 0, UnitPrice: 1}},
+			tax:      Tax{Rate: 11, Mode: TaxExclusive},
+			subtotal: 3, taxBase: 3, taxAmount: 0.33, grandTotal: 3.33,
+			lineTotals: []float64{1.11, 2.22, 0},
+		},
+		{
+			name:       "no lines",
+			tax:        Tax{Rate: 11, Mode: TaxExclusive},
+			lineTotals: []float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		got := Calculate(tt.lines, tt.discount, tt.tax)
+		if got.Subtotal != tt.subtotal || got.DiscountAmount != tt.discounts || got.TaxBase != tt.taxBase ||
+			got.TaxAmount != tt.taxAmount || got.GrandTotal != tt.grandTotal {
+			t.Errorf("%s: totals = %v/%v/%v/%v/%v, want %v/%v/%v/%v/%v", tt.name,
+				got.Subtotal, got.DiscountAmount, got.TaxBase, got.TaxAmount, got.GrandTotal,
+				tt.subtotal, tt.discounts, tt.taxBase, tt.taxAmount, tt.grandTotal)
+		}
+		if len(got.Lines) != len(tt.lineTotals) {
+			t.Errorf("%s: got %d lines, want %d", tt.name, len(got.Lines), len(tt.lineTotals))
+			continue
+		}
+		for i, want := range tt.lineTotals {
+			if got.Lines[i].Total != want {
+				t.Errorf("%s: line %d total = %v, want %v", tt.name, i, got.Lines[i].Total, want)
+			}
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   float64
+	}{
+		{0, 0},
+		{1.004, 1},
+		{124.875, 124.88},
+		{-124.875, -124.88},
+		{9009.009009, 9009.01},
+	}
+
+	for _, tt := range tests {
+		if got := Round(tt.amount); got != tt.want {
+			t.Errorf("Round(%v) = %v, want %v", tt.amount, got, tt.want)
+		}
+	}
+}
@@ -34,6 +34,16 @@ func (qb *QueryBuilder) AddFilter(condition string, value interface{}) *QueryBui
 	return qb
 }
 
+// AddFilterExpr adds a filter condition where every "$?" in the expression refers to the same parameter
+func (qb *QueryBuilder) AddFilterExpr(expr string, value interface{}) *QueryBuilder {
+	if value != nil && value != "" {
+		qb.Query.WriteString(" AND " + strings.ReplaceAll(expr, "$?", fmt.Sprintf("$%d", qb.Count)))
+		qb.Params = append(qb.Params, value)
+		qb.Count++
+	}
+	return qb
+}
+
 // AddOrFilter adds an OR filter condition with parameter
 func (qb *QueryBuilder) AddOrFilter(condition string, value interface{}) *QueryBuilder {
 	if value != nil && value != "" {
@@ -44,8 +54,18 @@ func (qb *QueryBuilder) AddOrFilter(condition string, value interface{}) *QueryB
 	return qb
 }
 
-// AddPagination adds pagination parameters
-func (qb *QueryBuilder) AddPagination(pageSize, page int) *QueryBuilder {
+// AddPagination adds pagination parameters, exports stream every row while lists default to the first page
+func (qb *QueryBuilder) AddPagination(pagination PaginationParameter) *QueryBuilder {
+	if pagination.Export != nil {
+		return qb
+	}
+	pageSize, page := pagination.PageSize, pagination.Page
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if page < 1 {
+		page = DefaultPage
+	}
 	qb.Query.WriteString(fmt.Sprintf(" LIMIT $%d OFFSET $%d", qb.Count, qb.Count+1))
 	qb.Params = append(qb.Params, pageSize, (page-1)*pageSize)
 	qb.Count += 2
@@ -86,44 +106,106 @@ func WithTransaction(db *sql.DB, fn func(*sql.Tx) error) error {
 	return nil
 }
 
-// GenerateNextSerialID generates the next serial ID for a document type
-// Note: This should be called within a transaction to ensure atomicity
+// DocumentNumbering is the numbering scheme configured for a document type
+type DocumentNumbering struct {
+	Prefix      string
+	ResetPeriod string
+	Padding     int
+	BranchCode  string
+}
+
+// SerialPeriod returns the counter key for the reset period, parts below the period are zero
+func SerialPeriod(resetPeriod string, t time.Time) (year, month, day int) {
+	switch resetPeriod {
+	case "yearly":
+		return t.Year(), 0, 0
+	case "monthly":
+		return t.Year(), int(t.Month()), 0
+	default:
+		return t.Year(), int(t.Month()), t.Day()
+	}
+}
+
+// FormatSerialID formats a serial ID as PREFIX[-BRANCH]-DATE-NNNN, where DATE follows the reset period
+func FormatSerialID(numbering DocumentNumbering, t time.Time, counter int) string {
+	parts := []string{numbering.Prefix}
+	if numbering.BranchCode != "" {
+		parts = append(parts, numbering.BranchCode)
+	}
+
+	switch numbering.ResetPeriod {
+	case "yearly":
+		parts = append(parts, t.Format("2006"))
+	case "monthly":
+		parts = append(parts, t.Format("200601"))
+	default:
+		parts = append(parts, t.Format("20060102"))
+	}
+
+	parts = append(parts, fmt.Sprintf("%0*d", numbering.Padding, counter))
+	return strings.Join(parts, "-")
+}
+
+// GenerateNextSerialID generates the next serial ID for a document type outside any branch
+// Note: This should be called within the transaction that inserts the document.
 func GenerateNextSerialID(tx *sql.Tx, documentType string) (string, error) {
+	return GenerateNextBranchSerialID(tx, documentType, "")
+}
+
+// GenerateNextBranchSerialID generates the next serial ID for a document type in a branch.
+// Each branch has its own counters and the branch code replaces the scheme's branch code.
+// Note: This should be called within the transaction that inserts the document.
+// The counter row stays locked until commit and a rollback releases the number, so the sequence is gap-free.
+func GenerateNextBranchSerialID(tx *sql.Tx, documentType string, branchID string) (string, error) {
 	now := time.Now()
-	year, month, day := now.Year(), int(now.Month()), now.Day()
 
-	// Try to update existing counter for today
-	var counter int
+	// Get the numbering scheme, types without one keep the XX-YYYYMMDD-NNNN format
+	numbering := DocumentNumbering{Prefix: documentType, ResetPeriod: "daily", Padding: 4}
 	err := tx.QueryRow(`
+        Select Prefix, Reset_Period, Padding, Coalesce(Branch_Code, '')
+        From Document_Numbering
+        Where Document_Type = $1
+    `, documentType).Scan(&numbering.Prefix, &numbering.ResetPeriod, &numbering.Padding, &numbering.BranchCode)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("gagal mengambil format penomoran dokumen: %w", err)
+	}
+
+	var branch sql.NullString
+	if branchID != "" {
+		branch = sql.NullString{String: branchID, Valid: true}
+		err = tx.QueryRow(`Select Code From Branch Where Id = $1`, branchID).Scan(&numbering.BranchCode)
+		if err != nil {
+			return "", fmt.Errorf("gagal mengambil kode cabang: %w", err)
+		}
+	}
+
+	year, month, day := SerialPeriod(numbering.ResetPeriod, now)
+
+	// Try to update existing counter for the current period
+	var counter int
+	err = tx.QueryRow(`
         UPDATE document_counter 
         SET counter = counter + 1, last_updated = NOW()
-        WHERE document_type = $1 AND year = $2 AND month = $3 AND day = $4
+        WHERE document_type = $1 AND branch_id IS NOT DISTINCT FROM $2 AND year = $3 AND month = $4 AND day = $5
         RETURNING counter
-    `, documentType, year, month, day).Scan(&counter)
+    `, documentType, branch, year, month, day).Scan(&counter)
 
-	// If no rows exist for today, insert a new counter
+	// If no rows exist for the current period, insert a new counter
 	if errors.Is(err, sql.ErrNoRows) {
 		err = tx.QueryRow(`
-            INSERT INTO document_counter (document_type, year, month, day, counter)
-            VALUES ($1, $2, $3, $4, 1)
-            ON CONFLICT (document_type, year, month, day) 
+            INSERT INTO document_counter (document_type, branch_id, year, month, day, counter)
+            VALUES ($1, $2, $3, $4, $5, 1)
+            ON CONFLICT (document_type, branch_id, year, month, day) 
             DO UPDATE SET counter = document_counter.counter + 1
             RETURNING counter
-        `, documentType, year, month, day).Scan(&counter)
+        `, documentType, branch, year, month, day).Scan(&counter)
 	}
 
 	if err != nil {
 		return "", fmt.Errorf("gagal mengupdate penghitung dokumen: %w", err)
 	}
 
-	// Format the serial number: XX-YYYYMMDD-NNNN
-	// XX = document type, YYYYMMDD = date, NNNN = counter (padded with zeros)
-	return fmt.Sprintf(
-		"%s-%04d%02d%02d-%04d",
-		documentType,
-		year, month, day,
-		counter,
-	), nil
+	return FormatSerialID(numbering, now, counter), nil
 }
 
 // Helper function to get first n characters or all if shorter
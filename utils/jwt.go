@@ -8,11 +8,13 @@ import (
 
 var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
 
-func GenerateAccessToken(userID string, roles []*string) (string, error) {
+func GenerateAccessToken(userID string, roles []*string, branches []string, defaultBranch string) (string, error) {
 	claims := jwt.MapClaims{
-		"user_id": userID,
-		"roles":   roles,
-		"exp":     time.Now().Add(time.Minute * 1).Unix(),
+		"user_id":        userID,
+		"roles":          roles,
+		"branches":       branches,
+		"default_branch": defaultBranch,
+		"exp":            time.Now().Add(time.Minute * 1).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
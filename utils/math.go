@@ -1,9 +1,55 @@
 package utils
 
-import "math"
+import (
+	"math"
+	"strconv"
+	"strings"
+)
 
 // FloatEquals checks if two float64 values are equal within a small epsilon
 func FloatEquals(a, b float64) bool {
 	epsilon := 0.000001 // Small threshold for floating point comparison
 	return math.Abs(a-b) < epsilon
 }
+
+// FormatNumber formats a number with Indonesian separators, e.g. 1.234.567,5
+func FormatNumber(value float64) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	formatted := strconv.FormatFloat(value, 'f', -1, 64)
+	if rounded := math.Round(value*100) / 100; !FloatEquals(rounded, value) {
+		formatted = strconv.FormatFloat(rounded, 'f', 2, 64)
+	}
+	integer, fraction, _ := strings.Cut(formatted, ".")
+
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(digit)
+	}
+	if fraction != "" {
+		grouped.WriteString("," + fraction)
+	}
+	return sign + grouped.String()
+}
+
+// FormatRupiah formats an amount as rupiah, e.g. Rp 1.234.567 or -Rp 1.234,50
+func FormatRupiah(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	formatted := FormatNumber(math.Round(amount*100) / 100)
+	if comma := strings.IndexByte(formatted, ','); comma >= 0 && len(formatted)-comma == 2 {
+		formatted += "0"
+	}
+	return sign + "Rp " + formatted
+}
@@ -0,0 +1,43 @@
+package utils
+
+import "testing"
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1.000"},
+		{1234567.5, "1.234.567,5"},
+		{1234.567, "1.234,57"},
+		{0.125, "0,13"},
+		{-1234.5, "-1.234,5"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatNumber(tt.value); got != tt.want {
+			t.Errorf("FormatNumber(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatRupiah(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{0, "Rp 0"},
+		{1234567, "Rp 1.234.567"},
+		{1500.05, "Rp 1.500,05"},
+		{0.1, "Rp 0,10"},
+		{-1234.5, "-Rp 1.234,50"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatRupiah(tt.amount); got != tt.want {
+			t.Errorf("FormatRupiah(%v) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
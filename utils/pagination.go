@@ -10,6 +10,14 @@ type PaginationParameter struct {
 	PageSize  int    `json:"page_size" validate:"omitempty,min=1"`
 	SortBy    string `json:"sort_by,omitempty"`
 	SortOrder string `json:"sort_order,omitempty" validate:"omitempty,oneof=asc desc"`
+
+	// Export receives every matching row instead of a page when the list is downloaded
+	Export RowWriter `json:"-" validate:"-"`
+}
+
+// RowWriter receives the rows of an exported list one at a time
+type RowWriter interface {
+	WriteRow(item interface{}) error
 }
 
 const (
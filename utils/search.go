@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SearchSimilarityThreshold is the minimum pg_trgm word similarity for a term to count as a typo match.
+// The "<%" operator used by the search reads it from pg_trgm.word_similarity_threshold, which migration
+// 022 sets to this value on the database.
+const SearchSimilarityThreshold = 0.3
+
+// SearchTerms splits a free-text search into lowercase alphanumeric terms
+func SearchTerms(search string) []string {
+	var terms []string
+	for _, word := range strings.Fields(strings.ToLower(search)) {
+		term := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, word)
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// likeEscaper escapes the ILIKE wildcards and the escape character itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// EscapeLike escapes user input for use inside an ILIKE pattern, so "%" and "_" match literally
+func EscapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// abbreviationPattern turns a consonant-only term such as "sbn" into "%s%b%n%" so it matches "Sabun"
+func abbreviationPattern(term string) string {
+	if strings.ContainsAny(term, "aiueo") || len([]rune(term)) < 2 {
+		return ""
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("%")
+	for _, r := range term {
+		pattern.WriteString(EscapeLike(string(r)))
+		pattern.WriteString("%")
+	}
+	return pattern.String()
+}
+
+// RelatedSearch is a column of related rows a term may match, such as the batch SKUs of a product.
+// From is the subquery source ending in the Where clause that ties it to the outer row.
+type RelatedSearch struct {
+	From   string
+	Column string
+}
+
+// AddSearch adds a fuzzy search condition where every term must match at least one of the fields.
+// A term matches a field by substring, by abbreviation (consonants in order), by word prefix or by
+// trigram similarity.
+func (qb *QueryBuilder) AddSearch(search string, fields ...string) *QueryBuilder {
+	return qb.AddSearchRelated(search, nil, fields...)
+}
+
+// AddSearchRelated is AddSearch where a term may also match a column of related rows. The related rows are
+// matched with Exists so the trigram index on the column is used instead of aggregating them per row.
+func (qb *QueryBuilder) AddSearchRelated(search string, related []RelatedSearch, fields ...string) *QueryBuilder {
+	terms := SearchTerms(search)
+	if len(terms) == 0 || (len(fields) == 0 && len(related) == 0) {
+		return qb
+	}
+
+	for _, term := range terms {
+		var conditions []string
+
+		// Substring match, served by the trigram GIN indexes
+		likeParam := qb.Count
+		qb.Params = append(qb.Params, "%"+EscapeLike(term)+"%")
+		qb.Count++
+
+		// Abbreviation match, only for terms without vowels
+		abbrParam := 0
+		if pattern := abbreviationPattern(term); pattern != "" {
+			abbrParam = qb.Count
+			qb.Params = append(qb.Params, pattern)
+			qb.Count++
+		}
+
+		// Typo tolerant match, "<%" can use the trigram GIN indexes where Word_Similarity can not
+		termParam := qb.Count
+		qb.Params = append(qb.Params, term)
+		qb.Count++
+
+		// Word prefix match, served by the full-text GIN indexes
+		tsParam := qb.Count
+		qb.Params = append(qb.Params, term+":*")
+		qb.Count++
+
+		for _, field := range fields {
+			conditions = append(conditions, fmt.Sprintf("%s ILIKE $%d", field, likeParam))
+			if abbrParam != 0 {
+				conditions = append(conditions, fmt.Sprintf("%s ILIKE $%d", field, abbrParam))
+			}
+			conditions = append(conditions,
+				fmt.Sprintf("$%d <%% %s", termParam, field),
+				fmt.Sprintf("To_Tsvector('simple', %s) @@ To_Tsquery('simple', $%d)", field, tsParam),
+			)
+		}
+		for _, rel := range related {
+			conditions = append(conditions, fmt.Sprintf("Exists(Select 1 From %s And (%s ILIKE $%d Or $%d <%% %s))",
+				rel.From, rel.Column, likeParam, termParam, rel.Column))
+		}
+
+		qb.Query.WriteString(" AND (" + strings.Join(conditions, " Or ") + ")")
+	}
+
+	return qb
+}
+
+// SearchRank returns an expression ranking a row against the search, combining full-text and trigram scores.
+// The returned expression references new parameters, so it must be written to the query after any filters.
+func (qb *QueryBuilder) SearchRank(search string, fields ...string) string {
+	terms := SearchTerms(search)
+	if len(terms) == 0 || len(fields) == 0 {
+		return ""
+	}
+
+	// Prefix query such as "sbn:* & cair:*", terms are already stripped of tsquery operators
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + ":*"
+	}
+
+	tsParam := qb.Count
+	qb.Params = append(qb.Params, strings.Join(prefixes, " & "))
+	qb.Count++
+
+	termParam := qb.Count
+	qb.Params = append(qb.Params, strings.Join(terms, " "))
+	qb.Count++
+
+	similarities := make([]string, len(fields))
+	for i, field := range fields {
+		similarities[i] = fmt.Sprintf("Coalesce(Word_Similarity($%d, %s), 0)", termParam, field)
+	}
+
+	return fmt.Sprintf(
+		"(Ts_Rank(To_Tsvector('simple', Concat_Ws(' ', %s)), To_Tsquery('simple', $%d)) + Greatest(%s))",
+		strings.Join(fields, ", "),
+		tsParam,
+		strings.Join(similarities, ", "),
+	)
+}
@@ -0,0 +1,393 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sinartimur-go/pkg/dto"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ReadSpreadsheet reads the rows of a CSV or XLSX file, workbooks are read from their first sheet.
+// CSV files may use a comma or a semicolon as separator, as exported by Excel with an Indonesian locale.
+func ReadSpreadsheet(r io.Reader, fileName string) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return readCSV(r)
+	case ".xlsx":
+		return readXLSX(r)
+	default:
+		return nil, fmt.Errorf("format file tidak didukung, gunakan CSV atau XLSX")
+	}
+}
+
+// readCSV reads a CSV file, detecting the separator from the header line
+func readCSV(r io.Reader) ([][]string, error) {
+	buffered := bufio.NewReader(r)
+	firstLine, err := buffered.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("gagal membaca file CSV: %w", err)
+	}
+	firstLine = bytes.TrimPrefix(firstLine, []byte("\xef\xbb\xbf"))
+	if end := bytes.IndexByte(firstLine, '\n'); end >= 0 {
+		firstLine = firstLine[:end]
+	}
+
+	// Skip the UTF-8 byte order mark written by Excel
+	if bom, _ := buffered.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		buffered.Discard(3)
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca file CSV: %w", err)
+	}
+	return rows, nil
+}
+
+// readXLSX reads the first sheet of a workbook
+func readXLSX(r io.Reader) ([][]string, error) {
+	workbook, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuka file XLSX: %w", err)
+	}
+	defer workbook.Close()
+
+	sheets := workbook.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("file XLSX tidak memiliki sheet")
+	}
+
+	rows, err := workbook.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca file XLSX: %w", err)
+	}
+	return rows, nil
+}
+
+// Export formats accepted by the format query parameter of list endpoints
+const (
+	ExportCSV  = "csv"
+	ExportXLSX = "xlsx"
+)
+
+// Kinds of exported cells
+const (
+	ExportText     = iota
+	ExportNumber   // quantity, written with Indonesian separators in CSV
+	ExportRupiah   // money amount, written as Rp 1.234.567
+	ExportDate     // RFC3339 value shown as DD/MM/YYYY
+	ExportDateTime // RFC3339 value shown as DD/MM/YYYY HH:MM
+)
+
+// ExportColumn is a column of an exported list, Field is the json name of the item field
+type ExportColumn struct {
+	Header string
+	Field  string
+	Kind   int
+}
+
+// ExportFormat returns the requested export format, empty when the list is requested as JSON
+func ExportFormat(r *http.Request) (string, *dto.APIError) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	switch format {
+	case "", "json":
+		return "", nil
+	case ExportCSV, ExportXLSX:
+		return format, nil
+	default:
+		return "", dto.NewAPIError(http.StatusBadRequest, map[string]string{
+			"format": "Format harus csv atau xlsx",
+		})
+	}
+}
+
+// WriteExport writes a slice of structs as a CSV or XLSX download with the given columns, the file name is without extension
+func WriteExport(w http.ResponseWriter, format, fileName string, columns []ExportColumn, items interface{}) {
+	export := NewExportWriter(w, format, fileName, columns)
+	if list := reflect.ValueOf(items); list.Kind() == reflect.Slice {
+		for i := 0; i < list.Len(); i++ {
+			if export.WriteRow(list.Index(i).Interface()) != nil {
+				break
+			}
+		}
+	}
+	export.Finish(nil)
+}
+
+// ExportWriter streams the rows of an exported list as a CSV or XLSX download. It is passed as the Export of a
+// PaginationParameter so repositories hand over each row as it is scanned instead of collecting the whole list.
+type ExportWriter struct {
+	w        http.ResponseWriter
+	format   string
+	fileName string
+	columns  []ExportColumn
+	rows     int
+	csv      *csv.Writer
+	workbook *excelize.File
+	stream   *excelize.StreamWriter
+	styles   map[int]int
+	err      error
+}
+
+// NewExportWriter creates the export of a list, the file name is without extension
+func NewExportWriter(w http.ResponseWriter, format, fileName string, columns []ExportColumn) *ExportWriter {
+	return &ExportWriter{
+		w:        w,
+		format:   format,
+		fileName: fmt.Sprintf("%s_%s.%s", fileName, time.Now().Format("20060102"), format),
+		columns:  columns,
+	}
+}
+
+// WriteRow writes an item of the list
+func (e *ExportWriter) WriteRow(item interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.err = e.start(); e.err != nil {
+		return e.err
+	}
+
+	value := reflect.ValueOf(item)
+	e.rows++
+	if e.csv != nil {
+		record := make([]string, len(e.columns))
+		for i, column := range e.columns {
+			record[i] = formatExportCell(column.Kind, exportField(value, column.Field))
+		}
+		e.err = e.csv.Write(record)
+		return e.err
+	}
+
+	row := make([]interface{}, len(e.columns))
+	for i, column := range e.columns {
+		row[i] = excelize.Cell{StyleID: e.styles[column.Kind], Value: exportCellValue(column.Kind, exportField(value, column.Field))}
+	}
+	cell, _ := excelize.CoordinatesToCellName(1, e.rows+1)
+	e.err = e.stream.SetRow(cell, row)
+	return e.err
+}
+
+// Finish completes the download. A list that failed before anything was sent is answered with the error as JSON,
+// a failure halfway through a CSV download can only be logged as the response is already under way.
+func (e *ExportWriter) Finish(apiErr *dto.APIError) {
+	if e.workbook != nil {
+		defer e.workbook.Close()
+	}
+	if apiErr != nil && e.csv == nil {
+		ErrorJSON(e.w, apiErr)
+		return
+	}
+	if apiErr != nil {
+		e.csv.Flush()
+		log.Printf("gagal menulis ekspor %s: %v", e.fileName, apiErr.Details)
+		return
+	}
+
+	err := e.err
+	if err == nil {
+		err = e.finish()
+	}
+	if err != nil {
+		log.Printf("gagal menulis ekspor %s: %v", e.fileName, err)
+	}
+}
+
+// start writes the header row, deferred to the first row so a list failing on its query is still answered as JSON
+func (e *ExportWriter) start() error {
+	if e.csv != nil || e.stream != nil {
+		return nil
+	}
+	if e.format == ExportXLSX {
+		return e.startXLSX()
+	}
+	return e.startCSV()
+}
+
+// startCSV sends the download with a byte order mark so Excel opens the file as UTF-8
+func (e *ExportWriter) startCSV() error {
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.fileName))
+	e.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	if _, err := e.w.Write([]byte("\xef\xbb\xbf")); err != nil {
+		return err
+	}
+
+	e.csv = csv.NewWriter(e.w)
+	header := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		header[i] = column.Header
+	}
+	return e.csv.Write(header)
+}
+
+// startXLSX opens a single sheet workbook, amounts and dates keep their type with a display format.
+// The stream writer keeps large sheets on disk until the workbook is sent by finish.
+func (e *ExportWriter) startXLSX() error {
+	e.workbook = excelize.NewFile()
+	stream, err := e.workbook.NewStreamWriter(e.workbook.GetSheetName(0))
+	if err != nil {
+		return err
+	}
+
+	headerStyle, err := e.workbook.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	formats := map[int]string{
+		ExportRupiah:   `"Rp"\ #,##0`,
+		ExportDate:     "dd/mm/yyyy",
+		ExportDateTime: "dd/mm/yyyy hh:mm",
+	}
+	e.styles = make(map[int]int)
+	for kind, numberFormat := range formats {
+		numberFormat := numberFormat
+		if e.styles[kind], err = e.workbook.NewStyle(&excelize.Style{CustomNumFmt: &numberFormat}); err != nil {
+			return err
+		}
+	}
+
+	header := make([]interface{}, len(e.columns))
+	for i, column := range e.columns {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: column.Header}
+	}
+	if err = stream.SetRow("A1", header); err != nil {
+		return err
+	}
+	e.stream = stream
+	return nil
+}
+
+// finish writes what is left of the file, an empty list still gets its header row
+func (e *ExportWriter) finish() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+
+	if err := e.stream.Flush(); err != nil {
+		return err
+	}
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.fileName))
+	e.w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	return e.workbook.Write(e.w)
+}
+
+// exportField returns the field of an item by its json name, nil for missing fields and nil pointers
+func exportField(item reflect.Value, field string) interface{} {
+	for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+		if item.IsNil() {
+			return nil
+		}
+		item = item.Elem()
+	}
+
+	itemType := item.Type()
+	for i := 0; i < itemType.NumField(); i++ {
+		if strings.Split(itemType.Field(i).Tag.Get("json"), ",")[0] != field {
+			continue
+		}
+		value := item.Field(i)
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return nil
+			}
+			value = value.Elem()
+		}
+		return value.Interface()
+	}
+	return nil
+}
+
+// exportCellValue converts a field to the typed value of an XLSX cell
+func exportCellValue(kind int, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if flag, ok := value.(bool); ok {
+		return yesNo(flag)
+	}
+
+	switch kind {
+	case ExportNumber, ExportRupiah:
+		if number, ok := toFloat(value); ok {
+			return number
+		}
+	case ExportDate, ExportDateTime:
+		if date, err := time.Parse(time.RFC3339, fmt.Sprint(value)); err == nil {
+			return date
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// formatExportCell formats a field as CSV text
+func formatExportCell(kind int, value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if flag, ok := value.(bool); ok {
+		return yesNo(flag)
+	}
+
+	switch kind {
+	case ExportNumber:
+		if number, ok := toFloat(value); ok {
+			return FormatNumber(number)
+		}
+	case ExportRupiah:
+		if number, ok := toFloat(value); ok {
+			return FormatRupiah(number)
+		}
+	case ExportDate:
+		if date, err := time.Parse(time.RFC3339, fmt.Sprint(value)); err == nil {
+			return date.Format("02/01/2006")
+		}
+	case ExportDateTime:
+		if date, err := time.Parse(time.RFC3339, fmt.Sprint(value)); err == nil {
+			return date.Format("02/01/2006 15:04")
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// yesNo shows a flag as Ya or Tidak
+func yesNo(flag bool) string {
+	if flag {
+		return "Ya"
+	}
+	return "Tidak"
+}
+
+// toFloat converts a numeric field to float64
+func toFloat(value interface{}) (float64, bool) {
+	number := reflect.ValueOf(value)
+	switch number.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return number.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(number.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(number.Uint()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"math"
+	"strings"
+)
+
+var terbilangDigits = []string{"", "satu", "dua", "tiga", "empat", "lima", "enam", "tujuh", "delapan", "sembilan", "sepuluh", "sebelas"}
+
+// terbilangScales are the named powers of a thousand, largest first
+var terbilangScales = []struct {
+	value int64
+	name  string
+}{
+	{1_000_000_000_000, "triliun"},
+	{1_000_000_000, "miliar"},
+	{1_000_000, "juta"},
+	{1_000, "ribu"},
+}
+
+// spell spells out a whole number in Indonesian words
+func spell(n int64) string {
+	switch {
+	case n < 12:
+		return terbilangDigits[n]
+	case n < 20:
+		return spell(n-10) + " belas"
+	case n < 100:
+		return spell(n/10) + " puluh " + spell(n%10)
+	case n < 200:
+		return "seratus " + spell(n-100)
+	case n < 1000:
+		return spell(n/100) + " ratus " + spell(n%100)
+	case n < 2000:
+		return "seribu " + spell(n-1000)
+	}
+
+	for _, scale := range terbilangScales {
+		if n >= scale.value {
+			return spell(n/scale.value) + " " + scale.name + " " + spell(n%scale.value)
+		}
+	}
+	return ""
+}
+
+// Terbilang spells out a rupiah amount, e.g. 1250000 becomes "Satu juta dua ratus lima puluh ribu rupiah"
+func Terbilang(amount float64) string {
+	negative := amount < 0
+	amount = math.Round(math.Abs(amount)*100) / 100
+	rupiah := int64(amount)
+	sen := int64(math.Round((amount - float64(rupiah)) * 100))
+
+	words := "nol"
+	if rupiah > 0 {
+		words = spell(rupiah)
+	}
+	words += " rupiah"
+	if sen > 0 {
+		words += " " + spell(sen) + " sen"
+	}
+	if negative {
+		words = "minus " + words
+	}
+
+	words = strings.Join(strings.Fields(words), " ")
+	return strings.ToUpper(words[:1]) + words[1:]
+}
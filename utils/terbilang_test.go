@@ -0,0 +1,33 @@
+package utils
+
+import "testing"
+
+func TestTerbilang(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{0, "Nol rupiah"},
+		{1, "Satu rupiah"},
+		{11, "Sebelas rupiah"},
+		{12, "Dua belas rupiah"},
+		{20, "Dua puluh rupiah"},
+		{100, "Seratus rupiah"},
+		{110, "Seratus sepuluh rupiah"},
+		{1000, "Seribu rupiah"},
+		{100000, "Seratus ribu rupiah"},
+		{1001000, "Satu juta seribu rupiah"},
+		{1250000, "Satu juta dua ratus lima puluh ribu rupiah"},
+		{2000000000, "Dua miliar rupiah"},
+		{1000000000000, "Satu triliun rupiah"},
+		{1500.5, "Seribu lima ratus rupiah lima puluh sen"},
+		{0.01, "Nol rupiah satu sen"},
+		{-2500, "Minus dua ribu lima ratus rupiah"},
+	}
+
+	for _, tt := range tests {
+		if got := Terbilang(tt.amount); got != tt.want {
+			t.Errorf("Terbilang(%v) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
@@ -34,6 +34,9 @@ var validationMessages = map[string]string{
 	"ltfield":  "Harus lebih kecil dari %s.",
 	"gtefield": "Harus lebih besar atau sama dengan %s.",
 	"ltefield": "Harus lebih kecil atau sama dengan %s.",
+
+	"required_without": "Wajib diisi jika %s kosong.",
+	"excluded_with":    "Harus kosong jika %s diisi.",
 }
 
 // Validator instance